@@ -0,0 +1,546 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/klauspost/compress/s2"
+)
+
+// maxAdvice is the maximum number of filters tracked by the index advisor
+const maxAdvice = 256
+
+// AdvisorPolicy represents the policy of the index advisor, which keeps track of the filters
+// which repeatedly scan a large number of rows and advises an index for them.
+type AdvisorPolicy struct {
+	MinScans     int  // The number of scans after which an index is advised, 10 by default
+	MinRows      int  // The average number of rows scanned for an index to be advised, a chunk by default
+	AutoIndex    bool // Whether the indexes advised for the range and equality filters are created automatically
+	MemoryBudget int  // The memory, in bytes, the indexes created automatically may use (optional)
+}
+
+// IndexAdvice represents a filter which repeatedly scans a large number of rows, and would
+// benefit from an index.
+type IndexAdvice struct {
+	Filter  string  // The filter scanning the column, for example "WithFloatGreater(age)"
+	Column  string  // The column scanned by the filter
+	Lo, Hi  float64 // The inclusive bounds of the range filters
+	Scans   uint64  // The number of times the filter scanned the column
+	Scanned uint64  // The total number of rows scanned by the filter
+	Index   string  // The index created automatically for the filter, if any
+}
+
+// adviceKey represents a filter tracked by the advisor. The range filters are tracked by
+// their bounds, since an index can only be created for a specific range, while the other
+// filters are tracked regardless of their predicate or value.
+type adviceKey struct {
+	kind   filterKind
+	column string
+	lo, hi float64
+}
+
+// adviceEntry represents the scans of a filter
+type adviceEntry struct {
+	scans    uint64 // The number of scans
+	scanned  uint64 // The number of rows scanned
+	used     uint64 // The last time the filter was applied
+	index    string // The index created automatically, if any
+	pending  bool   // Whether the index is being created
+	rejected bool   // Whether the index exceeded the memory budget on its own
+}
+
+// advisor keeps track of the filters which scan the columns
+type advisor struct {
+	lock    sync.Mutex
+	policy  AdvisorPolicy
+	tick    uint64
+	entries map[adviceKey]*adviceEntry
+}
+
+// newAdvisor creates a new index advisor with a policy, or with the default one
+func newAdvisor(policy *AdvisorPolicy) *advisor {
+	a := &advisor{
+		entries: make(map[adviceKey]*adviceEntry, 16),
+	}
+
+	if policy != nil {
+		a.policy = *policy
+	}
+	if a.policy.MinScans <= 0 {
+		a.policy.MinScans = 10
+	}
+	if a.policy.MinRows <= 0 {
+		a.policy.MinRows = chunkSize
+	}
+	return a
+}
+
+// IndexAdvice returns the filters which repeatedly scanned a large number of rows, ordered
+// by the number of rows they scanned. The range filters are reported by their bounds, and
+// the other filters regardless of their predicate or value. If the AutoIndex option of the
+// advisor policy is set, the indexes for the range and equality filters are created in the
+// background, and the filters use them instead of scanning the columns.
+func (c *Collection) IndexAdvice() []IndexAdvice {
+	a := c.advisor
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var out []IndexAdvice
+	for key, entry := range a.entries {
+		if !a.advised(entry) && entry.index == "" {
+			continue
+		}
+
+		out = append(out, IndexAdvice{
+			Filter:  filter{kind: key.kind, column: key.column}.String(),
+			Column:  key.column,
+			Lo:      key.lo,
+			Hi:      key.hi,
+			Scans:   entry.scans,
+			Scanned: entry.scanned,
+			Index:   entry.index,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Scanned > out[j].Scanned
+	})
+	return out
+}
+
+// keyOf returns the key of a filter
+func keyOf(f *filter) adviceKey {
+	key := adviceKey{kind: f.kind, column: f.column}
+	switch f.kind {
+	case filterGreater, filterLess, filterBetween:
+		key.lo, key.hi = f.lo, f.hi
+	}
+	return key
+}
+
+// advised checks whether an index is advised for the filter
+func (a *advisor) advised(entry *adviceEntry) bool {
+	return entry.scans >= uint64(a.policy.MinScans) &&
+		entry.scanned >= entry.scans*uint64(a.policy.MinRows)
+}
+
+// observe records the rows scanned by a filter, and creates an index for it in the
+// background once one is advised, if the policy allows it.
+func (a *advisor) observe(owner *Collection, f *filter, scanned uint64) {
+	if f.indexed() {
+		return
+	}
+
+	key := keyOf(f)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.tick++
+
+	entry, ok := a.entries[key]
+	if !ok {
+		if len(a.entries) >= maxAdvice && !a.evictEntry() {
+			return
+		}
+
+		entry = new(adviceEntry)
+		a.entries[key] = entry
+	}
+
+	entry.used = a.tick
+	if entry.index != "" {
+		return // Already indexed
+	}
+
+	entry.scans++
+	entry.scanned += scanned
+	if a.policy.AutoIndex && a.advised(entry) && !entry.pending && !entry.rejected && indexable(owner, key) {
+		entry.pending = true
+		go owner.autoIndex(key)
+	}
+}
+
+// evictEntry removes the least recently used entry without an index
+func (a *advisor) evictEntry() bool {
+	var oldest *adviceEntry
+	var oldestKey adviceKey
+	for key, entry := range a.entries {
+		if entry.index == "" && !entry.pending && (oldest == nil || entry.used < oldest.used) {
+			oldest, oldestKey = entry, key
+		}
+	}
+
+	if oldest != nil {
+		delete(a.entries, oldestKey)
+	}
+	return oldest != nil
+}
+
+// indexFor returns the index created automatically for a range of a column, if any. This
+// must be called within a transaction, since the indexes are only created and dropped
+// while the transactions are excluded.
+func (a *advisor) indexFor(column string, lo, hi float64) (string, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, kind := range []filterKind{filterBetween, filterGreater, filterLess} {
+		if entry, ok := a.entries[adviceKey{kind, column, lo, hi}]; ok && entry.index != "" {
+			a.tick++
+			entry.used = a.tick
+			return entry.index, true
+		}
+	}
+	return "", false
+}
+
+// indexable checks whether an index can be created automatically for a filter
+func indexable(owner *Collection, key adviceKey) bool {
+	column, ok := owner.cols.Load(key.column)
+	switch {
+	case !ok:
+		return false
+	case key.kind == filterGreater, key.kind == filterLess, key.kind == filterBetween:
+		return column.IsNumeric()
+	case key.kind == filterEqual:
+		return column.IsTextual() && collationOf(column.Column) == CollateBinary
+	default:
+		return false
+	}
+}
+
+// autoIndex creates the index advised for a filter, while the transactions are excluded so
+// that none of them sees it before it is complete, and then drops the least recently used
+// indexes created automatically if they exceed the memory budget.
+func (c *Collection) autoIndex(key adviceKey) {
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+
+	var err error
+	name := fmt.Sprintf("auto:%s:%g:%g", key.column, key.lo, key.hi)
+	switch {
+	case c.ctx.Err() != nil:
+		err = c.ctx.Err()
+	case key.kind == filterEqual:
+		name = "auto:" + key.column
+		err = c.CreateLookup(name, key.column)
+	default:
+		err = c.createRangeIndex(name, key.column, key.lo, key.hi)
+	}
+
+	a := c.advisor
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	entry, ok := a.entries[key]
+	if !ok {
+		return
+	}
+
+	entry.pending = false
+	if err != nil {
+		entry.rejected = true
+		return
+	}
+
+	a.tick++
+	entry.index = name
+	entry.used = a.tick
+	c.evictIndexes(entry)
+}
+
+// createRangeIndex creates an index of the rows whose values are within the inclusive range
+func (c *Collection) createRangeIndex(indexName, columnName string, lo, hi float64) error {
+	target, ok := c.cols.Load(columnName)
+	if !ok {
+		return errorOf(ErrColumnNotFound, "column: unable to create index, column '%v' does not exist", columnName)
+	}
+
+	return c.CreateIndex(indexName, columnName, func(r Reader) bool {
+		if r, ok := r.(*commit.Reader); ok {
+			v, ok := asFloat64(valueOf(target.Column, r))
+			return ok && v >= lo && v <= hi
+		}
+		return false
+	})
+}
+
+// evictIndexes drops the least recently used indexes created automatically, until they fit
+// into the memory budget. This is called while the transactions are excluded.
+func (c *Collection) evictIndexes(created *adviceEntry) {
+	a := c.advisor
+	if a.policy.MemoryBudget <= 0 {
+		return
+	}
+
+	for {
+		var total int
+		var oldest *adviceEntry
+		for _, entry := range a.entries {
+			if entry.index == "" {
+				continue
+			}
+
+			if column, ok := c.cols.Load(entry.index); ok {
+				total += usageOf(column).Total()
+			}
+			if oldest == nil || entry.used < oldest.used {
+				oldest = entry
+			}
+		}
+
+		if total <= a.policy.MemoryBudget || oldest == nil {
+			return
+		}
+
+		// If the index created does not fit on its own, it is never created again
+		c.DropIndex(oldest.index)
+		oldest.index = ""
+		oldest.rejected = oldest == created
+		oldest.scans, oldest.scanned = 0, 0
+	}
+}
+
+// --------------------------- Storage Advice ----------------------------
+
+const (
+	maxDistinct    = 1 << 16 // The maximum number of distinct strings counted by the storage advisor
+	minAdviceRows  = 100     // The minimum number of rows for the storage advisor to suggest a change
+	maxEnumPercent = 10      // The maximum ratio of distinct values to rows, in percent, of a string column advised as an enum
+)
+
+// StorageAdvice represents the storage of a column, and the changes of its type or encoding
+// which would store its values more compactly.
+type StorageAdvice struct {
+	Column     string   // The name of the column
+	Type       string   // The type of the column, for example "int64" or "enum"
+	Rows       int      // The number of values in the column
+	Raw        int      // The size of the values as they are written in a snapshot, in bytes
+	Compressed int      // The size of the values once compressed, in bytes
+	Ratio      float64  // The compression ratio, the raw size over the compressed size
+	Advice     []string // The suggested changes, for example "age fits in uint8"
+}
+
+// storageProfile represents the values of a column, as seen by the storage advisor
+type storageProfile struct {
+	numbers  int                 // The number of numeric values
+	integral bool                // Whether all of the numeric values are integers
+	sorted   bool                // Whether the numeric values are in ascending order of their rows
+	min, max float64             // The range of the numeric values
+	last     float64             // The last numeric value
+	runs     int                 // The number of runs of repeated numeric values
+	distinct map[string]struct{} // The distinct strings, nil once there are too many of them
+}
+
+// StorageAdvice reads the values of every column, as they are written in a snapshot, and
+// reports their raw and compressed sizes along with the changes which would store them more
+// compactly: a narrower type for the integers, the Delta or RLE encoding for the sorted or
+// repeated numbers, an enum for the strings with few distinct values, or a string for the
+// enums whose dictionary holds about as many values as there are rows. The columns are
+// ordered by their compressed size, the largest first. Since every value is read, this is
+// meant to guide the tuning of a schema rather than to be called frequently.
+func (c *Collection) StorageAdvice() ([]StorageAdvice, error) {
+	buffer := c.txns.acquirePage(rowColumn)
+	defer c.txns.releasePage(buffer)
+
+	var out []StorageAdvice
+	var encoded []byte
+	var raw bytes.Buffer
+	reader := commit.NewReader()
+	for _, v := range c.snapshotColumns() {
+		if v.IsIndex() || c.isInternal(v.name) {
+			continue
+		}
+
+		advice := StorageAdvice{Column: v.name, Type: typeName(v.Column)}
+		profile := storageProfile{
+			integral: true,
+			sorted:   true,
+			distinct: make(map[string]struct{}, 16),
+		}
+
+		for i := 0; i < c.chunks(); i++ {
+			if err := c.readChunk(commit.Chunk(i), func(_ uint64, chunk commit.Chunk, _ bitmap.Bitmap) error {
+				if !v.Snapshot(chunk, buffer) || buffer.IsEmpty() {
+					return nil
+				}
+
+				raw.Reset()
+				if _, err := buffer.WriteTo(&raw); err != nil {
+					return err
+				}
+
+				encoded = s2.Encode(encoded[:0], raw.Bytes())
+				advice.Raw += raw.Len()
+				advice.Compressed += len(encoded)
+				for reader.Seek(buffer); reader.Next(); advice.Rows++ {
+					profile.observe(valueOf(v.Column, reader))
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		if advice.Compressed > 0 {
+			advice.Ratio = float64(advice.Raw) / float64(advice.Compressed)
+		}
+		advice.Advice = profile.advise(v, advice.Rows)
+		out = append(out, advice)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Compressed > out[j].Compressed
+	})
+	return out, nil
+}
+
+// observe profiles a value of the column
+func (p *storageProfile) observe(value interface{}) {
+	if s, ok := value.(string); ok {
+		if p.distinct != nil {
+			p.distinct[s] = struct{}{}
+		}
+		if len(p.distinct) > maxDistinct {
+			p.distinct = nil
+		}
+		return
+	}
+
+	v, ok := asFloat64(value)
+	if !ok {
+		return
+	}
+
+	switch {
+	case p.numbers == 0:
+		p.min, p.max, p.runs = v, v, 1
+	case v != p.last:
+		p.runs++
+	}
+
+	p.min = math.Min(p.min, v)
+	p.max = math.Max(p.max, v)
+	p.integral = p.integral && v == math.Trunc(v)
+	p.sorted = p.sorted && (p.numbers == 0 || v >= p.last)
+	p.last = v
+	p.numbers++
+}
+
+// advise returns the changes which would store the values of the column more compactly
+func (p *storageProfile) advise(v *column, rows int) (out []string) {
+	if rows < minAdviceRows {
+		return nil
+	}
+
+	switch column := v.Column.(type) {
+	case *columnEnum:
+		if p.distinct == nil || len(p.distinct)*10 >= rows*9 {
+			out = append(out, fmt.Sprintf("%s dictionary is 1:1, use string", v.name))
+		}
+	case *columnString:
+		if p.distinct != nil && len(p.distinct)*100 <= rows*maxEnumPercent {
+			out = append(out, fmt.Sprintf("%s has %d distinct values, use enum", v.name, len(p.distinct)))
+		}
+	case interface{ Encoding() Encoding }:
+		if p.numbers < minAdviceRows || !p.integral {
+			return nil
+		}
+
+		if fit := fitOf(p.min, p.max); sizeOfType[fit] < sizeOfType[typeName(v.Column)] {
+			out = append(out, fmt.Sprintf("%s fits in %s", v.name, fit))
+		}
+
+		if column.Encoding() != Plain {
+			return
+		}
+
+		switch {
+		case p.runs*8 <= p.numbers:
+			out = append(out, fmt.Sprintf("%s repeats its values, use the RLE encoding", v.name))
+		case p.sorted:
+			out = append(out, fmt.Sprintf("%s is sorted, use the Delta encoding", v.name))
+		}
+	}
+	return
+}
+
+// sizeOfType is the size, in bytes, of the numeric types a column can be narrowed to
+var sizeOfType = map[string]int{
+	"int8": 1, "uint8": 1, "int16": 2, "uint16": 2, "int32": 4, "uint32": 4, "float32": 4,
+	"int": 8, "int64": 8, "uint": 8, "uint64": 8, "float64": 8,
+}
+
+// fitOf returns the narrowest integer type which fits the range
+func fitOf(min, max float64) string {
+	switch {
+	case min >= 0 && max <= math.MaxUint8:
+		return "uint8"
+	case min >= math.MinInt8 && max <= math.MaxInt8:
+		return "int8"
+	case min >= 0 && max <= math.MaxUint16:
+		return "uint16"
+	case min >= math.MinInt16 && max <= math.MaxInt16:
+		return "int16"
+	case min >= 0 && max <= math.MaxUint32:
+		return "uint32"
+	case min >= math.MinInt32 && max <= math.MaxInt32:
+		return "int32"
+	case min >= 0:
+		return "uint64"
+	default:
+		return "int64"
+	}
+}
+
+// typeName returns the name of the type of a column
+func typeName(column Column) string {
+	switch column.(type) {
+	case *float32Column:
+		return "float32"
+	case *float64Column:
+		return "float64"
+	case *intColumn:
+		return "int"
+	case *int8Column:
+		return "int8"
+	case *int16Column:
+		return "int16"
+	case *int32Column:
+		return "int32"
+	case *int64Column:
+		return "int64"
+	case *columnDuration:
+		return "duration"
+	case *uintColumn:
+		return "uint"
+	case *uint8Column:
+		return "uint8"
+	case *uint16Column:
+		return "uint16"
+	case *uint32Column:
+		return "uint32"
+	case *uint64Column:
+		return "uint64"
+	case *columnBool:
+		return "bool"
+	case *columnString:
+		return "string"
+	case *columnEnum:
+		return "enum"
+	case *columnKey:
+		return "key"
+	case *columnMap:
+		return "map"
+	case *columnIP:
+		return "ip"
+	case *columnInt128:
+		return "int128"
+	default:
+		return fmt.Sprintf("%T", column)
+	}
+}
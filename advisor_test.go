@@ -0,0 +1,189 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexAdvice(t *testing.T) {
+	col := newAdvised(nil)
+	defer col.Close()
+
+	for i := 0; i < 10; i++ {
+		countWhere(col, func(txn *Txn) *Txn {
+			return txn.WithFloatBetween("age", 18, 30)
+		})
+		countWhere(col, func(txn *Txn) *Txn {
+			return txn.With("old").WithValue("name", func(v interface{}) bool {
+				return strings.HasSuffix(v.(string), "7")
+			})
+		})
+	}
+
+	// Filters which were not repeated often enough are not advised
+	countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatBetween("age", 18, 31)
+	})
+
+	advice := col.IndexAdvice()
+	assert.Len(t, advice, 2)
+	assert.Equal(t, "WithFloatBetween(age)", advice[0].Filter)
+	assert.Equal(t, "age", advice[0].Column)
+	assert.Equal(t, 18.0, advice[0].Lo)
+	assert.Equal(t, 30.0, advice[0].Hi)
+	assert.Equal(t, uint64(10), advice[0].Scans)
+	assert.Equal(t, uint64(10*50000), advice[0].Scanned)
+	assert.Equal(t, "WithValue(name)", advice[1].Filter)
+	assert.Equal(t, "", advice[1].Index)
+	assert.Less(t, advice[1].Scanned, advice[0].Scanned)
+}
+
+func TestAutoIndex(t *testing.T) {
+	col := newAdvised(&AdvisorPolicy{
+		MinScans:  3,
+		AutoIndex: true,
+	})
+	defer col.Close()
+
+	adults := func(txn *Txn) *Txn { return txn.WithFloatGreater("age", 17) }
+	named := func(txn *Txn) *Txn { return txn.WithStringEqual("name", "player 42") }
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 41000, countWhere(col, adults))
+		assert.Equal(t, 1, countWhere(col, named))
+	}
+
+	// The indexes are created in the background
+	assert.Eventually(t, func() bool {
+		advice := col.IndexAdvice()
+		return len(advice) == 2 && advice[0].Index != "" && advice[1].Index != ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, ok := col.cols.Load("auto:name")
+	assert.True(t, ok)
+
+	// The filters use the indexes, which are kept up to date
+	col.QueryAt(1, func(r Row) error {
+		r.SetFloat64("age", 50)
+		r.SetString("name", "player 42")
+		return nil
+	})
+	assert.True(t, col.DeleteAt(42))
+	assert.Equal(t, 41000, countWhere(col, adults))
+	assert.Equal(t, 1, countWhere(col, named))
+	assert.Equal(t, 8999, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatLess("age", 18)
+	}))
+
+	// The scans of the indexed filters are no longer counted
+	for _, advice := range col.IndexAdvice() {
+		assert.Equal(t, uint64(3), advice.Scans)
+	}
+}
+
+func TestAutoIndexBudget(t *testing.T) {
+	col := newAdvised(&AdvisorPolicy{
+		MinScans:     2,
+		MinRows:      1,
+		AutoIndex:    true,
+		MemoryBudget: 1,
+	})
+	defer col.Close()
+
+	// The index does not fit into the budget, so it is dropped and not created again
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 41000, countWhere(col, func(txn *Txn) *Txn {
+			return txn.WithFloatGreater("age", 17)
+		}))
+	}
+
+	assert.Eventually(t, func() bool {
+		col.advisor.lock.Lock()
+		defer col.advisor.lock.Unlock()
+		for _, entry := range col.advisor.entries {
+			return entry.rejected
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	col.cols.Range(func(v *column) {
+		assert.False(t, strings.HasPrefix(v.name, "auto:"))
+	})
+}
+
+// newAdvised creates a collection of players with an index advisor
+func newAdvised(policy *AdvisorPolicy) *Collection {
+	col := NewCollection(Options{
+		Advisor: policy,
+	})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForFloat64())
+	col.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Float() >= 60
+	})
+
+	for i := 0; i < 50000; i++ {
+		col.InsertObject(Object{
+			"name": "player " + strings.Repeat("x", i%3) + string(rune('0'+i%10)),
+			"age":  float64(i % 100),
+		})
+	}
+
+	col.QueryAt(42, func(r Row) error {
+		r.SetString("name", "player 42")
+		return nil
+	})
+	return col
+}
+
+func TestStorageAdvice(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+
+	col.CreateColumn("age", ForInt64())
+	col.CreateColumn("time", ForInt64())
+	col.CreateColumn("level", ForUint32(WithEncoding(RLE)))
+	col.CreateColumn("class", ForString())
+	col.CreateColumn("serial", ForEnum())
+	col.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 60
+	})
+
+	for i := 0; i < 1000; i++ {
+		col.InsertObject(Object{
+			"age":    int64(i % 100),
+			"time":   int64(1e12) + int64(i*7),
+			"level":  uint32(i / 100),
+			"class":  fmt.Sprintf("class %d", i%4),
+			"serial": fmt.Sprintf("serial %d", i),
+		})
+	}
+
+	advice, err := col.StorageAdvice()
+	assert.NoError(t, err)
+	assert.Len(t, advice, 5)
+
+	byName := make(map[string]StorageAdvice, len(advice))
+	for i, v := range advice {
+		byName[v.Column] = v
+		assert.Equal(t, 1000, v.Rows)
+		assert.Greater(t, v.Raw, 0)
+		assert.Greater(t, v.Ratio, 0.0)
+		if i > 0 {
+			assert.LessOrEqual(t, v.Compressed, advice[i-1].Compressed)
+		}
+	}
+
+	assert.Equal(t, "int64", byName["age"].Type)
+	assert.Equal(t, []string{"age fits in uint8"}, byName["age"].Advice)
+	assert.Equal(t, []string{"time is sorted, use the Delta encoding"}, byName["time"].Advice)
+	assert.Equal(t, []string{"level fits in uint8"}, byName["level"].Advice)
+	assert.Equal(t, []string{"class has 4 distinct values, use enum"}, byName["class"].Advice)
+	assert.Equal(t, []string{"serial dictionary is 1:1, use string"}, byName["serial"].Advice)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Transform represents an anonymization of a column, applied to the values written into a
+// snapshot with SnapshotTransformed(), while the values of the collection remain intact.
+type Transform struct {
+	column  string                              // The name of the column
+	omit    bool                                // Whether the column is left out of the snapshot
+	textual bool                                // Whether the column must be a string or an enum
+	fn      func(value interface{}) interface{} // The function replacing the values, if any
+}
+
+// Omit creates a transform which leaves a column, along with its indexes, out of the snapshot.
+func Omit(columnName string) Transform {
+	return Transform{
+		column: columnName,
+		omit:   true,
+	}
+}
+
+// Redact creates a transform which replaces every value of a column with the one returned
+// by the function. Numbers are converted to the type of the column, and the values for
+// which the function returns nil are removed.
+func Redact(columnName string, fn func(value interface{}) interface{}) Transform {
+	return Transform{
+		column: columnName,
+		fn:     fn,
+	}
+}
+
+// Hash creates a transform which replaces every value of a string or enum column with its
+// HMAC-SHA256 for the key, encoded in hexadecimal. Equal values are hashed to equal values,
+// so that the rows can still be grouped or joined by the column, but without the key the
+// original values can not be recovered by hashing the likely ones.
+func Hash(columnName string, key []byte) Transform {
+	return Transform{
+		column:  columnName,
+		textual: true,
+		fn: func(value interface{}) interface{} {
+			h := hmac.New(sha256.New, key)
+			h.Write([]byte(value.(string)))
+			return hex.EncodeToString(h.Sum(nil))
+		},
+	}
+}
+
+// SnapshotTransformed writes a snapshot of the collection into the underlying writer, where
+// the columns are anonymized by the transforms, in the order they are given. This allows to
+// copy the production data to a staging environment in one step, without disclosing the
+// personal data it contains. The transforms are applied to a copy of the collection, so the
+// concurrent transactions are not blocked while the values are replaced.
+func (c *Collection) SnapshotTransformed(dst io.Writer, transforms ...Transform) error {
+	for _, t := range transforms {
+		column, ok := c.cols.Load(t.column)
+		switch {
+		case !ok:
+			return errorOf(ErrColumnNotFound, "column: unable to transform, column '%v' does not exist", t.column)
+		case column.IsIndex() && !t.omit:
+			return fmt.Errorf("column: unable to transform, column '%v' is an index", t.column)
+		case t.textual:
+			if _, ok := column.Column.(Textual); !ok {
+				return errorOf(ErrTypeMismatch, "column: unable to hash, column '%v' is not of type string", t.column)
+			}
+		}
+	}
+
+	view, err := c.clone()
+	if err != nil {
+		return err
+	}
+
+	defer view.Close()
+	for _, t := range transforms {
+		switch {
+		case t.omit:
+			if column, ok := view.cols.Load(t.column); ok && column.IsIndex() {
+				view.DropIndex(t.column)
+				continue
+			}
+			view.DropColumn(t.column)
+		case t.fn != nil:
+			if err := view.Query(func(txn *Txn) error {
+				return txn.transform(t)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return view.Snapshot(dst)
+}
+
+// transform replaces the values of a column with the ones returned by the transform
+func (txn *Txn) transform(t Transform) error {
+	column, ok := txn.columnAt(t.column)
+	if !ok {
+		return nil
+	}
+
+	var failed error
+	writer := txn.bufferFor(t.column)
+	err := txn.With(t.column).Range(func(idx uint32) {
+		current, ok := column.Value(idx)
+		if !ok || failed != nil {
+			return
+		}
+
+		value := t.fn(current)
+		if value == nil {
+			writer.PutOperation(commit.Delete, idx)
+			return
+		}
+
+		m := Set(t.column, value)
+		if value, failed = m.valueOf(column.Column); failed == nil {
+			writer.PutAny(commit.Put, idx, value)
+		}
+	})
+
+	if failed != nil {
+		return failed
+	}
+	return err
+}
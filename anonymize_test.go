@@ -0,0 +1,85 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotTransformed(t *testing.T) {
+	players := loadPlayers(1000)
+	defer players.Close()
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, players.SnapshotTransformed(buffer,
+		Omit("balance"),
+		Hash("name", []byte("secret")),
+		Redact("age", func(v interface{}) interface{} {
+			return int(v.(float64)/10) * 10
+		}),
+		Redact("mp", func(v interface{}) interface{} {
+			return nil
+		}),
+	))
+
+	staging := newEmpty(1000)
+	defer staging.Close()
+	assert.NoError(t, staging.Restore(buffer))
+	assert.Equal(t, 1000, staging.Count())
+
+	// The values of the collection itself are left intact
+	assert.NoError(t, players.QueryAt(42, func(r Row) error {
+		name, _ := r.Enum("name")
+		age, _ := r.Float64("age")
+		balance, _ := r.Float64("balance")
+
+		return staging.QueryAt(42, func(s Row) error {
+			hashed, _ := s.Enum("name")
+			assert.Len(t, hashed, 64)
+			assert.NotEqual(t, name, hashed)
+
+			rounded, _ := s.Float64("age")
+			assert.Equal(t, float64(int(age/10)*10), rounded)
+
+			_, ok := s.Float64("balance")
+			assert.NotZero(t, balance)
+			assert.False(t, ok)
+
+			_, ok = s.Float64("mp")
+			assert.False(t, ok)
+			return nil
+		})
+	}))
+
+	// Equal values are hashed to equal values
+	names := map[string]bool{}
+	staging.Query(func(txn *Txn) error {
+		name := txn.Enum("name")
+		return txn.Range(func(idx uint32) {
+			v, _ := name.Get()
+			names[v] = true
+		})
+	})
+	assert.Equal(t, 500, len(names))
+
+	// The indexes of the transformed columns are computed again
+	assert.Equal(t, countWhere(players, isOld), countWhere(staging, func(txn *Txn) *Txn {
+		return txn.With("old")
+	}))
+}
+
+func TestSnapshotTransformedInvalid(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	buffer := bytes.NewBuffer(nil)
+	assert.Error(t, players.SnapshotTransformed(buffer, Omit("missing")))
+	assert.Error(t, players.SnapshotTransformed(buffer, Hash("age", nil)))
+	assert.Error(t, players.SnapshotTransformed(buffer, Redact("human", func(v interface{}) interface{} { return v })))
+	assert.Error(t, players.SnapshotTransformed(buffer, Redact("age", func(v interface{}) interface{} { return "old" })))
+	assert.Zero(t, buffer.Len())
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/kelindar/column/commit"
+)
+
+// errAppendSelf is returned when a collection is appended into itself
+var errAppendSelf = errors.New("column: unable to append a collection into itself")
+
+// SchemaMode represents how the columns which only exist in the source collection are
+// reconciled when it is appended into another collection.
+type SchemaMode uint8
+
+// Various modes of schema reconciliation
+const (
+	SchemaStrict SchemaMode = iota // Fail if the destination lacks a column of the source
+	SchemaIgnore                   // Skip the columns which the destination lacks
+	SchemaExtend                   // Create the columns which the destination lacks
+)
+
+// AppendOptions represents the options of an append.
+type AppendOptions struct {
+	Schema SchemaMode // How to reconcile the columns missing in the destination (optional)
+}
+
+// columnPair represents a column of the source collection and its destination
+type columnPair struct {
+	src, dst *column
+}
+
+// Append copies all of the rows of the source collection into this collection and returns
+// the number of rows copied. The rows are copied column by column, one chunk at a time,
+// which is considerably faster than inserting them one by one. The columns of this
+// collection which the source lacks are left empty, while the columns of the source
+// which this collection lacks are reconciled according to the schema mode. If both
+// collections have a primary key, the rows whose key is already present are updated.
+func (c *Collection) Append(src *Collection, opts ...AppendOptions) (int, error) {
+	if src == c {
+		return 0, errAppendSelf
+	}
+
+	var options AppendOptions
+	for _, o := range opts {
+		options.Schema = o.Schema
+	}
+
+	pairs, err := c.reconcile(src, options.Schema)
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the primary key of the source, if both collections have one
+	var key *column
+	if c.pk != nil && src.pk != nil {
+		key, _ = src.cols.Load(src.pk.name)
+	}
+
+	total := 0
+	var tombstones *column
+	if src.opts.SoftDelete {
+		tombstones, _ = src.cols.Load(tombstoneColumn)
+	}
+	for chunk := commit.Chunk(0); int(chunk) < src.chunks(); chunk++ {
+		src.slock.RLock(uint(chunk))
+		src.lock.RLock()
+		fill := chunk.OfBitmap(src.fill)
+		src.lock.RUnlock()
+
+		err := c.Query(func(txn *Txn) error {
+			source, target := make([]uint32, 0, 64), make([]uint32, 0, 64)
+			inserts := txn.bufferFor(rowColumn)
+			chunk.Range(fill, func(idx uint32) {
+				if tombstones != nil && tombstones.Contains(idx) {
+					return // Soft deleted rows are not copied
+				}
+
+				// Update the row with the same key, or insert a new one
+				source = append(source, idx)
+				if key != nil {
+					if v, ok := key.Value(idx); ok {
+						if at, ok := c.pk.OffsetOf(v.(string)); ok {
+							target = append(target, at)
+							return
+						}
+					}
+				}
+
+				at := c.next()
+				inserts.PutOperation(commit.Insert, at)
+				target = append(target, at)
+			})
+
+			// Copy the values column by column
+			for _, pair := range pairs {
+				buffer := txn.bufferFor(pair.dst.name)
+				_, isBool := pair.src.Column.(*columnBool)
+				for i, idx := range source {
+					switch v, ok := pair.src.Value(idx); {
+					case isBool && pair.src.Contains(idx):
+						buffer.PutBool(target[i], true)
+					case ok && !isBool:
+						buffer.PutAny(commit.Put, target[i], v)
+					}
+				}
+			}
+
+			total += len(source)
+			return nil
+		})
+
+		src.slock.RUnlock(uint(chunk))
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// reconcile matches the columns of the source collection with the ones of this collection,
+// creating or skipping the missing ones according to the schema mode.
+func (c *Collection) reconcile(src *Collection, mode SchemaMode) ([]columnPair, error) {
+	pairs := make([]columnPair, 0, 16)
+	err := src.cols.RangeUntil(func(v *column) error {
+		if v.IsIndex() || v.name != expireColumn && src.isInternal(v.name) {
+			return nil
+		}
+
+		dst, ok := c.cols.Load(v.name)
+		switch {
+		case ok && reflect.TypeOf(dst.Column) != reflect.TypeOf(v.Column):
+			return fmt.Errorf("column: unable to append column '%s', types differ", v.name)
+		case ok:
+			pairs = append(pairs, columnPair{src: v, dst: dst})
+			return nil
+		case mode == SchemaIgnore:
+			return nil
+		case mode != SchemaExtend:
+			return errorOf(ErrColumnNotFound, "column: unable to append column '%s', column does not exist", v.name)
+		}
+
+		empty, err := makeEmpty(v.Column)
+		if err != nil {
+			return err
+		}
+
+		if err := c.CreateColumn(v.name, empty); err != nil {
+			return err
+		}
+
+		dst, _ = c.cols.Load(v.name)
+		pairs = append(pairs, columnPair{src: v, dst: dst})
+		return nil
+	})
+	return pairs, err
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppend(t *testing.T) {
+	players := loadPlayers(500)
+	shard := loadPlayers(500)
+	defer players.Close()
+	defer shard.Close()
+
+	shard.Query(func(txn *Txn) error {
+		age := txn.Float64("age")
+		return txn.Range(func(idx uint32) {
+			age.Set(99)
+		})
+	})
+
+	// The players have the same serial numbers, so they are updated
+	n, err := players.Append(shard)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, n)
+	assert.Equal(t, 500, players.Count())
+
+	// The indexes of the destination are computed for the appended rows
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.With("old").Count())
+		return nil
+	})
+
+	_, err = players.Append(players)
+	assert.Error(t, err)
+}
+
+func TestAppendSchema(t *testing.T) {
+	dst := NewCollection()
+	dst.CreateColumn("name", ForString())
+	dst.CreateColumn("age", ForInt())
+	defer dst.Close()
+
+	src := NewCollection()
+	src.CreateColumn("name", ForString())
+	src.CreateColumn("active", ForBool())
+	defer src.Close()
+	for i := 0; i < 100; i++ {
+		src.Insert(func(r Row) error {
+			r.SetString("name", fmt.Sprintf("p%d", i))
+			r.SetBool("active", i%2 == 0)
+			return nil
+		})
+	}
+
+	// The strict mode refuses the columns missing in the destination
+	_, err := dst.Append(src)
+	assert.Error(t, err)
+	assert.Equal(t, 0, dst.Count())
+
+	n, err := dst.Append(src, AppendOptions{Schema: SchemaIgnore})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, n)
+	_, exists := dst.cols.Load("active")
+	assert.False(t, exists)
+
+	n, err = dst.Append(src, AppendOptions{Schema: SchemaExtend})
+	assert.NoError(t, err)
+	assert.Equal(t, 100, n)
+	assert.Equal(t, 200, dst.Count())
+	count := func(fn func(txn *Txn) *Txn) (n int) {
+		dst.Query(func(txn *Txn) error {
+			n = fn(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	assert.Equal(t, 50, count(func(txn *Txn) *Txn { return txn.With("active") }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.With("age") }))
+	assert.Equal(t, 2, count(func(txn *Txn) *Txn {
+		return txn.WithValue("name", func(v interface{}) bool { return v == "p42" })
+	}))
+
+	// The types of the columns must match
+	other := NewCollection()
+	other.CreateColumn("name", ForInt())
+	defer other.Close()
+	_, err = dst.Append(other)
+	assert.Error(t, err)
+}
+
+func TestAppendKeys(t *testing.T) {
+	dst, src := newKeyed(), newKeyed()
+	defer dst.Close()
+	defer src.Close()
+
+	for i := 0; i < 10; i++ {
+		dst.InsertObject(Object{"key": fmt.Sprintf("k%d", i), "age": 1})
+		src.InsertObject(Object{"key": fmt.Sprintf("k%d", i+5), "name": "Roman", "age": 2})
+	}
+
+	// The rows with an existing key are updated rather than inserted
+	n, err := dst.Append(src)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, 15, dst.Count())
+	assert.NoError(t, dst.QueryKey("k7", func(r Row) error {
+		age, _ := r.Int("age")
+		assert.Equal(t, 2, age)
+		return nil
+	}))
+
+	diff, err := Diff(dst, src)
+	assert.NoError(t, err)
+	assert.Len(t, diff.Removed, 5)
+	assert.Empty(t, diff.Changed)
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/zeebo/xxh3"
+)
+
+const (
+	pageShift = 20 // 1MB
+	pageSize  = 1 << pageShift
+	maxPages  = 1 << 16
+)
+
+// span represents the location of a string in the arena. Since it does not contain any
+// pointers, a large number of spans does not need to be scanned by the garbage collector.
+type span struct {
+	page   uint32 // The page containing the string
+	offset uint32 // The offset of the string in the page
+	length uint32 // The length of the string
+}
+
+// arena represents an append-only allocator for the strings of a collection. The strings
+// are copied into large pages, so that the garbage collector only needs to track a few
+// objects instead of one per string. The strings are never modified once allocated, and
+// the space of the unused ones is only reclaimed once the arena is rebuilt.
+type arena struct {
+	lock   sync.Mutex
+	pages  []unsafe.Pointer // The directory of the pages, by their number
+	head   []byte           // The page currently being filled
+	headAt uint32           // The number of the page currently being filled
+	count  uint32           // The number of pages allocated
+	size   int64            // The number of bytes allocated in the pages
+	intern map[uint64]span  // The interned strings, by their hash
+}
+
+// newArena creates a new, empty arena
+func newArena() *arena {
+	return &arena{
+		intern: make(map[uint64]span, 64),
+	}
+}
+
+// alloc copies the string into the arena and returns its location.
+func (a *arena) alloc(v []byte) span {
+	if len(v) == 0 {
+		return span{}
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.allocLocked(v)
+}
+
+// allocLocked copies the string into the arena while the lock is held.
+func (a *arena) allocLocked(v []byte) span {
+	size := (len(v) + 7) &^ 7 // Keep the strings word-aligned
+	switch {
+	case size > pageSize/4:
+		page := make([]byte, len(v))
+		copy(page, v)
+		return span{page: a.addPage(page), length: uint32(len(v))}
+	case a.head == nil || len(a.head)+size > cap(a.head):
+		a.head = make([]byte, 0, pageSize)
+		a.headAt = a.addPage(a.head)
+	}
+
+	offset := len(a.head)
+	a.head = append(a.head, v...)
+	a.head = a.head[:offset+size]
+	return span{page: a.headAt, offset: uint32(offset), length: uint32(len(v))}
+}
+
+// addPage adds a page to the directory and returns its number.
+func (a *arena) addPage(page []byte) uint32 {
+	if a.pages == nil {
+		a.pages = make([]unsafe.Pointer, maxPages)
+	}
+
+	if a.count == maxPages {
+		panic("column: string arena is full")
+	}
+
+	at := a.count
+	atomic.StorePointer(&a.pages[at], unsafe.Pointer(&page[:1][0]))
+	atomic.AddInt64(&a.size, int64(cap(page)))
+	a.count++
+	return at
+}
+
+// internBytes returns the location of an identical string if one was interned, or copies
+// the string into the arena and interns it.
+func (a *arena) internBytes(v []byte) span {
+	if len(v) == 0 {
+		return span{}
+	}
+
+	hash := xxh3.Hash(v)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if at, ok := a.intern[hash]; ok && a.read(at) == string(v) {
+		return at
+	}
+
+	at := a.allocLocked(v)
+	a.intern[hash] = at
+	return at
+}
+
+// string copies the string into the arena and returns a string backed by the arena.
+func (a *arena) string(v []byte) string {
+	return a.read(a.alloc(v))
+}
+
+// read returns the string at the location, without copying it.
+func (a *arena) read(at span) string {
+	if at.length == 0 {
+		return ""
+	}
+
+	var out string
+	base := atomic.LoadPointer(&a.pages[at.page])
+	header := (*reflect.StringHeader)(unsafe.Pointer(&out))
+	header.Data = uintptr(base) + uintptr(at.offset)
+	header.Len = int(at.length)
+	return out
+}
+
+// usage returns the number of bytes allocated by the arena.
+func (a *arena) usage() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return int(a.size) + len(a.pages)*int(unsafe.Sizeof(unsafe.Pointer(nil)))
+}
+
+// --------------------------- Collection ----------------------------
+
+// arenaColumn represents a column which stores its strings in the arena of a collection.
+type arenaColumn interface {
+	attach(a *arena)     // Attaches the column to the arena of the collection
+	liveBytes() int      // Returns the number of bytes used by the live strings
+	relocate(dst *arena) // Copies the live strings into another arena and uses it
+}
+
+// compactArena rebuilds the arena of the collection, once more than half of its space is
+// used by strings which are no longer referenced. This must be called while all of the
+// chunks are locked.
+func (c *Collection) compactArena() {
+	live := 0
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(arenaColumn); ok {
+			v.lock.RLock()
+			live += column.liveBytes()
+			v.lock.RUnlock()
+		}
+	})
+
+	if size := int(atomic.LoadInt64(&c.arena.size)); size <= 4*pageSize || size <= 2*live {
+		return
+	}
+
+	next := newArena()
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(arenaColumn); ok {
+			v.lock.Lock()
+			column.relocate(next)
+			v.lock.Unlock()
+		}
+	})
+	c.lock.Lock()
+	c.arena = next
+	c.lock.Unlock()
+}
+
+// growSpans grows the slice of spans until it is able to store the index
+func growSpans(spans []span, idx uint32) []span {
+	switch {
+	case idx < uint32(len(spans)):
+		return spans
+	case idx < uint32(cap(spans)):
+		return spans[:idx+1]
+	}
+
+	clone := make([]span, idx+1, resize(cap(spans), idx+1))
+	copy(clone, spans)
+	return clone
+}
+
+// bytesOf returns the bytes of a string without copying them, which must not be modified
+func bytesOf(v string) (out []byte) {
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&out))
+	header.Data = (*reflect.StringHeader)(unsafe.Pointer(&v)).Data
+	header.Len = len(v)
+	header.Cap = len(v)
+	return
+}
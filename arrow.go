@@ -0,0 +1,422 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kelindar/bitmap"
+)
+
+// --------------------------- Arrow Stream ---------------------------
+
+// Various constants of the Arrow format
+const (
+	arrowVersion     = 4          // The V5 version of the metadata
+	arrowContinue    = 0xFFFFFFFF // The marker which precedes every message
+	arrowSchema      = 1          // The header of a schema message
+	arrowRecordBatch = 3          // The header of a record batch message
+)
+
+// Various types of the fields of an Arrow schema
+const (
+	arrowInt      = 2
+	arrowFloat    = 3
+	arrowUtf8     = 5
+	arrowBool     = 6
+	arrowDuration = 18
+)
+
+// WriteArrow writes the rows of the collection into the destination as an Arrow IPC stream,
+// so that they can be read by Arrow-based engines such as DuckDB, Polars or pandas without
+// exporting files. Only the specified columns are written, or all of the columns which are
+// not indexes if none are specified. Every chunk of the collection is written as a record
+// batch while it is read-locked, so the batches are consistent but the stream as a whole
+// may observe concurrent commits. The numeric and boolean columns keep their type, the
+// durations are written in nanoseconds and the other columns as strings, with the missing
+// values written as nulls.
+func (c *Collection) WriteArrow(dst io.Writer, columns ...string) error {
+	if len(columns) == 0 {
+		columns = c.Columns()
+	}
+
+	fields := make([]arrowField, 0, len(columns))
+	for _, name := range columns {
+		column, ok := c.cols.Load(name)
+		if !ok {
+			return errorOf(ErrColumnNotFound, "column: unable to write arrow, column '%s' does not exist", name)
+		}
+		fields = append(fields, newArrowField(column))
+	}
+
+	w := &arrowWriter{dst: dst}
+	w.message(arrowSchema, arrowSchemaOf(fields), nil)
+	if err := c.Query(func(txn *Txn) error {
+		txn.initialize()
+		txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+			idxs := make([]uint32, 0, index.Count())
+			index.Range(func(x uint32) {
+				idxs = append(idxs, offset+x)
+			})
+
+			if len(idxs) > 0 && w.err == nil {
+				w.batch(fields, idxs)
+			}
+		})
+		return w.err
+	}); err != nil {
+		return err
+	}
+
+	// Terminate the stream with an empty message
+	w.write(arrowContinue, 0)
+	return w.err
+}
+
+// arrowField represents a column written into an Arrow stream
+type arrowField struct {
+	column *column
+	kind   uint8 // The type of the field
+	width  int   // The width of the values in bytes, for the fixed-size types
+	signed bool  // Whether the integers are signed
+}
+
+// newArrowField creates a field for a column, depending on its type
+func newArrowField(column *column) arrowField {
+	f := arrowField{column: column, kind: arrowUtf8}
+	switch typ := typeName(column.Column); typ {
+	case "int8", "int16", "int32", "int64", "int":
+		f.kind, f.width, f.signed = arrowInt, sizeOfType[typ], true
+	case "uint8", "uint16", "uint32", "uint64", "uint":
+		f.kind, f.width = arrowInt, sizeOfType[typ]
+	case "float32", "float64":
+		f.kind, f.width = arrowFloat, sizeOfType[typ]
+	case "duration":
+		f.kind, f.width = arrowDuration, 8
+	case "bool":
+		f.kind = arrowBool
+	}
+	return f
+}
+
+// arrowSchemaOf returns the schema message of the fields
+func arrowSchemaOf(fields []arrowField) fbTable {
+	list := make([]fbTable, 0, len(fields))
+	for _, f := range fields {
+		var typ fbTable
+		switch f.kind {
+		case arrowInt:
+			typ = fbTable{int32(f.width * 8), f.signed}
+		case arrowFloat:
+			typ = fbTable{int16(f.width / 4)} // SINGLE or DOUBLE precision
+		case arrowDuration:
+			typ = fbTable{int16(3)} // NANOSECOND unit
+		default:
+			typ = fbTable{}
+		}
+
+		list = append(list, fbTable{f.column.name, true, f.kind, typ, nil, []fbTable{}})
+	}
+
+	return fbTable{int16(0), list}
+}
+
+// arrowWriter represents a writer of the messages of an Arrow stream, which keeps the first
+// error encountered.
+type arrowWriter struct {
+	dst  io.Writer
+	body []byte // The body of the current record batch
+	err  error
+}
+
+// batch writes a record batch with the values of the fields for the rows
+func (w *arrowWriter) batch(fields []arrowField, idxs []uint32) {
+	w.body = w.body[:0]
+	nodes := make([][2]int64, 0, len(fields))
+	buffers := make([][2]int64, 0, 3*len(fields))
+	for _, f := range fields {
+		valid := make([]byte, (len(idxs)+7)/8)
+		nulls := 0
+		values := make([]interface{}, len(idxs))
+		for i, idx := range idxs {
+			if v, ok := f.column.Value(idx); ok || f.kind == arrowBool {
+				valid[i/8] |= 1 << (i % 8)
+				values[i] = v
+				continue
+			}
+			nulls++
+		}
+
+		nodes = append(nodes, [2]int64{int64(len(idxs)), int64(nulls)})
+		buffers = append(buffers, w.buffer(valid))
+		switch f.kind {
+		case arrowUtf8:
+			offsets := make([]byte, 4*(len(idxs)+1))
+			var data []byte
+			for i, v := range values {
+				data = append(data, arrowStringOf(v)...)
+				binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+			}
+			buffers = append(buffers, w.buffer(offsets), w.buffer(data))
+		case arrowBool:
+			data := make([]byte, len(valid))
+			for i, v := range values {
+				if v, _ := v.(bool); v {
+					data[i/8] |= 1 << (i % 8)
+				}
+			}
+			buffers = append(buffers, w.buffer(data))
+		default:
+			data := make([]byte, f.width*len(idxs))
+			for i, v := range values {
+				if v != nil {
+					f.put(data[i*f.width:], v)
+				}
+			}
+			buffers = append(buffers, w.buffer(data))
+		}
+	}
+
+	w.message(arrowRecordBatch, fbTable{int64(len(idxs)), nodes, buffers}, w.body)
+}
+
+// put encodes a fixed-size value into the destination
+func (f arrowField) put(dst []byte, value interface{}) {
+	switch {
+	case f.kind == arrowDuration:
+		binary.LittleEndian.PutUint64(dst, uint64(value.(time.Duration)))
+	case f.kind == arrowFloat && f.width == 4:
+		v, _ := asFloat64(value)
+		binary.LittleEndian.PutUint32(dst, math.Float32bits(float32(v)))
+	case f.kind == arrowFloat:
+		v, _ := asFloat64(value)
+		binary.LittleEndian.PutUint64(dst, math.Float64bits(v))
+	default:
+		var v uint64
+		switch n := value.(type) {
+		case int:
+			v = uint64(n)
+		case int8:
+			v = uint64(n)
+		case int16:
+			v = uint64(n)
+		case int32:
+			v = uint64(n)
+		case int64:
+			v = uint64(n)
+		case uint:
+			v = uint64(n)
+		case uint8:
+			v = uint64(n)
+		case uint16:
+			v = uint64(n)
+		case uint32:
+			v = uint64(n)
+		case uint64:
+			v = n
+		}
+
+		for i := 0; i < f.width; i++ {
+			dst[i] = byte(v >> (8 * i))
+		}
+	}
+}
+
+// arrowStringOf returns the value of a string field
+func arrowStringOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}:
+		out, _ := json.Marshal(v)
+		return string(out)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// buffer appends a buffer to the body, padded to 8 bytes, and returns its offset and length
+func (w *arrowWriter) buffer(data []byte) [2]int64 {
+	at := len(w.body)
+	w.body = append(w.body, data...)
+	for len(w.body)%8 != 0 {
+		w.body = append(w.body, 0)
+	}
+	return [2]int64{int64(at), int64(len(data))}
+}
+
+// message writes an encapsulated message, with its metadata padded to 8 bytes
+func (w *arrowWriter) message(header uint8, table fbTable, body []byte) {
+	meta := encodeFlatbuffer(fbTable{int16(arrowVersion), header, table, int64(len(body))})
+	for len(meta)%8 != 0 {
+		meta = append(meta, 0)
+	}
+
+	w.write(arrowContinue, uint32(len(meta)))
+	if w.err == nil {
+		_, w.err = w.dst.Write(meta)
+	}
+	if w.err == nil && len(body) > 0 {
+		_, w.err = w.dst.Write(body)
+	}
+}
+
+// write writes the prefix of a message
+func (w *arrowWriter) write(marker, size uint32) {
+	if w.err != nil {
+		return
+	}
+
+	var prefix [8]byte
+	binary.LittleEndian.PutUint32(prefix[0:4], marker)
+	binary.LittleEndian.PutUint32(prefix[4:8], size)
+	_, w.err = w.dst.Write(prefix[:])
+}
+
+// --------------------------- Flatbuffers ---------------------------
+
+// fbTable represents a flatbuffers table, with the value of every field by its slot. A value
+// is either a scalar (bool, uint8, int16, int32 or int64), a string, a table, a vector of
+// tables, a vector of structs of two longs or nil if the field is absent. A union takes two
+// slots, the type of its value and the table.
+type fbTable []interface{}
+
+// fbEncoder represents an encoder of flatbuffers, which writes every table before its
+// children so that the offsets always point forward.
+type fbEncoder struct {
+	buf []byte
+}
+
+// encodeFlatbuffer encodes a flatbuffer with the table as its root
+func encodeFlatbuffer(root fbTable) []byte {
+	e := &fbEncoder{buf: make([]byte, 4, 256)}
+	at := e.table(root)
+	binary.LittleEndian.PutUint32(e.buf[0:], uint32(at))
+	return e.buf
+}
+
+// table encodes a table along with its vtable, then its children, and returns its position
+func (e *fbEncoder) table(t fbTable) int {
+	vtable := e.pad(2, 0)
+	e.buf = append(e.buf, make([]byte, 4+2*len(t))...)
+
+	// Lay out the inline fields from the largest to the smallest, each at its alignment
+	slots := make([]int, 0, len(t))
+	for i, v := range t {
+		if v != nil {
+			slots = append(slots, i)
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return fbSizeOf(t[slots[i]]) > fbSizeOf(t[slots[j]])
+	})
+
+	at := e.pad(8, 0)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	fields := make([]int, len(t))
+	for _, slot := range slots {
+		size := fbSizeOf(t[slot])
+		fields[slot] = e.pad(size, 0)
+		e.buf = append(e.buf, make([]byte, size)...)
+		switch v := t[slot].(type) {
+		case bool:
+			if v {
+				e.buf[fields[slot]] = 1
+			}
+		case uint8:
+			e.buf[fields[slot]] = v
+		case int16:
+			binary.LittleEndian.PutUint16(e.buf[fields[slot]:], uint16(v))
+		case int32:
+			binary.LittleEndian.PutUint32(e.buf[fields[slot]:], uint32(v))
+		case int64:
+			binary.LittleEndian.PutUint64(e.buf[fields[slot]:], uint64(v))
+		}
+	}
+
+	binary.LittleEndian.PutUint16(e.buf[vtable:], uint16(4+2*len(t)))
+	binary.LittleEndian.PutUint16(e.buf[vtable+2:], uint16(len(e.buf)-at))
+	for _, slot := range slots {
+		binary.LittleEndian.PutUint16(e.buf[vtable+4+2*slot:], uint16(fields[slot]-at))
+	}
+	binary.LittleEndian.PutUint32(e.buf[at:], uint32(at-vtable))
+
+	// Encode the children after the table, and point the fields to them
+	for _, slot := range slots {
+		if child := e.child(t[slot]); child > 0 {
+			binary.LittleEndian.PutUint32(e.buf[fields[slot]:], uint32(child-fields[slot]))
+		}
+	}
+	return at
+}
+
+// child encodes a value referenced by a table and returns its position, or zero if the
+// value is a scalar stored inline
+func (e *fbEncoder) child(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		at := e.pad(4, 0)
+		e.append(4, uint64(len(v)))
+		e.buf = append(append(e.buf, v...), 0)
+		return at
+	case fbTable:
+		return e.table(v)
+	case []fbTable:
+		at := e.pad(4, 0)
+		e.append(4, uint64(len(v)))
+		e.buf = append(e.buf, make([]byte, 4*len(v))...)
+		for i, item := range v {
+			offset, child := at+4+4*i, e.table(item)
+			binary.LittleEndian.PutUint32(e.buf[offset:], uint32(child-offset))
+		}
+		return at
+	case [][2]int64:
+		at := e.pad(8, 4)
+		e.append(4, uint64(len(v)))
+		for _, item := range v {
+			e.append(8, uint64(item[0]))
+			e.append(8, uint64(item[1]))
+		}
+		return at
+	default:
+		return 0
+	}
+}
+
+// append appends an unsigned integer of the specified size in bytes
+func (e *fbEncoder) append(size int, v uint64) {
+	for i := 0; i < size; i++ {
+		e.buf = append(e.buf, byte(v>>(8*i)))
+	}
+}
+
+// pad pads the buffer until its length is the remainder modulo the alignment, and returns it
+func (e *fbEncoder) pad(align, remainder int) int {
+	for len(e.buf)%align != remainder {
+		e.buf = append(e.buf, 0)
+	}
+	return len(e.buf)
+}
+
+// fbSizeOf returns the size of a field of a table
+func fbSizeOf(value interface{}) int {
+	switch value.(type) {
+	case bool, uint8:
+		return 1
+	case int16:
+		return 2
+	case int64:
+		return 8
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteArrow(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("name", ForString())
+	input.CreateColumn("age", ForInt32())
+	input.CreateColumn("score", ForFloat64())
+	input.CreateColumn("active", ForBool())
+	input.CreateColumn("wait", ForDuration())
+	input.InsertObject(Object{"name": "Alice", "age": int32(30), "score": 1.5, "active": true, "wait": time.Second})
+	input.InsertObject(Object{"name": "Bob", "score": 2.5})
+
+	var buffer bytes.Buffer
+	assert.NoError(t, input.WriteArrow(&buffer))
+	messages := readArrow(t, buffer.Bytes())
+	assert.Len(t, messages, 2)
+
+	// The schema lists the columns by name, with their type
+	schema := messages[0]
+	assert.Equal(t, uint8(arrowSchema), schema.u8(schema.root, 1))
+	fields := schema.vector(schema.table(schema.root, 2), 1)
+	assert.Len(t, fields, 5)
+
+	names := make([]string, 0, len(fields))
+	types := make([]uint8, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, schema.string(f, 0))
+		types = append(types, schema.u8(f, 2))
+	}
+	assert.Equal(t, []string{"active", "age", "name", "score", "wait"}, names)
+	assert.Equal(t, []uint8{arrowBool, arrowInt, arrowUtf8, arrowFloat, arrowDuration}, types)
+	assert.Equal(t, uint32(32), binary.LittleEndian.Uint32(schema.buf[schema.field(schema.table(fields[1], 3), 0):]))
+
+	// The record batch holds the values of the rows, with the missing ones as nulls
+	batch := messages[1]
+	header := batch.table(batch.root, 2)
+	assert.Equal(t, uint8(arrowRecordBatch), batch.u8(batch.root, 1))
+	assert.Equal(t, uint64(2), binary.LittleEndian.Uint64(batch.buf[batch.field(header, 0):]))
+
+	nodes := batch.structs(header, 1)
+	assert.Equal(t, [][2]int64{{2, 0}, {2, 1}, {2, 0}, {2, 0}, {2, 1}}, nodes)
+
+	buffers := batch.structs(header, 2)
+	assert.Len(t, buffers, 11)
+	age := batch.body[buffers[3][0]:]
+	assert.Equal(t, byte(0x1), batch.body[buffers[2][0]])
+	assert.Equal(t, uint32(30), binary.LittleEndian.Uint32(age))
+
+	offsets := batch.body[buffers[5][0]:]
+	names = []string{
+		string(batch.body[buffers[6][0]:][:binary.LittleEndian.Uint32(offsets[4:])]),
+		string(batch.body[buffers[6][0]:][binary.LittleEndian.Uint32(offsets[4:]):binary.LittleEndian.Uint32(offsets[8:])]),
+	}
+	assert.Equal(t, []string{"Alice", "Bob"}, names)
+
+	score := batch.body[buffers[8][0]:]
+	assert.Equal(t, 2.5, math.Float64frombits(binary.LittleEndian.Uint64(score[8:])))
+	assert.Equal(t, byte(0x1), batch.body[buffers[1][0]])
+}
+
+func TestWriteArrowInvalid(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("name", ForString())
+
+	var buffer bytes.Buffer
+	assert.Error(t, input.WriteArrow(&buffer, "missing"))
+
+	// An empty collection only has a schema
+	assert.NoError(t, input.WriteArrow(&buffer))
+	assert.Len(t, readArrow(t, buffer.Bytes()), 1)
+}
+
+// arrowMessage represents a decoded message of an Arrow stream
+type arrowMessage struct {
+	buf  []byte // The flatbuffer of the metadata
+	root int    // The position of the root table
+	body []byte // The body of the message
+}
+
+// readArrow reads the messages of an Arrow stream
+func readArrow(t *testing.T, data []byte) (out []arrowMessage) {
+	for {
+		assert.Equal(t, uint32(arrowContinue), binary.LittleEndian.Uint32(data))
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		if size == 0 {
+			assert.Len(t, data, 8)
+			return out
+		}
+
+		assert.Equal(t, 0, size%8)
+		m := arrowMessage{buf: data[8 : 8+size]}
+		m.root = int(binary.LittleEndian.Uint32(m.buf))
+		length := int(binary.LittleEndian.Uint64(m.buf[m.field(m.root, 3):]))
+		m.body = data[8+size : 8+size+length]
+		out = append(out, m)
+		data = data[8+size+length:]
+	}
+}
+
+// field returns the position of a field of a table, or zero if it is absent
+func (m arrowMessage) field(table, slot int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(m.buf[table:])))
+	if 4+2*slot >= int(binary.LittleEndian.Uint16(m.buf[vtable:])) {
+		return 0
+	}
+
+	if at := int(binary.LittleEndian.Uint16(m.buf[vtable+4+2*slot:])); at > 0 {
+		return table + at
+	}
+	return 0
+}
+
+// u8 returns the byte of a field
+func (m arrowMessage) u8(table, slot int) uint8 {
+	return m.buf[m.field(table, slot)]
+}
+
+// table returns the position of a table referenced by a field
+func (m arrowMessage) table(table, slot int) int {
+	at := m.field(table, slot)
+	return at + int(binary.LittleEndian.Uint32(m.buf[at:]))
+}
+
+// string returns the string referenced by a field
+func (m arrowMessage) string(table, slot int) string {
+	at := m.table(table, slot)
+	size := int(binary.LittleEndian.Uint32(m.buf[at:]))
+	return string(m.buf[at+4 : at+4+size])
+}
+
+// vector returns the positions of the tables of a vector referenced by a field
+func (m arrowMessage) vector(table, slot int) (out []int) {
+	at := m.table(table, slot)
+	for i := 0; i < int(binary.LittleEndian.Uint32(m.buf[at:])); i++ {
+		item := at + 4 + 4*i
+		out = append(out, item+int(binary.LittleEndian.Uint32(m.buf[item:])))
+	}
+	return
+}
+
+// structs returns the structs of two longs of a vector referenced by a field
+func (m arrowMessage) structs(table, slot int) (out [][2]int64) {
+	at := m.table(table, slot)
+	for i := 0; i < int(binary.LittleEndian.Uint32(m.buf[at:])); i++ {
+		item := m.buf[at+4+16*i:]
+		out = append(out, [2]int64{
+			int64(binary.LittleEndian.Uint64(item)),
+			int64(binary.LittleEndian.Uint64(item[8:])),
+		})
+	}
+	return
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Metadata represents a set of attributes attached to a transaction, such as the actor
+// who performed the change or the reason for it.
+type Metadata map[string]string
+
+// AuditRecord represents a single change of a row, as recorded in the audit log.
+type AuditRecord struct {
+	Time     time.Time   `json:"time"`               // The time of the commit
+	Commit   uint64      `json:"commit"`             // The commit ID
+	Index    uint32      `json:"index"`              // The index of the row changed
+	Column   string      `json:"column,omitempty"`   // The column changed, empty for inserts and deletes
+	Op       string      `json:"op"`                 // The operation, one of insert, delete, put or add
+	Value    interface{} `json:"value,omitempty"`    // The value of the column after the change
+	Metadata Metadata    `json:"metadata,omitempty"` // The metadata of the transaction
+}
+
+// AuditLog represents an append-only log of changes made to a collection.
+type AuditLog struct {
+	lock    sync.RWMutex
+	records []AuditRecord
+}
+
+// NewAuditLog creates a new, empty audit log.
+func NewAuditLog() *AuditLog {
+	return &AuditLog{
+		records: make([]AuditRecord, 0, 64),
+	}
+}
+
+// Append appends a set of records to the audit log.
+func (l *AuditLog) Append(records ...AuditRecord) {
+	l.lock.Lock()
+	l.records = append(l.records, records...)
+	l.lock.Unlock()
+}
+
+// Len returns the number of records in the audit log.
+func (l *AuditLog) Len() int {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return len(l.records)
+}
+
+// Range iterates over the records of the audit log, in order, until the callback
+// returns false.
+func (l *AuditLog) Range(fn func(AuditRecord) bool) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	for _, r := range l.records {
+		if !fn(r) {
+			return
+		}
+	}
+}
+
+// WriteTo exports the audit log into the destination writer as JSON lines.
+func (l *AuditLog) WriteTo(dst io.Writer) (int64, error) {
+	counter := &countingWriter{Writer: dst}
+	encoder := json.NewEncoder(counter)
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	for _, r := range l.records {
+		if err := encoder.Encode(r); err != nil {
+			return counter.n, err
+		}
+	}
+	return counter.n, nil
+}
+
+// countingWriter counts the number of bytes written to the underlying writer.
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+// Write writes the buffer to the underlying writer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// --------------------------- Transaction ---------------------------
+
+// SetMetadata attaches an attribute, such as the actor or the reason, to the transaction.
+// The metadata is recorded along with every change in the audit log of the collection.
+func (txn *Txn) SetMetadata(key, value string) {
+	if txn.meta == nil {
+		txn.meta = make(Metadata, 2)
+	}
+	txn.meta[key] = value
+}
+
+// Metadata returns the metadata attached to the transaction.
+func (txn *Txn) Metadata() Metadata {
+	return txn.meta
+}
+
+// commitAudit records every change of the chunk into the audit log. This must be called
+// once the updates are applied, while the chunk is still locked.
+func (txn *Txn) commitAudit(audit *AuditLog, commitID uint64, chunk commit.Chunk) {
+	now := txn.owner.now()
+	records := make([]AuditRecord, 0, 16)
+	for _, u := range txn.updates {
+		if u.IsEmpty() {
+			continue
+		}
+
+		column, exists := txn.owner.cols.Load(u.Column)
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				record := AuditRecord{
+					Time:     now,
+					Commit:   commitID,
+					Index:    r.Index(),
+					Metadata: txn.meta,
+				}
+
+				switch {
+				case u.Column == rowColumn && r.Type == commit.Insert:
+					record.Op = "insert"
+				case u.Column == rowColumn:
+					record.Op = "delete"
+				case !exists:
+					continue
+				default:
+					record.Column = u.Column
+					record.Op = "put"
+					if r.Type == commit.Add {
+						record.Op = "add"
+					}
+
+					value, ok := column.Value(record.Index)
+					if !ok {
+						record.Op = "delete"
+					}
+					record.Value = value
+				}
+
+				records = append(records, record)
+			}
+		})
+	}
+
+	if len(records) > 0 {
+		audit.Append(records...)
+	}
+}
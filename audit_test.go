@@ -0,0 +1,67 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	audit := NewAuditLog()
+	accounts := NewCollection(Options{Audit: audit})
+	accounts.CreateColumn("balance", ForFloat64())
+
+	// Insert a single account
+	idx, err := accounts.Insert(func(r Row) error {
+		r.SetFloat64("balance", 100)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, audit.Len())
+
+	// Update the balance on behalf of a user
+	assert.NoError(t, accounts.Query(func(txn *Txn) error {
+		txn.SetMetadata("user", "roman")
+		txn.SetMetadata("reason", "deposit")
+		assert.Equal(t, "roman", txn.Metadata()["user"])
+		return txn.QueryAt(idx, func(r Row) error {
+			r.AddFloat64("balance", 50)
+			return nil
+		})
+	}))
+
+	// Rolled back changes are not recorded
+	assert.Error(t, accounts.Query(func(txn *Txn) error {
+		txn.DeleteAt(idx)
+		return errNoKey
+	}))
+
+	var records []AuditRecord
+	audit.Range(func(r AuditRecord) bool {
+		records = append(records, r)
+		return true
+	})
+
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, "insert", records[0].Op)
+	assert.Equal(t, "put", records[1].Op)
+	assert.Equal(t, "add", records[2].Op)
+	assert.Equal(t, "balance", records[2].Column)
+	assert.Equal(t, idx, records[2].Index)
+	assert.Equal(t, 150.0, records[2].Value)
+	assert.Equal(t, Metadata{"user": "roman", "reason": "deposit"}, records[2].Metadata)
+	assert.Nil(t, records[1].Metadata)
+
+	// Export the audit log
+	buffer := bytes.NewBuffer(nil)
+	n, err := audit.WriteTo(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buffer.Len()), n)
+	assert.Equal(t, 3, strings.Count(buffer.String(), "\n"))
+	assert.Contains(t, buffer.String(), `"user":"roman"`)
+}
@@ -0,0 +1,199 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Backend represents a database in front of which the collection is used as a structured
+// cache. The keys which are missing from the collection are loaded from the database when
+// they are queried with QueryKey(), and the rows changed in the collection are written back
+// to it in the background, in batches. Both require a primary key column.
+type Backend struct {
+	Load     func(key string) (Object, bool, error) // Loads the row of a missing key, if it exists (optional)
+	Flush    func(writes []BackendWrite) error      // Writes a batch of changed rows (optional)
+	Interval time.Duration                          // The interval at which the changes are written, 1 second by default
+	OnError  func(err error)                        // The callback for the batches which failed to be written (optional)
+}
+
+// BackendWrite represents a row written back to the backend, once changed
+type BackendWrite struct {
+	Key    string // The primary key of the row
+	Values Object // The values of the row, or nil if it was deleted
+}
+
+// backend keeps track of the keys of the rows changed since they were last written
+type backend struct {
+	lock    sync.Mutex          // The lock protecting the changed keys
+	flush   sync.Mutex          // The lock held while the changes are written
+	conf    Backend             // The configuration of the backend
+	changed map[string]struct{} // The keys of the rows changed since the last flush
+}
+
+// newBackend creates a new backend, if one is configured
+func newBackend(conf *Backend) *backend {
+	if conf == nil {
+		return nil
+	}
+
+	b := &backend{
+		conf:    *conf,
+		changed: make(map[string]struct{}, 64),
+	}
+
+	if b.conf.Interval <= 0 {
+		b.conf.Interval = time.Second
+	}
+	return b
+}
+
+// track records the keys of the rows changed by a transaction in a chunk, before the changes
+// are applied. The previous key of a row is recorded along with the new one, so that it is
+// deleted from the backend if the key itself was changed.
+func (b *backend) track(txn *Txn, chunk commit.Chunk) {
+	pk := txn.owner.pk
+	if pk == nil || b.conf.Flush == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, u := range txn.updates {
+		if u.IsEmpty() {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				if u.Column == pk.name && r.Type == commit.Put {
+					b.changed[string(r.Bytes())] = struct{}{}
+				}
+				if key, ok := pk.LoadString(r.Index()); ok {
+					b.changed[key] = struct{}{}
+				}
+			}
+		})
+	}
+}
+
+// readThrough loads a key which is missing from the collection from the backend, so that
+// the row is found when the key is queried.
+func (c *Collection) readThrough(key string) error {
+	b := c.backend
+	if b == nil || b.conf.Load == nil || c.pk == nil {
+		return nil
+	}
+
+	if _, ok := c.pk.OffsetOf(key); ok {
+		return nil
+	}
+
+	object, ok, err := b.conf.Load(key)
+	switch {
+	case err != nil:
+		return fmt.Errorf("column: unable to load '%s', %w", key, err)
+	case !ok:
+		return nil
+	}
+
+	// The loaded rows are already in the backend, so they are not written back
+	return c.Query(func(txn *Txn) error {
+		txn.loaded = true
+		return txn.QueryKey(key, func(r Row) error {
+			for k, v := range object {
+				if column, ok := txn.columnAt(k); ok && k != c.pk.name && !column.IsIndex() {
+					txn.bufferFor(k).PutAny(commit.Put, txn.cursor, v)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Flush writes the rows changed since the last flush to the backend right away, rather than
+// waiting for the next interval. If the backend fails to write them, they are kept and
+// written again by the next flush.
+func (c *Collection) Flush() error {
+	b := c.backend
+	if b == nil || b.conf.Flush == nil {
+		return nil
+	}
+
+	b.flush.Lock()
+	defer b.flush.Unlock()
+	b.lock.Lock()
+	changed := b.changed
+	b.changed = make(map[string]struct{}, len(changed))
+	b.lock.Unlock()
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// Read the current values of the rows, the ones which no longer exist were deleted
+	writes := make([]BackendWrite, 0, len(changed))
+	for key := range changed {
+		write := BackendWrite{Key: key}
+		if idx, ok := c.pk.OffsetOf(key); ok {
+			c.QueryAt(idx, func(r Row) error {
+				if current, ok := c.pk.LoadString(idx); ok && current == key {
+					write.Values = r.txn.valuesAt(idx)
+				}
+				return nil
+			})
+		}
+		writes = append(writes, write)
+	}
+
+	sort.Slice(writes, func(i, j int) bool { return writes[i].Key < writes[j].Key })
+	if err := b.conf.Flush(writes); err != nil {
+		b.lock.Lock()
+		for key := range changed {
+			b.changed[key] = struct{}{}
+		}
+		b.lock.Unlock()
+		return fmt.Errorf("column: unable to flush, %w", err)
+	}
+	return nil
+}
+
+// writeBehind writes the changed rows to the backend at every interval, until the
+// collection is closed
+func (c *Collection) writeBehind(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			if err := c.Flush(); err != nil && c.backend.conf.OnError != nil {
+				c.backend.conf.OnError(err)
+			}
+		}
+	}
+}
+
+// valuesAt returns the values of the columns of a row, without the indexes and the
+// internal columns
+func (txn *Txn) valuesAt(idx uint32) Object {
+	out := make(Object, 8)
+	txn.owner.cols.Range(func(c *column) {
+		switch {
+		case c.IsIndex(), txn.owner.isInternal(c.name):
+			return
+		}
+
+		if v, ok := c.Value(idx); ok {
+			out[c.name] = v
+		}
+	})
+	return out
+}
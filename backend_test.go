@@ -0,0 +1,123 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendReadThrough(t *testing.T) {
+	loads := 0
+	db := map[string]Object{
+		"alice": {"name": "alice", "age": 30.0},
+	}
+
+	col := newBackendCollection(&Backend{
+		Load: func(key string) (Object, bool, error) {
+			loads++
+			if key == "broken" {
+				return nil, false, errors.New("unreachable")
+			}
+
+			row, ok := db[key]
+			return row, ok, nil
+		},
+		Flush: func([]BackendWrite) error { return nil },
+	})
+	defer col.Close()
+
+	// A missing key is loaded once, and then found in the collection
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, col.QueryKey("alice", func(r Row) error {
+			age, ok := r.Float64("age")
+			assert.True(t, ok)
+			assert.Equal(t, 30.0, age)
+			return nil
+		}))
+	}
+	assert.Equal(t, 1, loads)
+
+	// A key which is not in the backend either is inserted, as usual
+	assert.NoError(t, col.QueryKey("bob", func(r Row) error {
+		r.SetFloat64("age", 20)
+		return nil
+	}))
+	assert.Equal(t, 2, col.Count())
+
+	// The errors of the loader are returned
+	assert.Error(t, col.QueryKey("broken", func(r Row) error {
+		return nil
+	}))
+}
+
+func TestBackendWriteBehind(t *testing.T) {
+	var lock sync.Mutex
+	var flushed []BackendWrite
+	failing := true
+	col := newBackendCollection(&Backend{
+		Interval: time.Hour,
+		Flush: func(writes []BackendWrite) error {
+			lock.Lock()
+			defer lock.Unlock()
+			if failing {
+				failing = false
+				return errors.New("unreachable")
+			}
+
+			flushed = append(flushed, writes...)
+			return nil
+		},
+	})
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		assert.NoError(t, col.QueryKey(name, func(r Row) error {
+			r.SetFloat64("age", 20)
+			return nil
+		}))
+	}
+
+	// The rows which fail to be written are written by the next flush
+	assert.Error(t, col.Flush())
+	assert.NoError(t, col.Flush())
+	assert.Len(t, flushed, 3)
+	assert.Equal(t, "alice", flushed[0].Key)
+	assert.Equal(t, Object{"key": "alice", "age": 20.0}, flushed[0].Values)
+
+	// The updates and the deletes are written once the collection is closed
+	flushed = nil
+	assert.NoError(t, col.QueryKey("bob", func(r Row) error {
+		r.SetFloat64("age", 21)
+		return nil
+	}))
+	assert.NoError(t, col.QueryKey("carol", func(r Row) error {
+		r.txn.DeleteAt(r.txn.cursor)
+		return nil
+	}))
+
+	assert.NoError(t, col.Close())
+	assert.Equal(t, []BackendWrite{
+		{Key: "bob", Values: Object{"key": "bob", "age": 21.0}},
+		{Key: "carol"},
+	}, flushed)
+}
+
+// newBackendCollection creates a new collection with a primary key, cached in front of a backend
+func newBackendCollection(backend *Backend) *Collection {
+	col := NewCollection(Options{
+		Backend: backend,
+	})
+
+	col.CreateColumn("key", ForKey())
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForFloat64())
+	col.CreateIndex("adult", "age", func(r Reader) bool {
+		return r.Float() >= 18
+	})
+	return col
+}
@@ -0,0 +1,254 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package bench provides a harness which loads a collection with generated rows, drives it
+// with a workload of concurrent reads and writes for a duration, and reports the throughput
+// along with the percentiles of the latencies. The reads count the rows selected by a filter
+// of a configurable selectivity, and the writes update a random column of a random row with
+// a value of its source.
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelindar/column"
+	"github.com/kelindar/column/gen"
+)
+
+// filterColumn is the column holding the uniform values filtered by the reads
+const filterColumn = "bench.filter"
+
+// Column represents a column of the schema, along with the source of its values.
+type Column struct {
+	Name   string        // The name of the column
+	Column column.Column // The column to create, such as column.ForFloat64()
+	Source gen.Source    // The source of the values, both loaded and written
+}
+
+// Spec represents the schema of the collection and the workload to drive it with.
+type Spec struct {
+	Columns     []Column      // The columns of the collection
+	Rows        int           // The number of rows loaded before the workload starts
+	Duration    time.Duration // The duration of the workload (optional, defaults to a second)
+	Concurrency int           // The number of concurrent workers (optional, defaults to one per CPU)
+	Writes      float64       // The fraction of the operations which are writes, between 0 and 1
+	Selectivity float64       // The fraction of the rows selected by the reads (optional, defaults to all)
+	Seed        int64         // The seed of the generated values and operations (optional)
+}
+
+// Latency represents the percentiles of the latencies of a kind of operation.
+type Latency struct {
+	Count int           // The number of operations
+	P50   time.Duration // The median latency
+	P90   time.Duration // The 90th percentile of the latencies
+	P99   time.Duration // The 99th percentile of the latencies
+	Max   time.Duration // The maximum latency
+}
+
+// Report represents the results of a workload.
+type Report struct {
+	Duration   time.Duration // The duration of the workload
+	Throughput float64       // The number of operations per second
+	Errors     int           // The number of operations which have failed
+	Reads      Latency       // The latencies of the reads
+	Writes     Latency       // The latencies of the writes
+}
+
+// String returns the report as a table, with a line per kind of operation.
+func (r *Report) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%-6s\t%10s\t%10s\t%10s\t%10s\t%10s\n", "OP", "COUNT", "P50", "P90", "P99", "MAX")
+	for _, v := range []struct {
+		name string
+		Latency
+	}{{"read", r.Reads}, {"write", r.Writes}} {
+		fmt.Fprintf(&out, "%-6s\t%10d\t%10v\t%10v\t%10v\t%10v\n", v.name, v.Count, v.P50, v.P90, v.P99, v.Max)
+	}
+
+	fmt.Fprintf(&out, "%.0f ops/sec over %v, %d errors\n", r.Throughput, r.Duration, r.Errors)
+	return out.String()
+}
+
+// Run creates a collection with the schema of the specification, loads it with the rows
+// and drives it with the workload until its duration elapses or the context is done.
+func Run(ctx context.Context, spec Spec) (*Report, error) {
+	switch {
+	case len(spec.Columns) == 0:
+		return nil, errors.New("bench: the schema has no columns")
+	case spec.Writes < 0 || spec.Writes > 1:
+		return nil, fmt.Errorf("bench: invalid fraction of writes %v", spec.Writes)
+	case spec.Selectivity < 0 || spec.Selectivity > 1:
+		return nil, fmt.Errorf("bench: invalid selectivity %v", spec.Selectivity)
+	}
+
+	collection := column.NewCollection(column.Options{
+		Capacity: spec.Rows,
+	})
+	defer collection.Close()
+
+	if err := load(collection, spec); err != nil {
+		return nil, err
+	}
+
+	return drive(ctx, collection, spec)
+}
+
+// load creates the columns of the specification and inserts the rows
+func load(collection *column.Collection, spec Spec) error {
+	if err := collection.CreateColumn(filterColumn, column.ForFloat64()); err != nil {
+		return err
+	}
+
+	for _, c := range spec.Columns {
+		if err := collection.CreateColumn(c.Name, c.Column); err != nil {
+			return err
+		}
+	}
+
+	fields := []gen.Field{{Column: filterColumn, Source: gen.Floats(0, 1)}}
+	for _, c := range spec.Columns {
+		fields = append(fields, gen.Field{Column: c.Name, Source: c.Source})
+	}
+
+	return gen.New(spec.Seed, fields...).Fill(collection, spec.Rows)
+}
+
+// drive drives the collection, loaded with the rows of the specification, with its workload
+// until its duration elapses or the context is done.
+func drive(ctx context.Context, collection *column.Collection, spec Spec) (*Report, error) {
+	if spec.Duration <= 0 {
+		spec.Duration = time.Second
+	}
+	if spec.Concurrency <= 0 {
+		spec.Concurrency = runtime.GOMAXPROCS(0)
+	}
+	if spec.Selectivity == 0 {
+		spec.Selectivity = 1
+	}
+
+	rows := uint32(collection.Count())
+	if rows == 0 {
+		return nil, errors.New("bench: the workload requires at least one row")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, spec.Duration)
+	defer cancel()
+
+	// Run the workers until the deadline, each with its own source of randomness. Since the
+	// sources may keep a state, the values are generated under a lock, outside of the timing.
+	var wg sync.WaitGroup
+	var sources sync.Mutex
+	results := make([]result, spec.Concurrency)
+	start := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func(out *result, random *rand.Rand) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				op := operation{write: random.Float64() < spec.Writes}
+				if op.write {
+					target := spec.Columns[random.Intn(len(spec.Columns))]
+					sources.Lock()
+					op.column, op.value = target.Name, target.Source(random)
+					sources.Unlock()
+					op.index = uint32(random.Int63n(int64(rows)))
+				}
+
+				began := time.Now()
+				err := op.run(collection, spec.Selectivity)
+				out.observe(time.Since(began), op.write, err)
+			}
+		}(&results[i], rand.New(rand.NewSource(spec.Seed+int64(i)+1)))
+	}
+
+	wg.Wait()
+	return reportOf(time.Since(start), results), nil
+}
+
+// operation represents a single read or write of the workload
+type operation struct {
+	write  bool        // Whether the operation is a write
+	index  uint32      // The row to write
+	column string      // The column to write
+	value  interface{} // The value to write
+}
+
+// run runs the operation against the collection
+func (op *operation) run(collection *column.Collection, selectivity float64) error {
+	if !op.write {
+		return collection.Query(func(txn *column.Txn) error {
+			txn.WithFloatLess(filterColumn, selectivity).Count()
+			return nil
+		})
+	}
+
+	return collection.QueryAt(op.index, func(r column.Row) error {
+		r.SetAny(op.column, op.value)
+		return nil
+	})
+}
+
+// ----------------------------------------- Results -----------------------------------------
+
+// result represents the latencies observed by a worker
+type result struct {
+	reads  []time.Duration
+	writes []time.Duration
+	errors int
+}
+
+// observe records the latency of an operation
+func (r *result) observe(latency time.Duration, write bool, err error) {
+	switch {
+	case err != nil:
+		r.errors++
+	case write:
+		r.writes = append(r.writes, latency)
+	default:
+		r.reads = append(r.reads, latency)
+	}
+}
+
+// reportOf merges the results of the workers into a report
+func reportOf(elapsed time.Duration, results []result) *Report {
+	var reads, writes []time.Duration
+	report := &Report{Duration: elapsed}
+	for _, r := range results {
+		reads = append(reads, r.reads...)
+		writes = append(writes, r.writes...)
+		report.Errors += r.errors
+	}
+
+	report.Reads = latencyOf(reads)
+	report.Writes = latencyOf(writes)
+	report.Throughput = float64(len(reads)+len(writes)) / elapsed.Seconds()
+	return report
+}
+
+// latencyOf computes the percentiles of a set of latencies
+func latencyOf(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	at := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	return Latency{
+		Count: len(latencies),
+		P50:   at(.50),
+		P90:   at(.90),
+		P99:   at(.99),
+		Max:   latencies[len(latencies)-1],
+	}
+}
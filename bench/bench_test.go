@@ -0,0 +1,75 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package bench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kelindar/column"
+	"github.com/kelindar/column/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	report, err := Run(context.Background(), Spec{
+		Columns: []Column{{
+			Name:   "balance",
+			Column: column.ForFloat64(),
+			Source: gen.Normal(1000, 250),
+		}, {
+			Name:   "class",
+			Column: column.ForEnum(),
+			Source: gen.Skewed(2, "warrior", "mage", "rogue"),
+		}},
+		Rows:        1000,
+		Duration:    100 * time.Millisecond,
+		Concurrency: 4,
+		Writes:      0.5,
+		Selectivity: 0.1,
+	})
+
+	assert.NoError(t, err)
+	assert.Zero(t, report.Errors)
+	assert.Greater(t, report.Throughput, 0.0)
+	assert.Greater(t, report.Reads.Count, 0)
+	assert.Greater(t, report.Writes.Count, 0)
+	assert.LessOrEqual(t, report.Reads.P50, report.Reads.P99)
+	assert.LessOrEqual(t, report.Writes.P99, report.Writes.Max)
+	assert.Contains(t, report.String(), "ops/sec")
+}
+
+func TestRunInvalid(t *testing.T) {
+	balance := Column{
+		Name:   "balance",
+		Column: column.ForFloat64(),
+		Source: gen.Floats(0, 1),
+	}
+
+	for _, spec := range []Spec{
+		{Rows: 10},
+		{Rows: 10, Columns: []Column{balance}, Writes: 2},
+		{Rows: 10, Columns: []Column{balance}, Selectivity: -1},
+		{Columns: []Column{balance}},
+	} {
+		_, err := Run(context.Background(), spec)
+		assert.Error(t, err)
+	}
+}
+
+func TestLatencyOf(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	latency := latencyOf(latencies)
+	assert.Equal(t, 100, latency.Count)
+	assert.Equal(t, 50*time.Millisecond, latency.P50)
+	assert.Equal(t, 90*time.Millisecond, latency.P90)
+	assert.Equal(t, 99*time.Millisecond, latency.P99)
+	assert.Equal(t, 100*time.Millisecond, latency.Max)
+	assert.Equal(t, Latency{}, latencyOf(nil))
+}
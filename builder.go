@@ -0,0 +1,296 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/kelindar/bitmap"
+)
+
+// Various operations of a query built with a builder
+const (
+	opAnd     = "and"
+	opOr      = "or"
+	opHas     = "has"
+	opHasNot  = "hasNot"
+	opEq      = "eq"
+	opGt      = "gt"
+	opLt      = "lt"
+	opBetween = "between"
+)
+
+// Builder represents a composable query, which can be serialized to and from JSON so that
+// queries can be received over the wire. The conditions chained on a builder are combined
+// with a logical AND, while Or() combines the builder with other ones with a logical OR.
+// A builder is applied to a transaction with Where().
+type Builder struct {
+	root queryNode
+}
+
+// queryNode represents an operation of a query, as it is serialized
+type queryNode struct {
+	Op     string      `json:"op"`
+	Column string      `json:"column,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	Min    interface{} `json:"min,omitempty"`
+	Max    interface{} `json:"max,omitempty"`
+	Args   []queryNode `json:"args,omitempty"`
+}
+
+// Q creates a new query builder, which selects all of the rows until conditions are added.
+func Q() *Builder {
+	return &Builder{
+		root: queryNode{Op: opAnd},
+	}
+}
+
+// Has selects the rows present in the specified index or boolean column.
+func (b *Builder) Has(columnName string) *Builder {
+	return b.and(queryNode{Op: opHas, Column: columnName})
+}
+
+// HasNot selects the rows absent from the specified index or boolean column.
+func (b *Builder) HasNot(columnName string) *Builder {
+	return b.and(queryNode{Op: opHasNot, Column: columnName})
+}
+
+// Eq selects the rows whose value is equal to the specified one. Strings are compared to
+// string or enum columns, numbers to numeric columns and booleans to indexes or boolean
+// columns.
+func (b *Builder) Eq(columnName string, value interface{}) *Builder {
+	return b.and(queryNode{Op: opEq, Column: columnName, Value: value})
+}
+
+// Gt selects the rows whose value in a numeric column is greater than the specified one.
+func (b *Builder) Gt(columnName string, value interface{}) *Builder {
+	return b.and(queryNode{Op: opGt, Column: columnName, Value: value})
+}
+
+// Lt selects the rows whose value in a numeric column is less than the specified one.
+func (b *Builder) Lt(columnName string, value interface{}) *Builder {
+	return b.and(queryNode{Op: opLt, Column: columnName, Value: value})
+}
+
+// Between selects the rows whose value in a numeric column is between min and max, inclusive.
+func (b *Builder) Between(columnName string, min, max interface{}) *Builder {
+	return b.and(queryNode{Op: opBetween, Column: columnName, Min: min, Max: max})
+}
+
+// Or combines the conditions of the builder with the ones of the other builders, so that
+// the rows matching any of them are selected. The conditions chained afterwards apply to
+// the combination. If the builder has no conditions yet, only the other ones are combined.
+func (b *Builder) Or(others ...*Builder) *Builder {
+	args := make([]queryNode, 0, len(others)+1)
+	if b.root.Op != opAnd || len(b.root.Args) > 0 {
+		args = append(args, b.root)
+	}
+
+	for _, other := range others {
+		args = append(args, other.root.clone())
+	}
+
+	b.root = queryNode{Op: opOr, Args: args}
+	return b
+}
+
+// and adds a condition to the builder, combined with a logical AND
+func (b *Builder) and(node queryNode) *Builder {
+	if b.root.Op != opAnd {
+		b.root = queryNode{Op: opAnd, Args: []queryNode{b.root}}
+	}
+
+	b.root.Args = append(b.root.Args, node)
+	return b
+}
+
+// MarshalJSON encodes the query as JSON.
+func (b *Builder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.root)
+}
+
+// UnmarshalJSON decodes the query from JSON and validates its operations.
+func (b *Builder) UnmarshalJSON(data []byte) error {
+	var root queryNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	if err := root.validate(); err != nil {
+		return err
+	}
+
+	b.root = root
+	return nil
+}
+
+// clone returns a deep copy of the node
+func (n queryNode) clone() queryNode {
+	if n.Args != nil {
+		args := make([]queryNode, 0, len(n.Args))
+		for _, arg := range n.Args {
+			args = append(args, arg.clone())
+		}
+		n.Args = args
+	}
+	return n
+}
+
+// validate checks whether the operations of the node and of its arguments are known
+func (n *queryNode) validate() error {
+	switch n.Op {
+	case opAnd, opOr:
+		for i := range n.Args {
+			if err := n.Args[i].validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case opHas, opHasNot, opEq, opGt, opLt, opBetween:
+		if n.Column == "" {
+			return fmt.Errorf("column: query operation '%s' requires a column", n.Op)
+		}
+		return nil
+	default:
+		return fmt.Errorf("column: unknown query operation '%s'", n.Op)
+	}
+}
+
+// --------------------------- Compilation ----------------------------
+
+// Where filters down the selection with the conditions of a query builder. The conditions
+// combined with a logical AND are queued as filters, so that they are applied in the order
+// of their cost along with the other filters of the transaction. The conditions combined
+// with a logical OR are applied on what remains of the selection, and their union is kept.
+// If the query is invalid, the selection is cleared and the transaction fails with an error.
+func (txn *Txn) Where(query *Builder) *Txn {
+	if err := txn.where(&query.root); err != nil {
+		return txn.abort(err)
+	}
+	return txn
+}
+
+// where compiles a node of a query into the filters of the transaction
+func (txn *Txn) where(n *queryNode) error {
+	switch n.Op {
+	case opAnd:
+		var unions []*queryNode
+		for i := range n.Args {
+			if n.Args[i].Op == opOr {
+				unions = append(unions, &n.Args[i])
+				continue
+			}
+
+			if err := txn.where(&n.Args[i]); err != nil {
+				return err
+			}
+		}
+
+		// Apply the unions last, since they are computed over the narrowed selection
+		for _, union := range unions {
+			if err := txn.whereAny(union.Args); err != nil {
+				return err
+			}
+		}
+		return nil
+	case opOr:
+		return txn.whereAny(n.Args)
+	case opHas:
+		txn.filter(filterWith, n.Column, nil)
+		return nil
+	case opHasNot:
+		txn.filter(filterWithout, n.Column, nil)
+		return nil
+	case opEq:
+		return txn.whereEqual(n)
+	case opGt:
+		value, err := n.number(n.Value)
+		if err == nil {
+			txn.filterRange(filterGreater, n.Column, math.Nextafter(value, math.Inf(1)), math.Inf(1))
+		}
+		return err
+	case opLt:
+		value, err := n.number(n.Value)
+		if err == nil {
+			txn.filterRange(filterLess, n.Column, math.Inf(-1), math.Nextafter(value, math.Inf(-1)))
+		}
+		return err
+	case opBetween:
+		min, err := n.number(n.Min)
+		if err != nil {
+			return err
+		}
+
+		max, err := n.number(n.Max)
+		if err == nil {
+			txn.filterRange(filterBetween, n.Column, min, max)
+		}
+		return err
+	default:
+		return fmt.Errorf("column: unknown query operation '%s'", n.Op)
+	}
+}
+
+// whereEqual compiles an equality, based on the type of its value
+func (txn *Txn) whereEqual(n *queryNode) error {
+	switch v := n.Value.(type) {
+	case string:
+		txn.filter(filterEqual, n.Column, v)
+	case bool:
+		if v {
+			txn.filter(filterWith, n.Column, nil)
+		} else {
+			txn.filter(filterWithout, n.Column, nil)
+		}
+	default:
+		value, err := n.number(v)
+		if err != nil {
+			return err
+		}
+		txn.filterRange(filterBetween, n.Column, value, value)
+	}
+	return nil
+}
+
+// whereAny selects the rows of the current selection which match any of the nodes
+func (txn *Txn) whereAny(nodes []queryNode) error {
+	txn.initialize()
+	txn.cached = nil
+	defer txn.trace("Or", "", false)()
+
+	union := make(bitmap.Bitmap, len(txn.index))
+	for i := range nodes {
+		worker := txn.owner.txns.acquire(txn.owner)
+		worker.ctx = txn.ctx
+		worker.unlocked = txn.unlocked
+		worker.setup = true
+		txn.index.Clone(&worker.index)
+
+		err := worker.where(&nodes[i])
+		if err == nil {
+			worker.initialize()
+			err = worker.failed()
+		}
+
+		union.Or(worker.index)
+		worker.rollback()
+		txn.owner.txns.release(worker)
+		if err != nil {
+			return err
+		}
+	}
+
+	copy(txn.index, union)
+	return nil
+}
+
+// number converts a value of the node to a number
+func (n *queryNode) number(value interface{}) (float64, error) {
+	if v, ok := asFloat64(value); ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("column: query operation '%s' on '%s' expects a number, got %T", n.Op, n.Column, value)
+}
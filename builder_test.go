@@ -0,0 +1,133 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	for _, tc := range []struct {
+		query  *Builder
+		expect func(col *Collection) int
+	}{
+		{
+			query: Q().Eq("race", "human").Gt("age", 30),
+			expect: func(col *Collection) int {
+				return countWhere(col, func(txn *Txn) *Txn {
+					return txn.WithStringEqual("race", "human").WithFloatGreater("age", 30)
+				})
+			},
+		},
+		{
+			query: Q().Has("human").HasNot("mage").Between("balance", 1000, 3000),
+			expect: func(col *Collection) int {
+				return countWhere(col, func(txn *Txn) *Txn {
+					return txn.With("human").Without("mage").WithFloatBetween("balance", 1000, 3000)
+				})
+			},
+		},
+		{
+			query: Q().Eq("active", true).Lt("age", 25),
+			expect: func(col *Collection) int {
+				return countWhere(col, func(txn *Txn) *Txn {
+					return txn.With("active").WithFloatLess("age", 25)
+				})
+			},
+		},
+		{
+			query: Q().Has("human").Or(Q().Has("elf")).Gt("age", 30),
+			expect: func(col *Collection) int {
+				return countWhere(col, func(txn *Txn) *Txn {
+					return txn.With("human").WithFloatGreater("age", 30)
+				}) + countWhere(col, func(txn *Txn) *Txn {
+					return txn.With("elf").WithFloatGreater("age", 30)
+				})
+			},
+		},
+		{
+			query: Q().Or(Q().Has("dwarf"), Q().Eq("race", "orc").Eq("class", "mage")),
+			expect: func(col *Collection) int {
+				return countWhere(col, func(txn *Txn) *Txn {
+					return txn.With("dwarf")
+				}) + countWhere(col, func(txn *Txn) *Txn {
+					return txn.With("orc", "mage")
+				})
+			},
+		},
+	} {
+		var actual int
+		expect := tc.expect(players)
+
+		// The query is the same once serialized and deserialized
+		encoded, err := json.Marshal(tc.query)
+		assert.NoError(t, err)
+		decoded := new(Builder)
+		assert.NoError(t, json.Unmarshal(encoded, decoded))
+
+		for _, query := range []*Builder{tc.query, decoded} {
+			assert.NoError(t, players.Query(func(txn *Txn) error {
+				actual = txn.Where(query).Count()
+				return nil
+			}))
+			assert.Equal(t, expect, actual, string(encoded))
+		}
+	}
+}
+
+func TestBuilderJSON(t *testing.T) {
+	query := Q().Eq("race", "human").Or(Q().Between("age", 10, 20)).HasNot("mage")
+	encoded, err := json.Marshal(query)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"op":"and","args":[
+		{"op":"or","args":[
+			{"op":"and","args":[{"op":"eq","column":"race","value":"human"}]},
+			{"op":"and","args":[{"op":"between","column":"age","min":10,"max":20}]}
+		]},
+		{"op":"hasNot","column":"mage"}
+	]}`, string(encoded))
+
+	// Invalid queries can not be decoded
+	for _, data := range []string{
+		`{"op":"xor"}`,
+		`{"op":"and","args":[{"op":"eq"}]}`,
+		`{"op":"or","args":[{"op":"like","column":"name"}]}`,
+		`[]`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(data), new(Builder)), data)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	for _, query := range []*Builder{
+		Q().Gt("age", "thirty"),
+		Q().Lt("age", nil),
+		Q().Between("age", 10, "x"),
+		Q().Eq("age", []int{1}),
+		Q().Has("human").Or(Q().Gt("age", "x")),
+	} {
+		assert.Error(t, players.Query(func(txn *Txn) error {
+			assert.Equal(t, 0, txn.Where(query).Count())
+			return nil
+		}))
+	}
+}
+
+// countWhere counts the rows selected by the filters, in a separate transaction
+func countWhere(col *Collection, fn func(txn *Txn) *Txn) (n int) {
+	col.Query(func(txn *Txn) error {
+		n = fn(txn).Count()
+		return nil
+	})
+	return
+}
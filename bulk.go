@@ -0,0 +1,150 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// BulkLoad inserts rows given column by column, where the i-th value of every slice belongs
+// to the i-th row, and returns the number of rows inserted. A nil value leaves the column
+// of the row empty. The values are written straight into the commit buffers, one chunk of
+// rows per transaction, and the indexes of the loaded columns are only built once all of
+// the rows are loaded. This makes it considerably faster than inserting the rows one by
+// one for the initial hydration of a collection, but the queries running concurrently may
+// not find the loaded rows through the indexes until it returns.
+func (c *Collection) BulkLoad(columns map[string][]interface{}) (int, error) {
+	names, count, err := c.validateLoad(columns)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+
+	// Insert the rows one chunk at a time, without updating the indexes
+	var loaded bitmap.Bitmap
+	bulkContext := WithPriority(context.Background(), PriorityLow)
+	for offset := 0; offset < count; offset += chunkSize {
+		until := offset + chunkSize
+		if until > count {
+			until = count
+		}
+
+		if err := c.QueryContext(bulkContext, func(txn *Txn) error {
+			txn.bulk = true
+			rows := make([]uint32, 0, until-offset)
+			inserts := txn.bufferFor(rowColumn)
+			for i := offset; i < until; i++ {
+				idx := c.next()
+				inserts.PutOperation(commit.Insert, idx)
+				rows = append(rows, idx)
+				loaded.Set(uint32(commit.ChunkAt(idx)))
+			}
+
+			for _, name := range names {
+				column, _ := c.cols.Load(name)
+				typ, isNumber := numericTypeOf(column.Column)
+				buffer := txn.bufferFor(name)
+				for i, v := range columns[name][offset:until] {
+					switch b, isBool := v.(bool); {
+					case v == nil:
+					case isBool:
+						buffer.PutBool(rows[i], b)
+					default:
+						v, _ = nativeOf(v, typ, isNumber)
+						buffer.PutAny(commit.Put, rows[i], v)
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return offset, err
+		}
+	}
+
+	// Build the indexes of the loaded columns, once per chunk
+	c.buildIndexes(names, loaded)
+	return count, nil
+}
+
+// validateLoad checks that the columns to load exist and that their values are of the
+// expected types, and returns their names and the number of rows to load.
+func (c *Collection) validateLoad(columns map[string][]interface{}) ([]string, int, error) {
+	count := -1
+	names := make([]string, 0, len(columns))
+	for name, values := range columns {
+		column, ok := c.cols.Load(name)
+		switch {
+		case !ok:
+			return nil, 0, errorOf(ErrColumnNotFound, "column: unable to load column '%s', column does not exist", name)
+		case column.IsIndex():
+			return nil, 0, fmt.Errorf("column: unable to load column '%s', column is an index", name)
+		case count >= 0 && len(values) != count:
+			return nil, 0, fmt.Errorf("column: unable to load column '%s', expected %d values", name, count)
+		}
+
+		_, isBool := column.Column.(*columnBool)
+		typ, isNumber := numericTypeOf(column.Column)
+		for _, v := range values {
+			if _, ok := v.(bool); v == nil || ok && isBool {
+				continue
+			}
+
+			if _, ok := nativeOf(v, typ, isNumber); !ok || isBool {
+				return nil, 0, fmt.Errorf("column: unable to load column '%s', %T is not a valid value", name, v)
+			}
+		}
+
+		count = len(values)
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	if count < 0 {
+		count = 0
+	}
+	return names, count, nil
+}
+
+// buildIndexes evaluates the indexes of the columns for every row of the specified chunks,
+// while each chunk is locked.
+func (c *Collection) buildIndexes(names []string, chunks bitmap.Bitmap) {
+	start := time.Now()
+	buffer := commit.NewBuffer(chunkSize)
+	reader := commit.NewReader()
+	chunks.Range(func(x uint32) {
+		chunk := commit.Chunk(x)
+		c.lanes.yield(c.ctx, chunk)
+		c.slock.Lock(uint(chunk))
+		for _, name := range names {
+			columns, ok := c.cols.LoadWithIndex(name)
+			if !ok || len(columns) < 2 {
+				continue
+			}
+
+			buffer.Reset(name)
+			if !columns[0].Snapshot(chunk, buffer) {
+				continue
+			}
+
+			for _, index := range columns[1:] {
+				reader.Seek(buffer)
+				index.Apply(reader)
+			}
+		}
+		c.slock.Unlock(uint(chunk))
+	})
+	c.cache.reset()
+	c.pushed.reset()
+	c.log(LevelInfo, EventIndexBuilt, fmt.Sprintf("indexes of %d columns built", len(names)), nil,
+		FieldColumn, strings.Join(names, ","),
+		FieldChunks, chunks.Count(),
+		FieldDuration, time.Since(start),
+	)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkLoad(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("race", ForEnum())
+	col.CreateColumn("balance", ForFloat64())
+	col.CreateColumn("active", ForBool())
+	col.CreateIndex("rich", "balance", func(r Reader) bool {
+		return r.Float() >= 1000
+	})
+	defer col.Close()
+	col.InsertObject(Object{"name": "existing", "balance": 5000.0})
+
+	const count = 40000
+	names := make([]interface{}, count)
+	races := make([]interface{}, count)
+	balances := make([]interface{}, count)
+	actives := make([]interface{}, count)
+	for i := 0; i < count; i++ {
+		names[i] = "player"
+		races[i] = "human"
+		balances[i] = i % 2000 // converted to float64
+		actives[i] = i%2 == 0
+		if i%10 == 0 {
+			names[i] = nil
+		}
+	}
+
+	n, err := col.BulkLoad(map[string][]interface{}{
+		"name":    names,
+		"race":    races,
+		"balance": balances,
+		"active":  actives,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, count, n)
+	assert.Equal(t, count+1, col.Count())
+
+	// The indexes and the values of the loaded rows are present
+	assert.Equal(t, count/2+1, countWhere(col, func(txn *Txn) *Txn {
+		return txn.With("rich")
+	}))
+	assert.Equal(t, count/2, countWhere(col, func(txn *Txn) *Txn {
+		return txn.With("active")
+	}))
+	assert.Equal(t, count, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithValue("race", func(v interface{}) bool { return v == "human" })
+	}))
+
+	assert.NoError(t, col.QueryAt(12, func(r Row) error {
+		name, _ := r.String("name")
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, "player", name)
+		assert.Equal(t, 11.0, balance)
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(1, func(r Row) error {
+		_, ok := r.String("name")
+		assert.False(t, ok)
+		return nil
+	}))
+}
+
+func TestBulkLoadErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("balance", ForFloat64())
+	col.CreateColumn("active", ForBool())
+	col.CreateIndex("rich", "balance", func(r Reader) bool {
+		return r.Float() >= 1000
+	})
+	defer col.Close()
+
+	for _, columns := range []map[string][]interface{}{
+		{"missing": {1}},
+		{"rich": {true}},
+		{"name": {"a", "b"}, "balance": {1.0}},
+		{"balance": {"ten"}},
+		{"name": {10}},
+		{"active": {1}},
+		{"balance": {true}},
+	} {
+		n, err := col.BulkLoad(columns)
+		assert.Error(t, err)
+		assert.Equal(t, 0, n)
+	}
+
+	n, err := col.BulkLoad(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, col.Count())
+}
@@ -0,0 +1,347 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/zeebo/xxh3"
+)
+
+// queryCache represents a cache of the selections of the queries which are repeated, along
+// with the aggregates computed over them. Every commit marks the chunks it changed as stale
+// in the entries which depend on the columns it updated, and only these chunks are computed
+// again when the entry is used.
+type queryCache struct {
+	lock    sync.Mutex             // The lock protecting the entries and their stale chunks
+	size    int                    // The maximum number of entries
+	tick    uint64                 // The logical clock, for the least recently used eviction
+	entries map[uint64]*cacheEntry // The entries, by their fingerprint
+}
+
+// cacheEntry represents a cached selection
+type cacheEntry struct {
+	lock    sync.Mutex                 // The lock held while the entry is computed
+	filters []filter                   // The filters producing the selection
+	depends map[string]bool            // The columns the selection depends on
+	index   bitmap.Bitmap              // The cached selection
+	stale   bitmap.Bitmap              // The chunks of the selection to compute again
+	aggs    map[string]*cacheAggregate // The aggregates over the selection, by column
+	used    uint64                     // The last time the entry was used
+}
+
+// cacheAggregate represents an aggregate cached per chunk
+type cacheAggregate struct {
+	chunks []Aggregate   // The aggregate of every chunk
+	stale  bitmap.Bitmap // The chunks to aggregate again
+}
+
+// newQueryCache creates a new query cache with a maximum number of entries
+func newQueryCache(size int) *queryCache {
+	return &queryCache{
+		size:    size,
+		entries: make(map[uint64]*cacheEntry, size),
+	}
+}
+
+// Cached caches the selection produced by the filters chained so far, so that a transaction
+// which chains the same filters again reuses it. The selection is keyed by a fingerprint of
+// the filters and of the specified key, which must identify the predicate functions since
+// they can not be compared. When the columns it depends on are changed, only the chunks
+// which were committed are filtered again, and the same goes for the aggregates computed
+// over the selection. This has no effect unless the QueryCache option of the collection is
+// set, or if the selection was already used by the transaction.
+func (txn *Txn) Cached(key string) *Txn {
+	cache := txn.owner.cache
+	if cache == nil || txn.setup || txn.policy != nil || txn.tombstones || len(txn.filters) == 0 {
+		return txn
+	}
+
+	entry := cache.load(txn.owner, key, txn.filters)
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	if err := cache.refresh(txn, entry); err != nil {
+		txn.index.Clear()
+		txn.err = err
+		return txn
+	}
+
+	entry.index.Clone(&txn.index)
+	txn.filters = txn.filters[:0]
+	txn.setup = true
+	txn.cached = entry
+	return txn
+}
+
+// load loads or creates the entry for the filters, evicting the least recently used entry
+// if the cache is full
+func (c *queryCache) load(owner *Collection, key string, filters []filter) *cacheEntry {
+	hash := fingerprintOf(key, filters)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tick++
+	if entry, ok := c.entries[hash]; ok {
+		entry.used = c.tick
+		return entry
+	}
+
+	if len(c.entries) >= c.size {
+		var oldest *cacheEntry
+		var hashOf uint64
+		for k, v := range c.entries {
+			if oldest == nil || v.used < oldest.used {
+				oldest, hashOf = v, k
+			}
+		}
+		delete(c.entries, hashOf)
+	}
+
+	// The selection depends on the rows and the filtered columns, along with their sources
+	entry := &cacheEntry{
+		filters: append([]filter(nil), filters...),
+		depends: map[string]bool{rowColumn: true, tombstoneColumn: true},
+		aggs:    make(map[string]*cacheAggregate, 2),
+		used:    c.tick,
+	}
+
+	for _, f := range filters {
+		entry.depends[f.column] = true
+	}
+
+	for i := 0; i < owner.chunks(); i++ {
+		entry.stale.Set(uint32(i))
+	}
+
+	c.entries[hash] = entry
+	return entry
+}
+
+// refresh filters again the stale chunks of the entry. This must be called while holding
+// the lock of the entry.
+func (c *queryCache) refresh(txn *Txn, entry *cacheEntry) error {
+	c.lock.Lock()
+	stale := append(bitmap.Bitmap(nil), entry.stale...)
+	entry.stale.Clear()
+	c.lock.Unlock()
+	if stale.Count() == 0 {
+		return nil
+	}
+
+	// Filter the stale chunks with a separate transaction
+	worker := txn.owner.txns.acquire(txn.owner)
+	defer txn.owner.txns.release(worker)
+	defer worker.rollback()
+	worker.ctx = txn.ctx
+	worker.unlocked = txn.unlocked
+	worker.filters = append(worker.filters, entry.filters...)
+	worker.setupIndex()
+	for i := range worker.index {
+		if !stale.Contains(uint32(i >> bitmapShift)) {
+			worker.index[i] = 0
+		}
+	}
+
+	worker.applyFilters()
+	if err := worker.failed(); err != nil {
+		c.invalidateEntry(entry, stale)
+		return err
+	}
+
+	// Replace the stale chunks of the selection
+	if size := len(worker.index); len(entry.index) < size {
+		entry.index.Grow(uint32(size<<6) - 1)
+	}
+
+	for i := range entry.index {
+		if stale.Contains(uint32(i >> bitmapShift)) {
+			entry.index[i] = 0
+			if i < len(worker.index) {
+				entry.index[i] = worker.index[i]
+			}
+		}
+	}
+
+	// The aggregates of the chunks filtered again are stale as well
+	c.lock.Lock()
+	for _, agg := range entry.aggs {
+		agg.stale.Or(stale)
+	}
+	c.lock.Unlock()
+	return nil
+}
+
+// aggregate computes an aggregate of a numeric column over the cached selection, and only
+// aggregates again the chunks which are stale. The selection is refreshed first, so that
+// the aggregates of its chunks are computed over the same selection.
+func (c *queryCache) aggregate(txn *Txn, entry *cacheEntry, columnName string, column Numeric) (Aggregate, error) {
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	if err := c.refresh(txn, entry); err != nil {
+		return Aggregate{}, err
+	}
+
+	c.lock.Lock()
+	agg, ok := entry.aggs[columnName]
+	if !ok {
+		agg = new(cacheAggregate)
+		entry.aggs[columnName] = agg
+		for i := 0; i < txn.owner.chunks(); i++ {
+			agg.stale.Set(uint32(i))
+		}
+	}
+
+	stale := append(bitmap.Bitmap(nil), agg.stale...)
+	agg.stale.Clear()
+	c.lock.Unlock()
+
+	// Aggregate the stale chunks of the cached selection
+	var failed error
+	stale.Range(func(x uint32) {
+		chunk := commit.Chunk(x)
+		if failed != nil || !txn.rlock(chunk) {
+			failed = txn.failed()
+			return
+		}
+
+		for len(agg.chunks) <= int(chunk) {
+			agg.chunks = append(agg.chunks, Aggregate{})
+		}
+
+		var out Aggregate
+		chunk.Range(entry.index, func(idx uint32) {
+			if v, ok := column.LoadFloat64(idx); ok {
+				out.merge(Aggregate{Count: 1, Sum: v, Min: v, Max: v})
+			}
+		})
+
+		agg.chunks[chunk] = out
+		txn.runlock(chunk)
+	})
+
+	if failed != nil {
+		c.lock.Lock()
+		agg.stale.Or(stale)
+		c.lock.Unlock()
+		return Aggregate{}, failed
+	}
+
+	var out Aggregate
+	for _, chunk := range agg.chunks {
+		out.merge(chunk)
+	}
+	return out, nil
+}
+
+// invalidate marks a chunk as stale in the entries which depend on the columns changed
+func (c *queryCache) invalidate(chunk commit.Chunk, changed map[string]bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, entry := range c.entries {
+		depends := false
+		for name := range changed {
+			if entry.depends[name] {
+				depends = true
+				break
+			}
+		}
+
+		if depends {
+			entry.stale.Set(uint32(chunk))
+		}
+
+		for name, agg := range entry.aggs {
+			if depends || changed[name] {
+				agg.stale.Set(uint32(chunk))
+			}
+		}
+	}
+}
+
+// invalidateEntry marks the chunks of an entry as stale again
+func (c *queryCache) invalidateEntry(entry *cacheEntry, chunks bitmap.Bitmap) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry.stale.Or(chunks)
+}
+
+// reset removes all of the entries, once the schema of the collection has changed
+func (c *queryCache) reset() {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[uint64]*cacheEntry, c.size)
+}
+
+// commitCache invalidates the cached selections which depend on the columns committed
+// in the chunk
+func (txn *Txn) commitCache(cache *queryCache, chunk commit.Chunk) {
+	if changed := txn.changedColumns(chunk); len(changed) > 0 {
+		cache.invalidate(chunk, changed)
+	}
+}
+
+// changedColumns returns the columns changed by the transaction in the chunk, along with
+// the indexes which depend on them
+func (txn *Txn) changedColumns(chunk commit.Chunk) map[string]bool {
+	changed := make(map[string]bool, len(txn.updates))
+	for _, u := range txn.updates {
+		if u.IsEmpty() || changed[u.Column] {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			changed[u.Column] = true
+			if columns, ok := txn.owner.cols.LoadWithIndex(u.Column); ok {
+				for _, v := range columns {
+					changed[v.name] = true
+				}
+			}
+		})
+	}
+	return changed
+}
+
+// fingerprintOf computes the fingerprint of the filters and a key
+func fingerprintOf(key string, filters []filter) uint64 {
+	var word [8]byte
+	buffer := make([]byte, 0, 64)
+	appendWord := func(v uint64) {
+		binary.BigEndian.PutUint64(word[:], v)
+		buffer = append(buffer, word[:]...)
+	}
+
+	buffer = append(buffer, key...)
+	for _, f := range filters {
+		buffer = append(buffer, 0, byte(f.kind))
+		buffer = append(buffer, f.column...)
+		buffer = append(buffer, 0)
+		appendWord(math.Float64bits(f.lo))
+		appendWord(math.Float64bits(f.hi))
+		switch v := f.predicate.(type) {
+		case string:
+			buffer = append(buffer, v...)
+		case mapPredicate:
+			buffer = append(buffer, v.key...)
+		case ipNetwork:
+			buffer = append(buffer, v.addr[:]...)
+			buffer = append(buffer, byte(v.bits))
+		case int128Range:
+			buffer = append(buffer, v.lo.Encode()...)
+			buffer = append(buffer, v.hi.Encode()...)
+		case bitmap.Bitmap:
+			for _, w := range v {
+				appendWord(w)
+			}
+		}
+	}
+	return xxh3.Hash(buffer)
+}
@@ -0,0 +1,179 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/kelindar/bitmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCached(t *testing.T) {
+	col := loadPlayers(20000, Options{QueryCache: 2})
+	defer col.Close()
+
+	adults := func(txn *Txn) *Txn {
+		return txn.WithFloat("age", func(v float64) bool { return v >= 18 }).With("human")
+	}
+
+	// The first query fills the cache, the second one reuses it
+	assert.Equal(t, 5520, countCached(col, adults))
+	assert.Equal(t, 5520, countCached(col, adults))
+	assert.Len(t, col.cache.entries, 1)
+	entry := cacheEntryOf(col)
+	assert.Equal(t, 0, int(entry.stale.Count()))
+
+	// Changing an unrelated column does not invalidate the selection
+	assert.NoError(t, col.QueryAt(5, func(r Row) error {
+		r.SetFloat64("balance", 100)
+		return nil
+	}))
+	assert.Equal(t, 0, int(entry.stale.Count()))
+
+	// Changing a filtered column only invalidates its chunk
+	assert.NoError(t, col.QueryAt(16391, func(r Row) error {
+		r.SetFloat64("age", 1)
+		return nil
+	}))
+	assert.Equal(t, []uint32{1}, chunksOf(entry))
+	assert.Equal(t, 5519, countCached(col, adults))
+	assert.Equal(t, 0, int(entry.stale.Count()))
+
+	// Changing an index, through its source column, invalidates the selection
+	assert.NoError(t, col.QueryAt(16396, func(r Row) error {
+		r.SetEnum("race", "elf")
+		return nil
+	}))
+	assert.Equal(t, []uint32{1}, chunksOf(entry))
+	assert.Equal(t, 5518, countCached(col, adults))
+
+	// Deleting a row invalidates its chunk
+	assert.True(t, col.DeleteAt(6))
+	assert.Equal(t, []uint32{0}, chunksOf(entry))
+	assert.Equal(t, 5517, countCached(col, adults))
+
+	// Further filters are applied on top of the cached selection
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		assert.Equal(t, 5517, adults(txn).Cached("adults").Count())
+		return nil
+	}))
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		assert.Equal(t, 2518, adults(txn).Cached("adults").WithFloat("age", func(v float64) bool {
+			return v < 30
+		}).Count())
+		return nil
+	}))
+	assert.Len(t, col.cache.entries, 1)
+}
+
+func TestCachedAggregate(t *testing.T) {
+	col := loadPlayers(20000, Options{QueryCache: 2})
+	defer col.Close()
+
+	aggregate := func() (cached, expect Aggregate) {
+		col.Query(func(txn *Txn) error {
+			cached, _ = txn.With("human").Cached("human").Aggregate("balance", 2)
+			return nil
+		})
+		col.Query(func(txn *Txn) error {
+			expect, _ = txn.With("human").Aggregate("balance", 2)
+			return nil
+		})
+		return
+	}
+
+	cached, expect := aggregate()
+	assert.Equal(t, expect, cached)
+	assert.Equal(t, 5520, cached.Count)
+
+	// Changing the aggregated column only invalidates the aggregate of its chunk
+	entry := cacheEntryOf(col)
+	assert.NoError(t, col.QueryAt(5, func(r Row) error {
+		r.SetFloat64("balance", 1000000)
+		return nil
+	}))
+	assert.Equal(t, 0, int(entry.stale.Count()))
+	assert.Equal(t, []uint32{0}, bitsOf(entry.aggs["balance"].stale))
+
+	cached, expect = aggregate()
+	assert.Equal(t, expect, cached)
+	assert.Equal(t, float64(1000000), cached.Max)
+
+	// Rolled back inserts invalidate the selection as well
+	col.Query(func(txn *Txn) error {
+		txn.InsertObject(Object{"balance": 5.0, "race": "human"})
+		return errNoKey
+	})
+	cached, expect = aggregate()
+	assert.Equal(t, expect, cached)
+
+	// Changing the schema clears the cache
+	col.CreateColumn("other", ForInt())
+	assert.Len(t, col.cache.entries, 0)
+}
+
+func TestCachedEviction(t *testing.T) {
+	col := loadPlayers(500, Options{QueryCache: 1})
+	defer col.Close()
+
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 138, txn.With("human").Cached("a").Count())
+		return nil
+	})
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 151, txn.With("mage").Cached("b").Count())
+		return nil
+	})
+	assert.Len(t, col.cache.entries, 1)
+
+	// Different keys are cached separately
+	assert.NotEqual(t, fingerprintOf("a", []filter{{kind: filterWith, column: "active"}}),
+		fingerprintOf("b", []filter{{kind: filterWith, column: "active"}}))
+	assert.NotEqual(t, fingerprintOf("a", []filter{{kind: filterBetween, column: "age", lo: 1, hi: 2}}),
+		fingerprintOf("a", []filter{{kind: filterBetween, column: "age", lo: 1, hi: 3}}))
+}
+
+func TestCachedDisabled(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("age", ForInt())
+	col.InsertObject(Object{"age": 20})
+	defer col.Close()
+
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithFloatGreater("age", 18).Cached("adults").Count())
+		assert.Nil(t, txn.cached)
+		return nil
+	}))
+}
+
+// countCached counts the rows of a cached selection
+func countCached(col *Collection, fn func(txn *Txn) *Txn) (n int) {
+	col.Query(func(txn *Txn) error {
+		n = fn(txn).Cached("adults").Count()
+		return nil
+	})
+	return
+}
+
+// cacheEntryOf returns the only entry of the cache
+func cacheEntryOf(col *Collection) *cacheEntry {
+	for _, entry := range col.cache.entries {
+		return entry
+	}
+	return nil
+}
+
+// chunksOf returns the stale chunks of an entry
+func chunksOf(entry *cacheEntry) []uint32 {
+	return bitsOf(entry.stale)
+}
+
+// bitsOf returns the bits set in a bitmap
+func bitsOf(b bitmap.Bitmap) (out []uint32) {
+	b.Range(func(x uint32) {
+		out = append(out, x)
+	})
+	return
+}
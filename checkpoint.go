@@ -0,0 +1,256 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// CheckpointPolicy represents a policy to automatically write a snapshot of the collection
+// into a file and truncate its commit log afterwards, so that the log does not grow forever
+// and a collection can be recovered by replaying only the commits since the last snapshot.
+// The commit log must be a *commit.Log backed by a file, set as the Writer of the options.
+type CheckpointPolicy struct {
+	Path       string                // The file to write the snapshots into
+	Commits    int                   // The number of commits after which a checkpoint is written (optional)
+	LogSize    int64                 // The size of the commit log, in bytes, after which a checkpoint is written (optional)
+	OnProgress func(CheckpointEvent) // The callback to observe the progress of the checkpoints (optional)
+}
+
+// CheckpointStage represents a stage of a checkpoint
+type CheckpointStage int
+
+// Various checkpoint stages, in the order they are reported
+const (
+	CheckpointStarted   CheckpointStage = iota // The checkpoint has started
+	CheckpointSynced                           // The snapshot is written and synced to a temporary file
+	CheckpointReplaced                         // The snapshot has durably replaced the previous one
+	CheckpointTruncated                        // The commit log is truncated, the checkpoint is complete
+	CheckpointFailed                           // The checkpoint has failed, the previous snapshot and the log are kept
+)
+
+// CheckpointEvent represents the progress of a checkpoint
+type CheckpointEvent struct {
+	Stage    CheckpointStage // The stage the checkpoint has reached
+	Commits  int             // The number of commits since the previous checkpoint
+	LogSize  int64           // The size of the commit log, in bytes, when the checkpoint started
+	Duration time.Duration   // The time elapsed since the checkpoint started
+	Err      error           // The error of a failed checkpoint
+}
+
+// checkpointer keeps track of the commits written since the last checkpoint
+type checkpointer struct {
+	commits int64      // The number of commits since the last checkpoint (atomic)
+	running int32      // Whether an automatic checkpoint is in progress (atomic)
+	lock    sync.Mutex // The lock held while a checkpoint is written
+	policy  CheckpointPolicy
+}
+
+// newCheckpointer creates a new checkpointer, or returns nil if there is no policy
+func newCheckpointer(policy *CheckpointPolicy) *checkpointer {
+	if policy == nil {
+		return nil
+	}
+
+	return &checkpointer{
+		policy: *policy,
+	}
+}
+
+// commit counts a commit appended to the log
+func (c *checkpointer) commit() {
+	if c != nil {
+		atomic.AddInt64(&c.commits, 1)
+	}
+}
+
+// due checks whether a checkpoint should be written, given the current size of the log
+func (c *checkpointer) due(log *commit.Log) bool {
+	commits := atomic.LoadInt64(&c.commits)
+	switch {
+	case c.policy.Commits > 0 && commits >= int64(c.policy.Commits):
+		return true
+	case c.policy.LogSize > 0 && commits > 0 && log.Size() >= c.policy.LogSize:
+		return true
+	default:
+		return false
+	}
+}
+
+// progress reports the progress of a checkpoint to the callback, if any
+func (c *checkpointer) progress(event CheckpointEvent, stage CheckpointStage, start time.Time) {
+	if c.policy.OnProgress != nil {
+		event.Stage = stage
+		event.Duration = time.Since(start)
+		c.policy.OnProgress(event)
+	}
+}
+
+// checkpointIfDue writes a checkpoint in the background once the policy requires it. Only
+// one automatic checkpoint is written at a time.
+func (c *Collection) checkpointIfDue() {
+	cp := c.checkpoint
+	if cp == nil || c.ctx.Err() != nil {
+		return
+	}
+
+	log, ok := c.logger.(*commit.Log)
+	if !ok || !cp.due(log) || !atomic.CompareAndSwapInt32(&cp.running, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&cp.running, 0)
+		c.Checkpoint()
+	}()
+}
+
+// Checkpoint writes a snapshot of the collection into the file of the checkpoint policy and
+// then truncates the commit log, regardless of the number of commits since the previous one.
+// The snapshot is first written into a temporary file and synced, then renamed over the
+// previous snapshot and the directory synced, and only then the log is truncated and synced.
+// The transactions are excluded from the moment the state is written until the log is
+// truncated, so that no commit can be lost in between. A failure at any stage leaves the
+// previous snapshot or the log in place, and Recover() restores the collection either way.
+func (c *Collection) Checkpoint() (err error) {
+	cp := c.checkpoint
+	if cp == nil {
+		return fmt.Errorf("column: unable to checkpoint, no checkpoint policy is set")
+	}
+
+	log, ok := c.logger.(*commit.Log)
+	if !ok {
+		return fmt.Errorf("column: unable to checkpoint, the writer must be a commit log")
+	}
+
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	start := time.Now()
+	event := CheckpointEvent{
+		Commits: int(atomic.LoadInt64(&cp.commits)),
+		LogSize: log.Size(),
+	}
+
+	cp.progress(event, CheckpointStarted, start)
+	defer func() {
+		if err != nil {
+			event.Err = err
+			cp.progress(event, CheckpointFailed, start)
+		}
+	}()
+
+	// Write the snapshot into a temporary file of the same directory, so it can be renamed
+	dir := filepath.Dir(cp.policy.Path)
+	file, err := os.CreateTemp(dir, filepath.Base(cp.policy.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// Once the state is written, keep the transactions out until the log is truncated
+	var locked bool
+	err = c.snapshot(file, func() {
+		c.txlock.Lock()
+		locked = true
+	})
+	if locked {
+		defer c.txlock.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	// The snapshot must be durable before it replaces the previous one
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	cp.progress(event, CheckpointSynced, start)
+	if err := os.Rename(file.Name(), cp.policy.Path); err != nil {
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+
+	// The log can only be truncated once the rename itself is durable
+	cp.progress(event, CheckpointReplaced, start)
+	if err := log.Truncate(); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&cp.commits, 0)
+	cp.progress(event, CheckpointTruncated, start)
+	return nil
+}
+
+// Recover restores the collection from the snapshot of the checkpoint policy, if there is
+// one, and then replays the commits of the commit log which were not part of it. Since the
+// log may still contain commits of the snapshot if a checkpoint was interrupted before it
+// was truncated, these are skipped, except in deterministic mode where the snapshots carry
+// no commit IDs. This operation should be called before any of the transactions, right
+// after initialization.
+func (c *Collection) Recover() error {
+	cp := c.checkpoint
+	if cp == nil {
+		return fmt.Errorf("column: unable to recover, no checkpoint policy is set")
+	}
+
+	log, ok := c.logger.(*commit.Log)
+	if !ok {
+		return fmt.Errorf("column: unable to recover, the writer must be a commit log")
+	}
+
+	// The replayed commits are already in the log, so they must not be appended again
+	c.logger = nil
+	defer func() { c.logger = log }()
+
+	var commits []uint64
+	switch file, err := os.Open(cp.policy.Path); {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		defer file.Close()
+		if commits, err = c.restore(file); err != nil {
+			return err
+		}
+	}
+
+	return log.Range(func(change commit.Commit) error {
+		if int(change.Chunk) < len(commits) && change.ID <= commits[change.Chunk] {
+			return nil
+		}
+
+		cp.commit()
+		return c.Replay(change)
+	})
+}
+
+// CompactLog rewrites the commit log, keeping only the operations which still determine the
+// latest state of the rows. This bounds the size of the log and the time to recover a
+// long-lived, write-heavy collection without writing a full snapshot, and can be combined
+// with the checkpoints since the commits which remain keep their IDs. The writer must be a
+// *commit.Log backed by a file, the commits are blocked while the log is rewritten.
+func (c *Collection) CompactLog() error {
+	log, ok := c.logger.(*commit.Log)
+	if !ok {
+		return fmt.Errorf("column: unable to compact, the writer must be a commit log")
+	}
+
+	return log.Compact(rowColumn)
+}
@@ -0,0 +1,224 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "players.snapshot")
+	wal, err := commit.OpenFile(filepath.Join(dir, "players.log"))
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	var stages []CheckpointStage
+	col := newCheckpointed(wal, &CheckpointPolicy{
+		Path:    path,
+		Commits: 10,
+		OnProgress: func(event CheckpointEvent) {
+			lock.Lock()
+			stages = append(stages, event.Stage)
+			lock.Unlock()
+		},
+	})
+
+	// A checkpoint is written in the background after 10 commits
+	for i := 0; i < 10; i++ {
+		col.InsertObject(Object{"name": "roman", "balance": float64(i)})
+	}
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(stages) == 4
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []CheckpointStage{
+		CheckpointStarted, CheckpointSynced, CheckpointReplaced, CheckpointTruncated,
+	}, stages)
+
+	// The commits after the checkpoint are only in the log
+	for i := 10; i < 15; i++ {
+		col.InsertObject(Object{"name": "roman", "balance": float64(i)})
+	}
+	assert.NoError(t, col.Close())
+	assert.NoError(t, wal.Close())
+
+	// Recover from both the snapshot and the log
+	wal, err = commit.OpenFile(filepath.Join(dir, "players.log"))
+	assert.NoError(t, err)
+	defer wal.Close()
+
+	other := newCheckpointed(wal, &CheckpointPolicy{Path: path})
+	defer other.Close()
+	assert.NoError(t, other.Recover())
+	assert.Equal(t, 15, other.Count())
+	assert.NoError(t, other.QueryAt(14, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, 14.0, balance)
+		return nil
+	}))
+}
+
+func TestCheckpointInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "players.snapshot")
+	wal, err := commit.OpenFile(filepath.Join(dir, "players.log"))
+	assert.NoError(t, err)
+	defer wal.Close()
+
+	col := newCheckpointed(wal, &CheckpointPolicy{Path: path})
+	for i := 0; i < 5; i++ {
+		col.InsertObject(Object{"name": "roman", "balance": 1.0})
+	}
+
+	// The snapshot replaced the previous one, but the log was not truncated
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, col.Snapshot(file))
+	assert.NoError(t, file.Close())
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		r.AddFloat64("balance", 10)
+		return nil
+	}))
+	assert.NoError(t, col.Close())
+
+	// The commits already in the snapshot are skipped
+	log, err := commit.OpenFile(filepath.Join(dir, "players.log"))
+	assert.NoError(t, err)
+	defer log.Close()
+
+	var stages []CheckpointStage
+	other := newCheckpointed(log, &CheckpointPolicy{
+		Path:    path,
+		LogSize: 1,
+		OnProgress: func(event CheckpointEvent) {
+			stages = append(stages, event.Stage)
+		},
+	})
+	defer other.Close()
+	assert.NoError(t, other.Recover())
+	assert.Equal(t, 5, other.Count())
+	assert.NoError(t, other.QueryAt(0, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, 11.0, balance)
+		return nil
+	}))
+
+	// A manual checkpoint truncates the log
+	assert.NoError(t, other.Checkpoint())
+	assert.Equal(t, int64(0), log.Size())
+	assert.Equal(t, CheckpointTruncated, stages[len(stages)-1])
+}
+
+func TestCheckpointErrors(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+	assert.Error(t, col.Checkpoint())
+	assert.Error(t, col.Recover())
+
+	// The writer is not a commit log
+	writer := make(commit.Channel, 10)
+	other := NewCollection(Options{
+		Writer:     &writer,
+		Checkpoint: &CheckpointPolicy{Path: "players.snapshot"},
+	})
+	defer other.Close()
+	assert.Error(t, other.Checkpoint())
+	assert.Error(t, other.Recover())
+
+	// The directory does not exist, so the checkpoint fails
+	wal, err := commit.OpenTemp()
+	assert.NoError(t, err)
+	defer os.Remove(wal.Name())
+	defer wal.Close()
+
+	var failed error
+	broken := newCheckpointed(wal, &CheckpointPolicy{
+		Path: filepath.Join(t.TempDir(), "missing", "players.snapshot"),
+		OnProgress: func(event CheckpointEvent) {
+			failed = event.Err
+		},
+	})
+	defer broken.Close()
+	assert.Error(t, broken.Checkpoint())
+	assert.Error(t, failed)
+}
+
+func TestCompactLog(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := commit.OpenFile(filepath.Join(dir, "players.log"))
+	assert.NoError(t, err)
+	defer wal.Close()
+
+	col := newCheckpointed(wal, &CheckpointPolicy{Path: filepath.Join(dir, "players.snapshot")})
+	for i := 0; i < 100; i++ {
+		col.InsertObject(Object{"name": "roman", "balance": 0.0})
+	}
+
+	// Write the balances repeatedly, then delete some of the rows
+	for round := 0; round < 20; round++ {
+		assert.NoError(t, col.Query(func(txn *Txn) error {
+			balance := txn.Float64("balance")
+			return txn.Range(func(idx uint32) {
+				balance.Add(1)
+			})
+		}))
+	}
+	for i := uint32(0); i < 10; i++ {
+		col.DeleteAt(i)
+	}
+
+	size := wal.Size()
+	assert.NoError(t, col.CompactLog())
+	assert.Less(t, wal.Size(), size/5)
+	col.InsertObject(Object{"name": "merlin", "balance": 1.0})
+	assert.NoError(t, col.Close())
+
+	// Recover the collection from the compacted log alone
+	log, err := commit.OpenFile(filepath.Join(dir, "players.log"))
+	assert.NoError(t, err)
+	defer log.Close()
+
+	other := newCheckpointed(log, &CheckpointPolicy{Path: filepath.Join(dir, "players.snapshot")})
+	defer other.Close()
+	assert.NoError(t, other.Recover())
+	assert.Equal(t, 91, other.Count())
+	assert.NoError(t, other.Query(func(txn *Txn) error {
+		name, balance := txn.String("name"), txn.Float64("balance")
+		return txn.Range(func(idx uint32) {
+			value, _ := balance.Get()
+			switch v, _ := name.Get(); v {
+			case "roman":
+				assert.Equal(t, 20.0, value)
+			default:
+				assert.Equal(t, 1.0, value)
+			}
+		})
+	}))
+
+	// The writer must be a commit log
+	plain := NewCollection()
+	defer plain.Close()
+	assert.Error(t, plain.CompactLog())
+}
+
+// newCheckpointed creates a new collection with a checkpoint policy
+func newCheckpointed(wal *commit.Log, policy *CheckpointPolicy) *Collection {
+	col := NewCollection(Options{
+		Writer:     wal,
+		Checkpoint: policy,
+	})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("balance", ForFloat64())
+	return col
+}
@@ -0,0 +1,209 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/zeebo/xxh3"
+)
+
+// ErrCorrupted is returned when some values of a collection no longer match the checksums
+// recorded when they were committed. The error returned is a *CorruptionError which records
+// the chunks of the columns which are damaged.
+var ErrCorrupted = errors.New("column: collection is corrupted")
+
+// Corruption represents a chunk of a column whose values no longer match their checksum
+type Corruption struct {
+	Column string // The name of the column, or "row" for the rows present in the chunk
+	Chunk  int    // The chunk of the column, starting at row Chunk * 16384
+}
+
+// CorruptionError represents an error returned when some chunks of the columns are damaged.
+// It matches ErrCorrupted with errors.Is().
+type CorruptionError struct {
+	Corruptions []Corruption // The damaged chunks, by column then chunk
+}
+
+// Error returns the error message
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("column: collection is corrupted, %d chunks do not match their checksum", len(e.Corruptions))
+}
+
+// Is returns whether the error matches the target
+func (e *CorruptionError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+// checksums keeps the checksum of every chunk of the columns, as of the last commit which
+// changed it, so that the values which were changed since without a commit are detected.
+type checksums struct {
+	lock sync.Mutex
+	cols map[string][]uint64 // The checksums by column, then by chunk, 0 if unknown
+}
+
+// newChecksums creates a new table of checksums, if they are enabled
+func newChecksums(enabled bool) *checksums {
+	if !enabled {
+		return nil
+	}
+
+	return &checksums{
+		cols: make(map[string][]uint64, 8),
+	}
+}
+
+// Verify checks the values of every column against the checksums recorded by the commits,
+// and returns a *CorruptionError listing the chunks of the columns which no longer match,
+// for example if they were damaged by faulty memory. The checksums are only recorded if the
+// Checksums option is set. Every chunk is read-locked while its columns are hashed, so the
+// collection can be verified while it is in use.
+func (c *Collection) Verify() error {
+	if c.checksums == nil {
+		return fmt.Errorf("column: unable to verify, checksums are not enabled")
+	}
+
+	var damaged []Corruption
+	buffer := c.txns.acquirePage(rowColumn)
+	defer c.txns.releasePage(buffer)
+	for chunk := commit.Chunk(0); int(chunk) < c.chunks(); chunk++ {
+		c.readChunk(chunk, func(_ uint64, chunk commit.Chunk, fill bitmap.Bitmap) error {
+			damaged = append(damaged, c.checksums.verify(c, chunk, fill, buffer)...)
+			return nil
+		})
+	}
+
+	if len(damaged) == 0 {
+		return nil
+	}
+
+	sort.Slice(damaged, func(i, j int) bool {
+		if damaged[i].Column != damaged[j].Column {
+			return damaged[i].Column < damaged[j].Column
+		}
+		return damaged[i].Chunk < damaged[j].Chunk
+	})
+	return &CorruptionError{Corruptions: damaged}
+}
+
+// verify checks the columns of a chunk against their checksums, while it is locked
+func (s *checksums) verify(c *Collection, chunk commit.Chunk, fill bitmap.Bitmap, buffer *commit.Buffer) (out []Corruption) {
+	if !s.matches(rowColumn, chunk, checksumOfFill(fill)) {
+		out = append(out, Corruption{Column: rowColumn, Chunk: int(chunk)})
+	}
+
+	c.cols.Range(func(v *column) {
+		if v.Snapshot(chunk, buffer) && !s.matches(v.name, chunk, checksumOf(buffer)) {
+			out = append(out, Corruption{Column: v.name, Chunk: int(chunk)})
+		}
+	})
+	return
+}
+
+// commit records the checksums of the columns changed by a transaction in a chunk, once
+// they are applied. Since the rows deleted are removed from all of the columns, they are
+// all hashed again in that case.
+func (s *checksums) commit(txn *Txn, chunk commit.Chunk) {
+	changed := txn.changedColumns(chunk)
+	deleted := false
+	if markers, ok := txn.findMarkers(); ok {
+		txn.reader.Range(markers, chunk, func(r *commit.Reader) {
+			for r.Next() && !deleted {
+				deleted = r.Type == commit.Delete
+			}
+		})
+	}
+
+	if changed[rowColumn] {
+		txn.owner.lock.RLock()
+		sum := checksumOfFill(chunk.OfBitmap(txn.owner.fill))
+		txn.owner.lock.RUnlock()
+		s.record(rowColumn, chunk, sum)
+	}
+
+	buffer := txn.owner.txns.acquirePage(rowColumn)
+	defer txn.owner.txns.releasePage(buffer)
+	txn.owner.cols.Range(func(v *column) {
+		if (deleted || changed[v.name]) && v.Snapshot(chunk, buffer) {
+			s.record(v.name, chunk, checksumOf(buffer))
+		}
+	})
+}
+
+// record records the checksum of a chunk of a column
+func (s *checksums) record(columnName string, chunk commit.Chunk, sum uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sums := s.cols[columnName]
+	for len(sums) <= int(chunk) {
+		sums = append(sums, 0)
+	}
+
+	sums[chunk] = sum
+	s.cols[columnName] = sums
+}
+
+// matches checks whether a chunk of a column matches its checksum, if one was recorded
+func (s *checksums) matches(columnName string, chunk commit.Chunk, sum uint64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sums := s.cols[columnName]
+	return int(chunk) >= len(sums) || sums[chunk] == 0 || sums[chunk] == sum
+}
+
+// check returns a *CorruptionError if a chunk of a column snapshotted into a buffer does
+// not match its checksum, or nil if the checksums are not enabled
+func (s *checksums) check(columnName string, chunk commit.Chunk, buffer *commit.Buffer) error {
+	if s == nil || s.matches(columnName, chunk, checksumOf(buffer)) {
+		return nil
+	}
+
+	return &CorruptionError{Corruptions: []Corruption{{Column: columnName, Chunk: int(chunk)}}}
+}
+
+// checkFill returns a *CorruptionError if the rows present in a chunk do not match their
+// checksum, or nil if the checksums are not enabled
+func (s *checksums) checkFill(chunk commit.Chunk, fill bitmap.Bitmap) error {
+	if s == nil || s.matches(rowColumn, chunk, checksumOfFill(fill)) {
+		return nil
+	}
+
+	return &CorruptionError{Corruptions: []Corruption{{Column: rowColumn, Chunk: int(chunk)}}}
+}
+
+// remove removes the checksums of a column
+func (s *checksums) remove(columnName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.cols, columnName)
+}
+
+// checksumOf computes the checksum of a column snapshotted into a buffer
+func checksumOf(buffer *commit.Buffer) uint64 {
+	h := xxh3.New()
+	buffer.WriteTo(h)
+	return h.Sum64() | 1
+}
+
+// checksumOfFill computes the checksum of the rows present in a chunk, ignoring the empty
+// words at the end since the fill-list grows with the capacity
+func checksumOfFill(fill bitmap.Bitmap) uint64 {
+	for len(fill) > 0 && fill[len(fill)-1] == 0 {
+		fill = fill[:len(fill)-1]
+	}
+
+	var word [8]byte
+	h := xxh3.New()
+	for _, v := range fill {
+		binary.BigEndian.PutUint64(word[:], v)
+		h.Write(word[:])
+	}
+	return h.Sum64() | 1
+}
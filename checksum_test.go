@@ -0,0 +1,56 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	players := loadPlayers(40000, Options{Checksums: true})
+	defer players.Close()
+	assert.NoError(t, players.Verify())
+
+	// The changes made through the commits are hashed again
+	players.Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {
+			switch idx % 2 {
+			case 0:
+				txn.Float64("age").Add(1)
+			default:
+				txn.DeleteAt(idx)
+			}
+		})
+	})
+	assert.NoError(t, players.Verify())
+	assert.NoError(t, players.Snapshot(bytes.NewBuffer(nil)))
+
+	// Damage the values and the rows of the collection, without a commit
+	age, _ := players.cols.Load("age")
+	age.Column.(*float64Column).data[20000] += 1
+	players.lock.Lock()
+	players.fill.Remove(35000)
+	players.lock.Unlock()
+
+	err := players.Verify()
+	assert.True(t, errors.Is(err, ErrCorrupted))
+	assert.Equal(t, []Corruption{
+		{Column: "age", Chunk: 1},
+		{Column: "row", Chunk: 2},
+	}, err.(*CorruptionError).Corruptions)
+
+	// The corrupted values are not written into the snapshots
+	err = players.Snapshot(bytes.NewBuffer(nil))
+	assert.True(t, errors.Is(err, ErrCorrupted))
+}
+
+func TestVerifyDisabled(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	assert.Error(t, players.Verify())
+}
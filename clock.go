@@ -0,0 +1,73 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock represents the source of the current time of a collection, which is used for the
+// time-to-live of the rows and values, the soft-deleted rows, the retained history, the
+// audit log and the sliding windows.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock represents the wall clock, used by default
+type systemClock struct{}
+
+// Now returns the current local time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock represents a clock which only moves when it is told to, so that the tests can
+// fast-forward the time deterministically instead of sleeping.
+type ManualClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewManualClock creates a new manual clock, starting at the specified time.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the specified duration.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to the specified time.
+func (c *ManualClock) Set(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = t
+}
+
+// now returns the current time of the collection, as given by its clock
+func (c *Collection) now() time.Time {
+	return c.opts.Clock.Now()
+}
+
+// Expire removes the rows and the values of the columns whose time-to-live has elapsed as of
+// the current time of the clock of the collection, and returns the number of rows removed.
+// This is otherwise done by the vacuum on its interval, so that a test which advances a
+// manual clock can remove the expired entries right away.
+func (c *Collection) Expire() int {
+	now := c.now()
+	expired := c.expire(now)
+	c.expireColumns(now)
+	return expired
+}
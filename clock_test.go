@@ -0,0 +1,108 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockExpire(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	col := NewCollection(Options{Clock: clock, Vacuum: time.Hour})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("ability", ForString())
+	defer col.Close()
+
+	col.InsertObjectWithTTL(Object{"name": "Roman", "ability": "fire"}, time.Minute)
+	col.InsertObject(Object{"name": "Merlin", "ability": "ice"})
+	assert.NoError(t, col.SetColumnTTL("ability", 10*time.Second))
+
+	// Nothing expires until the clock moves
+	assert.Equal(t, 0, col.Expire())
+	assert.Equal(t, 2, col.Count())
+
+	// The values of the column expire first, then the row
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, 0, col.Expire())
+	assert.Equal(t, 2, col.Count())
+	assert.NoError(t, col.QueryAt(1, func(r Row) error {
+		_, ok := r.String("ability")
+		assert.False(t, ok)
+		return nil
+	}))
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, col.Expire())
+	assert.Equal(t, 1, col.Count())
+}
+
+func TestClockPurge(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	col := NewCollection(Options{Clock: clock, SoftDelete: true})
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	col.InsertObject(Object{"name": "Roman"})
+	col.InsertObject(Object{"name": "Merlin"})
+	assert.True(t, col.DeleteAt(0))
+
+	clock.Advance(time.Minute)
+	assert.True(t, col.DeleteAt(1))
+
+	// Only the row deleted earlier than the duration ago is purged
+	assert.Equal(t, 1, col.Purge(30*time.Second))
+	assert.Equal(t, 0, col.Purge(30*time.Second))
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, col.Purge(30*time.Second))
+}
+
+func TestClockQueryAsOf(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	col := NewCollection(Options{Clock: clock, Retention: time.Hour})
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	col.InsertObject(Object{"name": "Roman"})
+	clock.Advance(time.Minute)
+	col.InsertObject(Object{"name": "Merlin"})
+
+	count := func(at time.Time) (n int) {
+		assert.NoError(t, col.QueryAsOf(at, func(txn *Txn) error {
+			n = txn.Count()
+			return nil
+		}))
+		return
+	}
+
+	assert.Equal(t, 1, count(start.Add(30*time.Second)))
+	assert.Equal(t, 2, count(clock.Now()))
+
+	// Once the commits are out of the retention window, the earlier state is no longer retained
+	clock.Set(start.Add(2 * time.Hour))
+	col.InsertObject(Object{"name": "Alice"})
+	assert.Error(t, col.QueryAsOf(start.Add(30*time.Second), func(txn *Txn) error {
+		return nil
+	}))
+}
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+
+	// The collections use the wall clock by default
+	col := NewCollection()
+	defer col.Close()
+	assert.IsType(t, systemClock{}, col.opts.Clock)
+}
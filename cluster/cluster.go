@@ -0,0 +1,314 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package cluster provides a router which partitions the rows of a collection with a primary
+// key across several nodes, so that the store can run as a small cluster. Every key belongs
+// to a single node, chosen with rendezvous hashing, so that only the rows of the node added
+// or removed are moved when the membership changes, and the queries are fanned out to all
+// of the nodes. The nodes are reached through the Node interface, which is implemented by
+// Local() for the collections of the current process and by the clients of the transports.
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kelindar/column"
+	"github.com/zeebo/xxh3"
+)
+
+// errNoNodes is returned when the router has no node to route a key to
+var errNoNodes = errors.New("cluster: no nodes to route to")
+
+// Node represents a collection with a primary key, local or remote, which holds a partition
+// of the rows of the cluster. The methods must be safe for concurrent use.
+type Node interface {
+	Load(key string) (column.Object, bool, error)                // Loads the values of the row with the key
+	Store(key string, values column.Object) error                // Stores the values of the row with the key
+	Delete(key string) error                                     // Deletes the row with the key, if any
+	Query(query *column.Builder) ([]column.Object, error)        // Returns the rows matching the query
+	Range(fn func(key string, values column.Object) error) error // Iterates over all of the rows
+}
+
+// Router represents a client which routes the rows to the nodes of a cluster by their key.
+type Router struct {
+	lock  sync.RWMutex
+	nodes map[string]Node
+}
+
+// NewRouter creates a new router without any node.
+func NewRouter() *Router {
+	return &Router{
+		nodes: make(map[string]Node, 4),
+	}
+}
+
+// Nodes returns the names of the nodes of the cluster, in alphabetical order.
+func (r *Router) Nodes() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.names()
+}
+
+// Add adds a node to the cluster and rebalances the rows, moving to the new node the rows of
+// the other nodes whose keys now belong to it. The requests are blocked while the rows are
+// moved, and the node must not hold any row which belongs to another node.
+func (r *Router) Add(name string, node Node) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.nodes[name]; ok {
+		return fmt.Errorf("cluster: node '%s' already exists", name)
+	}
+
+	r.nodes[name] = node
+	for _, owner := range r.names() {
+		if owner == name {
+			continue
+		}
+
+		if err := r.move(r.nodes[owner], func(key string) bool {
+			return r.ownerOf(key) == name
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes a node from the cluster, once its rows are moved to the remaining nodes.
+// The last node can only be removed once it has no rows left. The requests are blocked while
+// the rows are moved.
+func (r *Router) Remove(name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	node, ok := r.nodes[name]
+	if !ok {
+		return fmt.Errorf("cluster: node '%s' does not exist", name)
+	}
+
+	delete(r.nodes, name)
+	if err := r.move(node, func(string) bool { return true }); err != nil {
+		r.nodes[name] = node
+		return err
+	}
+	return nil
+}
+
+// Load loads the values of the row with the key from the node it belongs to.
+func (r *Router) Load(key string) (column.Object, bool, error) {
+	node, err := r.route(key)
+	if err != nil {
+		return nil, false, err
+	}
+	return node.Load(key)
+}
+
+// Store stores the values of the row with the key in the node it belongs to.
+func (r *Router) Store(key string, values column.Object) error {
+	node, err := r.route(key)
+	if err != nil {
+		return err
+	}
+	return node.Store(key, values)
+}
+
+// Delete deletes the row with the key from the node it belongs to.
+func (r *Router) Delete(key string) error {
+	node, err := r.route(key)
+	if err != nil {
+		return err
+	}
+	return node.Delete(key)
+}
+
+// Query sends the query to all of the nodes at once and returns the rows matching it, in no
+// particular order. If any of the nodes fails, the first error is returned.
+func (r *Router) Query(query *column.Builder) ([]column.Object, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var wg sync.WaitGroup
+	results := make([][]column.Object, len(r.nodes))
+	errs := make([]error, len(r.nodes))
+
+	i := 0
+	for _, node := range r.nodes {
+		wg.Add(1)
+		go func(i int, node Node) {
+			defer wg.Done()
+			results[i], errs[i] = node.Query(query)
+		}(i, node)
+		i++
+	}
+	wg.Wait()
+
+	var out []column.Object
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		out = append(out, results[i]...)
+	}
+	return out, nil
+}
+
+// NodeOf returns the name of the node the key belongs to.
+func (r *Router) NodeOf(key string) (string, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if len(r.nodes) == 0 {
+		return "", errNoNodes
+	}
+	return r.ownerOf(key), nil
+}
+
+// route returns the node the key belongs to
+func (r *Router) route(key string) (Node, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if len(r.nodes) == 0 {
+		return nil, errNoNodes
+	}
+	return r.nodes[r.ownerOf(key)], nil
+}
+
+// ownerOf returns the name of the node with the highest score for the key, which is the
+// node the key belongs to. This must be called while the lock is held.
+func (r *Router) ownerOf(key string) (owner string) {
+	var best uint64
+	for name := range r.nodes {
+		score := xxh3.HashString(name + "\x00" + key)
+		if owner == "" || score > best || score == best && name < owner {
+			owner, best = name, score
+		}
+	}
+	return
+}
+
+// move moves the rows of a node which match the predicate to the nodes they belong to, which
+// must be another node. This must be called while the lock is held.
+func (r *Router) move(node Node, moved func(key string) bool) error {
+	rows := make(map[string]column.Object, 64)
+	if err := node.Range(func(key string, values column.Object) error {
+		if moved(key) {
+			rows[key] = values
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(rows) > 0 && len(r.nodes) == 0 {
+		return errNoNodes
+	}
+
+	for key, values := range rows {
+		if err := r.nodes[r.ownerOf(key)].Store(key, values); err != nil {
+			return err
+		}
+		if err := node.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the names of the nodes, in alphabetical order
+func (r *Router) names() []string {
+	out := make([]string, 0, len(r.nodes))
+	for name := range r.nodes {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// --------------------------- Local Node ----------------------------
+
+// localNode represents a collection of the current process, used as a node
+type localNode struct {
+	collection *column.Collection
+	key        string
+}
+
+// Local returns a node which stores its rows in a collection of the current process, given
+// the name of its primary key column. This allows a process to hold some of the partitions
+// of a cluster, and the nodes reached through a transport to use the same collections.
+func Local(collection *column.Collection, keyColumn string) Node {
+	return &localNode{
+		collection: collection,
+		key:        keyColumn,
+	}
+}
+
+// Load loads the values of the row with the key.
+func (n *localNode) Load(key string) (out column.Object, found bool, err error) {
+	idx, ok := n.collection.IndexOf(key)
+	if !ok {
+		return nil, false, nil
+	}
+
+	err = n.collection.QueryAt(idx, func(row column.Row) error {
+		out, found = n.objectOf(row), true
+		return nil
+	})
+	return
+}
+
+// Store stores the values of the row with the key, inserting the row if it does not exist.
+func (n *localNode) Store(key string, values column.Object) error {
+	return n.collection.QueryKey(key, func(row column.Row) error {
+		for name, v := range values {
+			if name != n.key {
+				row.SetAny(name, v)
+			}
+		}
+		return nil
+	})
+}
+
+// Delete deletes the row with the key, if any.
+func (n *localNode) Delete(key string) error {
+	if idx, ok := n.collection.IndexOf(key); ok {
+		n.collection.DeleteAt(idx)
+	}
+	return nil
+}
+
+// Query returns the rows matching the query.
+func (n *localNode) Query(query *column.Builder) (out []column.Object, err error) {
+	err = n.collection.Query(func(txn *column.Txn) error {
+		txn.Where(query).Rows()(func(_ uint32, row column.Row) bool {
+			out = append(out, n.objectOf(row))
+			return true
+		})
+		return nil
+	})
+	return
+}
+
+// Range iterates over all of the rows.
+func (n *localNode) Range(fn func(key string, values column.Object) error) error {
+	return n.collection.Query(func(txn *column.Txn) (err error) {
+		txn.Rows()(func(_ uint32, row column.Row) bool {
+			values := n.objectOf(row)
+			key, _ := values[n.key].(string)
+			err = fn(key, values)
+			return err == nil
+		})
+		return
+	})
+}
+
+// objectOf reads the values of the columns of a row
+func (n *localNode) objectOf(row column.Row) column.Object {
+	out := make(column.Object, 8)
+	for _, name := range n.collection.Columns() {
+		if v, ok := row.Any(name); ok {
+			out[name] = v
+		}
+	}
+	return out
+}
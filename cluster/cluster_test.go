@@ -0,0 +1,110 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package cluster
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	_, _, err := router.Load("player-1")
+	assert.Error(t, err)
+
+	nodes := map[string]*column.Collection{}
+	for _, name := range []string{"a", "b", "c"} {
+		nodes[name] = newPlayers()
+		assert.NoError(t, router.Add(name, Local(nodes[name], "serial")))
+	}
+
+	assert.Error(t, router.Add("a", Local(newPlayers(), "serial")))
+	assert.Equal(t, []string{"a", "b", "c"}, router.Nodes())
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, router.Store(keyOf(i), column.Object{"level": i}))
+	}
+
+	// The rows are spread across the nodes, each key on a single node
+	for _, node := range nodes {
+		assert.InDelta(t, 333, node.Count(), 100)
+	}
+	assertPlaced(t, router, nodes, 1000)
+
+	// The rows are fanned out from all of the nodes
+	rows, err := router.Query(column.Q().Gt("level", 899))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 100)
+
+	// Adding a node only moves the rows which now belong to it
+	nodes["d"] = newPlayers()
+	assert.NoError(t, router.Add("d", Local(nodes["d"], "serial")))
+	assert.InDelta(t, 250, nodes["d"].Count(), 100)
+	assertPlaced(t, router, nodes, 1000)
+
+	// Removing a node moves its rows to the remaining ones
+	assert.NoError(t, router.Remove("b"))
+	assert.Error(t, router.Remove("b"))
+	assert.Zero(t, nodes["b"].Count())
+	delete(nodes, "b")
+	assertPlaced(t, router, nodes, 1000)
+
+	assert.NoError(t, router.Delete(keyOf(42)))
+	_, ok, err := router.Load(keyOf(42))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRouterRemoveLast(t *testing.T) {
+	router := NewRouter()
+	players := newPlayers()
+	assert.NoError(t, router.Add("a", Local(players, "serial")))
+	assert.NoError(t, router.Store("player-1", column.Object{"level": 10}))
+
+	// The last node can not be removed while it still has rows
+	assert.Error(t, router.Remove("a"))
+	assert.Equal(t, []string{"a"}, router.Nodes())
+	assert.NoError(t, router.Delete("player-1"))
+	assert.NoError(t, router.Remove("a"))
+
+	_, err := router.NodeOf("player-1")
+	assert.Error(t, err)
+}
+
+// assertPlaced checks that every key is stored on the node it belongs to
+func assertPlaced(t *testing.T, router *Router, nodes map[string]*column.Collection, count int) {
+	total := 0
+	for _, node := range nodes {
+		total += node.Count()
+	}
+	assert.Equal(t, count, total)
+
+	for i := 0; i < count; i++ {
+		name, err := router.NodeOf(keyOf(i))
+		assert.NoError(t, err)
+		_, ok := nodes[name].IndexOf(keyOf(i))
+		assert.True(t, ok)
+
+		values, ok, err := router.Load(keyOf(i))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, i, values["level"])
+		assert.Equal(t, keyOf(i), values["serial"])
+	}
+}
+
+// keyOf returns the key of a player
+func keyOf(i int) string {
+	return "player-" + strconv.Itoa(i)
+}
+
+// newPlayers creates an empty collection of players with a primary key
+func newPlayers() *column.Collection {
+	players := column.NewCollection()
+	players.CreateColumn("serial", column.ForKey())
+	players.CreateColumn("level", column.ForInt())
+	return players
+}
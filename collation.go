@@ -0,0 +1,133 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Collation represents the rules by which the values of a string or an enum column are
+// compared with one another.
+type Collation uint8
+
+// Various collations of the string columns
+const (
+	CollateBinary Collation = iota // The values are compared byte by byte, the default
+	CollateFold                    // The values are compared with Unicode case folding
+)
+
+// collated represents a column which compares its values with a collation
+type collated interface {
+	collation() Collation
+}
+
+// collationOf returns the collation of a column, which is binary unless specified
+func collationOf(column Column) Collation {
+	if c, ok := column.(collated); ok {
+		return c.collation()
+	}
+	return CollateBinary
+}
+
+// Equal returns whether two strings are equal under the collation.
+func (c Collation) Equal(a, b string) bool {
+	switch c {
+	case CollateFold:
+		return strings.EqualFold(a, b)
+	default:
+		return a == b
+	}
+}
+
+// Compare returns an integer comparing two strings under the collation. The result is 0 if
+// they are equal, -1 if a sorts before b and +1 otherwise. With case folding, the strings
+// are first compared regardless of their case and of the accents of the latin letters, so
+// that "Émile" sorts between "eli" and "Eva" rather than after "zoe", and only then with
+// their accents.
+func (c Collation) Compare(a, b string) int {
+	if c != CollateFold {
+		return strings.Compare(a, b)
+	}
+
+	if n := compareRunes(a, b, baseRune); n != 0 {
+		return n
+	}
+	return compareRunes(a, b, foldRune)
+}
+
+// key returns a string which is the same for all of the strings equal under the collation,
+// so that it can be hashed.
+func (c Collation) key(value []byte) []byte {
+	if c != CollateFold {
+		return value
+	}
+
+	out := make([]byte, 0, len(value))
+	for len(value) > 0 {
+		r, n := utf8.DecodeRune(value)
+		out = appendRune(out, foldRune(r))
+		value = value[n:]
+	}
+	return out
+}
+
+// foldRune returns the lower case form of the smallest rune equivalent under Unicode case
+// folding, so that all of the equivalent runes have the same form.
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return unicode.ToLower(min)
+}
+
+// baseRune returns the folded form of a rune, without its accent if it is a latin letter
+func baseRune(r rune) rune {
+	r = foldRune(r)
+	if r >= 0xc0 && r < 0xc0+rune(len(latinBase)) && latinBase[r-0xc0] != '.' {
+		return rune(latinBase[r-0xc0])
+	}
+	return r
+}
+
+// latinBase are the base letters of the latin letters with accents, starting at U+00C0
+const latinBase = "" +
+	"aaaaaa.ceeeeiiii.nooooo..uuuuy..aaaaaa.ceeeeiiii.nooooo..uuuuy.y" +
+	"aaaaaaccccccccdd..eeeeeeeeeegggggggghh..iiiiiiiii...jjkk.llllll." +
+	"...nnnnnn...oooooo..rrrrrrsssssssstttt..uuuuuuuuuuuuwwyyyzzzzzz."
+
+// compareRunes compares two strings rune by rune, once mapped by a function
+func compareRunes(a, b string, fn func(rune) rune) int {
+	for len(a) > 0 && len(b) > 0 {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if ra, rb = fn(ra), fn(rb); ra != rb {
+			if ra < rb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[na:], b[nb:]
+	}
+
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// appendRune appends the UTF-8 encoding of a rune
+func appendRune(dst []byte, r rune) []byte {
+	var buffer [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buffer[:], r)
+	return append(dst, buffer[:n]...)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollationCompare(t *testing.T) {
+	names := []string{"zoe", "Émile", "eva", "Eli", "emile", "Åsa", "adam", "Zoë", "ZOE"}
+	sort.SliceStable(names, func(i, j int) bool {
+		return CollateFold.Compare(names[i], names[j]) < 0
+	})
+	assert.Equal(t, []string{"adam", "Åsa", "Eli", "emile", "Émile", "eva", "zoe", "ZOE", "Zoë"}, names)
+
+	for _, tc := range []struct {
+		collation Collation
+		a, b      string
+		expect    int
+	}{
+		{CollateBinary, "Roman", "roman", -1},
+		{CollateBinary, "roman", "roman", 0},
+		{CollateFold, "Roman", "rOMAN", 0},
+		{CollateFold, "straße", "STRASSE", 1},
+		{CollateFold, "Ǆ", "ǆ", 0},
+		{CollateFold, "K", "k", 0},
+		{CollateFold, "ab", "a", 1},
+		{CollateFold, "", "a", -1},
+	} {
+		assert.Equal(t, tc.expect, tc.collation.Compare(tc.a, tc.b), tc.a+" "+tc.b)
+		assert.Equal(t, tc.expect == 0, tc.collation.Equal(tc.a, tc.b), tc.a+" "+tc.b)
+		if tc.expect == 0 {
+			assert.Equal(t, tc.collation.key([]byte(tc.a)), tc.collation.key([]byte(tc.b)))
+		}
+	}
+}
+
+func TestCollationFilter(t *testing.T) {
+	for _, fn := range []func(opts ...ColumnOption) Column{ForString, ForEnum} {
+		col := NewCollection()
+		col.CreateColumn("name", fn(WithCollation(CollateFold)))
+		col.CreateColumn("other", fn())
+		for i := 0; i < 20000; i++ {
+			name := "alice"
+			if i%1000 == 0 {
+				name = "Émile"
+			}
+			col.InsertObject(Object{"name": name, "other": name})
+		}
+
+		count := func(fn func(txn *Txn) *Txn) (n int) {
+			col.Query(func(txn *Txn) error {
+				n = fn(txn).Count()
+				return nil
+			})
+			return
+		}
+
+		// The collation of the column is used for the equality
+		assert.Equal(t, 20, count(func(txn *Txn) *Txn { return txn.WithStringEqual("name", "éMILE") }))
+		assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithStringEqual("other", "éMILE") }))
+		assert.Equal(t, 20, count(func(txn *Txn) *Txn { return txn.WithStringFold("other", "éMILE") }))
+		assert.Equal(t, 19980, count(func(txn *Txn) *Txn { return txn.WithStringFold("name", "ALICE") }))
+		assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithStringFold("name", "emile") }))
+
+		// The bloom filters hash the values with the collation of the column
+		assert.NoError(t, col.CreateBloomIndex("name_bloom", "name"))
+		assert.NoError(t, col.CreateBloomIndex("other_bloom", "other"))
+		assert.Equal(t, 20, count(func(txn *Txn) *Txn { return txn.WithStringEqual("name", "ÉMILE") }))
+		assert.Equal(t, 20, count(func(txn *Txn) *Txn { return txn.WithStringFold("other", "ÉMILE") }))
+		assert.Equal(t, 20, count(func(txn *Txn) *Txn { return txn.WithStringEqual("other", "Émile") }))
+
+		// The collation is kept when the column is copied
+		column, _ := col.cols.Load("name")
+		empty, err := makeEmpty(column.Column)
+		assert.NoError(t, err)
+		assert.Equal(t, CollateFold, collationOf(empty))
+		col.Close()
+	}
+}
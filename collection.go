@@ -7,53 +7,143 @@ import (
 	"context"
 	"fmt"
 	"math/bits"
+	"net"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
-	"github.com/kelindar/smutex"
 )
 
 // Object represents a single object
 type Object = map[string]interface{}
 
 const (
-	expireColumn = "expire"
-	rowColumn    = "row"
+	expireColumn    = "expire"
+	rowColumn       = "row"
+	tombstoneColumn = "tombstone"
 )
 
 // Collection represents a collection of objects in a columnar format
 type Collection struct {
-	count   uint64             // The current count of elements
-	txns    *txnPool           // The transaction pool
-	lock    sync.RWMutex       // The mutex to guard the fill-list
-	slock   *smutex.SMutex128  // The sharded mutex for the collection
-	cols    columns            // The map of columns
-	fill    bitmap.Bitmap      // The fill-list
-	opts    Options            // The options configured
-	logger  commit.Logger      // The commit logger for CDC
-	record  *commit.Log        // The commit logger for snapshot
-	pk      *columnKey         // The primary key column
-	cancel  context.CancelFunc // The cancellation function for the context
-	commits []uint64           // The array of commit IDs for corresponding chunk
+	count      uint64             // The current count of elements
+	tombstones int64              // The current count of soft-deleted elements
+	observers  *rowObservers      // The features which read the changes of the rows as committed
+	txns       *txnPool           // The transaction pool
+	lock       sync.RWMutex       // The mutex to guard the fill-list
+	txlock     sync.RWMutex       // The mutex to exclude transactions while shrinking
+	slock      *chunkLocks        // The sharded mutex for the collection
+	cols       columns            // The map of columns
+	fill       bitmap.Bitmap      // The fill-list
+	opts       Options            // The options configured
+	logger     commit.Logger      // The commit logger for CDC
+	record     *commit.Log        // The commit logger for snapshot
+	history    *history           // The history of recent commits (optional)
+	pk         *columnKey         // The primary key column
+	ctx        context.Context    // The context of the collection, cancelled once closed
+	cancel     context.CancelFunc // The cancellation function for the context
+	commits    []uint64           // The array of commit IDs for corresponding chunk
+	stats      statistics         // The selectivity statistics of the filters
+	queries    counters           // The counters of the queries, for the statistics
+	arena      *arena             // The arena for the strings of the columns
+	rows       *mapped            // The storage of the fill-list (optional)
+	stored     bitmap.Bitmap      // The fill-list persisted in the storage (optional)
+	shared     *publisher         // The versions and the schema published for the shared readers (optional)
+	touched    []int64            // The last access time of each chunk, for spilling (optional)
+	policy     RowPolicy          // The row-level security policy (optional)
+	masks      map[string]Mask    // The masks of the columns, by their name (optional)
+	hooks      [3][]Hook          // The hooks called before the changes are committed (optional)
+	trigs      [3][]trigger       // The triggers called within the transactions (optional)
+	tenants    *tenants           // The tenants and their quotas (optional)
+	props      map[string]string  // The properties written in the snapshots (optional)
+	cache      *queryCache        // The cache of the selections of repeated queries (optional)
+	plans      *planCache         // The cache of the plans of the query shapes
+	pushed     *pushdownCache     // The aggregates of the chunks computed from the bitmaps
+	colstats   *columnStats       // The statistics maintained for the numeric columns
+	histograms *columnHistograms  // The histograms maintained for the numeric columns
+	scans      limiter            // The limit of the concurrent scans (optional)
+	writers    limiter            // The limit of the concurrent commits (optional)
+	checkpoint *checkpointer      // The automatic checkpoints of the collection (optional)
+	replicas   []*replica         // The filtered replicas to ship the commits to (optional)
+	outputs    []*commitWriter    // The writers to deliver the commits to, by priority (optional)
+	versions   *versions          // The versions of the values, if the replay conflicts are resolved
+	windows    []*Window          // The sliding windows to maintain (optional)
+	advisor    *advisor           // The advisor of the indexes for the filters which scan
+	backend    *backend           // The database the collection caches (optional)
+	ttls       *columnTTLs        // The deadlines of the values of the columns with a time-to-live
+	counters   *columnCounters    // The counters of the rows matching a predicate
+	checksums  *checksums         // The checksums of the chunks of the columns (optional)
+	lanes      lanes              // The high-priority transactions waiting for the chunk locks
+	frozen     int32              // Whether the collection is frozen, see Freeze()
+	seq        uint64             // The order in which the transactions spanning several collections lock it
+	compacting sync.Mutex         // The lock which serializes the compactions of the dictionaries
+	origin     atomic.Value       // The origin whose chunks are shared with this fork, see Fork()
+	forks      forkSet            // The forks which share the chunks of this collection
+	dirty      dirtyViews         // The frozen forks read by the dirty transactions, see QueryDirty()
 }
 
 // Options represents the options for a collection.
 type Options struct {
-	Capacity int           // The initial capacity when creating columns
-	Writer   commit.Logger // The writer for the commit log (optional)
-	Vacuum   time.Duration // The interval at which the vacuum of expired entries will be done
+	Capacity             int                          // The initial capacity when creating columns
+	Writer               commit.Logger                // The writer for the commit log (optional)
+	Vacuum               time.Duration                // The interval at which the vacuum of expired entries will be done
+	Timeout              time.Duration                // The maximum duration of a transaction (optional)
+	LockTimeout          time.Duration                // The maximum duration to wait for a chunk lock, for reading or committing (optional)
+	Retention            time.Duration                // The duration for which previous versions are retained (optional)
+	SoftDelete           bool                         // Whether deleted rows are hidden until purged (optional)
+	Versioned            bool                         // Whether every row has a version, incremented by every change, see Row.Version() (optional)
+	Audit                *AuditLog                    // The audit log to record the changes into (optional)
+	OnExpire             func(idx uint32, row Object) // The callback for expired rows, once removed (optional)
+	MaxRows              int                          // The maximum number of rows, enforced by the eviction policy (optional)
+	Eviction             Eviction                     // The eviction policy to use once MaxRows is exceeded (optional)
+	AutoShrink           bool                         // Whether unused capacity is released during the vacuum (optional)
+	Observer             Observer                     // The observer of the operations, for metrics (optional)
+	SlowQuery            time.Duration                // The duration after which a transaction is reported as slow (optional)
+	OnSlowQuery          func(info QueryInfo)         // The callback for transactions slower than SlowQuery (optional)
+	Tracer               Tracer                       // The tracer to report the spans of the operations to (optional)
+	Storage              Storage                      // The storage backing the numeric and boolean columns (optional)
+	SpillAfter           time.Duration                // The idle duration after which chunks are released to the storage (optional)
+	Encryption           KeyProvider                  // The provider of the keys to encrypt the snapshots with (optional)
+	Dictionary           []byte                       // The zstd dictionary to compress the snapshots with, see TrainDictionary() (optional)
+	Deterministic        bool                         // Whether the same rows always produce identical snapshots (optional)
+	SnapshotWorkers      int                          // The number of chunks written or restored in parallel by the snapshots (optional)
+	Checksums            bool                         // Whether the chunks of the columns are checksummed to detect corruption (optional)
+	QueryCache           int                          // The maximum number of selections kept by Cached() (optional)
+	MaxConcurrentQueries int                          // The maximum number of transactions scanning at once (optional)
+	MaxWriters           int                          // The maximum number of transactions committing at once (optional)
+	Checkpoint           *CheckpointPolicy            // The policy to snapshot the collection and truncate the commit log (optional)
+	Advisor              *AdvisorPolicy               // The policy of the index advisor, to create the indexes advised (optional)
+	Flatten              *Flattening                  // The flattening of the nested objects inserted into dotted columns (optional)
+	Conflicts            *ConflictPolicy              // The resolution of the conflicts of the replayed commits (optional)
+	Backend              *Backend                     // The database to load the missing keys from and write the changes to (optional)
+	Clock                Clock                        // The clock for the time-to-live, history and audit, the wall clock by default (optional)
+	Logger               Logger                       // The logger of the index builds, vacuum, snapshots, evictions and violations (optional)
+	Staleness            time.Duration                // The maximum age of the fork read by QueryDirty(), one second by default (optional)
 }
 
-// NewCollection creates a new columnar collection.
+// NewCollection creates a new columnar collection. It panics if the collection is backed
+// by a storage whose buffers can not be opened, use Open() to handle the error instead.
 func NewCollection(opts ...Options) *Collection {
+	store, err := Open(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// Open creates a new columnar collection. If the collection is backed by a storage, the rows
+// and the internal columns persisted in it are restored, and an error is returned if its
+// buffers can not be opened.
+func Open(opts ...Options) (*Collection, error) {
 	options := Options{
 		Capacity: 1024,
 		Vacuum:   1 * time.Second,
 		Writer:   nil,
+		Clock:    systemClock{},
 	}
 
 	// Merge options together
@@ -67,24 +157,202 @@ func NewCollection(opts ...Options) *Collection {
 		if o.Writer != nil {
 			options.Writer = o.Writer
 		}
+		if o.Timeout > 0 {
+			options.Timeout = o.Timeout
+		}
+		if o.Staleness > 0 {
+			options.Staleness = o.Staleness
+		}
+		if o.LockTimeout > 0 {
+			options.LockTimeout = o.LockTimeout
+		}
+		if o.Retention > 0 {
+			options.Retention = o.Retention
+		}
+		if o.SoftDelete {
+			options.SoftDelete = true
+		}
+		if o.Versioned {
+			options.Versioned = true
+		}
+		if o.Audit != nil {
+			options.Audit = o.Audit
+		}
+		if o.OnExpire != nil {
+			options.OnExpire = o.OnExpire
+		}
+		if o.MaxRows > 0 {
+			options.MaxRows = o.MaxRows
+		}
+		if o.Eviction != nil {
+			options.Eviction = o.Eviction
+		}
+		if o.AutoShrink {
+			options.AutoShrink = true
+		}
+		if o.Observer != nil {
+			options.Observer = o.Observer
+		}
+		if o.SlowQuery > 0 {
+			options.SlowQuery = o.SlowQuery
+		}
+		if o.OnSlowQuery != nil {
+			options.OnSlowQuery = o.OnSlowQuery
+		}
+		if o.Tracer != nil {
+			options.Tracer = o.Tracer
+		}
+		if o.Storage != nil {
+			options.Storage = o.Storage
+		}
+		if o.SpillAfter > 0 {
+			options.SpillAfter = o.SpillAfter
+		}
+		if o.Encryption != nil {
+			options.Encryption = o.Encryption
+		}
+		if o.Dictionary != nil {
+			options.Dictionary = o.Dictionary
+		}
+		if o.Deterministic {
+			options.Deterministic = true
+		}
+		if o.SnapshotWorkers > 0 {
+			options.SnapshotWorkers = o.SnapshotWorkers
+		}
+		if o.Checksums {
+			options.Checksums = true
+		}
+		if o.QueryCache > 0 {
+			options.QueryCache = o.QueryCache
+		}
+		if o.MaxConcurrentQueries > 0 {
+			options.MaxConcurrentQueries = o.MaxConcurrentQueries
+		}
+		if o.MaxWriters > 0 {
+			options.MaxWriters = o.MaxWriters
+		}
+		if o.Checkpoint != nil {
+			options.Checkpoint = o.Checkpoint
+		}
+		if o.Advisor != nil {
+			options.Advisor = o.Advisor
+		}
+		if o.Flatten != nil {
+			options.Flatten = o.Flatten
+		}
+		if o.Conflicts != nil {
+			options.Conflicts = o.Conflicts
+		}
+		if o.Backend != nil {
+			options.Backend = o.Backend
+		}
+		if o.Clock != nil {
+			options.Clock = o.Clock
+		}
+		if o.Logger != nil {
+			options.Logger = o.Logger
+		}
 	}
 
 	// Create a new collection
 	ctx, cancel := context.WithCancel(context.Background())
+	observers := new(rowObservers)
 	store := &Collection{
-		cols:   makeColumns(8),
-		txns:   newTxnPool(),
-		opts:   options,
-		slock:  new(smutex.SMutex128),
-		fill:   make(bitmap.Bitmap, 0, options.Capacity>>6),
-		logger: options.Writer,
-		cancel: cancel,
+		cols:       makeColumns(8),
+		txns:       newTxnPool(),
+		opts:       options,
+		slock:      new(chunkLocks),
+		fill:       make(bitmap.Bitmap, 0, options.Capacity>>6),
+		logger:     options.Writer,
+		ctx:        ctx,
+		cancel:     cancel,
+		arena:      newArena(),
+		scans:      newLimiter(options.MaxConcurrentQueries),
+		writers:    newLimiter(options.MaxWriters),
+		checkpoint: newCheckpointer(options.Checkpoint),
+		colstats:   newColumnStats(observers),
+		plans:      newPlanCache(),
+		pushed:     newPushdownCache(),
+		histograms: newColumnHistograms(observers),
+		advisor:    newAdvisor(options.Advisor),
+		versions:   newVersions(options.Conflicts),
+		backend:    newBackend(options.Backend),
+		ttls:       newColumnTTLs(observers),
+		counters:   newColumnCounters(observers),
+		checksums:  newChecksums(options.Checksums),
+		seq:        nextCollection(),
+		observers:  observers,
+	}
+	store.slock.owner = store
+
+	// If requested, cache the selections of the repeated queries
+	if options.QueryCache > 0 {
+		store.cache = newQueryCache(options.QueryCache)
+		observers.add(1)
+	}
+
+	// If requested, retain the history of the collection
+	if options.Retention > 0 {
+		store.history = newHistory(options.Retention, options.Clock.Now())
+		observers.add(1)
+	}
+
+	// The commit log, the audit, the eviction, the versions and the checksums read the
+	// changes of the rows one by one as well
+	for _, enabled := range []bool{
+		options.Writer != nil, options.Audit != nil, options.Eviction != nil,
+		store.versions != nil, store.checksums != nil,
+	} {
+		if enabled {
+			observers.add(1)
+		}
+	}
+
+	// If a storage is used, restore the rows which were persisted in it
+	if options.Storage != nil {
+		if err := store.mountRows(); err != nil {
+			cancel()
+			return nil, err
+		}
+		if _, readOnly := options.Storage.(remapper); !readOnly {
+			if err := store.mountShared(); err != nil {
+				cancel()
+				return nil, err
+			}
+		}
 	}
 
 	// Create an expiration column and start the cleanup goroutine
-	store.CreateColumn(expireColumn, ForInt64())
+	if err := store.createInternal(); err != nil {
+		cancel()
+		return nil, err
+	}
+
 	go store.vacuum(ctx, options.Vacuum)
-	return store
+	if b := store.backend; b != nil && b.conf.Flush != nil {
+		go store.writeBehind(ctx, b.conf.Interval)
+	}
+	return store, nil
+}
+
+// createInternal creates the internal columns of the collection, as per its options
+func (c *Collection) createInternal() error {
+	if err := c.CreateColumn(expireColumn, ForInt64()); err != nil {
+		return err
+	}
+
+	if c.opts.SoftDelete {
+		if err := c.CreateColumn(tombstoneColumn, ForInt64()); err != nil {
+			return err
+		}
+		c.recountTombstones()
+	}
+
+	if c.opts.Versioned {
+		return c.CreateColumn(versionColumn, ForUint64())
+	}
+	return nil
 }
 
 // next finds the next free index in the collection, atomically.
@@ -97,6 +365,32 @@ func (c *Collection) next() uint32 {
 	return idx
 }
 
+// nextN allocates the next free indexes for a number of rows, under a single lock, and
+// appends them to the destination.
+func (c *Collection) nextN(dst []uint32, n int) []uint32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i := 0; i < n; i++ {
+		idx := c.findFreeIndex(atomic.AddUint64(&c.count, 1))
+		c.fill.Set(idx)
+		dst = append(dst, idx)
+	}
+	return dst
+}
+
+// reserve reserves a specific index in the collection, atomically, unless it is taken.
+func (c *Collection) reserve(idx uint32) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.fill.Contains(idx) {
+		return false
+	}
+
+	c.fill.Set(idx)
+	atomic.AddUint64(&c.count, 1)
+	return true
+}
+
 // findFreeIndex finds a free index for insertion
 func (c *Collection) findFreeIndex(count uint64) uint32 {
 	fillSize := len(c.fill)
@@ -107,8 +401,9 @@ func (c *Collection) findFreeIndex(count uint64) uint32 {
 	}
 
 	// Check if we have space at the end, since if we're inserting a lot of data it's more
-	// likely that we're full in the beginning.
-	if tailAt := int((count - 1) >> 6); fillSize > tailAt {
+	// likely that we're full in the beginning. In deterministic mode, the first free index
+	// is always used so that it only depends on the rows present.
+	if tailAt := int((count - 1) >> 6); !c.opts.Deterministic && fillSize > tailAt {
 		if tail := c.fill[tailAt]; tail != 0xffffffffffffffff {
 			return uint32((tailAt)<<6 + bits.TrailingZeros64(^tail))
 		}
@@ -119,11 +414,21 @@ func (c *Collection) findFreeIndex(count uint64) uint32 {
 	return idx
 }
 
-// InsertObject adds an object to a collection and returns the allocated index.
+// InsertObject adds an object to a collection and returns the allocated index. If the
+// Flatten option is set, the nested maps and structs of the object are flattened first.
+// An object which can not be inserted is rolled back; use TryInsertObject() in order to
+// handle the error.
 func (c *Collection) InsertObject(obj Object) (index uint32) {
-	c.Query(func(txn *Txn) error {
-		index, _ = txn.InsertObject(obj)
-		return nil
+	index, _ = c.TryInsertObject(obj)
+	return
+}
+
+// TryInsertObject adds an object to a collection and returns the allocated index, or the
+// error for which the object could not be inserted, such as ErrTypeMismatch or ErrDuplicateKey.
+func (c *Collection) TryInsertObject(obj Object) (index uint32, err error) {
+	err = c.Query(func(txn *Txn) (innerErr error) {
+		index, innerErr = txn.InsertObject(obj)
+		return
 	})
 	return
 }
@@ -131,9 +436,17 @@ func (c *Collection) InsertObject(obj Object) (index uint32) {
 // InsertObjectWithTTL adds an object to a collection, sets the expiration time
 // based on the specified time-to-live and returns the allocated index.
 func (c *Collection) InsertObjectWithTTL(obj Object, ttl time.Duration) (index uint32) {
-	c.Query(func(txn *Txn) error {
-		index, _ = txn.InsertObjectWithTTL(obj, ttl)
-		return nil
+	index, _ = c.TryInsertObjectWithTTL(obj, ttl)
+	return
+}
+
+// TryInsertObjectWithTTL adds an object to a collection, sets the expiration time based on
+// the specified time-to-live and returns the allocated index, or the error for which the
+// object could not be inserted.
+func (c *Collection) TryInsertObjectWithTTL(obj Object, ttl time.Duration) (index uint32, err error) {
+	err = c.Query(func(txn *Txn) (innerErr error) {
+		index, innerErr = txn.InsertObjectWithTTL(obj, ttl)
+		return
 	})
 	return
 }
@@ -157,6 +470,15 @@ func (c *Collection) InsertWithTTL(ttl time.Duration, fn func(Row) error) (index
 	return
 }
 
+// InsertAt executes a mutable cursor transactionally at the specified offset, which must
+// not be taken by another row. This allows to replay the rows of another system while
+// preserving their offsets.
+func (c *Collection) InsertAt(index uint32, fn func(Row) error) error {
+	return c.Query(func(txn *Txn) error {
+		return txn.InsertAt(index, fn)
+	})
+}
+
 // DeleteAt attempts to delete an item at the specified index for this collection. If the item
 // exists, it marks at as deleted and returns true, otherwise it returns false.
 func (c *Collection) DeleteAt(idx uint32) (deleted bool) {
@@ -167,9 +489,42 @@ func (c *Collection) DeleteAt(idx uint32) (deleted bool) {
 	return
 }
 
-// Count returns the total number of elements in the collection.
+// Count returns the total number of elements in the collection. If soft delete is enabled,
+// the rows which were deleted but not yet purged are not counted.
 func (c *Collection) Count() (count int) {
-	return int(atomic.LoadUint64(&c.count))
+	count = int(atomic.LoadUint64(&c.count))
+	if c.opts.SoftDelete {
+		count -= int(atomic.LoadInt64(&c.tombstones))
+	}
+	return
+}
+
+// recountTombstones counts the soft-deleted rows again, once they are restored without
+// being committed.
+func (c *Collection) recountTombstones() {
+	if column, ok := c.cols.Load(tombstoneColumn); ok {
+		column.lock.RLock()
+		count := column.Index().Count()
+		column.lock.RUnlock()
+		atomic.StoreInt64(&c.tombstones, int64(count))
+	}
+}
+
+// Purge permanently removes all of the soft-deleted rows which were deleted earlier than the
+// specified duration ago and returns the number of rows removed.
+func (c *Collection) Purge(olderThan time.Duration) (purged int) {
+	until := c.now().Add(-olderThan).UnixNano()
+	c.Query(func(txn *Txn) error {
+		txn.tombstones = true
+		deletedAt := txn.Int64(tombstoneColumn)
+		return txn.With(tombstoneColumn).Range(func(idx uint32) {
+			if at, ok := deletedAt.Get(); ok && at <= until {
+				txn.deleteAt(idx)
+				purged++
+			}
+		})
+	})
+	return
 }
 
 // createColumnKey attempts to create a primary key column
@@ -184,11 +539,24 @@ func (c *Collection) createColumnKey(columnName string, column *columnKey) error
 }
 
 // CreateColumnsOf registers a set of columns that are present in the target object.
+// If the Flatten option is set, the columns of the nested fields are registered instead.
 func (c *Collection) CreateColumnsOf(object Object) error {
+	if f := c.opts.Flatten; f != nil {
+		object = f.flatten(object, func(string) bool { return false })
+	}
+
 	for k, v := range object {
-		column, err := ForKind(reflect.TypeOf(v).Kind())
-		if err != nil {
-			return err
+		var column Column
+		var err error
+		switch v.(type) {
+		case time.Duration:
+			column = ForDuration()
+		case net.IP:
+			column = ForIP()
+		default:
+			if column, err = ForKind(reflect.TypeOf(v).Kind()); err != nil {
+				return err
+			}
 		}
 
 		if err := c.CreateColumn(k, column); err != nil {
@@ -200,12 +568,51 @@ func (c *Collection) CreateColumnsOf(object Object) error {
 
 // CreateColumn creates a column of a specified type and adds it to the collection.
 func (c *Collection) CreateColumn(columnName string, column Column) error {
+	if err := c.writable(); err != nil {
+		return err
+	}
+
 	if _, ok := c.cols.Load(columnName); ok {
-		return fmt.Errorf("column: unable to create column '%s', already exists", columnName)
+		return errorOf(ErrColumnExists, "column: unable to create column '%s', already exists", columnName)
+	}
+
+	// If the column stores its strings in an arena, use the one of the collection
+	if v, ok := column.(arenaColumn); ok {
+		c.lock.RLock()
+		v.attach(c.arena)
+		c.lock.RUnlock()
+	}
+
+	// If the column supports it, back it with the storage and restore its values
+	v, mounted := column.(mountable)
+	if mounted = mounted && c.opts.Storage != nil; mounted {
+		if err := v.mount(&mapped{storage: c.opts.Storage, name: columnName}); err != nil {
+			return err
+		}
 	}
 
-	column.Grow(uint32(c.opts.Capacity))
-	c.cols.Store(columnName, columnFor(columnName, column))
+	// Keep the bounds of the values of every chunk, unless they were restored without a commit
+	stored := columnFor(columnName, column)
+	if stored.IsNumeric() && !mounted {
+		stored.zones = newZoneMap()
+	}
+
+	// Grow the buffers of the storage first, since they may fail to grow
+	if mounted {
+		if err := v.reserve(c.capacity()); err != nil {
+			return err
+		}
+	}
+
+	column.Grow(c.capacity())
+	c.cols.Store(columnName, stored)
+	c.cache.reset()
+	c.plans.reset()
+	c.colstats.remove(columnName)
+	c.histograms.remove(columnName)
+	if err := c.publishSchema(); err != nil {
+		return err
+	}
 
 	// If necessary, create a primary key column
 	if pk, ok := column.(*columnKey); ok {
@@ -217,7 +624,18 @@ func (c *Collection) CreateColumn(columnName string, column Column) error {
 // DropColumn removes the column (or an index) with the specified name. If the column with this
 // name does not exist, this operation is a no-op.
 func (c *Collection) DropColumn(columnName string) {
+	c.dropShared(columnName)
 	c.cols.DeleteColumn(columnName)
+	c.cache.reset()
+	c.plans.reset()
+	c.colstats.remove(columnName)
+	c.histograms.remove(columnName)
+	c.ttls.remove(columnName)
+	c.counters.remove(columnName)
+	if c.checksums != nil {
+		c.checksums.remove(columnName)
+	}
+	c.publishSchema()
 }
 
 // CreateIndex creates an index column with a specified name which depends on a given
@@ -228,35 +646,238 @@ func (c *Collection) CreateIndex(indexName, columnName string, fn func(r Reader)
 		return fmt.Errorf("column: create index must specify name, column and function")
 	}
 
-	// Prior to creating an index, we should have a column
-	column, ok := c.cols.Load(columnName)
-	if !ok {
-		return fmt.Errorf("column: unable to create index, column '%v' does not exist", columnName)
+	return c.addIndex(indexName, columnName, newIndex(indexName, columnName, fn))
+}
+
+// CreateExpressionIndex creates an index with a specified name whose rule can read any of
+// the source columns of a row, for example to index the rows whose ratio between two of
+// their columns is above a threshold. The rule is evaluated again whenever one of the source
+// columns of a row is updated, and must only read the source columns.
+func (c *Collection) CreateExpressionIndex(indexName string, columnNames []string, fn func(r Row) bool) error {
+	if fn == nil || len(columnNames) == 0 || indexName == "" {
+		return fmt.Errorf("column: create index must specify name, columns and function")
 	}
 
-	// Create and add the index column,
-	index := newIndex(indexName, columnName, fn)
+	sources := make([]*column, 0, len(columnNames))
+	for _, columnName := range columnNames {
+		column, ok := c.cols.Load(columnName)
+		if !ok {
+			return errorOf(ErrColumnNotFound, "column: unable to create index, column '%v' does not exist", columnName)
+		}
+		sources = append(sources, column)
+	}
+
+	// Attach the index to all of the source columns
+	index := newExpr(indexName, c, sources, fn)
+	capacity := c.capacity()
 	c.lock.Lock()
-	index.Grow(uint32(c.opts.Capacity))
+	index.Grow(capacity)
 	c.cols.Store(indexName, index)
-	c.cols.Store(columnName, column, index)
+	for _, columnName := range columnNames {
+		c.cols.Store(columnName, nil, index)
+	}
 	c.lock.Unlock()
 
+	// Evaluate the rule for all of the existing rows, chunk by chunk
+	start := time.Now()
+	buffer := commit.NewBuffer(chunkSize)
+	reader := commit.NewReader()
+	for chunk := commit.Chunk(0); int(chunk) < c.chunks(); chunk++ {
+		c.slock.Lock(uint(chunk))
+		c.lock.RLock()
+		buffer.Reset(indexName)
+		chunk.Range(c.fill, func(idx uint32) {
+			buffer.PutOperation(commit.Insert, idx)
+		})
+		c.lock.RUnlock()
+
+		reader.Seek(buffer)
+		index.Apply(reader)
+		c.slock.Unlock(uint(chunk))
+	}
+
+	c.logIndex(indexName, strings.Join(columnNames, ","), start)
+	return nil
+}
+
+// CreatePartialIndex creates an index in the same way as CreateIndex, but which only
+// contains the rows that are also in the scope, which is either a boolean column or another
+// index. Since the rule is only evaluated for the rows in the scope, this reduces the cost
+// of maintaining an index which is always queried together with its scope.
+func (c *Collection) CreatePartialIndex(indexName, columnName, scopeName string, fn func(r Reader) bool) error {
+	if fn == nil || columnName == "" || indexName == "" || scopeName == "" {
+		return fmt.Errorf("column: create index must specify name, column, scope and function")
+	}
+
+	scope, ok := c.cols.Load(scopeName)
+	if !ok {
+		return fmt.Errorf("column: unable to create index, scope '%v' does not exist", scopeName)
+	}
+
+	index := newIndex(indexName, columnName, fn)
+	index.Column.(*columnIndex).scope = scope
+	return c.addIndex(indexName, columnName, index)
+}
+
+// CreateBloomIndex creates a bloom filter index with a specified name on a string column.
+// The index keeps track of the values present in each chunk, so that the filters looking
+// for a specific value with WithStringEqual() can skip the chunks which do not contain it.
+func (c *Collection) CreateBloomIndex(indexName, columnName string) error {
+	if columnName == "" || indexName == "" {
+		return fmt.Errorf("column: create index must specify name and column")
+	}
+
+	collation := CollateBinary
+	if column, ok := c.cols.Load(columnName); ok {
+		if !column.IsTextual() {
+			return fmt.Errorf("column: unable to create bloom index, column '%v' is not textual", columnName)
+		}
+		collation = collationOf(column.Column)
+	}
+
+	return c.addIndex(indexName, columnName, newBloom(indexName, columnName, collation))
+}
+
+// CreateLookup creates a lookup index with a specified name on a textual or a numeric column.
+// The index maps each value of the column to the rows which hold it, so that SeekAll() finds
+// the rows with a specific value without scanning the column.
+func (c *Collection) CreateLookup(indexName, columnName string) error {
+	if columnName == "" || indexName == "" {
+		return fmt.Errorf("column: create index must specify name and column")
+	}
+
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return errorOf(ErrColumnNotFound, "column: unable to create index, column '%v' does not exist", columnName)
+	}
+
+	if !column.IsTextual() && !column.IsNumeric() {
+		return fmt.Errorf("column: unable to create lookup, column '%v' is not textual nor numeric", columnName)
+	}
+
+	return c.addIndex(indexName, columnName, newLookup(indexName, columnName, column.Column))
+}
+
+// CreateIndexDeferred creates an index column in the same way as CreateIndex, but without
+// computing it from the existing rows right away. The rows inserted or updated afterwards
+// are indexed immediately, while the existing rows are indexed in the background, one chunk
+// at a time, so that the writers are never blocked for more than a chunk. The returned
+// channel is closed once all of the existing rows were indexed, until then the index may
+// not contain all of the matching rows.
+func (c *Collection) CreateIndexDeferred(indexName, columnName string, fn func(r Reader) bool) (<-chan struct{}, error) {
+	if fn == nil || columnName == "" || indexName == "" {
+		return nil, fmt.Errorf("column: create index must specify name, column and function")
+	}
+
+	index := newIndex(indexName, columnName, fn)
+	column, err := c.registerIndex(indexName, columnName, index)
+	if err != nil {
+		return nil, err
+	}
+
+	ready := make(chan struct{})
+	go func() {
+		defer close(ready)
+		start := time.Now()
+		chunks := c.chunks()
+		buffer := commit.NewBuffer(chunkSize)
+		reader := commit.NewReader()
+		for chunk := commit.Chunk(0); int(chunk) < chunks && c.ctx.Err() == nil; chunk++ {
+			c.lanes.yield(c.ctx, chunk)
+			c.slock.Lock(uint(chunk))
+			fillIndex(column, index, chunk, buffer, reader)
+			c.slock.Unlock(uint(chunk))
+		}
+		c.cache.reset()
+		c.plans.reset()
+		c.logIndex(indexName, columnName, start)
+	}()
+	return ready, nil
+}
+
+// addIndex adds the index column for a target column and builds it from the values which
+// are already present in the target column.
+func (c *Collection) addIndex(indexName, columnName string, index *column) error {
+	column, err := c.registerIndex(indexName, columnName, index)
+	if err != nil {
+		return err
+	}
+
 	// Iterate over all of the values of the target column, chunk by chunk and fill
 	// the index accordingly.
+	start := time.Now()
 	chunks := c.chunks()
 	buffer := commit.NewBuffer(c.Count())
 	reader := commit.NewReader()
 	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
-		if column.Snapshot(chunk, buffer) {
-			reader.Seek(buffer)
-			index.Apply(reader)
-		}
+		fillIndex(column, index, chunk, buffer, reader)
 	}
 
+	c.cache.reset()
+	c.plans.reset()
+	c.logIndex(indexName, columnName, start)
 	return nil
 }
 
+// logIndex reports an index built from the existing rows of the collection
+func (c *Collection) logIndex(indexName, columnName string, start time.Time) {
+	c.log(LevelInfo, EventIndexBuilt, fmt.Sprintf("index '%s' built", indexName), nil,
+		FieldIndex, indexName,
+		FieldColumn, columnName,
+		FieldChunks, c.chunks(),
+		FieldDuration, time.Since(start),
+	)
+}
+
+// registerIndex adds the index column for a target column, so that it is updated by the
+// subsequent commits, and returns the target column.
+func (c *Collection) registerIndex(indexName, columnName string, index *column) (*column, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+
+	// Prior to creating an index, we should have a column
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return nil, errorOf(ErrColumnNotFound, "column: unable to create index, column '%v' does not exist", columnName)
+	}
+
+	// Create and add the index column,
+	capacity := c.capacity()
+	c.lock.Lock()
+	index.Grow(capacity)
+	c.cols.Store(indexName, index)
+	c.cols.Store(columnName, column, index)
+
+	// A partial index also needs to be evaluated when the rows enter or leave its scope. If
+	// the scope is an index itself, it changes along with the column it depends on.
+	if v, ok := index.Column.(*columnIndex); ok && v.scope != nil {
+		c.cols.Store(scopeColumn(v.scope), nil, columnFor(indexName, &columnScope{
+			index:  v,
+			target: column,
+		}))
+	}
+
+	c.lock.Unlock()
+	return column, nil
+}
+
+// scopeColumn returns the name of the column whose changes affect the scope
+func scopeColumn(scope *column) string {
+	if v, ok := scope.Column.(computed); ok {
+		return v.Column()
+	}
+	return scope.name
+}
+
+// fillIndex computes the index from the values of the target column in the chunk
+func fillIndex(column, index *column, chunk commit.Chunk, buffer *commit.Buffer, reader *commit.Reader) {
+	if column.Snapshot(chunk, buffer) {
+		reader.Seek(buffer)
+		index.Apply(reader)
+	}
+}
+
 // DropIndex removes the index column with the specified name. If the index with this
 // name does not exist, this operation is a no-op.
 func (c *Collection) DropIndex(indexName string) error {
@@ -272,7 +893,17 @@ func (c *Collection) DropIndex(indexName string) error {
 	// Figure out the associated column and delete the index from that
 	columnName := column.Column.(computed).Column()
 	c.cols.DeleteIndex(columnName, indexName)
+	if v, ok := column.Column.(*columnIndex); ok && v.scope != nil {
+		c.cols.DeleteIndex(scopeColumn(v.scope), indexName)
+	}
+	if v, ok := column.Column.(*columnExpr); ok {
+		for _, source := range v.sources {
+			c.cols.DeleteIndex(source.name, indexName)
+		}
+	}
 	c.cols.DeleteColumn(indexName)
+	c.cache.reset()
+	c.plans.reset()
 	return nil
 }
 
@@ -285,40 +916,504 @@ func (c *Collection) QueryAt(idx uint32, fn func(Row) error) error {
 }
 
 // QueryAt jumps at a particular key in the collection, sets the cursor to the
-// provided position and executes given callback fn.
+// provided position and executes given callback fn. If a backend with a loader is
+// configured, a missing key is loaded from it first.
 func (c *Collection) QueryKey(key string, fn func(Row) error) error {
+	if err := c.readThrough(key); err != nil {
+		return err
+	}
+
 	return c.Query(func(txn *Txn) error {
 		return txn.QueryKey(key, fn)
 	})
 }
 
+// IndexOf returns the index of the row with the specified primary key. Unlike QueryKey(),
+// it does not insert a row if the key does not exist, nor recover a soft-deleted one.
+func (c *Collection) IndexOf(key string) (uint32, bool) {
+	if c.pk == nil {
+		return 0, false
+	}
+
+	idx, ok := c.pk.OffsetOf(key)
+	if ok && c.opts.SoftDelete && c.QueryAt(idx, func(Row) error { return nil }) == ErrDeleted {
+		return 0, false
+	}
+	return idx, ok
+}
+
+// Columns returns the names of the columns of the collection in alphabetical order,
+// excluding the indexes and the internal columns.
+func (c *Collection) Columns() []string {
+	names := make([]string, 0, 8)
+	c.cols.Range(func(v *column) {
+		switch {
+		case v.IsIndex(), c.isInternal(v.name):
+			return
+		default:
+			names = append(names, v.name)
+		}
+	})
+
+	sort.Strings(names)
+	return names
+}
+
+// isInternal returns whether the column is one of the internal columns maintained by the
+// collection for the features which are enabled, rather than a column of the user.
+func (c *Collection) isInternal(columnName string) bool {
+	switch columnName {
+	case expireColumn:
+		return true
+	case tombstoneColumn:
+		return c.opts.SoftDelete
+	case versionColumn:
+		return c.opts.Versioned
+	default:
+		return false
+	}
+}
+
+// ColumnType returns the name of the type of a column, such as "int64", "string" or "enum",
+// and whether the column exists.
+func (c *Collection) ColumnType(columnName string) (string, bool) {
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return "", false
+	}
+	return typeName(column.Column), true
+}
+
 // Query creates a transaction which allows for filtering and iteration over the
 // columns in this collection. It also allows for individual rows to be modified or
 // deleted during iteration (range), but the actual operations will be queued and
 // executed after the iteration.
 func (c *Collection) Query(fn func(txn *Txn) error) error {
-	txn := c.txns.acquire(c)
+	return c.QueryContext(context.Background(), fn)
+}
+
+// QueryContext creates a transaction bound to a context. Iterations and filters check
+// the context periodically and stop early once it is cancelled, in which case all of
+// the pending changes are rolled back and the context error is returned.
+func (c *Collection) QueryContext(ctx context.Context, fn func(txn *Txn) error) error {
+	q, _ := c.begin(ctx, func() error {
+		c.txlock.RLock()
+		return nil
+	})
 
 	// Execute the query and keep the error for later
-	if err := fn(txn); err != nil {
-		txn.rollback()
-		c.txns.release(txn)
+	err := q.prepare(fn(q.txn))
+	derived := q.finish(err)
+	if err != nil {
 		return err
 	}
 
-	// Now that the iteration has finished, we can range over the pending action
-	// queue and apply all of the actions that were requested by the Selector.
-	txn.commit()
-	c.txns.release(txn)
+	propagate(derived)
+	q.done()
 	return nil
 }
 
+// query represents a transaction which is being executed, from the time it begins until
+// it is either committed or rolled back. The transactions spanning several collections
+// run one query per collection.
+type query struct {
+	owner   *Collection        // The collection queried
+	txn     *Txn               // The transaction of the query
+	ctx     context.Context    // The context of the caller
+	span    Span               // The span of the query
+	start   time.Time          // The time at which the query started
+	cancel  context.CancelFunc // The function which cancels the timeout of the query
+	frozen  bool               // Whether the collection was frozen when the query started
+	writing bool               // Whether the query holds a turn of the limited writers
+}
+
+// begin starts a query bound to a context. The lock function acquires the transaction
+// lock of the collection, and the query is not started if it fails.
+func (c *Collection) begin(ctx context.Context, lock func() error) (*query, error) {
+	q := &query{owner: c, start: time.Now()}
+	q.ctx, q.span = c.trace(ctx, SpanQuery)
+	if err := lock(); err != nil {
+		q.span.End(err)
+		return nil, err
+	}
+
+	txn := c.txns.acquire(c)
+	txn.ctx, q.cancel = c.withTimeout(q.ctx)
+	txn.priority = PriorityOf(q.ctx)
+	txn.restrict(q.ctx)
+	q.txn = txn
+
+	// The chunks of a frozen collection never change, so they are read without locking
+	if q.frozen = c.Frozen(); q.frozen {
+		txn.unlocked = true
+	}
+	return q, nil
+}
+
+// prepare checks the changes of the transaction once the function of the query has returned
+// with the specified error. The triggers, hooks and quotas can refuse the changes, and the
+// chunks are locked ahead of the commit so that none of the checks can fail afterwards.
+func (q *query) prepare(err error) error {
+	txn, c := q.txn, q.owner
+	txn.leave()
+	if err == nil {
+		err = txn.failed()
+	}
+	if err == nil && q.frozen && txn.modified() {
+		err = ErrReadOnly
+	}
+	if err == nil {
+		err = txn.runTriggers()
+	}
+	if err == nil {
+		err = txn.checkHooks()
+	}
+	if err == nil {
+		err = txn.checkQuotas()
+	}
+
+	// Wait for the turn of the transaction to commit, if the writers are limited
+	q.writing = err == nil && c.writers != nil && txn.modified()
+	if q.writing && !c.writers.acquire(txn.ctx) {
+		q.writing, err = false, ErrOverloaded
+	}
+
+	// Lock the chunks ahead of the commit if it needs to be checked before any change is
+	// applied, so that a conflict or a lock timeout rolls back the whole transaction
+	if err == nil {
+		err = txn.prepare()
+	}
+
+	// If the transaction deadline was reached but not the caller's one, it timed out
+	if err == context.DeadlineExceeded && q.ctx.Err() == nil {
+		err = ErrTimeout
+	}
+	return err
+}
+
+// finish commits the transaction, or rolls it back if the query failed with the specified
+// error, and releases it along with the transaction lock. It returns the changes to propagate
+// to the derived columns of the other collections, once the commit is complete.
+func (q *query) finish(err error) (derived []derivedChange) {
+	txn, c := q.txn, q.owner
+	defer q.cancel()
+	if err != nil {
+		txn.unlockHeld()
+		txn.rollback()
+		txn.traceQuery(q.span)
+	} else {
+		txn.traceQuery(q.span)
+		txn.commit()
+		derived = txn.derived
+	}
+
+	if q.writing {
+		c.writers.release()
+	}
+
+	c.queries.query(err)
+	slow := c.observeQuery(txn, time.Since(q.start), err)
+	c.txns.release(txn)
+	c.txlock.RUnlock()
+	c.reportSlow(slow)
+	q.span.End(err)
+	return derived
+}
+
+// done evicts the rows beyond the limit of the collection and writes a checkpoint if one
+// is due, once the query is committed.
+func (q *query) done() {
+	q.owner.evict()
+	q.owner.checkpointIfDue()
+}
+
+// QuerySnapshot creates a read-only transaction which runs against an immutable copy of
+// the collection, taken at the time of the call. This allows long-running scans to neither
+// block concurrent writers nor observe their changes midway. The copy is a fork, so only
+// the rows, the primary key and the looked up columns are copied upfront, while the chunks
+// of the other columns are shared until either side touches them. Any changes made in the
+// transaction are discarded.
+func (c *Collection) QuerySnapshot(fn func(txn *Txn) error) error {
+	view, err := c.Fork()
+	if err != nil {
+		return err
+	}
+
+	defer view.Close()
+	txn := view.txns.acquire(view)
+	defer view.txns.release(txn)
+	defer txn.rollback()
+	return fn(txn)
+}
+
 // Close closes the collection and clears up all of the resources.
 func (c *Collection) Close() error {
+	c.dirty.close()
+	c.unfork()
+	if c.history != nil {
+		c.history.base.Close()
+	}
+
+	// Write the pending changes to the backend, before the collection is closed
+	err := c.Flush()
+
+	// Wait for a pending checkpoint to complete
 	c.cancel()
+	if c.checkpoint != nil {
+		c.checkpoint.lock.Lock()
+		defer c.checkpoint.lock.Unlock()
+	}
+
+	if c.opts.Storage != nil {
+		if err := c.opts.Storage.Close(); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Vacuum reclaims the memory held by the values of deleted rows. The chunks are compacted
+// one by one while holding their write lock alone, so the collection remains queryable:
+// the values of the deleted rows are removed from the fill lists of the columns, the
+// strings are released and the encoded numbers are encoded again. The values of the enum
+// dictionaries which are no longer used by any row are removed once every chunk is marked,
+// without remapping the rows. Only if the strings are stored in the arena of the collection
+// and most of it is no longer used, all of the chunks are then locked to relocate it.
+func (c *Collection) Vacuum() {
+	c.txlock.RLock()
+	defer c.txlock.RUnlock()
+	c.compacting.Lock()
+	defer c.compacting.Unlock()
+
+	// Mark the codes of the enum dictionaries used from now on
+	var enums []*column
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(*columnEnum); ok {
+			v.lock.Lock()
+			column.beginSweep()
+			v.lock.Unlock()
+			enums = append(enums, v)
+		}
+	})
+
+	chunks := c.chunks()
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		c.lanes.yield(c.ctx, chunk)
+		c.slock.Lock(uint(chunk))
+		c.compactChunk(chunk)
+		c.slock.Unlock(uint(chunk))
+	}
+
+	// Remove the values of the dictionaries which no row uses anymore
+	for _, v := range enums {
+		v.lock.Lock()
+		v.Column.(*columnEnum).endSweep()
+		v.lock.Unlock()
+	}
+
+	if atomic.LoadInt64(&c.arena.size) > 4*pageSize {
+		c.lockAll()
+		c.compactArena()
+		c.unlockAll()
+	}
+}
+
+// compactChunk releases the values of the deleted rows of a chunk and marks the codes of
+// the enum dictionaries used by its rows. This must be called while the chunk is locked.
+func (c *Collection) compactChunk(chunk commit.Chunk) {
+	c.lock.RLock()
+	fill := append(bitmap.Bitmap(nil), chunk.OfBitmap(c.fill)...)
+	c.lock.RUnlock()
+
+	c.cols.Range(func(v *column) {
+		v.lock.RLock()
+		defer v.lock.RUnlock()
+
+		// Remove the rows which are no longer in the collection from the fill list
+		if !v.IsIndex() {
+			if index := v.Index(); index != nil {
+				values := chunk.OfBitmap(*index)
+				for i := range values {
+					if i < len(fill) {
+						values[i] &= fill[i]
+					} else {
+						values[i] = 0
+					}
+				}
+			}
+		}
+
+		switch column := v.Column.(type) {
+		case compacter:
+			column.compact(chunk)
+		case *columnEnum:
+			column.markChunk(chunk)
+		}
+	})
+}
+
+// Dictionary returns the distinct values stored in the dictionary of an enum column.
+func (c *Collection) Dictionary(columnName string) ([]string, error) {
+	v, ok := c.cols.Load(columnName)
+	if !ok {
+		return nil, errorOf(ErrColumnNotFound, "column: column '%s' does not exist", columnName)
+	}
+
+	column, ok := v.Column.(*columnEnum)
+	if !ok {
+		return nil, errorOf(ErrTypeMismatch, "column: column '%s' is not of type enum", columnName)
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	dictionary := column.Dictionary()
+	if c.opts.Deterministic {
+		sort.Strings(dictionary)
+	}
+	return dictionary, nil
+}
+
+// EnumCode returns the code of a value in the dictionary of an enum column, which can be
+// used with WithEnumCode to find the rows with the value by comparing their codes alone.
+// The codes change when the dictionary is compacted, so they should not be kept across a
+// call to Vacuum or CompactDictionary.
+func (c *Collection) EnumCode(columnName, value string) (uint32, error) {
+	v, ok := c.cols.Load(columnName)
+	if !ok {
+		return 0, errorOf(ErrColumnNotFound, "column: column '%s' does not exist", columnName)
+	}
+
+	column, ok := v.Column.(*columnEnum)
+	if !ok {
+		return 0, errorOf(ErrTypeMismatch, "column: column '%s' is not of type enum", columnName)
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	code, ok := column.codeOf(value)
+	if !ok {
+		return 0, fmt.Errorf("column: value '%s' is not in the dictionary of column '%s'", value, columnName)
+	}
+	return code, nil
+}
+
+// CompactDictionary removes the values which are no longer used by any of the rows from
+// the dictionary of an enum column. This briefly locks all of the chunks.
+func (c *Collection) CompactDictionary(columnName string) error {
+	v, ok := c.cols.Load(columnName)
+	if !ok {
+		return errorOf(ErrColumnNotFound, "column: column '%s' does not exist", columnName)
+	}
+
+	if _, ok := v.Column.(*columnEnum); !ok {
+		return errorOf(ErrTypeMismatch, "column: column '%s' is not of type enum", columnName)
+	}
+
+	c.txlock.RLock()
+	defer c.txlock.RUnlock()
+	c.compactEnums(func(name string) bool {
+		return name == columnName
+	})
 	return nil
 }
 
+// compactEnums rebuilds the dictionaries of the matching enum columns. Since this remaps
+// the rows of every chunk, all of the chunks are locked at once.
+func (c *Collection) compactEnums(match func(columnName string) bool) {
+	c.compacting.Lock()
+	defer c.compacting.Unlock()
+	c.detachAll()
+	c.lockAll()
+	defer c.unlockAll()
+
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(*columnEnum); ok && match(v.name) {
+			v.lock.Lock()
+			column.compact()
+			v.lock.Unlock()
+		}
+	})
+
+	c.compactArena()
+}
+
+// Shrink releases the capacity of the trailing chunks which no longer contain any rows, so
+// that the memory can be reclaimed after large deletes. The capacity of the empty chunks
+// below the last row is kept, since the columns store the values of all chunks contiguously,
+// but the strings, maps and mounted pages they hold are released. It waits for the pending
+// transactions to complete and blocks new ones while the columns are resized.
+func (c *Collection) Shrink() {
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+	c.shrink()
+}
+
+// shrink releases the capacity of the trailing chunks, while the transactions are excluded
+func (c *Collection) shrink() {
+	c.fetchAll()
+	c.detachAll()
+	c.releaseEmpty()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Compute the number of chunks which still contain rows
+	chunks := 0
+	if max, ok := c.fill.Max(); ok {
+		chunks = int(commit.ChunkAt(max)) + 1
+	}
+
+	if len(c.commits) <= chunks {
+		return
+	}
+
+	size := uint32(chunks) << chunkShift
+	c.commits = append(make([]uint64, 0, chunks), c.commits[:chunks]...)
+	if len(c.touched) > chunks {
+		c.touched = append(make([]int64, 0, chunks), c.touched[:chunks]...)
+	}
+	c.fill = shrinkBitmap(c.fill, size)
+	for i := len(c.fill); c.rows != nil && i < len(c.stored); i++ {
+		c.stored[i] = 0
+	}
+
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(shrinker); ok {
+			v.lock.Lock()
+			column.Shrink(size)
+			v.lock.Unlock()
+		}
+	})
+}
+
+// releaseEmpty releases the values held by the empty chunks below the last row, while the
+// transactions are excluded
+func (c *Collection) releaseEmpty() {
+	var empty []commit.Chunk
+	c.lock.RLock()
+	if max, ok := c.fill.Max(); ok {
+		for chunk := commit.Chunk(0); chunk < commit.ChunkAt(max); chunk++ {
+			if !hasValues(chunk, c.fill) {
+				empty = append(empty, chunk)
+			}
+		}
+	}
+	c.lock.RUnlock()
+
+	if len(empty) == 0 {
+		return
+	}
+
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(compacter); ok {
+			v.lock.RLock()
+			for _, chunk := range empty {
+				column.compact(chunk)
+			}
+			v.lock.RUnlock()
+		}
+	})
+}
+
 // vacuum cleans up the expired objects on a specified interval.
 func (c *Collection) vacuum(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -328,19 +1423,59 @@ func (c *Collection) vacuum(ctx context.Context, interval time.Duration) {
 			ticker.Stop()
 			return
 		case <-ticker.C:
-			now := time.Now().UnixNano()
-			c.Query(func(txn *Txn) error {
-				expire := txn.Int64(expireColumn)
-				return txn.With(expireColumn).Range(func(idx uint32) {
-					if expirateAt, ok := expire.Get(); ok && expirateAt != 0 && now >= expirateAt {
-						txn.DeleteAt(idx)
-					}
-				})
-			})
+			if c.Frozen() {
+				continue
+			}
+
+			start := time.Now()
+			expired := c.Expire()
+			if c.opts.AutoShrink {
+				c.Shrink()
+			}
+			if c.opts.SpillAfter > 0 {
+				c.spill(time.Now())
+			}
+
+			c.log(LevelDebug, EventVacuum, fmt.Sprintf("vacuum expired %d rows", expired), nil,
+				FieldRows, expired,
+				FieldDuration, time.Since(start),
+			)
 		}
 	}
 }
 
+// expire removes the rows which have expired at the specified time. If an expiration
+// callback is registered, it is called with the values of each row before its removal.
+func (c *Collection) expire(at time.Time) (expired int) {
+	var indexes []uint32
+	var objects []Object
+
+	now := at.UnixNano()
+	if err := c.Query(func(txn *Txn) error {
+		txn.tombstones = true
+		expire := txn.Int64(expireColumn)
+		return txn.With(expireColumn).Range(func(idx uint32) {
+			if expirateAt, ok := expire.Get(); ok && expirateAt != 0 && now >= expirateAt {
+				if c.opts.OnExpire != nil {
+					indexes = append(indexes, idx)
+					objects = append(objects, txn.objectAt(idx))
+				}
+
+				txn.deleteAt(idx)
+				expired++
+			}
+		})
+	}); err != nil {
+		return 0
+	}
+
+	// Call back with the values of the rows, only once they are removed
+	for i, idx := range indexes {
+		c.opts.OnExpire(idx, objects[i])
+	}
+	return
+}
+
 // --------------------------- column registry ---------------------------
 
 // columns represents a concurrent column registry.
@@ -463,7 +1598,6 @@ func (c *columns) DeleteColumn(columnName string) {
 
 // Delete deletes a column from the registry.
 func (c *columns) DeleteIndex(columnName, indexName string) {
-	index, _ := c.Load(indexName)
 	columns := c.cols.Load().([]columnEntry)
 	for i, v := range columns {
 		if v.name != columnName {
@@ -474,7 +1608,7 @@ func (c *columns) DeleteIndex(columnName, indexName string) {
 		filtered := make([]*column, 0, cap(columns[i].cols))
 		filtered = append(filtered, columns[i].cols[0])
 		for _, idx := range v.cols[1:] {
-			if idx != index {
+			if idx.name != indexName {
 				filtered = append(filtered, idx)
 			}
 		}
@@ -4,9 +4,12 @@
 package column
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"sync"
@@ -15,6 +18,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zeebo/xxh3"
 )
 
 /*
@@ -55,6 +59,26 @@ func BenchmarkCollection(b *testing.B) {
 		}
 	})
 
+	b.Run("insert-batch", func(b *testing.B) {
+		temp := loadPlayers(500)
+		data := loadFixture("players.json")
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			b.StopTimer()
+			temp.Query(func(txn *Txn) error {
+				txn.DeleteAll()
+				return nil
+			})
+			b.StartTimer()
+
+			temp.Query(func(txn *Txn) error {
+				_, err := txn.InsertObjects(data)
+				return err
+			})
+		}
+	})
+
 	b.Run("select-at", func(b *testing.B) {
 		name := ""
 		b.ReportAllocs()
@@ -85,6 +109,17 @@ func BenchmarkCollection(b *testing.B) {
 		}
 	})
 
+	b.Run("scan-range", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			players.Query(func(txn *Txn) error {
+				txn.WithFloatGreater("age", 30).WithFloatBetween("balance", 1000, 2000).Count()
+				return nil
+			})
+		}
+	})
+
 	b.Run("count", func(b *testing.B) {
 		b.ReportAllocs()
 		b.ResetTimer()
@@ -276,6 +311,65 @@ func TestInsertObject(t *testing.T) {
 	}))
 }
 
+func TestTryInsertObject(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("id", ForKey())
+	col.CreateColumn("age", ForInt())
+
+	idx, err := col.TryInsertObject(Object{"id": "A", "age": 35})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), idx)
+
+	// A value of the wrong type is reported and rolled back
+	_, err = col.TryInsertObject(Object{"id": "B", "age": "old"})
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	// A primary key which already exists is reported
+	_, err = col.TryInsertObjectWithTTL(Object{"id": "A", "age": 40}, time.Minute)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.Equal(t, 1, col.Count())
+
+	// A primary key which is pending in the same transaction is reported
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		if _, err := txn.InsertObject(Object{"id": "B"}); err != nil {
+			return err
+		}
+		_, err := txn.InsertObject(Object{"id": "B"})
+		return err
+	}), ErrDuplicateKey)
+	assert.Equal(t, 1, col.Count())
+
+	// A primary key set on a row is checked as well, both against the collection and
+	// against the other rows of the transaction
+	_, err = col.Insert(func(r Row) error {
+		r.SetKey("A")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		for i := 0; i < 2; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetKey("C")
+				return nil
+			})
+		}
+		return nil
+	}), ErrDuplicateKey)
+	assert.Equal(t, 1, col.Count())
+
+	// A primary key released by a rollback can be taken again
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		savepoint := txn.Savepoint()
+		txn.InsertObject(Object{"id": "D"})
+		if err := txn.RollbackTo(savepoint); err != nil {
+			return err
+		}
+		_, err := txn.InsertObject(Object{"id": "D"})
+		return err
+	}))
+	assert.Equal(t, 2, col.Count())
+}
+
 func TestExpire(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -311,6 +405,61 @@ func TestExpire(t *testing.T) {
 	assert.Equal(t, 0, col.Count())
 }
 
+func TestExpireCallback(t *testing.T) {
+	var expired []Object
+	col := NewCollection(Options{
+		OnExpire: func(idx uint32, row Object) {
+			expired = append(expired, row)
+		},
+	})
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	col.InsertObjectWithTTL(Object{"name": "Roman"}, time.Hour)
+	col.InsertObjectWithTTL(Object{"name": "Marcel"}, time.Minute)
+	col.InsertObject(Object{"name": "Merlin"})
+
+	// Nothing has expired yet
+	assert.Equal(t, 0, col.expire(time.Now()))
+	assert.Equal(t, 3, col.Count())
+
+	// Only the second row should expire
+	assert.Equal(t, 1, col.expire(time.Now().Add(2*time.Minute)))
+	assert.Equal(t, 2, col.Count())
+	assert.Equal(t, []Object{{"name": "Marcel"}}, expired)
+}
+
+func TestExpireCallbackIndexes(t *testing.T) {
+	var expired []Object
+	players := loadPlayers(500)
+	players.opts.OnExpire = func(idx uint32, row Object) {
+		expired = append(expired, row)
+	}
+
+	players.InsertObjectWithTTL(Object{
+		"serial": "merlin",
+		"name":   "merlin",
+		"race":   "human",
+		"age":    40.0,
+	}, time.Minute)
+
+	// A sweep which is rolled back does not call back
+	players.BeforeDelete(func(txn *Txn, change Change) error {
+		return fmt.Errorf("not allowed")
+	})
+	assert.Equal(t, 0, players.expire(time.Now().Add(time.Hour)))
+	assert.Empty(t, expired)
+	assert.Equal(t, 501, players.Count())
+
+	// The callback is called once the row is removed, without its indexes
+	players.hooks = [3][]Hook{}
+	assert.Equal(t, 1, players.expire(time.Now().Add(time.Hour)))
+	assert.Len(t, expired, 1)
+	assert.Equal(t, "merlin", expired[0]["name"])
+	assert.NotContains(t, expired[0], "old")
+	assert.NotContains(t, expired[0], "human")
+}
+
 func TestCreateIndex(t *testing.T) {
 	row := Object{
 		"age": 35,
@@ -335,6 +484,193 @@ func TestCreateIndex(t *testing.T) {
 	})
 }
 
+func TestCreateIndexDeferred(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	// Fill a few chunks before creating the index
+	col.Query(func(txn *Txn) error {
+		for i := 0; i < 3*chunkSize; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetInt("age", (i%2)*100)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	_, err := col.CreateIndexDeferred("young", "invalid", func(r Reader) bool { return true })
+	assert.Error(t, err)
+	_, err = col.CreateIndexDeferred("", "age", nil)
+	assert.Error(t, err)
+
+	// Keep writing while the index is built in the background
+	ready, err := col.CreateIndexDeferred("young", "age", func(r Reader) bool {
+		return r.Int() < 50
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		col.Insert(func(r Row) error {
+			r.SetInt("age", 10)
+			return nil
+		})
+	}
+
+	<-ready
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 3*chunkSize/2+100, txn.With("young").Count())
+		return nil
+	})
+}
+
+func TestCreatePartialIndex(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("active", ForBool())
+	col.CreateColumn("balance", ForFloat64())
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	for i := 0; i < 100; i++ {
+		col.Insert(func(r Row) error {
+			r.SetBool("active", i%2 == 0)
+			r.SetFloat64("balance", float64(i)*1e5)
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	rich := func(r Reader) bool {
+		return r.Float() > 1e6
+	}
+
+	assert.Error(t, col.CreatePartialIndex("rich", "balance", "missing", rich))
+	assert.Error(t, col.CreatePartialIndex("rich", "balance", "", rich))
+	assert.NoError(t, col.CreatePartialIndex("rich", "balance", "active", rich))
+	assert.NoError(t, col.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 90
+	}))
+	assert.NoError(t, col.CreatePartialIndex("rich_old", "balance", "old", rich))
+
+	count := func(index string) (n int) {
+		col.Query(func(txn *Txn) error {
+			n = txn.With(index).Count()
+			return nil
+		})
+		return
+	}
+
+	// Only the active rows with balance over 1e6 are indexed
+	assert.Equal(t, 44, count("rich"))
+	assert.Equal(t, 10, count("rich_old"))
+
+	// Rows entering or leaving the scope are evaluated again
+	col.QueryAt(11, func(r Row) error {
+		r.SetBool("active", true)
+		return nil
+	})
+	assert.Equal(t, 45, count("rich"))
+	col.QueryAt(12, func(r Row) error {
+		r.SetBool("active", false)
+		return nil
+	})
+	col.QueryAt(10, func(r Row) error {
+		r.SetBool("active", false)
+		r.SetFloat64("balance", 2e6)
+		return nil
+	})
+	assert.Equal(t, 44, count("rich"))
+
+	col.QueryAt(50, func(r Row) error {
+		r.SetInt("age", 95)
+		return nil
+	})
+	assert.Equal(t, 11, count("rich_old"))
+	col.QueryAt(99, func(r Row) error {
+		r.SetInt("age", 5)
+		return nil
+	})
+	assert.Equal(t, 10, count("rich_old"))
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.With("rich_old").WithFloat("age", func(v float64) bool {
+			return v == 95
+		}).Count())
+		return nil
+	})
+
+	// Dropping the index removes it from the scope as well
+	assert.NoError(t, col.DropIndex("rich"))
+	col.QueryAt(12, func(r Row) error {
+		r.SetBool("active", true)
+		return nil
+	})
+	assert.Equal(t, 0, count("rich"))
+}
+
+func TestCreateExpressionIndex(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("hp", ForFloat64())
+	col.CreateColumn("mp", ForFloat64())
+	defer col.Close()
+
+	for i := 0; i < 100; i++ {
+		col.Insert(func(r Row) error {
+			r.SetFloat64("hp", float64(i))
+			r.SetFloat64("mp", 10)
+			return nil
+		})
+	}
+
+	tank := func(r Row) bool {
+		hp, _ := r.Float64("hp")
+		mp, _ := r.Float64("mp")
+		return mp > 0 && hp/mp > 5
+	}
+
+	assert.Error(t, col.CreateExpressionIndex("tank", nil, tank))
+	assert.Error(t, col.CreateExpressionIndex("tank", []string{"hp", "missing"}, tank))
+	assert.NoError(t, col.CreateExpressionIndex("tank", []string{"hp", "mp"}, tank))
+
+	count := func() (n int) {
+		col.Query(func(txn *Txn) error {
+			n = txn.With("tank").Count()
+			return nil
+		})
+		return
+	}
+
+	// Updating either of the columns evaluates the rule again
+	assert.Equal(t, 49, count())
+	col.QueryAt(10, func(r Row) error {
+		r.SetFloat64("mp", 1)
+		return nil
+	})
+	assert.Equal(t, 50, count())
+	col.QueryAt(99, func(r Row) error {
+		r.SetFloat64("hp", 1)
+		return nil
+	})
+	assert.Equal(t, 49, count())
+
+	// Inserted and deleted rows are indexed as well
+	col.Insert(func(r Row) error {
+		r.SetFloat64("hp", 100)
+		r.SetFloat64("mp", 1)
+		return nil
+	})
+	assert.Equal(t, 50, count())
+	assert.True(t, col.DeleteAt(98))
+	assert.Equal(t, 49, count())
+
+	// Dropping the index detaches it from all of the columns
+	assert.NoError(t, col.DropIndex("tank"))
+	col.QueryAt(0, func(r Row) error {
+		r.SetFloat64("hp", 1000)
+		return nil
+	})
+	assert.Equal(t, 0, count())
+}
+
 func TestCreateIndexInvalidColumn(t *testing.T) {
 	col := NewCollection()
 	defer col.Close()
@@ -377,6 +713,130 @@ func TestDropColumnNotIndex(t *testing.T) {
 	assert.Error(t, col.DropIndex("age"))
 }
 
+func TestBloomIndex(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+	col.CreateColumn("serial", ForString())
+	col.CreateColumn("age", ForInt())
+	assert.Error(t, col.CreateBloomIndex("age_bloom", "age"))
+	assert.Error(t, col.CreateBloomIndex("serial_bloom", "missing"))
+
+	// Fill a few chunks before creating the index, so that it is built from the values
+	col.Query(func(txn *Txn) error {
+		serial := txn.String("serial")
+		for i := 0; i < 3*chunkSize; i++ {
+			txn.Insert(func(r Row) error {
+				serial.Set(fmt.Sprintf("s-%d", i))
+				return nil
+			})
+		}
+		return nil
+	})
+
+	assert.NoError(t, col.CreateBloomIndex("serial_bloom", "serial"))
+	assert.Equal(t, 3, col.cols.Count())
+
+	// Add a value once the index was created
+	col.Insert(func(r Row) error {
+		r.SetString("serial", "added")
+		return nil
+	})
+
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithStringEqual("serial", "s-20000").Count())
+		return nil
+	})
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithStringEqual("serial", "added").Count())
+		return nil
+	})
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithStringEqual("serial", "missing").Count())
+		assert.Equal(t, 0, txn.WithStringEqual("age", "missing").Count())
+		return nil
+	})
+
+	// Only the chunk containing the value should be scanned
+	col.Query(func(txn *Txn) error {
+		plan := txn.Explain()
+		assert.Equal(t, 1, txn.WithStringEqual("serial", "s-20000").Count())
+		assert.True(t, plan.Steps[0].Indexed)
+
+		filters := columnOf(col, "serial_bloom").(*columnBloom).chunks
+		assert.True(t, bloomContains(filters, 1, xxh3.HashString("s-20000")))
+		assert.False(t, bloomContains(filters, 0, xxh3.HashString("s-20000")) &&
+			bloomContains(filters, 2, xxh3.HashString("s-20000")))
+		return nil
+	})
+
+	assert.NoError(t, col.DropIndex("serial_bloom"))
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithStringEqual("serial", "s-20000").Count())
+		return nil
+	})
+}
+
+func TestLookup(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	assert.Error(t, players.CreateLookup("by_active", "active"))
+	assert.Error(t, players.CreateLookup("by_missing", "missing"))
+	assert.NoError(t, players.CreateLookup("by_name", "name"))
+	assert.NoError(t, players.CreateLookup("by_age", "age"))
+
+	seek := func(lookup string, value interface{}) (n int) {
+		players.Query(func(txn *Txn) error {
+			n = txn.SeekAll(lookup, value).Count()
+			return nil
+		})
+		return
+	}
+
+	// The lookup finds the same rows as a scan
+	assert.NotZero(t, seek("by_age", 21))
+	assert.Equal(t, 1, seek("by_name", "Maura Daugherty"))
+	assert.Equal(t, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithValue("name", func(v interface{}) bool { return v == "Maura Daugherty" })
+	}), seek("by_name", "Maura Daugherty"))
+	assert.Equal(t, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithFloatBetween("age", 21, 21)
+	}), seek("by_age", 21))
+
+	// The lookup is updated along with the column
+	players.QueryAt(0, func(r Row) error {
+		r.SetEnum("name", "Aragorn")
+		r.AddFloat64("age", 1000)
+		return nil
+	})
+	players.DeleteAt(1)
+	players.QueryAt(1, func(r Row) error {
+		r.SetEnum("name", "Aragorn")
+		return nil
+	})
+	assert.Equal(t, 1, seek("by_name", "Aragorn"))
+	assert.Equal(t, 1, seek("by_age", 1026))
+
+	// It narrows down the selection along with the filters
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.Without("active").With("active").SeekAll("by_name", "Aragorn").Count())
+		return nil
+	})
+
+	// Invalid lookups abort the transaction
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.SeekAll("missing", "Aragorn")
+		return nil
+	}))
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.SeekAll("name", "Aragorn")
+		return nil
+	}))
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.SeekAll("by_age", "old")
+		return nil
+	}))
+}
+
 func TestDropOneOfMultipleIndices(t *testing.T) {
 	col := NewCollection()
 	col.CreateColumn("age", ForInt())
@@ -482,6 +942,45 @@ func TestInsert(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestInsertAt(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	for _, idx := range []uint32{5, 40000, 2} {
+		assert.NoError(t, c.InsertAt(idx, func(r Row) error {
+			r.SetString("name", "Roman")
+			return nil
+		}))
+	}
+
+	// Offsets which are taken or rolled back are not inserted
+	assert.Error(t, c.InsertAt(5, func(r Row) error { return nil }))
+	assert.Error(t, c.InsertAt(6, func(r Row) error {
+		return fmt.Errorf("failed")
+	}))
+	assert.Equal(t, 3, c.Count())
+
+	// The snapshot preserves the offsets, as well as the replication
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, c.Snapshot(buffer))
+	restored := NewCollection()
+	restored.CreateColumn("name", ForString())
+	assert.NoError(t, restored.Restore(buffer))
+	for _, col := range []*Collection{c, restored} {
+		var rows []uint32
+		col.Query(func(txn *Txn) error {
+			return txn.Range(func(idx uint32) {
+				rows = append(rows, idx)
+			})
+		})
+		assert.Equal(t, []uint32{2, 5, 40000}, rows)
+	}
+
+	// New rows fill the gaps between the explicit offsets
+	idx, err := restored.Insert(func(r Row) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), idx)
+}
+
 func TestInsertWithTTL(t *testing.T) {
 	c := NewCollection()
 	c.CreateColumn("name", ForString())
@@ -534,15 +1033,20 @@ func TestFindFreeIndex(t *testing.T) {
 
 // --------------------------- Mocks & Fixtures ----------------------------
 
-// loadPlayers loads a list of players from the fixture
-func loadPlayers(amount int) *Collection {
-	out := newEmpty(amount)
+// loadPlayers loads a list of players from the fixture, into a collection created with
+// the options specified on top of the ones of the fixture
+func loadPlayers(amount int, opts ...Options) *Collection {
+	out := newEmpty(amount, opts...)
 
-	// Load and copy until we reach the amount required
+	// Load and copy until we reach the amount required, the copies with their own keys
 	data := loadFixture("players.json")
 	for i := 0; i < amount/len(data); i++ {
 		out.Query(func(txn *Txn) error {
 			for _, p := range data {
+				if i > 0 {
+					p = copyOf(p)
+					p["serial"] = fmt.Sprintf("%v-%d", p["serial"], i)
+				}
 				txn.InsertObject(p)
 			}
 			return nil
@@ -551,13 +1055,22 @@ func loadPlayers(amount int) *Collection {
 	return out
 }
 
+// copyOf returns a shallow copy of an object
+func copyOf(object Object) Object {
+	out := make(Object, len(object))
+	for k, v := range object {
+		out[k] = v
+	}
+	return out
+}
+
 // newEmpty creates a new empty collection for a the fixture
-func newEmpty(capacity int) *Collection {
-	out := NewCollection(Options{
+func newEmpty(capacity int, opts ...Options) *Collection {
+	out := NewCollection(append([]Options{{
 		Capacity: capacity,
 		Vacuum:   500 * time.Millisecond,
 		Writer:   new(noopWriter),
-	})
+	}}, opts...)...)
 
 	// Load the items into the collection
 	out.CreateColumn("serial", ForKey())
@@ -620,3 +1133,1146 @@ func loadFixture(name string) []Object {
 
 	return data
 }
+
+func TestQuerySnapshot(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.QuerySnapshot(func(txn *Txn) error {
+
+		// Concurrent changes should not be observed by the snapshot
+		go players.Query(func(txn *Txn) error {
+			txn.DeleteAll()
+			return nil
+		})
+
+		assert.Equal(t, 500, txn.Count())
+		txn.Range(func(idx uint32) {
+			txn.Float64("balance").Set(0)
+		})
+		return nil
+	}))
+
+	// Wait for the concurrent delete to take place
+	for players.Count() > 0 {
+		runtime.Gosched()
+	}
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.Count())
+		return nil
+	})
+}
+
+func TestQuerySnapshotIndexes(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.QuerySnapshot(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.Count())
+		assert.Equal(t, 21, txn.With("human", "mage", "old").Count())
+		return nil
+	}))
+
+	assert.NoError(t, players.QuerySnapshot(func(txn *Txn) error {
+		return txn.QueryKey("18771f05-a3be-49b1-9567-f8f2b891d5ff", func(r Row) error {
+			name, _ := r.Enum("name")
+			assert.Equal(t, "Maura Daugherty", name)
+			return nil
+		})
+	}))
+}
+
+func TestQuerySnapshotShared(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.QuerySnapshot(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		return txn.Range(func(idx uint32) {
+			balance.Set(0)
+		})
+	}))
+
+	// The chunks shared with the snapshot are left intact, and released once it completes
+	assert.Empty(t, players.forks.all())
+	players.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		return txn.Range(func(idx uint32) {
+			value, _ := balance.Get()
+			assert.NotZero(t, value)
+		})
+	})
+}
+
+func TestQueryChunk(t *testing.T) {
+	players := loadPlayers(20000)
+	assert.Equal(t, 2, players.Chunks())
+	counts := make([]int, 0, 2)
+	for chunk := 0; chunk < players.Chunks(); chunk++ {
+		assert.NoError(t, players.QueryChunk(chunk, func(txn *Txn) error {
+			counts = append(counts, txn.Count())
+			return nil
+		}))
+	}
+
+	assert.Equal(t, players.Count(), counts[0]+counts[1])
+	assert.Equal(t, chunkSize, counts[0])
+}
+
+func TestFork(t *testing.T) {
+	players := loadPlayers(500)
+	fork, err := players.Fork()
+	assert.NoError(t, err)
+	defer fork.Close()
+
+	// Changes made to the fork are isolated from the parent
+	assert.NoError(t, fork.Query(func(txn *Txn) error {
+		txn.With("human").DeleteAll()
+		return nil
+	}))
+
+	assert.Equal(t, 500, players.Count())
+	assert.Equal(t, 500-138, fork.Count())
+
+	// Changes made to the parent are isolated from the fork
+	players.InsertObject(Object{"name": "Roman", "race": "human"})
+	assert.Equal(t, 501, players.Count())
+	assert.Equal(t, 500-138, fork.Count())
+	assert.NoError(t, fork.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("human").Count())
+		return nil
+	}))
+}
+
+func TestForkCopyOnWrite(t *testing.T) {
+	players := loadPlayers(500)
+	fork, err := players.Fork()
+	assert.NoError(t, err)
+	defer fork.Close()
+
+	// The chunks are shared until they are accessed
+	origin := fork.originOf()
+	assert.NotNil(t, origin)
+	assert.Contains(t, origin.columns, "balance")
+	assert.NotContains(t, origin.columns, "serial")
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&origin.pending[0]))
+
+	// A write to the fork copies the chunk and leaves the parent unchanged
+	assert.NoError(t, fork.QueryAt(0, func(r Row) error {
+		r.SetFloat64("balance", -1)
+		return nil
+	}))
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&origin.pending[0]))
+
+	var before, after float64
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		before, _ = r.Float64("balance")
+		return nil
+	}))
+	assert.NoError(t, fork.QueryAt(0, func(r Row) error {
+		after, _ = r.Float64("balance")
+		return nil
+	}))
+	assert.NotEqual(t, -1.0, before)
+	assert.Equal(t, -1.0, after)
+
+	// A write to the parent copies the chunk into the fork first
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			txn.Float64("balance").Set(1000)
+		})
+	}))
+
+	assert.NoError(t, fork.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithValue("balance", func(v interface{}) bool {
+			return v.(float64) == 1000
+		}).Count())
+		return nil
+	}))
+
+	// Both collections keep their own values once they are closed
+	assert.NoError(t, players.Close())
+	assert.NoError(t, fork.QueryAt(0, func(r Row) error {
+		after, _ = r.Float64("balance")
+		return nil
+	}))
+	assert.Equal(t, -1.0, after)
+}
+
+func TestIndexOfAndColumns(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("key", ForKey())
+	col.CreateColumn("name", ForString())
+	col.CreateIndex("short", "name", func(r Reader) bool {
+		return len(r.String()) < 4
+	})
+	defer col.Close()
+
+	idx := col.InsertObject(Object{"key": "a", "name": "Roman"})
+	at, ok := col.IndexOf("a")
+	assert.True(t, ok)
+	assert.Equal(t, idx, at)
+
+	// A missing key is not inserted
+	_, ok = col.IndexOf("b")
+	assert.False(t, ok)
+	assert.Equal(t, 1, col.Count())
+	assert.Equal(t, []string{"key", "name"}, col.Columns())
+
+	_, ok = NewCollection().IndexOf("a")
+	assert.False(t, ok)
+}
+
+func TestQueryContext(t *testing.T) {
+	players := loadPlayers(500)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, players.QueryContext(ctx, func(txn *Txn) error {
+		assert.Equal(t, ctx, txn.Context())
+		assert.Equal(t, 0, txn.WithFloat("age", func(v float64) bool {
+			return v >= 30
+		}).Count())
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}))
+
+	assert.Equal(t, 500, players.Count())
+	assert.NoError(t, players.QueryContext(context.Background(), func(txn *Txn) error {
+		assert.Equal(t, 245, txn.Without("old").Count())
+		return nil
+	}))
+}
+
+func TestQueryTimeout(t *testing.T) {
+	players := newEmpty(500)
+	players.opts.Timeout = time.Millisecond
+	players.Insert(func(r Row) error {
+		return nil
+	})
+
+	assert.Equal(t, ErrTimeout, players.Query(func(txn *Txn) error {
+		time.Sleep(5 * time.Millisecond)
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}))
+	assert.Equal(t, 1, players.Count())
+}
+
+func TestQueryLockTimeout(t *testing.T) {
+	players := NewCollection(Options{
+		LockTimeout: time.Millisecond,
+	})
+	players.Insert(func(r Row) error {
+		return nil
+	})
+
+	// Hold the write lock on the first chunk
+	players.slock.Lock(0)
+	assert.Equal(t, ErrLockTimeout, players.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {})
+	}))
+	assert.Equal(t, ErrLockTimeout, players.QueryAt(0, func(r Row) error {
+		return nil
+	}))
+	players.slock.Unlock(0)
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {})
+	}))
+}
+
+func TestQueryLockTimeoutWrite(t *testing.T) {
+	players := NewCollection(Options{
+		LockTimeout: time.Millisecond,
+	})
+	players.CreateColumn("name", ForString())
+	players.Insert(func(r Row) error {
+		r.SetString("name", "Roman")
+		return nil
+	})
+
+	// Hold the read lock on the first chunk, the write is rolled back as a whole
+	players.slock.RLock(0)
+	assert.Equal(t, ErrLockTimeout, players.QueryAt(0, func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	}))
+	assert.Equal(t, ErrLockTimeout, players.Query(func(txn *Txn) error {
+		txn.Insert(func(r Row) error { return nil })
+		return nil
+	}))
+	players.slock.RUnlock(0)
+	assert.Equal(t, 1, players.Count())
+
+	// No lock is left held once timed out
+	assert.True(t, players.slock.TryLock(0))
+	players.slock.Unlock(0)
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Roman", name)
+		r.SetString("name", "Merlin")
+		return nil
+	}))
+}
+
+func TestQueryAsOf(t *testing.T) {
+	players := NewCollection(Options{
+		Retention: 50 * time.Millisecond,
+	})
+	players.CreateColumn("name", ForString())
+	players.CreateColumn("balance", ForFloat64())
+	players.Insert(func(r Row) error {
+		r.SetString("name", "Roman")
+		r.SetFloat64("balance", 100)
+		return nil
+	})
+
+	before := time.Now()
+	players.CreateColumn("age", ForInt())
+	players.QueryAt(0, func(r Row) error {
+		r.SetFloat64("balance", 200)
+		r.SetInt("age", 35)
+		return nil
+	})
+
+	// Query the state before the update
+	assert.NoError(t, players.QueryAsOf(before, func(txn *Txn) error {
+		assert.Equal(t, 1, txn.Count())
+		return txn.QueryAt(0, func(r Row) error {
+			balance, _ := r.Float64("balance")
+			_, hasAge := r.Int("age")
+			assert.Equal(t, 100.0, balance)
+			assert.False(t, hasAge)
+			return nil
+		})
+	}))
+
+	// Query the current state
+	assert.NoError(t, players.QueryAsOf(time.Now(), func(txn *Txn) error {
+		return txn.QueryAt(0, func(r Row) error {
+			balance, _ := r.Float64("balance")
+			assert.Equal(t, 200.0, balance)
+			return nil
+		})
+	}))
+
+	// Expire the history by committing past the retention window
+	time.Sleep(60 * time.Millisecond)
+	players.QueryAt(0, func(r Row) error {
+		r.SetFloat64("balance", 300)
+		return nil
+	})
+
+	assert.Error(t, players.QueryAsOf(before, func(txn *Txn) error {
+		return nil
+	}))
+	assert.NoError(t, players.QueryAsOf(time.Now().Add(-time.Millisecond), func(txn *Txn) error {
+		return txn.QueryAt(0, func(r Row) error {
+			balance, _ := r.Float64("balance")
+			age, _ := r.Int("age")
+			assert.Equal(t, 200.0, balance)
+			assert.Equal(t, 35, age)
+			return nil
+		})
+	}))
+}
+
+func TestQueryAsOfNoHistory(t *testing.T) {
+	players := NewCollection()
+	assert.Error(t, players.QueryAsOf(time.Now(), func(txn *Txn) error {
+		return nil
+	}))
+}
+
+func TestRowHistory(t *testing.T) {
+	players := NewCollection(Options{
+		Retention: time.Minute,
+	})
+	players.CreateColumn("name", ForString())
+	players.CreateColumn("balance", ForFloat64())
+	for i := 0; i < 2; i++ {
+		players.Insert(func(r Row) error {
+			r.SetString("name", "Roman")
+			r.SetFloat64("balance", 100)
+			return nil
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		players.QueryAt(0, func(r Row) error {
+			r.AddFloat64("balance", 50)
+			return nil
+		})
+	}
+	players.QueryAt(0, func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+
+	// The latest values of the column, the most recent first
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		history, err := r.History(2, "balance")
+		assert.NoError(t, err)
+		assert.Len(t, history, 2)
+		assert.Equal(t, map[string]interface{}{"balance": 250.0}, history[0].Values)
+		assert.Equal(t, map[string]interface{}{"balance": 200.0}, history[1].Values)
+		assert.False(t, history[0].Time.Before(history[1].Time))
+
+		history, err = r.History(0)
+		assert.NoError(t, err)
+		assert.Len(t, history, 5)
+		assert.Equal(t, map[string]interface{}{"name": "Merlin"}, history[0].Values)
+		assert.Equal(t, map[string]interface{}{"name": "Roman", "balance": 100.0}, history[4].Values)
+		return nil
+	}))
+
+	// A row inserted in place of a deleted one does not inherit its history
+	players.DeleteAt(0)
+	players.Insert(func(r Row) error {
+		r.SetFloat64("balance", 10)
+		return nil
+	})
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		history, err := r.History(0)
+		assert.NoError(t, err)
+		assert.Len(t, history, 1)
+		return nil
+	}))
+
+	// The history is only available if retained
+	assert.NoError(t, loadPlayers(10).QueryAt(0, func(r Row) error {
+		_, err := r.History(1)
+		assert.Error(t, err)
+		return nil
+	}))
+}
+
+func TestSoftDelete(t *testing.T) {
+	players := NewCollection(Options{SoftDelete: true})
+	players.CreateColumn("name", ForString())
+	for i := 0; i < 10; i++ {
+		players.Insert(func(r Row) error {
+			r.SetString("name", fmt.Sprintf("player %d", i))
+			return nil
+		})
+	}
+
+	// Deleted rows are hidden, but not removed
+	assert.True(t, players.DeleteAt(1))
+	assert.True(t, players.DeleteAt(2))
+	assert.False(t, players.DeleteAt(2))
+	assert.Equal(t, 8, players.Count())
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 8, txn.Count())
+		assert.False(t, txn.Undelete(3))
+		assert.True(t, txn.Undelete(2))
+		return nil
+	}))
+
+	// Recovered rows are visible again
+	assert.Equal(t, 9, players.Count())
+	assert.Equal(t, 0, players.Purge(time.Hour))
+	assert.Equal(t, 1, players.Purge(0))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.False(t, txn.Undelete(1))
+		assert.Equal(t, 9, txn.Count())
+		return nil
+	}))
+}
+
+func TestSoftDeleteAll(t *testing.T) {
+	players := NewCollection(Options{SoftDelete: true})
+	players.CreateColumn("name", ForString())
+	for i := 0; i < 10; i++ {
+		players.Insert(func(r Row) error {
+			r.SetString("name", fmt.Sprintf("player %d", i))
+			return nil
+		})
+	}
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 10, txn.DeleteAll())
+		return nil
+	}))
+
+	assert.Equal(t, 0, players.Count())
+	assert.Equal(t, 10, players.Purge(0))
+	assert.Equal(t, 0, players.Purge(0))
+}
+
+func TestSoftDeleteCount(t *testing.T) {
+	players := NewCollection(Options{SoftDelete: true})
+	players.CreateColumn("age", ForInt())
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		for i := 0; i < 3*chunkSize; i++ {
+			txn.InsertObject(Object{"age": i})
+		}
+		return nil
+	}))
+
+	// The soft-deleted rows of every chunk are counted once committed
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		for i := 0; i < 3*chunkSize; i += 3 {
+			txn.DeleteAt(uint32(i))
+		}
+		return nil
+	}))
+	assert.Equal(t, 2*chunkSize, players.Count())
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.True(t, txn.Undelete(0))
+		assert.True(t, txn.Undelete(3))
+		return nil
+	}))
+	assert.Equal(t, 2*chunkSize+2, players.Count())
+	assert.Equal(t, chunkSize-2, players.Purge(0))
+	assert.Equal(t, 2*chunkSize+2, players.Count())
+
+	// The count is restored along with the soft-deleted rows
+	assert.True(t, players.DeleteAt(1))
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, players.Snapshot(buffer))
+	restored := NewCollection(Options{SoftDelete: true})
+	restored.CreateColumn("age", ForInt())
+	assert.NoError(t, restored.Restore(buffer))
+	assert.Equal(t, 2*chunkSize+1, restored.Count())
+	assert.NoError(t, restored.Query(func(txn *Txn) error {
+		assert.Equal(t, 2*chunkSize+1, txn.Count())
+		return nil
+	}))
+}
+
+func TestSoftDeleteKey(t *testing.T) {
+	players := NewCollection(Options{SoftDelete: true})
+	players.CreateColumn("serial", ForKey())
+	players.CreateColumn("name", ForString())
+	for i := 0; i < 10; i++ {
+		players.InsertObject(Object{"serial": fmt.Sprint(i), "name": fmt.Sprintf("player %d", i)})
+	}
+
+	// The deleted row can not be found nor read
+	idx, ok := players.IndexOf("1")
+	assert.True(t, ok)
+	assert.True(t, players.DeleteAt(idx))
+	_, ok = players.IndexOf("1")
+	assert.False(t, ok)
+	assert.Equal(t, ErrDeleted, players.QueryAt(idx, func(r Row) error {
+		r.SetString("name", "ghost")
+		return nil
+	}))
+
+	// The copies hide the deleted row as well
+	fork, err := players.Fork()
+	assert.NoError(t, err)
+	assert.Equal(t, 9, fork.Count())
+	assert.NoError(t, players.QuerySnapshot(func(txn *Txn) error {
+		assert.Equal(t, 9, txn.Count())
+		return nil
+	}))
+
+	// An upsert of the key recovers the row
+	assert.NoError(t, players.QueryKey("1", func(r Row) error {
+		r.SetString("name", "Roman")
+		return nil
+	}))
+	assert.Equal(t, 10, players.Count())
+	assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Roman", name)
+		return nil
+	}))
+}
+
+func TestVacuum(t *testing.T) {
+	players := loadPlayers(500)
+	players.CreateColumn("note", ForString())
+	players.Query(func(txn *Txn) error {
+		note := txn.String("note")
+		return txn.Range(func(idx uint32) {
+			note.Set(fmt.Sprintf("note %d", idx))
+		})
+	})
+
+	// Delete everyone except the mages
+	players.Query(func(txn *Txn) error {
+		txn.WithValue("class", func(v interface{}) bool {
+			return v != "mage"
+		}).DeleteAll()
+		return nil
+	})
+
+	expect := make(map[uint32]string)
+	players.Query(func(txn *Txn) error {
+		name := txn.Enum("name")
+		return txn.Range(func(idx uint32) {
+			expect[idx], _ = name.Get()
+		})
+	})
+
+	names, _ := players.cols.Load("name")
+	before := len(names.Column.(*columnEnum).Dictionary())
+	players.Vacuum()
+
+	// The dictionary should only contain the remaining names
+	assert.Less(t, len(names.Column.(*columnEnum).Dictionary()), before)
+	assert.Equal(t, 151, players.Count())
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 151, txn.With("mage").Count())
+		assert.Equal(t, 151, txn.WithString("class", func(v string) bool {
+			return v == "mage"
+		}).Count())
+
+		name := txn.Enum("name")
+		note := txn.String("note")
+		return txn.Range(func(idx uint32) {
+			v, ok := name.Get()
+			assert.True(t, ok)
+			assert.Equal(t, expect[idx], v)
+			s, _ := note.Get()
+			assert.Equal(t, fmt.Sprintf("note %d", idx), s)
+		})
+	}))
+
+	// Deleted strings are released
+	notes, _ := players.cols.Load("note")
+	for idx, v := range notes.Column.(*columnString).data {
+		if !notes.Contains(uint32(idx)) {
+			assert.Empty(t, v)
+		}
+	}
+
+	// The codes of the removed names are reused, without changing the remaining rows
+	size := len(names.Column.(*columnEnum).data)
+	idx := players.InsertObject(Object{"name": "Roman"})
+	assert.Equal(t, size, len(names.Column.(*columnEnum).data))
+	assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+		v, _ := r.Enum("name")
+		assert.Equal(t, "Roman", v)
+		return nil
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		name := txn.Enum("name")
+		return txn.Range(func(idx uint32) {
+			if v, ok := expect[idx]; ok {
+				got, _ := name.Get()
+				assert.Equal(t, v, got)
+			}
+		})
+	}))
+}
+
+func TestVacuumChunks(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("name", ForEnum())
+	players.CreateColumn("score", ForInt64(WithEncoding(RLE)))
+	defer players.Close()
+
+	for i := 0; i < 2000; i++ {
+		players.InsertObject(Object{"name": fmt.Sprint("player ", i), "score": int64(i + 1)})
+	}
+
+	players.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			if idx >= 1024 {
+				txn.DeleteAt(idx)
+			}
+		})
+	})
+
+	// A chunk which is not part of the collection can be held while vacuuming
+	players.slock.RLock(100)
+	done := make(chan struct{})
+	go func() {
+		players.Vacuum()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "vacuum waited for an unrelated chunk")
+	}
+	players.slock.RUnlock(100)
+	<-done
+
+	// The block of the deleted scores is released, the others are kept
+	scores, _ := players.cols.Load("score")
+	blocks := scores.Column.(*int64Column).enc.blocks
+	assert.NotNil(t, blocks[0])
+	assert.Nil(t, blocks[1])
+	dictionary, err := players.Dictionary("name")
+	assert.NoError(t, err)
+	assert.Equal(t, 1024, len(dictionary))
+	assert.Equal(t, 1024, players.Count())
+}
+
+func TestShrink(t *testing.T) {
+	players := NewCollection(Options{Capacity: 100})
+	players.CreateColumn("name", ForEnum())
+	players.CreateColumn("balance", ForFloat64())
+	players.CreateColumn("active", ForBool())
+	players.CreateIndex("rich", "balance", func(r Reader) bool {
+		return r.Float() > 100
+	})
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		for i := 0; i < 3*chunkSize; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetEnum("name", "Roman")
+				r.SetFloat64("balance", float64(i))
+				r.SetBool("active", true)
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	// Delete everything beyond the first 10 rows
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.WithUint("balance", func(v uint64) bool {
+			return v >= 10
+		}).DeleteAll()
+		return nil
+	}))
+
+	balance, _ := players.cols.Load("balance")
+	assert.Equal(t, 3, len(players.commits))
+	assert.Equal(t, 3*chunkSize, len(balance.Column.(*float64Column).data))
+
+	// Shrink and make sure the data is still there
+	players.Shrink()
+	assert.Equal(t, 1, len(players.commits))
+	assert.Equal(t, chunkSize, cap(balance.Column.(*float64Column).data))
+	assert.Equal(t, 10, players.Count())
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 10, txn.With("active").Count())
+		assert.Equal(t, 0, txn.With("rich").Count())
+		return nil
+	}))
+
+	// We should be able to grow again
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		for i := 0; i < 2*chunkSize; i++ {
+			txn.InsertObject(Object{"balance": 1000.0})
+		}
+		return nil
+	}))
+
+	assert.Equal(t, 3, len(players.commits))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 2*chunkSize, txn.With("rich").Count())
+		return nil
+	}))
+
+	// Shrinking everything releases all of the chunks
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.DeleteAll()
+		return nil
+	}))
+
+	players.Shrink()
+	assert.Equal(t, 0, len(players.commits))
+	assert.Equal(t, 0, players.Count())
+}
+
+func TestShrinkEmptyChunks(t *testing.T) {
+	players := NewCollection(Options{Capacity: 100})
+	players.CreateColumn("name", ForString())
+	players.CreateColumn("balance", ForFloat64())
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		for i := 0; i < 3*chunkSize; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetString("name", fmt.Sprintf("player %d", i))
+				r.SetFloat64("balance", float64(i))
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	// Empty the chunk in the middle, while the last one still contains rows
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.WithFloat("balance", func(v float64) bool {
+			return v >= chunkSize && v < 2*chunkSize
+		}).DeleteAll()
+		return nil
+	}))
+
+	// The capacity of the empty chunk is kept, but its strings are released
+	players.Shrink()
+	name, _ := players.cols.Load("name")
+	balance, _ := players.cols.Load("balance")
+	assert.Equal(t, 3, len(players.commits))
+	assert.Equal(t, 3*chunkSize, len(balance.Column.(*float64Column).data))
+	assert.Equal(t, "", name.Column.(*columnString).data[chunkSize])
+	assert.Equal(t, "player 0", name.Column.(*columnString).data[0])
+	assert.Equal(t, 2*chunkSize, players.Count())
+
+	// The rows of the other chunks are still there
+	assert.NoError(t, players.QueryAt(2*chunkSize, func(r Row) error {
+		v, ok := r.String("name")
+		assert.True(t, ok)
+		assert.Equal(t, fmt.Sprintf("player %d", 2*chunkSize), v)
+		return nil
+	}))
+}
+
+func TestMemoryUsage(t *testing.T) {
+	players := loadPlayers(500)
+	usage := players.MemoryUsage()
+	assert.Greater(t, usage.Fill, 0)
+	assert.Greater(t, usage.Total(), usage.Fill)
+
+	// Numeric columns have data and a fill list
+	age := usage.Columns["age"]
+	assert.GreaterOrEqual(t, age.Data, 500*8)
+	assert.Greater(t, age.Index, 0)
+	assert.Equal(t, 0, age.Dictionary)
+
+	// Enums have a dictionary and indexes only have bitmaps
+	assert.Greater(t, usage.Columns["race"].Dictionary, 0)
+	assert.Equal(t, 0, usage.Columns["human"].Data)
+	assert.Greater(t, usage.Columns["human"].Index, 0)
+	assert.Greater(t, usage.Columns["serial"].Dictionary, 0)
+	assert.Equal(t, age.Data+age.Index, age.Total())
+}
+
+func TestSlowQuery(t *testing.T) {
+	var slow []QueryInfo
+	players := newEmpty(500)
+	players.opts.SlowQuery = 5 * time.Millisecond
+	players.opts.OnSlowQuery = func(info QueryInfo) {
+		slow = append(slow, info)
+	}
+
+	for _, p := range loadFixture("players.json") {
+		players.InsertObject(p)
+	}
+
+	// Fast queries are not reported
+	slow = slow[:0]
+	players.Query(func(txn *Txn) error {
+		txn.With("human").Count()
+		return nil
+	})
+	assert.Empty(t, slow)
+
+	// Slow queries are reported with their filters and scanned rows
+	err := players.Query(func(txn *Txn) error {
+		txn.With("human").WithFloat("age", func(v float64) bool {
+			return v >= 30
+		}).Union("elf").Count()
+
+		time.Sleep(10 * time.Millisecond)
+		return io.EOF
+	})
+
+	assert.Equal(t, io.EOF, err)
+	assert.Len(t, slow, 1)
+	assert.Equal(t, []string{"With(human)", "WithFloat(age)", "Union(elf)"}, slow[0].Filters)
+	assert.NotZero(t, slow[0].Scanned)
+	assert.GreaterOrEqual(t, slow[0].Duration, 10*time.Millisecond)
+	assert.Equal(t, io.EOF, slow[0].Err)
+}
+
+func TestStats(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	before := players.Stats()
+
+	var human, old int
+	players.Query(func(txn *Txn) error {
+		human = txn.With("human").Count()
+		old = txn.WithFloat("age", func(v float64) bool {
+			return v >= 30
+		}).Count()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		return io.EOF
+	})
+
+	stats := players.Stats()
+	assert.Equal(t, before.Queries+2, stats.Queries)
+	assert.Equal(t, before.Failed+1, stats.Failed)
+	assert.Equal(t, before.Filters+2, stats.Filters)
+	assert.Equal(t, before.Indexed+1, stats.Indexed)
+	assert.Equal(t, before.Scanned+uint64(human), stats.Scanned)
+	assert.Equal(t, before.Matched+uint64(old), stats.Matched)
+	assert.Equal(t, uint64(human), stats.Columns["age"])
+	assert.Equal(t, 0.5, stats.IndexHitRatio())
+	assert.InDelta(t, float64(old)/float64(human), stats.MatchRatio(), 0.001)
+	assert.Zero(t, Stats{}.IndexHitRatio())
+	assert.Zero(t, Stats{}.MatchRatio())
+}
+
+func TestStatsAccess(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	before := players.Stats().Access
+
+	players.Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {
+			txn.Float64("age").Get()
+		})
+	})
+	players.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		return txn.WithFloat("age", func(v float64) bool { return v >= 30 }).Range(func(idx uint32) {
+			balance.Set(10)
+		})
+	})
+
+	access := players.Stats().Access
+	assert.Equal(t, before["human"].Reads+1, access["human"].Reads)
+	assert.Equal(t, before["age"].Reads+2, access["age"].Reads)
+	assert.Equal(t, before["balance"].Reads, access["balance"].Reads)
+	assert.Equal(t, before["balance"].Writes+1, access["balance"].Writes)
+	assert.Equal(t, before["age"].Writes, access["age"].Writes)
+	assert.Equal(t, before["mp"], access["mp"])
+}
+
+func TestTracer(t *testing.T) {
+	tracer := new(testTracer)
+	players := newEmpty(500)
+	players.opts.Tracer = tracer
+	players.Query(func(txn *Txn) error {
+		for _, p := range loadFixture("players.json") {
+			txn.InsertObject(p)
+		}
+		return nil
+	})
+
+	tracer.spans = tracer.spans[:0]
+	players.QueryContext(context.Background(), func(txn *Txn) error {
+		assert.NotNil(t, txn.Context().Value(testSpanKey{}))
+		txn.With("human").WithFloat("age", func(v float64) bool {
+			return v >= 30
+		}).Count()
+		return nil
+	})
+
+	assert.Len(t, tracer.spans, 2)
+	query, commit := tracer.spans[0], tracer.spans[1]
+	assert.Equal(t, SpanQuery, query.name)
+	assert.Equal(t, SpanCommit, commit.name)
+	assert.True(t, query.ended && commit.ended)
+	assert.NotZero(t, query.attrs[AttrRowsMatched])
+	assert.NotZero(t, query.attrs[AttrRowsScanned])
+	assert.Equal(t, []string{"human"}, query.attrs[AttrIndexes])
+	assert.Equal(t, []string{"With(human)", "WithFloat(age)"}, query.attrs[AttrFilters])
+	assert.Equal(t, 0, commit.attrs[AttrChunks])
+
+	// Snapshots are traced as well
+	assert.NoError(t, players.Snapshot(new(bytes.Buffer)))
+	assert.Equal(t, SpanSnapshot, tracer.spans[2].name)
+}
+
+type testSpanKey struct{}
+
+// testTracer records the spans started, for testing
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, testSpanKey{}, span), span
+}
+
+// testSpan represents a recorded span, for testing
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.ended = true
+}
+
+func TestStorageMMap(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *Collection {
+		coll := NewCollection(Options{Storage: MMap(dir)})
+		assert.NoError(t, coll.CreateColumn("age", ForFloat64()))
+		assert.NoError(t, coll.CreateColumn("active", ForBool()))
+		assert.NoError(t, coll.CreateColumn("name", ForString()))
+		return coll
+	}
+
+	// Insert enough rows to span multiple chunks and grow the buffers
+	coll := open()
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		for i := 0; i < 50000; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetFloat64("age", float64(i))
+				r.SetBool("active", i%2 == 0)
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	assert.True(t, coll.DeleteAt(10))
+	assert.Equal(t, 49999, coll.Count())
+	assert.NoError(t, coll.Close())
+
+	// Reopen the collection and check that the rows were restored
+	coll = open()
+	defer coll.Close()
+	assert.Equal(t, 49999, coll.Count())
+	assert.NoError(t, coll.QueryAt(40000, func(r Row) error {
+		age, ok := r.Float64("age")
+		assert.True(t, ok)
+		assert.Equal(t, 40000.0, age)
+		assert.True(t, r.Bool("active"))
+		return nil
+	}))
+
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 24999, txn.With("active").Count())
+		return nil
+	}))
+
+	// Released chunks are paged in again from the files
+	coll.opts.SpillAfter = time.Minute
+	assert.Equal(t, 4, coll.spill(time.Now()))
+	assert.NoError(t, coll.QueryAt(40000, func(r Row) error {
+		age, _ := r.Float64("age")
+		assert.Equal(t, 40000.0, age)
+		return nil
+	}))
+
+	// The restored collection remains writable
+	idx, err := coll.Insert(func(r Row) error {
+		r.SetFloat64("age", 1)
+		r.SetString("name", "Roman")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 50000, coll.Count())
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Roman", name)
+		return nil
+	}))
+}
+
+func TestSpillColdChunks(t *testing.T) {
+	storage := newTestStorage()
+	coll := NewCollection(Options{
+		Storage:    storage,
+		SpillAfter: time.Minute,
+	})
+	defer coll.Close()
+	assert.NoError(t, coll.CreateColumn("age", ForFloat64()))
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		for i := 0; i < 3*chunkSize; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetFloat64("age", float64(i))
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	// Nothing is released while the chunks are recently used
+	assert.Equal(t, 0, coll.spill(time.Now()))
+
+	// Touch the last chunk, only the other ones should be released
+	coll.lock.Lock()
+	coll.touched[0], coll.touched[1] = 0, 0
+	coll.lock.Unlock()
+	assert.Equal(t, 2, coll.spill(time.Now()))
+	assert.Equal(t, 2, storage.released["age.data"])
+	assert.Equal(t, 0, coll.spill(time.Now()))
+
+	// Accessing a released chunk makes it hot again
+	assert.NoError(t, coll.QueryAt(10, func(r Row) error {
+		age, _ := r.Float64("age")
+		assert.Equal(t, 10.0, age)
+		return nil
+	}))
+	assert.Equal(t, 0, coll.spill(time.Now()))
+	assert.Equal(t, 2, coll.spill(time.Now().Add(2*time.Minute)))
+}
+
+func TestStorageErrors(t *testing.T) {
+	storage := newTestStorage()
+	storage.failure = errors.New("disk full")
+	_, err := Open(Options{Storage: storage})
+	assert.ErrorIs(t, err, storage.failure)
+	assert.Panics(t, func() {
+		NewCollection(Options{Storage: storage})
+	})
+
+	storage.failure = nil
+	coll, err := Open(Options{Storage: storage})
+	assert.NoError(t, err)
+	defer coll.Close()
+	assert.NoError(t, coll.CreateColumn("age", ForFloat64()))
+	assert.NoError(t, insertObject(coll, Object{"age": 30}))
+
+	// A transaction which can not grow the buffers is rolled back
+	storage.failure = errors.New("disk full")
+	assert.ErrorIs(t, coll.Query(func(txn *Txn) error {
+		for i := 0; i < 2*chunkSize; i++ {
+			txn.InsertObject(Object{"age": float64(i)})
+		}
+		return nil
+	}), storage.failure)
+	assert.Equal(t, 1, coll.Count())
+	assert.ErrorIs(t, coll.CreateColumn("active", ForBool()), storage.failure)
+
+	// Once the storage recovers, the buffers are grown again
+	storage.failure = nil
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		for i := 0; i < 2*chunkSize; i++ {
+			txn.InsertObject(Object{"age": float64(i)})
+		}
+		return nil
+	}))
+	assert.Equal(t, 2*chunkSize+1, coll.Count())
+}
+
+// testStorage represents a storage in memory, which records the released buffers
+type testStorage struct {
+	buffers  map[string][]byte
+	released map[string]int
+	failure  error // The error returned by the storage, if any
+}
+
+func newTestStorage() *testStorage {
+	return &testStorage{
+		buffers:  make(map[string][]byte),
+		released: make(map[string]int),
+	}
+}
+
+func (s *testStorage) Open(name string) ([]byte, error) {
+	return s.buffers[name], s.failure
+}
+
+func (s *testStorage) Resize(name string, size int) ([]byte, error) {
+	if s.failure != nil {
+		return nil, s.failure
+	}
+
+	if buffer := s.buffers[name]; len(buffer) < size {
+		s.buffers[name] = append(buffer, make([]byte, size-len(buffer))...)
+	}
+	return s.buffers[name], nil
+}
+
+func (s *testStorage) Release(name string, offset, size int) error {
+	s.released[name]++
+	return nil
+}
+
+func (s *testStorage) Close() error {
+	return nil
+}
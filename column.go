@@ -1,7 +1,7 @@
 // Copyright (c) Roman Atachiants and contributors. All rights reserved.
 // Licensed under the MIT license. See LICENSE file in the project root for details.
 
-//go:generate genny -pkg=column -in=column_generate.go -out=column_numbers.go gen "number=float32,float64,int,int16,int32,int64,uint,uint16,uint32,uint64"
+//go:generate genny -pkg=column -in=column_generate.go -out=column_numbers.go gen "number=float32,float64,int,int8,int16,int32,int64,uint,uint8,uint16,uint32,uint64"
 
 package column
 
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -57,6 +58,18 @@ type Numeric interface {
 	FilterInt64(uint32, bitmap.Bitmap, func(v int64) bool)
 }
 
+// rangeFilter represents a numeric column which is able to filter its values against a
+// range without calling a predicate for every value.
+type rangeFilter interface {
+	filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64)
+}
+
+// vectorLoader represents a numeric column which is able to load the values of a chunk
+// into a vector without a call for every value.
+type vectorLoader interface {
+	loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64)
+}
+
 // Textual represents a column that stores strings.
 type Textual interface {
 	Column
@@ -68,22 +81,114 @@ type Textual interface {
 
 // Various column constructor functions for a specific types.
 var (
-	ForString  = makeStrings
-	ForFloat32 = makeFloat32s
-	ForFloat64 = makeFloat64s
-	ForInt     = makeInts
-	ForInt16   = makeInt16s
-	ForInt32   = makeInt32s
-	ForInt64   = makeInt64s
-	ForUint    = makeUints
-	ForUint16  = makeUint16s
-	ForUint32  = makeUint32s
-	ForUint64  = makeUint64s
-	ForBool    = makeBools
-	ForEnum    = makeEnum
-	ForKey     = makeKey
+	ForString   = makeStrings
+	ForFloat32  = makeFloat32s
+	ForFloat64  = makeFloat64s
+	ForInt      = makeInts
+	ForInt8     = makeInt8s
+	ForInt16    = makeInt16s
+	ForInt32    = makeInt32s
+	ForInt64    = makeInt64s
+	ForUint     = makeUints
+	ForUint8    = makeUint8s
+	ForUint16   = makeUint16s
+	ForUint32   = makeUint32s
+	ForUint64   = makeUint64s
+	ForBool     = makeBools
+	ForEnum     = makeEnum
+	ForKey      = makeKey
+	ForMap      = makeMaps
+	ForDuration = makeDurations
+	ForIP       = makeIPs
+	ForInt128   = makeInt128s
 )
 
+// ColumnOption represents an option which can be specified when creating a column.
+type ColumnOption func(*columnConfig)
+
+// columnConfig represents the configuration of a column
+type columnConfig struct {
+	encoding    Encoding  // The encoding of the values
+	cardinality int       // The maximum number of values in the dictionary
+	dictionary  []string  // The values to seed the dictionary with
+	width       int       // The width of the dictionary codes, in bits
+	prefix      [2]int    // The lengths of the indexed IPv4 and IPv6 prefixes
+	arena       bool      // Whether the strings are stored in the arena
+	intern      bool      // Whether identical strings are stored only once
+	collation   Collation // The collation of the strings
+	merge       MergeFunc // The function merging the deltas into the values
+}
+
+// WithEncoding specifies the encoding to use for the values of a numeric column. The
+// values are decoded transparently by the cursors and the filters.
+func WithEncoding(encoding Encoding) ColumnOption {
+	return func(c *columnConfig) {
+		c.encoding = encoding
+	}
+}
+
+// WithCardinality specifies the maximum number of distinct values kept in the dictionary
+// of an enum column. Once the dictionary is full, any new value is stored separately for
+// each row, as a regular string column would.
+func WithCardinality(max int) ColumnOption {
+	return func(c *columnConfig) {
+		c.cardinality = max
+	}
+}
+
+// WithDictionary seeds the dictionary of an enum column with a set of values. The seeded
+// values are retained in the dictionary, even if no longer used.
+func WithDictionary(values ...string) ColumnOption {
+	return func(c *columnConfig) {
+		c.dictionary = append(c.dictionary, values...)
+	}
+}
+
+// WithArena stores the values of a string column, or the dictionary of an enum column, in
+// the string arena of the collection. The arena copies the strings into large pages, so
+// that the garbage collector only tracks a few objects instead of one for every value.
+// The space of the strings which are overwritten or deleted is reclaimed by Vacuum().
+func WithArena() ColumnOption {
+	return func(c *columnConfig) {
+		c.arena = true
+	}
+}
+
+// WithInterning stores the values of a string column in the string arena of the collection,
+// and stores identical values only once, which saves memory for repetitive values that
+// do not fit an enum column.
+func WithInterning() ColumnOption {
+	return func(c *columnConfig) {
+		c.arena = true
+		c.intern = true
+	}
+}
+
+// WithCollation specifies the collation of a string or an enum column, which is used by
+// WithStringEqual() to compare the values and by the bloom filter indexes on the column.
+func WithCollation(collation Collation) ColumnOption {
+	return func(c *columnConfig) {
+		c.collation = collation
+	}
+}
+
+// WithMerge specifies the function which merges the deltas written with Merge() into the
+// values of a numeric, string or enum column, once the transaction is committed.
+func WithMerge(fn MergeFunc) ColumnOption {
+	return func(c *columnConfig) {
+		c.merge = fn
+	}
+}
+
+// configure applies the options and returns the configuration of a column
+func configure(opts []ColumnOption) columnConfig {
+	var config columnConfig
+	for _, apply := range opts {
+		apply(&config)
+	}
+	return config
+}
+
 // ForKind creates a new column instance for a specified reflect.Kind
 func ForKind(kind reflect.Kind) (Column, error) {
 	switch kind {
@@ -93,6 +198,8 @@ func ForKind(kind reflect.Kind) (Column, error) {
 		return makeFloat64s(), nil
 	case reflect.Int:
 		return makeInts(), nil
+	case reflect.Int8:
+		return makeInt8s(), nil
 	case reflect.Int16:
 		return makeInt16s(), nil
 	case reflect.Int32:
@@ -101,6 +208,8 @@ func ForKind(kind reflect.Kind) (Column, error) {
 		return makeInt64s(), nil
 	case reflect.Uint:
 		return makeUints(), nil
+	case reflect.Uint8:
+		return makeUint8s(), nil
 	case reflect.Uint16:
 		return makeUint16s(), nil
 	case reflect.Uint32:
@@ -111,19 +220,73 @@ func ForKind(kind reflect.Kind) (Column, error) {
 		return makeBools(), nil
 	case reflect.String:
 		return makeStrings(), nil
+	case reflect.Map:
+		return makeMaps(), nil
 	default:
 		return nil, fmt.Errorf("column: unsupported column kind (%v)", kind)
 	}
 }
 
+// makeEmpty creates a new, empty column of the same type as the specified column.
+func makeEmpty(column Column) (Column, error) {
+	merge := WithMerge(mergeOf(column))
+	switch v := column.(type) {
+	case *float32Column:
+		return makeFloat32s(WithEncoding(v.Encoding()), merge), nil
+	case *float64Column:
+		return makeFloat64s(WithEncoding(v.Encoding()), merge), nil
+	case *intColumn:
+		return makeInts(WithEncoding(v.Encoding()), merge), nil
+	case *int8Column:
+		return makeInt8s(WithEncoding(v.Encoding()), merge), nil
+	case *int16Column:
+		return makeInt16s(WithEncoding(v.Encoding()), merge), nil
+	case *int32Column:
+		return makeInt32s(WithEncoding(v.Encoding()), merge), nil
+	case *int64Column:
+		return makeInt64s(WithEncoding(v.Encoding()), merge), nil
+	case *columnDuration:
+		return makeDurations(WithEncoding(v.Encoding()), merge), nil
+	case *uintColumn:
+		return makeUints(WithEncoding(v.Encoding()), merge), nil
+	case *uint8Column:
+		return makeUint8s(WithEncoding(v.Encoding()), merge), nil
+	case *uint16Column:
+		return makeUint16s(WithEncoding(v.Encoding()), merge), nil
+	case *uint32Column:
+		return makeUint32s(WithEncoding(v.Encoding()), merge), nil
+	case *uint64Column:
+		return makeUint64s(WithEncoding(v.Encoding()), merge), nil
+	case *columnBool:
+		return makeBools(), nil
+	case *columnString:
+		return makeStrings(append(v.options(), merge)...), nil
+	case *columnEnum:
+		return makeEnum(append(v.options(), merge)...), nil
+	case *columnKey:
+		return makeKey(), nil
+	case *columnMap:
+		return makeMaps(), nil
+	case *columnIP:
+		return makeIPs(v.options()...), nil
+	case *columnInt128:
+		return makeInt128s(), nil
+	default:
+		return nil, fmt.Errorf("column: unable to copy column of type %T", column)
+	}
+}
+
 // --------------------------- Column ----------------------------
 
 // column represents a column wrapper that synchronizes operations
 type column struct {
+	reads  uint64 // The number of transactions which read the column (atomic)
+	writes uint64 // The number of transactions which changed the column (atomic)
 	Column
-	lock sync.RWMutex // The lock to protect the entire column
-	kind columnType   // The type of the colum
-	name string       // The name of the column
+	lock  sync.RWMutex // The lock to protect the entire column
+	kind  columnType   // The type of the colum
+	name  string       // The name of the column
+	zones *zoneMap     // The bounds of the values of every chunk, for numeric columns (optional)
 }
 
 // columnFor creates a synchronized column for a column implementation
@@ -137,7 +300,7 @@ func columnFor(name string, v Column) *column {
 
 // IsIndex returns whether the column is an index
 func (c *column) IsIndex() bool {
-	_, ok := c.Column.(*columnIndex)
+	_, ok := c.Column.(computed)
 	return ok
 }
 
@@ -190,6 +353,7 @@ func (c *column) Value(idx uint32) (v interface{}, ok bool) {
 // columnBool represents a boolean column
 type columnBool struct {
 	data bitmap.Bitmap
+	disk *mapped // The storage of the values, if the column is mounted
 }
 
 // makeBools creates a new boolean column
@@ -201,9 +365,41 @@ func makeBools() Column {
 
 // Grow grows the size of the column until we have enough to store
 func (c *columnBool) Grow(idx uint32) {
+	if c.disk != nil {
+		c.reserve(idx) // The buffer was reserved before the commit
+		return
+	}
+
 	c.data.Grow(idx)
 }
 
+// reserve grows the buffer of the storage until it is able to store the index, if the
+// column is mounted.
+func (c *columnBool) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), 8, int(idx>>6)+1)
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnBool) Shrink(size uint32) {
+	if c.disk == nil {
+		c.data = shrinkBitmap(c.data, size)
+	}
+}
+
+// mount backs the values of the column with a buffer of the storage, and restores them if
+// they were previously persisted.
+func (c *columnBool) mount(m *mapped) error {
+	if err := m.open(".data", unsafe.Pointer(&c.data), 8); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
 // Apply applies a set of operations to the column.
 func (c *columnBool) Apply(r *commit.Reader) {
 	for r.Next() {
@@ -233,6 +429,13 @@ func (c *columnBool) Index() *bitmap.Bitmap {
 	return &c.data
 }
 
+// usage returns the memory used by the column
+func (c *columnBool) usage() ColumnUsage {
+	return ColumnUsage{
+		Data: sizeOfBitmap(c.data),
+	}
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *columnBool) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	dst.PutBitmap(commit.PutTrue, chunk, c.data)
@@ -253,7 +456,8 @@ func (s boolReader) Get() bool {
 func boolReaderFor(txn *Txn, columnName string) boolReader {
 	column, ok := txn.columnAt(columnName)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+		txn.misuse(columnName, false, "bool")
+		return boolReader{cursor: &txn.cursor, reader: makeBools()}
 	}
 
 	return boolReader{
@@ -287,23 +491,30 @@ func (txn *Txn) Bool(columnName string) boolWriter {
 type anyReader struct {
 	cursor *uint32
 	reader Column
+	masked masking
 }
 
 // Get loads the value at the current transaction cursor
 func (s anyReader) Get() (interface{}, bool) {
-	return s.reader.Value(*s.cursor)
+	v, ok := s.reader.Value(*s.cursor)
+	if text, isText := v.(string); isText && s.masked.mask != nil {
+		return s.masked.apply(text, ok)
+	}
+	return v, ok
 }
 
 // anyReaderFor creates a new any reader
 func anyReaderFor(txn *Txn, columnName string) anyReader {
 	column, ok := txn.columnAt(columnName)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+		txn.misuse(columnName, false, "any")
+		return anyReader{cursor: &txn.cursor, reader: makeBools()}
 	}
 
 	return anyReader{
 		cursor: &txn.cursor,
 		reader: column.Column,
+		masked: txn.maskOf(columnName),
 	}
 }
 
@@ -311,23 +522,113 @@ func anyReaderFor(txn *Txn, columnName string) anyReader {
 type anyWriter struct {
 	anyReader
 	writer *commit.Buffer
+	name   string
+	txn    *Txn
 }
 
-// Set sets the value at the current transaction cursor
+// Set sets the value at the current transaction cursor. Numbers are converted to the type
+// of the column, and if the value can not be stored in the column, the transaction is
+// aborted with ErrTypeMismatch.
 func (s anyWriter) Set(value interface{}) {
+	value, err := valueFor(s.name, s.reader, value)
+	if err != nil {
+		s.txn.abort(s.txn.violated(err, FieldColumn, s.name))
+		return
+	}
+
 	s.writer.PutAny(commit.Put, *s.cursor, value)
 }
 
+// Add atomically adds a delta of any numeric type to the value at the current transaction
+// cursor. The delta is converted to the type of the column and, since it is only added to
+// the value of the row once the transaction is committed, the concurrent transactions
+// adding to the same value do not overwrite each other.
+func (s anyWriter) Add(delta interface{}) {
+	mutation := Add(s.name, delta)
+	value, err := mutation.valueOf(s.reader)
+	if err != nil {
+		s.txn.abort(err)
+		return
+	}
+
+	s.writer.AddAny(*s.cursor, value)
+}
+
 // Any returns a column accessor
 func (txn *Txn) Any(columnName string) anyWriter {
 	return anyWriter{
 		anyReader: anyReaderFor(txn, columnName),
 		writer:    txn.bufferFor(columnName),
+		name:      columnName,
+		txn:       txn,
 	}
 }
 
 // --------------------------- funcs ----------------------------
 
+// compacter represents a column which can release the values of its deleted rows
+type compacter interface {
+	compact(chunk commit.Chunk)
+}
+
+// hasValues returns whether any of the rows of the chunk is in the fill list
+func hasValues(chunk commit.Chunk, fill bitmap.Bitmap) bool {
+	for _, word := range chunk.OfBitmap(fill) {
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// shrinker represents a column which can release its unused capacity
+type shrinker interface {
+	Shrink(size uint32)
+}
+
+// shrink returns the length of a slice once shrunk to the specified size
+func shrink(length int, size uint32) int {
+	if length > int(size) {
+		return int(size)
+	}
+	return length
+}
+
+// andFill intersects the index of a chunk with the fill list of a column, starting at the
+// offset. The fill list may be shorter than the chunk, for example if the column was
+// created after the rows were inserted, in which case the remaining rows are removed.
+func andFill(index, fill bitmap.Bitmap, offset uint32) {
+	from := int(offset >> 6)
+	if from+len(index) <= len(fill) {
+		index.And(fill[from : from+len(index)])
+		return
+	}
+
+	for i := range index {
+		if from+i < len(fill) {
+			index[i] &= fill[from+i]
+		} else {
+			index[i] = 0
+		}
+	}
+}
+
+// bit converts a boolean into a single bit, without branching
+func bit(v bool) uint64 {
+	var out uint64
+	if v {
+		out = 1
+	}
+	return out
+}
+
+// shrinkBitmap copies the bitmap into a new one, large enough for the specified size
+func shrinkBitmap(v bitmap.Bitmap, size uint32) bitmap.Bitmap {
+	clone := make(bitmap.Bitmap, shrink(len(v), (size+63)>>6))
+	copy(clone, v)
+	return clone
+}
+
 // resize calculates the new required capacity and a new index
 func resize(capacity int, v uint32) int {
 	const threshold = 256
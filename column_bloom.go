@@ -0,0 +1,127 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/zeebo/xxh3"
+)
+
+const (
+	bloomBits   = 8 << chunkShift // The number of bits of the filter of each chunk
+	bloomHashes = 4               // The number of bits set for each value
+)
+
+// columnBloom represents an index which keeps a bloom filter of the string values of each
+// chunk, so that the chunks which definitely do not contain a value can be skipped. Since
+// the filters do not support removals, the values which were deleted or overwritten remain
+// in them and only make false positives more likely.
+type columnBloom struct {
+	fill   bitmap.Bitmap   // The rows which have a value in the target column
+	name   string          // The name of the target column
+	order  Collation       // The collation of the target column
+	chunks []bitmap.Bitmap // The bloom filter of each chunk
+}
+
+// newBloom creates a new bloom filter index column, which hashes the values so that the
+// values equal under the collation of the target column have the same hash.
+func newBloom(indexName, columnName string, collation Collation) *column {
+	return columnFor(indexName, &columnBloom{
+		fill:  make(bitmap.Bitmap, 0, 4),
+		name:  columnName,
+		order: collation,
+	})
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnBloom) Grow(idx uint32) {
+	c.fill.Grow(idx)
+	for len(c.chunks) <= int(commit.ChunkAt(idx)) {
+		c.chunks = append(c.chunks, make(bitmap.Bitmap, bloomBits/64))
+	}
+}
+
+// Column returns the target name of the column on which this index should apply.
+func (c *columnBloom) Column() string {
+	return c.name
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnBloom) Apply(r *commit.Reader) {
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill.Set(r.Index())
+			filter := c.chunks[commit.ChunkAt(r.Index())]
+			h1, h2 := bloomHash(xxh3.Hash(c.order.key(r.Bytes())))
+			for i := uint32(0); i < bloomHashes; i++ {
+				filter.Set((h1 + i*h2) % bloomBits)
+			}
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// bloomContains returns whether the chunk may contain the value with the specified hash,
+// given the filters of the chunks. If it returns false, the value is definitely not present.
+func bloomContains(filters []bitmap.Bitmap, chunk commit.Chunk, hash uint64) bool {
+	if int(chunk) >= len(filters) {
+		return false
+	}
+
+	filter := filters[chunk]
+	h1, h2 := bloomHash(hash)
+	for i := uint32(0); i < bloomHashes; i++ {
+		if !filter.Contains((h1 + i*h2) % bloomBits) {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash splits the hash of a value into the two hashes used by the filter
+func bloomHash(hash uint64) (uint32, uint32) {
+	return uint32(hash), uint32(hash>>32) | 1
+}
+
+// Value retrieves a value at a specified index.
+func (c *columnBloom) Value(idx uint32) (v interface{}, ok bool) {
+	if idx < uint32(len(c.fill))<<6 {
+		v, ok = c.fill.Contains(idx), true
+	}
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnBloom) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnBloom) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// usage returns the memory used by the column
+func (c *columnBloom) usage() ColumnUsage {
+	return ColumnUsage{
+		Index: sizeOfBitmap(c.fill) + len(c.chunks)*bloomBits/8,
+	}
+}
+
+// Snapshot does nothing, as the index is rebuilt from the target column
+func (c *columnBloom) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {}
+
+// bloomOf returns the filters of the bloom filter index among the indexes of a column, or
+// nil if the column does not have one.
+func bloomOf(columns []*column) []bitmap.Bitmap {
+	for _, v := range columns[1:] {
+		if bloom, ok := v.Column.(*columnBloom); ok {
+			return bloom.chunks
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+)
+
+// codes represents the dictionary codes of the rows of an enum column, stored with a width
+// of 8, 16 or 32 bits. The largest code of every width marks the values which overflowed
+// the dictionary, and the codes are widened once the dictionary outgrows them.
+type codes struct {
+	width int      // The width of the codes, in bits
+	w8    []uint8  // The codes, if 8 bits wide
+	w16   []uint16 // The codes, if 16 bits wide
+	w32   []uint32 // The codes, if 32 bits wide
+}
+
+// newCodes creates a new list of codes, with the width rounded up to 8, 16 or 32 bits
+func newCodes(width int, capacity int) *codes {
+	switch {
+	case width > 0 && width <= 8:
+		return &codes{width: 8, w8: make([]uint8, 0, capacity)}
+	case width > 0 && width <= 16:
+		return &codes{width: 16, w16: make([]uint16, 0, capacity)}
+	default:
+		return &codes{width: 32, w32: make([]uint32, 0, capacity)}
+	}
+}
+
+// WithCodeWidth specifies the width, in bits, of the dictionary codes an enum column stores
+// for every row, rounded up to 8, 16 or 32. Narrower codes use less memory and are faster
+// to scan, and they are widened automatically once the dictionary no longer fits into them.
+func WithCodeWidth(bits int) ColumnOption {
+	return func(c *columnConfig) {
+		c.width = bits
+	}
+}
+
+// limit returns the overflow marker of the width, which is also the number of codes it fits
+func (c *codes) limit() uint32 {
+	switch c.width {
+	case 8:
+		return 1<<8 - 1
+	case 16:
+		return 1<<16 - 1
+	default:
+		return overflowAt
+	}
+}
+
+// fits checks whether a location of the dictionary fits into the codes
+func (c *codes) fits(at uint32) bool {
+	return at == overflowAt || at < c.limit()
+}
+
+// len returns the number of codes
+func (c *codes) len() int {
+	switch c.width {
+	case 8:
+		return len(c.w8)
+	case 16:
+		return len(c.w16)
+	default:
+		return len(c.w32)
+	}
+}
+
+// capacity returns the number of codes which fit without growing
+func (c *codes) capacity() int {
+	switch c.width {
+	case 8:
+		return cap(c.w8)
+	case 16:
+		return cap(c.w16)
+	default:
+		return cap(c.w32)
+	}
+}
+
+// size returns the memory used by the codes, in bytes
+func (c *codes) size() int {
+	return c.capacity() * c.width / 8
+}
+
+// get returns the location of the dictionary at an index, or the overflow location
+func (c *codes) get(idx uint32) uint32 {
+	switch c.width {
+	case 8:
+		if v := c.w8[idx]; v != 1<<8-1 {
+			return uint32(v)
+		}
+	case 16:
+		if v := c.w16[idx]; v != 1<<16-1 {
+			return uint32(v)
+		}
+	default:
+		return c.w32[idx]
+	}
+	return overflowAt
+}
+
+// set sets the location of the dictionary at an index, which must fit into the codes
+func (c *codes) set(idx uint32, at uint32) {
+	if at == overflowAt {
+		at = c.limit()
+	}
+
+	switch c.width {
+	case 8:
+		c.w8[idx] = uint8(at)
+	case 16:
+		c.w16[idx] = uint16(at)
+	default:
+		c.w32[idx] = at
+	}
+}
+
+// resize resizes the codes to the specified length and capacity, keeping the existing ones
+func (c *codes) resize(length, capacity int) {
+	switch c.width {
+	case 8:
+		clone := make([]uint8, length, capacity)
+		copy(clone, c.w8)
+		c.w8 = clone
+	case 16:
+		clone := make([]uint16, length, capacity)
+		copy(clone, c.w16)
+		c.w16 = clone
+	default:
+		clone := make([]uint32, length, capacity)
+		copy(clone, c.w32)
+		c.w32 = clone
+	}
+}
+
+// grow grows the codes so that the index fits
+func (c *codes) grow(idx uint32) {
+	switch {
+	case idx < uint32(c.len()):
+		return
+	case idx < uint32(c.capacity()):
+		switch c.width {
+		case 8:
+			c.w8 = c.w8[:idx+1]
+		case 16:
+			c.w16 = c.w16[:idx+1]
+		default:
+			c.w32 = c.w32[:idx+1]
+		}
+	default:
+		c.resize(int(idx)+1, resize(c.capacity(), idx+1))
+	}
+}
+
+// widen returns the codes widened so that the location of the dictionary fits into them
+func (c *codes) widen(at uint32) *codes {
+	out := c
+	for !out.fits(at) {
+		wider := newCodes(out.width*2, 0)
+		wider.resize(out.len(), out.len())
+		for i := 0; i < out.len(); i++ {
+			wider.set(uint32(i), out.get(uint32(i)))
+		}
+		out = wider
+	}
+	return out
+}
+
+// filter filters down the rows of the chunk whose code is the specified one
+func (c *codes) filter(offset uint32, index bitmap.Bitmap, code uint32) {
+	size := uint32(c.len())
+	switch c.width {
+	case 8:
+		index.Filter(func(idx uint32) bool {
+			idx += offset
+			return idx < size && uint32(c.w8[idx]) == code
+		})
+	case 16:
+		index.Filter(func(idx uint32) bool {
+			idx += offset
+			return idx < size && uint32(c.w16[idx]) == code
+		})
+	default:
+		index.Filter(func(idx uint32) bool {
+			idx += offset
+			return idx < size && c.w32[idx] == code
+		})
+	}
+}
+
+// WithEnumCode filters down the rows of an enum column whose value has the specified code in
+// the dictionary, as returned by EnumCode. Only the codes are compared, which makes this the
+// fastest equality filter on columns with few distinct values, but the values which did not
+// fit into the dictionary of the column never match.
+func (txn *Txn) WithEnumCode(column string, code uint32) *Txn {
+	txn.filterRange(filterCode, column, float64(code), float64(code))
+	return txn
+}
+
+// withEnumCode filters down the current selection based on the codes of an enum column
+func (txn *Txn) withEnumCode(column string, code uint32) {
+	defer txn.trace("WithEnumCode", column, false)()
+	c, ok := txn.columnAt(column)
+	if !ok {
+		txn.index.Clear()
+		return
+	}
+
+	enum, ok := c.Column.(*columnEnum)
+	if !ok {
+		txn.index.Clear()
+		return
+	}
+
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		enum.filterCode(offset, index, code)
+	})
+}
@@ -0,0 +1,195 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// columnDictionary represents an index of an enum column which keeps a bitmap of the rows
+// holding each of its distinct values, so that the rows equal to any value are found with
+// a single intersection, in the same way as a bitmap index created for that value alone.
+type columnDictionary struct {
+	lock   sync.RWMutex      // Lock to protect the bitmaps of the values
+	fill   bitmap.Bitmap     // The rows which have a value in the target column
+	name   string            // The name of the target column
+	codes  []uint32          // The code of the value of each row, 0 if none
+	values []string          // The distinct values, by their code minus one
+	seek   map[string]uint32 // The code of each distinct value
+	rows   []bitmap.Bitmap   // The rows holding each distinct value, by their code minus one
+}
+
+// CreateDictionaryIndex creates an index on an enum column which maintains a bitmap of the
+// rows for every distinct value of the column, so that the WithStringEqual() filters on
+// any of its values are answered from the index rather than by scanning the column. This
+// is the equivalent of creating a bitmap index per value, but the values do not need to be
+// known upfront. The index is named "dictionary:" followed by the name of the column.
+func (c *Collection) CreateDictionaryIndex(columnName string) error {
+	if columnName == "" {
+		return fmt.Errorf("column: create index must specify a column")
+	}
+
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return errorOf(ErrColumnNotFound, "column: unable to create index, column '%v' does not exist", columnName)
+	}
+
+	if _, ok := column.Column.(*columnEnum); !ok {
+		return fmt.Errorf("column: unable to create dictionary index, column '%v' is not an enum", columnName)
+	}
+
+	return c.addIndex(dictionaryName(columnName), columnName, newDictionary(dictionaryName(columnName), columnName))
+}
+
+// dictionaryName returns the name of the dictionary index of a column
+func dictionaryName(columnName string) string {
+	return "dictionary:" + columnName
+}
+
+// newDictionary creates a new dictionary index column.
+func newDictionary(indexName, columnName string) *column {
+	return columnFor(indexName, &columnDictionary{
+		fill: make(bitmap.Bitmap, 0, 4),
+		name: columnName,
+		seek: make(map[string]uint32, 16),
+	})
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnDictionary) Grow(idx uint32) {
+	c.lock.Lock()
+	c.fill.Grow(idx)
+	c.grow(idx)
+	c.lock.Unlock()
+}
+
+// grow grows the codes of the rows, while the lock is held
+func (c *columnDictionary) grow(idx uint32) {
+	switch {
+	case int(idx) < len(c.codes):
+		return
+	case int(idx) < cap(c.codes):
+		c.codes = c.codes[:idx+1]
+	default:
+		codes := make([]uint32, idx+1, resize(cap(c.codes), idx+1))
+		copy(codes, c.codes)
+		c.codes = codes
+	}
+}
+
+// Column returns the target name of the column on which this index should apply.
+func (c *columnDictionary) Column() string {
+	return c.name
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnDictionary) Apply(r *commit.Reader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for r.Next() {
+		idx := r.Index()
+		switch r.Type {
+		case commit.Put:
+			c.remove(idx)
+			code, ok := c.seek[string(r.Bytes())]
+			if !ok {
+				value := string(r.Bytes()) // Copied, since the buffer of the commit is reused
+				c.values = append(c.values, value)
+				c.rows = append(c.rows, make(bitmap.Bitmap, 0, 4))
+				code = uint32(len(c.values))
+				c.seek[value] = code
+			}
+
+			c.grow(idx)
+			c.codes[idx] = code
+			c.rows[code-1].Set(idx)
+			c.fill.Set(idx)
+		case commit.Delete:
+			c.remove(idx)
+		}
+	}
+}
+
+// remove removes a row from the bitmap of its value, if present
+func (c *columnDictionary) remove(idx uint32) {
+	if int(idx) >= len(c.codes) || c.codes[idx] == 0 {
+		return
+	}
+
+	c.rows[c.codes[idx]-1].Remove(idx)
+	c.codes[idx] = 0
+	c.fill.Remove(idx)
+}
+
+// dictionaryOf returns the dictionary index among the indexes of a column, if any
+func dictionaryOf(columns []*column) *columnDictionary {
+	for _, v := range columns[1:] {
+		if dictionary, ok := v.Column.(*columnDictionary); ok {
+			return dictionary
+		}
+	}
+	return nil
+}
+
+// filter narrows down the selection to the rows holding the value
+func (c *columnDictionary) filter(value string, dst *bitmap.Bitmap) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if code, ok := c.seek[value]; ok {
+		dst.And(c.rows[code-1])
+		return
+	}
+
+	dst.Clear()
+}
+
+// Value retrieves a value at a specified index.
+func (c *columnDictionary) Value(idx uint32) (interface{}, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if int(idx) >= len(c.codes) || c.codes[idx] == 0 {
+		return nil, false
+	}
+	return c.values[c.codes[idx]-1], true
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnDictionary) Contains(idx uint32) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnDictionary) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// usage returns the memory used by the column
+func (c *columnDictionary) usage() ColumnUsage {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	index := sizeOfBitmap(c.fill)
+	for _, rows := range c.rows {
+		index += sizeOfBitmap(rows)
+	}
+
+	dictionary := cap(c.codes) * 4
+	for _, v := range c.values {
+		dictionary += len(v)
+	}
+	return ColumnUsage{
+		Index:      index,
+		Dictionary: dictionary,
+	}
+}
+
+// Snapshot does nothing, as the index is rebuilt from the target column
+func (c *columnDictionary) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {}
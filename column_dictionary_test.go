@@ -0,0 +1,94 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDictionaryIndex(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	expect := map[string]int{}
+	for _, class := range []string{"mage", "rogue", "warrior", "druid"} {
+		expect[class] = countWhere(players, func(txn *Txn) *Txn {
+			return txn.WithString("class", func(v string) bool { return v == class })
+		})
+	}
+
+	assert.NoError(t, players.CreateDictionaryIndex("class"))
+	for _, class := range []string{"mage", "rogue", "warrior", "druid", "bard"} {
+		assert.Equal(t, expect[class], countWhere(players, func(txn *Txn) *Txn {
+			return txn.WithStringEqual("class", class)
+		}))
+	}
+
+	// The bitmaps follow the updates and the deletes of the rows
+	players.Query(func(txn *Txn) error {
+		class := txn.Enum("class")
+		return txn.WithStringEqual("class", "mage").Range(func(idx uint32) {
+			switch idx % 2 {
+			case 0:
+				class.Set("bard")
+			default:
+				txn.DeleteAt(idx)
+			}
+		})
+	})
+
+	bards := countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithStringEqual("class", "bard")
+	})
+	assert.NotZero(t, bards)
+	assert.Equal(t, bards, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithString("class", func(v string) bool { return v == "bard" })
+	}))
+	assert.Equal(t, 0, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithStringEqual("class", "mage")
+	}))
+
+	// The index is only created for enums
+	assert.Error(t, players.CreateDictionaryIndex("age"))
+	assert.Error(t, players.CreateDictionaryIndex("missing"))
+	assert.Error(t, players.CreateDictionaryIndex(""))
+}
+
+func TestDictionaryIndexValue(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	assert.NoError(t, players.CreateDictionaryIndex("race"))
+
+	index, ok := players.cols.Load(dictionaryName("race"))
+	assert.True(t, ok)
+	assert.NoError(t, players.QueryAt(10, func(r Row) error {
+		race, _ := r.Enum("race")
+		value, ok := index.Value(10)
+		assert.True(t, ok)
+		assert.Equal(t, race, value)
+		return nil
+	}))
+
+	assert.NotZero(t, index.Column.(*columnDictionary).usage().Index)
+}
+
+func TestDictionaryIndexReuse(t *testing.T) {
+	players := NewCollection()
+	defer players.Close()
+	players.CreateColumn("class", ForEnum())
+	assert.NoError(t, players.CreateDictionaryIndex("class"))
+	players.InsertObject(Object{"class": "mage"})
+
+	// The commit buffers are reused by the next transactions, which must not change the
+	// values kept by the index
+	for i := 0; i < 100; i++ {
+		players.InsertObject(Object{"class": "xxxx"})
+	}
+
+	assert.Equal(t, 1, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithStringEqual("class", "mage")
+	}))
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// columnDuration represents a column of durations, stored as int64 nanoseconds
+type columnDuration struct {
+	*int64Column
+}
+
+// makeDurations creates a new duration column
+func makeDurations(opts ...ColumnOption) Column {
+	return &columnDuration{
+		int64Column: makeInt64s(opts...).(*int64Column),
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnDuration) Value(idx uint32) (v interface{}, ok bool) {
+	n, ok := c.load(idx)
+	return time.Duration(n), ok
+}
+
+// durationReader represents a read-only accessor for durations
+type durationReader struct {
+	cursor *uint32
+	reader *columnDuration
+}
+
+// Get loads the value at the current transaction cursor
+func (s durationReader) Get() (time.Duration, bool) {
+	v, ok := s.reader.load(*s.cursor)
+	return time.Duration(v), ok
+}
+
+// durationReaderFor creates a new duration reader
+func durationReaderFor(txn *Txn, columnName string) durationReader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*columnDuration)(nil), false
+	if exists {
+		reader, ok = column.Column.(*columnDuration)
+	}
+
+	if !ok {
+		txn.misuse(columnName, exists, "duration")
+		reader = makeDurations().(*columnDuration)
+	}
+
+	return durationReader{
+		cursor: &txn.cursor,
+		reader: reader,
+	}
+}
+
+// durationWriter represents a read-write accessor for durations
+type durationWriter struct {
+	durationReader
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s durationWriter) Set(value time.Duration) {
+	s.writer.PutInt64(*s.cursor, int64(value))
+}
+
+// Add atomically adds a delta to the value at the current transaction cursor
+func (s durationWriter) Add(delta time.Duration) {
+	s.writer.AddInt64(*s.cursor, int64(delta))
+}
+
+// Duration returns a read-write accessor for duration column
+func (txn *Txn) Duration(columnName string) durationWriter {
+	return durationWriter{
+		durationReader: durationReaderFor(txn, columnName),
+		writer:         txn.bufferFor(columnName),
+	}
+}
+
+// WithDurationGreater filters down the durations to the ones longer than the specified one.
+// Like WithFloatGreater(), the values are compared in bulk.
+func (txn *Txn) WithDurationGreater(column string, value time.Duration) *Txn {
+	txn.filterRange(filterGreater, column, math.Nextafter(float64(value), math.Inf(1)), math.Inf(1))
+	return txn
+}
+
+// WithDurationLess filters down the durations to the ones shorter than the specified one.
+// Like WithFloatLess(), the values are compared in bulk.
+func (txn *Txn) WithDurationLess(column string, value time.Duration) *Txn {
+	txn.filterRange(filterLess, column, math.Inf(-1), math.Nextafter(float64(value), math.Inf(-1)))
+	return txn
+}
+
+// WithDurationBetween filters down the durations to the ones between min and max, inclusive.
+// Like WithFloatBetween(), the values are compared in bulk.
+func (txn *Txn) WithDurationBetween(column string, min, max time.Duration) *Txn {
+	txn.filterRange(filterBetween, column, float64(min), float64(max))
+	return txn
+}
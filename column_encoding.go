@@ -0,0 +1,265 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"sync"
+	"unsafe"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Encoding represents a compression scheme for the values of a numeric column.
+type Encoding uint8
+
+// Various supported encodings for numeric columns
+const (
+	Plain Encoding = iota // Plain stores the values as they are, uncompressed
+	RLE                   // RLE stores runs of repeated values, best for low-variance data
+	Delta                 // Delta stores the differences between values, best for sorted integers
+)
+
+// --------------------------- Encoded Blocks ----------------------------
+
+const (
+	blockShift = 10 // 1K
+	blockSize  = 1 << blockShift
+	blockMask  = blockSize - 1
+)
+
+// encoded represents a set of numeric values, stored in compressed blocks of 1024 rows.
+// The values are represented as their raw bits, so that all numeric types can share
+// the same encoders.
+type encoded struct {
+	codec  Encoding // The encoding of the blocks
+	size   uint32   // The number of values
+	blocks [][]byte // The encoded blocks, nil for a block of zeroes
+}
+
+// newEncoded creates a new, empty set of encoded values
+func newEncoded(codec Encoding) *encoded {
+	return &encoded{
+		codec:  codec,
+		blocks: make([][]byte, 0, 4),
+	}
+}
+
+// grow grows the set until it is able to store the value at the index
+func (e *encoded) grow(idx uint32) {
+	if idx < e.size {
+		return
+	}
+
+	e.size = idx + 1
+	for len(e.blocks) <= int(idx>>blockShift) {
+		e.blocks = append(e.blocks, nil)
+	}
+}
+
+// shrink releases the blocks beyond the specified size
+func (e *encoded) shrink(size uint32) {
+	if size >= e.size {
+		return
+	}
+
+	blocks := make([][]byte, (size+blockMask)>>blockShift)
+	copy(blocks, e.blocks)
+	e.blocks = blocks
+	e.size = size
+}
+
+// usage returns the memory used by the encoded blocks
+func (e *encoded) usage() int {
+	size := cap(e.blocks) * int(unsafe.Sizeof([]byte{}))
+	for _, block := range e.blocks {
+		size += cap(block)
+	}
+	return size
+}
+
+// load loads a single value, decoding the block only up to the index
+func (e *encoded) load(idx uint32) uint64 {
+	src := e.blocks[idx>>blockShift]
+	if src == nil {
+		return 0
+	}
+
+	offset := int(idx & blockMask)
+	switch e.codec {
+	case RLE:
+		for i := 0; len(src) > 0; {
+			value, n := binary.Uvarint(src)
+			count, m := binary.Uvarint(src[n:])
+			if i += int(count); offset < i {
+				return value
+			}
+			src = src[n+m:]
+		}
+		return 0
+	default:
+		value, n := binary.Uvarint(src)
+		for i := 0; i < offset && n < len(src); i++ {
+			delta, m := binary.Varint(src[n:])
+			value += uint64(delta)
+			n += m
+		}
+		return value
+	}
+}
+
+// decode decodes an entire block into the destination
+func (e *encoded) decode(block int, dst *[blockSize]uint64) {
+	src := e.blocks[block]
+	if src == nil {
+		*dst = [blockSize]uint64{}
+		return
+	}
+
+	switch e.codec {
+	case RLE:
+		for i := 0; len(src) > 0; {
+			value, n := binary.Uvarint(src)
+			count, m := binary.Uvarint(src[n:])
+			for end := i + int(count); i < end; i++ {
+				dst[i] = value
+			}
+			src = src[n+m:]
+		}
+	default:
+		value, n := binary.Uvarint(src)
+		for i := 0; i < blockSize; i++ {
+			if i > 0 && n < len(src) {
+				delta, m := binary.Varint(src[n:])
+				value += uint64(delta)
+				n += m
+			}
+			dst[i] = value
+		}
+	}
+}
+
+// encode encodes an entire block from the source
+func (e *encoded) encode(block int, src *[blockSize]uint64) {
+	if *src == [blockSize]uint64{} {
+		e.blocks[block] = nil
+		return
+	}
+
+	var tmp [binary.MaxVarintLen64]byte
+	out := make([]byte, 0, 64)
+	switch e.codec {
+	case RLE:
+		for i := 0; i < blockSize; {
+			j := i + 1
+			for j < blockSize && src[j] == src[i] {
+				j++
+			}
+
+			out = append(out, tmp[:binary.PutUvarint(tmp[:], src[i])]...)
+			out = append(out, tmp[:binary.PutUvarint(tmp[:], uint64(j-i))]...)
+			i = j
+		}
+	default:
+		// Trailing repeated values are omitted, the decoder carries the last value over
+		last := blockSize - 1
+		for last > 0 && src[last] == src[last-1] {
+			last--
+		}
+
+		out = append(out, tmp[:binary.PutUvarint(tmp[:], src[0])]...)
+		for i := 1; i <= last; i++ {
+			out = append(out, tmp[:binary.PutVarint(tmp[:], int64(src[i]-src[i-1]))]...)
+		}
+	}
+
+	// Copy the block so that it does not retain any excess capacity
+	e.blocks[block] = append(make([]byte, 0, len(out)), out...)
+}
+
+// compact clears the values of the rows of the chunk which are not in the fill list and
+// encodes their blocks again, so that a block without values is released and the others
+// compress better.
+func (e *encoded) compact(chunk commit.Chunk, fill bitmap.Bitmap) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for block := int(chunk.Min() >> blockShift); block <= int(chunk.Max()>>blockShift) && block < len(e.blocks); block++ {
+		if e.blocks[block] == nil {
+			continue
+		}
+
+		changed := false
+		e.decode(block, &cursor.values)
+		for i, v := range cursor.values {
+			if v != 0 && !fill.Contains(uint32(block<<blockShift+i)) {
+				cursor.values[i], changed = 0, true
+			}
+		}
+
+		if changed {
+			e.encode(block, &cursor.values)
+		}
+	}
+}
+
+// --------------------------- Block Cursor ----------------------------
+
+// cursors is a pool of block cursors, since they are relatively large
+var cursors = sync.Pool{
+	New: func() interface{} {
+		return &blockCursor{block: -1}
+	},
+}
+
+// blockCursor represents a cursor which decodes the values one block at a time, in
+// order to efficiently read a sequence of values.
+type blockCursor struct {
+	block  int               // The currently decoded block
+	dirty  bool              // Whether the block was modified
+	values [blockSize]uint64 // The decoded values of the block
+}
+
+// acquireCursor acquires a new block cursor from the pool
+func acquireCursor() *blockCursor {
+	return cursors.Get().(*blockCursor)
+}
+
+// release releases the cursor back to the pool
+func (c *blockCursor) release() {
+	c.block = -1
+	c.dirty = false
+	cursors.Put(c)
+}
+
+// seek decodes the block containing the index, if not already decoded
+func (c *blockCursor) seek(e *encoded, idx uint32) {
+	if block := int(idx >> blockShift); block != c.block {
+		c.flush(e)
+		e.decode(block, &c.values)
+		c.block = block
+	}
+}
+
+// load loads the value at the specified index
+func (c *blockCursor) load(e *encoded, idx uint32) uint64 {
+	c.seek(e, idx)
+	return c.values[idx&blockMask]
+}
+
+// store stores the value at the specified index, the block is encoded once flushed
+func (c *blockCursor) store(e *encoded, idx uint32, value uint64) {
+	c.seek(e, idx)
+	c.values[idx&blockMask] = value
+	c.dirty = true
+}
+
+// flush encodes the current block back, if it was modified
+func (c *blockCursor) flush(e *encoded) {
+	if c.dirty {
+		e.encode(c.block, &c.values)
+		c.dirty = false
+	}
+}
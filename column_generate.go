@@ -4,7 +4,7 @@
 package column
 
 import (
-	"fmt"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -17,20 +17,42 @@ type number = generic.Number
 
 // numberColumn represents a generic column
 type numberColumn struct {
-	fill bitmap.Bitmap // The fill-list
-	data []number      // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []number      // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeNumbers creates a new vector for Numbers
-func makeNumbers() Column {
-	return &numberColumn{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]number, 0, 64),
+func makeNumbers(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &numberColumn{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]number, 0, 64),
+		merger: merger{config.merge},
 	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
+	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *numberColumn) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -47,8 +69,85 @@ func (c *numberColumn) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *numberColumn) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]number, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *numberColumn) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(number(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *numberColumn) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(number(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *numberColumn) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(number(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *numberColumn) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *numberColumn) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -57,9 +156,15 @@ func (c *numberColumn) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Number()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Number()
 			c.data[r.Offset] = value
 			r.SwapNumber(value)
 
@@ -69,6 +174,84 @@ func (c *numberColumn) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *numberColumn) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), numberToBits(r.Number()))
+
+		case commit.Add:
+			value := r.Number()
+			if c.fill.Contains(r.Index()) {
+				value += numberFromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), numberToBits(value))
+			r.SwapNumber(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *numberColumn) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the number of values the column is able to store
+func (c *numberColumn) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *numberColumn) at(idx uint32) number {
+	if c.enc != nil {
+		return numberFromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// numberToBits converts the number into its raw bits
+func numberToBits(v number) (bits uint64) {
+	*(*number)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// numberFromBits converts the raw bits back into the number
+func numberFromBits(bits uint64) number {
+	return *(*number)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *numberColumn) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(number(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *numberColumn) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -82,47 +265,54 @@ func (c *numberColumn) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *numberColumn) Value(idx uint32) (v interface{}, ok bool) {
 	v = number(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a number value at a specified index
 func (c *numberColumn) load(idx uint32) (v number, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = number(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = number(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *numberColumn) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *numberColumn) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *numberColumn) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *numberColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v number) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -131,7 +321,14 @@ func (c *numberColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predica
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *numberColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v number) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -140,15 +337,118 @@ func (c *numberColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *numberColumn) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v number) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *numberColumn) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v number) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(numberFromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *numberColumn) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v number) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & numberRangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *numberColumn) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// numberRangeMask returns the mask of the 64 values which are within the inclusive range
+func numberRangeMask(values []number, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *numberColumn) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutNumber(idx, numberFromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutNumber(idx, c.data[idx])
 	})
@@ -167,14 +467,15 @@ func (s numberReader) Get() (number, bool) {
 
 // numberReaderFor creates a new number reader
 func numberReaderFor(txn *Txn, columnName string) numberReader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*numberColumn)(nil), false
+	if exists {
+		reader, ok = column.Column.(*numberColumn)
 	}
 
-	reader, ok := column.Column.(*numberColumn)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, number(0)))
+		txn.misuse(columnName, exists, "number")
+		reader = makeNumbers().(*numberColumn)
 	}
 
 	return numberReader{
@@ -206,3 +507,42 @@ func (txn *Txn) Number(columnName string) numberWriter {
 		writer:       txn.bufferFor(columnName),
 	}
 }
+
+// Numbers returns an iterator over the values of a number column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Numbers(columnName string) func(yield func(number) bool) {
+	reader := numberReaderFor(txn, columnName)
+	return func(yield func(number) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// NumberSlice returns a read-only view over the values of a number column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) NumberSlice(columnName string) ([]number, bitmap.Bitmap) {
+	var values *numberColumn
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*numberColumn)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "number")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
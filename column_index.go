@@ -4,8 +4,9 @@
 package column
 
 import (
-	"fmt"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -36,9 +37,11 @@ type computed interface {
 
 // columnIndex represents the index implementation
 type columnIndex struct {
-	fill bitmap.Bitmap     // The fill list for the column
-	name string            // The name of the target column
-	rule func(Reader) bool // The rule to apply when building the index
+	fill    bitmap.Bitmap     // The fill list for the column
+	name    string            // The name of the target column
+	rule    func(Reader) bool // The rule to apply when building the index
+	scope   *column           // The column or index the rows must be in, for partial indexes
+	restore uint32            // Whether the bitmap is being restored from a snapshot
 }
 
 // newIndex creates a new bitmap index column.
@@ -55,6 +58,11 @@ func (c *columnIndex) Grow(idx uint32) {
 	c.fill.Grow(idx)
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnIndex) Shrink(size uint32) {
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
 // Column returns the target name of the column on which this index should apply.
 func (c *columnIndex) Column() string {
 	return c.name
@@ -66,12 +74,15 @@ func (c *columnIndex) Apply(r *commit.Reader) {
 	// Index can only be updated based on the final stored value, so we can only work
 	// with put operations here. The trick is to update the final value after applying
 	// on the actual column.
+	restoring := atomic.LoadUint32(&c.restore) == 1
 	for r.Next() {
 		switch r.Type {
 		case commit.Put, commit.Add:
-			if c.rule(r) {
+			switch {
+			case restoring: // The bitmap is restored as is
+			case c.inScope(r.Index()) && c.rule(r):
 				c.fill.Set(uint32(r.Offset))
-			} else {
+			default:
 				c.fill.Remove(uint32(r.Offset))
 			}
 		case commit.Delete:
@@ -80,6 +91,31 @@ func (c *columnIndex) Apply(r *commit.Reader) {
 	}
 }
 
+// inScope returns whether the row is in the scope of the index, which is always the case
+// unless this is a partial index.
+func (c *columnIndex) inScope(idx uint32) bool {
+	return c.scope == nil || c.scope.Contains(idx)
+}
+
+// restoring sets whether the bitmap is being restored from a snapshot, in which case the
+// rule is not applied on the restored values.
+func (c *columnIndex) restoring(enabled bool) {
+	if enabled {
+		atomic.StoreUint32(&c.restore, 1)
+	} else {
+		atomic.StoreUint32(&c.restore, 0)
+	}
+}
+
+// load sets the bits of the bitmap which were written in a snapshot for a chunk
+func (c *columnIndex) load(r *commit.Reader) {
+	for r.Next() {
+		if r.Type == commit.PutTrue {
+			c.fill.Set(uint32(r.Offset))
+		}
+	}
+}
+
 // Value retrieves a value at a specified index.
 func (c *columnIndex) Value(idx uint32) (v interface{}, ok bool) {
 	if idx < uint32(len(c.fill))<<6 {
@@ -98,11 +134,165 @@ func (c *columnIndex) Index() *bitmap.Bitmap {
 	return &c.fill
 }
 
+// usage returns the memory used by the column
+func (c *columnIndex) usage() ColumnUsage {
+	return ColumnUsage{
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *columnIndex) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	dst.PutBitmap(commit.PutTrue, chunk, c.fill)
 }
 
+// --------------------------- Partial Index ----------------------------
+
+// columnScope represents a hook of a partial index on the column of its scope. Whenever
+// rows enter or leave the scope, the rule of the index is evaluated again for them with
+// the current values of the target column.
+type columnScope struct {
+	index  *columnIndex // The partial index to update
+	target *column      // The target column of the index
+}
+
+// Grow does nothing, as the hook does not store anything
+func (c *columnScope) Grow(idx uint32) {}
+
+// Apply evaluates the partial index again for the rows which were changed in the scope.
+func (c *columnScope) Apply(r *commit.Reader) {
+	if atomic.LoadUint32(&c.index.restore) == 1 {
+		return
+	}
+
+	buffer := commit.NewBuffer(64)
+	for r.Next() {
+		idx := r.Index()
+		if v, ok := c.target.Value(idx); ok && c.index.inScope(idx) {
+			buffer.PutAny(commit.Put, idx, v)
+		} else {
+			c.index.fill.Remove(idx)
+		}
+	}
+
+	reader := commit.NewReader()
+	reader.Seek(buffer)
+	c.index.Apply(reader)
+}
+
+// Value returns nothing, as the hook does not store anything
+func (c *columnScope) Value(idx uint32) (interface{}, bool) {
+	return nil, false
+}
+
+// Contains returns false, as the hook does not store anything
+func (c *columnScope) Contains(idx uint32) bool {
+	return false
+}
+
+// Index returns the bitmap of the partial index
+func (c *columnScope) Index() *bitmap.Bitmap {
+	return &c.index.fill
+}
+
+// Snapshot does nothing, as the hook does not store anything
+func (c *columnScope) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {}
+
+// --------------------------- Expression Index ----------------------------
+
+// columnExpr represents an index computed from the values of several columns of a row. It
+// is attached to each of the source columns, and whenever one of them changes the rule is
+// evaluated again for the changed rows with their current values.
+type columnExpr struct {
+	fill    bitmap.Bitmap  // The fill list for the index
+	owner   *Collection    // The collection, used to read the rows
+	sources []*column      // The source columns of the index
+	rule    func(Row) bool // The rule to apply when building the index
+}
+
+// newExpr creates a new expression index column.
+func newExpr(indexName string, owner *Collection, sources []*column, rule func(Row) bool) *column {
+	return columnFor(indexName, &columnExpr{
+		fill:    make(bitmap.Bitmap, 0, 4),
+		owner:   owner,
+		sources: sources,
+		rule:    rule,
+	})
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnExpr) Grow(idx uint32) {
+	c.fill.Grow(idx)
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnExpr) Shrink(size uint32) {
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// Column returns the name of the first source column of the index.
+func (c *columnExpr) Column() string {
+	return c.sources[0].name
+}
+
+// Apply evaluates the rule again for the rows which were changed in a source column.
+func (c *columnExpr) Apply(r *commit.Reader) {
+	txn := c.owner.txns.acquire(c.owner)
+	defer c.owner.txns.release(txn)
+	defer txn.rollback()
+
+	row := Row{txn}
+	for r.Next() {
+		txn.cursor = r.Index()
+		if c.present(txn.cursor) && c.rule(row) {
+			c.fill.Set(txn.cursor)
+		} else {
+			c.fill.Remove(txn.cursor)
+		}
+	}
+}
+
+// present returns whether any of the source columns has a value for the row. Once a row
+// is deleted, all of its values are removed.
+func (c *columnExpr) present(idx uint32) bool {
+	for _, v := range c.sources {
+		if v.Contains(idx) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value retrieves a value at a specified index.
+func (c *columnExpr) Value(idx uint32) (v interface{}, ok bool) {
+	if idx < uint32(len(c.fill))<<6 {
+		v, ok = c.fill.Contains(idx), true
+	}
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnExpr) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnExpr) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// usage returns the memory used by the column
+func (c *columnExpr) usage() ColumnUsage {
+	return ColumnUsage{
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnExpr) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	dst.PutBitmap(commit.PutTrue, chunk, c.fill)
+}
+
 // --------------------------- Key ----------------------------
 
 // columnKey represents the primary key column implementation
@@ -146,6 +336,15 @@ func (c *columnKey) Apply(r *commit.Reader) {
 	}
 }
 
+// usage returns the memory used by the column
+func (c *columnKey) usage() ColumnUsage {
+	usage := c.columnString.usage()
+	c.lock.RLock()
+	usage.Dictionary = len(c.seek) * (int(unsafe.Sizeof("")) + 4)
+	c.lock.RUnlock()
+	return usage
+}
+
 // OffsetOf returns the offset for a particular value
 func (c *columnKey) OffsetOf(v string) (uint32, bool) {
 	c.lock.RLock()
@@ -156,13 +355,21 @@ func (c *columnKey) OffsetOf(v string) (uint32, bool) {
 
 // slice accessor for keys
 type keySlice struct {
+	txn    *Txn
 	cursor *uint32
 	writer *commit.Buffer
 	reader *columnKey
 }
 
-// Set sets the value at the current transaction index
+// Set sets the value at the current transaction index, unless the key is already taken by
+// another row, in which case the transaction is aborted with ErrDuplicateKey.
 func (s keySlice) Set(value string) {
+	if s.txn != nil {
+		if err := s.txn.claimKey(value, *s.cursor); err != nil {
+			s.txn.abort(err)
+			return
+		}
+	}
 	s.writer.PutString(commit.Put, *s.cursor, value)
 }
 
@@ -174,10 +381,12 @@ func (s keySlice) Get() (string, bool) {
 // Enum returns a enumerable column accessor
 func (txn *Txn) Key() keySlice {
 	if txn.owner.pk == nil {
-		panic(fmt.Errorf("column: primary key column does not exist"))
+		txn.abort(errorOf(ErrColumnNotFound, "column: primary key column does not exist"))
+		return keySlice{cursor: &txn.cursor, writer: txn.bufferFor(""), reader: makeKey().(*columnKey)}
 	}
 
 	return keySlice{
+		txn:    txn,
 		cursor: &txn.cursor,
 		writer: txn.bufferFor(txn.owner.pk.name),
 		reader: txn.owner.pk,
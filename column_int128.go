@@ -0,0 +1,366 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Int128 represents a signed 128-bit integer in two's complement, for the values which
+// overflow an int64 such as token amounts.
+type Int128 struct {
+	Hi int64  // The upper 64 bits, including the sign
+	Lo uint64 // The lower 64 bits
+}
+
+// Int128Of converts an int64 to a 128-bit integer
+func Int128Of(v int64) Int128 {
+	return Int128{Hi: v >> 63, Lo: uint64(v)}
+}
+
+// Int128FromBig converts a big integer to a 128-bit integer, and returns false if it does
+// not fit into 128 bits.
+func Int128FromBig(v *big.Int) (Int128, bool) {
+	if v.BitLen() > 127 && !(v.Sign() < 0 && v.BitLen() == 128 && v.TrailingZeroBits() == 127) {
+		return Int128{}, false
+	}
+
+	// Compute the two's complement of the magnitude for the negative values
+	abs := new(big.Int).Abs(v)
+	lo := new(big.Int).And(abs, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	out := Int128{Hi: int64(new(big.Int).Rsh(abs, 64).Uint64()), Lo: lo}
+	if v.Sign() < 0 {
+		out = out.neg()
+	}
+	return out, true
+}
+
+// ParseInt128 parses a 128-bit integer from its decimal representation
+func ParseInt128(s string) (Int128, error) {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return Int128{}, fmt.Errorf("column: unable to parse '%s' as an int128", s)
+	}
+
+	out, ok := Int128FromBig(v)
+	if !ok {
+		return Int128{}, fmt.Errorf("column: unable to parse '%s' as an int128, value is out of range", s)
+	}
+	return out, nil
+}
+
+// Big returns the value as a big integer
+func (v Int128) Big() *big.Int {
+	out := new(big.Int).SetInt64(v.Hi)
+	out.Lsh(out, 64)
+	return out.Add(out, new(big.Int).SetUint64(v.Lo))
+}
+
+// String returns the decimal representation of the value
+func (v Int128) String() string {
+	return v.Big().String()
+}
+
+// Cmp compares the value with another one, and returns -1, 0 or +1 if it is respectively
+// less than, equal to or greater than the other value.
+func (v Int128) Cmp(other Int128) int {
+	switch {
+	case v.Hi < other.Hi || v.Hi == other.Hi && v.Lo < other.Lo:
+		return -1
+	case v == other:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// Add returns the sum of the value and another one, and whether it overflowed 128 bits
+func (v Int128) Add(other Int128) (Int128, bool) {
+	lo := v.Lo + other.Lo
+	carry := int64(0)
+	if lo < v.Lo {
+		carry = 1
+	}
+
+	out := Int128{Hi: v.Hi + other.Hi + carry, Lo: lo}
+	overflow := (v.Hi >= 0) == (other.Hi >= 0) && (out.Hi >= 0) != (v.Hi >= 0)
+	return out, overflow
+}
+
+// neg returns the two's complement of the value
+func (v Int128) neg() Int128 {
+	out, _ := Int128{Hi: ^v.Hi, Lo: ^v.Lo}.Add(Int128{Lo: 1})
+	return out
+}
+
+// Encode encodes the value into 16 bytes, in big-endian order
+func (v Int128) Encode() []byte {
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[:8], uint64(v.Hi))
+	binary.BigEndian.PutUint64(out[8:], v.Lo)
+	return out
+}
+
+// int128Of decodes a value encoded into 16 bytes
+func int128Of(b []byte) (Int128, bool) {
+	if len(b) != 16 {
+		return Int128{}, false
+	}
+
+	return Int128{
+		Hi: int64(binary.BigEndian.Uint64(b[:8])),
+		Lo: binary.BigEndian.Uint64(b[8:]),
+	}, true
+}
+
+// --------------------------- Int128 Column ----------------------------
+
+// columnInt128 represents a column of 128-bit integers
+type columnInt128 struct {
+	fill bitmap.Bitmap // The fill-list
+	data []Int128      // The actual values
+}
+
+// makeInt128s creates a new 128-bit integer column
+func makeInt128s() Column {
+	return &columnInt128{
+		fill: make(bitmap.Bitmap, 0, 4),
+		data: make([]Int128, 0, 64),
+	}
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnInt128) Grow(idx uint32) {
+	if idx < uint32(len(c.data)) {
+		return
+	}
+
+	if idx < uint32(cap(c.data)) {
+		c.fill.Grow(idx)
+		c.data = c.data[:idx+1]
+		return
+	}
+
+	c.fill.Grow(idx)
+	clone := make([]Int128, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnInt128) Shrink(size uint32) {
+	if uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]Int128, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnInt128) Apply(r *commit.Reader) {
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			if v, ok := int128Of(r.Bytes()); ok {
+				c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+				c.data[r.Offset] = v
+			}
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// usage returns the memory used by the column
+func (c *columnInt128) usage() ColumnUsage {
+	return ColumnUsage{
+		Data:  cap(c.data) * 16,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnInt128) Value(idx uint32) (v interface{}, ok bool) {
+	if v, ok := c.LoadInt128(idx); ok {
+		return v, true
+	}
+	return nil, false
+}
+
+// LoadInt128 retrieves a value at a specified index
+func (c *columnInt128) LoadInt128(idx uint32) (Int128, bool) {
+	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
+		return c.data[idx], true
+	}
+	return Int128{}, false
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnInt128) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnInt128) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// filterRange filters down the values within the inclusive range
+func (c *columnInt128) filterRange(offset uint32, index bitmap.Bitmap, lo, hi Int128) {
+	andFill(index, c.fill, offset)
+	size := uint32(len(c.data))
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < size && c.data[idx].Cmp(lo) >= 0 && c.data[idx].Cmp(hi) <= 0
+	})
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnInt128) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	chunk.Range(c.fill, func(idx uint32) {
+		dst.PutBytes(commit.Put, idx, c.data[idx].Encode())
+	})
+}
+
+// int128Reader represents a read-only accessor for 128-bit integers
+type int128Reader struct {
+	cursor *uint32
+	reader *columnInt128
+}
+
+// Get loads the value at the current transaction cursor
+func (s int128Reader) Get() (Int128, bool) {
+	return s.reader.LoadInt128(*s.cursor)
+}
+
+// int128ReaderFor creates a new 128-bit integer reader
+func int128ReaderFor(txn *Txn, columnName string) int128Reader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*columnInt128)(nil), false
+	if exists {
+		reader, ok = column.Column.(*columnInt128)
+	}
+
+	if !ok {
+		txn.misuse(columnName, exists, "int128")
+		reader = makeInt128s().(*columnInt128)
+	}
+
+	return int128Reader{
+		cursor: &txn.cursor,
+		reader: reader,
+	}
+}
+
+// int128Writer represents read-write accessor for 128-bit integers
+type int128Writer struct {
+	int128Reader
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s int128Writer) Set(value Int128) {
+	s.writer.PutBytes(commit.Put, *s.cursor, value.Encode())
+}
+
+// Int128 returns a 128-bit integer column accessor
+func (txn *Txn) Int128(columnName string) int128Writer {
+	return int128Writer{
+		int128Reader: int128ReaderFor(txn, columnName),
+		writer:       txn.bufferFor(columnName),
+	}
+}
+
+// int128Range represents the inclusive bounds of a 128-bit integer filter
+type int128Range struct {
+	lo, hi Int128
+}
+
+var (
+	minInt128 = Int128{Hi: -1 << 63}
+	maxInt128 = Int128{Hi: 1<<63 - 1, Lo: ^uint64(0)}
+)
+
+// WithInt128Greater filters down the values of a 128-bit integer column to the ones greater
+// than the specified value.
+func (txn *Txn) WithInt128Greater(column string, value Int128) *Txn {
+	if value == maxInt128 {
+		return txn.WithInt128Between(column, maxInt128, minInt128)
+	}
+
+	lo, _ := value.Add(Int128Of(1))
+	return txn.WithInt128Between(column, lo, maxInt128)
+}
+
+// WithInt128Less filters down the values of a 128-bit integer column to the ones less than
+// the specified value.
+func (txn *Txn) WithInt128Less(column string, value Int128) *Txn {
+	if value == minInt128 {
+		return txn.WithInt128Between(column, maxInt128, minInt128)
+	}
+
+	hi, _ := value.Add(Int128Of(-1))
+	return txn.WithInt128Between(column, minInt128, hi)
+}
+
+// WithInt128Between filters down the values of a 128-bit integer column to the ones between
+// min and max, inclusive.
+func (txn *Txn) WithInt128Between(column string, min, max Int128) *Txn {
+	txn.filter(filterInt128, column, int128Range{lo: min, hi: max})
+	return txn
+}
+
+// withInt128 filters down the current selection to the values within the inclusive range
+func (txn *Txn) withInt128(column string, bounds int128Range) {
+	defer txn.trace("WithInt128Between", column, false)()
+	var values *columnInt128
+	if c, ok := txn.columnAt(column); ok {
+		values, _ = c.Column.(*columnInt128)
+	}
+
+	if values == nil {
+		txn.index.Clear()
+		return
+	}
+
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		values.filterRange(offset, index, bounds.lo, bounds.hi)
+	})
+}
+
+// SumInt128 computes the exact sum of a 128-bit integer column over the result set. Since
+// the sum may overflow 128 bits, it is returned as a big integer.
+func (txn *Txn) SumInt128(columnName string) (*big.Int, error) {
+	var values *columnInt128
+	if c, ok := txn.columnAt(columnName); ok {
+		values, _ = c.Column.(*columnInt128)
+	}
+
+	if values == nil {
+		return nil, errorOf(ErrTypeMismatch, "column: unable to sum '%s', column is not of type int128", columnName)
+	}
+
+	// Sum in 128 bits, and only carry the partial sum over when it would overflow
+	out := new(big.Int)
+	var sum Int128
+	err := txn.Range(func(idx uint32) {
+		if v, ok := values.LoadInt128(idx); ok {
+			next, overflow := sum.Add(v)
+			if overflow {
+				out.Add(out, sum.Big())
+				next = v
+			}
+			sum = next
+		}
+	})
+	return out.Add(out, sum.Big()), err
+}
@@ -0,0 +1,395 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// ipNetwork represents a network of IP addresses, with the IPv4 addresses mapped into IPv6
+type ipNetwork struct {
+	addr [16]byte // The address of the network, masked to its prefix
+	bits int      // The length of the prefix, in bits
+}
+
+// networkOf parses a network in the CIDR notation, such as "10.0.0.0/8"
+func networkOf(cidr string) (ipNetwork, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ipNetwork{}, err
+	}
+
+	var out ipNetwork
+	ones, bits := network.Mask.Size()
+	copy(out.addr[:], network.IP.To16())
+	out.bits = ones + 128 - bits
+	return out, nil
+}
+
+// contains checks whether the network contains the address
+func (n ipNetwork) contains(addr [16]byte) bool {
+	return maskIP(addr, n.bits) == n.addr
+}
+
+// maskIP keeps the prefix of an address of the specified length, in bits
+func maskIP(addr [16]byte, bits int) (out [16]byte) {
+	for i := 0; i < 16 && bits > 0; i, bits = i+1, bits-8 {
+		if bits >= 8 {
+			out[i] = addr[i]
+		} else {
+			out[i] = addr[i] & byte(0xff<<(8-bits))
+		}
+	}
+	return
+}
+
+// ipOf decodes an address, written either in its text form or as its 16 bytes. The text
+// form is attempted first, which only fails to tell them apart for the IPv6 addresses made
+// of printable characters, none of which are allocated.
+func ipOf(b []byte) (out [16]byte, ok bool) {
+	ip := net.ParseIP(string(b))
+	switch {
+	case ip != nil:
+		copy(out[:], ip.To16())
+		return out, true
+	case len(b) == net.IPv6len:
+		copy(out[:], b)
+		return out, true
+	case len(b) == net.IPv4len:
+		copy(out[:], net.IP(b).To16())
+		return out, true
+	default:
+		return out, false
+	}
+}
+
+// isIPv4 checks whether the address is an IPv4 address mapped into IPv6
+func isIPv4(addr [16]byte) bool {
+	return net.IP(addr[:]).To4() != nil
+}
+
+// WithPrefixIndex indexes the rows of an IP column by the prefix of their address, of the
+// specified lengths for the IPv4 and IPv6 addresses, such as 24 and 64 bits. The CIDR filters
+// then only look at the rows of the prefixes within the network, rather than at every row.
+func WithPrefixIndex(ipv4, ipv6 int) ColumnOption {
+	return func(c *columnConfig) {
+		c.prefix = [2]int{ipv4, ipv6}
+	}
+}
+
+// --------------------------- IP Column ----------------------------
+
+// columnIP represents a column of IPv4 and IPv6 addresses, stored in 16 bytes each
+type columnIP struct {
+	fill     bitmap.Bitmap              // The fill-list
+	data     [][16]byte                 // The addresses, with the IPv4 ones mapped into IPv6
+	prefix   [2]int                     // The lengths of the indexed prefixes, if any
+	lock     sync.Mutex                 // The lock protecting the prefix index
+	prefixes map[[16]byte]bitmap.Bitmap // The rows by the prefix of their address
+}
+
+// makeIPs creates a new IP column
+func makeIPs(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &columnIP{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([][16]byte, 0, 64),
+		prefix: config.prefix,
+	}
+
+	if column.indexed() {
+		column.prefixes = make(map[[16]byte]bitmap.Bitmap, 64)
+	}
+	return column
+}
+
+// options returns the options the column was created with
+func (c *columnIP) options() []ColumnOption {
+	if c.indexed() {
+		return []ColumnOption{WithPrefixIndex(c.prefix[0], c.prefix[1])}
+	}
+	return nil
+}
+
+// indexed checks whether the rows are indexed by the prefix of their address
+func (c *columnIP) indexed() bool {
+	return c.prefix[0] > 0 || c.prefix[1] > 0
+}
+
+// prefixOf returns the length of the prefix by which an address is indexed, in bits
+func (c *columnIP) prefixOf(addr [16]byte) int {
+	if isIPv4(addr) {
+		return 96 + c.prefix[0]
+	}
+	return c.prefix[1]
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnIP) Grow(idx uint32) {
+	if idx < uint32(len(c.data)) {
+		return
+	}
+
+	if idx < uint32(cap(c.data)) {
+		c.fill.Grow(idx)
+		c.data = c.data[:idx+1]
+		return
+	}
+
+	c.fill.Grow(idx)
+	clone := make([][16]byte, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnIP) Shrink(size uint32) {
+	if uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([][16]byte, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnIP) Apply(r *commit.Reader) {
+	for r.Next() {
+		idx := r.Index()
+		switch r.Type {
+		case commit.Put:
+			if c.indexed() && c.fill.Contains(idx) {
+				c.unindex(idx)
+			}
+
+			// Addresses which can not be parsed are stored as missing values
+			addr, ok := ipOf(r.Bytes())
+			if !ok {
+				c.fill.Remove(idx)
+				continue
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			c.data[r.Offset] = addr
+			if c.indexed() {
+				c.index(idx)
+			}
+
+		case commit.Delete:
+			if c.indexed() && c.fill.Contains(idx) {
+				c.unindex(idx)
+			}
+			c.fill.Remove(idx)
+		}
+	}
+}
+
+// index adds the row to the prefix index
+func (c *columnIP) index(idx uint32) {
+	key := maskIP(c.data[idx], c.prefixOf(c.data[idx]))
+	c.lock.Lock()
+	rows := c.prefixes[key]
+	rows.Set(idx)
+	c.prefixes[key] = rows
+	c.lock.Unlock()
+}
+
+// unindex removes the row from the prefix index
+func (c *columnIP) unindex(idx uint32) {
+	key := maskIP(c.data[idx], c.prefixOf(c.data[idx]))
+	c.lock.Lock()
+	rows := c.prefixes[key]
+	if rows.Remove(idx); rows.Count() == 0 {
+		delete(c.prefixes, key)
+	} else {
+		c.prefixes[key] = rows
+	}
+	c.lock.Unlock()
+}
+
+// usage returns the memory used by the column
+func (c *columnIP) usage() ColumnUsage {
+	index := 0
+	c.lock.Lock()
+	for _, rows := range c.prefixes {
+		index += 16 + sizeOfBitmap(rows)
+	}
+	c.lock.Unlock()
+
+	return ColumnUsage{
+		Data:  cap(c.data) * 16,
+		Index: sizeOfBitmap(c.fill) + index,
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnIP) Value(idx uint32) (v interface{}, ok bool) {
+	if ip, ok := c.LoadIP(idx); ok {
+		return ip, true
+	}
+	return nil, false
+}
+
+// LoadIP retrieves the address at a specified index, in its 4-byte form for IPv4
+func (c *columnIP) LoadIP(idx uint32) (net.IP, bool) {
+	if idx >= uint32(len(c.data)) || !c.fill.Contains(idx) {
+		return nil, false
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, c.data[idx][:])
+	if v4 := ip.To4(); v4 != nil {
+		return v4, true
+	}
+	return ip, true
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnIP) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnIP) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// candidates returns the rows whose prefix may be within the network, and whether all of
+// them are. This returns false if the rows are not indexed by their prefix.
+func (c *columnIP) candidates(network ipNetwork, dst *bitmap.Bitmap) (exact bool, ok bool) {
+	if !c.indexed() {
+		return false, false
+	}
+
+	// The rows of a prefix within the network are all in the network, while the ones of a
+	// prefix shorter than the network still need to be scanned
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	exact = true
+	for key, rows := range c.prefixes {
+		switch bits := c.prefixOf(key); {
+		case network.bits <= bits && network.contains(key):
+			dst.Or(rows)
+		case network.bits > bits && maskIP(network.addr, bits) == key:
+			dst.Or(rows)
+			exact = false
+		}
+	}
+	return exact, true
+}
+
+// filterNetwork filters down the rows whose address is within the network
+func (c *columnIP) filterNetwork(offset uint32, index bitmap.Bitmap, network ipNetwork) {
+	andFill(index, c.fill, offset)
+	size := uint32(len(c.data))
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < size && network.contains(c.data[idx])
+	})
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnIP) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	chunk.Range(c.fill, func(idx uint32) {
+		dst.PutBytes(commit.Put, idx, c.data[idx][:])
+	})
+}
+
+// ipReader represents a read-only accessor for IP addresses
+type ipReader struct {
+	cursor *uint32
+	reader *columnIP
+}
+
+// Get loads the value at the current transaction cursor
+func (s ipReader) Get() (net.IP, bool) {
+	return s.reader.LoadIP(*s.cursor)
+}
+
+// ipReaderFor creates a new IP reader
+func ipReaderFor(txn *Txn, columnName string) ipReader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*columnIP)(nil), false
+	if exists {
+		reader, ok = column.Column.(*columnIP)
+	}
+
+	if !ok {
+		txn.misuse(columnName, exists, "ip")
+		reader = makeIPs().(*columnIP)
+	}
+
+	return ipReader{
+		cursor: &txn.cursor,
+		reader: reader,
+	}
+}
+
+// ipWriter represents read-write accessor for IP addresses
+type ipWriter struct {
+	ipReader
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s ipWriter) Set(value net.IP) {
+	s.writer.PutBytes(commit.Put, *s.cursor, value.To16())
+}
+
+// IP returns an IP column accessor
+func (txn *Txn) IP(columnName string) ipWriter {
+	return ipWriter{
+		ipReader: ipReaderFor(txn, columnName),
+		writer:   txn.bufferFor(columnName),
+	}
+}
+
+// WithinCIDR filters down the rows whose address in the specified IP column is within the
+// network, in the CIDR notation such as "10.0.0.0/8" or "2001:db8::/32". If the network can
+// not be parsed, the transaction is aborted with an error.
+func (txn *Txn) WithinCIDR(column, cidr string) *Txn {
+	network, err := networkOf(cidr)
+	if err != nil {
+		txn.err = fmt.Errorf("column: unable to parse network, %w", err)
+		return txn
+	}
+
+	txn.filter(filterCIDR, column, network)
+	return txn
+}
+
+// withinCIDR filters down the current selection to the addresses within the network
+func (txn *Txn) withinCIDR(column string, network ipNetwork) {
+	var ips *columnIP
+	if c, ok := txn.columnAt(column); ok {
+		ips, _ = c.Column.(*columnIP)
+	}
+
+	if ips == nil {
+		defer txn.trace("WithinCIDR", column, false)()
+		txn.index.Clear()
+		return
+	}
+
+	// Narrow down the selection to the rows of the prefixes within the network
+	var rows bitmap.Bitmap
+	exact, indexed := ips.candidates(network, &rows)
+	defer txn.trace("WithinCIDR", column, indexed)()
+	if indexed {
+		txn.index.And(rows)
+	}
+
+	if !exact {
+		txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+			ips.filterNetwork(offset, index, network)
+		})
+	}
+}
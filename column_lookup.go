@@ -0,0 +1,146 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// columnLookup represents an index which maps each value of the target column to the rows
+// which hold it, so that the rows with a specific value are found without scanning the
+// column, similarly to the lookup table of the primary key.
+type columnLookup struct {
+	lock   sync.RWMutex             // Lock to protect the lookup table
+	fill   bitmap.Bitmap            // The rows which have a value in the target column
+	name   string                   // The name of the target column
+	target Column                   // The target column, used to decode the values
+	seek   map[interface{}][]uint32 // The rows of each value
+	values map[uint32]interface{}   // The value of each row
+}
+
+// newLookup creates a new lookup index column.
+func newLookup(indexName, columnName string, target Column) *column {
+	return columnFor(indexName, &columnLookup{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		name:   columnName,
+		target: target,
+		seek:   make(map[interface{}][]uint32, 64),
+		values: make(map[uint32]interface{}, 64),
+	})
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnLookup) Grow(idx uint32) {
+	c.lock.Lock()
+	c.fill.Grow(idx)
+	c.lock.Unlock()
+}
+
+// Column returns the target name of the column on which this index should apply.
+func (c *columnLookup) Column() string {
+	return c.name
+}
+
+// Apply applies a set of operations to the column. Since the additions are swapped with
+// the resulting values by the numeric columns, they are handled as puts.
+func (c *columnLookup) Apply(r *commit.Reader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for r.Next() {
+		idx := r.Index()
+		switch r.Type {
+		case commit.Put, commit.Add:
+			c.remove(idx)
+			value := valueOf(c.target, r)
+			c.fill.Set(idx)
+			c.values[idx] = value
+			c.seek[value] = append(c.seek[value], idx)
+		case commit.Delete:
+			c.remove(idx)
+		}
+	}
+}
+
+// remove removes a row from the lookup table, if present
+func (c *columnLookup) remove(idx uint32) {
+	value, ok := c.values[idx]
+	if !ok {
+		return
+	}
+
+	rows := c.seek[value]
+	for i, v := range rows {
+		if v == idx {
+			rows[i] = rows[len(rows)-1]
+			rows = rows[:len(rows)-1]
+			break
+		}
+	}
+
+	if len(rows) == 0 {
+		delete(c.seek, value)
+	} else {
+		c.seek[value] = rows
+	}
+
+	delete(c.values, idx)
+	c.fill.Remove(idx)
+}
+
+// lookupOf returns the lookup index among the indexes of a column, if any
+func lookupOf(columns []*column) *columnLookup {
+	for _, v := range columns[1:] {
+		if lookup, ok := v.Column.(*columnLookup); ok {
+			return lookup
+		}
+	}
+	return nil
+}
+
+// rowsOf sets the rows holding the value in the destination bitmap
+func (c *columnLookup) rowsOf(value interface{}, dst *bitmap.Bitmap) {
+	c.lock.RLock()
+	for _, idx := range c.seek[value] {
+		dst.Set(idx)
+	}
+	c.lock.RUnlock()
+}
+
+// Value retrieves a value at a specified index.
+func (c *columnLookup) Value(idx uint32) (v interface{}, ok bool) {
+	c.lock.RLock()
+	v, ok = c.values[idx]
+	c.lock.RUnlock()
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnLookup) Contains(idx uint32) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnLookup) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// usage returns the memory used by the column
+func (c *columnLookup) usage() ColumnUsage {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return ColumnUsage{
+		Index:      sizeOfBitmap(c.fill),
+		Dictionary: (len(c.seek) + len(c.values)) * int(unsafe.Sizeof(interface{}(nil))+4),
+	}
+}
+
+// Snapshot does nothing, as the index is rebuilt from the target column
+func (c *columnLookup) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {}
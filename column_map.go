@@ -0,0 +1,399 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Various tags of the values of an encoded map
+const (
+	mapString = byte(iota + 1)
+	mapInt
+	mapFloat
+	mapBool
+)
+
+// Map represents a small map of scalar values, stored in a row of a map column. The values
+// are strings, int64, float64 or bool, while the other integer and floating-point values are
+// converted to int64 and float64 respectively.
+type Map map[string]interface{}
+
+// Encode encodes the map with its keys in sorted order, and panics if one of its values is
+// not a scalar.
+func (m Map) Encode() []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var scratch [binary.MaxVarintLen64]byte
+	out := make([]byte, 0, 16*len(m))
+	for _, k := range keys {
+		out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(k)))]...)
+		out = append(out, k...)
+
+		switch v := mapValueOf(m[k]).(type) {
+		case string:
+			out = append(out, mapString)
+			out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(v)))]...)
+			out = append(out, v...)
+		case int64:
+			out = append(out, mapInt)
+			out = append(out, scratch[:binary.PutVarint(scratch[:], v)]...)
+		case float64:
+			binary.BigEndian.PutUint64(scratch[:8], math.Float64bits(v))
+			out = append(out, mapFloat)
+			out = append(out, scratch[:8]...)
+		case bool:
+			out = append(out, mapBool, byte(bit(v)))
+		default:
+			panic(fmt.Errorf("column: unsupported map value (%T) for key '%s'", m[k], k))
+		}
+	}
+	return out
+}
+
+// mapValueOf converts a value to one of the types stored in a map
+func mapValueOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string, int64, float64, bool:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	default:
+		return nil
+	}
+}
+
+// rangeMap iterates over the entries of an encoded map, until the function returns false
+func rangeMap(data string, fn func(key string, value interface{}) bool) {
+	for len(data) > 0 {
+		size, n := uvarintOf(data)
+		key := data[n : n+int(size)]
+		data = data[n+int(size):]
+
+		var value interface{}
+		tag := data[0]
+		data = data[1:]
+		switch tag {
+		case mapString:
+			size, n := uvarintOf(data)
+			value = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case mapInt:
+			v, n := uvarintOf(data)
+			value = int64(v>>1) ^ -int64(v&1) // Zig-zag decoding
+			data = data[n:]
+		case mapFloat:
+			var bits uint64
+			for i := 0; i < 8; i++ {
+				bits = bits<<8 | uint64(data[i])
+			}
+			value = math.Float64frombits(bits)
+			data = data[8:]
+		case mapBool:
+			value = data[0] == 1
+			data = data[1:]
+		}
+
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// uvarintOf decodes an unsigned varint from the string
+func uvarintOf(data string) (v uint64, n int) {
+	for shift := uint(0); n < len(data); shift += 7 {
+		b := data[n]
+		n++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			break
+		}
+	}
+	return
+}
+
+// lookupMap finds the value of a key in an encoded map, without decoding the other values
+func lookupMap(data, key string) (value interface{}, found bool) {
+	rangeMap(data, func(k string, v interface{}) bool {
+		if k == key {
+			value, found = v, true
+		}
+		return !found && k < key
+	})
+	return
+}
+
+// decodeMap decodes an entire map
+func decodeMap(data string) Map {
+	out := make(Map, 4)
+	rangeMap(data, func(k string, v interface{}) bool {
+		out[k] = v
+		return true
+	})
+	return out
+}
+
+// --------------------------- Map Column ----------------------------
+
+// columnMap represents a column of small maps, encoded in their binary form
+type columnMap struct {
+	fill bitmap.Bitmap // The fill-list
+	data []string      // The encoded maps
+}
+
+// makeMaps creates a new map column
+func makeMaps() Column {
+	return &columnMap{
+		fill: make(bitmap.Bitmap, 0, 4),
+		data: make([]string, 0, 64),
+	}
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnMap) Grow(idx uint32) {
+	if idx < uint32(len(c.data)) {
+		return
+	}
+
+	if idx < uint32(cap(c.data)) {
+		c.fill.Grow(idx)
+		c.data = c.data[:idx+1]
+		return
+	}
+
+	c.fill.Grow(idx)
+	clone := make([]string, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnMap) Shrink(size uint32) {
+	if uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]string, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnMap) Apply(r *commit.Reader) {
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			c.data[r.Offset] = string(r.Bytes())
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// compact releases the maps of the deleted rows in the chunk, so they can be
+// garbage-collected. This must be called while the chunk is locked.
+func (c *columnMap) compact(chunk commit.Chunk) {
+	max := chunk.Max() + 1
+	if max > uint32(len(c.data)) {
+		max = uint32(len(c.data))
+	}
+
+	for idx := chunk.Min(); idx < max; idx++ {
+		if !c.fill.Contains(idx) {
+			c.data[idx] = ""
+		}
+	}
+}
+
+// usage returns the memory used by the column
+func (c *columnMap) usage() ColumnUsage {
+	return ColumnUsage{
+		Data:  sizeOfStrings(c.data),
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnMap) Value(idx uint32) (v interface{}, ok bool) {
+	if m, ok := c.LoadMap(idx); ok {
+		return m, true
+	}
+	return nil, false
+}
+
+// LoadMap retrieves the map at a specified index
+func (c *columnMap) LoadMap(idx uint32) (Map, bool) {
+	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
+		return decodeMap(c.data[idx]), true
+	}
+	return nil, false
+}
+
+// LoadKey retrieves the value of a key of the map at a specified index
+func (c *columnMap) LoadKey(idx uint32, key string) (interface{}, bool) {
+	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
+		return lookupMap(c.data[idx], key)
+	}
+	return nil, false
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnMap) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnMap) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// filterKey filters down the rows whose map has the key, with a value matching the predicate
+func (c *columnMap) filterKey(offset uint32, index bitmap.Bitmap, key string, predicate func(v interface{}) bool) {
+	andFill(index, c.fill, offset)
+	size := uint32(len(c.data))
+	index.Filter(func(idx uint32) bool {
+		if idx = offset + idx; idx < size {
+			v, ok := lookupMap(c.data[idx], key)
+			return ok && predicate(v)
+		}
+		return false
+	})
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnMap) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	chunk.Range(c.fill, func(idx uint32) {
+		dst.PutString(commit.Put, idx, c.data[idx])
+	})
+}
+
+// mapReader represents a read-only accessor for maps
+type mapReader struct {
+	cursor *uint32
+	reader *columnMap
+}
+
+// Get loads the value at the current transaction cursor
+func (s mapReader) Get() (Map, bool) {
+	return s.reader.LoadMap(*s.cursor)
+}
+
+// Key loads the value of a key of the map at the current transaction cursor
+func (s mapReader) Key(key string) (interface{}, bool) {
+	return s.reader.LoadKey(*s.cursor, key)
+}
+
+// mapReaderFor creates a new map reader
+func mapReaderFor(txn *Txn, columnName string) mapReader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*columnMap)(nil), false
+	if exists {
+		reader, ok = column.Column.(*columnMap)
+	}
+
+	if !ok {
+		txn.misuse(columnName, exists, "map")
+		reader = makeMaps().(*columnMap)
+	}
+
+	return mapReader{
+		cursor: &txn.cursor,
+		reader: reader,
+	}
+}
+
+// mapWriter represents read-write accessor for maps
+type mapWriter struct {
+	mapReader
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s mapWriter) Set(value Map) {
+	s.writer.PutBytes(commit.Put, *s.cursor, value.Encode())
+}
+
+// Map returns a map column accessor
+func (txn *Txn) Map(columnName string) mapWriter {
+	return mapWriter{
+		mapReader: mapReaderFor(txn, columnName),
+		writer:    txn.bufferFor(columnName),
+	}
+}
+
+// isMap checks whether a column is a map column
+func (txn *Txn) isMap(columnName string) bool {
+	if c, ok := txn.columnAt(columnName); ok {
+		_, ok = c.Column.(*columnMap)
+		return ok
+	}
+	return false
+}
+
+// WithMapKey filters down the rows whose map in the specified column has the key, with a
+// value matching the predicate. The values are strings, int64, float64 or bool.
+func (txn *Txn) WithMapKey(column, key string, predicate func(v interface{}) bool) *Txn {
+	txn.filter(filterMapKey, column, mapPredicate{key: key, fn: predicate})
+	return txn
+}
+
+// mapPredicate represents the predicate of a map key filter
+type mapPredicate struct {
+	key string
+	fn  func(v interface{}) bool
+}
+
+// withMapKey filters down the current selection based on the value of a key of the maps
+func (txn *Txn) withMapKey(column string, predicate mapPredicate) {
+	defer txn.trace("WithMapKey", column, false)()
+	c, ok := txn.columnAt(column)
+	if !ok {
+		txn.index.Clear()
+		return
+	}
+
+	maps, ok := c.Column.(*columnMap)
+	if !ok {
+		txn.index.Clear()
+		return
+	}
+
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		maps.filterKey(offset, index, predicate.key, predicate.fn)
+	})
+}
@@ -4,7 +4,7 @@
 package column
 
 import (
-	"fmt"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -14,20 +14,42 @@ import (
 
 // float32Column represents a generic column
 type float32Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []float32     // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []float32     // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeFloat32s creates a new vector for Float32s
-func makeFloat32s() Column {
-	return &float32Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]float32, 0, 64),
+func makeFloat32s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &float32Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]float32, 0, 64),
+		merger: merger{config.merge},
 	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
+	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *float32Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -44,8 +66,85 @@ func (c *float32Column) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *float32Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]float32, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *float32Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(float32(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *float32Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(float32(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *float32Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(float32(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *float32Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *float32Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -54,9 +153,15 @@ func (c *float32Column) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Float32()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Float32()
 			c.data[r.Offset] = value
 			r.SwapFloat32(value)
 
@@ -66,6 +171,84 @@ func (c *float32Column) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *float32Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), float32ToBits(r.Float32()))
+
+		case commit.Add:
+			value := r.Float32()
+			if c.fill.Contains(r.Index()) {
+				value += float32FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), float32ToBits(value))
+			r.SwapFloat32(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *float32Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the float32 of values the column is able to store
+func (c *float32Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *float32Column) at(idx uint32) float32 {
+	if c.enc != nil {
+		return float32FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// float32ToBits converts the float32 into its raw bits
+func float32ToBits(v float32) (bits uint64) {
+	*(*float32)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// float32FromBits converts the raw bits back into the float32
+func float32FromBits(bits uint64) float32 {
+	return *(*float32)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *float32Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(float32(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *float32Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -79,47 +262,54 @@ func (c *float32Column) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *float32Column) Value(idx uint32) (v interface{}, ok bool) {
 	v = float32(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a float32 value at a specified index
 func (c *float32Column) load(idx uint32) (v float32, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float32(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float32(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *float32Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *float32Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *float32Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *float32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float32) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -128,7 +318,14 @@ func (c *float32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predic
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *float32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float32) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -137,15 +334,118 @@ func (c *float32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicat
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *float32Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float32) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *float32Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v float32) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(float32FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *float32Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float32) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & float32RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *float32Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// float32RangeMask returns the mask of the 64 values which are within the inclusive range
+func float32RangeMask(values []float32, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *float32Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutFloat32(idx, float32FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutFloat32(idx, c.data[idx])
 	})
@@ -164,14 +464,15 @@ func (s float32Reader) Get() (float32, bool) {
 
 // float32ReaderFor creates a new float32 reader
 func float32ReaderFor(txn *Txn, columnName string) float32Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*float32Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*float32Column)
 	}
 
-	reader, ok := column.Column.(*float32Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, float32(0)))
+		txn.misuse(columnName, exists, "float32")
+		reader = makeFloat32s().(*float32Column)
 	}
 
 	return float32Reader{
@@ -204,24 +505,85 @@ func (txn *Txn) Float32(columnName string) float32Writer {
 	}
 }
 
+// Float32s returns an iterator over the values of a float32 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Float32s(columnName string) func(yield func(float32) bool) {
+	reader := float32ReaderFor(txn, columnName)
+	return func(yield func(float32) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Float32Slice returns a read-only view over the values of a float32 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Float32Slice(columnName string) ([]float32, bitmap.Bitmap) {
+	var values *float32Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*float32Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "float32")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
 // --------------------------- Float64s ----------------------------
 
 // float64Column represents a generic column
 type float64Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []float64     // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []float64     // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeFloat64s creates a new vector for Float64s
-func makeFloat64s() Column {
-	return &float64Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]float64, 0, 64),
+func makeFloat64s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &float64Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]float64, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *float64Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -238,8 +600,85 @@ func (c *float64Column) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *float64Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]float64, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *float64Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(float64(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *float64Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(float64(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *float64Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(float64(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *float64Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *float64Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -248,9 +687,15 @@ func (c *float64Column) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Float64()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Float64()
 			c.data[r.Offset] = value
 			r.SwapFloat64(value)
 
@@ -260,6 +705,84 @@ func (c *float64Column) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *float64Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), float64ToBits(r.Float64()))
+
+		case commit.Add:
+			value := r.Float64()
+			if c.fill.Contains(r.Index()) {
+				value += float64FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), float64ToBits(value))
+			r.SwapFloat64(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *float64Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the float64 of values the column is able to store
+func (c *float64Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *float64Column) at(idx uint32) float64 {
+	if c.enc != nil {
+		return float64FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// float64ToBits converts the float64 into its raw bits
+func float64ToBits(v float64) (bits uint64) {
+	*(*float64)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// float64FromBits converts the raw bits back into the float64
+func float64FromBits(bits uint64) float64 {
+	return *(*float64)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *float64Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(float64(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *float64Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -273,47 +796,54 @@ func (c *float64Column) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *float64Column) Value(idx uint32) (v interface{}, ok bool) {
 	v = float64(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a float64 value at a specified index
 func (c *float64Column) load(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *float64Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *float64Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *float64Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *float64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float64) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -322,7 +852,14 @@ func (c *float64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predic
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *float64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float64) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -331,15 +868,118 @@ func (c *float64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicat
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *float64Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float64) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *float64Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(float64FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *float64Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v float64) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & float64RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *float64Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// float64RangeMask returns the mask of the 64 values which are within the inclusive range
+func float64RangeMask(values []float64, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *float64Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutFloat64(idx, float64FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutFloat64(idx, c.data[idx])
 	})
@@ -358,14 +998,15 @@ func (s float64Reader) Get() (float64, bool) {
 
 // float64ReaderFor creates a new float64 reader
 func float64ReaderFor(txn *Txn, columnName string) float64Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*float64Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*float64Column)
 	}
 
-	reader, ok := column.Column.(*float64Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, float64(0)))
+		txn.misuse(columnName, exists, "float64")
+		reader = makeFloat64s().(*float64Column)
 	}
 
 	return float64Reader{
@@ -398,24 +1039,85 @@ func (txn *Txn) Float64(columnName string) float64Writer {
 	}
 }
 
+// Float64s returns an iterator over the values of a float64 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Float64s(columnName string) func(yield func(float64) bool) {
+	reader := float64ReaderFor(txn, columnName)
+	return func(yield func(float64) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Float64Slice returns a read-only view over the values of a float64 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Float64Slice(columnName string) ([]float64, bitmap.Bitmap) {
+	var values *float64Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*float64Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "float64")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
 // --------------------------- Ints ----------------------------
 
 // intColumn represents a generic column
 type intColumn struct {
-	fill bitmap.Bitmap // The fill-list
-	data []int         // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []int         // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeInts creates a new vector for Ints
-func makeInts() Column {
-	return &intColumn{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]int, 0, 64),
+func makeInts(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &intColumn{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]int, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *intColumn) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -432,8 +1134,85 @@ func (c *intColumn) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *intColumn) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]int, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *intColumn) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *intColumn) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *intColumn) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(int(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *intColumn) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *intColumn) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -442,9 +1221,15 @@ func (c *intColumn) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Int()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Int()
 			c.data[r.Offset] = value
 			r.SwapInt(value)
 
@@ -454,6 +1239,84 @@ func (c *intColumn) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *intColumn) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), intToBits(r.Int()))
+
+		case commit.Add:
+			value := r.Int()
+			if c.fill.Contains(r.Index()) {
+				value += intFromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), intToBits(value))
+			r.SwapInt(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *intColumn) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the int of values the column is able to store
+func (c *intColumn) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *intColumn) at(idx uint32) int {
+	if c.enc != nil {
+		return intFromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// intToBits converts the int into its raw bits
+func intToBits(v int) (bits uint64) {
+	*(*int)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// intFromBits converts the raw bits back into the int
+func intFromBits(bits uint64) int {
+	return *(*int)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *intColumn) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(int(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *intColumn) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -467,47 +1330,54 @@ func (c *intColumn) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *intColumn) Value(idx uint32) (v interface{}, ok bool) {
 	v = int(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a int value at a specified index
 func (c *intColumn) load(idx uint32) (v int, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *intColumn) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *intColumn) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *intColumn) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *intColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -516,7 +1386,14 @@ func (c *intColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *intColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -525,15 +1402,118 @@ func (c *intColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate fu
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *intColumn) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *intColumn) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v int) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(intFromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *intColumn) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & intRangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *intColumn) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// intRangeMask returns the mask of the 64 values which are within the inclusive range
+func intRangeMask(values []int, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *intColumn) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutInt(idx, intFromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutInt(idx, c.data[idx])
 	})
@@ -552,14 +1532,15 @@ func (s intReader) Get() (int, bool) {
 
 // intReaderFor creates a new int reader
 func intReaderFor(txn *Txn, columnName string) intReader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*intColumn)(nil), false
+	if exists {
+		reader, ok = column.Column.(*intColumn)
 	}
 
-	reader, ok := column.Column.(*intColumn)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, int(0)))
+		txn.misuse(columnName, exists, "int")
+		reader = makeInts().(*intColumn)
 	}
 
 	return intReader{
@@ -592,24 +1573,85 @@ func (txn *Txn) Int(columnName string) intWriter {
 	}
 }
 
-// --------------------------- Int16s ----------------------------
+// Ints returns an iterator over the values of a int column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Ints(columnName string) func(yield func(int) bool) {
+	reader := intReaderFor(txn, columnName)
+	return func(yield func(int) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
 
-// int16Column represents a generic column
-type int16Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []int16       // The actual values
+// IntSlice returns a read-only view over the values of a int column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) IntSlice(columnName string) ([]int, bitmap.Bitmap) {
+	var values *intColumn
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*intColumn)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "int")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
 }
 
-// makeInt16s creates a new vector for Int16s
-func makeInt16s() Column {
-	return &int16Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]int16, 0, 64),
+// --------------------------- Int8s ----------------------------
+
+// int8Column represents a generic column
+type int8Column struct {
+	fill   bitmap.Bitmap // The fill-list
+	data   []int8        // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
+}
+
+// makeInt8s creates a new vector for Int8s
+func makeInt8s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &int8Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]int8, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
-func (c *int16Column) Grow(idx uint32) {
+func (c *int8Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -621,87 +1663,255 @@ func (c *int16Column) Grow(idx uint32) {
 	}
 
 	c.fill.Grow(idx)
-	clone := make([]int16, idx+1, resize(cap(c.data), idx+1))
+	clone := make([]int8, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *int8Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]int8, shrink(len(c.data), size))
 	copy(clone, c.data)
 	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *int8Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int8(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *int8Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int8(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *int8Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(int8(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *int8Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
 }
 
 // Apply applies a set of operations to the column.
-func (c *int16Column) Apply(r *commit.Reader) {
+func (c *int8Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			c.data[r.Offset] = r.Int16()
+			c.data[r.Offset] = r.Int8()
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Int8()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Int16()
 			c.data[r.Offset] = value
-			r.SwapInt16(value)
+			r.SwapInt8(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *int8Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int8ToBits(r.Int8()))
+
+		case commit.Add:
+			value := r.Int8()
+			if c.fill.Contains(r.Index()) {
+				value += int8FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int8ToBits(value))
+			r.SwapInt8(value)
 
 		case commit.Delete:
 			c.fill.Remove(r.Index())
 		}
 	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *int8Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the int8 of values the column is able to store
+func (c *int8Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *int8Column) at(idx uint32) int8 {
+	if c.enc != nil {
+		return int8FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// int8ToBits converts the int8 into its raw bits
+func int8ToBits(v int8) (bits uint64) {
+	*(*int8)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// int8FromBits converts the raw bits back into the int8
+func int8FromBits(bits uint64) int8 {
+	return *(*int8)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *int8Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(int8(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
 }
 
 // Contains checks whether the column has a value at a specified index.
-func (c *int16Column) Contains(idx uint32) bool {
+func (c *int8Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
 }
 
 // Index returns the fill list for the column
-func (c *int16Column) Index() *bitmap.Bitmap {
+func (c *int8Column) Index() *bitmap.Bitmap {
 	return &c.fill
 }
 
 // Value retrieves a value at a specified index
-func (c *int16Column) Value(idx uint32) (v interface{}, ok bool) {
-	v = int16(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+func (c *int8Column) Value(idx uint32) (v interface{}, ok bool) {
+	v = int8(0)
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
-// load retrieves a int16 value at a specified index
-func (c *int16Column) load(idx uint32) (v int16, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int16(c.data[idx]), true
+// load retrieves a int8 value at a specified index
+func (c *int8Column) load(idx uint32) (v int8, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int8(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
-func (c *int16Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+func (c *int8Column) LoadFloat64(idx uint32) (v float64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
-func (c *int16Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+func (c *int8Column) LoadInt64(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
-func (c *int16Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+func (c *int8Column) LoadUint64(idx uint32) (v uint64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
-func (c *int16Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int8Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int8) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -709,8 +1919,15 @@ func (c *int16Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicat
 }
 
 // FilterInt64 filters down the values based on the specified predicate.
-func (c *int16Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int8Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int8) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -718,92 +1935,257 @@ func (c *int16Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 }
 
 // FilterUint64 filters down the values based on the specified predicate.
-func (c *int16Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int8Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int8) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
-// Snapshot writes the entire column into the specified destination buffer
-func (c *int16Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
-	chunk.Range(c.fill, func(idx uint32) {
-		dst.PutInt16(idx, c.data[idx])
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *int8Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v int8) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(int8FromBits(cursor.load(c.enc, idx)))
 	})
 }
 
-// int16Reader represents a read-only accessor for int16
-type int16Reader struct {
-	cursor *uint32
-	reader *int16Column
-}
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *int8Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int8) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
 
-// Get loads the value at the current transaction cursor
-func (s int16Reader) Get() (int16, bool) {
-	return s.reader.load(*s.cursor)
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & int8RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
 }
 
-// int16ReaderFor creates a new int16 reader
-func int16ReaderFor(txn *Txn, columnName string) int16Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *int8Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// int8RangeMask returns the mask of the 64 values which are within the inclusive range
+func int8RangeMask(values []int8, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *int8Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutInt8(idx, int8FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
+	chunk.Range(c.fill, func(idx uint32) {
+		dst.PutInt8(idx, c.data[idx])
+	})
+}
+
+// int8Reader represents a read-only accessor for int8
+type int8Reader struct {
+	cursor *uint32
+	reader *int8Column
+}
+
+// Get loads the value at the current transaction cursor
+func (s int8Reader) Get() (int8, bool) {
+	return s.reader.load(*s.cursor)
+}
+
+// int8ReaderFor creates a new int8 reader
+func int8ReaderFor(txn *Txn, columnName string) int8Reader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*int8Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*int8Column)
 	}
 
-	reader, ok := column.Column.(*int16Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, int16(0)))
+		txn.misuse(columnName, exists, "int8")
+		reader = makeInt8s().(*int8Column)
 	}
 
-	return int16Reader{
+	return int8Reader{
 		cursor: &txn.cursor,
 		reader: reader,
 	}
 }
 
-// int16Writer represents a read-write accessor for int16
-type int16Writer struct {
-	int16Reader
+// int8Writer represents a read-write accessor for int8
+type int8Writer struct {
+	int8Reader
 	writer *commit.Buffer
 }
 
 // Set sets the value at the current transaction cursor
-func (s int16Writer) Set(value int16) {
-	s.writer.PutInt16(*s.cursor, value)
+func (s int8Writer) Set(value int8) {
+	s.writer.PutInt8(*s.cursor, value)
 }
 
 // Add atomically adds a delta to the value at the current transaction cursor
-func (s int16Writer) Add(delta int16) {
-	s.writer.AddInt16(*s.cursor, delta)
+func (s int8Writer) Add(delta int8) {
+	s.writer.AddInt8(*s.cursor, delta)
 }
 
-// Int16 returns a read-write accessor for int16 column
-func (txn *Txn) Int16(columnName string) int16Writer {
-	return int16Writer{
-		int16Reader: int16ReaderFor(txn, columnName),
-		writer:      txn.bufferFor(columnName),
+// Int8 returns a read-write accessor for int8 column
+func (txn *Txn) Int8(columnName string) int8Writer {
+	return int8Writer{
+		int8Reader: int8ReaderFor(txn, columnName),
+		writer:     txn.bufferFor(columnName),
 	}
 }
 
-// --------------------------- Int32s ----------------------------
+// Int8s returns an iterator over the values of a int8 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Int8s(columnName string) func(yield func(int8) bool) {
+	reader := int8ReaderFor(txn, columnName)
+	return func(yield func(int8) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
 
-// int32Column represents a generic column
-type int32Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []int32       // The actual values
+// Int8Slice returns a read-only view over the values of a int8 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Int8Slice(columnName string) ([]int8, bitmap.Bitmap) {
+	var values *int8Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*int8Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "int8")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
 }
 
-// makeInt32s creates a new vector for Int32s
-func makeInt32s() Column {
-	return &int32Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]int32, 0, 64),
+// --------------------------- Int16s ----------------------------
+
+// int16Column represents a generic column
+type int16Column struct {
+	fill   bitmap.Bitmap // The fill-list
+	data   []int16       // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
+}
+
+// makeInt16s creates a new vector for Int16s
+func makeInt16s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &int16Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]int16, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
-func (c *int32Column) Grow(idx uint32) {
+func (c *int16Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -815,87 +2197,255 @@ func (c *int32Column) Grow(idx uint32) {
 	}
 
 	c.fill.Grow(idx)
-	clone := make([]int32, idx+1, resize(cap(c.data), idx+1))
+	clone := make([]int16, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *int16Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]int16, shrink(len(c.data), size))
 	copy(clone, c.data)
 	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *int16Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int16(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *int16Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int16(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *int16Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(int16(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *int16Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
 }
 
 // Apply applies a set of operations to the column.
-func (c *int32Column) Apply(r *commit.Reader) {
+func (c *int16Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			c.data[r.Offset] = r.Int32()
+			c.data[r.Offset] = r.Int16()
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Int16()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Int32()
 			c.data[r.Offset] = value
-			r.SwapInt32(value)
+			r.SwapInt16(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *int16Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int16ToBits(r.Int16()))
+
+		case commit.Add:
+			value := r.Int16()
+			if c.fill.Contains(r.Index()) {
+				value += int16FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int16ToBits(value))
+			r.SwapInt16(value)
 
 		case commit.Delete:
 			c.fill.Remove(r.Index())
 		}
 	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *int16Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the int16 of values the column is able to store
+func (c *int16Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *int16Column) at(idx uint32) int16 {
+	if c.enc != nil {
+		return int16FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// int16ToBits converts the int16 into its raw bits
+func int16ToBits(v int16) (bits uint64) {
+	*(*int16)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// int16FromBits converts the raw bits back into the int16
+func int16FromBits(bits uint64) int16 {
+	return *(*int16)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *int16Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(int16(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
 }
 
 // Contains checks whether the column has a value at a specified index.
-func (c *int32Column) Contains(idx uint32) bool {
+func (c *int16Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
 }
 
 // Index returns the fill list for the column
-func (c *int32Column) Index() *bitmap.Bitmap {
+func (c *int16Column) Index() *bitmap.Bitmap {
 	return &c.fill
 }
 
 // Value retrieves a value at a specified index
-func (c *int32Column) Value(idx uint32) (v interface{}, ok bool) {
-	v = int32(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+func (c *int16Column) Value(idx uint32) (v interface{}, ok bool) {
+	v = int16(0)
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
-// load retrieves a int32 value at a specified index
-func (c *int32Column) load(idx uint32) (v int32, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int32(c.data[idx]), true
+// load retrieves a int16 value at a specified index
+func (c *int16Column) load(idx uint32) (v int16, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int16(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
-func (c *int32Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+func (c *int16Column) LoadFloat64(idx uint32) (v float64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
-func (c *int32Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+func (c *int16Column) LoadInt64(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
-func (c *int32Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+func (c *int16Column) LoadUint64(idx uint32) (v uint64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
-func (c *int32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int16Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int16) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -903,8 +2453,15 @@ func (c *int32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicat
 }
 
 // FilterInt64 filters down the values based on the specified predicate.
-func (c *int32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int16Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int16) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -912,92 +2469,257 @@ func (c *int32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 }
 
 // FilterUint64 filters down the values based on the specified predicate.
-func (c *int32Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int16Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int16) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *int16Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v int16) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(int16FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *int16Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int16) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & int16RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *int16Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// int16RangeMask returns the mask of the 64 values which are within the inclusive range
+func int16RangeMask(values []int16, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
-func (c *int32Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+func (c *int16Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutInt16(idx, int16FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
-		dst.PutInt32(idx, c.data[idx])
+		dst.PutInt16(idx, c.data[idx])
 	})
 }
 
-// int32Reader represents a read-only accessor for int32
-type int32Reader struct {
+// int16Reader represents a read-only accessor for int16
+type int16Reader struct {
 	cursor *uint32
-	reader *int32Column
+	reader *int16Column
 }
 
 // Get loads the value at the current transaction cursor
-func (s int32Reader) Get() (int32, bool) {
+func (s int16Reader) Get() (int16, bool) {
 	return s.reader.load(*s.cursor)
 }
 
-// int32ReaderFor creates a new int32 reader
-func int32ReaderFor(txn *Txn, columnName string) int32Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+// int16ReaderFor creates a new int16 reader
+func int16ReaderFor(txn *Txn, columnName string) int16Reader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*int16Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*int16Column)
 	}
 
-	reader, ok := column.Column.(*int32Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, int32(0)))
+		txn.misuse(columnName, exists, "int16")
+		reader = makeInt16s().(*int16Column)
 	}
 
-	return int32Reader{
+	return int16Reader{
 		cursor: &txn.cursor,
 		reader: reader,
 	}
 }
 
-// int32Writer represents a read-write accessor for int32
-type int32Writer struct {
-	int32Reader
+// int16Writer represents a read-write accessor for int16
+type int16Writer struct {
+	int16Reader
 	writer *commit.Buffer
 }
 
 // Set sets the value at the current transaction cursor
-func (s int32Writer) Set(value int32) {
-	s.writer.PutInt32(*s.cursor, value)
+func (s int16Writer) Set(value int16) {
+	s.writer.PutInt16(*s.cursor, value)
 }
 
 // Add atomically adds a delta to the value at the current transaction cursor
-func (s int32Writer) Add(delta int32) {
-	s.writer.AddInt32(*s.cursor, delta)
+func (s int16Writer) Add(delta int16) {
+	s.writer.AddInt16(*s.cursor, delta)
 }
 
-// Int32 returns a read-write accessor for int32 column
-func (txn *Txn) Int32(columnName string) int32Writer {
-	return int32Writer{
-		int32Reader: int32ReaderFor(txn, columnName),
+// Int16 returns a read-write accessor for int16 column
+func (txn *Txn) Int16(columnName string) int16Writer {
+	return int16Writer{
+		int16Reader: int16ReaderFor(txn, columnName),
 		writer:      txn.bufferFor(columnName),
 	}
 }
 
-// --------------------------- Int64s ----------------------------
-
-// int64Column represents a generic column
-type int64Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []int64       // The actual values
-}
-
-// makeInt64s creates a new vector for Int64s
-func makeInt64s() Column {
-	return &int64Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]int64, 0, 64),
+// Int16s returns an iterator over the values of a int16 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Int16s(columnName string) func(yield func(int16) bool) {
+	reader := int16ReaderFor(txn, columnName)
+	return func(yield func(int16) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
 	}
 }
 
+// Int16Slice returns a read-only view over the values of a int16 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Int16Slice(columnName string) ([]int16, bitmap.Bitmap) {
+	var values *int16Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*int16Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "int16")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
+// --------------------------- Int32s ----------------------------
+
+// int32Column represents a generic column
+type int32Column struct {
+	fill   bitmap.Bitmap // The fill-list
+	data   []int32       // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
+}
+
+// makeInt32s creates a new vector for Int32s
+func makeInt32s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &int32Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]int32, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
+	}
+	return column
+}
+
 // Grow grows the size of the column until we have enough to store
-func (c *int64Column) Grow(idx uint32) {
+func (c *int32Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -1009,87 +2731,255 @@ func (c *int64Column) Grow(idx uint32) {
 	}
 
 	c.fill.Grow(idx)
-	clone := make([]int64, idx+1, resize(cap(c.data), idx+1))
+	clone := make([]int32, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *int32Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]int32, shrink(len(c.data), size))
 	copy(clone, c.data)
 	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *int32Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int32(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *int32Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int32(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *int32Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(int32(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *int32Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
 }
 
 // Apply applies a set of operations to the column.
-func (c *int64Column) Apply(r *commit.Reader) {
+func (c *int32Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			c.data[r.Offset] = r.Int64()
+			c.data[r.Offset] = r.Int32()
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Int32()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Int64()
 			c.data[r.Offset] = value
-			r.SwapInt64(value)
+			r.SwapInt32(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *int32Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int32ToBits(r.Int32()))
+
+		case commit.Add:
+			value := r.Int32()
+			if c.fill.Contains(r.Index()) {
+				value += int32FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int32ToBits(value))
+			r.SwapInt32(value)
 
 		case commit.Delete:
 			c.fill.Remove(r.Index())
 		}
 	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *int32Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the int32 of values the column is able to store
+func (c *int32Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *int32Column) at(idx uint32) int32 {
+	if c.enc != nil {
+		return int32FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// int32ToBits converts the int32 into its raw bits
+func int32ToBits(v int32) (bits uint64) {
+	*(*int32)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// int32FromBits converts the raw bits back into the int32
+func int32FromBits(bits uint64) int32 {
+	return *(*int32)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *int32Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(int32(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
 }
 
 // Contains checks whether the column has a value at a specified index.
-func (c *int64Column) Contains(idx uint32) bool {
+func (c *int32Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
 }
 
 // Index returns the fill list for the column
-func (c *int64Column) Index() *bitmap.Bitmap {
+func (c *int32Column) Index() *bitmap.Bitmap {
 	return &c.fill
 }
 
 // Value retrieves a value at a specified index
-func (c *int64Column) Value(idx uint32) (v interface{}, ok bool) {
-	v = int64(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+func (c *int32Column) Value(idx uint32) (v interface{}, ok bool) {
+	v = int32(0)
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
-// load retrieves a int64 value at a specified index
-func (c *int64Column) load(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+// load retrieves a int32 value at a specified index
+func (c *int32Column) load(idx uint32) (v int32, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int32(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
-func (c *int64Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+func (c *int32Column) LoadFloat64(idx uint32) (v float64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
-func (c *int64Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+func (c *int32Column) LoadInt64(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
-func (c *int64Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+func (c *int32Column) LoadUint64(idx uint32) (v uint64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
-func (c *int64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int32) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -1097,8 +2987,15 @@ func (c *int64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicat
 }
 
 // FilterInt64 filters down the values based on the specified predicate.
-func (c *int64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int32) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -1106,92 +3003,257 @@ func (c *int64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 }
 
 // FilterUint64 filters down the values based on the specified predicate.
-func (c *int64Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *int32Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int32) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *int32Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v int32) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(int32FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *int32Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int32) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & int32RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *int32Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// int32RangeMask returns the mask of the 64 values which are within the inclusive range
+func int32RangeMask(values []int32, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
-func (c *int64Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+func (c *int32Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutInt32(idx, int32FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
-		dst.PutInt64(idx, c.data[idx])
+		dst.PutInt32(idx, c.data[idx])
 	})
 }
 
-// int64Reader represents a read-only accessor for int64
-type int64Reader struct {
+// int32Reader represents a read-only accessor for int32
+type int32Reader struct {
 	cursor *uint32
-	reader *int64Column
+	reader *int32Column
 }
 
 // Get loads the value at the current transaction cursor
-func (s int64Reader) Get() (int64, bool) {
+func (s int32Reader) Get() (int32, bool) {
 	return s.reader.load(*s.cursor)
 }
 
-// int64ReaderFor creates a new int64 reader
-func int64ReaderFor(txn *Txn, columnName string) int64Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+// int32ReaderFor creates a new int32 reader
+func int32ReaderFor(txn *Txn, columnName string) int32Reader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*int32Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*int32Column)
 	}
 
-	reader, ok := column.Column.(*int64Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, int64(0)))
+		txn.misuse(columnName, exists, "int32")
+		reader = makeInt32s().(*int32Column)
 	}
 
-	return int64Reader{
+	return int32Reader{
 		cursor: &txn.cursor,
 		reader: reader,
 	}
 }
 
-// int64Writer represents a read-write accessor for int64
-type int64Writer struct {
-	int64Reader
+// int32Writer represents a read-write accessor for int32
+type int32Writer struct {
+	int32Reader
 	writer *commit.Buffer
 }
 
 // Set sets the value at the current transaction cursor
-func (s int64Writer) Set(value int64) {
-	s.writer.PutInt64(*s.cursor, value)
+func (s int32Writer) Set(value int32) {
+	s.writer.PutInt32(*s.cursor, value)
 }
 
 // Add atomically adds a delta to the value at the current transaction cursor
-func (s int64Writer) Add(delta int64) {
-	s.writer.AddInt64(*s.cursor, delta)
+func (s int32Writer) Add(delta int32) {
+	s.writer.AddInt32(*s.cursor, delta)
 }
 
-// Int64 returns a read-write accessor for int64 column
-func (txn *Txn) Int64(columnName string) int64Writer {
-	return int64Writer{
-		int64Reader: int64ReaderFor(txn, columnName),
+// Int32 returns a read-write accessor for int32 column
+func (txn *Txn) Int32(columnName string) int32Writer {
+	return int32Writer{
+		int32Reader: int32ReaderFor(txn, columnName),
 		writer:      txn.bufferFor(columnName),
 	}
 }
 
-// --------------------------- Uints ----------------------------
+// Int32s returns an iterator over the values of a int32 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Int32s(columnName string) func(yield func(int32) bool) {
+	reader := int32ReaderFor(txn, columnName)
+	return func(yield func(int32) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
 
-// uintColumn represents a generic column
-type uintColumn struct {
-	fill bitmap.Bitmap // The fill-list
-	data []uint        // The actual values
+// Int32Slice returns a read-only view over the values of a int32 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Int32Slice(columnName string) ([]int32, bitmap.Bitmap) {
+	var values *int32Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*int32Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "int32")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
 }
 
-// makeUints creates a new vector for Uints
-func makeUints() Column {
-	return &uintColumn{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]uint, 0, 64),
+// --------------------------- Int64s ----------------------------
+
+// int64Column represents a generic column
+type int64Column struct {
+	fill   bitmap.Bitmap // The fill-list
+	data   []int64       // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
+}
+
+// makeInt64s creates a new vector for Int64s
+func makeInt64s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &int64Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]int64, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
-func (c *uintColumn) Grow(idx uint32) {
+func (c *int64Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -1202,88 +3264,1324 @@ func (c *uintColumn) Grow(idx uint32) {
 		return
 	}
 
-	c.fill.Grow(idx)
-	clone := make([]uint, idx+1, resize(cap(c.data), idx+1))
-	copy(clone, c.data)
-	c.data = clone
+	c.fill.Grow(idx)
+	clone := make([]int64, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *int64Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]int64, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *int64Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int64(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *int64Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(int64(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *int64Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(int64(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *int64Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *int64Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			c.data[r.Offset] = r.Int64()
+
+		// If this is an atomic increment/decrement, we need to change the operation to
+		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
+		case commit.Add:
+			value := r.Int64()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			c.data[r.Offset] = value
+			r.SwapInt64(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *int64Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int64ToBits(r.Int64()))
+
+		case commit.Add:
+			value := r.Int64()
+			if c.fill.Contains(r.Index()) {
+				value += int64FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), int64ToBits(value))
+			r.SwapInt64(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *int64Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the int64 of values the column is able to store
+func (c *int64Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *int64Column) at(idx uint32) int64 {
+	if c.enc != nil {
+		return int64FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// int64ToBits converts the int64 into its raw bits
+func int64ToBits(v int64) (bits uint64) {
+	*(*int64)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// int64FromBits converts the raw bits back into the int64
+func int64FromBits(bits uint64) int64 {
+	return *(*int64)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *int64Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(int64(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *int64Column) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *int64Column) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// Value retrieves a value at a specified index
+func (c *int64Column) Value(idx uint32) (v interface{}, ok bool) {
+	v = int64(0)
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
+	}
+	return
+}
+
+// load retrieves a int64 value at a specified index
+func (c *int64Column) load(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
+	}
+	return
+}
+
+// LoadFloat64 retrieves a float64 value at a specified index
+func (c *int64Column) LoadFloat64(idx uint32) (v float64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
+	}
+	return
+}
+
+// LoadInt64 retrieves an int64 value at a specified index
+func (c *int64Column) LoadInt64(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
+	}
+	return
+}
+
+// LoadUint64 retrieves an uint64 value at a specified index
+func (c *int64Column) LoadUint64(idx uint32) (v uint64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
+	}
+	return
+}
+
+// FilterFloat64 filters down the values based on the specified predicate.
+func (c *int64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int64) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
+	})
+}
+
+// FilterInt64 filters down the values based on the specified predicate.
+func (c *int64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int64) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
+	index.Filter(func(idx uint32) (match bool) {
+		idx = offset + idx
+		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
+	})
+}
+
+// FilterUint64 filters down the values based on the specified predicate.
+func (c *int64Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int64) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
+	index.Filter(func(idx uint32) (match bool) {
+		idx = offset + idx
+		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
+	})
+}
+
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *int64Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(int64FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *int64Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v int64) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & int64RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *int64Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// int64RangeMask returns the mask of the 64 values which are within the inclusive range
+func int64RangeMask(values []int64, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *int64Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutInt64(idx, int64FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
+	chunk.Range(c.fill, func(idx uint32) {
+		dst.PutInt64(idx, c.data[idx])
+	})
+}
+
+// int64Reader represents a read-only accessor for int64
+type int64Reader struct {
+	cursor *uint32
+	reader *int64Column
+}
+
+// Get loads the value at the current transaction cursor
+func (s int64Reader) Get() (int64, bool) {
+	return s.reader.load(*s.cursor)
+}
+
+// int64ReaderFor creates a new int64 reader
+func int64ReaderFor(txn *Txn, columnName string) int64Reader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*int64Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*int64Column)
+	}
+
+	if !ok {
+		txn.misuse(columnName, exists, "int64")
+		reader = makeInt64s().(*int64Column)
+	}
+
+	return int64Reader{
+		cursor: &txn.cursor,
+		reader: reader,
+	}
+}
+
+// int64Writer represents a read-write accessor for int64
+type int64Writer struct {
+	int64Reader
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s int64Writer) Set(value int64) {
+	s.writer.PutInt64(*s.cursor, value)
+}
+
+// Add atomically adds a delta to the value at the current transaction cursor
+func (s int64Writer) Add(delta int64) {
+	s.writer.AddInt64(*s.cursor, delta)
+}
+
+// Int64 returns a read-write accessor for int64 column
+func (txn *Txn) Int64(columnName string) int64Writer {
+	return int64Writer{
+		int64Reader: int64ReaderFor(txn, columnName),
+		writer:      txn.bufferFor(columnName),
+	}
+}
+
+// Int64s returns an iterator over the values of a int64 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Int64s(columnName string) func(yield func(int64) bool) {
+	reader := int64ReaderFor(txn, columnName)
+	return func(yield func(int64) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Int64Slice returns a read-only view over the values of a int64 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Int64Slice(columnName string) ([]int64, bitmap.Bitmap) {
+	var values *int64Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*int64Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "int64")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
+// --------------------------- Uints ----------------------------
+
+// uintColumn represents a generic column
+type uintColumn struct {
+	fill   bitmap.Bitmap // The fill-list
+	data   []uint        // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
+}
+
+// makeUints creates a new vector for Uints
+func makeUints(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &uintColumn{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]uint, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
+	}
+	return column
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *uintColumn) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
+	if idx < uint32(len(c.data)) {
+		return
+	}
+
+	if idx < uint32(cap(c.data)) {
+		c.fill.Grow(idx)
+		c.data = c.data[:idx+1]
+		return
+	}
+
+	c.fill.Grow(idx)
+	clone := make([]uint, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *uintColumn) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]uint, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *uintColumn) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *uintColumn) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *uintColumn) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(uint(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *uintColumn) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *uintColumn) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			c.data[r.Offset] = r.Uint()
+
+		// If this is an atomic increment/decrement, we need to change the operation to
+		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
+		case commit.Add:
+			value := r.Uint()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			c.data[r.Offset] = value
+			r.SwapUint(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *uintColumn) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uintToBits(r.Uint()))
+
+		case commit.Add:
+			value := r.Uint()
+			if c.fill.Contains(r.Index()) {
+				value += uintFromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uintToBits(value))
+			r.SwapUint(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *uintColumn) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the uint of values the column is able to store
+func (c *uintColumn) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *uintColumn) at(idx uint32) uint {
+	if c.enc != nil {
+		return uintFromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// uintToBits converts the uint into its raw bits
+func uintToBits(v uint) (bits uint64) {
+	*(*uint)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// uintFromBits converts the raw bits back into the uint
+func uintFromBits(bits uint64) uint {
+	return *(*uint)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *uintColumn) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(uint(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *uintColumn) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *uintColumn) Index() *bitmap.Bitmap {
+	return &c.fill
+}
+
+// Value retrieves a value at a specified index
+func (c *uintColumn) Value(idx uint32) (v interface{}, ok bool) {
+	v = uint(0)
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
+	}
+	return
+}
+
+// load retrieves a uint value at a specified index
+func (c *uintColumn) load(idx uint32) (v uint, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint(c.at(idx)), true
+	}
+	return
+}
+
+// LoadFloat64 retrieves a float64 value at a specified index
+func (c *uintColumn) LoadFloat64(idx uint32) (v float64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
+	}
+	return
+}
+
+// LoadInt64 retrieves an int64 value at a specified index
+func (c *uintColumn) LoadInt64(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
+	}
+	return
+}
+
+// LoadUint64 retrieves an uint64 value at a specified index
+func (c *uintColumn) LoadUint64(idx uint32) (v uint64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
+	}
+	return
+}
+
+// FilterFloat64 filters down the values based on the specified predicate.
+func (c *uintColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
+	})
+}
+
+// FilterInt64 filters down the values based on the specified predicate.
+func (c *uintColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
+	index.Filter(func(idx uint32) (match bool) {
+		idx = offset + idx
+		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
+	})
+}
+
+// FilterUint64 filters down the values based on the specified predicate.
+func (c *uintColumn) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
+	index.Filter(func(idx uint32) (match bool) {
+		idx = offset + idx
+		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
+	})
+}
+
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *uintColumn) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v uint) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(uintFromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *uintColumn) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & uintRangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *uintColumn) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// uintRangeMask returns the mask of the 64 values which are within the inclusive range
+func uintRangeMask(values []uint, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *uintColumn) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutUint(idx, uintFromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
+	chunk.Range(c.fill, func(idx uint32) {
+		dst.PutUint(idx, c.data[idx])
+	})
+}
+
+// uintReader represents a read-only accessor for uint
+type uintReader struct {
+	cursor *uint32
+	reader *uintColumn
+}
+
+// Get loads the value at the current transaction cursor
+func (s uintReader) Get() (uint, bool) {
+	return s.reader.load(*s.cursor)
+}
+
+// uintReaderFor creates a new uint reader
+func uintReaderFor(txn *Txn, columnName string) uintReader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*uintColumn)(nil), false
+	if exists {
+		reader, ok = column.Column.(*uintColumn)
+	}
+
+	if !ok {
+		txn.misuse(columnName, exists, "uint")
+		reader = makeUints().(*uintColumn)
+	}
+
+	return uintReader{
+		cursor: &txn.cursor,
+		reader: reader,
+	}
+}
+
+// uintWriter represents a read-write accessor for uint
+type uintWriter struct {
+	uintReader
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s uintWriter) Set(value uint) {
+	s.writer.PutUint(*s.cursor, value)
+}
+
+// Add atomically adds a delta to the value at the current transaction cursor
+func (s uintWriter) Add(delta uint) {
+	s.writer.AddUint(*s.cursor, delta)
+}
+
+// Uint returns a read-write accessor for uint column
+func (txn *Txn) Uint(columnName string) uintWriter {
+	return uintWriter{
+		uintReader: uintReaderFor(txn, columnName),
+		writer:     txn.bufferFor(columnName),
+	}
+}
+
+// Uints returns an iterator over the values of a uint column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Uints(columnName string) func(yield func(uint) bool) {
+	reader := uintReaderFor(txn, columnName)
+	return func(yield func(uint) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// UintSlice returns a read-only view over the values of a uint column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) UintSlice(columnName string) ([]uint, bitmap.Bitmap) {
+	var values *uintColumn
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*uintColumn)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "uint")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
+// --------------------------- Uint8s ----------------------------
+
+// uint8Column represents a generic column
+type uint8Column struct {
+	fill   bitmap.Bitmap // The fill-list
+	data   []uint8       // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
+}
+
+// makeUint8s creates a new vector for Uint8s
+func makeUint8s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &uint8Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]uint8, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
+	}
+	return column
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *uint8Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
+	if idx < uint32(len(c.data)) {
+		return
+	}
+
+	if idx < uint32(cap(c.data)) {
+		c.fill.Grow(idx)
+		c.data = c.data[:idx+1]
+		return
+	}
+
+	c.fill.Grow(idx)
+	clone := make([]uint8, idx+1, resize(cap(c.data), idx+1))
+	copy(clone, c.data)
+	c.data = clone
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *uint8Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]uint8, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *uint8Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint8(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *uint8Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint8(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *uint8Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(uint8(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *uint8Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
 }
 
 // Apply applies a set of operations to the column.
-func (c *uintColumn) Apply(r *commit.Reader) {
+func (c *uint8Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			c.data[r.Offset] = r.Uint()
+			c.data[r.Offset] = r.Uint8()
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Uint8()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Uint()
 			c.data[r.Offset] = value
-			r.SwapUint(value)
+			r.SwapUint8(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+}
+
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *uint8Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint8ToBits(r.Uint8()))
+
+		case commit.Add:
+			value := r.Uint8()
+			if c.fill.Contains(r.Index()) {
+				value += uint8FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint8ToBits(value))
+			r.SwapUint8(value)
 
 		case commit.Delete:
 			c.fill.Remove(r.Index())
 		}
 	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *uint8Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the uint8 of values the column is able to store
+func (c *uint8Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *uint8Column) at(idx uint32) uint8 {
+	if c.enc != nil {
+		return uint8FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// uint8ToBits converts the uint8 into its raw bits
+func uint8ToBits(v uint8) (bits uint64) {
+	*(*uint8)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// uint8FromBits converts the raw bits back into the uint8
+func uint8FromBits(bits uint64) uint8 {
+	return *(*uint8)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *uint8Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(uint8(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
 }
 
 // Contains checks whether the column has a value at a specified index.
-func (c *uintColumn) Contains(idx uint32) bool {
+func (c *uint8Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
 }
 
 // Index returns the fill list for the column
-func (c *uintColumn) Index() *bitmap.Bitmap {
+func (c *uint8Column) Index() *bitmap.Bitmap {
 	return &c.fill
 }
 
 // Value retrieves a value at a specified index
-func (c *uintColumn) Value(idx uint32) (v interface{}, ok bool) {
-	v = uint(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+func (c *uint8Column) Value(idx uint32) (v interface{}, ok bool) {
+	v = uint8(0)
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
-// load retrieves a uint value at a specified index
-func (c *uintColumn) load(idx uint32) (v uint, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint(c.data[idx]), true
+// load retrieves a uint8 value at a specified index
+func (c *uint8Column) load(idx uint32) (v uint8, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint8(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
-func (c *uintColumn) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+func (c *uint8Column) LoadFloat64(idx uint32) (v float64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
-func (c *uintColumn) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+func (c *uint8Column) LoadInt64(idx uint32) (v int64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
-func (c *uintColumn) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+func (c *uint8Column) LoadUint64(idx uint32) (v uint64, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
-func (c *uintColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *uint8Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint8) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -1291,8 +4589,15 @@ func (c *uintColumn) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate
 }
 
 // FilterInt64 filters down the values based on the specified predicate.
-func (c *uintColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *uint8Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint8) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -1300,92 +4605,257 @@ func (c *uintColumn) FilterInt64(offset uint32, index bitmap.Bitmap, predicate f
 }
 
 // FilterUint64 filters down the values based on the specified predicate.
-func (c *uintColumn) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+func (c *uint8Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint8) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *uint8Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v uint8) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(uint8FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *uint8Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint8) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & uint8RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *uint8Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// uint8RangeMask returns the mask of the 64 values which are within the inclusive range
+func uint8RangeMask(values []uint8, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
-func (c *uintColumn) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+func (c *uint8Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutUint8(idx, uint8FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
-		dst.PutUint(idx, c.data[idx])
+		dst.PutUint8(idx, c.data[idx])
 	})
 }
 
-// uintReader represents a read-only accessor for uint
-type uintReader struct {
+// uint8Reader represents a read-only accessor for uint8
+type uint8Reader struct {
 	cursor *uint32
-	reader *uintColumn
+	reader *uint8Column
 }
 
 // Get loads the value at the current transaction cursor
-func (s uintReader) Get() (uint, bool) {
+func (s uint8Reader) Get() (uint8, bool) {
 	return s.reader.load(*s.cursor)
 }
 
-// uintReaderFor creates a new uint reader
-func uintReaderFor(txn *Txn, columnName string) uintReader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+// uint8ReaderFor creates a new uint8 reader
+func uint8ReaderFor(txn *Txn, columnName string) uint8Reader {
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*uint8Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*uint8Column)
 	}
 
-	reader, ok := column.Column.(*uintColumn)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, uint(0)))
+		txn.misuse(columnName, exists, "uint8")
+		reader = makeUint8s().(*uint8Column)
 	}
 
-	return uintReader{
+	return uint8Reader{
 		cursor: &txn.cursor,
 		reader: reader,
 	}
 }
 
-// uintWriter represents a read-write accessor for uint
-type uintWriter struct {
-	uintReader
+// uint8Writer represents a read-write accessor for uint8
+type uint8Writer struct {
+	uint8Reader
 	writer *commit.Buffer
 }
 
 // Set sets the value at the current transaction cursor
-func (s uintWriter) Set(value uint) {
-	s.writer.PutUint(*s.cursor, value)
+func (s uint8Writer) Set(value uint8) {
+	s.writer.PutUint8(*s.cursor, value)
 }
 
 // Add atomically adds a delta to the value at the current transaction cursor
-func (s uintWriter) Add(delta uint) {
-	s.writer.AddUint(*s.cursor, delta)
+func (s uint8Writer) Add(delta uint8) {
+	s.writer.AddUint8(*s.cursor, delta)
 }
 
-// Uint returns a read-write accessor for uint column
-func (txn *Txn) Uint(columnName string) uintWriter {
-	return uintWriter{
-		uintReader: uintReaderFor(txn, columnName),
-		writer:     txn.bufferFor(columnName),
+// Uint8 returns a read-write accessor for uint8 column
+func (txn *Txn) Uint8(columnName string) uint8Writer {
+	return uint8Writer{
+		uint8Reader: uint8ReaderFor(txn, columnName),
+		writer:      txn.bufferFor(columnName),
+	}
+}
+
+// Uint8s returns an iterator over the values of a uint8 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Uint8s(columnName string) func(yield func(uint8) bool) {
+	reader := uint8ReaderFor(txn, columnName)
+	return func(yield func(uint8) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Uint8Slice returns a read-only view over the values of a uint8 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Uint8Slice(columnName string) ([]uint8, bitmap.Bitmap) {
+	var values *uint8Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*uint8Column)
 	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "uint8")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
 }
 
 // --------------------------- Uint16s ----------------------------
 
 // uint16Column represents a generic column
 type uint16Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []uint16      // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []uint16      // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeUint16s creates a new vector for Uint16s
-func makeUint16s() Column {
-	return &uint16Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]uint16, 0, 64),
+func makeUint16s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &uint16Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]uint16, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *uint16Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -1402,8 +4872,85 @@ func (c *uint16Column) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *uint16Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]uint16, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *uint16Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint16(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *uint16Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint16(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *uint16Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(uint16(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *uint16Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *uint16Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -1412,9 +4959,15 @@ func (c *uint16Column) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Uint16()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Uint16()
 			c.data[r.Offset] = value
 			r.SwapUint16(value)
 
@@ -1424,6 +4977,84 @@ func (c *uint16Column) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *uint16Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint16ToBits(r.Uint16()))
+
+		case commit.Add:
+			value := r.Uint16()
+			if c.fill.Contains(r.Index()) {
+				value += uint16FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint16ToBits(value))
+			r.SwapUint16(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *uint16Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the uint16 of values the column is able to store
+func (c *uint16Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *uint16Column) at(idx uint32) uint16 {
+	if c.enc != nil {
+		return uint16FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// uint16ToBits converts the uint16 into its raw bits
+func uint16ToBits(v uint16) (bits uint64) {
+	*(*uint16)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// uint16FromBits converts the raw bits back into the uint16
+func uint16FromBits(bits uint64) uint16 {
+	return *(*uint16)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *uint16Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(uint16(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *uint16Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -1437,47 +5068,54 @@ func (c *uint16Column) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *uint16Column) Value(idx uint32) (v interface{}, ok bool) {
 	v = uint16(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a uint16 value at a specified index
 func (c *uint16Column) load(idx uint32) (v uint16, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint16(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint16(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *uint16Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *uint16Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *uint16Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *uint16Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint16) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -1486,7 +5124,14 @@ func (c *uint16Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predica
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *uint16Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint16) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -1495,15 +5140,118 @@ func (c *uint16Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *uint16Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint16) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *uint16Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v uint16) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(uint16FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *uint16Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint16) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & uint16RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *uint16Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// uint16RangeMask returns the mask of the 64 values which are within the inclusive range
+func uint16RangeMask(values []uint16, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *uint16Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutUint16(idx, uint16FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutUint16(idx, c.data[idx])
 	})
@@ -1522,14 +5270,15 @@ func (s uint16Reader) Get() (uint16, bool) {
 
 // uint16ReaderFor creates a new uint16 reader
 func uint16ReaderFor(txn *Txn, columnName string) uint16Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*uint16Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*uint16Column)
 	}
 
-	reader, ok := column.Column.(*uint16Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, uint16(0)))
+		txn.misuse(columnName, exists, "uint16")
+		reader = makeUint16s().(*uint16Column)
 	}
 
 	return uint16Reader{
@@ -1562,24 +5311,85 @@ func (txn *Txn) Uint16(columnName string) uint16Writer {
 	}
 }
 
+// Uint16s returns an iterator over the values of a uint16 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Uint16s(columnName string) func(yield func(uint16) bool) {
+	reader := uint16ReaderFor(txn, columnName)
+	return func(yield func(uint16) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Uint16Slice returns a read-only view over the values of a uint16 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Uint16Slice(columnName string) ([]uint16, bitmap.Bitmap) {
+	var values *uint16Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*uint16Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "uint16")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
 // --------------------------- Uint32s ----------------------------
 
 // uint32Column represents a generic column
 type uint32Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []uint32      // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []uint32      // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeUint32s creates a new vector for Uint32s
-func makeUint32s() Column {
-	return &uint32Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]uint32, 0, 64),
+func makeUint32s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &uint32Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]uint32, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *uint32Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -1596,8 +5406,85 @@ func (c *uint32Column) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *uint32Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]uint32, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *uint32Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint32(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *uint32Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint32(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *uint32Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(uint32(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *uint32Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *uint32Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -1606,9 +5493,15 @@ func (c *uint32Column) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Uint32()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Uint32()
 			c.data[r.Offset] = value
 			r.SwapUint32(value)
 
@@ -1618,6 +5511,84 @@ func (c *uint32Column) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *uint32Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint32ToBits(r.Uint32()))
+
+		case commit.Add:
+			value := r.Uint32()
+			if c.fill.Contains(r.Index()) {
+				value += uint32FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint32ToBits(value))
+			r.SwapUint32(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *uint32Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the uint32 of values the column is able to store
+func (c *uint32Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *uint32Column) at(idx uint32) uint32 {
+	if c.enc != nil {
+		return uint32FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// uint32ToBits converts the uint32 into its raw bits
+func uint32ToBits(v uint32) (bits uint64) {
+	*(*uint32)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// uint32FromBits converts the raw bits back into the uint32
+func uint32FromBits(bits uint64) uint32 {
+	return *(*uint32)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *uint32Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(uint32(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *uint32Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -1631,47 +5602,54 @@ func (c *uint32Column) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *uint32Column) Value(idx uint32) (v interface{}, ok bool) {
 	v = uint32(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a uint32 value at a specified index
 func (c *uint32Column) load(idx uint32) (v uint32, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint32(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint32(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *uint32Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *uint32Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *uint32Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *uint32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint32) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -1680,7 +5658,14 @@ func (c *uint32Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predica
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *uint32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint32) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -1689,15 +5674,118 @@ func (c *uint32Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *uint32Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint32) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *uint32Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v uint32) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(uint32FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *uint32Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint32) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & uint32RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *uint32Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// uint32RangeMask returns the mask of the 64 values which are within the inclusive range
+func uint32RangeMask(values []uint32, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *uint32Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutUint32(idx, uint32FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutUint32(idx, c.data[idx])
 	})
@@ -1716,14 +5804,15 @@ func (s uint32Reader) Get() (uint32, bool) {
 
 // uint32ReaderFor creates a new uint32 reader
 func uint32ReaderFor(txn *Txn, columnName string) uint32Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*uint32Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*uint32Column)
 	}
 
-	reader, ok := column.Column.(*uint32Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, uint32(0)))
+		txn.misuse(columnName, exists, "uint32")
+		reader = makeUint32s().(*uint32Column)
 	}
 
 	return uint32Reader{
@@ -1756,24 +5845,85 @@ func (txn *Txn) Uint32(columnName string) uint32Writer {
 	}
 }
 
+// Uint32s returns an iterator over the values of a uint32 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Uint32s(columnName string) func(yield func(uint32) bool) {
+	reader := uint32ReaderFor(txn, columnName)
+	return func(yield func(uint32) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Uint32Slice returns a read-only view over the values of a uint32 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Uint32Slice(columnName string) ([]uint32, bitmap.Bitmap) {
+	var values *uint32Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*uint32Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "uint32")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
+
 // --------------------------- Uint64s ----------------------------
 
 // uint64Column represents a generic column
 type uint64Column struct {
-	fill bitmap.Bitmap // The fill-list
-	data []uint64      // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []uint64      // The actual values
+	enc    *encoded      // The encoded values, if an encoding is used
+	disk   *mapped       // The storage of the values, if the column is mounted
+	merger               // The merge function of the values, if any
 }
 
 // makeUint64s creates a new vector for Uint64s
-func makeUint64s() Column {
-	return &uint64Column{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]uint64, 0, 64),
+func makeUint64s(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &uint64Column{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]uint64, 0, 64),
+		merger: merger{config.merge},
+	}
+
+	if config.encoding != Plain {
+		column.data = nil
+		column.enc = newEncoded(config.encoding)
 	}
+	return column
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *uint64Column) Grow(idx uint32) {
+	if c.enc != nil {
+		c.fill.Grow(idx)
+		c.enc.grow(idx)
+		return
+	}
+
+	if c.disk != nil {
+		c.reserve(idx) // The buffers were reserved before the commit
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -1790,8 +5940,85 @@ func (c *uint64Column) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *uint64Column) Shrink(size uint32) {
+	if c.enc != nil {
+		c.enc.shrink(size)
+		c.fill = shrinkBitmap(c.fill, size)
+		return
+	}
+
+	// The buffers of a mounted column are never truncated, since readers may still be
+	// using them.
+	if c.disk != nil || uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]uint64, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
+// mount backs the values and the fill-list of the column with buffers of the storage, and
+// restores them if they were previously persisted. Encoded columns remain in memory.
+func (c *uint64Column) mount(m *mapped) error {
+	if c.enc != nil {
+		return nil
+	}
+
+	if err := m.open(".fill", unsafe.Pointer(&c.fill), 8); err != nil {
+		return err
+	}
+
+	if err := m.open(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint64(0))); err != nil {
+		return err
+	}
+
+	c.disk = m
+	return nil
+}
+
+// reserve grows the buffers of the storage until they are able to store the index, if the
+// column is mounted.
+func (c *uint64Column) reserve(idx uint32) error {
+	if c.disk == nil {
+		return nil
+	}
+
+	if err := c.disk.grow(".fill", unsafe.Pointer(&c.fill), 8, int(idx>>6)+1); err != nil {
+		return err
+	}
+	return c.disk.grow(".data", unsafe.Pointer(&c.data), unsafe.Sizeof(uint64(0)), int(idx)+1)
+}
+
+// release releases the memory of the values of the chunk to the storage, if mounted
+func (c *uint64Column) release(chunk commit.Chunk) {
+	if c.disk != nil {
+		size := int(unsafe.Sizeof(uint64(0)))
+		c.disk.release(".data", int(chunk.Min())*size, chunkSize*size)
+	}
+}
+
+// compact releases the memory held by the values of the deleted rows of the chunk, by
+// encoding their blocks again or, if the column is mounted and no row of the chunk has a
+// value, by releasing the chunk to the storage. This must be called while the chunk is locked.
+func (c *uint64Column) compact(chunk commit.Chunk) {
+	switch {
+	case c.enc != nil:
+		c.enc.compact(chunk, c.fill)
+	case c.disk != nil && !hasValues(chunk, c.fill):
+		c.release(chunk)
+	}
+}
+
 // Apply applies a set of operations to the column.
 func (c *uint64Column) Apply(r *commit.Reader) {
+	if c.enc != nil {
+		c.applyEncoded(r)
+		return
+	}
+
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
@@ -1800,9 +6027,15 @@ func (c *uint64Column) Apply(r *commit.Reader) {
 
 		// If this is an atomic increment/decrement, we need to change the operation to
 		// the final value, since after this update an index needs to be recalculated.
+		// The addition starts from zero if the row has no value, rather than the value the
+		// row had before it was deleted.
 		case commit.Add:
+			value := r.Uint64()
+			if c.fill.Contains(r.Index()) {
+				value += c.data[r.Offset]
+			}
+
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			value := c.data[r.Offset] + r.Uint64()
 			c.data[r.Offset] = value
 			r.SwapUint64(value)
 
@@ -1812,6 +6045,84 @@ func (c *uint64Column) Apply(r *commit.Reader) {
 	}
 }
 
+// applyEncoded applies a set of operations to the encoded values, each modified block
+// is decoded once and encoded back when the cursor moves past it.
+func (c *uint64Column) applyEncoded(r *commit.Reader) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint64ToBits(r.Uint64()))
+
+		case commit.Add:
+			value := r.Uint64()
+			if c.fill.Contains(r.Index()) {
+				value += uint64FromBits(cursor.load(c.enc, uint32(r.Offset)))
+			}
+
+			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
+			cursor.store(c.enc, uint32(r.Offset), uint64ToBits(value))
+			r.SwapUint64(value)
+
+		case commit.Delete:
+			c.fill.Remove(r.Index())
+		}
+	}
+
+	cursor.flush(c.enc)
+}
+
+// Encoding returns the encoding used by the column.
+func (c *uint64Column) Encoding() Encoding {
+	if c.enc != nil {
+		return c.enc.codec
+	}
+	return Plain
+}
+
+// size returns the uint64 of values the column is able to store
+func (c *uint64Column) size() uint32 {
+	if c.enc != nil {
+		return c.enc.size
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *uint64Column) at(idx uint32) uint64 {
+	if c.enc != nil {
+		return uint64FromBits(c.enc.load(idx))
+	}
+	return c.data[idx]
+}
+
+// uint64ToBits converts the uint64 into its raw bits
+func uint64ToBits(v uint64) (bits uint64) {
+	*(*uint64)(unsafe.Pointer(&bits)) = v
+	return
+}
+
+// uint64FromBits converts the raw bits back into the uint64
+func uint64FromBits(bits uint64) uint64 {
+	return *(*uint64)(unsafe.Pointer(&bits))
+}
+
+// usage returns the memory used by the column
+func (c *uint64Column) usage() ColumnUsage {
+	size := cap(c.data) * int(unsafe.Sizeof(uint64(0)))
+	if c.enc != nil {
+		size = c.enc.usage()
+	}
+
+	return ColumnUsage{
+		Data:  size,
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *uint64Column) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -1825,47 +6136,54 @@ func (c *uint64Column) Index() *bitmap.Bitmap {
 // Value retrieves a value at a specified index
 func (c *uint64Column) Value(idx uint32) (v interface{}, ok bool) {
 	v = uint64(0)
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
 
 // load retrieves a uint64 value at a specified index
 func (c *uint64Column) load(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadFloat64 retrieves a float64 value at a specified index
 func (c *uint64Column) LoadFloat64(idx uint32) (v float64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = float64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = float64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadInt64 retrieves an int64 value at a specified index
 func (c *uint64Column) LoadInt64(idx uint32) (v int64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = int64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = int64(c.at(idx)), true
 	}
 	return
 }
 
 // LoadUint64 retrieves an uint64 value at a specified index
 func (c *uint64Column) LoadUint64(idx uint32) (v uint64, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = uint64(c.data[idx]), true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = uint64(c.at(idx)), true
 	}
 	return
 }
 
 // FilterFloat64 filters down the values based on the specified predicate.
 func (c *uint64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predicate func(v float64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint64) bool {
+			return predicate(float64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(float64(c.data[idx]))
@@ -1874,7 +6192,14 @@ func (c *uint64Column) FilterFloat64(offset uint32, index bitmap.Bitmap, predica
 
 // FilterInt64 filters down the values based on the specified predicate.
 func (c *uint64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate func(v int64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint64) bool {
+			return predicate(int64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(int64(c.data[idx]))
@@ -1883,15 +6208,118 @@ func (c *uint64Column) FilterInt64(offset uint32, index bitmap.Bitmap, predicate
 
 // FilterUint64 filters down the values based on the specified predicate.
 func (c *uint64Column) FilterUint64(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint64) bool {
+			return predicate(uint64(v))
+		})
+		return
+	}
+
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
 		return idx < uint32(len(c.data)) && predicate(uint64(c.data[idx]))
 	})
 }
 
+// filterEncoded filters down the encoded values, decoding them one block at a time.
+func (c *uint64Column) filterEncoded(offset uint32, index bitmap.Bitmap, predicate func(v uint64) bool) {
+	cursor := acquireCursor()
+	defer cursor.release()
+
+	index.Filter(func(idx uint32) bool {
+		idx = offset + idx
+		return idx < c.enc.size && predicate(uint64FromBits(cursor.load(c.enc, idx)))
+	})
+}
+
+// filterRange filters down the values to the ones within the inclusive range. Rather
+// than calling a predicate for every value, the comparisons are evaluated over the 64
+// values of each word of the index at once.
+func (c *uint64Column) filterRange(offset uint32, index bitmap.Bitmap, lo, hi float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		c.filterEncoded(offset, index, func(v uint64) bool {
+			return float64(v) >= lo && float64(v) <= hi
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			index[i] = word & uint64RangeMask(c.data[at:at+64], lo, hi)
+		default:
+			for j := 0; j < 64; j++ {
+				if at+j >= len(c.data) || float64(c.data[at+j]) < lo || float64(c.data[at+j]) > hi {
+					word &^= 1 << j
+				}
+			}
+			index[i] = word
+		}
+	}
+}
+
+// loadFloat64s loads the values of the rows of a chunk selected by the index into a dense
+// vector, where dst[x] is the value of the row at offset+x, and removes the rows without a
+// value from the index.
+func (c *uint64Column) loadFloat64s(offset uint32, index bitmap.Bitmap, dst []float64) {
+	andFill(index, c.fill, offset)
+	if c.enc != nil {
+		index.Range(func(x uint32) {
+			dst[x] = float64(c.at(offset + x))
+		})
+		return
+	}
+
+	for i, word := range index {
+		at := int(offset) + i<<6
+		switch {
+		case word == 0:
+			continue
+		case at+64 <= len(c.data):
+			values, out := c.data[at:at+64], dst[i<<6:i<<6+64]
+			for j := range out {
+				out[j] = float64(values[j])
+			}
+		default:
+			for j := 0; j < 64 && at+j < len(c.data); j++ {
+				dst[i<<6+j] = float64(c.data[at+j])
+			}
+		}
+	}
+}
+
+// uint64RangeMask returns the mask of the 64 values which are within the inclusive range
+func uint64RangeMask(values []uint64, lo, hi float64) (mask uint64) {
+	values = values[:64]
+	for j := 0; j < 64; j += 8 {
+		mask |= bit(float64(values[j]) >= lo && float64(values[j]) <= hi) << j
+		mask |= bit(float64(values[j+1]) >= lo && float64(values[j+1]) <= hi) << (j + 1)
+		mask |= bit(float64(values[j+2]) >= lo && float64(values[j+2]) <= hi) << (j + 2)
+		mask |= bit(float64(values[j+3]) >= lo && float64(values[j+3]) <= hi) << (j + 3)
+		mask |= bit(float64(values[j+4]) >= lo && float64(values[j+4]) <= hi) << (j + 4)
+		mask |= bit(float64(values[j+5]) >= lo && float64(values[j+5]) <= hi) << (j + 5)
+		mask |= bit(float64(values[j+6]) >= lo && float64(values[j+6]) <= hi) << (j + 6)
+		mask |= bit(float64(values[j+7]) >= lo && float64(values[j+7]) <= hi) << (j + 7)
+	}
+	return
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *uint64Column) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	if c.enc != nil {
+		cursor := acquireCursor()
+		defer cursor.release()
+		chunk.Range(c.fill, func(idx uint32) {
+			dst.PutUint64(idx, uint64FromBits(cursor.load(c.enc, idx)))
+		})
+		return
+	}
+
 	chunk.Range(c.fill, func(idx uint32) {
 		dst.PutUint64(idx, c.data[idx])
 	})
@@ -1910,14 +6338,15 @@ func (s uint64Reader) Get() (uint64, bool) {
 
 // uint64ReaderFor creates a new uint64 reader
 func uint64ReaderFor(txn *Txn, columnName string) uint64Reader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*uint64Column)(nil), false
+	if exists {
+		reader, ok = column.Column.(*uint64Column)
 	}
 
-	reader, ok := column.Column.(*uint64Column)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type %T", columnName, uint64(0)))
+		txn.misuse(columnName, exists, "uint64")
+		reader = makeUint64s().(*uint64Column)
 	}
 
 	return uint64Reader{
@@ -1949,3 +6378,42 @@ func (txn *Txn) Uint64(columnName string) uint64Writer {
 		writer:       txn.bufferFor(columnName),
 	}
 }
+
+// Uint64s returns an iterator over the values of a uint64 column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Uint64s(columnName string) func(yield func(uint64) bool) {
+	reader := uint64ReaderFor(txn, columnName)
+	return func(yield func(uint64) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
+
+// Uint64Slice returns a read-only view over the values of a uint64 column, without copying
+// them. The value of a row is at its index, and the validity bitmap tells which rows have a
+// value, as the buffers of an Arrow array. The view is only valid until the transaction
+// ends and must not be written to.
+func (txn *Txn) Uint64Slice(columnName string) ([]uint64, bitmap.Bitmap) {
+	var values *uint64Column
+	column, ok := txn.columnAt(columnName)
+	if ok {
+		values, _ = column.Column.(*uint64Column)
+	}
+
+	switch {
+	case values == nil:
+		txn.misuse(columnName, ok, "uint64")
+		return nil, nil
+	case !txn.pin(columnName, values.Encoding()):
+		return nil, nil
+	}
+
+	txn.owner.lock.RLock()
+	data, fill := values.data, values.fill
+	txn.owner.lock.RUnlock()
+	return data[:len(data):len(data)], fill[:len(fill):len(fill)]
+}
@@ -4,8 +4,9 @@
 package column
 
 import (
-	"fmt"
 	"math"
+	"sync"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -17,74 +18,351 @@ import (
 
 var _ Textual = new(columnEnum)
 
+// overflowAt is the location of the values which did not fit into the dictionary
+const overflowAt = math.MaxUint32
+
 // columnEnum represents a string column
 type columnEnum struct {
-	fill bitmap.Bitmap // The fill-list
-	locs []uint32      // The list of locations
-	seek *intmap.Sync  // The hash->location table
-	data []string      // The string data
+	fill   bitmap.Bitmap // The fill-list
+	locs   *codes        // The dictionary codes of the rows
+	widen  sync.RWMutex  // The lock held exclusively while the codes are widened
+	width  int           // The width of the codes the column was created with
+	seek   *intmap.Sync  // The hash->location table
+	data   []string      // The string data
+	seed   []string      // The values the dictionary was seeded with
+	max    int           // The maximum number of values in the dictionary
+	over   sync.Map      // The values which did not fit into the dictionary, by index
+	arena  *arena        // The arena storing the dictionary, if one is used
+	order  Collation     // The collation of the values
+	marks  *enumMarks    // The codes in use while the dictionary is swept, see Vacuum()
+	holes  bitmap.Bitmap // The codes which were swept and can be reused
+	merger               // The merge function of the values, if any
+}
+
+// enumMarks represents the codes of a dictionary which are found in use, while its unused
+// values are being swept chunk by chunk.
+type enumMarks struct {
+	lock sync.Mutex
+	used bitmap.Bitmap
 }
 
 // makeEnum creates a new column
-func makeEnum() Column {
-	return &columnEnum{
-		fill: make(bitmap.Bitmap, 0, 4),
-		locs: make([]uint32, 0, 64),
-		seek: intmap.NewSync(64, .95),
-		data: make([]string, 0, 64),
+func makeEnum(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &columnEnum{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		locs:   newCodes(config.width, 64),
+		width:  config.width,
+		seek:   intmap.NewSync(64, .95),
+		data:   make([]string, 0, 64),
+		seed:   config.dictionary,
+		max:    config.cardinality,
+		order:  config.collation,
+		merger: merger{config.merge},
+	}
+
+	// Until the column is added to a collection, it uses its own arena
+	if config.arena {
+		column.arena = newArena()
+	}
+
+	for _, v := range column.seed {
+		column.findOrAdd([]byte(v))
+	}
+	return column
+}
+
+// options returns the options the column was created with
+func (c *columnEnum) options() []ColumnOption {
+	opts := []ColumnOption{
+		WithCardinality(c.max),
+		WithDictionary(c.seed...),
+	}
+
+	if c.arena != nil {
+		opts = append(opts, WithArena())
+	}
+	if c.order != CollateBinary {
+		opts = append(opts, WithCollation(c.order))
 	}
+	if c.width != 0 {
+		opts = append(opts, WithCodeWidth(c.width))
+	}
+	return opts
+}
+
+// collation returns the collation of the values
+func (c *columnEnum) collation() Collation {
+	return c.order
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *columnEnum) Grow(idx uint32) {
-	if idx < uint32(len(c.locs)) {
+	if idx < uint32(c.locs.len()) {
 		return
 	}
 
-	if idx < uint32(cap(c.locs)) {
-		c.fill.Grow(idx)
-		c.locs = c.locs[:idx+1]
+	c.fill.Grow(idx)
+	c.locs.grow(idx)
+}
+
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnEnum) Shrink(size uint32) {
+	if uint32(c.locs.capacity()) <= size {
 		return
 	}
 
-	c.fill.Grow(idx)
-	clone := make([]uint32, idx+1, resize(cap(c.locs), idx+1))
-	copy(clone, c.locs)
-	c.locs = clone
+	length := shrink(c.locs.len(), size)
+	c.locs.resize(length, length)
+	c.fill = shrinkBitmap(c.fill, size)
 }
 
-// Apply applies a set of operations to the column.
+// Apply applies a set of operations to the column. Other chunks may be applied at the
+// same time, so the codes are only widened while none of them is being written.
 func (c *columnEnum) Apply(r *commit.Reader) {
+	c.widen.RLock()
+	defer c.widen.RUnlock()
 	for r.Next() {
 		switch r.Type {
 		case commit.Put:
 			// Set the value at the index
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			c.locs[r.Offset] = c.findOrAdd(r.Bytes())
+			if c.locs.get(uint32(r.Offset)) == overflowAt {
+				c.over.Delete(r.Index())
+			}
+
+			// Widen the codes if the dictionary has outgrown them
+			at := c.findOrAdd(r.Bytes())
+			if !c.locs.fits(at) {
+				c.widen.RUnlock()
+				c.widen.Lock()
+				c.locs = c.locs.widen(at)
+				c.widen.Unlock()
+				c.widen.RLock()
+			}
+
+			// If the dictionary is full, keep a copy of the string for this row only, since
+			// the buffer of the commit is reused afterwards
+			if c.locs.set(uint32(r.Offset), at); at == overflowAt {
+				c.over.Store(r.Index(), string(r.Bytes()))
+			}
 
 		case commit.Delete:
 			c.fill.Remove(r.Index())
-			// TODO: remove unused strings, need some reference counting for that
-			// and can proably be done during vacuum() instead
+			if c.locs.get(uint32(r.Offset)) == overflowAt {
+				c.over.Delete(r.Index())
+				c.locs.set(uint32(r.Offset), 0)
+			}
+			// Unused strings are removed from the dictionary by Vacuum()
 		}
 	}
 }
 
-// Search for the string or adds it and returns the offset
+// compact rebuilds the dictionary, dropping the strings which are no longer referenced by
+// any of the rows. This must be called while all of the chunks are locked.
+func (c *columnEnum) compact() {
+	remap := make(map[uint32]uint32, len(c.data))
+	data := make([]string, 0, len(c.data))
+	seek := intmap.NewSync(64, .95)
+
+	// The seeded values are always retained, at the beginning of the dictionary
+	for _, v := range c.seed {
+		if _, ok := seek.Load(uint32(xxh3.HashString(v))); !ok {
+			seek.Store(uint32(xxh3.HashString(v)), uint32(len(data)))
+			data = append(data, v)
+		}
+	}
+
+	c.fill.Range(func(idx uint32) {
+		at := c.locs.get(idx)
+		if at == overflowAt {
+			return
+		}
+
+		loc, ok := remap[at]
+		if !ok {
+			hash := uint32(xxh3.HashString(c.data[at]))
+			if loc, ok = seek.Load(hash); !ok {
+				loc = uint32(len(data))
+				data = append(data, c.data[at])
+				seek.Store(hash, loc)
+			}
+			remap[at] = loc
+		}
+
+		c.locs.set(idx, loc)
+	})
+
+	c.data = data
+	c.seek = seek
+	c.holes.Clear()
+}
+
+// beginSweep starts to mark the codes which are in use, including the ones of the seeded
+// values and of the values written until endSweep is called. This must be called while the
+// column is exclusively locked.
+func (c *columnEnum) beginSweep() {
+	c.marks = new(enumMarks)
+	for _, v := range c.seed {
+		if at, ok := c.codeOf(v); ok {
+			c.marks.used.Set(at)
+		}
+	}
+}
+
+// markChunk marks the codes used by the rows of the chunk. This must be called while the
+// chunk is locked.
+func (c *columnEnum) markChunk(chunk commit.Chunk) {
+	c.marks.lock.Lock()
+	defer c.marks.lock.Unlock()
+	chunk.Range(c.fill, func(idx uint32) {
+		if at := c.locs.get(idx); at != overflowAt {
+			c.marks.used.Set(at)
+		}
+	})
+}
+
+// endSweep removes the values which were not marked from the dictionary, without changing
+// the codes of the others, so the rows do not need to be remapped. The codes of the values
+// removed are reused for the next values added. This must be called while the column is
+// exclusively locked.
+func (c *columnEnum) endSweep() {
+	used := c.marks.used
+	c.marks = nil
+	for at := range c.data {
+		if used.Contains(uint32(at)) || c.holes.Contains(uint32(at)) {
+			continue
+		}
+
+		hash := uint32(xxh3.HashString(c.data[at]))
+		if loc, ok := c.seek.Load(hash); ok && loc == uint32(at) {
+			c.seek.Delete(hash)
+		}
+
+		c.data[at] = ""
+		c.holes.Set(uint32(at))
+	}
+}
+
+// mark marks the code of a value written while the dictionary is swept
+func (c *columnEnum) mark(at uint32) {
+	if c.marks != nil && at != overflowAt {
+		c.marks.lock.Lock()
+		c.marks.used.Set(at)
+		c.marks.lock.Unlock()
+	}
+}
+
+// Search for the string or adds it and returns the offset. If the dictionary is
+// full, the value is not added and the overflow location is returned instead.
 func (c *columnEnum) findOrAdd(v []byte) uint32 {
 	target := uint32(xxh3.Hash(v))
+	if at, ok := c.seek.Load(target); ok {
+		c.mark(at)
+		return at
+	}
+
 	at, _ := c.seek.LoadOrStore(target, func() uint32 {
-		c.data = append(c.data, string(v))
+		if at, ok := c.holes.Min(); ok {
+			c.holes.Remove(at)
+			if c.arena != nil {
+				c.data[at] = c.arena.string(v)
+			} else {
+				c.data[at] = string(v)
+			}
+			return at
+		}
+
+		if c.max > 0 && len(c.data) >= c.max {
+			return overflowAt
+		}
+
+		if c.arena != nil {
+			c.data = append(c.data, c.arena.string(v))
+		} else {
+			c.data = append(c.data, string(v))
+		}
 		return uint32(len(c.data)) - 1
 	})
+
+	c.mark(at)
 	return at
 }
 
+// attach attaches the column to the arena of the collection
+func (c *columnEnum) attach(a *arena) {
+	if c.arena == nil {
+		return
+	}
+
+	// The seeded values were already allocated, copy them into the new arena
+	c.relocate(a)
+}
+
+// liveBytes returns the number of bytes used by the dictionary in the arena
+func (c *columnEnum) liveBytes() (size int) {
+	if c.arena != nil {
+		for _, v := range c.data {
+			size += len(v)
+		}
+	}
+	return
+}
+
+// relocate copies the dictionary into another arena and uses it
+func (c *columnEnum) relocate(dst *arena) {
+	if c.arena == nil {
+		return
+	}
+
+	for i, v := range c.data {
+		c.data[i] = dst.string(bytesOf(v))
+	}
+	c.arena = dst
+}
+
 // readAt reads a string at a location
 func (c *columnEnum) readAt(at uint32) string {
 	return c.data[at]
 }
 
+// stringAt reads the string of a row, which is either in the dictionary or overflown
+func (c *columnEnum) stringAt(idx uint32) string {
+	if at := c.locs.get(idx); at != overflowAt {
+		return c.data[at]
+	}
+
+	v, _ := c.over.Load(idx)
+	s, _ := v.(string)
+	return s
+}
+
+// usage returns the memory used by the column
+func (c *columnEnum) usage() ColumnUsage {
+	overflow := 0
+	c.over.Range(func(_, v interface{}) bool {
+		overflow += len(v.(string)) + int(unsafe.Sizeof(""))
+		return true
+	})
+
+	return ColumnUsage{
+		Data:       c.locs.size() + overflow,
+		Dictionary: sizeOfStrings(c.data) + c.seek.Count()*8,
+		Index:      sizeOfBitmap(c.fill),
+	}
+}
+
+// Dictionary returns a copy of the distinct values stored in the dictionary.
+func (c *columnEnum) Dictionary() []string {
+	out := make([]string, 0, len(c.data))
+	for at, v := range c.data {
+		if !c.holes.Contains(uint32(at)) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 // Value retrieves a value at a specified index
 func (c *columnEnum) Value(idx uint32) (v interface{}, ok bool) {
 	return c.LoadString(idx)
@@ -92,8 +370,8 @@ func (c *columnEnum) Value(idx uint32) (v interface{}, ok bool) {
 
 // LoadString retrieves a value at a specified index
 func (c *columnEnum) LoadString(idx uint32) (v string, ok bool) {
-	if idx < uint32(len(c.locs)) && c.fill.Contains(idx) {
-		v, ok = c.readAt(c.locs[idx]), true
+	if idx < uint32(c.locs.len()) && c.fill.Contains(idx) {
+		v, ok = c.stringAt(idx), true
 	}
 	return
 }
@@ -111,13 +389,17 @@ func (c *columnEnum) FilterString(offset uint32, index bitmap.Bitmap, predicate
 
 	// Do a quick ellimination of elements which are NOT contained in this column, this
 	// allows us not to check contains during the filter itself
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
 
 	// Filters down the strings, if strings repeat we avoid reading every time by
 	// caching the last seen index/value combination.
+	locs := c.locs
 	index.Filter(func(idx uint32) bool {
 		idx = offset + idx
-		if at := c.locs[idx]; at != cache.index {
+		switch at := locs.get(idx); {
+		case at == overflowAt:
+			return predicate(c.stringAt(idx))
+		case at != cache.index:
 			cache.index = at
 			cache.value = predicate(c.readAt(at))
 			return cache.value
@@ -128,6 +410,29 @@ func (c *columnEnum) FilterString(offset uint32, index bitmap.Bitmap, predicate
 	})
 }
 
+// filterCode filters down the rows whose value has the specified code in the dictionary
+func (c *columnEnum) filterCode(offset uint32, index bitmap.Bitmap, code uint32) {
+	locs := c.locs
+	if !locs.fits(code) || code == overflowAt {
+		for i := range index {
+			index[i] = 0
+		}
+		return
+	}
+
+	andFill(index, c.fill, offset)
+	locs.filter(offset, index, code)
+}
+
+// codeOf returns the code of a value in the dictionary, if it was added to it
+func (c *columnEnum) codeOf(value string) (uint32, bool) {
+	at, ok := c.seek.Load(uint32(xxh3.HashString(value)))
+	if !ok || at == overflowAt || c.data[at] != value {
+		return 0, false
+	}
+	return at, true
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *columnEnum) Contains(idx uint32) bool {
 	return c.fill.Contains(idx)
@@ -141,7 +446,7 @@ func (c *columnEnum) Index() *bitmap.Bitmap {
 // Snapshot writes the entire column into the specified destination buffer
 func (c *columnEnum) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	chunk.Range(c.fill, func(idx uint32) {
-		dst.PutString(commit.Put, idx, c.readAt(c.locs[idx]))
+		dst.PutString(commit.Put, idx, c.stringAt(idx))
 	})
 }
 
@@ -149,28 +454,31 @@ func (c *columnEnum) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 type enumReader struct {
 	cursor *uint32
 	reader *columnEnum
+	masked masking
 }
 
 // Get loads the value at the current transaction cursor
 func (s enumReader) Get() (string, bool) {
-	return s.reader.LoadString(*s.cursor)
+	return s.masked.apply(s.reader.LoadString(*s.cursor))
 }
 
 // enumReaderFor creates a new enum string reader
 func enumReaderFor(txn *Txn, columnName string) enumReader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*columnEnum)(nil), false
+	if exists {
+		reader, ok = column.Column.(*columnEnum)
 	}
 
-	reader, ok := column.Column.(*columnEnum)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type string", columnName))
+		txn.misuse(columnName, exists, "string")
+		reader = makeEnum().(*columnEnum)
 	}
 
 	return enumReader{
 		cursor: &txn.cursor,
 		reader: reader,
+		masked: txn.maskOf(columnName),
 	}
 }
 
@@ -199,20 +507,63 @@ var _ Textual = new(columnString)
 
 // columnString represents a string column
 type columnString struct {
-	fill bitmap.Bitmap // The fill-list
-	data []string      // The actual values
+	fill   bitmap.Bitmap // The fill-list
+	data   []string      // The actual values
+	arena  *arena        // The arena storing the values, if one is used
+	spans  []span        // The locations of the values in the arena
+	intern bool          // Whether identical values share the same location
+	order  Collation     // The collation of the values
+	merger               // The merge function of the values, if any
 }
 
 // makeString creates a new string column
-func makeStrings() Column {
-	return &columnString{
-		fill: make(bitmap.Bitmap, 0, 4),
-		data: make([]string, 0, 64),
+func makeStrings(opts ...ColumnOption) Column {
+	config := configure(opts)
+	column := &columnString{
+		fill:   make(bitmap.Bitmap, 0, 4),
+		data:   make([]string, 0, 64),
+		order:  config.collation,
+		merger: merger{config.merge},
+	}
+
+	// Until the column is added to a collection, it uses its own arena
+	if config.arena || config.intern {
+		column.data = nil
+		column.arena = newArena()
+		column.spans = make([]span, 0, 64)
+		column.intern = config.intern
+	}
+	return column
+}
+
+// options returns the options the column was created with
+func (c *columnString) options() (opts []ColumnOption) {
+	switch {
+	case c.intern:
+		opts = append(opts, WithInterning())
+	case c.arena != nil:
+		opts = append(opts, WithArena())
 	}
+
+	if c.order != CollateBinary {
+		opts = append(opts, WithCollation(c.order))
+	}
+	return
+}
+
+// collation returns the collation of the values
+func (c *columnString) collation() Collation {
+	return c.order
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *columnString) Grow(idx uint32) {
+	if c.arena != nil {
+		c.fill.Grow(idx)
+		c.spans = growSpans(c.spans, idx)
+		return
+	}
+
 	if idx < uint32(len(c.data)) {
 		return
 	}
@@ -229,6 +580,28 @@ func (c *columnString) Grow(idx uint32) {
 	c.data = clone
 }
 
+// Shrink releases the capacity of the column beyond the specified size
+func (c *columnString) Shrink(size uint32) {
+	if c.arena != nil {
+		if uint32(cap(c.spans)) > size {
+			clone := make([]span, shrink(len(c.spans), size))
+			copy(clone, c.spans)
+			c.spans = clone
+			c.fill = shrinkBitmap(c.fill, size)
+		}
+		return
+	}
+
+	if uint32(cap(c.data)) <= size {
+		return
+	}
+
+	clone := make([]string, shrink(len(c.data), size))
+	copy(clone, c.data)
+	c.data = clone
+	c.fill = shrinkBitmap(c.fill, size)
+}
+
 // Apply applies a set of operations to the column.
 func (c *columnString) Apply(r *commit.Reader) {
 
@@ -237,17 +610,118 @@ func (c *columnString) Apply(r *commit.Reader) {
 		switch r.Type {
 		case commit.Put:
 			c.fill[r.Offset>>6] |= 1 << (r.Offset & 0x3f)
-			c.data[r.Offset] = string(r.Bytes())
+			switch {
+			case c.intern:
+				c.spans[r.Offset] = c.arena.internBytes(r.Bytes())
+			case c.arena != nil:
+				c.spans[r.Offset] = c.arena.alloc(r.Bytes())
+			default:
+				c.data[r.Offset] = string(r.Bytes())
+			}
 		case commit.Delete:
 			c.fill.Remove(r.Index())
 		}
 	}
 }
 
+// compact releases the strings of the deleted rows in the chunk, so they can be
+// garbage-collected. This must be called while the chunk is locked.
+func (c *columnString) compact(chunk commit.Chunk) {
+	if c.arena != nil {
+		return // The arena is compacted as a whole
+	}
+
+	max := chunk.Max() + 1
+	if max > uint32(len(c.data)) {
+		max = uint32(len(c.data))
+	}
+
+	for idx := chunk.Min(); idx < max; idx++ {
+		if !c.fill.Contains(idx) {
+			c.data[idx] = ""
+		}
+	}
+}
+
+// attach attaches the column to the arena of the collection
+func (c *columnString) attach(a *arena) {
+	if c.arena != nil {
+		c.arena = a
+	}
+}
+
+// liveBytes returns the number of bytes used by the strings in the arena
+func (c *columnString) liveBytes() (size int) {
+	if c.arena == nil {
+		return 0
+	}
+
+	c.fill.Range(func(idx uint32) {
+		if idx < uint32(len(c.spans)) {
+			size += int(c.spans[idx].length)
+		}
+	})
+	return
+}
+
+// relocate copies the live strings into another arena and uses it
+func (c *columnString) relocate(dst *arena) {
+	if c.arena == nil {
+		return
+	}
+
+	spans := make([]span, len(c.spans), cap(c.spans))
+	c.fill.Range(func(idx uint32) {
+		if idx >= uint32(len(c.spans)) {
+			return
+		}
+
+		if v := bytesOf(c.arena.read(c.spans[idx])); c.intern {
+			spans[idx] = dst.internBytes(v)
+		} else {
+			spans[idx] = dst.alloc(v)
+		}
+	})
+
+	c.arena = dst
+	c.spans = spans
+}
+
+// usage returns the memory used by the column
+func (c *columnString) usage() ColumnUsage {
+	if c.arena != nil {
+		return ColumnUsage{
+			Data:  cap(c.spans) * int(unsafe.Sizeof(span{})),
+			Index: sizeOfBitmap(c.fill),
+		}
+	}
+
+	return ColumnUsage{
+		Data:  sizeOfStrings(c.data),
+		Index: sizeOfBitmap(c.fill),
+	}
+}
+
+// size returns the number of values the column is able to store
+func (c *columnString) size() uint32 {
+	if c.arena != nil {
+		return uint32(len(c.spans))
+	}
+	return uint32(len(c.data))
+}
+
+// at returns the value stored at the index, without checking the bounds
+func (c *columnString) at(idx uint32) string {
+	if c.arena != nil {
+		return c.arena.read(c.spans[idx])
+	}
+	return c.data[idx]
+}
+
 // Value retrieves a value at a specified index
 func (c *columnString) Value(idx uint32) (v interface{}, ok bool) {
-	if idx < uint32(len(c.data)) && c.fill.Contains(idx) {
-		v, ok = c.data[idx], true
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
 	}
 	return
 }
@@ -263,26 +737,28 @@ func (c *columnString) Index() *bitmap.Bitmap {
 }
 
 // LoadString retrieves a value at a specified index
-func (c *columnString) LoadString(idx uint32) (string, bool) {
-	v, has := c.Value(idx)
-	s, ok := v.(string)
-	return s, has && ok
+func (c *columnString) LoadString(idx uint32) (v string, ok bool) {
+	if idx < c.size() && c.fill.Contains(idx) {
+		v, ok = c.at(idx), true
+	}
+	return
 }
 
 // FilterString filters down the values based on the specified predicate. The column for
 // this filter must be a string.
 func (c *columnString) FilterString(offset uint32, index bitmap.Bitmap, predicate func(v string) bool) {
-	index.And(c.fill[offset>>6 : int(offset>>6)+len(index)])
+	andFill(index, c.fill, offset)
+	size := c.size()
 	index.Filter(func(idx uint32) (match bool) {
 		idx = offset + idx
-		return idx < uint32(len(c.data)) && predicate(c.data[idx])
+		return idx < size && predicate(c.at(idx))
 	})
 }
 
 // Snapshot writes the entire column into the specified destination buffer
 func (c *columnString) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	chunk.Range(c.fill, func(idx uint32) {
-		dst.PutString(commit.Put, idx, c.data[idx])
+		dst.PutString(commit.Put, idx, c.at(idx))
 	})
 }
 
@@ -290,28 +766,31 @@ func (c *columnString) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 type stringReader struct {
 	cursor *uint32
 	reader *columnString
+	masked masking
 }
 
 // Get loads the value at the current transaction cursor
 func (s stringReader) Get() (string, bool) {
-	return s.reader.LoadString(*s.cursor)
+	return s.masked.apply(s.reader.LoadString(*s.cursor))
 }
 
 // stringReaderFor creates a new string reader
 func stringReaderFor(txn *Txn, columnName string) stringReader {
-	column, ok := txn.columnAt(columnName)
-	if !ok {
-		panic(fmt.Errorf("column: column '%s' does not exist", columnName))
+	column, exists := txn.columnAt(columnName)
+	reader, ok := (*columnString)(nil), false
+	if exists {
+		reader, ok = column.Column.(*columnString)
 	}
 
-	reader, ok := column.Column.(*columnString)
 	if !ok {
-		panic(fmt.Errorf("column: column '%s' is not of type string", columnName))
+		txn.misuse(columnName, exists, "string")
+		reader = makeStrings().(*columnString)
 	}
 
 	return stringReader{
 		cursor: &txn.cursor,
 		reader: reader,
+		masked: txn.maskOf(columnName),
 	}
 }
 
@@ -333,3 +812,17 @@ func (txn *Txn) String(columnName string) stringWriter {
 		writer:       txn.bufferFor(columnName),
 	}
 }
+
+// Strings returns an iterator over the values of a string column for the selected rows,
+// which can be used in a range-over-func loop. The rows without a value are skipped.
+func (txn *Txn) Strings(columnName string) func(yield func(string) bool) {
+	reader := stringReaderFor(txn, columnName)
+	return func(yield func(string) bool) {
+		txn.each(func(uint32) bool {
+			if v, ok := reader.Get(); ok {
+				return yield(v)
+			}
+			return true
+		})
+	}
+}
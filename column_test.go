@@ -4,9 +4,12 @@
 package column
 
 import (
+	"bytes"
 	"fmt"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -22,15 +25,23 @@ func TestColumns(t *testing.T) {
 		{column: ForBool(), value: true},
 		{column: ForString(), value: "test"},
 		{column: ForInt(), value: int(99)},
+		{column: ForInt8(), value: int8(99)},
 		{column: ForInt16(), value: int16(99)},
 		{column: ForInt32(), value: int32(99)},
 		{column: ForInt64(), value: int64(99)},
 		{column: ForUint(), value: uint(99)},
+		{column: ForUint8(), value: uint8(99)},
 		{column: ForUint16(), value: uint16(99)},
 		{column: ForUint32(), value: uint32(99)},
 		{column: ForUint64(), value: uint64(99)},
 		{column: ForFloat32(), value: float32(99.5)},
 		{column: ForFloat64(), value: float64(99.5)},
+		{column: ForInt8(WithEncoding(Delta)), value: int8(-99)},
+		{column: ForInt16(WithEncoding(Delta)), value: int16(-99)},
+		{column: ForInt64(WithEncoding(Delta)), value: int64(99)},
+		{column: ForUint32(WithEncoding(RLE)), value: uint32(99)},
+		{column: ForFloat32(WithEncoding(RLE)), value: float32(99.5)},
+		{column: ForFloat64(WithEncoding(Delta)), value: float64(-99.5)},
 	}
 
 	for _, tc := range tests {
@@ -192,8 +203,8 @@ func testSnapshot(t *testing.T, column Column, value interface{}) {
 
 func TestFromKind(t *testing.T) {
 	for _, v := range []reflect.Kind{
-		reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Bool, reflect.String,
 		reflect.Float32, reflect.Float64,
 	} {
@@ -360,10 +371,12 @@ func TestAccessors(t *testing.T) {
 		{column: ForEnum(), value: "mage", access: func(txn *Txn, n string) interface{} { return txn.Enum(n) }},
 		{column: ForString(), value: "test", access: func(txn *Txn, n string) interface{} { return txn.String(n) }},
 		{column: ForInt(), value: int(99), access: func(txn *Txn, n string) interface{} { return txn.Int(n) }},
+		{column: ForInt8(), value: int8(99), access: func(txn *Txn, n string) interface{} { return txn.Int8(n) }},
 		{column: ForInt16(), value: int16(99), access: func(txn *Txn, n string) interface{} { return txn.Int16(n) }},
 		{column: ForInt32(), value: int32(99), access: func(txn *Txn, n string) interface{} { return txn.Int32(n) }},
 		{column: ForInt64(), value: int64(99), access: func(txn *Txn, n string) interface{} { return txn.Int64(n) }},
 		{column: ForUint(), value: uint(99), access: func(txn *Txn, n string) interface{} { return txn.Uint(n) }},
+		{column: ForUint8(), value: uint8(99), access: func(txn *Txn, n string) interface{} { return txn.Uint8(n) }},
 		{column: ForUint16(), value: uint16(99), access: func(txn *Txn, n string) interface{} { return txn.Uint16(n) }},
 		{column: ForUint32(), value: uint32(99), access: func(txn *Txn, n string) interface{} { return txn.Uint32(n) }},
 		{column: ForUint64(), value: uint64(99), access: func(txn *Txn, n string) interface{} { return txn.Uint64(n) }},
@@ -400,21 +413,18 @@ func TestAccessors(t *testing.T) {
 				return nil
 			}))
 
-			// Invalid column  name should panic
-			assert.Panics(t, func() {
-				col.Query(func(txn *Txn) error {
-					tc.access(txn, "invalid")
-					return nil
-				})
-			})
+			// Invalid column name should fail the query, and its accessor read nothing
+			assert.ErrorIs(t, col.QueryAt(0, func(r Row) error {
+				column := tc.access(r.txn, "invalid")
+				assert.False(t, invoke(column, "Get")[1].Bool())
+				return nil
+			}), ErrColumnNotFound)
 
-			// Invalid column type should panic
-			assert.Panics(t, func() {
-				col.Query(func(txn *Txn) error {
-					tc.access(txn, "pk")
-					return nil
-				})
-			})
+			// Invalid column type should fail the query
+			assert.ErrorIs(t, col.QueryAt(0, func(r Row) error {
+				tc.access(r.txn, "pk")
+				return nil
+			}), ErrTypeMismatch)
 		})
 	}
 }
@@ -454,20 +464,17 @@ func TestColumnNotFound(t *testing.T) {
 	assert.NoError(t, col.CreateColumn("name", ForString()))
 
 	// Boolean column does not exist
-	assert.Panics(t, func() {
-		col.QueryAt(0, func(r Row) error {
-			r.txn.Bool("xxx")
-			return nil
-		})
-	})
+	assert.ErrorIs(t, col.QueryAt(0, func(r Row) error {
+		assert.False(t, r.txn.Bool("xxx").Get())
+		return nil
+	}), ErrColumnNotFound)
 
 	// Any column does not exist
-	assert.Panics(t, func() {
-		col.QueryAt(0, func(r Row) error {
-			r.txn.Any("xxx")
-			return nil
-		})
-	})
+	assert.ErrorIs(t, col.QueryAt(0, func(r Row) error {
+		_, ok := r.txn.Any("xxx").Get()
+		assert.False(t, ok)
+		return nil
+	}), ErrColumnNotFound)
 }
 
 func TestPKAccessor(t *testing.T) {
@@ -493,12 +500,11 @@ func TestPKAccessor(t *testing.T) {
 func TestInvalidPKAccessor(t *testing.T) {
 	col := NewCollection()
 	assert.NoError(t, col.CreateColumn("pk", ForString()))
-	assert.Panics(t, func() {
-		col.Query(func(txn *Txn) error {
-			txn.Key()
-			return nil
-		})
-	})
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		_, ok := txn.Key().Get()
+		assert.False(t, ok)
+		return nil
+	}), ErrColumnNotFound)
 }
 
 func TestIndexValue(t *testing.T) {
@@ -519,3 +525,511 @@ func invoke(any interface{}, name string, args ...interface{}) []reflect.Value {
 
 	return reflect.ValueOf(any).MethodByName(name).Call(inputs)
 }
+
+func TestEncodedColumns(t *testing.T) {
+	for _, encoding := range []Encoding{RLE, Delta} {
+		t.Run(fmt.Sprintf("encoding-%d", encoding), func(t *testing.T) {
+			players := NewCollection()
+			players.CreateColumn("time", ForInt64(WithEncoding(encoding)))
+			players.CreateColumn("score", ForFloat64(WithEncoding(encoding)))
+			assert.NoError(t, players.Query(func(txn *Txn) error {
+				for i := 0; i < 5000; i++ {
+					txn.Insert(func(r Row) error {
+						r.SetInt64("time", int64(1000000+i))
+						r.SetFloat64("score", float64(i/1000))
+						return nil
+					})
+				}
+				return nil
+			}))
+
+			// Update some of the values
+			assert.NoError(t, players.Query(func(txn *Txn) error {
+				score := txn.Float64("score")
+				return txn.WithInt("time", func(v int64) bool {
+					return v >= 1004000
+				}).Range(func(idx uint32) {
+					score.Add(10)
+				})
+			}))
+
+			assert.NoError(t, players.Query(func(txn *Txn) error {
+				assert.Equal(t, 1000, txn.WithFloat("score", func(v float64) bool {
+					return v == 14
+				}).Count())
+				return nil
+			}))
+
+			assert.NoError(t, players.QueryAt(4321, func(r Row) error {
+				ts, _ := r.Int64("time")
+				score, _ := r.Float64("score")
+				assert.Equal(t, int64(1004321), ts)
+				assert.Equal(t, 14.0, score)
+				return nil
+			}))
+
+			// Cursors should decode transparently
+			assert.NoError(t, players.Query(func(txn *Txn) error {
+				ts := txn.Int64("time")
+				return txn.Range(func(idx uint32) {
+					v, ok := ts.Get()
+					assert.True(t, ok)
+					assert.Equal(t, int64(1000000+idx), v)
+				})
+			}))
+
+			// Timestamps compress well with delta and scores with RLE
+			name := map[Encoding]string{RLE: "score", Delta: "time"}[encoding]
+			column, _ := players.cols.Load(name)
+			var enc *encoded
+			switch c := column.Column.(type) {
+			case *int64Column:
+				enc = c.enc
+			case *float64Column:
+				enc = c.enc
+			}
+
+			size := 0
+			for _, block := range enc.blocks {
+				size += len(block)
+			}
+			assert.Less(t, size, 5000*8/4)
+		})
+	}
+}
+
+func TestEnumCardinality(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("name", ForEnum(WithCardinality(2)))
+	for _, name := range []string{"Roman", "Merlin", "Roman", "Gandalf", "Merlin", "Gandalf"} {
+		players.InsertObject(Object{"name": name})
+	}
+
+	// Only the first two names should fit into the dictionary
+	dict, err := players.Dictionary("name")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Roman", "Merlin"}, dict)
+
+	// The overflown values should still be readable and filterable
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithString("name", func(v string) bool {
+			return v == "Gandalf"
+		}).Count())
+		return nil
+	}))
+
+	assert.NoError(t, players.QueryAt(3, func(r Row) error {
+		name, ok := r.Enum("name")
+		assert.True(t, ok)
+		assert.Equal(t, "Gandalf", name)
+		r.SetEnum("name", "Roman")
+		return nil
+	}))
+
+	assert.NoError(t, players.QueryAt(3, func(r Row) error {
+		name, _ := r.Enum("name")
+		assert.Equal(t, "Roman", name)
+		return nil
+	}))
+
+	// Snapshots should contain the overflown values as well
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, players.Snapshot(buffer))
+	other := NewCollection()
+	other.CreateColumn("name", ForEnum())
+	assert.NoError(t, other.Restore(buffer))
+	assert.NoError(t, other.QueryAt(5, func(r Row) error {
+		name, _ := r.Enum("name")
+		assert.Equal(t, "Gandalf", name)
+		return nil
+	}))
+}
+
+func TestEnumOverflowReuse(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("name", ForEnum(WithCardinality(1)))
+	players.InsertObject(Object{"name": "Roman"})
+	players.InsertObject(Object{"name": "Gandalf"})
+
+	// The commit buffers are reused by the next transactions, which must not change
+	// the overflown value kept for the row
+	for i := 0; i < 100; i++ {
+		players.InsertObject(Object{"name": "Xxxxxxx"})
+	}
+
+	assert.NoError(t, players.QueryAt(1, func(r Row) error {
+		name, _ := r.Enum("name")
+		assert.Equal(t, "Gandalf", name)
+		return nil
+	}))
+}
+
+func TestEnumDictionary(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("class", ForEnum(WithDictionary("mage", "warrior")))
+	players.CreateColumn("age", ForInt())
+	players.InsertObject(Object{"class": "rogue", "age": 10})
+	players.InsertObject(Object{"class": "mage", "age": 20})
+
+	dict, err := players.Dictionary("class")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mage", "warrior", "rogue"}, dict)
+
+	// Compacting removes the unused values, except the seeded ones
+	assert.True(t, players.DeleteAt(0))
+	assert.NoError(t, players.CompactDictionary("class"))
+	dict, err = players.Dictionary("class")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mage", "warrior"}, dict)
+
+	// Invalid columns
+	_, err = players.Dictionary("age")
+	assert.Error(t, err)
+	_, err = players.Dictionary("xxx")
+	assert.Error(t, err)
+	assert.Error(t, players.CompactDictionary("age"))
+	assert.Error(t, players.CompactDictionary("xxx"))
+}
+
+func TestEnumCodeWidth(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("name", ForEnum(WithCodeWidth(8)))
+	players.CreateColumn("age", ForInt())
+	for i := 0; i < 1000; i++ {
+		players.InsertObject(Object{"name": fmt.Sprintf("player-%d", i%300)})
+	}
+
+	// The codes were widened once the dictionary outgrew 8 bits
+	column, _ := players.cols.Load("name")
+	assert.Equal(t, 16, column.Column.(*columnEnum).locs.width)
+	assert.NoError(t, players.QueryAt(299, func(r Row) error {
+		name, _ := r.Enum("name")
+		assert.Equal(t, "player-299", name)
+		return nil
+	}))
+
+	// The rows can be filtered by the code of their value
+	code, err := players.EnumCode("name", "player-280")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(280), code)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.WithEnumCode("name", code).Count())
+		assert.Equal(t, 0, txn.WithEnumCode("name", 1<<20).Count())
+		return nil
+	}))
+
+	_, err = players.EnumCode("name", "merlin")
+	assert.Error(t, err)
+	_, err = players.EnumCode("age", "merlin")
+	assert.Error(t, err)
+	_, err = players.EnumCode("xxx", "merlin")
+	assert.Error(t, err)
+}
+
+func TestStringArena(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("name", ForString(WithArena()))
+	players.CreateColumn("guild", ForString(WithInterning()))
+	players.CreateColumn("class", ForEnum(WithArena(), WithDictionary("mage")))
+	for i := 0; i < 100; i++ {
+		players.InsertObject(Object{
+			"name":  fmt.Sprintf("player %d", i),
+			"guild": fmt.Sprintf("guild %d", i%2),
+			"class": "mage",
+		})
+	}
+
+	players.QueryAt(42, func(r Row) error {
+		name, _ := r.String("name")
+		guild, _ := r.String("guild")
+		class, _ := r.Enum("class")
+		assert.Equal(t, "player 42", name)
+		assert.Equal(t, "guild 0", guild)
+		assert.Equal(t, "mage", class)
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 50, txn.WithString("guild", func(v string) bool {
+			return v == "guild 1"
+		}).Count())
+		return nil
+	})
+
+	// Interned values are stored once, all of the columns share the same arena
+	guild := columnOf(players, "guild").(*columnString)
+	assert.Equal(t, guild.spans[0], guild.spans[2])
+	assert.NotEqual(t, guild.spans[0], guild.spans[1])
+	assert.Equal(t, players.arena, guild.arena)
+	assert.Equal(t, players.arena, columnOf(players, "class").(*columnEnum).arena)
+	assert.NotZero(t, players.MemoryUsage().Arena)
+
+	// Overwriting the values leaves garbage in the arena, until it is compacted
+	large := string(bytes.Repeat([]byte{'x'}, 1000))
+	for i := 0; i < 100; i++ {
+		players.Query(func(txn *Txn) error {
+			name := txn.String("name")
+			return txn.Range(func(idx uint32) {
+				name.Set(fmt.Sprintf("%s %d", large, idx))
+			})
+		})
+	}
+
+	before := players.arena.usage()
+	players.Vacuum()
+	assert.Less(t, players.arena.usage(), before/4)
+	players.QueryAt(42, func(r Row) error {
+		name, _ := r.String("name")
+		guild, _ := r.String("guild")
+		class, _ := r.Enum("class")
+		assert.Equal(t, large+" 42", name)
+		assert.Equal(t, "guild 0", guild)
+		assert.Equal(t, "mage", class)
+		return nil
+	})
+}
+
+func TestDurationColumn(t *testing.T) {
+	players := NewCollection()
+	assert.NoError(t, players.CreateColumnsOf(Object{"session": time.Minute}))
+	players.CreateColumn("cooldown", ForDuration())
+	for i := 0; i < 10; i++ {
+		players.InsertObject(Object{
+			"session":  time.Duration(i) * time.Minute,
+			"cooldown": 5 * time.Second,
+		})
+	}
+
+	// The values are read and written as durations
+	assert.NoError(t, players.QueryAt(3, func(r Row) error {
+		session, ok := r.Duration("session")
+		assert.True(t, ok)
+		assert.Equal(t, 3*time.Minute, session)
+		r.AddDuration("cooldown", time.Second)
+		return nil
+	}))
+
+	assert.NoError(t, players.QueryAt(3, func(r Row) error {
+		cooldown, _ := r.Duration("cooldown")
+		assert.Equal(t, 6*time.Second, cooldown)
+		assert.Equal(t, "6s", cooldown.String())
+		return nil
+	}))
+
+	// The durations can be filtered by range
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 6, txn.WithDurationGreater("session", 3*time.Minute).Count())
+		return nil
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.WithDurationLess("session", 3*time.Minute).Count())
+		return nil
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithDurationBetween("cooldown", 6*time.Second, time.Minute).Count())
+		return nil
+	}))
+
+	value, ok := players.cols.Load("session")
+	assert.True(t, ok)
+	v, _ := value.Value(2)
+	assert.Equal(t, 2*time.Minute, v)
+}
+
+func TestIPColumn(t *testing.T) {
+	for _, column := range []Column{ForIP(), ForIP(WithPrefixIndex(24, 64))} {
+		conns := NewCollection()
+		conns.CreateColumn("ip", column)
+		conns.InsertObject(Object{"ip": "10.0.0.1"})
+		conns.InsertObject(Object{"ip": net.ParseIP("10.1.2.3")})
+		conns.InsertObject(Object{"ip": "192.168.1.20"})
+		conns.InsertObject(Object{"ip": "2001:db8::1"})
+		conns.InsertObject(Object{"ip": "2001:db8:0:1::1a"})
+		conns.InsertObject(Object{"ip": "not an ip"})
+
+		// The addresses are read back in their shortest form
+		assert.NoError(t, conns.QueryAt(1, func(r Row) error {
+			ip, ok := r.IP("ip")
+			assert.True(t, ok)
+			assert.Equal(t, "10.1.2.3", ip.String())
+			assert.Len(t, ip, net.IPv4len)
+			return nil
+		}))
+
+		assert.NoError(t, conns.QueryAt(5, func(r Row) error {
+			_, ok := r.IP("ip")
+			assert.False(t, ok)
+			r.SetIP("ip", net.ParseIP("10.0.0.200"))
+			return nil
+		}))
+
+		// The networks may be wider or narrower than the indexed prefixes
+		for cidr, expect := range map[string]int{
+			"10.0.0.0/8":        3,
+			"10.0.0.0/24":       2,
+			"10.0.0.128/25":     1,
+			"192.168.0.0/16":    1,
+			"2001:db8::/32":     2,
+			"2001:db8::/64":     1,
+			"2001:db8:0:1::/80": 1,
+			"::/0":              6,
+		} {
+			assert.NoError(t, conns.Query(func(txn *Txn) error {
+				assert.Equal(t, expect, txn.WithinCIDR("ip", cidr).Count(), cidr)
+				return nil
+			}))
+		}
+
+		// Deleted and updated rows are no longer in their previous network
+		assert.True(t, conns.DeleteAt(0))
+		assert.NoError(t, conns.QueryAt(2, func(r Row) error {
+			r.SetIP("ip", net.ParseIP("10.0.0.2"))
+			return nil
+		}))
+		assert.NoError(t, conns.Query(func(txn *Txn) error {
+			assert.Equal(t, 2, txn.WithinCIDR("ip", "10.0.0.0/24").Count())
+			assert.Equal(t, 0, txn.WithinCIDR("ip", "192.168.0.0/16").Count())
+			return nil
+		}))
+
+		assert.Error(t, conns.Query(func(txn *Txn) error {
+			txn.WithinCIDR("ip", "10.0.0.0").Count()
+			return nil
+		}))
+	}
+}
+
+func TestInt128(t *testing.T) {
+	for _, s := range []string{"0", "-1", "42", "18446744073709551616", "-18446744073709551617",
+		"170141183460469231731687303715884105727", "-170141183460469231731687303715884105728"} {
+		v, err := ParseInt128(s)
+		assert.NoError(t, err)
+		assert.Equal(t, s, v.String())
+		decoded, ok := int128Of(v.Encode())
+		assert.True(t, ok)
+		assert.Equal(t, v, decoded)
+	}
+
+	_, err := ParseInt128("170141183460469231731687303715884105728")
+	assert.Error(t, err)
+	_, err = ParseInt128("abc")
+	assert.Error(t, err)
+
+	// Additions report overflows, comparisons are signed
+	_, overflow := maxInt128.Add(Int128Of(1))
+	assert.True(t, overflow)
+	sum, overflow := Int128Of(-5).Add(Int128Of(3))
+	assert.False(t, overflow)
+	assert.Equal(t, Int128Of(-2), sum)
+	assert.Equal(t, -1, Int128Of(-1).Cmp(Int128Of(0)))
+	assert.Equal(t, 1, maxInt128.Cmp(minInt128))
+}
+
+func TestInt128Column(t *testing.T) {
+	wallets := NewCollection()
+	wallets.CreateColumn("balance", ForInt128())
+	amount, _ := ParseInt128("100000000000000000000000")
+	for i := 0; i < 10; i++ {
+		wallets.InsertObject(Object{"balance": amount})
+	}
+	wallets.InsertObject(Object{"balance": Int128Of(-5)})
+
+	assert.NoError(t, wallets.QueryAt(0, func(r Row) error {
+		v, ok := r.Int128("balance")
+		assert.True(t, ok)
+		assert.Equal(t, amount, v)
+		r.SetInt128("balance", maxInt128)
+		return nil
+	}))
+
+	// The values are compared and summed without overflowing
+	assert.NoError(t, wallets.Query(func(txn *Txn) error {
+		assert.Equal(t, 10, txn.WithInt128Greater("balance", Int128Of(0)).Count())
+		return nil
+	}))
+	assert.NoError(t, wallets.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithInt128Less("balance", Int128Of(0)).Count())
+		return nil
+	}))
+	assert.NoError(t, wallets.Query(func(txn *Txn) error {
+		assert.Equal(t, 9, txn.WithInt128Between("balance", amount, amount).Count())
+		return nil
+	}))
+
+	assert.NoError(t, wallets.Query(func(txn *Txn) error {
+		sum, err := txn.SumInt128("balance")
+		assert.NoError(t, err)
+		assert.Equal(t, "170141183460470131731687303715884105722", sum.String())
+
+		_, err = txn.SumInt128("missing")
+		assert.Error(t, err)
+		return nil
+	}))
+}
+
+func TestMapColumn(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("attrs", ForMap())
+	defer col.Close()
+
+	for i := 0; i < 1000; i++ {
+		attrs := Map{"level": i % 10}
+		if i%4 == 0 {
+			attrs["region"] = "eu"
+		}
+		if i == 42 {
+			attrs["vip"], attrs["score"], attrs["delta"] = true, 1.5, int64(-300)
+		}
+
+		col.InsertObject(Object{"name": fmt.Sprintf("player %d", i), "attrs": attrs})
+	}
+
+	// The rows are filtered on the value of a key, the rows without it are not matched
+	assert.Equal(t, 250, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithMapKey("attrs", "region", func(v interface{}) bool { return v == "eu" })
+	}))
+	assert.Equal(t, 50, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithMapKey("attrs", "region", func(v interface{}) bool {
+			return v == "eu"
+		}).WithMapKey("attrs", "level", func(v interface{}) bool {
+			return v.(int64) == 4
+		})
+	}))
+	assert.Equal(t, 0, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithMapKey("name", "region", func(v interface{}) bool { return true })
+	}))
+
+	// The values are decoded with their types
+	assert.NoError(t, col.QueryAt(42, func(r Row) error {
+		attrs, ok := r.Map("attrs")
+		assert.True(t, ok)
+		assert.Equal(t, Map{"level": int64(2), "vip": true, "score": 1.5, "delta": int64(-300)}, attrs)
+
+		v, ok := r.txn.Map("attrs").Key("score")
+		assert.True(t, ok)
+		assert.Equal(t, 1.5, v)
+		_, ok = r.txn.Map("attrs").Key("missing")
+		assert.False(t, ok)
+
+		r.SetMap("attrs", Map{"region": "us"})
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(42, func(r Row) error {
+		attrs, _ := r.Any("attrs")
+		assert.Equal(t, Map{"region": "us"}, attrs)
+		return nil
+	}))
+
+	// The values which are not scalars can not be stored
+	assert.Panics(t, func() {
+		Map{"nested": Map{}}.Encode()
+	})
+}
+
+// columnOf returns the underlying column, for testing
+func columnOf(c *Collection, columnName string) Column {
+	v, _ := c.cols.Load(columnName)
+	return v.Column
+}
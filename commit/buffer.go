@@ -6,6 +6,8 @@ package commit
 import (
 	"fmt"
 	"math"
+	"net"
+	"time"
 
 	"github.com/kelindar/bitmap"
 )
@@ -32,6 +34,7 @@ const (
 	PutTrue  OpType = 2 // PutTrue is a combination of Put+True for boolean values
 	Put      OpType = 2 // Put stores a value regardless of a previous value
 	Add      OpType = 3 // Add increments the current stored value by the amount
+	Merge    OpType = 4 // Merge combines the current stored value with a delta
 )
 
 // --------------------------- Delta log ----------------------------
@@ -86,6 +89,36 @@ func (b *Buffer) Reset(column string) {
 	b.Column = column
 }
 
+// Mark represents a position in the buffer which can be used to truncate it.
+type Mark struct {
+	last   int32 // The last offset written
+	chunk  Chunk // The current chunk
+	size   int   // The size of the buffer
+	chunks int   // The number of chunk headers
+}
+
+// Mark returns the current position of the buffer.
+func (b *Buffer) Mark() Mark {
+	return Mark{
+		last:   b.last,
+		chunk:  b.chunk,
+		size:   len(b.buffer),
+		chunks: len(b.chunks),
+	}
+}
+
+// Truncate discards all of the operations written after the specified mark.
+func (b *Buffer) Truncate(mark Mark) {
+	if mark.size > len(b.buffer) || mark.chunks > len(b.chunks) {
+		return
+	}
+
+	b.last = mark.last
+	b.chunk = mark.chunk
+	b.buffer = b.buffer[:mark.size]
+	b.chunks = b.chunks[:mark.chunks]
+}
+
 // IsEmpty returns whether the buffer is empty or not.
 func (b *Buffer) IsEmpty() bool {
 	return len(b.buffer) == 0
@@ -98,6 +131,11 @@ func (b *Buffer) RangeChunks(fn func(chunk Chunk)) {
 	}
 }
 
+// Encoder represents a value which is written onto a buffer as bytes, such as a map.
+type Encoder interface {
+	Encode() []byte
+}
+
 // PutAny appends a supported value onto the buffer.
 func (b *Buffer) PutAny(op OpType, idx uint32, value interface{}) {
 	switch v := value.(type) {
@@ -108,7 +146,7 @@ func (b *Buffer) PutAny(op OpType, idx uint32, value interface{}) {
 	case uint16:
 		b.PutUint16(idx, v)
 	case uint8:
-		b.PutUint16(idx, uint16(v))
+		b.PutUint8(idx, v)
 	case int64:
 		b.PutInt64(idx, v)
 	case int32:
@@ -116,7 +154,7 @@ func (b *Buffer) PutAny(op OpType, idx uint32, value interface{}) {
 	case int16:
 		b.PutInt16(idx, v)
 	case int8:
-		b.PutInt16(idx, int16(v))
+		b.PutInt8(idx, v)
 	case string:
 		b.PutString(op, idx, v)
 	case []byte:
@@ -129,10 +167,16 @@ func (b *Buffer) PutAny(op OpType, idx uint32, value interface{}) {
 		b.PutInt64(idx, int64(v))
 	case uint:
 		b.PutUint64(idx, uint64(v))
+	case time.Duration:
+		b.PutInt64(idx, int64(v))
+	case net.IP:
+		b.PutBytes(op, idx, v.To16())
 	case bool:
 		b.PutBool(idx, v)
 	case nil:
 		b.PutOperation(op, idx)
+	case Encoder:
+		b.PutBytes(op, idx, v.Encode())
 	default:
 		panic(fmt.Errorf("column: unsupported type (%T)", value))
 	}
@@ -155,6 +199,11 @@ func (b *Buffer) PutUint16(idx uint32, value uint16) {
 	b.writeUint16(Put, idx, value)
 }
 
+// PutUint8 appends an uint8 value, encoded on two bytes.
+func (b *Buffer) PutUint8(idx uint32, value uint8) {
+	b.writeUint16(Put, idx, uint16(value))
+}
+
 // PutUint appends a uint64 value.
 func (b *Buffer) PutUint(idx uint32, value uint) {
 	b.writeUint64(Put, idx, uint64(value))
@@ -175,6 +224,11 @@ func (b *Buffer) PutInt16(idx uint32, value int16) {
 	b.writeUint16(Put, idx, uint16(value))
 }
 
+// PutInt8 appends an int8 value, encoded on two bytes.
+func (b *Buffer) PutInt8(idx uint32, value int8) {
+	b.writeUint16(Put, idx, uint16(value))
+}
+
 // PutInt appends a int64 value.
 func (b *Buffer) PutInt(idx uint32, value int) {
 	b.writeUint64(Put, idx, uint64(value))
@@ -212,6 +266,11 @@ func (b *Buffer) AddUint16(idx uint32, value uint16) {
 	b.writeUint16(Add, idx, value)
 }
 
+// AddUint8 appends an addition of uint8 value.
+func (b *Buffer) AddUint8(idx uint32, value uint8) {
+	b.writeUint16(Add, idx, uint16(value))
+}
+
 // AddUint appends an addition of uint64 value.
 func (b *Buffer) AddUint(idx uint32, value uint) {
 	b.writeUint64(Add, idx, uint64(value))
@@ -232,6 +291,11 @@ func (b *Buffer) AddInt16(idx uint32, value int16) {
 	b.writeUint16(Add, idx, uint16(value))
 }
 
+// AddInt8 appends an addition of int8 value.
+func (b *Buffer) AddInt8(idx uint32, value int8) {
+	b.writeUint16(Add, idx, uint16(value))
+}
+
 // AddInt appends an addition of int64 value.
 func (b *Buffer) AddInt(idx uint32, value int) {
 	b.writeUint64(Add, idx, uint64(value))
@@ -252,6 +316,79 @@ func (b *Buffer) AddNumber(idx uint32, value float64) {
 	b.writeUint64(Add, idx, math.Float64bits(value))
 }
 
+// AddAny appends an addition of a supported numeric value onto the buffer.
+func (b *Buffer) AddAny(idx uint32, value interface{}) {
+	switch v := value.(type) {
+	case uint64:
+		b.AddUint64(idx, v)
+	case uint32:
+		b.AddUint32(idx, v)
+	case uint16:
+		b.AddUint16(idx, v)
+	case uint8:
+		b.AddUint8(idx, v)
+	case int64:
+		b.AddInt64(idx, v)
+	case int32:
+		b.AddInt32(idx, v)
+	case int16:
+		b.AddInt16(idx, v)
+	case int8:
+		b.AddInt8(idx, v)
+	case float32:
+		b.AddFloat32(idx, v)
+	case float64:
+		b.AddFloat64(idx, v)
+	case int:
+		b.AddInt64(idx, int64(v))
+	case uint:
+		b.AddUint64(idx, uint64(v))
+	case time.Duration:
+		b.AddInt64(idx, int64(v))
+	default:
+		panic(fmt.Errorf("column: unsupported type (%T)", value))
+	}
+}
+
+// MergeAny appends a merge of a supported value onto the buffer, which is combined with the
+// current stored value by the merge function of the column once committed.
+func (b *Buffer) MergeAny(idx uint32, value interface{}) {
+	switch v := value.(type) {
+	case uint64:
+		b.writeUint64(Merge, idx, v)
+	case uint32:
+		b.writeUint32(Merge, idx, v)
+	case uint16:
+		b.writeUint16(Merge, idx, v)
+	case uint8:
+		b.writeUint16(Merge, idx, uint16(v))
+	case int64:
+		b.writeUint64(Merge, idx, uint64(v))
+	case int32:
+		b.writeUint32(Merge, idx, uint32(v))
+	case int16:
+		b.writeUint16(Merge, idx, uint16(v))
+	case int8:
+		b.writeUint16(Merge, idx, uint16(v))
+	case float32:
+		b.writeUint32(Merge, idx, math.Float32bits(v))
+	case float64:
+		b.writeUint64(Merge, idx, math.Float64bits(v))
+	case int:
+		b.writeUint64(Merge, idx, uint64(v))
+	case uint:
+		b.writeUint64(Merge, idx, uint64(v))
+	case time.Duration:
+		b.writeUint64(Merge, idx, uint64(v))
+	case string:
+		b.PutString(Merge, idx, v)
+	case []byte:
+		b.PutBytes(Merge, idx, v)
+	default:
+		panic(fmt.Errorf("column: unsupported type (%T)", value))
+	}
+}
+
 // --------------------------- Others ----------------------------
 
 // PutOperation appends an operation type without a value.
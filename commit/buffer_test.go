@@ -261,6 +261,75 @@ func TestAdd(t *testing.T) {
 	assert.False(t, r.Next())
 }
 
+func TestAddAny(t *testing.T) {
+	buf := NewBuffer(0)
+	buf.AddAny(10, int16(100))
+	buf.AddAny(20, uint32(200))
+	buf.AddAny(30, 300)
+	buf.AddAny(40, 12.34)
+
+	// Read values back
+	r := NewReader()
+	r.Seek(buf)
+	assert.True(t, r.Next())
+	assert.Equal(t, Add, r.Type)
+	assert.Equal(t, int16(100), r.Int16())
+	assert.True(t, r.Next())
+	assert.Equal(t, uint32(200), r.Uint32())
+	assert.True(t, r.Next())
+	assert.Equal(t, int64(300), r.Int64())
+	assert.True(t, r.Next())
+	assert.Equal(t, 12.34, r.Float64())
+	assert.False(t, r.Next())
+	assert.Panics(t, func() {
+		buf.AddAny(50, "hello")
+	})
+}
+
+func TestMergeAny(t *testing.T) {
+	buf := NewBuffer(0)
+	buf.MergeAny(10, int8(-1))
+	buf.MergeAny(20, float32(1.5))
+	buf.MergeAny(30, "hello")
+
+	// Read values back
+	r := NewReader()
+	r.Seek(buf)
+	assert.True(t, r.Next())
+	assert.Equal(t, Merge, r.Type)
+	assert.Equal(t, int8(-1), r.Int8())
+	assert.True(t, r.Next())
+	assert.Equal(t, Merge, r.Type)
+	assert.Equal(t, float32(1.5), r.Float32())
+	assert.True(t, r.Next())
+	assert.Equal(t, Merge, r.Type)
+	assert.Equal(t, "hello", r.String())
+	assert.False(t, r.Next())
+	assert.Panics(t, func() {
+		buf.MergeAny(50, true)
+	})
+}
+
+func TestBufferTruncate(t *testing.T) {
+	buf := NewBuffer(0)
+	buf.PutInt16(10, 100)
+	mark := buf.Mark()
+	buf.PutInt16(11, 200)
+	buf.PutString(Put, 20000, "hello")
+	buf.Truncate(mark)
+	buf.PutInt16(12, 300)
+
+	r := NewReader()
+	r.Seek(buf)
+	assert.True(t, r.Next())
+	assert.Equal(t, int16(100), r.Int16())
+	assert.True(t, r.Next())
+	assert.Equal(t, uint32(12), r.Index())
+	assert.Equal(t, int16(300), r.Int16())
+	assert.False(t, r.Next())
+	assert.Equal(t, 1, len(buf.chunks))
+}
+
 func TestBufferClone(t *testing.T) {
 	buf := NewBuffer(0)
 	buf.PutInt16(10, 100)
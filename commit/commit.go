@@ -21,6 +21,12 @@ func Next() uint64 {
 	return atomic.AddUint64(&id, 1)
 }
 
+// Last returns the last commit ID issued, so that every commit ID issued afterwards is
+// greater than it.
+func Last() uint64 {
+	return atomic.LoadUint64(&id)
+}
+
 // --------------------------- Chunk ----------------------------
 
 const (
@@ -82,6 +88,7 @@ type Commit struct {
 
 // Clone clones a commit into a new one
 func (c *Commit) Clone() (clone Commit) {
+	clone.ID = c.ID
 	clone.Chunk = c.Chunk
 	for _, u := range c.Updates {
 		if len(u.buffer) > 0 {
@@ -35,6 +35,7 @@ func BenchmarkColumn(b *testing.B) {
 
 func TestCommitClone(t *testing.T) {
 	commit := Commit{
+		ID: 1,
 		Updates: []*Buffer{{
 			buffer: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
 			chunks: []header{{
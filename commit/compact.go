@@ -0,0 +1,199 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package commit
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kelindar/iostream"
+	"github.com/klauspost/compress/s2"
+)
+
+// cell represents a value of a column at a given offset
+type cell struct {
+	column string
+	offset uint32
+}
+
+// Compact rewrites the log so that it only keeps the operations which still determine the
+// state of the rows, dropping every value which was overwritten by a later commit. This
+// bounds the size of the log, and the time to replay it, to the number of rows written
+// rather than the number of commits, without requiring a snapshot of the collection.
+//
+// The delete operations of the rows column remove the entire row, so the values of all of
+// the columns written by the earlier commits are dropped as well. The merges depend on the
+// value they are merged into, so they are kept along with it. The commits which remain are
+// kept in their order and with their IDs, so that the recovery from a snapshot still skips
+// the ones it already contains.
+//
+// The compacted log is written into a temporary file of the same directory, synced and
+// then renamed over the log, so that a failure leaves the previous log in place. The
+// commits appended meanwhile wait for the compaction to complete. The underlying source
+// must be a file for this to work.
+func (l *Log) Compact(rowsColumn string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	file, ok := l.source.(*os.File)
+	if !ok || l.encoder == nil {
+		return errors.New("column: unable to compact the commit log, source is not a file")
+	}
+
+	// Find the last operation which overwrites every value. Since the inserts and deletes
+	// of a commit are applied before its updates, a deleted row removes the values of the
+	// previous commits only.
+	var seq uint64
+	reader := NewReader()
+	latest := make(map[cell]uint64, 1024)
+	deleted := make(map[uint32]uint64, 64)
+	if err := rangeFile(file, func(commit Commit) error {
+		start := seq
+		for _, u := range commit.Updates {
+			reader.Range(u, commit.Chunk, func(r *Reader) {
+				for r.Next() {
+					seq++
+					switch {
+					case r.Type == Merge:
+					case u.Column == rowsColumn && r.Type == Delete:
+						deleted[r.Index()] = start
+						latest[cell{u.Column, r.Index()}] = seq
+					default:
+						latest[cell{u.Column, r.Index()}] = seq
+					}
+				}
+			})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Write the compacted log into a temporary file, next to the log
+	name := file.Name()
+	temp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	seq = 0
+	output := &sizeWriter{out: temp, size: new(int64)}
+	encoder := s2.NewWriter(output)
+	writer := iostream.NewWriter(encoder)
+	if err := rangeFile(file, func(commit Commit) error {
+		start := seq
+		compacted := Commit{ID: commit.ID, Chunk: commit.Chunk}
+		for _, u := range commit.Updates {
+			buffer := NewBuffer(len(u.buffer))
+			buffer.Reset(u.Column)
+			reader.Range(u, commit.Chunk, func(r *Reader) {
+				for r.Next() {
+					seq++
+					if seq >= latest[cell{u.Column, r.Index()}] && start >= deleted[r.Index()] {
+						buffer.putFrom(r)
+					}
+				}
+			})
+
+			if !buffer.IsEmpty() {
+				compacted.Updates = append(compacted.Updates, buffer)
+			}
+		}
+
+		if len(compacted.Updates) == 0 {
+			return nil
+		}
+
+		_, err := compacted.WriteTo(writer)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	// The compacted log must be durable before it replaces the previous one
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), name); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(name))
+
+	// Continue appending the commits at the end of the compacted log
+	reopened, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := reopened.Seek(0, io.SeekEnd); err != nil {
+		reopened.Close()
+		return err
+	}
+
+	file.Close()
+	l.source = reopened
+	l.size = *output.size
+	output.out, output.size = reopened, &l.size
+	l.encoder = encoder
+	l.writer = writer
+	l.reader = iostream.NewReader(s2.NewReader(reopened))
+	return nil
+}
+
+// rangeFile reads all of the commits of a log file from its beginning
+func rangeFile(file *os.File, fn func(Commit) error) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := iostream.NewReader(s2.NewReader(file))
+	for {
+		var commit Commit
+		_, err := commit.ReadFrom(reader)
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if err := fn(commit); err != nil {
+			return err
+		}
+	}
+}
+
+// putFrom appends the current operation of the reader, along with its value as is.
+func (b *Buffer) putFrom(r *Reader) {
+	idx, value := r.Index(), r.buffer[r.i0:r.i1]
+	switch {
+	case r.varlen:
+		b.PutBytes(r.Type, idx, value)
+	case len(value) == 2:
+		b.writeUint16(r.Type, idx, binary.BigEndian.Uint16(value))
+	case len(value) == 4:
+		b.writeUint32(r.Type, idx, binary.BigEndian.Uint32(value))
+	case len(value) == 8:
+		b.writeUint64(r.Type, idx, binary.BigEndian.Uint64(value))
+	default:
+		b.PutOperation(r.Type, idx)
+	}
+}
+
+// syncDir syncs a directory so that a file renamed into it is durable. Not every platform
+// supports this, so the errors are ignored.
+func syncDir(dir string) {
+	if f, err := os.Open(dir); err == nil {
+		f.Sync()
+		f.Close()
+	}
+}
@@ -4,6 +4,7 @@
 package commit
 
 import (
+	"errors"
 	"io"
 	"os"
 	"sync"
@@ -37,10 +38,12 @@ func (w *Channel) Append(commit Commit) error {
 // Log represents a commit log that can be used to write the changes to the collection
 // during a snapshot. It also supports reading a commit log back.
 type Log struct {
-	lock   sync.Mutex
-	source io.Reader
-	writer *iostream.Writer
-	reader *iostream.Reader
+	lock    sync.Mutex
+	source  io.Reader
+	size    int64
+	encoder *s2.Writer
+	writer  *iostream.Writer
+	reader  *iostream.Reader
 }
 
 // Open opens a commit log stream for both read and write.
@@ -50,8 +53,17 @@ func Open(source io.Reader) *Log {
 		reader: iostream.NewReader(s2.NewReader(source)),
 	}
 
+	if file, ok := source.(interface {
+		Stat() (os.FileInfo, error)
+	}); ok {
+		if info, err := file.Stat(); err == nil {
+			log.size = info.Size()
+		}
+	}
+
 	if rw, ok := source.(io.Writer); ok {
-		log.writer = iostream.NewWriter(s2.NewWriter(rw))
+		log.encoder = s2.NewWriter(&sizeWriter{out: rw, size: &log.size})
+		log.writer = iostream.NewWriter(log.encoder)
 	}
 	return log
 }
@@ -140,6 +152,62 @@ func (l *Log) Copy(dst io.Writer) error {
 	return err
 }
 
+// Size returns the size of the log in bytes, as written to its source.
+func (l *Log) Size() int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.size
+}
+
+// Sync commits the contents of the log to stable storage, if the underlying source
+// supports it, as the files do.
+func (l *Log) Sync() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.sync()
+}
+
+// sync syncs the source of the log, if supported
+func (l *Log) sync() error {
+	if file, ok := l.source.(interface {
+		Sync() error
+	}); ok {
+		return file.Sync()
+	}
+	return nil
+}
+
+// Truncate discards all of the commits of the log and syncs it, so that the commits
+// appended afterwards are written from the beginning of the source. The underlying
+// source must implement both Truncate(int64) and io.Seeker for this to work, as the
+// files do.
+func (l *Log) Truncate() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	file, ok := l.source.(interface {
+		io.Seeker
+		Truncate(size int64) error
+	})
+	if !ok || l.encoder == nil {
+		return errors.New("column: unable to truncate the commit log, source is not a file")
+	}
+
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Start a new stream, since the stream header was discarded as well
+	l.size = 0
+	l.encoder.Reset(&sizeWriter{out: l.source.(io.Writer), size: &l.size})
+	l.writer.Reset(l.encoder)
+	l.reader = iostream.NewReader(s2.NewReader(l.source))
+	return l.sync()
+}
+
 // Close closes the source log file.
 func (l *Log) Close() (err error) {
 	l.lock.Lock()
@@ -149,3 +217,16 @@ func (l *Log) Close() (err error) {
 	}
 	return
 }
+
+// sizeWriter represents a writer which counts the bytes written into its output
+type sizeWriter struct {
+	out  io.Writer
+	size *int64
+}
+
+// Write writes the bytes into the output and counts them
+func (w *sizeWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	*w.size += int64(n)
+	return n, err
+}
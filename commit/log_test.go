@@ -133,8 +133,120 @@ func TestLogOpenTemp(t *testing.T) {
 	assert.NotEmpty(t, logger.Name())
 }
 
+func TestLogTruncate(t *testing.T) {
+	logger, err := OpenTemp()
+	assert.NoError(t, err)
+	defer os.Remove(logger.Name())
+	defer logger.Close()
+
+	assert.NoError(t, logger.Append(newCommit(1)))
+	assert.NoError(t, logger.Append(newCommit(2)))
+	assert.NoError(t, logger.Sync())
+	assert.Greater(t, logger.Size(), int64(0))
+
+	// Only the commits appended after the truncation remain
+	assert.NoError(t, logger.Truncate())
+	assert.Equal(t, int64(0), logger.Size())
+	assert.NoError(t, logger.Append(newCommit(3)))
+
+	reopened, err := OpenFile(logger.Name())
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, logger.Size(), reopened.Size())
+
+	var arr []uint64
+	assert.NoError(t, reopened.Range(func(commit Commit) error {
+		arr = append(arr, commit.ID)
+		return nil
+	}))
+	assert.Equal(t, []uint64{3}, arr)
+
+	// A log which is not a file can not be truncated
+	assert.Error(t, Open(bytes.NewBuffer(nil)).Truncate())
+}
+
 func TestLogOpenFileInvalid(t *testing.T) {
 	logger, err := OpenFile("")
 	assert.Error(t, err)
 	assert.Nil(t, logger)
 }
+
+func TestLogCompact(t *testing.T) {
+	logger, err := OpenTemp()
+	assert.NoError(t, err)
+	defer os.Remove(logger.Name())
+	defer logger.Close()
+
+	// Insert two rows, then overwrite their values several times
+	rows, values := NewBuffer(16), NewBuffer(16)
+	rows.Reset("row")
+	rows.PutOperation(Insert, 1)
+	rows.PutOperation(Insert, 2)
+	values.Reset("name")
+	values.PutString(Put, 1, "a")
+	values.PutString(Put, 2, "b")
+	assert.NoError(t, logger.Append(Commit{ID: 1, Updates: []*Buffer{rows, values}}))
+
+	for i := 2; i < 10; i++ {
+		balance := NewBuffer(16)
+		balance.Reset("balance")
+		balance.PutFloat64(1, float64(i))
+		balance.PutFloat64(2, float64(i))
+		assert.NoError(t, logger.Append(Commit{ID: uint64(i), Updates: []*Buffer{balance}}))
+	}
+
+	// Merge a delta into the first row, then delete the second one
+	merges := NewBuffer(16)
+	merges.Reset("name")
+	merges.PutString(Merge, 1, "c")
+	deletes := NewBuffer(16)
+	deletes.Reset("row")
+	deletes.PutOperation(Delete, 2)
+	assert.NoError(t, logger.Append(Commit{ID: 10, Updates: []*Buffer{merges, deletes}}))
+
+	name, size := logger.Name(), logger.Size()
+	assert.NoError(t, logger.Compact("row"))
+	assert.Less(t, logger.Size(), size)
+	assert.Equal(t, name, logger.Name())
+
+	// The commits appended afterwards are written after the compacted ones
+	assert.NoError(t, logger.Append(newCommit(11)))
+	reopened, err := OpenFile(logger.Name())
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, logger.Size(), reopened.Size())
+
+	var ids []uint64
+	var ops []string
+	assert.NoError(t, reopened.Range(func(commit Commit) error {
+		ids = append(ids, commit.ID)
+		if commit.ID == 11 {
+			return nil
+		}
+
+		reader := NewReader()
+		for _, u := range commit.Updates {
+			reader.Range(u, commit.Chunk, func(r *Reader) {
+				for r.Next() {
+					switch u.Column {
+					case "balance":
+						ops = append(ops, fmt.Sprintf("%s[%d]=%v", u.Column, r.Index(), r.Float64()))
+					case "name":
+						ops = append(ops, fmt.Sprintf("%s[%d]=%v", u.Column, r.Index(), r.String()))
+					default:
+						ops = append(ops, fmt.Sprintf("%s[%d]:%d", u.Column, r.Index(), r.Type))
+					}
+				}
+			})
+		}
+		return nil
+	}))
+
+	assert.Equal(t, []uint64{1, 9, 10, 11}, ids)
+	assert.Equal(t, []string{
+		"row[1]:1", "name[1]=a", "balance[1]=9", "name[1]=c", "row[2]:0",
+	}, ops)
+
+	// A log which is not a file can not be compacted
+	assert.Error(t, Open(bytes.NewBuffer(nil)).Compact("row"))
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package commit
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+)
+
+// Bus represents an event bus onto which messages are published, such as a NATS connection.
+type Bus interface {
+	Publish(subject string, data []byte) error
+}
+
+var _ Logger = new(Publisher)
+
+// Publisher represents a commit writer which publishes each commit as a compressed message
+// onto a subject of an event bus, so that other services can subscribe to the changes of a
+// collection. The messages can be decoded back into commits with Decode().
+type Publisher struct {
+	lock    sync.Mutex
+	bus     Bus
+	subject string
+	columns map[string]bool
+	buffer  bytes.Buffer
+}
+
+// rowColumn is the column in which the markers of the inserted and deleted rows are written
+const rowColumn = "row"
+
+// NewPublisher creates a new commit writer which publishes onto a subject of the bus, which
+// is typically one subject per collection. If columns are specified, only their updates are
+// published and the commits which do not update any of them are skipped. The markers of the
+// inserted and deleted rows, written in the "row" column, are always published.
+func NewPublisher(bus Bus, subject string, columns ...string) *Publisher {
+	p := &Publisher{
+		bus:     bus,
+		subject: subject,
+	}
+
+	if len(columns) > 0 {
+		p.columns = make(map[string]bool, len(columns))
+		for _, name := range columns {
+			p.columns[name] = true
+		}
+	}
+	return p
+}
+
+// Append encodes the commit and publishes it onto the subject
+func (p *Publisher) Append(commit Commit) error {
+	if p.columns != nil {
+		filtered := Commit{ID: commit.ID, Chunk: commit.Chunk}
+		for _, u := range commit.Updates {
+			if u.Column == rowColumn || p.columns[u.Column] {
+				filtered.Updates = append(filtered.Updates, u)
+			}
+		}
+
+		if len(filtered.Updates) == 0 {
+			return nil
+		}
+		commit = filtered
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.buffer.Reset()
+	if _, err := commit.WriteTo(&p.buffer); err != nil {
+		return err
+	}
+
+	return p.bus.Publish(p.subject, s2.Encode(nil, p.buffer.Bytes()))
+}
+
+// Decode decodes a message published by a publisher back into a commit.
+func Decode(message []byte) (commit Commit, err error) {
+	data, err := s2.Decode(nil, message)
+	if err != nil {
+		return
+	}
+
+	_, err = commit.ReadFrom(bytes.NewReader(data))
+	return
+}
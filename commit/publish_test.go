@@ -0,0 +1,83 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package commit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublisher(t *testing.T) {
+	bus := new(fakeBus)
+	publisher := NewPublisher(bus, "column.players")
+	assert.NoError(t, publisher.Append(newCommit(1)))
+	assert.NoError(t, publisher.Append(newCommit(2)))
+	assert.Equal(t, []string{"column.players", "column.players"}, bus.subjects)
+
+	commit, err := Decode(bus.messages[1])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), commit.ID)
+	assert.Len(t, commit.Updates, 2)
+	assert.Equal(t, []int64{1, 2, 4, 5, 7, 8}, updatesAt(commit.Updates[1], 0))
+
+	_, err = Decode([]byte("invalid"))
+	assert.Error(t, err)
+}
+
+func TestPublisherColumns(t *testing.T) {
+	bus := new(fakeBus)
+	publisher := NewPublisher(bus, "column.players", "b")
+	assert.NoError(t, publisher.Append(newCommit(1)))
+
+	commit, err := Decode(bus.messages[0])
+	assert.NoError(t, err)
+	assert.Len(t, commit.Updates, 1)
+	assert.Equal(t, "b", commit.Updates[0].Column)
+
+	// Commits which do not update any of the columns are not published
+	assert.NoError(t, NewPublisher(bus, "column.players", "c").Append(newCommit(2)))
+	assert.Len(t, bus.messages, 1)
+}
+
+func TestPublisherRows(t *testing.T) {
+	rows := NewBuffer(10)
+	rows.Reset("row")
+	rows.PutOperation(Insert, 1)
+	rows.PutOperation(Delete, 2)
+
+	bus := new(fakeBus)
+	publisher := NewPublisher(bus, "column.players", "b")
+	assert.NoError(t, publisher.Append(Commit{
+		ID:      1,
+		Updates: []*Buffer{newInterleaved("a"), rows},
+	}))
+
+	// The markers of the rows are published, even if they are not in the columns
+	commit, err := Decode(bus.messages[0])
+	assert.NoError(t, err)
+	assert.Len(t, commit.Updates, 1)
+	assert.Equal(t, "row", commit.Updates[0].Column)
+}
+
+func TestPublisherError(t *testing.T) {
+	bus := &fakeBus{err: errors.New("disconnected")}
+	publisher := NewPublisher(bus, "column.players")
+	assert.Error(t, publisher.Append(newCommit(1)))
+}
+
+// fakeBus represents an event bus which keeps the messages published
+type fakeBus struct {
+	err      error
+	subjects []string
+	messages [][]byte
+}
+
+// Publish records the message
+func (b *fakeBus) Publish(subject string, data []byte) error {
+	b.subjects = append(b.subjects, subject)
+	b.messages = append(b.messages, data)
+	return b.err
+}
@@ -17,6 +17,7 @@ type Reader struct {
 	buffer []byte // The log slice
 	Offset int32  // The current offset
 	start  int32  // The start offset
+	varlen bool   // Whether the current value is variable-size
 }
 
 // NewReader creates a new reader for a commit log.
@@ -47,6 +48,11 @@ func (r *Reader) use(buffer []byte) {
 
 // --------------------------- Value Read ----------------------------
 
+// Int8 reads an int8 value, encoded on two bytes.
+func (r *Reader) Int8() int8 {
+	return int8(binary.BigEndian.Uint16(r.buffer[r.i0:r.i1]))
+}
+
 // Int16 reads a uint16 value.
 func (r *Reader) Int16() int16 {
 	return int16(binary.BigEndian.Uint16(r.buffer[r.i0:r.i1]))
@@ -62,6 +68,11 @@ func (r *Reader) Int64() int64 {
 	return int64(binary.BigEndian.Uint64(r.buffer[r.i0:r.i1]))
 }
 
+// Uint8 reads a uint8 value, encoded on two bytes.
+func (r *Reader) Uint8() uint8 {
+	return uint8(binary.BigEndian.Uint16(r.buffer[r.i0:r.i1]))
+}
+
 // Uint16 reads a uint16 value.
 func (r *Reader) Uint16() uint16 {
 	return binary.BigEndian.Uint16(r.buffer[r.i0:r.i1])
@@ -148,6 +159,11 @@ func (r *Reader) Bool() bool {
 
 // --------------------------- Value Swap ----------------------------
 
+// SwapInt8 swaps an int8 value with a new one.
+func (r *Reader) SwapInt8(v int8) {
+	binary.BigEndian.PutUint16(r.buffer[r.i0:r.i1], uint16(v))
+}
+
 // SwapInt16 swaps a uint16 value with a new one.
 func (r *Reader) SwapInt16(v int16) {
 	binary.BigEndian.PutUint16(r.buffer[r.i0:r.i1], uint16(v))
@@ -168,6 +184,11 @@ func (r *Reader) SwapInt(v int) {
 	binary.BigEndian.PutUint64(r.buffer[r.i0:r.i1], uint64(v))
 }
 
+// SwapUint8 swaps a uint8 value with a new one.
+func (r *Reader) SwapUint8(v uint8) {
+	binary.BigEndian.PutUint16(r.buffer[r.i0:r.i1], uint16(v))
+}
+
 // SwapUint16 swaps a uint16 value with a new one.
 func (r *Reader) SwapUint16(v uint16) {
 	binary.BigEndian.PutUint16(r.buffer[r.i0:r.i1], v)
@@ -331,6 +352,7 @@ func (r *Reader) readFixed(v byte) {
 	r.head += size
 	r.i1 = r.head
 	r.Type = OpType(v & 0xf)
+	r.varlen = false
 }
 
 // readString reads the operation type and the value at the current position.
@@ -341,4 +363,5 @@ func (r *Reader) readString(v byte) {
 	r.head += size
 	r.i1 = r.head
 	r.Type = OpType(v & 0xf)
+	r.varlen = true
 }
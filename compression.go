@@ -0,0 +1,211 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	codecS2   = 0x0 // The state is compressed with s2
+	codecZstd = 0x1 // The state is compressed with zstd and a dictionary
+)
+
+// TrainDictionary trains a zstd dictionary of up to the specified size in bytes, 64KB by
+// default, on the current rows of the collection. Once the dictionary is specified in the
+// options of a collection, its snapshots are compressed with zstd and the dictionary, which
+// compresses the frequent and small snapshots much better than the default codec since the
+// rows they contain look alike. The same dictionary is required to restore the snapshots, so
+// it needs to be stored along with them.
+func (c *Collection) TrainDictionary(size int) ([]byte, error) {
+	if size <= 0 {
+		size = 64 << 10
+	}
+
+	// Every column of every chunk is a sample, as it is written in the snapshots
+	var samples [][]byte
+	buffer := c.txns.acquirePage(rowColumn)
+	defer c.txns.releasePage(buffer)
+	columns := c.snapshotColumns()
+	for i := 0; i < c.chunks(); i++ {
+		if err := c.readChunk(commit.Chunk(i), func(_ uint64, chunk commit.Chunk, _ bitmap.Bitmap) error {
+			for _, column := range columns {
+				if !column.Snapshot(chunk, buffer) || buffer.IsEmpty() {
+					continue
+				}
+
+				var sample bytes.Buffer
+				if _, err := buffer.WriteTo(&sample); err != nil {
+					return err
+				}
+				samples = append(samples, sample.Bytes())
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("column: unable to train dictionary, collection is empty")
+	}
+
+	out, err := dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: size,
+		HashBytes:   6,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to train dictionary, %w", err)
+	}
+	return out, nil
+}
+
+// codec returns the codec the snapshots of the collection are compressed with
+func (c *Collection) codec() byte {
+	if c.opts.Dictionary != nil {
+		return codecZstd
+	}
+	return codecS2
+}
+
+// compressor returns a writer which compresses the state of a snapshot with the codec
+func (c *Collection) compressor(dst io.Writer, codec byte) (io.WriteCloser, error) {
+	if codec == codecS2 {
+		return s2.NewWriter(dst), nil
+	}
+
+	frames := &frameWriter{dst: dst}
+	encoder, err := zstd.NewWriter(frames,
+		zstd.WithEncoderDict(c.opts.Dictionary),
+		zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to snapshot, %w", err)
+	}
+
+	return &zstdWriter{Encoder: encoder, frames: frames}, nil
+}
+
+// decompressor returns a reader which decompresses the state of a snapshot with the codec
+func (c *Collection) decompressor(src io.Reader, codec byte) (io.ReadCloser, error) {
+	switch codec {
+	case codecS2:
+		return io.NopCloser(s2.NewReader(src)), nil
+	case codecZstd:
+		if c.opts.Dictionary == nil {
+			return nil, fmt.Errorf("column: unable to restore, snapshot requires a dictionary")
+		}
+	default:
+		return nil, fmt.Errorf("column: unable to restore, unknown codec %d", codec)
+	}
+
+	decoder, err := zstd.NewReader(&frameReader{src: src},
+		zstd.WithDecoderDicts(c.opts.Dictionary),
+		zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to restore, %w", err)
+	}
+
+	return &zstdReader{Decoder: decoder}, nil
+}
+
+// --------------------------- Zstd Stream ----------------------------
+
+// zstdWriter represents a zstd encoder writing into frames, so that the commits which are
+// appended to the snapshot follow the end of the state.
+type zstdWriter struct {
+	*zstd.Encoder
+	frames *frameWriter
+}
+
+// Close closes the encoder and terminates the frames
+func (w *zstdWriter) Close() error {
+	if err := w.Encoder.Close(); err != nil {
+		return err
+	}
+	return w.frames.Close()
+}
+
+// zstdReader represents a zstd decoder reading from frames
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+// Close reads the remainder of the state, up to its last frame, and closes the decoder
+func (r *zstdReader) Close() error {
+	defer r.Decoder.Close()
+	_, err := io.Copy(io.Discard, r.Decoder)
+	return err
+}
+
+// frameWriter represents a writer which prefixes every write with its size
+type frameWriter struct {
+	dst io.Writer
+}
+
+// Write writes a frame
+func (w *frameWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(p)))
+	if _, err := w.dst.Write(size[:]); err != nil {
+		return 0, err
+	}
+	return w.dst.Write(p)
+}
+
+// Close writes the empty frame which terminates the stream
+func (w *frameWriter) Close() error {
+	_, err := w.dst.Write(make([]byte, 4))
+	return err
+}
+
+// frameReader represents a reader of the frames, which stops at the empty frame without
+// reading past it.
+type frameReader struct {
+	src  io.Reader
+	left uint32 // The number of bytes left in the current frame
+	done bool   // Whether the empty frame was read
+}
+
+// Read reads from the current frame
+func (r *frameReader) Read(p []byte) (int, error) {
+	if r.left == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		var size [4]byte
+		if err := readFull(r.src, size[:]); err != nil {
+			return 0, err
+		}
+
+		if r.left = binary.BigEndian.Uint32(size[:]); r.left == 0 {
+			r.done = true
+			return 0, io.EOF
+		}
+	}
+
+	if uint32(len(p)) > r.left {
+		p = p[:r.left]
+	}
+
+	n, err := r.src.Read(p)
+	r.left -= uint32(n)
+	if err == io.EOF {
+		err = errUnexpectedEOF
+	}
+	return n, err
+}
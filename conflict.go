@@ -0,0 +1,226 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kelindar/column/commit"
+)
+
+// ErrReplayConflict is returned when a replayed commit was rejected, since some of the values
+// it changes were changed by a newer commit. The error returned is a *ConflictError which
+// records the conflicts.
+var ErrReplayConflict = errors.New("column: replayed commit conflicts with a newer commit")
+
+// Resolution represents how the conflicts of a replayed commit are resolved
+type Resolution uint8
+
+// Various resolutions of the conflicts
+const (
+	LastWriterWins  Resolution = iota // The values changed by a newer commit are kept
+	MergeWrites                       // The values are merged with the merge function of their column, or kept otherwise
+	RejectConflicts                   // The whole commit is rejected and Replay() returns a *ConflictError
+)
+
+// ConflictPolicy represents how the commits replayed on a collection are reconciled with the
+// commits which were applied on it, for example by bidirectional or catch-up replication.
+type ConflictPolicy struct {
+	Resolve    Resolution       // How the conflicts are resolved, LastWriterWins by default
+	OnConflict func(c Conflict) // The callback for every conflict, once the commit is replayed (optional)
+}
+
+// Conflict represents a value changed by a replayed commit, which was already changed by a
+// newer commit on the collection.
+type Conflict struct {
+	Column string // The name of the column
+	Index  uint32 // The index of the row
+	Commit uint64 // The ID of the replayed commit
+	Latest uint64 // The ID of the newer commit which changed the value
+}
+
+// ConflictError represents an error returned when a replayed commit was rejected. It matches
+// ErrReplayConflict with errors.Is().
+type ConflictError struct {
+	Conflicts []Conflict // The conflicts of the commit
+}
+
+// Error returns the error message
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("column: unable to replay, %d values were changed by a newer commit", len(e.Conflicts))
+}
+
+// Is returns whether the error matches the target
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrReplayConflict
+}
+
+// replayState represents the commit being replayed by a transaction
+type replayState struct {
+	id        uint64     // The ID of the replayed commit
+	conflicts []Conflict // The conflicts found while it was committed
+}
+
+// --------------------------- Versions ----------------------------
+
+// versions keeps the ID of the last commit which changed every value of the collection, so
+// that the replayed commits can be compared with them. Since the commit IDs start from the
+// time of the process, the newer commits have the larger IDs.
+type versions struct {
+	lock sync.Mutex
+	cols map[string][]uint64 // The commit IDs by column, then by row
+}
+
+// newVersions creates a new table of versions, if a conflict policy is specified
+func newVersions(policy *ConflictPolicy) *versions {
+	if policy == nil {
+		return nil
+	}
+
+	return &versions{
+		cols: make(map[string][]uint64, 8),
+	}
+}
+
+// get returns the ID of the last commit which changed a value
+func (v *versions) get(columnName string, idx uint32) uint64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if ids := v.cols[columnName]; idx < uint32(len(ids)) {
+		return ids[idx]
+	}
+	return 0
+}
+
+// record records the ID of the commit for every value of a chunk changed by a transaction,
+// unless a newer commit has already changed it.
+func (v *versions) record(txn *Txn, commitID uint64, chunk commit.Chunk) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn {
+			continue
+		}
+
+		ids := v.cols[u.Column]
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				idx := r.Index()
+				if idx >= uint32(len(ids)) {
+					clone := make([]uint64, idx+1, resize(cap(ids), idx+1))
+					copy(clone, ids)
+					ids = clone
+				}
+
+				if ids[idx] < commitID {
+					ids[idx] = commitID
+				}
+			}
+		})
+		v.cols[u.Column] = ids
+	}
+}
+
+// --------------------------- Replay ----------------------------
+
+// commitReplay reconciles the changes of a replayed commit for a chunk with the versions of
+// the values, while the chunk is locked. The changes of the values which were changed by a
+// newer commit are removed from the updates of the transaction or turned into merges until
+// the returned function is called. If the commit is rejected, this returns false.
+func (txn *Txn) commitReplay(chunk commit.Chunk) (func(), bool) {
+	policy, state := txn.owner.opts.Conflicts, txn.replay
+	var lost map[string]map[uint32]bool
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				idx := r.Index()
+				latest := txn.owner.versions.get(u.Column, idx)
+				if latest <= state.id {
+					continue
+				}
+
+				if lost == nil {
+					lost = make(map[string]map[uint32]bool, 1)
+				}
+				if lost[u.Column] == nil {
+					lost[u.Column] = make(map[uint32]bool, 1)
+				}
+
+				if !lost[u.Column][idx] {
+					lost[u.Column][idx] = true
+					state.conflicts = append(state.conflicts, Conflict{
+						Column: u.Column,
+						Index:  idx,
+						Commit: state.id,
+						Latest: latest,
+					})
+				}
+			}
+		})
+	}
+
+	switch {
+	case len(lost) == 0:
+		return func() {}, true
+	case policy.Resolve == RejectConflicts:
+		return func() {}, false
+	}
+
+	var replaced []int
+	var originals []*commit.Buffer
+	for i, u := range txn.updates {
+		rows, ok := lost[u.Column]
+		if !ok {
+			continue
+		}
+
+		column, ok := txn.owner.cols.Load(u.Column)
+		if !ok {
+			continue
+		}
+
+		replaced = append(replaced, i)
+		originals = append(originals, u)
+		if policy.Resolve == MergeWrites && mergeOf(column.Column) != nil {
+			txn.updates[i] = txn.mergeWrites(column, u, chunk, rows)
+		} else {
+			txn.updates[i] = txn.discardWrites(column, u, chunk, rows)
+		}
+	}
+
+	return func() {
+		for i, at := range replaced {
+			txn.owner.txns.releasePage(txn.updates[at])
+			txn.updates[at] = originals[i]
+		}
+	}, true
+}
+
+// mergeWrites copies the operations of a chunk, turning the puts of the specified rows into
+// merges so that they are combined with the current values by the merge function
+func (txn *Txn) mergeWrites(column *column, u *commit.Buffer, chunk commit.Chunk, rows map[uint32]bool) *commit.Buffer {
+	merged := txn.owner.txns.acquirePage(u.Column)
+	txn.reader.Range(u, chunk, func(r *commit.Reader) {
+		for r.Next() {
+			idx := r.Index()
+			switch {
+			case r.Type == commit.Delete:
+				if !rows[idx] {
+					merged.PutOperation(commit.Delete, idx)
+				}
+			case rows[idx] || r.Type == commit.Merge:
+				merged.MergeAny(idx, valueOf(column.Column, r))
+			default:
+				merged.PutAny(r.Type, idx, valueOf(column.Column, r))
+			}
+		}
+	})
+	return merged
+}
@@ -0,0 +1,134 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+// newConflicting creates a replica resolving the conflicts, along with a primary whose
+// commits are replayed on it. Both have the same row.
+func newConflicting(policy *ConflictPolicy) (replica, primary *Collection, writer commit.Channel) {
+	writer = make(commit.Channel, 10)
+	replica = NewCollection(Options{Conflicts: policy})
+	primary = NewCollection(Options{Writer: &writer})
+	for _, c := range []*Collection{replica, primary} {
+		c.CreateColumn("name", ForString())
+		c.CreateColumn("hp", ForFloat64(WithMerge(func(value, delta interface{}) interface{} {
+			if value == nil {
+				return delta
+			}
+			return math.Max(value.(float64), delta.(float64))
+		})))
+		c.InsertObject(Object{"name": "merlin", "hp": 10.0})
+	}
+
+	<-writer
+	return
+}
+
+// update updates the values of the row
+func update(c *Collection, values Object) {
+	c.QueryAt(0, func(r Row) error {
+		for k, v := range values {
+			r.SetAny(k, v)
+		}
+		return nil
+	})
+}
+
+func TestReplayLastWriterWins(t *testing.T) {
+	var conflicts []Conflict
+	replica, primary, writer := newConflicting(&ConflictPolicy{
+		OnConflict: func(c Conflict) {
+			conflicts = append(conflicts, c)
+		},
+	})
+	defer replica.Close()
+	defer primary.Close()
+
+	// The primary changes the row before the replica does, and is replayed afterwards
+	update(primary, Object{"name": "morgana", "hp": 20.0})
+	older := <-writer
+	update(replica, Object{"name": "arthur"})
+	assert.NoError(t, replica.Replay(older))
+
+	// The name was changed by a newer commit on the replica, but not the hp
+	replica.QueryAt(0, func(r Row) error {
+		name, _ := r.String("name")
+		hp, _ := r.Float64("hp")
+		assert.Equal(t, "arthur", name)
+		assert.Equal(t, 20.0, hp)
+		return nil
+	})
+
+	assert.Len(t, conflicts, 1)
+	assert.Equal(t, "name", conflicts[0].Column)
+	assert.Equal(t, older.ID, conflicts[0].Commit)
+	assert.Greater(t, conflicts[0].Latest, older.ID)
+
+	// A newer commit of the primary overwrites the value
+	update(primary, Object{"name": "lancelot"})
+	assert.NoError(t, replica.Replay(<-writer))
+	replica.QueryAt(0, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "lancelot", name)
+		return nil
+	})
+}
+
+func TestReplayMergeWrites(t *testing.T) {
+	replica, primary, writer := newConflicting(&ConflictPolicy{
+		Resolve: MergeWrites,
+	})
+	defer replica.Close()
+	defer primary.Close()
+
+	update(primary, Object{"name": "morgana", "hp": 50.0})
+	older := <-writer
+	update(replica, Object{"name": "arthur", "hp": 30.0})
+	assert.NoError(t, replica.Replay(older))
+
+	// The hp is merged with its merge function, the name is kept since it has none
+	replica.QueryAt(0, func(r Row) error {
+		name, _ := r.String("name")
+		hp, _ := r.Float64("hp")
+		assert.Equal(t, "arthur", name)
+		assert.Equal(t, 50.0, hp)
+		return nil
+	})
+}
+
+func TestReplayRejectConflicts(t *testing.T) {
+	replica, primary, writer := newConflicting(&ConflictPolicy{
+		Resolve: RejectConflicts,
+	})
+	defer replica.Close()
+	defer primary.Close()
+
+	update(primary, Object{"name": "morgana", "hp": 20.0})
+	older := <-writer
+	update(replica, Object{"name": "arthur"})
+
+	err := replica.Replay(older)
+	assert.True(t, errors.Is(err, ErrReplayConflict))
+
+	var conflict *ConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Len(t, conflict.Conflicts, 1)
+
+	// None of the changes of the commit are applied
+	replica.QueryAt(0, func(r Row) error {
+		name, _ := r.String("name")
+		hp, _ := r.Float64("hp")
+		assert.Equal(t, "arthur", name)
+		assert.Equal(t, 10.0, hp)
+		return nil
+	})
+}
@@ -0,0 +1,192 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// columnCounters keeps the number of rows matching the predicates of the counters. Every
+// commit evaluates the predicates again for the rows it inserts or deletes, and for the rows
+// whose value it writes into the column of a counter.
+type columnCounters struct {
+	lock      sync.Mutex                // The lock protecting the counters and their rows
+	count     int32                     // The number of counters (atomic)
+	counters  map[string]*columnCounter // The counters, by name
+	observers *rowObservers             // The observers of the rows of the collection
+}
+
+// columnCounter represents the number of rows whose value in a column matches a predicate
+type columnCounter struct {
+	column string                   // The name of the column counted
+	fn     func(v interface{}) bool // The predicate of the values counted
+	rows   bitmap.Bitmap            // The rows currently counted
+	value  int64                    // The number of rows counted (atomic)
+}
+
+// newColumnCounters creates a new set of counters
+func newColumnCounters(observers *rowObservers) *columnCounters {
+	return &columnCounters{
+		counters:  make(map[string]*columnCounter, 4),
+		observers: observers,
+	}
+}
+
+// CreateCounter creates a counter with a specified name, which maintains the number of rows
+// whose value in a column matches a predicate, such as the number of players which are
+// online. The rows are counted once when the counter is created, then every commit only
+// evaluates the predicate again for the rows it changes, so that Counter() returns the number
+// without a transaction, instead of counting an index for every request. The rows without a
+// value in the column are not counted, and a boolean column has no value when it is false.
+func (c *Collection) CreateCounter(counterName, columnName string, fn func(v interface{}) bool) error {
+	if fn == nil || columnName == "" || counterName == "" {
+		return fmt.Errorf("column: create counter must specify name, column and function")
+	}
+
+	column, ok := c.cols.Load(columnName)
+	if !ok || column.IsIndex() {
+		return errorOf(ErrColumnNotFound, "column: unable to create counter, column '%s' does not exist", columnName)
+	}
+
+	// Exclude the transactions while the current rows are counted
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+
+	c.counters.lock.Lock()
+	defer c.counters.lock.Unlock()
+	if _, ok := c.counters.counters[counterName]; ok {
+		return fmt.Errorf("column: unable to create counter, counter '%s' already exists", counterName)
+	}
+
+	entry := &columnCounter{column: columnName, fn: fn}
+	entry.recount(c, column)
+	c.counters.counters[counterName] = entry
+	c.counters.observers.track(&c.counters.count, len(c.counters.counters))
+	return nil
+}
+
+// DropCounter removes the counter with a specified name
+func (c *Collection) DropCounter(counterName string) {
+	c.counters.lock.Lock()
+	defer c.counters.lock.Unlock()
+	delete(c.counters.counters, counterName)
+	c.counters.observers.track(&c.counters.count, len(c.counters.counters))
+}
+
+// Counter returns the number of rows counted by a counter, as of the last commit, and
+// whether the counter exists.
+func (c *Collection) Counter(counterName string) (int, bool) {
+	c.counters.lock.Lock()
+	entry, ok := c.counters.counters[counterName]
+	c.counters.lock.Unlock()
+	if !ok {
+		return 0, false
+	}
+
+	return int(atomic.LoadInt64(&entry.value)), true
+}
+
+// tracked checks whether any counter is maintained
+func (t *columnCounters) tracked() bool {
+	return atomic.LoadInt32(&t.count) > 0
+}
+
+// remove removes the counters of a column
+func (t *columnCounters) remove(columnName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name, entry := range t.counters {
+		if entry.column == columnName {
+			delete(t.counters, name)
+		}
+	}
+	t.observers.track(&t.count, len(t.counters))
+}
+
+// recount counts the rows of every counter again, once a snapshot is restored
+func (t *columnCounters) recount(c *Collection) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, entry := range t.counters {
+		if column, ok := c.cols.Load(entry.column); ok {
+			entry.recount(c, column)
+		}
+	}
+}
+
+// commit counts again the rows changed by a transaction in a chunk, once they are applied
+func (t *columnCounters) commit(txn *Txn, chunk commit.Chunk) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// The rows inserted or deleted are counted again by every counter
+	var changed []uint32
+	if markers, ok := txn.findMarkers(); ok {
+		txn.reader.Range(markers, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				changed = append(changed, r.Index())
+			}
+		})
+	}
+
+	for _, entry := range t.counters {
+		column, ok := txn.owner.cols.Load(entry.column)
+		if !ok {
+			continue
+		}
+
+		rows := changed[:len(changed):len(changed)]
+		for _, u := range txn.updates {
+			if u.Column == entry.column && !u.IsEmpty() {
+				txn.reader.Range(u, chunk, func(r *commit.Reader) {
+					for r.Next() {
+						rows = append(rows, r.Index())
+					}
+				})
+			}
+		}
+
+		txn.owner.lock.RLock()
+		for _, idx := range rows {
+			entry.update(idx, txn.owner.fill.Contains(idx) && entry.matches(column, idx))
+		}
+		txn.owner.lock.RUnlock()
+	}
+}
+
+// recount counts all of the rows of the collection which match the predicate
+func (e *columnCounter) recount(c *Collection, column *column) {
+	c.lock.RLock()
+	fill := append(bitmap.Bitmap(nil), c.fill...)
+	c.lock.RUnlock()
+
+	e.rows.Clear()
+	atomic.StoreInt64(&e.value, 0)
+	fill.Range(func(idx uint32) {
+		e.update(idx, e.matches(column, idx))
+	})
+}
+
+// matches checks whether the value of a row matches the predicate
+func (e *columnCounter) matches(column *column, idx uint32) bool {
+	v, ok := column.Value(idx)
+	return ok && e.fn(v)
+}
+
+// update counts a row, or stops counting it
+func (e *columnCounter) update(idx uint32, match bool) {
+	switch counted := e.rows.Contains(idx); {
+	case match && !counted:
+		e.rows.Set(idx)
+		atomic.AddInt64(&e.value, 1)
+	case !match && counted:
+		e.rows.Remove(idx)
+		atomic.AddInt64(&e.value, -1)
+	}
+}
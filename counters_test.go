@@ -0,0 +1,104 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	assert.NoError(t, players.CreateCounter("active", "active", func(v interface{}) bool {
+		return v == true
+	}))
+	assert.NoError(t, players.CreateCounter("old", "age", func(v interface{}) bool {
+		return v.(float64) >= 30
+	}))
+	assert.Equal(t, countWhere(players, isActive), counterOf(players, "active"))
+	assert.Equal(t, countWhere(players, isOld), counterOf(players, "old"))
+
+	// Deactivate some players, delete others and insert new ones
+	active := counterOf(players, "active")
+	players.Query(func(txn *Txn) error {
+		active := txn.Bool("active")
+		return txn.WithValue("active", func(v interface{}) bool {
+			return v == true
+		}).Range(func(idx uint32) {
+			switch idx % 3 {
+			case 0:
+				active.Set(false)
+			case 1:
+				txn.DeleteAt(idx)
+			}
+		})
+	})
+	players.Insert(func(r Row) error {
+		r.SetBool("active", true)
+		r.SetFloat64("age", 50)
+		return nil
+	})
+
+	assert.Less(t, counterOf(players, "active"), active)
+	assert.Equal(t, countWhere(players, isActive), counterOf(players, "active"))
+	assert.Equal(t, countWhere(players, isOld), counterOf(players, "old"))
+
+	// The counters are dropped along with their column
+	players.DropCounter("old")
+	players.DropColumn("active")
+	_, ok := players.Counter("old")
+	assert.False(t, ok)
+	_, ok = players.Counter("active")
+	assert.False(t, ok)
+}
+
+func TestCounterRestore(t *testing.T) {
+	input := loadPlayers(500)
+	defer input.Close()
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer))
+
+	output := newEmpty(500)
+	defer output.Close()
+	assert.NoError(t, output.CreateCounter("active", "active", func(v interface{}) bool {
+		return v == true
+	}))
+	assert.Equal(t, 0, counterOf(output, "active"))
+	assert.NoError(t, output.Restore(buffer))
+	assert.Equal(t, countWhere(input, isActive), counterOf(output, "active"))
+}
+
+func TestCounterInvalid(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	match := func(v interface{}) bool { return true }
+	assert.Error(t, players.CreateCounter("", "active", match))
+	assert.Error(t, players.CreateCounter("active", "active", nil))
+	assert.Error(t, players.CreateCounter("active", "missing", match))
+	assert.Error(t, players.CreateCounter("human", "human", match))
+	assert.NoError(t, players.CreateCounter("active", "active", match))
+	assert.Error(t, players.CreateCounter("active", "active", match))
+}
+
+// counterOf returns the value of a counter
+func counterOf(c *Collection, counterName string) int {
+	count, _ := c.Counter(counterName)
+	return count
+}
+
+// isActive selects the active players
+func isActive(txn *Txn) *Txn {
+	return txn.WithValue("active", func(v interface{}) bool { return v == true })
+}
+
+// isOld selects the players of 30 or older
+func isOld(txn *Txn) *Txn {
+	return txn.WithValue("age", func(v interface{}) bool { return v.(float64) >= 30 })
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/kelindar/iostream"
+)
+
+// DB represents a set of named collections which are managed together. The collections
+// can be created and dropped at any time, snapshotted and restored as a whole, and the
+// transactions spanning several of them acquire their locks in a consistent order.
+type DB struct {
+	lock        sync.RWMutex           // The lock protecting the collections
+	txlock      sync.RWMutex           // The lock excluding the transactions during a snapshot
+	collections map[string]*Collection // The collections, by their name
+}
+
+// NewDB creates a new, empty set of collections.
+func NewDB() *DB {
+	return &DB{
+		collections: make(map[string]*Collection, 4),
+	}
+}
+
+// Create creates a new collection with the specified name and options. If a collection
+// with the same name already exists, an error is returned.
+func (db *DB) Create(name string, opts ...Options) (*Collection, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if _, exists := db.collections[name]; exists {
+		return nil, fmt.Errorf("column: unable to create collection '%s', it already exists", name)
+	}
+
+	collection := NewCollection(opts...)
+	db.collections[name] = collection
+	return collection, nil
+}
+
+// Collection returns the collection with the specified name, if it exists.
+func (db *DB) Collection(name string) (*Collection, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	collection, ok := db.collections[name]
+	return collection, ok
+}
+
+// Drop closes and removes the collection with the specified name.
+func (db *DB) Drop(name string) error {
+	db.lock.Lock()
+	collection, ok := db.collections[name]
+	delete(db.collections, name)
+	db.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("column: unable to drop collection '%s', it does not exist", name)
+	}
+	return collection.Close()
+}
+
+// List returns the names of the collections, in order.
+func (db *DB) List() []string {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	names := make([]string, 0, len(db.collections))
+	for name := range db.collections {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Close closes all of the collections.
+func (db *DB) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	for name, collection := range db.collections {
+		if err := collection.Close(); err != nil {
+			return err
+		}
+		delete(db.collections, name)
+	}
+	return nil
+}
+
+// Atomic creates a transaction spanning the collections with the specified names, similar
+// to the Atomic() function. The locks of the collections are acquired upfront and in the
+// order in which the collections were created, so that concurrent transactions over the
+// same collections can not deadlock. For the same reason, the transaction can only query
+// the collections named.
+func (db *DB) Atomic(names []string, fn func(tx *MultiTxn) error) error {
+	db.txlock.RLock()
+	defer db.txlock.RUnlock()
+
+	ordered := append([]string(nil), names...)
+	sort.Strings(ordered)
+	collections := make([]*Collection, 0, len(ordered))
+	for i, name := range ordered {
+		collection, ok := db.Collection(name)
+		switch {
+		case !ok:
+			return fmt.Errorf("column: collection '%s' does not exist", name)
+		case i == 0 || ordered[i-1] != name:
+			collections = append(collections, collection)
+		}
+	}
+
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].seq < collections[j].seq
+	})
+
+	return Atomic(func(tx *MultiTxn) error {
+		for _, collection := range collections {
+			if _, err := tx.txnFor(collection); err != nil {
+				return err
+			}
+		}
+
+		tx.sealed = true
+		return fn(tx)
+	})
+}
+
+// Snapshot writes a snapshot of all of the collections into the writer. The transactions
+// created with Atomic() on the set are excluded while the snapshot is taken, so the
+// changes they make to several collections are either all present or all absent.
+func (db *DB) Snapshot(dst io.Writer) error {
+	db.txlock.Lock()
+	defer db.txlock.Unlock()
+
+	names := db.List()
+	writer := iostream.NewWriter(dst)
+	if err := writer.WriteUvarint(uint64(len(names))); err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	for _, name := range names {
+		collection, ok := db.Collection(name)
+		if !ok {
+			return fmt.Errorf("column: collection '%s' was dropped during the snapshot", name)
+		}
+
+		buffer.Reset()
+		if err := collection.Snapshot(&buffer); err != nil {
+			return err
+		}
+
+		if err := writer.WriteString(name); err != nil {
+			return err
+		}
+
+		if err := writer.WriteBytes(buffer.Bytes()); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Restore restores all of the collections from a snapshot. Since the snapshots do not
+// carry the schema, the collections must already be created with their columns.
+func (db *DB) Restore(src io.Reader) error {
+	db.txlock.Lock()
+	defer db.txlock.Unlock()
+
+	reader := iostream.NewReader(src)
+	count, err := reader.ReadUvarint()
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		name, err := reader.ReadString()
+		if err != nil {
+			return err
+		}
+
+		snapshot, err := reader.ReadBytes()
+		if err != nil {
+			return err
+		}
+
+		collection, ok := db.Collection(name)
+		if !ok {
+			return fmt.Errorf("column: unable to restore collection '%s', it does not exist", name)
+		}
+
+		if err := collection.Restore(bytes.NewReader(snapshot)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
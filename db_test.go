@@ -0,0 +1,73 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB(t *testing.T) {
+	db := NewDB()
+	defer db.Close()
+
+	accounts, err := db.Create("accounts")
+	assert.NoError(t, err)
+	accounts.CreateColumn("balance", ForInt())
+	ledger, err := db.Create("ledger")
+	assert.NoError(t, err)
+	ledger.CreateColumn("amount", ForInt())
+
+	_, err = db.Create("accounts")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"accounts", "ledger"}, db.List())
+
+	// Transfer between the collections atomically
+	assert.NoError(t, db.Atomic([]string{"ledger", "accounts"}, func(tx *MultiTxn) error {
+		if err := tx.Query(accounts, func(txn *Txn) error {
+			_, err := txn.InsertObject(Object{"balance": 100})
+			return err
+		}); err != nil {
+			return err
+		}
+
+		return tx.Query(ledger, func(txn *Txn) error {
+			_, err := txn.InsertObject(Object{"amount": 100})
+			return err
+		})
+	}))
+	assert.Equal(t, 1, accounts.Count())
+	assert.Equal(t, 1, ledger.Count())
+
+	// Only the collections named can be queried
+	other := NewCollection()
+	defer other.Close()
+	assert.Equal(t, errNotJoined, db.Atomic([]string{"accounts"}, func(tx *MultiTxn) error {
+		return tx.Query(other, func(txn *Txn) error { return nil })
+	}))
+	assert.Error(t, db.Atomic([]string{"missing"}, func(tx *MultiTxn) error { return nil }))
+
+	// Snapshot the whole set and restore it into another one
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, db.Snapshot(buffer))
+
+	restored := NewDB()
+	defer restored.Close()
+	a, _ := restored.Create("accounts")
+	a.CreateColumn("balance", ForInt())
+	l, _ := restored.Create("ledger")
+	l.CreateColumn("amount", ForInt())
+	assert.NoError(t, restored.Restore(buffer))
+	assert.Equal(t, 1, a.Count())
+	assert.Equal(t, 1, l.Count())
+
+	// Dropping a collection removes it from the set
+	assert.NoError(t, db.Drop("ledger"))
+	assert.Error(t, db.Drop("ledger"))
+	_, ok := db.Collection("ledger")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"accounts"}, db.List())
+}
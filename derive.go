@@ -0,0 +1,235 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Derivation represents the lookup of the values of a column into another collection, by
+// the primary key of its rows.
+type Derivation struct {
+	Key    string      // The column holding the primary key of the source rows
+	Source *Collection // The collection the values are looked up into
+	Column string      // The column of the source collection whose values are copied
+}
+
+// derivation represents a derived column of a collection, along with its lookup
+type derivation struct {
+	Derivation
+	target *Collection // The collection of the derived column
+	name   string      // The name of the derived column
+}
+
+// derivedChange represents a value of a source row, to be copied into the derived column
+type derivedChange struct {
+	derived *derivation // The derived column to update
+	key     string      // The primary key of the source row
+	value   interface{} // The value of the source row, or nil if it was removed
+}
+
+// Derive declares that a column is derived from a lookup into another collection, for
+// example the name of the guild of a player, from the guilds whose primary key is held by
+// the "guild_id" column. If the column does not exist, it is created with the type of the
+// source column. The column is then maintained by the engine: the rows inserted or whose
+// key changes look the value up within their transaction, and once a transaction changing
+// the source column, or inserting or deleting source rows, is committed, the rows of the
+// collection referring to them are updated. The rows whose key is missing from the source
+// have no value.
+//
+// Since the changes of the source are copied once committed, a row written concurrently
+// with them may keep the previous value until its key changes. Every commit changing the
+// source scans the rows of the collection once.
+func (c *Collection) Derive(columnName string, from Derivation) error {
+	switch {
+	case from.Source == nil || from.Source == c:
+		return errors.New("column: unable to derive a column, the source must be another collection")
+	case from.Source.pk == nil:
+		return errorOf(ErrColumnNotFound, "column: unable to derive '%s', source collection has no primary key", columnName)
+	}
+
+	source, ok := from.Source.cols.Load(from.Column)
+	if !ok {
+		return errorOf(ErrColumnNotFound, "column: unable to derive '%s', source column '%s' does not exist", columnName, from.Column)
+	}
+
+	if _, ok := c.cols.Load(from.Key); !ok {
+		return errorOf(ErrColumnNotFound, "column: unable to derive '%s', column '%s' does not exist", columnName, from.Key)
+	}
+
+	// Create the derived column with the type of the source column, unless it exists
+	if _, ok := c.cols.Load(columnName); !ok {
+		empty, err := makeEmpty(source.Column)
+		if err != nil {
+			return err
+		}
+
+		if err := c.CreateColumn(columnName, empty); err != nil {
+			return err
+		}
+	}
+
+	d := &derivation{Derivation: from, target: c, name: columnName}
+	c.OnInsert(d.lookupRow)
+	c.OnUpdate(from.Key, d.lookupRow)
+	from.Source.OnInsert(d.record)
+	from.Source.OnUpdate(from.Column, d.record)
+	from.Source.OnDelete(d.record)
+
+	// Look up the values of the rows which already exist
+	values := make(map[string]interface{}, 64)
+	return c.Query(func(txn *Txn) error {
+		keys := txn.Any(from.Key)
+		return txn.Range(func(idx uint32) {
+			key, ok := derivedKey(keys.Get())
+			if !ok {
+				return
+			}
+
+			value, found := values[key]
+			if !found {
+				value = d.lookup(key)
+				values[key] = value
+			}
+
+			if value != nil {
+				d.write(txn, idx, value)
+			}
+		})
+	})
+}
+
+// lookupRow looks up the value of a row inserted, or whose key changed, within the
+// transaction which changes it.
+func (d *derivation) lookupRow(txn *Txn, event TriggerEvent) error {
+	var value interface{}
+	if key, ok := derivedKey(event.New[d.Key], event.New[d.Key] != nil); ok {
+		value = d.lookup(key)
+	}
+
+	if value == nil && event.Old[d.name] == nil {
+		return nil
+	}
+
+	d.write(txn, event.Index, value)
+	return txn.failed()
+}
+
+// lookup returns the value of the source column for a primary key, or nil if the source
+// row or its value do not exist.
+func (d *derivation) lookup(key string) (value interface{}) {
+	idx, ok := d.Source.IndexOf(key)
+	if !ok {
+		return nil
+	}
+
+	d.Source.QueryAt(idx, func(r Row) error {
+		value, _ = r.Any(d.Column)
+		return nil
+	})
+	return
+}
+
+// write writes the value of the derived column of a row, or removes it if nil
+func (d *derivation) write(txn *Txn, idx uint32, value interface{}) {
+	buffer := txn.bufferFor(d.name)
+	if value == nil {
+		buffer.PutOperation(commit.Delete, idx)
+		return
+	}
+
+	column, ok := txn.columnAt(d.name)
+	if !ok {
+		txn.misuse(d.name, false, "any")
+		return
+	}
+
+	value, err := valueFor(d.name, column.Column, value)
+	if err != nil {
+		txn.abort(err)
+		return
+	}
+
+	buffer.PutAny(commit.Put, idx, value)
+}
+
+// record records a change of a source row within the transaction of the source, so that
+// it is copied into the derived column once the transaction is committed.
+func (d *derivation) record(txn *Txn, event TriggerEvent) error {
+	row := event.New
+	if row == nil {
+		row = event.Old
+	}
+
+	key, ok := derivedKey(row[d.Source.pk.name], row[d.Source.pk.name] != nil)
+	if !ok {
+		return nil
+	}
+
+	var value interface{}
+	if event.New != nil {
+		value = event.New[d.Column]
+	}
+
+	txn.derived = append(txn.derived, derivedChange{
+		derived: d,
+		key:     key,
+		value:   value,
+	})
+	return nil
+}
+
+// propagate copies the changes of the source rows, once committed, into the derived
+// columns of the rows which refer to them. Each derived column is updated with a single
+// transaction, and its errors are ignored since the source changes are already committed.
+func propagate(changes []derivedChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	// Keep the latest value of every key, by derived column
+	order := make([]*derivation, 0, 1)
+	values := make(map[*derivation]map[string]interface{}, 1)
+	for _, change := range changes {
+		if _, ok := values[change.derived]; !ok {
+			order = append(order, change.derived)
+			values[change.derived] = make(map[string]interface{}, len(changes))
+		}
+		values[change.derived][change.key] = change.value
+	}
+
+	for _, d := range order {
+		changed := values[d]
+		d.target.Query(func(txn *Txn) error {
+			keys := txn.Any(d.Key)
+			current := txn.Any(d.name)
+			return txn.Range(func(idx uint32) {
+				key, ok := derivedKey(keys.Get())
+				if !ok {
+					return
+				}
+
+				value, found := changed[key]
+				if _, exists := current.Get(); found && (value != nil || exists) {
+					d.write(txn, idx, value)
+				}
+			})
+		})
+	}
+}
+
+// derivedKey returns the primary key held by a value of a key column
+func derivedKey(value interface{}, ok bool) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, ok
+	default:
+		return fmt.Sprint(v), ok
+	}
+}
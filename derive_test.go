@@ -0,0 +1,117 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerive(t *testing.T) {
+	guilds := NewCollection()
+	defer guilds.Close()
+	assert.NoError(t, guilds.CreateColumn("id", ForKey()))
+	assert.NoError(t, guilds.CreateColumn("name", ForString()))
+	guilds.InsertObject(Object{"id": "g1", "name": "Knights"})
+	guilds.InsertObject(Object{"id": "g2", "name": "Rogues"})
+
+	players := NewCollection()
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("name", ForString()))
+	assert.NoError(t, players.CreateColumn("guild_id", ForString()))
+	alice := players.InsertObject(Object{"name": "alice", "guild_id": "g1"})
+
+	// The existing rows are looked up once derived
+	assert.NoError(t, players.Derive("guild_name", Derivation{
+		Key:    "guild_id",
+		Source: guilds,
+		Column: "name",
+	}))
+	assert.Equal(t, "Knights", guildOf(players, alice))
+
+	// The rows inserted, or whose key changes, are looked up
+	bob := players.InsertObject(Object{"name": "bob", "guild_id": "g2"})
+	carol := players.InsertObject(Object{"name": "carol", "guild_id": "g3"})
+	assert.Equal(t, "Rogues", guildOf(players, bob))
+	assert.Equal(t, "", guildOf(players, carol))
+
+	assert.NoError(t, players.QueryAt(bob, func(r Row) error {
+		r.SetString("guild_id", "g1")
+		return nil
+	}))
+	assert.Equal(t, "Knights", guildOf(players, bob))
+
+	// The changes of the source are copied once committed
+	assert.NoError(t, guilds.QueryKey("g1", func(r Row) error {
+		r.SetString("name", "Paladins")
+		return nil
+	}))
+	guilds.InsertObject(Object{"id": "g3", "name": "Mages"})
+	assert.Equal(t, "Paladins", guildOf(players, alice))
+	assert.Equal(t, "Paladins", guildOf(players, bob))
+	assert.Equal(t, "Mages", guildOf(players, carol))
+
+	// The source changes which are rolled back are not copied
+	guilds.Query(func(txn *Txn) error {
+		txn.QueryKey("g3", func(r Row) error {
+			r.SetString("name", "Druids")
+			return nil
+		})
+		return ErrReadOnly
+	})
+	assert.Equal(t, "Mages", guildOf(players, carol))
+
+	// Deleting the source row removes the value
+	idx, _ := guilds.IndexOf("g3")
+	guilds.DeleteAt(idx)
+	assert.Equal(t, "", guildOf(players, carol))
+
+	// The lookup must refer to existing columns of another collection
+	assert.Error(t, players.Derive("other", Derivation{Key: "guild_id", Source: players, Column: "name"}))
+	assert.ErrorIs(t, players.Derive("other", Derivation{Key: "guild_id", Source: guilds, Column: "motto"}), ErrColumnNotFound)
+	assert.ErrorIs(t, players.Derive("other", Derivation{Key: "guild", Source: guilds, Column: "name"}), ErrColumnNotFound)
+	assert.ErrorIs(t, guilds.Derive("other", Derivation{Key: "name", Source: players, Column: "name"}), ErrColumnNotFound)
+}
+
+func TestDeriveRollbackTo(t *testing.T) {
+	guilds := NewCollection()
+	defer guilds.Close()
+	assert.NoError(t, guilds.CreateColumn("id", ForKey()))
+	assert.NoError(t, guilds.CreateColumn("name", ForString()))
+	guilds.InsertObject(Object{"id": "g1", "name": "Knights"})
+
+	players := NewCollection()
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("guild_id", ForString()))
+	alice := players.InsertObject(Object{"guild_id": "g1"})
+	assert.NoError(t, players.Derive("guild_name", Derivation{
+		Key:    "guild_id",
+		Source: guilds,
+		Column: "name",
+	}))
+
+	// A trigger rolling back to a savepoint discards the source changes recorded after it
+	var savepoint Savepoint
+	guilds.OnUpdate("name", func(txn *Txn, event TriggerEvent) error {
+		return txn.RollbackTo(savepoint)
+	})
+	assert.NoError(t, guilds.Query(func(txn *Txn) error {
+		savepoint = txn.Savepoint()
+		return txn.QueryKey("g1", func(r Row) error {
+			r.SetString("name", "Paladins")
+			return nil
+		})
+	}))
+	assert.Equal(t, "Knights", guildOf(players, alice))
+}
+
+// guildOf returns the derived guild name of a player
+func guildOf(players *Collection, idx uint32) (name string) {
+	players.QueryAt(idx, func(r Row) error {
+		name, _ = r.String("guild_name")
+		return nil
+	})
+	return
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Difference represents the differences between the rows of two collections, matched by
+// their primary key.
+type Difference struct {
+	Added   []string       // The keys of the rows only in the second collection
+	Removed []string       // The keys of the rows only in the first collection
+	Changed []string       // The keys of the rows in both collections, with different values
+	Columns map[string]int // The number of changed rows, by column
+}
+
+// Equal returns whether the collections contain the same rows.
+func (d *Difference) Equal() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the rows of two collections, which must both have a primary key, and
+// returns the rows which were added, removed or changed in the second collection with
+// respect to the first one. The values of every column, except the indexes, are compared
+// and the keys are returned in order.
+func Diff(a, b *Collection) (*Difference, error) {
+	if a.pk == nil || b.pk == nil {
+		return nil, errNoKey
+	}
+
+	diff := &Difference{
+		Columns: make(map[string]int, 4),
+	}
+
+	names := columnsOf(a, b)
+	err := a.Query(func(ta *Txn) error {
+		return b.Query(func(tb *Txn) error {
+			keys := make(map[string]uint32, tb.Count())
+			other := tb.Key()
+			if err := tb.Range(func(idx uint32) {
+				key, _ := other.Get()
+				keys[key] = idx
+			}); err != nil {
+				return err
+			}
+
+			// Compare every row of the first collection with the one of the second
+			own := ta.Key()
+			if err := ta.Range(func(idx uint32) {
+				key, _ := own.Get()
+				at, ok := keys[key]
+				if !ok {
+					diff.Removed = append(diff.Removed, key)
+					return
+				}
+
+				delete(keys, key)
+				changed := false
+				tb.QueryAt(at, func(r Row) error {
+					for _, name := range names {
+						if !reflect.DeepEqual(valueAt(ta, name), valueAt(tb, name)) {
+							diff.Columns[name]++
+							changed = true
+						}
+					}
+					return nil
+				})
+
+				if changed {
+					diff.Changed = append(diff.Changed, key)
+				}
+			}); err != nil {
+				return err
+			}
+
+			for key := range keys {
+				diff.Added = append(diff.Added, key)
+			}
+			return nil
+		})
+	})
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, err
+}
+
+// columnsOf returns the names of the columns of both collections, except the indexes and
+// the primary key.
+func columnsOf(a, b *Collection) []string {
+	names := make([]string, 0, 16)
+	seen := make(map[string]bool, 16)
+	for _, c := range []*Collection{a, b} {
+		c.cols.Range(func(v *column) {
+			if !seen[v.name] && !v.IsIndex() && v.name != c.pk.name {
+				seen[v.name] = true
+				names = append(names, v.name)
+			}
+		})
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// valueAt returns the value of a column at the cursor of the transaction, or nil if the
+// column does not exist or has no value.
+func valueAt(txn *Txn, columnName string) interface{} {
+	column, ok := txn.columnAt(columnName)
+	if !ok {
+		return nil
+	}
+
+	if v, ok := column.Value(txn.cursor); ok {
+		return v
+	}
+	return nil
+}
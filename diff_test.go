@@ -0,0 +1,73 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	a, b := newKeyed(), newKeyed()
+	defer a.Close()
+	defer b.Close()
+
+	for i := 0; i < 100; i++ {
+		obj := Object{"key": fmt.Sprintf("k%03d", i), "name": "Roman", "age": i}
+		a.InsertObject(obj)
+		b.InsertObject(obj)
+	}
+
+	diff, err := Diff(a, b)
+	assert.NoError(t, err)
+	assert.True(t, diff.Equal())
+
+	// Change the second collection
+	b.QueryKey("k001", func(r Row) error {
+		r.SetInt("age", 1000)
+		r.SetString("name", "Merlin")
+		return nil
+	})
+	b.QueryKey("k002", func(r Row) error {
+		r.SetInt("age", 1000)
+		return nil
+	})
+	idx, _ := b.pk.OffsetOf("k003")
+	b.DeleteAt(idx)
+	b.InsertObject(Object{"key": "k999", "age": 1})
+
+	diff, err = Diff(a, b)
+	assert.NoError(t, err)
+	assert.False(t, diff.Equal())
+	assert.Equal(t, []string{"k999"}, diff.Added)
+	assert.Equal(t, []string{"k003"}, diff.Removed)
+	assert.Equal(t, []string{"k001", "k002"}, diff.Changed)
+	assert.Equal(t, map[string]int{"age": 2, "name": 1}, diff.Columns)
+
+	// A snapshot round-trip is lossless
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, b.Snapshot(buffer))
+	c := newKeyed()
+	defer c.Close()
+	assert.NoError(t, c.Restore(buffer))
+	diff, err = Diff(b, c)
+	assert.NoError(t, err)
+	assert.True(t, diff.Equal())
+
+	// Both collections must have a key
+	_, err = Diff(a, NewCollection())
+	assert.Error(t, err)
+}
+
+// newKeyed creates a new collection with a primary key
+func newKeyed() *Collection {
+	out := NewCollection()
+	out.CreateColumn("key", ForKey())
+	out.CreateColumn("name", ForString())
+	out.CreateColumn("age", ForInt())
+	return out
+}
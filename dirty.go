@@ -0,0 +1,156 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errClosed is returned when a dirty transaction is started on a closed collection
+var errClosed = errors.New("column: collection is closed")
+
+// defaultStaleness is the maximum age of the view read by QueryDirty(), if the collection
+// has no staleness configured
+const defaultStaleness = time.Second
+
+// QueryDirty creates a read-only transaction which runs against a frozen fork of the
+// collection, rather than the collection itself. The transaction never takes the locks of
+// the chunks of the collection, hence it never waits for its writers nor delays them; only
+// the renewal of the fork reads the chunks, once per fork. In exchange, the reads are stale:
+// the fork is renewed in the background once it is older than the Staleness option, while
+// the transactions keep reading the previous one until the new one is ready. The first call
+// waits for the fork to be made. If the transaction attempts to modify the collection, it is
+// rolled back and ErrReadOnly is returned.
+func (c *Collection) QueryDirty(fn func(txn *Txn) error) error {
+	view, err := c.dirty.acquire(c)
+	if err != nil {
+		return err
+	}
+
+	defer c.dirty.release(view)
+	return view.fork.Query(fn)
+}
+
+// --------------------------- Dirty Views ----------------------------
+
+// dirtyView represents a frozen fork of the collection, read by the dirty transactions
+type dirtyView struct {
+	fork    *Collection // The frozen fork of the collection
+	created time.Time   // The time at which the fork was made
+	readers int         // The number of transactions reading the fork
+	retired bool        // Whether the fork was replaced, and is closed once no longer read
+}
+
+// dirtyViews represents the views read by the dirty transactions. A view is replaced once
+// it becomes stale, and closed once the last transaction reading it completes.
+type dirtyViews struct {
+	lock     sync.Mutex
+	current  *dirtyView // The view read by the new transactions
+	renewing bool       // Whether the view is being renewed in the background
+	closed   bool       // Whether the collection is closed
+}
+
+// acquire returns the current view for a transaction to read, and starts renewing it in
+// the background if it is stale.
+func (d *dirtyViews) acquire(c *Collection) (*dirtyView, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return nil, errClosed
+	}
+
+	if d.current == nil {
+		view, err := c.dirtyView()
+		if err != nil {
+			return nil, err
+		}
+		d.current = view
+	}
+
+	staleness := c.opts.Staleness
+	if staleness <= 0 {
+		staleness = defaultStaleness
+	}
+
+	if !d.renewing && time.Since(d.current.created) > staleness {
+		d.renewing = true
+		go d.renew(c)
+	}
+
+	d.current.readers++
+	return d.current, nil
+}
+
+// release releases a view once a transaction no longer reads it
+func (d *dirtyViews) release(view *dirtyView) {
+	d.lock.Lock()
+	view.readers--
+	idle := view.retired && view.readers == 0
+	d.lock.Unlock()
+
+	if idle {
+		view.fork.Close()
+	}
+}
+
+// renew replaces the current view with a new one, and retires the previous one
+func (d *dirtyViews) renew(c *Collection) {
+	view, err := c.dirtyView()
+	d.lock.Lock()
+	d.renewing = false
+	if err != nil || d.closed {
+		d.lock.Unlock()
+		if view != nil {
+			view.fork.Close()
+		}
+		return
+	}
+
+	previous := d.current
+	d.current = view
+	d.lock.Unlock()
+	d.retire(previous)
+}
+
+// retire closes a view once no transaction reads it anymore
+func (d *dirtyViews) retire(view *dirtyView) {
+	if view == nil {
+		return
+	}
+
+	d.lock.Lock()
+	view.retired = true
+	idle := view.readers == 0
+	d.lock.Unlock()
+
+	if idle {
+		view.fork.Close()
+	}
+}
+
+// close retires the current view, once the collection is closed
+func (d *dirtyViews) close() {
+	d.lock.Lock()
+	d.closed = true
+	view := d.current
+	d.current = nil
+	d.lock.Unlock()
+	d.retire(view)
+}
+
+// dirtyView makes a frozen fork of the collection, for the dirty transactions to read
+func (c *Collection) dirtyView() (*dirtyView, error) {
+	fork, err := c.Fork()
+	if err != nil {
+		return nil, err
+	}
+
+	fork.Freeze()
+	return &dirtyView{
+		fork:    fork,
+		created: time.Now(),
+	}, nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryDirty(t *testing.T) {
+	players := loadPlayers(500)
+	players.opts.Staleness = time.Millisecond
+
+	// The first dirty read makes the fork of the collection
+	assert.NoError(t, players.QueryDirty(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.Count())
+		return nil
+	}))
+
+	// Dirty reads do not wait for the chunk locks held by the writers
+	players.slock.Lock(0)
+	assert.NoError(t, players.QueryDirty(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.Count())
+		return txn.QueryAt(0, func(r Row) error {
+			_, ok := r.Enum("race")
+			assert.True(t, ok)
+			return nil
+		})
+	}))
+	players.slock.Unlock(0)
+
+	// Writes are rejected and rolled back
+	assert.Equal(t, ErrReadOnly, players.QueryDirty(func(txn *Txn) error {
+		txn.DeleteAll()
+		return nil
+	}))
+	assert.Equal(t, 500, players.Count())
+
+	// The changes are eventually observed, once the fork is renewed
+	players.Query(func(txn *Txn) error {
+		txn.With("old").DeleteAll()
+		return nil
+	})
+
+	assert.Eventually(t, func() bool {
+		count := 0
+		assert.NoError(t, players.QueryDirty(func(txn *Txn) error {
+			count = txn.Count()
+			return nil
+		}))
+		return count == 245
+	}, time.Second, time.Millisecond)
+
+	// The forks are closed along with the collection
+	assert.NoError(t, players.Close())
+	assert.Error(t, players.QueryDirty(func(txn *Txn) error {
+		return nil
+	}))
+}
@@ -0,0 +1,229 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/kelindar/bitmap"
+)
+
+// reducerKind represents a kind of aggregate computed per time bucket
+type reducerKind uint8
+
+// Various kinds of aggregates
+const (
+	reduceCount reducerKind = iota
+	reduceSum
+	reduceAvg
+	reduceMin
+	reduceMax
+)
+
+// Reducer represents an aggregate of a numeric column, computed for every time bucket of
+// a downsampled selection.
+type Reducer struct {
+	kind   reducerKind // The kind of the aggregate
+	column string      // The name of the numeric column
+}
+
+// Count creates a reducer which counts the rows of a bucket which have a value in the column.
+func Count(columnName string) Reducer {
+	return Reducer{kind: reduceCount, column: columnName}
+}
+
+// Sum creates a reducer which sums the values of a column in a bucket.
+func Sum(columnName string) Reducer {
+	return Reducer{kind: reduceSum, column: columnName}
+}
+
+// Avg creates a reducer which averages the values of a column in a bucket.
+func Avg(columnName string) Reducer {
+	return Reducer{kind: reduceAvg, column: columnName}
+}
+
+// Min creates a reducer which computes the smallest value of a column in a bucket.
+func Min(columnName string) Reducer {
+	return Reducer{kind: reduceMin, column: columnName}
+}
+
+// Max creates a reducer which computes the largest value of a column in a bucket.
+func Max(columnName string) Reducer {
+	return Reducer{kind: reduceMax, column: columnName}
+}
+
+// valueOf returns the value of the reducer, out of the aggregate of its column
+func (r Reducer) valueOf(agg Aggregate) float64 {
+	switch r.kind {
+	case reduceCount:
+		return float64(agg.Count)
+	case reduceSum:
+		return agg.Sum
+	case reduceAvg:
+		return agg.Avg()
+	case reduceMin:
+		return agg.Min
+	default:
+		return agg.Max
+	}
+}
+
+// Bucket represents the aggregates of the rows whose time falls into the same interval.
+type Bucket struct {
+	Time   time.Time // The start of the interval
+	Count  int       // The number of rows in the interval
+	Values []float64 // The values of the reducers, in the order they were specified
+}
+
+// Downsampler groups the selection of a transaction into time buckets of a fixed width.
+type Downsampler struct {
+	txn    *Txn          // The transaction whose selection is grouped
+	column string        // The name of the time column
+	width  time.Duration // The width of the buckets
+}
+
+// Downsample groups the current selection into buckets of the specified width, based on
+// the time stored in a numeric column as nanoseconds since the Unix epoch. The buckets
+// are aligned on the epoch, so that the same rows fall into the same buckets across queries.
+func (txn *Txn) Downsample(timeColumn string, width time.Duration) *Downsampler {
+	return &Downsampler{
+		txn:    txn,
+		column: timeColumn,
+		width:  width,
+	}
+}
+
+// Aggregate computes the reducers for every bucket which contains at least one row of the
+// selection, and returns the buckets in the order of their time. The chunks of the
+// selection are processed by a number of workers, which can be set with the Parallel() hint.
+func (d *Downsampler) Aggregate(reducers ...Reducer) ([]Bucket, error) {
+	txn := d.txn
+	if d.width <= 0 {
+		return nil, fmt.Errorf("column: unable to downsample, width of %v is not positive", d.width)
+	}
+
+	at, ok := txn.columnAt(d.column)
+	if !ok || !at.IsNumeric() {
+		return nil, errorOf(ErrTypeMismatch, "column: unable to downsample '%s', column is not numeric", d.column)
+	}
+
+	// Resolve the columns of the reducers, each column is only loaded once per row
+	columns := make([]Numeric, 0, len(reducers))
+	slots := make([]int, len(reducers))
+	names := make(map[string]int, len(reducers))
+	for i, r := range reducers {
+		c, ok := txn.columnAt(r.column)
+		if !ok || !c.IsNumeric() {
+			return nil, errorOf(ErrTypeMismatch, "column: unable to aggregate '%s', column is not numeric", r.column)
+		}
+
+		slot, ok := names[r.column]
+		if !ok {
+			slot = len(columns)
+			names[r.column] = slot
+			columns = append(columns, c.Column.(Numeric))
+		}
+		slots[i] = slot
+	}
+
+	var lock sync.Mutex
+	width := int64(d.width)
+	clock := at.Column.(Numeric)
+	groups := make(map[int64]*bucketState, 64)
+	txn.initialize()
+	txn.rangeParallel(0, func(worker *Txn, offset uint32, index bitmap.Bitmap) {
+		local := make(map[int64]*bucketState, 8)
+		index.Range(func(x uint32) {
+			ts, ok := clock.LoadInt64(offset + x)
+			if !ok {
+				return
+			}
+
+			// Floor the time, so that the times before the epoch are bucketed the same way
+			key := ts / width
+			if ts%width < 0 {
+				key--
+			}
+
+			state := local[key]
+			if state == nil {
+				if !worker.allocate(sizeOfBucketState + len(columns)*sizeOfAggregate) {
+					return
+				}
+
+				state = &bucketState{aggs: make([]Aggregate, len(columns))}
+				local[key] = state
+			}
+
+			state.count++
+			for i, c := range columns {
+				if v, ok := c.LoadFloat64(offset + x); ok {
+					state.aggs[i].merge(Aggregate{Count: 1, Sum: v, Min: v, Max: v})
+				}
+			}
+		})
+
+		lock.Lock()
+		for key, state := range local {
+			if dst := groups[key]; dst != nil {
+				dst.merge(state)
+				continue
+			}
+			groups[key] = state
+		}
+		lock.Unlock()
+	})
+
+	if err := txn.failed(); err != nil {
+		return nil, err
+	}
+
+	// The buckets are sorted once they are all computed, along with their values
+	if !txn.allocate(len(groups) * (sizeOfBucket + len(reducers)*8)) {
+		return nil, txn.err
+	}
+
+	out := make([]Bucket, 0, len(groups))
+	for key, state := range groups {
+		values := make([]float64, len(reducers))
+		for i, r := range reducers {
+			values[i] = r.valueOf(state.aggs[slots[i]])
+		}
+
+		out = append(out, Bucket{
+			Time:   time.Unix(0, key*width),
+			Count:  state.count,
+			Values: values,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Time.Before(out[j].Time)
+	})
+	return out, nil
+}
+
+const (
+	sizeOfBucket      = int(unsafe.Sizeof(Bucket{}))
+	sizeOfBucketState = int(unsafe.Sizeof(bucketState{})) + 16 // Including its entry in the groups
+	sizeOfAggregate   = int(unsafe.Sizeof(Aggregate{}))
+)
+
+// bucketState represents the aggregates of a bucket, while it is being computed
+type bucketState struct {
+	count int         // The number of rows
+	aggs  []Aggregate // The aggregates, by column
+}
+
+// merge merges another state of the same bucket into this one
+func (s *bucketState) merge(other *bucketState) {
+	s.count += other.count
+	for i := range s.aggs {
+		s.aggs[i].merge(other.aggs[i])
+	}
+}
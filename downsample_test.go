@@ -0,0 +1,87 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownsample(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("ts", ForInt64())
+	col.CreateColumn("hp", ForFloat64())
+	col.CreateColumn("mana", ForInt())
+	defer col.Close()
+
+	// One sample per second for 10 minutes, the mana is only sampled every other second
+	start := time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC)
+	for i := 0; i < 600; i++ {
+		col.Insert(func(r Row) error {
+			r.SetInt64("ts", start.Add(time.Duration(i)*time.Second).UnixNano())
+			r.SetFloat64("hp", float64(i))
+			if i%2 == 0 {
+				r.SetInt("mana", 1)
+			}
+			return nil
+		})
+	}
+
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		buckets, err := txn.Downsample("ts", time.Minute).Aggregate(
+			Avg("hp"), Min("hp"), Max("hp"), Sum("mana"), Count("mana"))
+		assert.NoError(t, err)
+		assert.Len(t, buckets, 11)
+
+		// The buckets are aligned on the minute, so the first and last are partial
+		assert.Equal(t, start.Add(-30*time.Second), buckets[0].Time.UTC())
+		assert.Equal(t, 30, buckets[0].Count)
+		assert.Equal(t, []float64{14.5, 0, 29, 15, 15}, buckets[0].Values)
+		assert.Equal(t, 60, buckets[1].Count)
+		assert.Equal(t, []float64{59.5, 30, 89, 30, 30}, buckets[1].Values)
+		assert.Equal(t, 30, buckets[10].Count)
+		return nil
+	}))
+
+	// Only the selection is downsampled
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		buckets, err := txn.WithFloatGreater("hp", 100).Downsample("ts", time.Hour).Aggregate(Count("hp"))
+		assert.NoError(t, err)
+		assert.Len(t, buckets, 1)
+		assert.Equal(t, 499, buckets[0].Count)
+		assert.Equal(t, []float64{499}, buckets[0].Values)
+		return nil
+	}))
+}
+
+func TestDownsampleErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("ts", ForInt64())
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	// Times before the epoch are bucketed downwards
+	col.InsertObject(Object{"ts": int64(-1)})
+	col.InsertObject(Object{"ts": int64(1)})
+	col.InsertObject(Object{"name": "no time"})
+
+	col.Query(func(txn *Txn) error {
+		buckets, err := txn.Downsample("ts", time.Second).Aggregate()
+		assert.NoError(t, err)
+		assert.Equal(t, []Bucket{
+			{Time: time.Unix(-1, 0), Count: 1, Values: []float64{}},
+			{Time: time.Unix(0, 0), Count: 1, Values: []float64{}},
+		}, buckets)
+
+		_, err = txn.Downsample("name", time.Second).Aggregate()
+		assert.Error(t, err)
+		_, err = txn.Downsample("ts", 0).Aggregate()
+		assert.Error(t, err)
+		_, err = txn.Downsample("ts", time.Second).Aggregate(Avg("name"))
+		assert.Error(t, err)
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// DryRun represents the changes a transaction would have made to a collection, had it
+// been committed.
+type DryRun struct {
+	Inserted []uint32       // The indexes of the rows which would be inserted
+	Deleted  []uint32       // The indexes of the rows which would be deleted
+	Columns  map[string]int // The number of rows whose value would be written, by column
+}
+
+// Changed returns whether the transaction would have changed the collection.
+func (d *DryRun) Changed() bool {
+	return len(d.Inserted) > 0 || len(d.Deleted) > 0 || len(d.Columns) > 0
+}
+
+// QueryDryRun executes a transaction, including its triggers and the checks of its hooks
+// and quotas, then reports the changes it would make and discards them instead of
+// committing them. This is useful to preview a bulk update before applying it. The
+// indexes of the rows inserted are only reserved for the duration of the transaction, so
+// the rows inserted once it is actually committed may be given different ones.
+func (c *Collection) QueryDryRun(fn func(txn *Txn) error) (*DryRun, error) {
+	c.txlock.RLock()
+	defer c.txlock.RUnlock()
+	txn := c.txns.acquire(c)
+	defer c.txns.release(txn)
+	defer txn.rollback()
+
+	deadline, cancel := c.withTimeout(context.Background())
+	defer cancel()
+	txn.ctx = deadline
+
+	err := fn(txn)
+	if err == nil {
+		err = txn.failed()
+	}
+	if err == nil {
+		err = txn.runTriggers()
+	}
+	if err == nil {
+		err = txn.checkHooks()
+	}
+	if err == nil {
+		err = txn.checkQuotas()
+	}
+
+	txn.leave()
+	if err != nil {
+		return nil, err
+	}
+	return txn.dryRun(), nil
+}
+
+// dryRun summarizes the pending changes of the transaction
+func (txn *Txn) dryRun() *DryRun {
+	var inserted, deleted bitmap.Bitmap
+	deleted.Or(txn.deletes)
+
+	out := &DryRun{Columns: make(map[string]int, 4)}
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == expireColumn {
+			continue
+		}
+
+		var rows bitmap.Bitmap
+		u.RangeChunks(func(chunk commit.Chunk) {
+			txn.reader.Range(u, chunk, func(r *commit.Reader) {
+				for r.Next() {
+					switch {
+					case u.Column == rowColumn && r.Type == commit.Insert:
+						inserted.Set(r.Index())
+					case u.Column == rowColumn, u.Column == tombstoneColumn:
+						deleted.Set(r.Index())
+					default:
+						rows.Set(r.Index())
+					}
+				}
+			})
+		})
+
+		if count := rows.Count(); count > 0 {
+			out.Columns[u.Column] += count
+		}
+	}
+
+	// Only the rows which exist and were not inserted by the transaction can be deleted
+	txn.owner.lock.RLock()
+	deleted.And(txn.owner.fill)
+	txn.owner.lock.RUnlock()
+	deleted.AndNot(inserted)
+
+	inserted.Range(func(idx uint32) {
+		out.Inserted = append(out.Inserted, idx)
+	})
+	deleted.Range(func(idx uint32) {
+		out.Deleted = append(out.Deleted, idx)
+	})
+	return out
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryDryRun(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	humans := countWhere(players, func(txn *Txn) *Txn { return txn.With("human") })
+	report, err := players.QueryDryRun(func(txn *Txn) error {
+		txn.DeleteAt(1)
+		txn.DeleteAt(2)
+		txn.With("human").Range(func(idx uint32) {
+			txn.Float64("balance").Add(10)
+			txn.Float64("age").Set(30)
+		})
+
+		_, err := txn.Insert(func(r Row) error {
+			r.SetEnum("name", "Merlin")
+			return nil
+		})
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, report.Changed())
+	assert.Equal(t, []uint32{1, 2}, report.Deleted)
+	assert.Len(t, report.Inserted, 1)
+	assert.Equal(t, map[string]int{
+		"balance": humans,
+		"age":     humans,
+		"name":    1,
+	}, report.Columns)
+
+	// Nothing was changed in the collection
+	assert.Equal(t, 500, players.Count())
+	assert.NoError(t, players.QueryAt(1, func(r Row) error {
+		_, ok := r.Any("name")
+		assert.True(t, ok)
+		return nil
+	}))
+}
+
+func TestQueryDryRunDeleteAll(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	report, err := players.QueryDryRun(func(txn *Txn) error {
+		txn.WithValue("age", func(v interface{}) bool {
+			return v.(float64) >= 30
+		}).DeleteAll()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, report.Deleted, countWhere(players, isOld))
+	assert.Empty(t, report.Columns)
+	assert.Equal(t, 500, players.Count())
+
+	// An empty transaction does not change anything, and an error is returned as is
+	report, err = players.QueryDryRun(func(txn *Txn) error { return nil })
+	assert.NoError(t, err)
+	assert.False(t, report.Changed())
+
+	_, err = players.QueryDryRun(func(txn *Txn) error { return fmt.Errorf("failed") })
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrDecrypt is returned when an encrypted snapshot could not be authenticated, either
+	// because the key is wrong or because the snapshot was modified or truncated.
+	ErrDecrypt = errors.New("column: unable to decrypt snapshot")
+)
+
+// KeyProvider represents a source of encryption keys for the snapshots, such as a key
+// management service. The snapshots are encrypted with the current key and record its
+// identifier, so that the keys can be rotated while the older snapshots remain readable
+// as long as the provider is still able to return their keys.
+type KeyProvider interface {
+	Current() (id string, key []byte, err error) // Returns the key to encrypt with
+	Key(id string) ([]byte, error)               // Returns the key with the specified identifier
+}
+
+// Keyring represents a static set of AES keys, by their identifier. The key named by
+// Active is used to encrypt and all of the keys can be used to decrypt.
+type Keyring struct {
+	Active string            // The identifier of the key to encrypt with
+	Keys   map[string][]byte // The keys, of 16, 24 or 32 bytes, by their identifier
+}
+
+// Current returns the key to encrypt with.
+func (k *Keyring) Current() (string, []byte, error) {
+	key, err := k.Key(k.Active)
+	return k.Active, key, err
+}
+
+// Key returns the key with the specified identifier.
+func (k *Keyring) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("column: encryption key '%s' does not exist", id)
+	}
+	return key, nil
+}
+
+// Rotate re-encrypts a snapshot with the current key of the provider, without restoring
+// it. This allows to retire a key once all of the snapshots encrypted with it are rotated.
+func Rotate(dst io.Writer, src io.Reader, keys KeyProvider) error {
+	decrypter, err := newDecrypter(src, keys)
+	if err != nil {
+		return err
+	}
+
+	encrypter, err := newEncrypter(dst, keys)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(encrypter, decrypter); err != nil {
+		return err
+	}
+	return encrypter.Close()
+}
+
+// --------------------------- Encrypted Stream ----------------------------
+
+const (
+	sealMagic = "COLE"   // The magic of an encrypted snapshot
+	sealFrame = 64 << 10 // The size of the plaintext of a frame
+	sealLast  = 1 << 31  // The flag of the size, set on the last frame
+)
+
+// sealHeader writes the header of an encrypted stream, which contains the identifier of
+// the key and the random prefix of the nonces. The header is authenticated with every
+// frame, so that it can not be altered without failing the decryption.
+func sealHeader(keyID string, prefix []byte) []byte {
+	var size [binary.MaxVarintLen64]byte
+	header := make([]byte, 0, 32+len(keyID))
+	header = append(header, sealMagic...)
+	header = append(header, size[:binary.PutUvarint(size[:], uint64(len(keyID)))]...)
+	header = append(header, keyID...)
+	return append(header, prefix...)
+}
+
+// sealNonce returns the nonce of a frame, from the prefix and the frame counter.
+func sealNonce(nonce, prefix []byte, frame uint32) []byte {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], frame)
+	return nonce
+}
+
+// sealData returns the additional data of a frame, so that the frames can be neither
+// reordered nor truncated.
+func sealData(data, header []byte, last bool) []byte {
+	data = append(data[:0], header...)
+	if last {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// newAEAD creates an AES-GCM cipher for the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to create cipher, %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypter represents a writer which encrypts the stream in authenticated frames.
+type encrypter struct {
+	dst    io.Writer
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	nonce  []byte
+	data   []byte
+	buffer []byte
+	sealed []byte
+	frame  uint32
+}
+
+// newEncrypter creates a new encrypter with the current key and writes the header.
+func newEncrypter(dst io.Writer, keys KeyProvider) (*encrypter, error) {
+	id, key, err := keys.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, aead.NonceSize()-4)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header := sealHeader(id, prefix)
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encrypter{
+		dst:    dst,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		buffer: make([]byte, 0, sealFrame),
+	}, nil
+}
+
+// Write buffers the data and encrypts every complete frame.
+func (e *encrypter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(e.buffer) == sealFrame {
+			if err := e.flush(false); err != nil {
+				return 0, err
+			}
+		}
+
+		size := sealFrame - len(e.buffer)
+		if size > len(p) {
+			size = len(p)
+		}
+
+		e.buffer = append(e.buffer, p[:size]...)
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// Close encrypts the last frame, which marks the end of the stream.
+func (e *encrypter) Close() error {
+	return e.flush(true)
+}
+
+// flush encrypts the buffered frame and writes it, prefixed by its size.
+func (e *encrypter) flush(last bool) error {
+	e.data = sealData(e.data, e.header, last)
+	e.sealed = e.aead.Seal(append(e.sealed[:0], 0, 0, 0, 0),
+		sealNonce(e.nonce, e.prefix, e.frame), e.buffer, e.data)
+
+	size := uint32(len(e.sealed) - 4)
+	if last {
+		size |= sealLast
+	}
+
+	binary.BigEndian.PutUint32(e.sealed, size)
+	if _, err := e.dst.Write(e.sealed); err != nil {
+		return err
+	}
+
+	e.frame++
+	e.buffer = e.buffer[:0]
+	return nil
+}
+
+// decrypter represents a reader which decrypts and authenticates the frames of a stream.
+type decrypter struct {
+	src    io.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	nonce  []byte
+	data   []byte
+	sealed []byte
+	buffer []byte
+	frame  uint32
+	done   bool
+}
+
+// newDecrypter reads the header of an encrypted stream and creates a decrypter with the
+// key it was encrypted with.
+func newDecrypter(src io.Reader, keys KeyProvider) (*decrypter, error) {
+	var header bytes.Buffer
+	magic := make([]byte, len(sealMagic))
+	if _, err := io.ReadFull(io.TeeReader(src, &header), magic); err != nil || string(magic) != sealMagic {
+		return nil, ErrDecrypt
+	}
+
+	reader := &byteReader{Reader: io.TeeReader(src, &header)}
+	size, err := binary.ReadUvarint(reader)
+	if err != nil || size > 1024 {
+		return nil, ErrDecrypt
+	}
+
+	id := make([]byte, size)
+	if _, err := io.ReadFull(reader, id); err != nil {
+		return nil, ErrDecrypt
+	}
+
+	key, err := keys.Key(string(id))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, aead.NonceSize()-4)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return &decrypter{
+		src:    src,
+		aead:   aead,
+		header: header.Bytes(),
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+// Read decrypts the next frames as needed and reads the plaintext.
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.buffer) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buffer)
+	d.buffer = d.buffer[n:]
+	return n, nil
+}
+
+// next reads, decrypts and authenticates the next frame of the stream.
+func (d *decrypter) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(d.src, size[:]); err != nil {
+		return ErrDecrypt
+	}
+
+	length := binary.BigEndian.Uint32(size[:])
+	last := length&sealLast != 0
+	length &^= sealLast
+	if length > sealFrame+uint32(d.aead.Overhead()) {
+		return ErrDecrypt
+	}
+
+	if cap(d.sealed) < int(length) {
+		d.sealed = make([]byte, length)
+	}
+
+	d.sealed = d.sealed[:length]
+	if _, err := io.ReadFull(d.src, d.sealed); err != nil {
+		return ErrDecrypt
+	}
+
+	// The flag of the last frame is authenticated along with the header
+	d.data = sealData(d.data, d.header, last)
+	plain, err := d.aead.Open(d.sealed[:0], sealNonce(d.nonce, d.prefix, d.frame), d.sealed, d.data)
+	if err != nil {
+		return ErrDecrypt
+	}
+
+	d.buffer = plain
+	d.done = last
+	d.frame++
+	return nil
+}
+
+// byteReader implements io.ByteReader on top of a reader.
+type byteReader struct {
+	io.Reader
+}
+
+// ReadByte reads a single byte.
+func (r *byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
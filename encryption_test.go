@@ -0,0 +1,125 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedSnapshot(t *testing.T) {
+	keys := &Keyring{
+		Active: "v1",
+		Keys: map[string][]byte{
+			"v1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+
+	input := newSecret(keys)
+	for i := 0; i < 20000; i++ {
+		input.Insert(func(r Row) error {
+			r.SetString("email", "roman@example.com")
+			return nil
+		})
+	}
+
+	// The snapshot must not contain the plaintext
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer))
+	assert.False(t, bytes.Contains(buffer.Bytes(), []byte("roman@example.com")))
+	encrypted := buffer.Bytes()
+
+	// Restore with the same keys
+	output := newSecret(keys)
+	assert.NoError(t, output.Restore(bytes.NewReader(encrypted)))
+	assert.Equal(t, 20000, output.Count())
+
+	// Restoring with a missing or a wrong key fails
+	assert.Error(t, newSecret(&Keyring{Active: "v1"}).Restore(bytes.NewReader(encrypted)))
+	err := newSecret(&Keyring{Active: "v1", Keys: map[string][]byte{
+		"v1": bytes.Repeat([]byte{2}, 32),
+	}}).Restore(bytes.NewReader(encrypted))
+	assert.Contains(t, err.Error(), ErrDecrypt.Error())
+
+	// Rotate the key, the old snapshot remains readable as long as its key is kept
+	keys.Keys["v2"] = bytes.Repeat([]byte{3}, 16)
+	keys.Active = "v2"
+	rotated := bytes.NewBuffer(nil)
+	assert.NoError(t, Rotate(rotated, bytes.NewReader(encrypted), keys))
+
+	delete(keys.Keys, "v1")
+	output = newSecret(keys)
+	assert.NoError(t, output.Restore(rotated))
+	assert.Equal(t, 20000, output.Count())
+	assert.Error(t, newSecret(keys).Restore(bytes.NewReader(encrypted)))
+}
+
+func TestEncryptedTampering(t *testing.T) {
+	keys := &Keyring{
+		Active: "v1",
+		Keys: map[string][]byte{
+			"v1": bytes.Repeat([]byte{1}, 32),
+			"v2": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+
+	encrypt := func(data []byte) []byte {
+		buffer := bytes.NewBuffer(nil)
+		encrypter, err := newEncrypter(buffer, keys)
+		assert.NoError(t, err)
+		encrypter.Write(data)
+		assert.NoError(t, encrypter.Close())
+		return buffer.Bytes()
+	}
+
+	decrypt := func(data []byte) ([]byte, error) {
+		decrypter, err := newDecrypter(bytes.NewReader(data), keys)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(decrypter)
+	}
+
+	plain := bytes.Repeat([]byte("hello"), 30000)
+	sealed := encrypt(plain)
+	output, err := decrypt(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, output)
+
+	// Flipping a bit of the ciphertext is detected
+	modified := append([]byte{}, sealed...)
+	modified[len(modified)-20] ^= 1
+	_, err = decrypt(modified)
+	assert.Equal(t, ErrDecrypt, err)
+
+	// Truncating the last frame is detected
+	_, err = decrypt(sealed[:len(sealed)-10])
+	assert.Equal(t, ErrDecrypt, err)
+
+	// Changing the key identifier of the header is detected, even with the same key
+	modified = append([]byte{}, sealed...)
+	modified[len(sealMagic)+2] = '2'
+	_, err = decrypt(modified)
+	assert.Equal(t, ErrDecrypt, err)
+
+	// Not an encrypted stream
+	_, err = decrypt([]byte("hello"))
+	assert.Equal(t, ErrDecrypt, err)
+
+	// Invalid key size
+	_, err = newEncrypter(io.Discard, &Keyring{Active: "x", Keys: map[string][]byte{"x": {1}}})
+	assert.Error(t, err)
+}
+
+// newSecret creates a collection with encrypted snapshots
+func newSecret(keys KeyProvider) *Collection {
+	out := NewCollection(Options{
+		Encryption: keys,
+	})
+	out.CreateColumn("email", ForString())
+	return out
+}
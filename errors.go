@@ -0,0 +1,97 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrColumnNotFound is returned when an operation or an accessor refers to a column which
+	// does not exist, including the primary key column of a collection which has none.
+	ErrColumnNotFound = errors.New("column: column does not exist")
+
+	// ErrColumnExists is returned when a column is created with the name of an existing one.
+	ErrColumnExists = errors.New("column: column already exists")
+
+	// ErrTypeMismatch is returned when a value, or an accessor, does not match the type of the
+	// column it is used with, for example a string written into a numeric column.
+	ErrTypeMismatch = errors.New("column: value does not match the type of the column")
+
+	// ErrDuplicateKey is returned when a row is inserted with a primary key which is already
+	// taken by another row.
+	ErrDuplicateKey = errors.New("column: primary key already exists")
+
+	// ErrReservedColumn is returned when a transaction writes into one of the internal columns
+	// which are maintained by the collection itself, such as the versions of the rows.
+	ErrReservedColumn = errors.New("column: column is reserved")
+)
+
+// kindError represents an error with a detailed message, which wraps one of the errors
+// above so that the callers can tell the failures apart with errors.Is().
+type kindError struct {
+	kind    error  // The kind of the error
+	message string // The detailed message
+}
+
+// errorOf creates a new error of a specific kind, with a formatted message
+func errorOf(kind error, format string, args ...interface{}) error {
+	return &kindError{
+		kind:    kind,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the detailed message of the error
+func (e *kindError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the kind of the error
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// misuse aborts the transaction once an accessor is created for a column which does not
+// exist, or which is not of the type of the accessor. The accessor reads no values and its
+// writes are rolled back, while the query returns the error.
+func (txn *Txn) misuse(columnName string, exists bool, typeName string) {
+	if !exists {
+		txn.abort(errorOf(ErrColumnNotFound, "column: column '%s' does not exist", columnName))
+		return
+	}
+
+	txn.abort(errorOf(ErrTypeMismatch, "column: column '%s' is not of type %s", columnName, typeName))
+}
+
+// valueFor checks that a value can be stored in a column, and converts the numbers to the
+// type of the column, so that a value is never written in a format the column misreads.
+func valueFor(columnName string, c Column, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch c.(type) {
+	case *columnBool:
+		if _, ok := value.(bool); !ok {
+			return nil, errorOf(ErrTypeMismatch, "column: unable to store %T into '%s', column is of type bool", value, columnName)
+		}
+		return value, nil
+	case Textual:
+		switch value.(type) {
+		case string, []byte:
+			return value, nil
+		default:
+			return nil, errorOf(ErrTypeMismatch, "column: unable to store %T into '%s', column is of type string", value, columnName)
+		}
+	}
+
+	if _, isNumber := numericTypeOf(c); !isNumber {
+		return value, nil
+	}
+
+	m := Set(columnName, value)
+	return m.valueOf(c)
+}
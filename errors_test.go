@@ -0,0 +1,104 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorKinds(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+	assert.NoError(t, col.CreateColumn("age", ForInt32()))
+	assert.NoError(t, col.CreateColumn("active", ForBool()))
+
+	err := col.CreateColumn("name", ForString())
+	assert.ErrorIs(t, err, ErrColumnExists)
+	assert.Equal(t, "column: unable to create column 'name', already exists", err.Error())
+
+	// The collection has no primary key
+	assert.ErrorIs(t, col.QueryKey("roman", func(Row) error { return nil }), ErrColumnNotFound)
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		txn.Key().Set("roman")
+		return nil
+	}), ErrColumnNotFound)
+
+	// The values which can not be stored in their column are rejected
+	for _, object := range []Object{
+		{"name": 42},
+		{"age": "old"},
+		{"active": 1},
+	} {
+		assert.ErrorIs(t, insertObject(col, object), ErrTypeMismatch)
+	}
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		_, err := txn.InsertObjects([]Object{{"name": "roman"}, {"age": true}})
+		return err
+	}), ErrTypeMismatch)
+	assert.Zero(t, col.Count())
+
+	// The numbers are converted to the type of the column, rather than misread
+	idx := col.InsertObject(Object{"name": "roman", "age": 30.0})
+	assert.Equal(t, 1, col.Count())
+	assert.ErrorIs(t, col.QueryAt(idx, func(r Row) error {
+		r.SetAny("age", int64(31))
+		r.SetAny("name", 3.14)
+		return nil
+	}), ErrTypeMismatch)
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		age, _ := r.Int32("age")
+		assert.Equal(t, int32(30), age)
+		r.SetAny("age", uint8(32))
+		return nil
+	}))
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		age, _ := r.Int32("age")
+		assert.Equal(t, int32(32), age)
+		return nil
+	}))
+
+	// The frozen collections can not be written
+	col.Freeze()
+	assert.True(t, errors.Is(col.Query(func(txn *Txn) error {
+		_, err := txn.InsertObject(Object{"name": "merlin"})
+		return err
+	}), ErrReadOnly))
+}
+
+func TestDuplicateKeyInsert(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("serial", ForKey()))
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+
+	assert.NoError(t, insertObject(col, Object{"serial": "a", "name": "roman"}))
+	assert.ErrorIs(t, insertObject(col, Object{"serial": "a", "name": "merlin"}), ErrDuplicateKey)
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		_, err := txn.InsertObjects([]Object{{"serial": "b"}, {"serial": "a"}})
+		return err
+	}), ErrDuplicateKey)
+
+	// The collection-level insert rolls back silently
+	col.InsertObject(Object{"serial": "a", "name": "merlin"})
+	assert.Equal(t, 1, col.Count())
+
+	// Upserting the key is still allowed
+	assert.NoError(t, col.QueryKey("a", func(r Row) error {
+		r.SetString("name", "merlin")
+		return nil
+	}))
+	assert.Equal(t, 1, col.Count())
+}
+
+// insertObject inserts an object and returns the error of the transaction
+func insertObject(col *Collection, object Object) error {
+	return col.Query(func(txn *Txn) error {
+		_, err := txn.InsertObject(object)
+		return err
+	})
+}
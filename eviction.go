@@ -0,0 +1,160 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Eviction represents a policy which decides which rows to evict once the collection
+// grows beyond its maximum number of rows.
+type Eviction interface {
+	Insert(idx uint32)     // Insert is called when a row is inserted
+	Touch(idx uint32)      // Touch is called when a row is accessed or updated
+	Remove(idx uint32)     // Remove is called when a row is deleted
+	Evict() (uint32, bool) // Evict selects the next row to evict
+}
+
+// EvictFIFO creates an eviction policy which evicts the rows in their insertion order.
+func EvictFIFO() Eviction {
+	return newEvictList(false)
+}
+
+// EvictLRU creates an eviction policy which evicts the least recently accessed or
+// updated rows first.
+func EvictLRU() Eviction {
+	return newEvictList(true)
+}
+
+// evictList represents an ordered list of rows, with the oldest one at the front.
+type evictList struct {
+	lock  sync.Mutex
+	touch bool                     // Whether accessing a row moves it to the back
+	order *list.List               // The order of the rows
+	rows  map[uint32]*list.Element // The elements of the list, by row
+}
+
+// newEvictList creates a new eviction list.
+func newEvictList(touch bool) *evictList {
+	return &evictList{
+		touch: touch,
+		order: list.New(),
+		rows:  make(map[uint32]*list.Element, 64),
+	}
+}
+
+// Insert is called when a row is inserted.
+func (l *evictList) Insert(idx uint32) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if e, ok := l.rows[idx]; ok {
+		l.order.MoveToBack(e)
+		return
+	}
+
+	l.rows[idx] = l.order.PushBack(idx)
+}
+
+// Touch is called when a row is accessed or updated.
+func (l *evictList) Touch(idx uint32) {
+	if !l.touch {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if e, ok := l.rows[idx]; ok {
+		l.order.MoveToBack(e)
+	}
+}
+
+// Remove is called when a row is deleted.
+func (l *evictList) Remove(idx uint32) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if e, ok := l.rows[idx]; ok {
+		l.order.Remove(e)
+		delete(l.rows, idx)
+	}
+}
+
+// Evict selects the next row to evict.
+func (l *evictList) Evict() (uint32, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	e := l.order.Front()
+	if e == nil {
+		return 0, false
+	}
+
+	idx := l.order.Remove(e).(uint32)
+	delete(l.rows, idx)
+	return idx, true
+}
+
+// --------------------------- Collection ---------------------------
+
+// evict deletes the rows selected by the eviction policy until the collection no longer
+// exceeds its maximum number of rows.
+func (c *Collection) evict() {
+	policy, limit := c.opts.Eviction, c.opts.MaxRows
+	count := int(atomic.LoadUint64(&c.count))
+	if policy == nil || limit <= 0 || count <= limit {
+		return
+	}
+
+	// Select the rows to evict first, the deletion itself triggers another eviction
+	victims := make([]uint32, 0, count-limit)
+	for n := count - limit; n > 0; n-- {
+		idx, ok := policy.Evict()
+		if !ok {
+			break
+		}
+		victims = append(victims, idx)
+	}
+
+	if len(victims) == 0 {
+		return
+	}
+
+	c.Query(func(txn *Txn) error {
+		for _, idx := range victims {
+			txn.deleteAt(idx)
+		}
+		return nil
+	})
+
+	c.log(LevelInfo, EventEviction, fmt.Sprintf("evicted %d rows", len(victims)), nil,
+		FieldRows, len(victims),
+		FieldLimit, limit,
+	)
+}
+
+// commitEviction notifies the eviction policy of the rows inserted, updated or deleted
+// in the chunk by the transaction.
+func (txn *Txn) commitEviction(policy Eviction, chunk commit.Chunk) {
+	for _, u := range txn.updates {
+		if u.IsEmpty() {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				switch {
+				case u.Column != rowColumn:
+					policy.Touch(r.Index())
+				case r.Type == commit.Insert:
+					policy.Insert(r.Index())
+				default:
+					policy.Remove(r.Index())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictFIFO(t *testing.T) {
+	cache := newCache(EvictFIFO())
+	for i := 0; i < 10; i++ {
+		cache.InsertObject(Object{"name": fmt.Sprintf("item %d", i)})
+	}
+
+	// Touching the first item does not matter for FIFO
+	assert.NoError(t, cache.QueryAt(0, func(r Row) error { return nil }))
+	cache.InsertObject(Object{"name": "item 10"})
+
+	assert.Equal(t, 5, cache.Count())
+	assert.ElementsMatch(t, []string{"item 10", "item 6", "item 7", "item 8", "item 9"}, namesOf(cache))
+}
+
+func TestEvictLRU(t *testing.T) {
+	cache := newCache(EvictLRU())
+	for i := 0; i < 5; i++ {
+		cache.InsertObject(Object{"name": fmt.Sprintf("item %d", i)})
+	}
+
+	// Access the first and update the second item, so they become recent
+	assert.NoError(t, cache.QueryAt(0, func(r Row) error { return nil }))
+	assert.NoError(t, cache.QueryAt(1, func(r Row) error {
+		r.SetString("name", "item 1*")
+		return nil
+	}))
+
+	cache.InsertObject(Object{"name": "item 5"})
+	cache.InsertObject(Object{"name": "item 6"})
+	assert.Equal(t, 5, cache.Count())
+	assert.ElementsMatch(t, []string{"item 0", "item 1*", "item 5", "item 6", "item 4"}, namesOf(cache))
+}
+
+func TestEvictDeleted(t *testing.T) {
+	cache := newCache(EvictFIFO())
+	for i := 0; i < 5; i++ {
+		cache.InsertObject(Object{"name": fmt.Sprintf("item %d", i)})
+	}
+
+	// Deleted rows are no longer candidates for eviction
+	assert.True(t, cache.DeleteAt(0))
+	cache.InsertObject(Object{"name": "item 5"})
+	cache.InsertObject(Object{"name": "item 6"})
+	assert.Equal(t, 5, cache.Count())
+	assert.ElementsMatch(t, []string{"item 5", "item 6", "item 2", "item 3", "item 4"}, namesOf(cache))
+}
+
+// newCache creates a new bounded collection for testing
+func newCache(policy Eviction) *Collection {
+	out := NewCollection(Options{
+		MaxRows:  5,
+		Eviction: policy,
+	})
+	out.CreateColumn("name", ForString())
+	return out
+}
+
+// namesOf returns the names in the collection, ordered by index
+func namesOf(c *Collection) (names []string) {
+	c.Query(func(txn *Txn) error {
+		name := txn.String("name")
+		return txn.Range(func(idx uint32) {
+			v, _ := name.Get()
+			names = append(names, v)
+		})
+	})
+	return
+}
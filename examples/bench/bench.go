@@ -136,10 +136,8 @@ func createCollection(out *column.Collection, amount int) *column.Collection {
 	// Load the data in
 	for i := 0; i < amount/len(data); i++ {
 		out.Query(func(txn *column.Txn) error {
-			for _, p := range data {
-				txn.InsertObject(p)
-			}
-			return nil
+			_, err := txn.InsertObjects(data)
+			return err
 		})
 	}
 
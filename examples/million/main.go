@@ -84,12 +84,9 @@ func main() {
 	// update age of mages
 	measure("update", "age of mages", func() {
 		updates := 0
-		players.Query(func(txn *column.Txn) error {
-			age := txn.Float64("age")
-			return txn.With("mage").Range(func(idx uint32) {
-				updates++
-				age.Set(99.0)
-			})
+		players.Query(func(txn *column.Txn) (err error) {
+			updates, err = txn.With("mage").Update(column.Set("age", 99.0))
+			return
 		})
 		fmt.Printf("-> updated %v rows\n", updates)
 	}, runs)
@@ -141,20 +138,22 @@ func createCollection(out *column.Collection, amount int) *column.Collection {
 		panic(err)
 	}
 
-	// Load the data in
+	// Load the data in, the ingester batches the rows into transactions
+	ingest := out.Ingest(column.IngestOptions{
+		BatchSize: 5000,
+	})
+
 	for i := 0; i < amount/len(data); i++ {
 		if i%200 == 0 {
-			fmt.Printf("-> inserted %v rows\n", out.Count())
+			fmt.Printf("-> inserted %v rows\n", ingest.Inserted())
 		}
 
-		out.Query(func(txn *column.Txn) error {
-			for _, p := range data {
-				txn.InsertObject(p)
-			}
-			return nil
-		})
+		for _, row := range data {
+			ingest.Insert(row)
+		}
 	}
 
+	ingest.Close()
 	return out
 }
 
@@ -0,0 +1,220 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// columnTTLs keeps the time at which the values of the columns with a time-to-live expire.
+// Every commit sets the deadline of the values it writes into these columns, and clears the
+// deadlines of the rows it inserts or deletes.
+type columnTTLs struct {
+	lock      sync.Mutex            // The lock protecting the columns and their deadlines
+	count     int32                 // The number of columns with a time-to-live (atomic)
+	columns   map[string]*columnTTL // The columns with a time-to-live, by name
+	observers *rowObservers         // The observers of the rows of the collection
+}
+
+// columnTTL represents the time-to-live of the values of a column
+type columnTTL struct {
+	ttl       time.Duration // The time-to-live of the values
+	deadlines []int64       // The time at which the value of every row expires, 0 if it does not
+}
+
+// newColumnTTLs creates a new set of column deadlines
+func newColumnTTLs(observers *rowObservers) *columnTTLs {
+	return &columnTTLs{
+		columns:   make(map[string]*columnTTL, 4),
+		observers: observers,
+	}
+}
+
+// SetColumnTTL sets a time-to-live for the values of a column, independently of the
+// time-to-live of the rows. Once a value was not written for that long, it is removed and
+// the row is left without a value in the column, as if it was never set, which is useful
+// for ephemeral state such as the last ability used by a player. The values are removed by
+// the vacuum, so they may outlive their time-to-live by up to its interval. The values which
+// exist when the time-to-live is set expire once it elapses. A time-to-live of zero removes
+// it, so that the values are kept. Since the deadlines are kept in memory, the values of a
+// restored snapshot expire once the time-to-live is set again.
+func (c *Collection) SetColumnTTL(columnName string, ttl time.Duration) error {
+	column, ok := c.cols.Load(columnName)
+	if !ok || column.IsIndex() {
+		return errorOf(ErrColumnNotFound, "column: unable to set time-to-live, column '%s' does not exist", columnName)
+	}
+
+	// Exclude the transactions while the deadlines of the current values are set
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+	if ttl <= 0 {
+		c.ttls.remove(columnName)
+		return nil
+	}
+
+	entry := &columnTTL{ttl: ttl}
+	deadline := c.now().Add(ttl).UnixNano()
+	column.lock.RLock()
+	fill := append(bitmap.Bitmap(nil), (*column.Index())...)
+	column.lock.RUnlock()
+	fill.Range(func(idx uint32) {
+		entry.set(idx, deadline)
+	})
+
+	c.ttls.lock.Lock()
+	c.ttls.columns[columnName] = entry
+	c.ttls.observers.track(&c.ttls.count, len(c.ttls.columns))
+	c.ttls.lock.Unlock()
+	return nil
+}
+
+// tracked checks whether any column has a time-to-live
+func (t *columnTTLs) tracked() bool {
+	return atomic.LoadInt32(&t.count) > 0
+}
+
+// remove removes the time-to-live of a column
+func (t *columnTTLs) remove(columnName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.columns, columnName)
+	t.observers.track(&t.count, len(t.columns))
+}
+
+// set sets the deadline of the value of a row
+func (e *columnTTL) set(idx uint32, deadline int64) {
+	if idx >= uint32(len(e.deadlines)) {
+		if deadline == 0 {
+			return
+		}
+
+		clone := make([]int64, idx+1, resize(cap(e.deadlines), idx+1))
+		copy(clone, e.deadlines)
+		e.deadlines = clone
+	}
+	e.deadlines[idx] = deadline
+}
+
+// due checks whether the value of a row has expired at the specified time
+func (e *columnTTL) due(idx uint32, now int64) bool {
+	return idx < uint32(len(e.deadlines)) && e.deadlines[idx] != 0 && e.deadlines[idx] <= now
+}
+
+// commit sets the deadlines of the values written by a transaction in a chunk, once they
+// are applied
+func (t *columnTTLs) commit(txn *Txn, chunk commit.Chunk) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := txn.owner.now()
+
+	// The rows inserted or deleted start without a value
+	if markers, ok := txn.findMarkers(); ok {
+		txn.reader.Range(markers, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				for _, entry := range t.columns {
+					entry.set(r.Index(), 0)
+				}
+			}
+		})
+	}
+
+	for _, u := range txn.updates {
+		entry, ok := t.columns[u.Column]
+		if !ok || u.IsEmpty() {
+			continue
+		}
+
+		deadline := now.Add(entry.ttl).UnixNano()
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				switch r.Type {
+				case commit.Delete:
+					entry.set(r.Index(), 0)
+				default:
+					entry.set(r.Index(), deadline)
+				}
+			}
+		})
+	}
+}
+
+// commitExpiry discards the removals of the expired values which were written again since
+// they expired, while the chunk is locked, until the returned function is called.
+func (txn *Txn) commitExpiry(chunk commit.Chunk) func() {
+	t := txn.owner.ttls
+	now := txn.expiring
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var replaced []int
+	var originals []*commit.Buffer
+	for i, u := range txn.updates {
+		entry, ok := t.columns[u.Column]
+		if !ok || u.IsEmpty() {
+			continue
+		}
+
+		renewed := make(map[uint32]bool)
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				if !entry.due(r.Index(), now) {
+					renewed[r.Index()] = true
+				}
+			}
+		})
+
+		if column, ok := txn.owner.cols.Load(u.Column); ok && len(renewed) > 0 {
+			replaced = append(replaced, i)
+			originals = append(originals, u)
+			txn.updates[i] = txn.discardWrites(column, u, chunk, renewed)
+		}
+	}
+
+	return func() {
+		for i, at := range replaced {
+			txn.owner.txns.releasePage(txn.updates[at])
+			txn.updates[at] = originals[i]
+		}
+	}
+}
+
+// expireColumns removes the values of the columns with a time-to-live which have expired at
+// the specified time.
+func (c *Collection) expireColumns(at time.Time) {
+	if !c.ttls.tracked() {
+		return
+	}
+
+	// Collect the rows whose values have expired, by column
+	now := at.UnixNano()
+	expired := make(map[string][]uint32, 4)
+	c.ttls.lock.Lock()
+	for name, entry := range c.ttls.columns {
+		for idx := range entry.deadlines {
+			if entry.due(uint32(idx), now) {
+				expired[name] = append(expired[name], uint32(idx))
+			}
+		}
+	}
+	c.ttls.lock.Unlock()
+	if len(expired) == 0 {
+		return
+	}
+
+	c.Query(func(txn *Txn) error {
+		txn.expiring = now
+		for name, rows := range expired {
+			buffer := txn.bufferFor(name)
+			for _, idx := range rows {
+				buffer.PutOperation(commit.Delete, idx)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnTTL(t *testing.T) {
+	col := NewCollection(Options{
+		Vacuum: time.Hour,
+	})
+	defer col.Close()
+
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("ability", ForString())
+	col.CreateIndex("casting", "ability", func(r Reader) bool {
+		return r.String() != ""
+	})
+
+	assert.Error(t, col.SetColumnTTL("invalid", time.Minute))
+	assert.Error(t, col.SetColumnTTL("casting", time.Minute))
+	assert.NoError(t, col.SetColumnTTL("ability", time.Minute))
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		col.Insert(func(r Row) error {
+			r.SetString("name", name)
+			r.SetString("ability", "fireball")
+			return nil
+		})
+	}
+
+	// Nothing expires before the time-to-live
+	col.expireColumns(time.Now())
+	assert.Equal(t, 3, countWhere(col, func(txn *Txn) *Txn { return txn.With("casting") }))
+
+	// Once it elapses, only the values of the column are removed
+	col.QueryAt(0, func(r Row) error {
+		r.SetString("name", "alice")
+		return nil
+	})
+
+	col.expireColumns(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, 3, col.Count())
+	assert.Equal(t, 0, countWhere(col, func(txn *Txn) *Txn { return txn.With("casting") }))
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		_, ok := r.String("ability")
+		name, _ := r.String("name")
+		assert.False(t, ok)
+		assert.Equal(t, "alice", name)
+		return nil
+	}))
+
+	// A row inserted at the index of a deleted row does not inherit its deadline
+	col.QueryAt(1, func(r Row) error {
+		r.SetString("ability", "heal")
+		return nil
+	})
+	assert.True(t, col.DeleteAt(1))
+	col.Insert(func(r Row) error {
+		r.SetString("name", "dave")
+		return nil
+	})
+
+	col.ttls.lock.Lock()
+	assert.False(t, col.ttls.columns["ability"].due(1, time.Now().Add(time.Hour).UnixNano()))
+	col.ttls.lock.Unlock()
+
+	// Once removed, the values are kept
+	assert.NoError(t, col.SetColumnTTL("ability", 0))
+	col.QueryAt(2, func(r Row) error {
+		r.SetString("ability", "heal")
+		return nil
+	})
+	col.expireColumns(time.Now().Add(time.Hour))
+	assert.Equal(t, 1, countWhere(col, func(txn *Txn) *Txn { return txn.With("casting") }))
+}
+
+func TestColumnTTLRenewed(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+
+	col.CreateColumn("ability", ForString())
+	assert.NoError(t, col.SetColumnTTL("ability", time.Minute))
+	col.Insert(func(r Row) error {
+		r.SetString("ability", "fireball")
+		return nil
+	})
+
+	// A value written again after it expired, but before it is removed, is kept
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		txn.expiring = time.Now().UnixNano()
+		txn.bufferFor("ability").PutOperation(commit.Delete, 0)
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		ability, _ := r.String("ability")
+		assert.Equal(t, "fireball", ability)
+		return nil
+	}))
+}
@@ -0,0 +1,551 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Expression represents a comparison between two arithmetic expressions of numeric columns
+// and constants, such as "hp + mp > 150". Rather than calling a predicate for every row, an
+// expression is evaluated over vectors holding the values of a chunk of rows at a time.
+type Expression struct {
+	text    string    // The text of the expression
+	op      string    // The comparison operator
+	left    *exprNode // The left side of the comparison
+	right   *exprNode // The right side of the comparison
+	columns []string  // The columns referenced, in the order of their first use
+}
+
+// exprKind represents a kind of node of an arithmetic expression
+type exprKind uint8
+
+const (
+	exprConst exprKind = iota
+	exprColumn
+	exprNeg
+	exprAdd
+	exprSub
+	exprMul
+	exprDiv
+)
+
+// exprNode represents a node of an arithmetic expression
+type exprNode struct {
+	kind  exprKind     // The kind of the node
+	value float64      // The value of a constant
+	slot  int          // The position of a column among the columns referenced
+	args  [2]*exprNode // The operands of the operators
+}
+
+// Expr parses an expression which compares two arithmetic expressions with one of the >,
+// >=, <, <=, == or != operators. The arithmetic expressions are made of the names of numeric
+// columns, numbers, the +, -, * and / operators and parentheses, for example "hp + mp > 150"
+// or "balance / (age - 18) >= 10.5". The values are compared as float64.
+func Expr(text string) (*Expression, error) {
+	p := &exprParser{text: text}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+
+	out := &Expression{text: text}
+	p.expr = out
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.next()
+	if !isComparison(op) {
+		return nil, fmt.Errorf("column: unable to parse expression '%s', expected a comparison", text)
+	}
+
+	right, err := p.parseSum()
+	switch {
+	case err != nil:
+		return nil, err
+	case p.pos < len(p.tokens):
+		return nil, fmt.Errorf("column: unable to parse expression '%s', unexpected '%s'", text, p.tokens[p.pos])
+	}
+
+	out.op, out.left, out.right = op, left, right
+	return out, nil
+}
+
+// MustExpr parses an expression in the same way as Expr(), and panics if it is invalid.
+func MustExpr(text string) *Expression {
+	expr, err := Expr(text)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+// String returns the text of the expression.
+func (e *Expression) String() string {
+	return e.text
+}
+
+// Columns returns the names of the columns referenced by the expression.
+func (e *Expression) Columns() []string {
+	return e.columns
+}
+
+// WithExpr filters down the values to the rows for which the expression holds. The columns
+// of the expression must be numeric, and the rows without a value in any of them are left
+// out. The values of a chunk are loaded into vectors and the expression is evaluated over
+// these vectors at once, and the chunks whose zone maps show that the expression can not
+// hold for any of their rows are skipped.
+func (txn *Txn) WithExpr(expr *Expression) *Txn {
+	column := ""
+	if len(expr.columns) > 0 {
+		column = expr.columns[0]
+	}
+
+	txn.filter(filterExpr, column, expr)
+	return txn
+}
+
+// withExpr filters down the current selection to the rows for which the expression holds.
+func (txn *Txn) withExpr(expr *Expression) {
+	defer txn.trace("WithExpr", expr.text, false)()
+	columns := make([]*column, 0, len(expr.columns))
+	for _, name := range expr.columns {
+		c, ok := txn.columnAt(name)
+		if !ok || !c.IsNumeric() {
+			txn.index.Clear()
+			return
+		}
+		columns = append(columns, c)
+	}
+
+	eval := &exprEval{
+		values: make([][]float64, len(columns)),
+		zones:  make([]zone, len(columns)),
+	}
+	for i := range eval.values {
+		eval.values[i] = make([]float64, chunkSize)
+	}
+
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		if index.Count() == 0 {
+			return
+		}
+
+		// Skip the chunk if the bounds of its values show that the expression never holds
+		if txn.excludes(expr, columns, eval.zones, commit.ChunkAt(offset)) {
+			atomic.AddUint64(&txn.owner.queries.pruned, 1)
+			index.Clear()
+			return
+		}
+
+		// Load the values of every column, leaving out the rows without a value
+		size := len(index) << 6
+		for i, c := range columns {
+			dst := eval.values[i][:size]
+			if loader, ok := c.Column.(vectorLoader); ok {
+				loader.loadFloat64s(offset, index, dst)
+				continue
+			}
+
+			column := c.Column.(Numeric)
+			index.Filter(func(x uint32) bool {
+				v, ok := column.LoadFloat64(offset + x)
+				dst[x] = v
+				return ok
+			})
+		}
+
+		eval.used = 0
+		left, right := eval.eval(expr.left, size), eval.eval(expr.right, size)
+		compareVectors(expr.op, left, right, index)
+	})
+}
+
+// excludes checks whether the expression can not hold for any row of the chunk, given the
+// bounds of the values of its columns. This is called while the chunk is read-locked.
+func (txn *Txn) excludes(expr *Expression, columns []*column, zones []zone, chunk commit.Chunk) bool {
+	for i, c := range columns {
+		switch {
+		case c.zones == nil || !txn.hints.allows(c.name):
+			zones[i] = zone{min: math.Inf(-1), max: math.Inf(1), set: true}
+		default:
+			if zones[i] = c.zones.load(chunk); !zones[i].set {
+				return true // None of the rows has a value
+			}
+		}
+	}
+
+	llo, lhi := expr.left.bounds(zones)
+	rlo, rhi := expr.right.bounds(zones)
+	switch expr.op {
+	case ">":
+		return lhi <= rlo
+	case ">=":
+		return lhi < rlo
+	case "<":
+		return llo >= rhi
+	case "<=":
+		return llo > rhi
+	case "==":
+		return lhi < rlo || llo > rhi
+	default:
+		return false
+	}
+}
+
+// compareVectors removes from the index the rows for which the comparison does not hold
+func compareVectors(op string, a, b []float64, index bitmap.Bitmap) {
+	for i, word := range index {
+		if word == 0 {
+			continue
+		}
+
+		var mask uint64
+		x, y := a[i<<6:i<<6+64], b[i<<6:i<<6+64]
+		switch op {
+		case ">":
+			for j := range x {
+				mask |= bit(x[j] > y[j]) << j
+			}
+		case ">=":
+			for j := range x {
+				mask |= bit(x[j] >= y[j]) << j
+			}
+		case "<":
+			for j := range x {
+				mask |= bit(x[j] < y[j]) << j
+			}
+		case "<=":
+			for j := range x {
+				mask |= bit(x[j] <= y[j]) << j
+			}
+		case "==":
+			for j := range x {
+				mask |= bit(x[j] == y[j]) << j
+			}
+		case "!=":
+			for j := range x {
+				mask |= bit(x[j] != y[j]) << j
+			}
+		}
+		index[i] = word & mask
+	}
+}
+
+// --------------------------- Evaluation ----------------------------
+
+// exprEval represents the vectors used to evaluate an expression over a chunk
+type exprEval struct {
+	values  [][]float64 // The values of the columns, by their position
+	zones   []zone      // The bounds of the values of the columns in the chunk
+	scratch [][]float64 // The vectors holding the intermediate results
+	used    int         // The number of scratch vectors used for the current chunk
+}
+
+// alloc returns a scratch vector of the specified size
+func (e *exprEval) alloc(size int) []float64 {
+	if e.used == len(e.scratch) {
+		e.scratch = append(e.scratch, make([]float64, chunkSize))
+	}
+
+	e.used++
+	return e.scratch[e.used-1][:size]
+}
+
+// eval evaluates a node of the expression over the vectors of the chunk
+func (e *exprEval) eval(n *exprNode, size int) []float64 {
+	switch n.kind {
+	case exprConst:
+		out := e.alloc(size)
+		for i := range out {
+			out[i] = n.value
+		}
+		return out
+	case exprColumn:
+		return e.values[n.slot][:size]
+	case exprNeg:
+		a, out := e.eval(n.args[0], size), e.alloc(size)
+		for i := range out {
+			out[i] = -a[i]
+		}
+		return out
+	}
+
+	a, b := e.eval(n.args[0], size), e.eval(n.args[1], size)
+	out := e.alloc(size)
+	switch n.kind {
+	case exprAdd:
+		for i := range out {
+			out[i] = a[i] + b[i]
+		}
+	case exprSub:
+		for i := range out {
+			out[i] = a[i] - b[i]
+		}
+	case exprMul:
+		for i := range out {
+			out[i] = a[i] * b[i]
+		}
+	case exprDiv:
+		for i := range out {
+			out[i] = a[i] / b[i]
+		}
+	}
+	return out
+}
+
+// bounds computes the bounds of the values of a node, given the bounds of the columns
+func (n *exprNode) bounds(zones []zone) (lo, hi float64) {
+	switch n.kind {
+	case exprConst:
+		return n.value, n.value
+	case exprColumn:
+		return zones[n.slot].min, zones[n.slot].max
+	case exprNeg:
+		lo, hi = n.args[0].bounds(zones)
+		return -hi, -lo
+	}
+
+	alo, ahi := n.args[0].bounds(zones)
+	blo, bhi := n.args[1].bounds(zones)
+	switch n.kind {
+	case exprAdd:
+		lo, hi = alo+blo, ahi+bhi
+	case exprSub:
+		lo, hi = alo-bhi, ahi-blo
+	case exprMul:
+		lo, hi = boundsOf(alo*blo, alo*bhi, ahi*blo, ahi*bhi)
+	case exprDiv:
+		if blo <= 0 && bhi >= 0 {
+			return math.Inf(-1), math.Inf(1)
+		}
+		lo, hi = boundsOf(alo/blo, alo/bhi, ahi/blo, ahi/bhi)
+	}
+
+	// The bounds are unknown if they are not a number, such as infinity minus infinity
+	if math.IsNaN(lo) || math.IsNaN(hi) {
+		return math.Inf(-1), math.Inf(1)
+	}
+	return
+}
+
+// boundsOf returns the smallest and the largest of the values
+func boundsOf(values ...float64) (lo, hi float64) {
+	lo, hi = values[0], values[0]
+	for _, v := range values[1:] {
+		if math.IsNaN(v) {
+			return math.NaN(), math.NaN()
+		}
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return
+}
+
+// --------------------------- Parser ----------------------------
+
+// exprParser represents the state of the parser of an expression
+type exprParser struct {
+	text   string      // The text of the expression
+	tokens []string    // The tokens of the expression
+	pos    int         // The position of the next token
+	expr   *Expression // The expression parsed, for the columns it references
+}
+
+// tokenize splits the text of the expression into tokens
+func (p *exprParser) tokenize() error {
+	for i := 0; i < len(p.text); {
+		c := p.text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(p.text[i:], ">=") || strings.HasPrefix(p.text[i:], "<=") ||
+			strings.HasPrefix(p.text[i:], "==") || strings.HasPrefix(p.text[i:], "!="):
+			p.tokens = append(p.tokens, p.text[i:i+2])
+			i += 2
+		case strings.IndexByte("+-*/()<>", c) >= 0:
+			p.tokens = append(p.tokens, p.text[i:i+1])
+			i++
+		case isDigit(c) || c == '.':
+			j := i + 1
+			for j < len(p.text) && (isDigit(p.text[j]) || p.text[j] == '.' || p.text[j] == 'e' || p.text[j] == 'E' ||
+				(p.text[j] == '-' || p.text[j] == '+') && (p.text[j-1] == 'e' || p.text[j-1] == 'E')) {
+				j++
+			}
+			p.tokens = append(p.tokens, p.text[i:j])
+			i = j
+		case isLetter(c):
+			j := i + 1
+			for j < len(p.text) && (isLetter(p.text[j]) || isDigit(p.text[j]) || p.text[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, p.text[i:j])
+			i = j
+		default:
+			return fmt.Errorf("column: unable to parse expression '%s', unexpected '%c'", p.text, c)
+		}
+	}
+	return nil
+}
+
+// next returns the next token and advances, or an empty string at the end
+func (p *exprParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+// peek returns the next token without advancing, or an empty string at the end
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// parseSum parses a sequence of terms separated by + or -
+func (p *exprParser) parseSum() (*exprNode, error) {
+	left, err := p.parseTerm()
+	for err == nil && (p.peek() == "+" || p.peek() == "-") {
+		kind := exprAdd
+		if p.next() == "-" {
+			kind = exprSub
+		}
+
+		var right *exprNode
+		if right, err = p.parseTerm(); err == nil {
+			left = fold(&exprNode{kind: kind, args: [2]*exprNode{left, right}})
+		}
+	}
+	return left, err
+}
+
+// parseTerm parses a sequence of factors separated by * or /
+func (p *exprParser) parseTerm() (*exprNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && (p.peek() == "*" || p.peek() == "/") {
+		kind := exprMul
+		if p.next() == "/" {
+			kind = exprDiv
+		}
+
+		var right *exprNode
+		if right, err = p.parseUnary(); err == nil {
+			left = fold(&exprNode{kind: kind, args: [2]*exprNode{left, right}})
+		}
+	}
+	return left, err
+}
+
+// parseUnary parses a factor, which may be negated
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	if p.peek() != "-" {
+		return p.parsePrimary()
+	}
+
+	p.next()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return fold(&exprNode{kind: exprNeg, args: [2]*exprNode{operand}}), nil
+}
+
+// parsePrimary parses a number, a column or an expression between parentheses
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	token := p.next()
+	switch {
+	case token == "(":
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("column: unable to parse expression '%s', expected ')'", p.text)
+		}
+		return node, nil
+	case token != "" && (isDigit(token[0]) || token[0] == '.'):
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column: unable to parse expression '%s', invalid number '%s'", p.text, token)
+		}
+		return &exprNode{kind: exprConst, value: value}, nil
+	case token != "" && isLetter(token[0]):
+		return &exprNode{kind: exprColumn, slot: p.slotOf(token)}, nil
+	case token == "":
+		return nil, fmt.Errorf("column: unable to parse expression '%s', unexpected end", p.text)
+	default:
+		return nil, fmt.Errorf("column: unable to parse expression '%s', unexpected '%s'", p.text, token)
+	}
+}
+
+// slotOf returns the position of a column among the columns referenced by the expression
+func (p *exprParser) slotOf(columnName string) int {
+	for i, name := range p.expr.columns {
+		if name == columnName {
+			return i
+		}
+	}
+
+	p.expr.columns = append(p.expr.columns, columnName)
+	return len(p.expr.columns) - 1
+}
+
+// fold replaces an operator applied on constants with its result
+func fold(n *exprNode) *exprNode {
+	var v [2]float64
+	for i, arg := range n.args {
+		if arg != nil && arg.kind != exprConst {
+			return n
+		}
+		if arg != nil {
+			v[i] = arg.value
+		}
+	}
+
+	out := &exprNode{kind: exprConst}
+	switch n.kind {
+	case exprNeg:
+		out.value = -v[0]
+	case exprAdd:
+		out.value = v[0] + v[1]
+	case exprSub:
+		out.value = v[0] - v[1]
+	case exprMul:
+		out.value = v[0] * v[1]
+	case exprDiv:
+		out.value = v[0] / v[1]
+	}
+	return out
+}
+
+// isComparison checks whether the token is a comparison operator
+func isComparison(token string) bool {
+	switch token {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	default:
+		return false
+	}
+}
+
+// isDigit checks whether the character is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isLetter checks whether the character can start the name of a column
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
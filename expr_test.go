@@ -0,0 +1,102 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithExpr(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	for _, tc := range []struct {
+		expr    string
+		matches func(hp, mp, age float64) bool
+	}{
+		{"hp + mp > 150", func(hp, mp, age float64) bool { return hp+mp > 150 }},
+		{"hp*2 >= mp - -10", func(hp, mp, age float64) bool { return hp*2 >= mp+10 }},
+		{"(hp - mp) / age < 0.5", func(hp, mp, age float64) bool { return (hp-mp)/age < 0.5 }},
+		{"age <= 30", func(hp, mp, age float64) bool { return age <= 30 }},
+		{"-age == -30", func(hp, mp, age float64) bool { return age == 30 }},
+		{"mp != 2 * 50", func(hp, mp, age float64) bool { return mp != 100 }},
+	} {
+		expect := 0
+		players.Query(func(txn *Txn) error {
+			hp, mp, age := txn.Float64("hp"), txn.Float64("mp"), txn.Float64("age")
+			return txn.Range(func(idx uint32) {
+				vh, _ := hp.Get()
+				vm, _ := mp.Get()
+				va, _ := age.Get()
+				if tc.matches(vh, vm, va) {
+					expect++
+				}
+			})
+		})
+
+		assert.NotZero(t, expect, tc.expr)
+		assert.Equal(t, expect, countWhere(players, func(txn *Txn) *Txn {
+			return txn.WithExpr(MustExpr(tc.expr))
+		}), tc.expr)
+	}
+
+	// The columns must exist and be numeric
+	assert.Equal(t, 0, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithExpr(MustExpr("missing > 0"))
+	}))
+	assert.Equal(t, 0, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithExpr(MustExpr("race > 0"))
+	}))
+}
+
+func TestWithExprZones(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("hp", ForFloat64())
+	col.CreateColumn("mp", ForInt32())
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	// The first chunk has low values, the second one high values
+	for i := 0; i < 2*chunkSize; i++ {
+		col.InsertObject(Object{"hp": float64(i), "mp": int32(i % 100)})
+	}
+	col.InsertObject(Object{"name": "no values"})
+
+	expect := 0
+	for i := 0; i < 2*chunkSize; i++ {
+		if i+i%100 >= chunkSize+99 {
+			expect++
+		}
+	}
+
+	// Only the second chunk is scanned, as the others can not match
+	pruned := col.Stats().Pruned
+	assert.Equal(t, expect, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithExpr(MustExpr("hp + mp >= 16384 + 99"))
+	}))
+	assert.Equal(t, pruned+2, col.Stats().Pruned)
+	assert.Equal(t, 2*chunkSize, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithExpr(MustExpr("hp / 0 != -1"))
+	}))
+}
+
+func TestExprParse(t *testing.T) {
+	expr, err := Expr("hp + mp * 2.5e1 > hp / (1 - 3)")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hp", "mp"}, expr.Columns())
+	assert.Equal(t, "hp + mp * 2.5e1 > hp / (1 - 3)", expr.String())
+	assert.Equal(t, -2.0, expr.right.args[1].value)
+
+	for _, text := range []string{
+		"", "hp", "hp +", "hp > ", "hp > 1 2", "(hp > 1", "(hp + 1 > 2", "hp = 1",
+		"hp > 1.2.3", "hp > $", "hp > > 1", "hp > )",
+	} {
+		_, err := Expr(text)
+		assert.Error(t, err, text)
+	}
+
+	assert.Panics(t, func() { MustExpr("hp") })
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Flattening represents how the nested objects are flattened into columns when inserted
+type Flattening struct {
+	Separator string // The separator between the names of the nested fields, "." by default
+	MaxDepth  int    // The maximum number of nested levels which are flattened, unlimited by default
+}
+
+// flatten flattens the nested maps and structs of an object into dotted column names, for
+// example {"stats": {"hp": 10}} into {"stats.hp": 10}. The values of the map columns, as
+// well as the values of the Map type, are kept as they are.
+func (f *Flattening) flatten(object Object, isMap func(string) bool) Object {
+	out := make(Object, len(object))
+	for k, v := range object {
+		f.append(out, k, v, 0, isMap)
+	}
+	return out
+}
+
+// append appends a value to the flattened object, flattening it further if it is nested
+func (f *Flattening) append(dst Object, name string, value interface{}, depth int, isMap func(string) bool) {
+	if f.MaxDepth <= 0 || depth < f.MaxDepth {
+		if fields, ok := fieldsOf(value); ok && !isMap(name) {
+			separator := f.Separator
+			if separator == "" {
+				separator = "."
+			}
+
+			for k, v := range fields {
+				f.append(dst, name+separator+k, v, depth+1, isMap)
+			}
+			return
+		}
+	}
+
+	// A generic map stored in a map column is converted to the type the column expects
+	if v, ok := value.(map[string]interface{}); ok && isMap(name) {
+		value = Map(v)
+	}
+	dst[name] = value
+}
+
+// fieldsOf returns the fields of a nested map or struct, if the value is one
+func fieldsOf(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case nil, Map:
+		return nil, false
+	case map[string]interface{}:
+		return v, true
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+
+		fields := make(map[string]interface{}, rv.Len())
+		for it := rv.MapRange(); it.Next(); {
+			fields[it.Key().String()] = it.Value().Interface()
+		}
+		return fields, true
+
+	// Structs without exported fields, such as time.Time, are not flattened
+	case reflect.Struct:
+		typ := rv.Type()
+		fields := make(map[string]interface{}, typ.NumField())
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue // Not exported
+			}
+
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			fields[name] = rv.Field(i).Interface()
+		}
+		return fields, len(fields) > 0
+	default:
+		return nil, false
+	}
+}
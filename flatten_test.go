@@ -0,0 +1,79 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	col := NewCollection(Options{
+		Flatten: &Flattening{},
+	})
+	defer col.Close()
+
+	// The columns are inferred from the nested object
+	var player Object
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"name": "merlin",
+		"stats": {"hp": 100, "mp": 50, "buffs": {"haste": true}}
+	}`), &player))
+	assert.NoError(t, col.CreateColumnsOf(player))
+	assert.NoError(t, col.CreateColumn("attrs", ForMap()))
+	for _, name := range []string{"name", "stats.hp", "stats.mp", "stats.buffs.haste"} {
+		_, ok := col.cols.Load(name)
+		assert.True(t, ok, name)
+	}
+
+	// Both maps and structs are flattened, the maps of the map columns are kept as they are
+	type stats struct {
+		HP      float64 `json:"hp"`
+		MP      float64 `json:"mp"`
+		Ignored string  `json:"-"`
+		private int
+	}
+
+	idx := col.InsertObject(player)
+	other := col.InsertObject(Object{
+		"name":  "gandalf",
+		"stats": &stats{HP: 200, MP: 400},
+		"attrs": map[string]interface{}{"region": "eu"},
+	})
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		hp, _ := r.Float64("stats.hp")
+		assert.Equal(t, 100.0, hp)
+		assert.True(t, r.Bool("stats.buffs.haste"))
+		return nil
+	}))
+	assert.NoError(t, col.QueryAt(other, func(r Row) error {
+		mp, _ := r.Float64("stats.mp")
+		assert.Equal(t, 400.0, mp)
+		attrs, _ := r.Map("attrs")
+		assert.Equal(t, Map{"region": "eu"}, attrs)
+		return nil
+	}))
+}
+
+func TestFlattenDepth(t *testing.T) {
+	f := &Flattening{Separator: "_", MaxDepth: 1}
+	out := f.flatten(Object{
+		"a": map[string]interface{}{
+			"b": map[string]int{"c": 1},
+			"d": 2,
+		},
+		"e": Map{"f": 3},
+		"g": nil,
+	}, func(string) bool { return false })
+
+	assert.Equal(t, Object{
+		"a_b": map[string]int{"c": 1},
+		"a_d": 2,
+		"e":   Map{"f": 3},
+		"g":   nil,
+	}, out)
+}
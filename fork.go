@@ -0,0 +1,254 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Fork creates an isolated copy of the collection, with its options, schema, indexes and
+// data, as of the time of the call. Changes made to the fork are not visible in this
+// collection and vice versa, which is useful for what-if simulations or to set up tests
+// from a common fixture. The rows, the primary key and the columns looked up by a lookup
+// or dictionary index are copied right away, while the chunks of the other columns are
+// shared copy-on-write. A shared chunk is only copied into the fork once either collection
+// is about to write it, or once the fork reads it, so the chunks which are never accessed
+// are never duplicated.
+func (c *Collection) Fork() (*Collection, error) {
+	out, err := c.cloneSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	// Acquire the read locks on all of the chunks at once, so the copy is consistent
+	c.txlock.RLock()
+	defer c.txlock.RUnlock()
+	chunks := c.chunks()
+	for shard := 0; shard < chunks && shard < lockShards; shard++ {
+		c.slock.RLock(uint(shard))
+		defer c.slock.RUnlock(uint(shard))
+	}
+
+	// Copy the rows and the columns which can not be shared right away
+	origin := &forkOrigin{base: c, chunks: chunks, columns: c.shareable()}
+	if err := c.copyChunks(out, chunks, func(v *column) bool {
+		return origin.columns[v.name] == nil
+	}); err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	// Share the chunks of the other columns, until either collection needs them
+	if len(origin.columns) > 0 {
+		for shard := 0; shard < chunks && shard < lockShards; shard++ {
+			origin.pending[shard] = 1
+		}
+
+		out.origin.Store(origin)
+		c.forks.add(out)
+	}
+	return out, nil
+}
+
+// --------------------------- Shared Chunks ----------------------------
+
+// forkOrigin represents the collection a fork was created from, along with the columns
+// whose chunks are still shared with it.
+type forkOrigin struct {
+	lock    sync.Mutex             // The lock of the shared columns
+	base    *Collection            // The collection the fork was created from
+	columns map[string]*column     // The columns of the base whose chunks are shared, by name
+	chunks  int                    // The number of chunks at the time of the fork
+	pending [lockShards]uint32     // Whether the chunks of each shard are still shared (atomic)
+	fetches [lockShards]sync.Mutex // The locks which serialize the copies of each shard
+}
+
+// forkSet represents the forks which may still share chunks with a collection
+type forkSet struct {
+	lock  sync.Mutex
+	count int32         // The number of forks (atomic)
+	list  []*Collection // The forks of the collection
+}
+
+// add registers a fork of the collection
+func (s *forkSet) add(fork *Collection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.list = append(s.list, fork)
+	atomic.StoreInt32(&s.count, int32(len(s.list)))
+}
+
+// remove unregisters a fork of the collection, once it is closed
+func (s *forkSet) remove(fork *Collection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, v := range s.list {
+		if v == fork {
+			s.list = append(s.list[:i], s.list[i+1:]...)
+			break
+		}
+	}
+	atomic.StoreInt32(&s.count, int32(len(s.list)))
+}
+
+// all returns the forks of the collection
+func (s *forkSet) all() []*Collection {
+	if atomic.LoadInt32(&s.count) == 0 {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*Collection(nil), s.list...)
+}
+
+// originOf returns the origin of a fork, or nil if the collection shares no chunks
+func (c *Collection) originOf() *forkOrigin {
+	origin, _ := c.origin.Load().(*forkOrigin)
+	return origin
+}
+
+// shareable returns the columns whose chunks can be shared with a fork, which are the
+// ones whose values are only ever read chunk by chunk, while the chunk is locked. The
+// primary key, the internal columns and the columns indexed by a lookup, dictionary, bloom
+// or expression index are also read across the chunks, so they are copied into the fork
+// right away.
+func (c *Collection) shareable() map[string]*column {
+	out := make(map[string]*column, 8)
+	c.cols.Range(func(v *column) {
+		if c.isInternal(v.name) {
+			return
+		}
+
+		switch v.Column.(type) {
+		case *columnBool, *columnString, *columnEnum, *columnDuration, *columnInt128,
+			*float32Column, *float64Column, *intColumn, *int8Column, *int16Column, *int32Column,
+			*int64Column, *uintColumn, *uint8Column, *uint16Column, *uint32Column, *uint64Column:
+			out[v.name] = v
+		}
+	})
+
+	c.cols.Range(func(v *column) {
+		switch index := v.Column.(type) {
+		case *columnLookup:
+			delete(out, index.name)
+		case *columnDictionary:
+			delete(out, index.name)
+		case *columnBloom:
+			delete(out, index.name)
+		case *columnExpr:
+			for _, source := range index.sources {
+				delete(out, source.name)
+			}
+		}
+	})
+	return out
+}
+
+// fetching returns whether the chunks of the shard are still shared with the origin of the
+// fork and need to be copied before the shard is locked.
+func (c *Collection) fetching(shard uint) bool {
+	origin := c.originOf()
+	return origin != nil && atomic.LoadUint32(&origin.pending[shard%lockShards]) == 1
+}
+
+// fetch copies the chunks of the shard which are still shared with the origin of the fork
+// into the columns of the fork. The shard of the fork is locked for writing during the copy,
+// and the one of the origin for reading.
+func (c *Collection) fetch(shard uint) {
+	shard %= lockShards
+	if !c.fetching(shard) {
+		return
+	}
+
+	origin := c.originOf()
+	origin.fetches[shard].Lock()
+	defer origin.fetches[shard].Unlock()
+	if atomic.LoadUint32(&origin.pending[shard]) == 0 {
+		return
+	}
+
+	txn := c.txns.acquire(c)
+	defer c.txns.release(txn)
+	c.slock.mu[shard].Lock()
+
+	// Take a snapshot of the shared chunks of the origin, which may be a fork itself
+	origin.base.slock.RLock(shard)
+	origin.lock.Lock()
+	for chunk := commit.Chunk(shard); int(chunk) < origin.chunks; chunk += lockShards {
+		for _, v := range origin.columns {
+			buffer := c.txns.acquirePage(v.name)
+			v.lock.RLock()
+			if v.Snapshot(chunk, buffer) {
+				txn.updates = append(txn.updates, buffer)
+			}
+			v.lock.RUnlock()
+		}
+	}
+	origin.lock.Unlock()
+	origin.base.slock.RUnlock(shard)
+
+	// Apply the values while the shard is locked, the commit then unlocks it
+	txn.held.Set(uint32(shard))
+	txn.commit()
+	atomic.StoreUint32(&origin.pending[shard], 0)
+}
+
+// fetchAll copies all of the chunks still shared with the origin of the fork
+func (c *Collection) fetchAll() {
+	for shard := uint(0); shard < lockShards; shard++ {
+		c.fetch(shard)
+	}
+}
+
+// sharing returns whether the chunks of the shard are still shared with one of the forks
+// of the collection, and need to be copied into it before the shard is written.
+func (c *Collection) sharing(shard uint) bool {
+	for _, fork := range c.forks.all() {
+		if fork.fetching(shard) {
+			return true
+		}
+	}
+	return false
+}
+
+// detach copies the chunks of the shard into the forks which still share them, before the
+// shard is written. Like the other commits of the fork, the copy holds its transaction lock.
+func (c *Collection) detach(shard uint) {
+	for _, fork := range c.forks.all() {
+		if fork.fetching(shard) {
+			fork.txlock.RLock()
+			fork.fetch(shard)
+			fork.txlock.RUnlock()
+		}
+	}
+}
+
+// detachAll copies all of the chunks into the forks which still share them
+func (c *Collection) detachAll() {
+	for shard := uint(0); shard < lockShards; shard++ {
+		c.detach(shard)
+	}
+}
+
+// unfork stops sharing the chunks of a fork which is being closed with its origin, or
+// stops sharing the chunks of a collection which is being closed with its forks.
+func (c *Collection) unfork() {
+	c.detachAll()
+	if origin := c.originOf(); origin != nil {
+		origin.base.forks.remove(c)
+	}
+}
+
+// dropShared stops sharing a column with the origin of the fork, once it is dropped
+func (c *Collection) dropShared(columnName string) {
+	if origin := c.originOf(); origin != nil {
+		origin.lock.Lock()
+		delete(origin.columns, columnName)
+		origin.lock.Unlock()
+	}
+}
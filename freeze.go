@@ -0,0 +1,51 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync/atomic"
+)
+
+// FreezeOptions represents the options of a collection being frozen.
+type FreezeOptions struct {
+	Compact bool // Whether the unused capacity and dictionary entries are released (optional)
+}
+
+// Freeze makes the collection read-only, for reference data which is loaded once and then
+// queried concurrently. It waits for the pending transactions to complete, after which the
+// transactions no longer acquire the chunk locks while reading, since no writer can change
+// the chunks anymore. A transaction which attempts to modify a frozen collection is rolled
+// back and returns ErrReadOnly, and so do the creation of columns and indexes and the
+// restoration of a snapshot. The background expiration and spilling of the rows stop as
+// well. If the Compact option is set, the trailing chunks without rows are released and
+// the dictionaries of the enum columns are compacted before the collection is frozen.
+func (c *Collection) Freeze(opts ...FreezeOptions) {
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+
+	// The frozen collection reads its chunks without locking them, so copy the shared ones
+	c.fetchAll()
+	for _, o := range opts {
+		if o.Compact {
+			c.shrink()
+			c.compactEnums(func(string) bool { return true })
+			break
+		}
+	}
+
+	atomic.StoreInt32(&c.frozen, 1)
+}
+
+// Frozen returns whether the collection is frozen and rejects the writes.
+func (c *Collection) Frozen() bool {
+	return atomic.LoadInt32(&c.frozen) == 1
+}
+
+// writable returns an error if the collection is frozen
+func (c *Collection) writable() error {
+	if c.Frozen() {
+		return ErrReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeze(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	old := countWhere(players, isOld)
+	assert.False(t, players.Frozen())
+	players.Freeze()
+	assert.True(t, players.Frozen())
+
+	// The writes are rejected and rolled back
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		_, err := txn.InsertObject(Object{"name": "merlin"})
+		return err
+	}), ErrReadOnly)
+	assert.ErrorIs(t, players.QueryAt(0, func(r Row) error {
+		r.SetFloat64("age", 99)
+		return nil
+	}), ErrReadOnly)
+	players.DeleteAt(0)
+	assert.Equal(t, 500, players.Count())
+	assert.ErrorIs(t, players.CreateColumn("score", ForFloat64()), ErrReadOnly)
+	assert.ErrorIs(t, players.CreateIndex("young", "age", func(r Reader) bool {
+		return r.Float() < 30
+	}), ErrReadOnly)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, players.Snapshot(&buffer))
+	assert.ErrorIs(t, players.Restore(&buffer), ErrReadOnly)
+
+	// The reads are unaffected, even when concurrent
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 500, players.Count())
+			assert.Equal(t, old, countWhere(players, isOld))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFreezeCompact(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	players.Query(func(txn *Txn) error {
+		txn.WithString("race", func(v string) bool {
+			return v == "elf"
+		}).DeleteAll()
+		return nil
+	})
+
+	count := players.Count()
+	players.Freeze(FreezeOptions{Compact: true})
+	assert.True(t, players.Frozen())
+	assert.Equal(t, count, players.Count())
+
+	values, err := players.Dictionary("race")
+	assert.NoError(t, err)
+	assert.NotContains(t, values, "elf")
+}
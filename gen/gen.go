@@ -0,0 +1,192 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package gen provides a generator of randomized rows for collections, which is useful to
+// produce large data sets for tests and benchmarks. For the same seed and schema, the
+// generator always produces the same rows.
+package gen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/kelindar/column"
+)
+
+// Source represents a source of random values for a column. The sources produce int values
+// for ForInt() columns, float64 values for ForFloat64() columns, bool values for ForBool()
+// columns and string values for the string, enum and key columns.
+type Source func(r *rand.Rand) interface{}
+
+// Field represents a column of the schema and the source of its values.
+type Field struct {
+	Column string // The name of the column
+	Source Source // The source of the values of the column
+}
+
+// Generator represents a generator of rows for a schema.
+type Generator struct {
+	rng    *rand.Rand
+	schema []Field
+}
+
+// New creates a new generator for the schema, seeded with the specified seed. Since some
+// of the sources keep a state, a generator should be created with its own sources.
+func New(seed int64, schema ...Field) *Generator {
+	return &Generator{
+		rng:    rand.New(rand.NewSource(seed)),
+		schema: schema,
+	}
+}
+
+// Object generates a single row as an object.
+func (g *Generator) Object() column.Object {
+	obj := make(column.Object, len(g.schema))
+	for _, f := range g.schema {
+		obj[f.Column] = f.Source(g.rng)
+	}
+	return obj
+}
+
+// Fill inserts the specified number of generated rows into the collection. The columns of
+// the schema must already exist in the collection, and the rows are inserted in batches.
+func (g *Generator) Fill(collection *column.Collection, count int) error {
+	const batch = 1000
+	for count > 0 {
+		size := count
+		if size > batch {
+			size = batch
+		}
+
+		if err := collection.Query(func(txn *column.Txn) error {
+			for i := 0; i < size; i++ {
+				if _, err := txn.Insert(func(r column.Row) error {
+					for _, f := range g.schema {
+						r.SetAny(f.Column, f.Source(g.rng))
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		count -= size
+	}
+	return nil
+}
+
+// --------------------------- Sources ----------------------------
+
+// Const returns a source which always produces the same value.
+func Const(v interface{}) Source {
+	return func(*rand.Rand) interface{} {
+		return v
+	}
+}
+
+// Sequence returns a source of unique strings, made of the prefix followed by a number
+// which is incremented for every value. This is suitable for the key columns.
+func Sequence(prefix string) Source {
+	next := 0
+	return func(*rand.Rand) interface{} {
+		next++
+		return fmt.Sprintf("%s%d", prefix, next)
+	}
+}
+
+// Ints returns a source of int values, uniformly distributed in [min, max).
+func Ints(min, max int) Source {
+	return func(r *rand.Rand) interface{} {
+		return min + r.Intn(max-min)
+	}
+}
+
+// Floats returns a source of float64 values, uniformly distributed in [min, max).
+func Floats(min, max float64) Source {
+	return func(r *rand.Rand) interface{} {
+		return min + r.Float64()*(max-min)
+	}
+}
+
+// Normal returns a source of float64 values, normally distributed with the specified mean
+// and standard deviation.
+func Normal(mean, stddev float64) Source {
+	return func(r *rand.Rand) interface{} {
+		return mean + r.NormFloat64()*stddev
+	}
+}
+
+// Bools returns a source of bool values, which are true with the specified probability.
+func Bools(probability float64) Source {
+	return func(r *rand.Rand) interface{} {
+		return r.Float64() < probability
+	}
+}
+
+// OneOf returns a source of values uniformly chosen among the specified ones.
+func OneOf(values ...interface{}) Source {
+	return func(r *rand.Rand) interface{} {
+		return values[r.Intn(len(values))]
+	}
+}
+
+// Weighted returns a source of values chosen among the specified ones, proportionally to
+// their weights.
+func Weighted(values []interface{}, weights []float64) Source {
+	cumulative := make([]float64, len(weights))
+	total := 0.0
+	for i, w := range weights {
+		total += w
+		cumulative[i] = total
+	}
+
+	return func(r *rand.Rand) interface{} {
+		x := r.Float64() * total
+		for i, c := range cumulative {
+			if x < c {
+				return values[i]
+			}
+		}
+		return values[len(values)-1]
+	}
+}
+
+// Skewed returns a source of values chosen among the specified ones following a Zipf
+// distribution, where the first values are the most frequent. The skew must be greater
+// than 1, and the larger it is, the more frequent the first values are.
+func Skewed(skew float64, values ...interface{}) Source {
+	weights := make([]float64, len(values))
+	for i := range values {
+		weights[i] = 1 / math.Pow(float64(i+1), skew)
+	}
+	return Weighted(values, weights)
+}
+
+// Strings returns a source of random strings of the specified length, drawn from a set of
+// distinct strings of the specified cardinality. The set is generated on first use.
+func Strings(cardinality, length int) Source {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	var pool []string
+	return func(r *rand.Rand) interface{} {
+		if pool == nil {
+			pool = make([]string, 0, cardinality)
+			for seen := make(map[string]bool, cardinality); len(pool) < cardinality; {
+				b := make([]byte, length)
+				for i := range b {
+					b[i] = letters[r.Intn(len(letters))]
+				}
+
+				if s := string(b); !seen[s] {
+					seen[s] = true
+					pool = append(pool, s)
+				}
+			}
+		}
+		return pool[r.Intn(len(pool))]
+	}
+}
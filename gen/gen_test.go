@@ -0,0 +1,91 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package gen
+
+import (
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFill(t *testing.T) {
+	players := column.NewCollection()
+	players.CreateColumn("serial", column.ForKey())
+	players.CreateColumn("name", column.ForString())
+	players.CreateColumn("class", column.ForEnum())
+	players.CreateColumn("age", column.ForInt())
+	players.CreateColumn("balance", column.ForFloat64())
+	players.CreateColumn("active", column.ForBool())
+	defer players.Close()
+
+	generator := New(42, schema()...)
+	assert.NoError(t, generator.Fill(players, 2500))
+	assert.Equal(t, 2500, players.Count())
+
+	classes := map[string]int{}
+	names := map[string]bool{}
+	assert.NoError(t, players.Query(func(txn *column.Txn) error {
+		serial := txn.Key()
+		name := txn.String("name")
+		class := txn.Enum("class")
+		age := txn.Int("age")
+		return txn.Range(func(idx uint32) {
+			_, ok := serial.Get()
+			assert.True(t, ok)
+
+			v, _ := age.Get()
+			assert.True(t, v >= 18 && v < 65)
+
+			c, _ := class.Get()
+			classes[c]++
+
+			n, _ := name.Get()
+			names[n] = true
+		})
+	}))
+
+	// The cardinality and the skew of the values are respected
+	assert.Len(t, names, 100)
+	assert.Greater(t, classes["warrior"], classes["mage"])
+	assert.Greater(t, classes["mage"], classes["rogue"])
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b, c := New(1, schema()...), New(1, schema()...), New(2, schema()...)
+	for i := 0; i < 10; i++ {
+		x, y, z := a.Object(), b.Object(), c.Object()
+		assert.Equal(t, x, y)
+		assert.NotEqual(t, x, z)
+	}
+}
+
+func TestSources(t *testing.T) {
+	generator := New(1,
+		Field{Column: "const", Source: Const("x")},
+		Field{Column: "weighted", Source: Weighted([]interface{}{"a", "b"}, []float64{0, 1})},
+		Field{Column: "normal", Source: Normal(100, 0)},
+		Field{Column: "float", Source: Floats(1, 2)},
+		Field{Column: "one", Source: OneOf(7)},
+	)
+
+	obj := generator.Object()
+	assert.Equal(t, "x", obj["const"])
+	assert.Equal(t, "b", obj["weighted"])
+	assert.Equal(t, 100.0, obj["normal"])
+	assert.InDelta(t, 1.5, obj["float"], 0.5)
+	assert.Equal(t, 7, obj["one"])
+}
+
+// schema returns the schema of the players
+func schema() []Field {
+	return []Field{
+		{Column: "serial", Source: Sequence("player-")},
+		{Column: "name", Source: Strings(100, 8)},
+		{Column: "class", Source: Skewed(2, "warrior", "mage", "rogue")},
+		{Column: "age", Source: Ints(18, 65)},
+		{Column: "balance", Source: Normal(1000, 250)},
+		{Column: "active", Source: Bools(0.8)},
+	}
+}
@@ -1,13 +1,12 @@
 module github.com/kelindar/column
 
-go 1.17
+go 1.18
 
 require (
 	github.com/kelindar/bitmap v1.1.5
 	github.com/kelindar/intmap v1.1.0
 	github.com/kelindar/iostream v1.3.0
-	github.com/kelindar/smutex v1.0.0
-	github.com/klauspost/compress v1.13.6
+	github.com/klauspost/compress v1.17.5
 	github.com/stretchr/testify v1.7.0
 	github.com/zeebo/xxh3 v1.0.1
 )
@@ -0,0 +1,626 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package graphql provides an HTTP handler which generates a GraphQL schema from the columns
+// of collections and serves the queries against them, so that front-ends can read the rows
+// without a bespoke API for every collection. Every collection is a field of the query type
+// which accepts a filter on its columns, a limit and an offset, and returns the selected
+// columns of the matching rows. Fragments, directives and the introspection queries are not
+// supported, the schema being available through Schema() instead.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/kelindar/column"
+)
+
+// Options represents the options of the handler.
+type Options struct {
+	Limit int // The maximum number of rows returned for a collection, 1000 by default
+}
+
+// Handler represents an HTTP handler which serves GraphQL queries against collections.
+type Handler struct {
+	collections map[string]*column.Collection // The collections by the name of their field
+	opts        Options
+}
+
+// New creates a new handler for the collections, by their name. The names which are not
+// valid GraphQL names have their invalid characters replaced with underscores.
+func New(collections map[string]*column.Collection, opts Options) *Handler {
+	if opts.Limit <= 0 {
+		opts.Limit = 1000
+	}
+
+	h := &Handler{
+		collections: make(map[string]*column.Collection, len(collections)),
+		opts:        opts,
+	}
+	for name, collection := range collections {
+		h.collections[nameOf(name)] = collection
+	}
+	return h
+}
+
+// Request represents a GraphQL request, as it is posted to the handler
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response represents the response to a GraphQL request
+type Response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []Error         `json:"errors,omitempty"`
+}
+
+// Error represents an error of a GraphQL request
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// ServeHTTP serves a request, either posted as JSON or with the "query", "operationName"
+// and "variables" parameters of the URL. A GET request without a query returns the schema.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if req.Query = query.Get("query"); req.Query == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(h.Schema()))
+			return
+		}
+
+		req.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "graphql: invalid variables, "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "graphql: invalid request, "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "graphql: method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	out, err := json.Marshal(h.Execute(r.Context(), req))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// Execute executes a GraphQL request. If the request is invalid, the response contains no
+// data, otherwise the fields which have failed are null and their errors are reported.
+func (h *Handler) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return failed(err)
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return failed(err)
+	}
+
+	vars, err := variablesOf(op, req.Variables)
+	if err != nil {
+		return failed(err)
+	}
+
+	// Validate the whole query first, since no data is returned for an invalid query
+	types := make(map[string]*typeDef, len(op.fields))
+	for _, f := range op.fields {
+		if f.name == "__typename" {
+			continue
+		}
+
+		collection, ok := h.collections[f.name]
+		if !ok {
+			return failed(fmt.Errorf("graphql: cannot query field '%s' on type 'Query'", f.name))
+		}
+
+		types[f.name] = h.typeOf(f.name, collection)
+		if err := types[f.name].validate(f); err != nil {
+			return failed(err)
+		}
+	}
+
+	out := &Response{}
+	data := new(object)
+	for _, f := range op.fields {
+		if f.name == "__typename" {
+			data.add(f.alias, "Query")
+			continue
+		}
+
+		rows, err := h.resolve(ctx, types[f.name], f, vars)
+		if err != nil {
+			data.add(f.alias, nil)
+			out.Errors = append(out.Errors, Error{
+				Message: err.Error(),
+				Path:    []interface{}{f.alias},
+			})
+			continue
+		}
+		data.add(f.alias, rows)
+	}
+
+	out.Data, _ = json.Marshal(data)
+	return out
+}
+
+// failed returns the response to a request which could not be executed
+func failed(err error) *Response {
+	return &Response{
+		Data:   json.RawMessage("null"),
+		Errors: []Error{{Message: err.Error()}},
+	}
+}
+
+// variablesOf returns the values of the variables of an operation, or their defaults
+func variablesOf(op *operation, values map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(op.variables))
+	for _, v := range op.variables {
+		value, ok := values[v.name]
+		switch {
+		case ok && value != nil:
+			out[v.name] = value
+		case v.defaults != nil:
+			out[v.name] = v.defaults
+		case v.nonNull:
+			return nil, fmt.Errorf("graphql: variable '$%s' is required", v.name)
+		}
+	}
+	return out, nil
+}
+
+// --------------------------- Schema ----------------------------
+
+// Various scalar types of the schema
+const (
+	typeInt     = "Int"
+	typeFloat   = "Float"
+	typeBoolean = "Boolean"
+	typeString  = "String"
+	typeJSON    = "JSON"
+)
+
+// comparisons represents the suffixes of the filters which compare the numeric columns
+var comparisons = []string{"_gt", "_gte", "_lt", "_lte"}
+
+// typeDef represents the GraphQL type generated for a collection
+type typeDef struct {
+	name       string             // The name of the type
+	collection *column.Collection // The collection of the type
+	fields     []fieldDef         // The fields of the type, sorted by name
+}
+
+// fieldDef represents a field of a type, generated for a column
+type fieldDef struct {
+	name   string // The name of the field
+	column string // The name of the column
+	typ    string // The type of the column
+	kind   string // The scalar type of the field
+}
+
+// Schema returns the schema generated from the current columns of the collections, in the
+// GraphQL schema definition language.
+func (h *Handler) Schema() string {
+	names := make([]string, 0, len(h.collections))
+	for name := range h.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var query, types bytes.Buffer
+	query.WriteString("scalar JSON\n\ntype Query {\n")
+	for _, name := range names {
+		t := h.typeOf(name, h.collections[name])
+		fmt.Fprintf(&query, "  %s(where: %sFilter, limit: Int, offset: Int): [%s!]!\n", name, t.name, t.name)
+
+		fmt.Fprintf(&types, "\ntype %s {\n", t.name)
+		for _, f := range t.fields {
+			fmt.Fprintf(&types, "  %s: %s\n", f.name, f.kind)
+		}
+
+		fmt.Fprintf(&types, "}\n\ninput %sFilter {\n  or: [%sFilter!]\n", t.name, t.name)
+		for _, f := range t.fields {
+			for _, op := range f.filters() {
+				fmt.Fprintf(&types, "  %s%s: %s\n", f.name, op, f.kind)
+			}
+		}
+		types.WriteString("}\n")
+	}
+
+	query.WriteString("}\n")
+	query.Write(types.Bytes())
+	return query.String()
+}
+
+// typeOf generates the type of a collection from its current columns
+func (h *Handler) typeOf(name string, collection *column.Collection) *typeDef {
+	t := &typeDef{
+		name:       strings.ToUpper(name[:1]) + name[1:],
+		collection: collection,
+	}
+
+	for _, columnName := range collection.Columns() {
+		typ, ok := collection.ColumnType(columnName)
+		if !ok {
+			continue
+		}
+
+		t.fields = append(t.fields, fieldDef{
+			name:   nameOf(columnName),
+			column: columnName,
+			typ:    typ,
+			kind:   scalarOf(typ),
+		})
+	}
+
+	sort.Slice(t.fields, func(i, j int) bool {
+		return t.fields[i].name < t.fields[j].name
+	})
+	return t
+}
+
+// field returns the field with the specified name
+func (t *typeDef) field(name string) (fieldDef, bool) {
+	i := sort.Search(len(t.fields), func(i int) bool {
+		return t.fields[i].name >= name
+	})
+	if i < len(t.fields) && t.fields[i].name == name {
+		return t.fields[i], true
+	}
+	return fieldDef{}, false
+}
+
+// validate checks the arguments and the selected fields of a collection field
+func (t *typeDef) validate(f *selection) error {
+	for name := range f.args {
+		switch name {
+		case "where", "limit", "offset":
+		default:
+			return fmt.Errorf("graphql: unknown argument '%s' on field '%s'", name, f.name)
+		}
+	}
+
+	if len(f.fields) == 0 {
+		return fmt.Errorf("graphql: field '%s' of type '[%s!]!' must have a selection of subfields", f.name, t.name)
+	}
+
+	for _, sub := range f.fields {
+		if _, ok := t.field(sub.name); !ok && sub.name != "__typename" {
+			return fmt.Errorf("graphql: cannot query field '%s' on type '%s'", sub.name, t.name)
+		}
+		if len(sub.fields) > 0 || len(sub.args) > 0 {
+			return fmt.Errorf("graphql: field '%s' of type '%s' is a scalar", sub.name, t.name)
+		}
+	}
+	return nil
+}
+
+// filters returns the suffixes of the filters of the field
+func (f fieldDef) filters() []string {
+	switch f.kind {
+	case typeInt, typeFloat:
+		return append([]string{""}, comparisons...)
+	case typeBoolean:
+		return []string{""}
+	case typeString:
+		if f.typ == "string" || f.typ == "enum" || f.typ == "key" {
+			return []string{""}
+		}
+	}
+	return nil
+}
+
+// accepts returns whether a value of a filter is of the scalar type of the field
+func (f fieldDef) accepts(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64:
+		return f.kind == typeInt || f.kind == typeFloat
+	case bool:
+		return f.kind == typeBoolean
+	case string:
+		return f.kind == typeString
+	default:
+		return false
+	}
+}
+
+// scalarOf returns the scalar type of the values of a column type
+func scalarOf(typ string) string {
+	switch typ {
+	case "float32", "float64":
+		return typeFloat
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "duration":
+		return typeInt
+	case "bool":
+		return typeBoolean
+	case "string", "enum", "key", "ip", "int128":
+		return typeString
+	default:
+		return typeJSON
+	}
+}
+
+// nameOf replaces the characters which are not allowed in a GraphQL name with underscores
+func nameOf(name string) string {
+	out := []byte(name)
+	for i, c := range out {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			out[i] = '_'
+		}
+	}
+
+	if len(out) == 0 {
+		return "_"
+	}
+	return string(out)
+}
+
+// --------------------------- Execution ----------------------------
+
+// resolve reads the selected columns of the rows of a collection which match the filter
+func (h *Handler) resolve(ctx context.Context, t *typeDef, f *selection, vars map[string]interface{}) ([]*object, error) {
+	args, err := substitute(f.args, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := intArg(args, "limit", h.opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if limit > h.opts.Limit {
+		limit = h.opts.Limit
+	}
+
+	offset, err := intArg(args, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var where *column.Builder
+	if filter, ok := args["where"]; ok && filter != nil {
+		if where, err = t.filter(filter); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]*object, 0, 16)
+	if limit <= 0 {
+		return out, nil
+	}
+
+	return out, t.collection.QueryContext(ctx, func(txn *column.Txn) error {
+		if where != nil {
+			txn.Where(where)
+		}
+
+		skipped := 0
+		txn.Rows()(func(_ uint32, row column.Row) bool {
+			if skipped < offset {
+				skipped++
+				return true
+			}
+
+			value := new(object)
+			for _, sub := range f.fields {
+				if sub.name == "__typename" {
+					value.add(sub.alias, t.name)
+					continue
+				}
+
+				field, _ := t.field(sub.name)
+				v, _ := row.Any(field.column)
+				value.add(sub.alias, field.convert(v))
+			}
+
+			out = append(out, value)
+			return len(out) < limit
+		})
+		return nil
+	})
+}
+
+// filter compiles the value of the "where" argument into a query
+func (t *typeDef) filter(value interface{}) (*column.Builder, error) {
+	filter, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("graphql: filter of '%s' must be an object, got %T", t.name, value)
+	}
+
+	// The alternatives are combined first, since the conditions chained afterwards apply
+	// to their combination
+	query := column.Q()
+	if or, ok := filter["or"]; ok && or != nil {
+		list, ok := or.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("graphql: filter 'or' of '%s' must be a list", t.name)
+		}
+
+		others := make([]*column.Builder, 0, len(list))
+		for _, v := range list {
+			other, err := t.filter(v)
+			if err != nil {
+				return nil, err
+			}
+			others = append(others, other)
+		}
+		query.Or(others...)
+	}
+
+	names := make([]string, 0, len(filter))
+	for name := range filter {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := filter[name]
+		if name == "or" || value == nil {
+			continue
+		}
+
+		field, op, ok := t.condition(name)
+		if !ok {
+			return nil, fmt.Errorf("graphql: unknown filter '%s' on type '%s'", name, t.name)
+		}
+
+		if !field.accepts(value) {
+			return nil, fmt.Errorf("graphql: filter '%s' expects a value of type '%s', got %T", name, field.kind, value)
+		}
+
+		switch op {
+		case "":
+			query.Eq(field.column, value)
+		case "_gt":
+			query.Gt(field.column, value)
+		case "_lt":
+			query.Lt(field.column, value)
+		case "_gte":
+			query.Between(field.column, value, math.Inf(1))
+		case "_lte":
+			query.Between(field.column, math.Inf(-1), value)
+		}
+	}
+	return query, nil
+}
+
+// condition returns the field and the comparison of a filter
+func (t *typeDef) condition(name string) (fieldDef, string, bool) {
+	for _, op := range append([]string{""}, comparisons...) {
+		if field, ok := t.field(strings.TrimSuffix(name, op)); ok && strings.HasSuffix(name, op) {
+			for _, supported := range field.filters() {
+				if supported == op {
+					return field, op, true
+				}
+			}
+		}
+	}
+	return fieldDef{}, "", false
+}
+
+// convert converts a value read from the column into the value of the field
+func (f fieldDef) convert(v interface{}) interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case f.kind == typeString:
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	default:
+		return v
+	}
+}
+
+// substitute replaces the references to the variables in the arguments with their values
+func substitute(args map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		v, err := substituteValue(value, vars)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = v
+	}
+	return out, nil
+}
+
+// substituteValue replaces the references to the variables in a value with their values
+func substituteValue(value interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case reference:
+		return vars[string(v)], nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			item, err := substituteValue(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, item)
+		}
+		return out, nil
+	case map[string]interface{}:
+		return substitute(v, vars)
+	default:
+		return v, nil
+	}
+}
+
+// intArg returns the value of an integer argument, or its default value
+func intArg(args map[string]interface{}, name string, defaults int) (int, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return defaults, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("graphql: argument '%s' must be an integer", name)
+}
+
+// object represents an object of the response, whose fields are kept in the order in which
+// they were selected
+type object struct {
+	keys   []string
+	values []interface{}
+}
+
+// add adds a field to the object
+func (o *object) add(key string, value interface{}) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+// MarshalJSON encodes the object as JSON, with its fields in order
+func (o *object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		out.Write(k)
+		out.WriteByte(':')
+		out.Write(v)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	players := newPlayers(10)
+	defer players.Close()
+
+	schema := New(map[string]*column.Collection{"players": players}, Options{}).Schema()
+	assert.Contains(t, schema, "players(where: PlayersFilter, limit: Int, offset: Int): [Players!]!")
+	assert.Contains(t, schema, "type Players {\n  active: Boolean\n  level: Int\n  name: String\n  race: String\n  score: Float\n}")
+	assert.Contains(t, schema, "  or: [PlayersFilter!]\n")
+	assert.Contains(t, schema, "  level_gte: Int\n")
+	assert.Contains(t, schema, "  race: String\n")
+	assert.NotContains(t, schema, "race_gt")
+}
+
+func TestQuery(t *testing.T) {
+	players := newPlayers(10)
+	defer players.Close()
+
+	h := New(map[string]*column.Collection{"players": players}, Options{})
+	tests := []struct {
+		query  string
+		expect string
+	}{
+		{
+			query:  `{ players(limit: 2) { name level } }`,
+			expect: `{"players":[{"name":"player-0","level":0},{"name":"player-1","level":1}]}`,
+		},
+		{
+			query:  `{ players(where: {level_gte: 3, level_lt: 5}) { n: name, __typename } }`,
+			expect: `{"players":[{"n":"player-3","__typename":"Players"},{"n":"player-4","__typename":"Players"}]}`,
+		},
+		{
+			query:  `{ players(where: {race: "elf", active: true}, offset: 1, limit: 1) { name } }`,
+			expect: `{"players":[{"name":"player-9"}]}`,
+		},
+		{
+			query:  `{ players(where: {or: [{name: "player-1"}, {level_gt: 8}]}) { name } }`,
+			expect: `{"players":[{"name":"player-1"},{"name":"player-9"}]}`,
+		},
+		{
+			query:  `query Q { __typename, all: players(where: {score_lte: 1.5}) { score } }`,
+			expect: `{"__typename":"Query","all":[{"score":0},{"score":1.5}]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		out := h.Execute(context.Background(), Request{Query: tc.query})
+		assert.Empty(t, out.Errors, tc.query)
+		assert.JSONEq(t, tc.expect, string(out.Data), tc.query)
+	}
+}
+
+func TestQueryVariables(t *testing.T) {
+	players := newPlayers(10)
+	defer players.Close()
+
+	h := New(map[string]*column.Collection{"players": players}, Options{})
+	query := `query Page($min: Int!, $limit: Int = 2) { players(where: {level_gte: $min}, limit: $limit) { level } }`
+
+	out := h.Execute(context.Background(), Request{
+		Query:     query,
+		Variables: map[string]interface{}{"min": float64(7)},
+	})
+	assert.Empty(t, out.Errors)
+	assert.JSONEq(t, `{"players":[{"level":7},{"level":8}]}`, string(out.Data))
+
+	out = h.Execute(context.Background(), Request{Query: query})
+	assert.Equal(t, "null", string(out.Data))
+	assert.Equal(t, "graphql: variable '$min' is required", out.Errors[0].Message)
+}
+
+func TestQueryInvalid(t *testing.T) {
+	players := newPlayers(10)
+	defer players.Close()
+
+	h := New(map[string]*column.Collection{"players": players}, Options{Limit: 3})
+	for _, query := range []string{
+		`{ players { name `,
+		`{ missing { name } }`,
+		`{ players }`,
+		`{ players { missing } }`,
+		`{ players(sort: "name") { name } }`,
+		`{ players { ...fields } }`,
+		`mutation { players { name } }`,
+	} {
+		out := h.Execute(context.Background(), Request{Query: query})
+		assert.Equal(t, "null", string(out.Data), query)
+		assert.Len(t, out.Errors, 1, query)
+	}
+
+	// The fields with an invalid filter are null, while the others are returned
+	out := h.Execute(context.Background(), Request{
+		Query: `{ a: players(where: {level: "high"}) { name }, b: players { name } }`,
+	})
+	assert.Len(t, out.Errors, 1)
+	assert.Equal(t, []interface{}{"a"}, out.Errors[0].Path)
+	assert.JSONEq(t, `{"a":null,"b":[{"name":"player-0"},{"name":"player-1"},{"name":"player-2"}]}`, string(out.Data))
+}
+
+func TestHandler(t *testing.T) {
+	players := newPlayers(10)
+	defer players.Close()
+
+	server := httptest.NewServer(New(map[string]*column.Collection{"players": players}, Options{}))
+	defer server.Close()
+
+	// Post a query as JSON
+	body := `{"query": "query($name: String) { players(where: {name: $name}) { level } }", "variables": {"name": "player-5"}}`
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"players":[{"level":5}]}}`, readAll(t, resp))
+
+	// Send a query in the URL
+	resp, err = http.Get(server.URL + "?query=" + url.QueryEscape(`{ players(limit: 1) { name } }`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"players":[{"name":"player-0"}]}}`, readAll(t, resp))
+
+	// Without a query, the schema is returned
+	resp, err = http.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, readAll(t, resp), "type Query {")
+}
+
+// newPlayers creates a collection of players
+func newPlayers(count int) *column.Collection {
+	players := column.NewCollection()
+	players.CreateColumn("name", column.ForString())
+	players.CreateColumn("race", column.ForEnum())
+	players.CreateColumn("level", column.ForInt())
+	players.CreateColumn("score", column.ForFloat64())
+	players.CreateColumn("active", column.ForBool())
+	players.CreateIndex("elf", "race", func(r column.Reader) bool {
+		return r.String() == "elf"
+	})
+
+	for i := 0; i < count; i++ {
+		players.InsertObject(map[string]interface{}{
+			"name":   fmt.Sprintf("player-%d", i),
+			"race":   []string{"human", "elf"}[i%2],
+			"level":  i,
+			"score":  float64(i) * 1.5,
+			"active": i%3 == 0,
+		})
+	}
+	return players
+}
+
+// readAll reads the body of a response
+func readAll(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,381 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// document represents a parsed GraphQL document
+type document struct {
+	operations []*operation
+}
+
+// operation represents a query of a document
+type operation struct {
+	kind      string       // The kind of operation, such as "query"
+	name      string       // The name of the operation (optional)
+	variables []variable   // The declared variables
+	fields    []*selection // The selected fields
+}
+
+// variable represents the declaration of a variable
+type variable struct {
+	name     string      // The name of the variable, without the '$'
+	nonNull  bool        // Whether the variable is required
+	defaults interface{} // The default value of the variable (optional)
+}
+
+// selection represents a field selected by a query
+type selection struct {
+	alias  string                 // The name of the field in the response
+	name   string                 // The name of the field
+	args   map[string]interface{} // The arguments of the field
+	fields []*selection           // The selected sub-fields
+}
+
+// reference represents a reference to a variable in the value of an argument
+type reference string
+
+// operation returns the operation to execute, by its name if the document has several
+func (d *document) operation(name string) (*operation, error) {
+	switch {
+	case name == "" && len(d.operations) == 1:
+		return d.operations[0], nil
+	case name == "":
+		return nil, fmt.Errorf("graphql: operation name is required, document has %d operations", len(d.operations))
+	}
+
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("graphql: unknown operation '%s'", name)
+}
+
+// --------------------------- Parser ----------------------------
+
+// parser represents a recursive descent parser of the executable GraphQL documents
+type parser struct {
+	src string
+	pos int
+}
+
+// parse parses a document which contains one or several queries
+func parse(query string) (*document, error) {
+	p := &parser{src: query}
+	doc := new(document)
+	for p.skip(); p.pos < len(p.src); p.skip() {
+		op, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		doc.operations = append(doc.operations, op)
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("graphql: document does not contain any operation")
+	}
+	return doc, nil
+}
+
+// operation parses an operation, either in its shorthand or its full form
+func (p *parser) operation() (op *operation, err error) {
+	op = &operation{kind: "query"}
+	if !p.peek('{') {
+		if op.kind, err = p.name(); err != nil {
+			return nil, err
+		}
+
+		switch op.kind {
+		case "query":
+		case "fragment":
+			return nil, fmt.Errorf("graphql: fragments are not supported")
+		default:
+			return nil, fmt.Errorf("graphql: %s operations are not supported", op.kind)
+		}
+
+		if p.skip(); !p.peek('(') && !p.peek('{') {
+			if op.name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+
+		if op.variables, err = p.variables(); err != nil {
+			return nil, err
+		}
+	}
+
+	op.fields, err = p.selections()
+	return op, err
+}
+
+// variables parses the optional declarations of the variables of an operation
+func (p *parser) variables() (out []variable, err error) {
+	if !p.accept('(') {
+		return nil, nil
+	}
+
+	for !p.accept(')') {
+		if err := p.expect('$'); err != nil {
+			return nil, err
+		}
+
+		var v variable
+		if v.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		if v.nonNull, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+
+		if p.accept('=') {
+			if v.defaults, err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// typeRef parses the type of a variable and returns whether it is non-null
+func (p *parser) typeRef() (bool, error) {
+	if p.accept('[') {
+		if _, err := p.typeRef(); err != nil {
+			return false, err
+		}
+		if err := p.expect(']'); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+
+	return p.accept('!'), nil
+}
+
+// selections parses a set of selected fields, enclosed in braces
+func (p *parser) selections() (out []*selection, err error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	for !p.accept('}') {
+		if p.skip(); strings.HasPrefix(p.src[p.pos:], "...") {
+			return nil, fmt.Errorf("graphql: fragments are not supported")
+		}
+
+		field := new(selection)
+		if field.name, err = p.name(); err != nil {
+			return nil, err
+		}
+
+		field.alias = field.name
+		if p.accept(':') {
+			if field.name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.accept('(') {
+			field.args = make(map[string]interface{}, 2)
+			for !p.accept(')') {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(':'); err != nil {
+					return nil, err
+				}
+				if field.args[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if p.peek('@') {
+			return nil, fmt.Errorf("graphql: directives are not supported")
+		}
+
+		if p.peek('{') {
+			if field.fields, err = p.selections(); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, field)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("graphql: selection set is empty")
+	}
+	return out, nil
+}
+
+// value parses the value of an argument
+func (p *parser) value() (interface{}, error) {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return nil, p.unexpected()
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '$':
+		p.pos++
+		name, err := p.name()
+		return reference(name), err
+	case c == '"':
+		return p.string()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case c == '[':
+		p.pos++
+		out := make([]interface{}, 0, 4)
+		for !p.accept(']') {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case c == '{':
+		p.pos++
+		out := make(map[string]interface{}, 4)
+		for !p.accept('}') {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			if out[name], err = p.value(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+
+	// Otherwise, this is a keyword or the value of an enum
+	name, err := p.name()
+	switch {
+	case err != nil:
+		return nil, err
+	case name == "true":
+		return true, nil
+	case name == "false":
+		return false, nil
+	case name == "null":
+		return nil, nil
+	default:
+		return name, nil
+	}
+}
+
+// string parses a quoted string, whose escape sequences are the same as in JSON
+func (p *parser) string() (interface{}, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+			var out string
+			if err := json.Unmarshal([]byte(p.src[start:p.pos]), &out); err != nil {
+				return nil, fmt.Errorf("graphql: invalid string at %d", start)
+			}
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("graphql: unterminated string at %d", start)
+}
+
+// number parses an integer or a floating-point number
+func (p *parser) number() (interface{}, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0; p.pos++ {
+	}
+
+	text := p.src[start:p.pos]
+	if v, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return v, nil
+	}
+	if v, err := strconv.ParseFloat(text, 64); err == nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("graphql: invalid number '%s' at %d", text, start)
+}
+
+// name parses a name
+func (p *parser) name() (string, error) {
+	p.skip()
+	start := p.pos
+	for ; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (p.pos == start || c < '0' || c > '9') {
+			break
+		}
+	}
+
+	if p.pos == start {
+		return "", p.unexpected()
+	}
+	return p.src[start:p.pos], nil
+}
+
+// accept consumes the punctuator if it is next, and returns whether it was
+func (p *parser) accept(c byte) bool {
+	if p.peek(c) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the punctuator, or returns an error if it is not next
+func (p *parser) expect(c byte) error {
+	if !p.accept(c) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+// peek returns whether the punctuator is next, without consuming it
+func (p *parser) peek(c byte) bool {
+	p.skip()
+	return p.pos < len(p.src) && p.src[p.pos] == c
+}
+
+// skip skips the white space, the commas and the comments
+func (p *parser) skip() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r', ',':
+			p.pos++
+		case '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// unexpected returns an error for the unexpected character at the current position
+func (p *parser) unexpected() error {
+	if p.pos >= len(p.src) {
+		return fmt.Errorf("graphql: unexpected end of document")
+	}
+	return fmt.Errorf("graphql: unexpected '%c' at %d", p.src[p.pos], p.pos)
+}
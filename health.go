@@ -0,0 +1,162 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Health represents a report of the fragmentation of a collection, which tells whether it is
+// worth running Vacuum() or Shrink() on it.
+type Health struct {
+	Rows          int                         // The number of rows in the collection
+	Chunks        []ChunkHealth               // The fill of every allocated chunk
+	Fragmentation float64                     // The ratio of the slots left free by deleted rows, below the last row
+	Trailing      int                         // The number of trailing chunks without rows, released by Shrink()
+	Dictionaries  map[string]DictionaryHealth // The dictionaries of the enum columns
+	Indexes       map[string]IndexHealth      // The bitmap indexes
+}
+
+// ChunkHealth represents the fill of a chunk of the collection
+type ChunkHealth struct {
+	Rows int     // The number of rows in the chunk
+	Fill float64 // The ratio of the rows to the capacity of the chunk
+}
+
+// DictionaryHealth represents the dictionary of an enum column. The values which are no
+// longer used by any row are only removed by Vacuum() or CompactDictionary().
+type DictionaryHealth struct {
+	Values int     // The number of values in the dictionary
+	Unused int     // The number of values which are no longer used by any row
+	Bloat  float64 // The ratio of the unused values to all of the values
+}
+
+// IndexHealth represents a bitmap index. An index is stale when its bitmap differs from its
+// rule, for example when it was restored from a snapshot after its rule has changed or while
+// it is still being built, and can be fixed by creating it again.
+type IndexHealth struct {
+	Rows      int     // The number of rows in the index
+	Stale     int     // The number of rows whose bit differs from the rule of the index
+	Staleness float64 // The ratio of the stale rows to the rows with a value in the column
+}
+
+// Health reports the fill of the chunks, the fragmentation left by the deleted rows, the
+// unused values of the enum dictionaries and the staleness of the bitmap indexes. Since the
+// rules of the indexes are evaluated again on every row, this scans the collection chunk by
+// chunk, and the dictionaries are inspected while all of the chunks are read-locked.
+func (c *Collection) Health() Health {
+	c.lock.RLock()
+	count := int(c.fill.Count())
+	allocated := len(c.commits)
+	health := Health{
+		Rows:         count,
+		Chunks:       make([]ChunkHealth, allocated),
+		Dictionaries: make(map[string]DictionaryHealth, 4),
+		Indexes:      make(map[string]IndexHealth, 4),
+	}
+
+	// Compute the fill of the chunks, and the free slots below the last row
+	used := 0
+	for i := range health.Chunks {
+		rows := int(commit.Chunk(i).OfBitmap(c.fill).Count())
+		health.Chunks[i] = ChunkHealth{
+			Rows: rows,
+			Fill: float64(rows) / chunkSize,
+		}
+
+		if rows > 0 {
+			used = i + 1
+		}
+	}
+
+	if max, ok := c.fill.Max(); ok {
+		health.Fragmentation = float64(int(max)+1-count) / float64(max+1)
+	}
+	health.Trailing = allocated - used
+	c.lock.RUnlock()
+
+	// Inspect the dictionaries and the indexes
+	c.cols.Range(func(v *column) {
+		switch column := v.Column.(type) {
+		case *columnEnum:
+			health.Dictionaries[v.name] = c.dictionaryHealth(v, column)
+		case *columnIndex:
+			if target, ok := c.cols.Load(column.name); ok {
+				health.Indexes[v.name] = c.indexHealth(column, target)
+			}
+		}
+	})
+	return health
+}
+
+// dictionaryHealth counts the values of the dictionary of an enum column which are used
+func (c *Collection) dictionaryHealth(v *column, enum *columnEnum) DictionaryHealth {
+	for shard := 0; shard < 128; shard++ {
+		c.slock.RLock(uint(shard))
+		defer c.slock.RUnlock(uint(shard))
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	var used bitmap.Bitmap
+	enum.fill.Range(func(idx uint32) {
+		if at := enum.locs.get(idx); at != overflowAt {
+			used.Set(at)
+		}
+	})
+
+	// The seeded values are always retained, so they are never unused
+	for _, v := range enum.seed {
+		if at, ok := enum.codeOf(v); ok {
+			used.Set(at)
+		}
+	}
+
+	values := len(enum.data) - int(enum.holes.Count())
+	out := DictionaryHealth{
+		Values: values,
+		Unused: values - int(used.Count()),
+	}
+	if out.Values > 0 {
+		out.Bloat = float64(out.Unused) / float64(out.Values)
+	}
+	return out
+}
+
+// indexHealth evaluates the rule of a bitmap index on every row of its column, chunk by
+// chunk, and counts the rows whose bit differs from it.
+func (c *Collection) indexHealth(index *columnIndex, target *column) IndexHealth {
+	buffer := c.txns.acquirePage(target.name)
+	defer c.txns.releasePage(buffer)
+
+	var out IndexHealth
+	values := 0
+	reader := commit.NewReader()
+	for chunk := commit.Chunk(0); int(chunk) < c.chunks(); chunk++ {
+		c.slock.RLock(uint(chunk))
+		if target.Snapshot(chunk, buffer) {
+			for reader.Seek(buffer); reader.Next(); values++ {
+				idx := reader.Index()
+				expect := index.inScope(idx) && index.rule(reader)
+				if expect != index.fill.Contains(idx) {
+					out.Stale++
+				}
+			}
+		}
+
+		// The rows without a value in the column should not be in the index either
+		chunk.Range(index.fill, func(idx uint32) {
+			if out.Rows++; !target.Contains(idx) {
+				out.Stale++
+			}
+		})
+		c.slock.RUnlock(uint(chunk))
+	}
+
+	if values > 0 {
+		out.Staleness = float64(out.Stale) / float64(values)
+	}
+	return out
+}
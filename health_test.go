@@ -0,0 +1,58 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("class", ForEnum())
+	col.CreateColumn("age", ForInt())
+	col.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 30
+	})
+	defer col.Close()
+
+	for i := 0; i < 100; i++ {
+		col.Insert(func(r Row) error {
+			r.SetEnum("class", []string{"mage", "rogue", "warrior", "druid"}[i%4])
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	health := col.Health()
+	assert.Equal(t, 100, health.Rows)
+	assert.Equal(t, 100, health.Chunks[0].Rows)
+	assert.Equal(t, 0.0, health.Fragmentation)
+	assert.Equal(t, DictionaryHealth{Values: 4}, health.Dictionaries["class"])
+	assert.Equal(t, IndexHealth{Rows: 70}, health.Indexes["old"])
+
+	// Deleting the druids leaves holes and an unused value in the dictionary
+	col.Query(func(txn *Txn) error {
+		return txn.WithValue("class", func(v interface{}) bool {
+			return v == "druid"
+		}).Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	})
+
+	health = col.Health()
+	assert.Equal(t, 75, health.Rows)
+	assert.InDelta(t, 24.0/99, health.Fragmentation, 0.001)
+	assert.Equal(t, DictionaryHealth{Values: 4, Unused: 1, Bloat: 0.25}, health.Dictionaries["class"])
+
+	// Vacuum removes the unused values of the dictionary
+	col.Vacuum()
+	assert.Equal(t, DictionaryHealth{Values: 3}, col.Health().Dictionaries["class"])
+
+	// An index whose bitmap differs from its rule is stale
+	index, _ := col.cols.Load("old")
+	index.Column.(*columnIndex).fill.Set(1)
+	assert.Equal(t, 1, col.Health().Indexes["old"].Stale)
+}
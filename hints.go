@@ -0,0 +1,105 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+// hintKind represents a kind of hint which can be given to the query planner
+type hintKind uint8
+
+// Various kinds of hints
+const (
+	hintNoIndex hintKind = iota
+	hintParallel
+	hintInOrder
+)
+
+// Hint represents a hint which overrides a choice the query planner would otherwise make
+// for a transaction, when that choice turns out to be wrong for a specific query.
+type Hint struct {
+	kind  hintKind // The kind of the hint
+	name  string   // The name of the column or the index, if any
+	value int      // The number of workers, if any
+}
+
+// NoIndex creates a hint which prevents the filters from using an index they would pick
+// on their own, such as a lookup, a bloom filter or an index created by the advisor. If
+// the name is the one of a column, none of its indexes nor its zone maps are used, and
+// its values are always scanned. The indexes named explicitly by With() and Without()
+// are used regardless.
+func NoIndex(name string) Hint {
+	return Hint{
+		kind: hintNoIndex,
+		name: name,
+	}
+}
+
+// Parallel creates a hint which specifies the number of workers used by the parallel
+// operations of the transaction, such as Aggregate(), CountParallel() and RangeParallel(),
+// when these are not given a positive number of workers themselves.
+func Parallel(workers int) Hint {
+	return Hint{
+		kind:  hintParallel,
+		value: workers,
+	}
+}
+
+// InOrder creates a hint which applies the filters in the order they were chained in,
+// rather than in the order of their estimated cost.
+func InOrder() Hint {
+	return Hint{
+		kind: hintInOrder,
+	}
+}
+
+// hints represents the hints given to a transaction
+type hints struct {
+	noIndex []string // The columns and the indexes which must not be used implicitly
+	workers int      // The number of workers of the parallel operations
+	inOrder bool     // Whether the filters are applied in their chained order
+}
+
+// Hint overrides the choices of the query planner for the transaction. Since
+// the filters are only applied once the selection is needed, the hints also apply to the
+// filters chained before them.
+func (txn *Txn) Hint(hints ...Hint) *Txn {
+	for _, h := range hints {
+		switch h.kind {
+		case hintNoIndex:
+			txn.hints.noIndex = append(txn.hints.noIndex, h.name)
+		case hintParallel:
+			txn.hints.workers = h.value
+		case hintInOrder:
+			txn.hints.inOrder = true
+		}
+	}
+	return txn
+}
+
+// allows checks whether the hints allow a column or one of its indexes to be used
+func (h *hints) allows(name string) bool {
+	for _, v := range h.noIndex {
+		if v == name {
+			return false
+		}
+	}
+	return true
+}
+
+// indexesOf returns a column along with the indexes on it which the hints allow to use
+func (h *hints) indexesOf(columns []*column) []*column {
+	if len(h.noIndex) == 0 || len(columns) == 1 {
+		return columns
+	}
+
+	if !h.allows(columns[0].name) {
+		return columns[:1]
+	}
+
+	out := make([]*column, 0, len(columns))
+	for _, v := range columns {
+		if v == columns[0] || h.allows(v.name) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
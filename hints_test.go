@@ -0,0 +1,122 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHintNoIndex(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("serial", ForString())
+	col.CreateColumn("time", ForInt64())
+	defer col.Close()
+
+	for i := 0; i < 50000; i++ {
+		col.InsertObject(Object{
+			"serial": string(rune('a' + i%26)),
+			"time":   int64(i),
+		})
+	}
+
+	assert.NoError(t, col.CreateLookup("serial_lookup", "serial"))
+	assert.NoError(t, col.CreateBloomIndex("serial_bloom", "serial"))
+
+	// explain returns whether the string equality filter used an index
+	explain := func(hints ...Hint) (indexed bool) {
+		col.Query(func(txn *Txn) error {
+			plan := txn.Explain()
+			assert.Equal(t, 1923, txn.Hint(hints...).WithStringEqual("serial", "c").Count())
+			indexed = plan.Steps[0].Indexed
+			return nil
+		})
+		return
+	}
+
+	assert.True(t, explain())
+	assert.True(t, explain(NoIndex("serial_lookup")))
+	assert.False(t, explain(NoIndex("serial_lookup"), NoIndex("serial_bloom")))
+	assert.False(t, explain(NoIndex("serial")))
+
+	// The zone maps of a column are not used either
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 100, txn.Hint(NoIndex("time")).WithFloatGreater("time", 49899).Count())
+		return nil
+	})
+	assert.Equal(t, uint64(0), col.Stats().Pruned)
+	assert.Equal(t, 100, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatGreater("time", 49899)
+	}))
+	assert.NotZero(t, col.Stats().Pruned)
+}
+
+func TestHintInOrder(t *testing.T) {
+	players := loadPlayers(500)
+	players.Query(func(txn *Txn) error {
+		plan := txn.Explain()
+		txn.Hint(InOrder()).WithString("race", func(v string) bool {
+			return v == "human"
+		}).With("rogue").Count()
+
+		assert.Len(t, plan.Steps, 2)
+		assert.Equal(t, "WithString", plan.Steps[0].Operation)
+		assert.Equal(t, "With", plan.Steps[1].Operation)
+		return nil
+	})
+
+	// Without the hint, the index is applied first
+	players.Query(func(txn *Txn) error {
+		plan := txn.Explain()
+		txn.WithString("race", func(v string) bool {
+			return v == "human"
+		}).With("rogue").Count()
+
+		assert.Equal(t, "With", plan.Steps[0].Operation)
+		return nil
+	})
+}
+
+func TestHintParallel(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	for i := 0; i < 100000; i++ {
+		col.InsertObject(Object{"age": i % 100})
+	}
+
+	// workersOf returns the number of workers used with a hint
+	workersOf := func(hint Hint, workers int) int {
+		var lock sync.Mutex
+		used := make(map[*Txn]bool)
+		col.Query(func(txn *Txn) error {
+			txn.Hint(hint).initialize()
+			txn.rangeParallel(workers, func(worker *Txn, _ uint32, _ bitmap.Bitmap) {
+				lock.Lock()
+				used[worker] = true
+				lock.Unlock()
+				time.Sleep(time.Millisecond)
+			})
+			return nil
+		})
+		return len(used)
+	}
+
+	assert.Equal(t, 1, workersOf(Parallel(1), 0))
+	assert.Equal(t, 2, workersOf(Parallel(1), 2))
+
+	// The hint applies to the aggregates as well
+	col.Query(func(txn *Txn) error {
+		stats, err := txn.Hint(Parallel(3)).Aggregate("age", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 100000, stats.Count)
+		assert.Equal(t, 99.0, stats.Max)
+		return nil
+	})
+}
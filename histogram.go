@@ -0,0 +1,265 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// sketchSize is the number of values sampled from every chunk of a column for its histogram
+const sketchSize = 64
+
+// Histogram represents an equi-depth histogram of the values of a numeric column, where
+// every bucket holds approximately the same number of values.
+type Histogram struct {
+	Count   int               // The number of rows which have a value
+	Buckets []HistogramBucket // The buckets, in the order of their values
+}
+
+// HistogramBucket represents a bucket of a histogram
+type HistogramBucket struct {
+	Lo, Hi float64 // The inclusive bounds of the values of the bucket
+	Count  int     // The estimated number of values in the bucket
+}
+
+// Estimate estimates the number of values within the inclusive range, assuming that the
+// values of a bucket are uniformly distributed between its bounds.
+func (h *Histogram) Estimate(lo, hi float64) (out float64) {
+	for _, b := range h.Buckets {
+		switch {
+		case hi < b.Lo || lo > b.Hi:
+			continue
+		case lo <= b.Lo && hi >= b.Hi:
+			out += float64(b.Count)
+		default:
+			width := math.Min(hi, b.Hi) - math.Max(lo, b.Lo)
+			out += math.Max(float64(b.Count)*width/(b.Hi-b.Lo), 1)
+		}
+	}
+	return math.Min(out, float64(h.Count))
+}
+
+// columnHistograms keeps the sketches of every chunk of the numeric columns whose histograms
+// were requested. Similarly to the column statistics, the commits mark the chunks they changed
+// as stale, and only these are sampled again when a histogram is built.
+type columnHistograms struct {
+	lock      sync.Mutex                 // The lock protecting the columns and their stale chunks
+	count     int32                      // The number of columns tracked (atomic)
+	columns   map[string]*histogramEntry // The sketches of the columns, by name
+	observers *rowObservers              // The observers of the rows of the collection
+}
+
+// histogramEntry represents the sketches of a column
+type histogramEntry struct {
+	lock   sync.Mutex    // The lock held while the stale chunks are sampled
+	stale  bitmap.Bitmap // The chunks to sample again
+	chunks []sketch      // The sketch of every chunk
+	last   *Histogram    // The histogram built last, for the query planner
+}
+
+// sketch represents the values sampled from a chunk, at regular intervals of their order
+type sketch struct {
+	count  int       // The number of values in the chunk
+	values []float64 // The sorted samples, including the smallest and the largest value
+}
+
+// newColumnHistograms creates a new set of column histograms
+func newColumnHistograms(observers *rowObservers) *columnHistograms {
+	return &columnHistograms{
+		columns:   make(map[string]*histogramEntry, 4),
+		observers: observers,
+	}
+}
+
+// ColumnHistogram returns an equi-depth histogram of a numeric column with up to the number
+// of buckets specified, 32 by default. Once requested for a column, a sketch of the values of
+// every chunk is maintained in the same way as the statistics of ColumnStats(), so that only
+// the chunks changed since the last call are sampled again. The query planner uses the last
+// histogram built for a column to estimate the number of rows its range filters select.
+func (c *Collection) ColumnHistogram(columnName string, buckets int) (out *Histogram, err error) {
+	column, ok := c.cols.Load(columnName)
+	if !ok || !column.IsNumeric() {
+		return nil, errorOf(ErrTypeMismatch, "column: unable to compute histogram of '%s', column is not numeric", columnName)
+	}
+
+	if buckets <= 0 {
+		buckets = 32
+	}
+
+	err = c.Query(func(txn *Txn) (err error) {
+		out, err = c.histograms.build(txn, columnName, column.Column.(Numeric), buckets)
+		return
+	})
+
+	// The ranges of the column are now estimated from the histogram instead
+	c.plans.invalidate()
+	return
+}
+
+// tracked checks whether the histograms of any column are maintained
+func (s *columnHistograms) tracked() bool {
+	return atomic.LoadInt32(&s.count) > 0
+}
+
+// build samples the stale chunks of a column and builds its histogram from the sketches of
+// all of its chunks.
+func (s *columnHistograms) build(txn *Txn, columnName string, column Numeric, buckets int) (*Histogram, error) {
+	s.lock.Lock()
+	entry, ok := s.columns[columnName]
+	if !ok {
+		entry = new(histogramEntry)
+		s.columns[columnName] = entry
+		s.observers.track(&s.count, len(s.columns))
+		for i := 0; i < txn.owner.chunks(); i++ {
+			entry.stale.Set(uint32(i))
+		}
+	}
+	s.lock.Unlock()
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	s.lock.Lock()
+	stale := append(bitmap.Bitmap(nil), entry.stale...)
+	entry.stale.Clear()
+	s.lock.Unlock()
+
+	// Sample the stale chunks, reading the values of the column which are present
+	var failed error
+	var values []float64
+	fill := column.Index()
+	stale.Range(func(x uint32) {
+		chunk := commit.Chunk(x)
+		if failed != nil || !txn.rlock(chunk) {
+			failed = txn.failed()
+			return
+		}
+
+		for len(entry.chunks) <= int(chunk) {
+			entry.chunks = append(entry.chunks, sketch{})
+		}
+
+		values = values[:0]
+		chunk.Range(*fill, func(idx uint32) {
+			if v, ok := column.LoadFloat64(idx); ok {
+				values = append(values, v)
+			}
+		})
+
+		entry.chunks[chunk] = sketchOf(values)
+		txn.runlock(chunk)
+	})
+
+	if failed != nil {
+		s.lock.Lock()
+		entry.stale.Or(stale)
+		s.lock.Unlock()
+		return nil, failed
+	}
+
+	out := histogramOf(entry.chunks, buckets)
+	s.lock.Lock()
+	entry.last = out
+	s.lock.Unlock()
+	return out, nil
+}
+
+// cached returns the histogram of a column built last, if any
+func (s *columnHistograms) cached(columnName string) (*Histogram, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if entry, ok := s.columns[columnName]; ok && entry.last != nil {
+		return entry.last, true
+	}
+	return nil, false
+}
+
+// invalidate marks a chunk as stale for the columns which were changed, or for all of the
+// columns if rows were inserted or deleted.
+func (s *columnHistograms) invalidate(chunk commit.Chunk, changed map[string]bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name, entry := range s.columns {
+		if changed[name] || changed[rowColumn] {
+			entry.stale.Set(uint32(chunk))
+		}
+	}
+}
+
+// remove stops maintaining the histogram of a column, once it is dropped or replaced
+func (s *columnHistograms) remove(columnName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.columns, columnName)
+	s.observers.track(&s.count, len(s.columns))
+}
+
+// sketchOf samples the values of a chunk at regular intervals of their order
+func sketchOf(values []float64) sketch {
+	sort.Float64s(values)
+	if len(values) <= sketchSize {
+		return sketch{count: len(values), values: append([]float64(nil), values...)}
+	}
+
+	out := sketch{count: len(values), values: make([]float64, sketchSize)}
+	for i := range out.values {
+		out.values[i] = values[i*(len(values)-1)/(sketchSize-1)]
+	}
+	return out
+}
+
+// histogramOf builds an equi-depth histogram from the sketches of the chunks, where every
+// sample stands for an equal share of the values of its chunk. The equal values are kept
+// in the same bucket, so a frequent value may make its bucket deeper than the others.
+func histogramOf(sketches []sketch, buckets int) *Histogram {
+	type sample struct {
+		value, weight float64
+	}
+
+	var samples []sample
+	out := &Histogram{}
+	for _, s := range sketches {
+		out.Count += s.count
+		for _, v := range s.values {
+			samples = append(samples, sample{value: v, weight: float64(s.count) / float64(len(s.values))})
+		}
+	}
+
+	if len(samples) == 0 {
+		return out
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].value < samples[j].value
+	})
+
+	// Cut the samples into buckets of an equal depth
+	depth := float64(out.Count) / float64(buckets)
+	cumulative, assigned, open := 0.0, 0, false
+	for i, s := range samples {
+		if !open {
+			out.Buckets = append(out.Buckets, HistogramBucket{Lo: s.value})
+			open = true
+		}
+
+		bucket := &out.Buckets[len(out.Buckets)-1]
+		bucket.Hi = s.value
+		cumulative += s.weight
+		switch {
+		case i == len(samples)-1:
+			bucket.Count = out.Count - assigned
+		case samples[i+1].value != s.value && len(out.Buckets) < buckets && cumulative >= depth*float64(len(out.Buckets)):
+			bucket.Count = int(math.Round(cumulative)) - assigned
+			assigned += bucket.Count
+			open = false
+		}
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnHistogram(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("balance", ForInt64())
+	defer col.Close()
+
+	for i := 0; i < 50000; i++ {
+		col.InsertObject(Object{"balance": int64(i % 1000)})
+	}
+
+	// The buckets are of an equal depth, and cover all of the values
+	h, err := col.ColumnHistogram("balance", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 50000, h.Count)
+	assert.Len(t, h.Buckets, 10)
+	assert.Equal(t, 0.0, h.Buckets[0].Lo)
+	assert.Equal(t, 999.0, h.Buckets[9].Hi)
+
+	total := 0
+	for _, b := range h.Buckets {
+		assert.InDelta(t, 5000, b.Count, 500)
+		total += b.Count
+	}
+	assert.Equal(t, 50000, total)
+	assert.InDelta(t, 25000, h.Estimate(0, 499), 1000)
+	assert.InDelta(t, 5000, h.Estimate(900, 2000), 500)
+	assert.Equal(t, 0.0, h.Estimate(2000, 3000))
+
+	// Only the chunk changed is stale, and changes of other columns are ignored
+	assert.NoError(t, col.QueryAt(20000, func(r Row) error {
+		r.SetInt64("balance", 5000)
+		return nil
+	}))
+	assert.NoError(t, col.QueryAt(100, func(r Row) error {
+		r.SetString("name", "roman")
+		return nil
+	}))
+	assert.Equal(t, 1, col.histograms.columns["balance"].stale.Count())
+
+	h, err = col.ColumnHistogram("balance", 0)
+	assert.NoError(t, err)
+	assert.Len(t, h.Buckets, 32)
+	assert.Equal(t, 5000.0, h.Buckets[31].Hi)
+
+	// The histogram is no longer maintained once the column is dropped
+	col.DropColumn("balance")
+	_, ok := col.histograms.cached("balance")
+	assert.False(t, ok)
+	assert.False(t, col.histograms.tracked())
+
+	_, err = col.ColumnHistogram("name", 10)
+	assert.Error(t, err)
+}
+
+func TestHistogramSkewed(t *testing.T) {
+	h := histogramOf([]sketch{
+		sketchOf([]float64{1, 1, 1, 1, 1, 1, 2, 3}),
+		sketchOf([]float64{1, 1, 4}),
+	}, 4)
+
+	// The frequent value is kept in a single bucket
+	assert.Equal(t, 11, h.Count)
+	assert.Equal(t, HistogramBucket{Lo: 1, Hi: 1, Count: 8}, h.Buckets[0])
+	assert.Equal(t, 8.0, h.Estimate(1, 1))
+	assert.Empty(t, histogramOf(nil, 4).Buckets)
+}
+
+func TestSelectivityOfHistogram(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	_, err := players.ColumnHistogram("age", 16)
+	assert.NoError(t, err)
+
+	// The range filters are estimated from the histogram, given their bounds
+	old := countWhere(players, isOld)
+	players.Query(func(txn *Txn) error {
+		selectivity := txn.selectivityOf(&filter{kind: filterBetween, column: "age", lo: 30, hi: 100})
+		assert.InDelta(t, float64(old)/500, selectivity, 0.1)
+		assert.Equal(t, 0.0, txn.selectivityOf(&filter{kind: filterGreater, column: "age", lo: 1000, hi: 2000}))
+		return nil
+	})
+}
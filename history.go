@@ -0,0 +1,229 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// version represents a commit which was applied on the collection at a specific time.
+type version struct {
+	time   int64         // The time at which the commit was applied
+	change commit.Commit // The commit itself
+}
+
+// RowVersion represents the values written into a row by a commit retained in the history
+type RowVersion struct {
+	Time   time.Time              // The time at which the commit was applied
+	Values map[string]interface{} // The values written by the commit, by column
+}
+
+// history represents a log of recent commits of a collection, along with the state of
+// the collection as of the beginning of the retention window.
+type history struct {
+	lock    sync.Mutex    // The lock to protect the history
+	window  time.Duration // The retention window
+	since   int64         // The time of the state of the base collection
+	base    *Collection   // The state of the collection before the retained commits
+	changes []version     // The retained commits, in order
+}
+
+// newHistory creates a new history with the specified retention window.
+func newHistory(window time.Duration, since time.Time) *history {
+	return &history{
+		window:  window,
+		since:   since.UnixNano(),
+		base:    NewCollection(),
+		changes: make([]version, 0, 64),
+	}
+}
+
+// Append appends a commit into the history and returns whether some of the commits went
+// out of the retention window, in which case they should be compacted.
+func (h *history) Append(owner *Collection, change commit.Commit) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	now := owner.now().UnixNano()
+	h.changes = append(h.changes, version{
+		time:   now,
+		change: change.Clone(),
+	})
+	return h.changes[0].time < now-int64(h.window)
+}
+
+// Compact applies the commits which are no longer retained on the base collection. Since
+// this replays the commits, it is called once the chunks of the owner are unlocked.
+func (h *history) Compact(owner *Collection) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	// Find the commits which went out of the retention window
+	now := owner.now().UnixNano()
+	expired := 0
+	for expired < len(h.changes) && h.changes[expired].time < now-int64(h.window) {
+		expired++
+	}
+
+	if expired == 0 {
+		return nil
+	}
+
+	// Apply the expired commits on the base, making sure the schema is up to date
+	if err := h.base.copySchema(owner); err != nil {
+		return err
+	}
+
+	// Keep the commit which failed to be applied, so that it is attempted again
+	for i, v := range h.changes[:expired] {
+		if err := h.base.Replay(v.change.Clone()); err != nil {
+			h.changes = append(h.changes[:0], h.changes[i:]...)
+			return err
+		}
+		h.since = v.time
+	}
+
+	h.changes = append(h.changes[:0], h.changes[expired:]...)
+	return nil
+}
+
+// At creates a copy of the collection with the state as of the specified time.
+func (h *history) At(owner *Collection, at time.Time) (*Collection, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if at.UnixNano() < h.since {
+		return nil, fmt.Errorf("column: state as of %v is no longer retained", at)
+	}
+
+	view, err := h.base.clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := view.copySchema(owner); err != nil {
+		view.Close()
+		return nil, err
+	}
+
+	// Replay all of the retained commits up until the specified time
+	for _, v := range h.changes {
+		if v.time > at.UnixNano() {
+			break
+		}
+
+		// Replay takes the ownership of the buffers, hence the clone
+		if err := view.Replay(v.change.Clone()); err != nil {
+			view.Close()
+			return nil, err
+		}
+	}
+
+	return view, nil
+}
+
+// Versions returns up to n of the latest versions of a row which wrote into any of the columns,
+// or into any column if none are specified, the most recent first.
+func (h *history) Versions(owner *Collection, idx uint32, n int, columnNames []string) []RowVersion {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	chunk := commit.ChunkAt(idx)
+	reader := commit.NewReader()
+	out := make([]RowVersion, 0, 4)
+	for i := len(h.changes) - 1; i >= 0 && (n <= 0 || len(out) < n); i-- {
+		v := h.changes[i]
+		if v.change.Chunk != chunk {
+			continue
+		}
+
+		inserted := false
+		values := make(map[string]interface{}, 2)
+		for _, u := range v.change.Updates {
+			if u.Column == rowColumn {
+				reader.Range(u, chunk, func(r *commit.Reader) {
+					for r.Next() {
+						inserted = inserted || (r.Index() == idx && r.Type == commit.Insert)
+					}
+				})
+				continue
+			}
+
+			column, ok := owner.cols.Load(u.Column)
+			if !ok || u.Column == expireColumn || !selected(u.Column, columnNames) {
+				continue
+			}
+
+			reader.Range(u, chunk, func(r *commit.Reader) {
+				for r.Next() {
+					if r.Index() == idx {
+						values[u.Column] = valueOf(column.Column, r)
+					}
+				}
+			})
+		}
+
+		if len(values) > 0 {
+			out = append(out, RowVersion{
+				Time:   time.Unix(0, v.time),
+				Values: values,
+			})
+		}
+
+		// The commits before the row was inserted belong to a row deleted before it
+		if inserted {
+			break
+		}
+	}
+	return out
+}
+
+// selected returns whether the column is among the ones specified, or if none were
+func selected(columnName string, columnNames []string) bool {
+	for _, name := range columnNames {
+		if name == columnName {
+			return true
+		}
+	}
+	return len(columnNames) == 0
+}
+
+// History returns up to n of the latest values committed into the row, along with the time
+// they were committed at, the most recent first. Only the commits which wrote into any of
+// the specified columns are returned, or into any column if none are specified, and only
+// the values of these columns. This requires the history retention to be enabled on the
+// collection, and the commits older than the retention window or than the insertion of the
+// row are not returned. If n is zero, all of the retained values are returned.
+func (r Row) History(n int, columnNames ...string) ([]RowVersion, error) {
+	owner := r.txn.owner
+	if owner.history == nil {
+		return nil, fmt.Errorf("column: unable to read the history of the row, history is not retained")
+	}
+
+	return owner.history.Versions(owner, r.txn.cursor, n, columnNames), nil
+}
+
+// QueryAsOf creates a read-only transaction which runs against the state of the collection
+// as of the specified time. This requires the history retention to be enabled on the
+// collection and the time to be within the retention window.
+func (c *Collection) QueryAsOf(at time.Time, fn func(txn *Txn) error) error {
+	if c.history == nil {
+		return fmt.Errorf("column: unable to query as of %v, history is not retained", at)
+	}
+
+	view, err := c.history.At(c, at)
+	if err != nil {
+		return err
+	}
+
+	defer view.Close()
+	txn := view.txns.acquire(view)
+	defer view.txns.release(txn)
+	defer txn.rollback()
+	return fn(txn)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Change represents a change of a row proposed by a transaction, before it is committed.
+type Change struct {
+	Index  uint32                 // The index of the row
+	Values map[string]interface{} // The values written, by column, or nil if a value is removed
+	Deltas map[string]interface{} // The amounts added to the numeric columns, by column
+	Merges map[string]interface{} // The deltas merged into the columns, by column
+}
+
+// Hook represents a function which is called with a change proposed by a transaction, and
+// which can veto it by returning an error. The transaction is available to read the rows,
+// its context and its metadata, but must not be modified by the hook.
+type Hook func(txn *Txn, change Change) error
+
+// hookType represents the kind of change a hook is called for
+type hookType int
+
+const (
+	hookInsert hookType = iota
+	hookUpdate
+	hookDelete
+)
+
+// BeforeInsert registers a hook which is called for every row inserted by a transaction,
+// before the transaction is committed. If the hook returns an error, the entire transaction
+// is rolled back and the error is returned.
+func (c *Collection) BeforeInsert(fn Hook) {
+	c.addHook(hookInsert, fn)
+}
+
+// BeforeUpdate registers a hook which is called for every existing row updated by a
+// transaction, before the transaction is committed. If the hook returns an error, the
+// entire transaction is rolled back and the error is returned.
+func (c *Collection) BeforeUpdate(fn Hook) {
+	c.addHook(hookUpdate, fn)
+}
+
+// BeforeDelete registers a hook which is called for every row deleted by a transaction,
+// before the transaction is committed. If the hook returns an error, the entire transaction
+// is rolled back and the error is returned.
+func (c *Collection) BeforeDelete(fn Hook) {
+	c.addHook(hookDelete, fn)
+}
+
+// addHook registers a hook for a kind of change. The hooks are copied, so that they can
+// be read without holding the lock.
+func (c *Collection) addHook(kind hookType, fn Hook) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	hooks := make([]Hook, 0, len(c.hooks[kind])+1)
+	c.hooks[kind] = append(append(hooks, c.hooks[kind]...), fn)
+}
+
+// checkHooks calls the registered hooks with all of the changes proposed by the transaction,
+// in the order of the rows, and returns the first error.
+func (txn *Txn) checkHooks() error {
+	txn.owner.lock.RLock()
+	hooks := txn.owner.hooks
+	txn.owner.lock.RUnlock()
+	if len(hooks[hookInsert]) == 0 && len(hooks[hookUpdate]) == 0 && len(hooks[hookDelete]) == 0 {
+		return nil
+	}
+
+	changes, kinds := txn.changes()
+	order := make([]uint32, 0, len(changes))
+	for idx := range changes {
+		order = append(order, idx)
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	for _, idx := range order {
+		for _, fn := range hooks[kinds[idx]] {
+			if err := fn(txn, *changes[idx]); err != nil {
+				return txn.violated(err, FieldRow, idx)
+			}
+		}
+	}
+	return nil
+}
+
+// changes collects the pending changes of the transaction by row, along with their kind.
+func (txn *Txn) changes() (map[uint32]*Change, map[uint32]hookType) {
+	changes := make(map[uint32]*Change, 16)
+	kinds := make(map[uint32]hookType, 16)
+	changeAt := func(idx uint32) *Change {
+		if change, ok := changes[idx]; ok {
+			return change
+		}
+
+		changes[idx] = &Change{Index: idx}
+		kinds[idx] = hookUpdate
+		return changes[idx]
+	}
+
+	// Collect the inserts and deletes first, so the kind of the rows is known
+	for _, u := range txn.updates {
+		if u.Column != rowColumn {
+			continue
+		}
+
+		txn.reader.Seek(u)
+		for txn.reader.Next() {
+			changeAt(txn.reader.Index())
+			switch txn.reader.Type {
+			case commit.Insert:
+				kinds[txn.reader.Index()] = hookInsert
+			case commit.Delete:
+				kinds[txn.reader.Index()] = hookDelete
+			}
+		}
+	}
+
+	txn.deletes.Range(func(idx uint32) {
+		changeAt(idx)
+		kinds[idx] = hookDelete
+	})
+
+	// Collect the values written in every column
+	for _, u := range txn.updates {
+		column, ok := txn.owner.cols.Load(u.Column)
+		if u.Column == rowColumn || !ok || column.IsIndex() {
+			continue
+		}
+
+		txn.reader.Seek(u)
+		for txn.reader.Next() {
+			change := changeAt(txn.reader.Index())
+			switch txn.reader.Type {
+			case commit.Add:
+				if change.Deltas == nil {
+					change.Deltas = make(map[string]interface{}, 4)
+				}
+				change.Deltas[u.Column] = valueOf(column.Column, txn.reader)
+			case commit.Merge:
+				if change.Merges == nil {
+					change.Merges = make(map[string]interface{}, 4)
+				}
+				change.Merges[u.Column] = valueOf(column.Column, txn.reader)
+			default:
+				if change.Values == nil {
+					change.Values = make(map[string]interface{}, 4)
+				}
+				change.Values[u.Column] = valueOf(column.Column, txn.reader)
+			}
+		}
+	}
+
+	txn.reader.Rewind()
+	return changes, kinds
+}
+
+// valueOf decodes the value of the current operation of the reader, for the column. A
+// removed value is decoded as nil.
+func valueOf(c Column, r *commit.Reader) interface{} {
+	if _, isBool := c.(*columnBool); isBool {
+		return r.Bool()
+	}
+
+	if r.Type == commit.Delete {
+		return nil
+	}
+
+	switch c.(type) {
+	case *float32Column:
+		return r.Float32()
+	case *float64Column:
+		return r.Float64()
+	case *intColumn:
+		return int(r.Int64())
+	case *int8Column:
+		return r.Int8()
+	case *int16Column:
+		return r.Int16()
+	case *int32Column:
+		return r.Int32()
+	case *int64Column:
+		return r.Int64()
+	case *columnDuration:
+		return time.Duration(r.Int64())
+	case *uintColumn:
+		return uint(r.Uint64())
+	case *uint8Column:
+		return r.Uint8()
+	case *uint16Column:
+		return r.Uint16()
+	case *uint32Column:
+		return r.Uint32()
+	case *uint64Column:
+		return r.Uint64()
+	case *columnString, *columnEnum, *columnKey:
+		return string(r.Bytes())
+	case *columnMap:
+		return decodeMap(string(r.Bytes()))
+	case *columnIP:
+		if addr, ok := ipOf(r.Bytes()); ok {
+			return net.IP(addr[:])
+		}
+		return nil
+	case *columnInt128:
+		if v, ok := int128Of(r.Bytes()); ok {
+			return v
+		}
+		return nil
+	default:
+		return nil
+	}
+}
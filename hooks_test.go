@@ -0,0 +1,105 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("owner", ForString())
+	col.CreateColumn("balance", ForInt())
+	col.CreateColumn("active", ForBool())
+	defer col.Close()
+
+	errNegative := errors.New("negative balance")
+	errForbidden := errors.New("forbidden")
+	var inserts, updates, deletes []Change
+
+	col.BeforeInsert(func(txn *Txn, change Change) error {
+		inserts = append(inserts, change)
+		if v, ok := change.Values["balance"]; ok && v.(int) < 0 {
+			return errNegative
+		}
+		return nil
+	})
+	col.BeforeUpdate(func(txn *Txn, change Change) error {
+		updates = append(updates, change)
+		return nil
+	})
+	col.BeforeDelete(func(txn *Txn, change Change) error {
+		deletes = append(deletes, change)
+		if principal, _ := PrincipalOf(txn.Context()); principal != "admin" {
+			return errForbidden
+		}
+		return nil
+	})
+
+	// Inserts are validated with their values
+	for i := 0; i < 3; i++ {
+		col.Insert(func(r Row) error {
+			r.SetString("owner", "roman")
+			r.SetInt("balance", i*10)
+			r.SetBool("active", true)
+			return nil
+		})
+	}
+
+	assert.Equal(t, 3, col.Count())
+	assert.Len(t, inserts, 3)
+	assert.Equal(t, map[string]interface{}{
+		"owner":   "roman",
+		"balance": 20,
+		"active":  true,
+	}, inserts[2].Values)
+
+	// A vetoed insert is never committed
+	_, err := col.Insert(func(r Row) error {
+		r.SetInt("balance", -1)
+		return nil
+	})
+	assert.Equal(t, errNegative, err)
+	assert.Equal(t, 3, col.Count())
+
+	// Updates receive the values and the deltas
+	assert.NoError(t, col.QueryAt(1, func(r Row) error {
+		r.SetBool("active", false)
+		r.AddInt("balance", 5)
+		return nil
+	}))
+	assert.Len(t, updates, 1)
+	assert.Equal(t, uint32(1), updates[0].Index)
+	assert.Equal(t, map[string]interface{}{"active": false}, updates[0].Values)
+	assert.Equal(t, map[string]interface{}{"balance": 5}, updates[0].Deltas)
+
+	// Deletes are authorized with the context of the transaction
+	assert.Equal(t, errForbidden, col.Query(func(txn *Txn) error {
+		txn.DeleteAt(0)
+		return nil
+	}))
+	assert.Equal(t, 3, col.Count())
+
+	admin := WithPrincipal(context.Background(), "admin")
+	assert.NoError(t, col.QueryContext(admin, func(txn *Txn) error {
+		txn.DeleteAt(0)
+		return nil
+	}))
+	assert.Equal(t, 2, col.Count())
+	assert.Len(t, deletes, 2)
+
+	// A veto in one of the collections rolls back the entire transaction
+	assert.Equal(t, errForbidden, Atomic(func(tx *MultiTxn) error {
+		return tx.Query(col, func(txn *Txn) error {
+			txn.InsertObject(Object{"balance": 100})
+			txn.DeleteAt(1)
+			return nil
+		})
+	}))
+	assert.Equal(t, 2, col.Count())
+}
@@ -0,0 +1,209 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errIngestClosed is returned when a row is ingested once the ingester is closed
+var errIngestClosed = errors.New("column: ingester is closed")
+
+// IngestOptions represents the options of an ingester
+type IngestOptions struct {
+	BatchSize int                             // The maximum number of rows inserted by a transaction, 1024 by default
+	Interval  time.Duration                   // The maximum duration a row waits for its batch to fill up, 100ms by default
+	Buffer    int                             // The number of rows buffered before Insert() blocks, 4 batches by default
+	OnError   func(row Object, err error)     // The callback for the rows which could not be inserted (optional)
+	OnBatch   func(rows int, d time.Duration) // The callback for every batch inserted at once, for metrics (optional)
+}
+
+// Ingester represents an asynchronous ingestion of rows into a collection, which inserts the
+// rows in batches of a single transaction each, in the background.
+type Ingester struct {
+	owner    *Collection        // The collection the rows are inserted into
+	opts     IngestOptions      // The options of the ingester
+	lock     sync.RWMutex       // The lock excluding the inserts while the ingester is closed
+	closed   bool               // Whether the ingester is closed
+	rows     chan Object        // The buffered rows
+	flushes  chan chan struct{} // The requests to insert the buffered rows right away
+	done     chan struct{}      // The channel closed once the background insertion stops
+	inserted uint64             // The number of rows inserted (atomic)
+	failed   uint64             // The number of rows which could not be inserted (atomic)
+}
+
+// Ingest creates an ingester which inserts the rows into the collection in the background,
+// rather than with a transaction per row. The rows are inserted in batches of up to the
+// batch size, and a batch which does not fill up is inserted once the interval elapses. Once
+// the buffer is full, Insert() blocks until the rows are inserted, which applies back-pressure
+// to the producers. If a batch fails, its rows are inserted again one by one so that only the
+// rows in error are rejected, and reported to the error callback. The ingester must be closed
+// once done, which inserts the rows which are still buffered.
+func (c *Collection) Ingest(opts ...IngestOptions) *Ingester {
+	options := IngestOptions{
+		BatchSize: 1024,
+		Interval:  100 * time.Millisecond,
+	}
+
+	for _, o := range opts {
+		if o.BatchSize > 0 {
+			options.BatchSize = o.BatchSize
+		}
+		if o.Interval > 0 {
+			options.Interval = o.Interval
+		}
+		if o.Buffer > 0 {
+			options.Buffer = o.Buffer
+		}
+		if o.OnError != nil {
+			options.OnError = o.OnError
+		}
+		if o.OnBatch != nil {
+			options.OnBatch = o.OnBatch
+		}
+	}
+
+	if options.Buffer <= 0 {
+		options.Buffer = 4 * options.BatchSize
+	}
+
+	ingester := &Ingester{
+		owner:   c,
+		opts:    options,
+		rows:    make(chan Object, options.Buffer),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go ingester.run()
+	return ingester
+}
+
+// Insert queues a row to be inserted, and blocks while the buffer is full. This returns an
+// error once the ingester or the collection is closed.
+func (g *Ingester) Insert(row Object) error {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if g.closed {
+		return errIngestClosed
+	}
+
+	select {
+	case g.rows <- row:
+		return nil
+	case <-g.done:
+		return errIngestClosed
+	}
+}
+
+// Flush inserts the rows which are buffered, and waits until they are.
+func (g *Ingester) Flush() {
+	ack := make(chan struct{})
+	select {
+	case g.flushes <- ack:
+		<-ack
+	case <-g.done:
+	}
+}
+
+// Close inserts the rows which are still buffered and stops the ingester. The rows queued
+// afterwards are rejected.
+func (g *Ingester) Close() {
+	g.lock.Lock()
+	if !g.closed {
+		g.closed = true
+		close(g.rows)
+	}
+	g.lock.Unlock()
+	<-g.done
+}
+
+// Inserted returns the number of rows inserted so far.
+func (g *Ingester) Inserted() int {
+	return int(atomic.LoadUint64(&g.inserted))
+}
+
+// Failed returns the number of rows which could not be inserted so far.
+func (g *Ingester) Failed() int {
+	return int(atomic.LoadUint64(&g.failed))
+}
+
+// run inserts the buffered rows in batches, until the ingester or the collection is closed
+func (g *Ingester) run() {
+	defer close(g.done)
+	ticker := time.NewTicker(g.opts.Interval)
+	defer ticker.Stop()
+
+	batch := make([]Object, 0, g.opts.BatchSize)
+	for {
+		select {
+		case row, ok := <-g.rows:
+			if !ok {
+				g.insert(batch)
+				return
+			}
+
+			if batch = append(batch, row); len(batch) >= g.opts.BatchSize {
+				batch = g.insert(batch)
+			}
+
+		// Insert the rows buffered at the time of the flush, including the ones in the channel
+		case ack := <-g.flushes:
+			for pending := len(g.rows); pending > 0; pending-- {
+				if batch = append(batch, <-g.rows); len(batch) >= g.opts.BatchSize {
+					batch = g.insert(batch)
+				}
+			}
+			batch = g.insert(batch)
+			close(ack)
+
+		case <-ticker.C:
+			batch = g.insert(batch)
+
+		case <-g.owner.ctx.Done():
+			return
+		}
+	}
+}
+
+// insert inserts a batch of rows with a single transaction and returns the emptied batch. If
+// the transaction fails, the rows are inserted one by one to only reject the ones in error.
+func (g *Ingester) insert(batch []Object) []Object {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	start := time.Now()
+	if err := g.owner.Query(func(txn *Txn) error {
+		_, err := txn.InsertObjects(batch)
+		return err
+	}); err == nil {
+		atomic.AddUint64(&g.inserted, uint64(len(batch)))
+		if g.opts.OnBatch != nil {
+			g.opts.OnBatch(len(batch), time.Since(start))
+		}
+		return batch[:0]
+	}
+
+	for _, row := range batch {
+		err := g.owner.Query(func(txn *Txn) error {
+			_, err := txn.InsertObject(row)
+			return err
+		})
+
+		if err == nil {
+			atomic.AddUint64(&g.inserted, 1)
+			continue
+		}
+
+		atomic.AddUint64(&g.failed, 1)
+		if g.opts.OnError != nil {
+			g.opts.OnError(row, err)
+		}
+	}
+	return batch[:0]
+}
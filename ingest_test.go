@@ -0,0 +1,111 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIngest(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	var batches int32
+	ingest := col.Ingest(IngestOptions{
+		BatchSize: 100,
+		Interval:  time.Hour,
+		OnBatch: func(rows int, _ time.Duration) {
+			assert.LessOrEqual(t, rows, 100)
+			atomic.AddInt32(&batches, 1)
+		},
+	})
+
+	// Concurrent producers are batched together
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < 250; i++ {
+				assert.NoError(t, ingest.Insert(Object{"name": fmt.Sprintf("%d-%d", p, i), "age": i}))
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	// The rows of the batch which is not full are inserted once flushed
+	ingest.Flush()
+	assert.Equal(t, 1000, col.Count())
+	assert.Equal(t, 1000, ingest.Inserted())
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&batches), int32(10))
+
+	ingest.Close()
+	assert.Error(t, ingest.Insert(Object{"name": "late"}))
+	assert.Equal(t, 1000, col.Count())
+}
+
+func TestIngestInterval(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	ingest := col.Ingest(IngestOptions{Interval: 10 * time.Millisecond})
+	defer ingest.Close()
+	assert.NoError(t, ingest.Insert(Object{"name": "Roman"}))
+	assert.Eventually(t, func() bool {
+		return col.Count() == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestIngestErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	var rejected []Object
+	ingest := col.Ingest(IngestOptions{
+		BatchSize: 10,
+		OnError: func(row Object, err error) {
+			assert.ErrorIs(t, err, ErrTypeMismatch)
+			rejected = append(rejected, row)
+		},
+	})
+
+	// Only the invalid row of the batch is rejected
+	for i := 0; i < 9; i++ {
+		assert.NoError(t, ingest.Insert(Object{"name": fmt.Sprint(i), "age": i}))
+	}
+	assert.NoError(t, ingest.Insert(Object{"name": "Roman", "age": "old"}))
+
+	ingest.Close()
+	assert.Equal(t, 9, col.Count())
+	assert.Equal(t, 9, ingest.Inserted())
+	assert.Equal(t, 1, ingest.Failed())
+	assert.Equal(t, []Object{{"name": "Roman", "age": "old"}}, rejected)
+}
+
+func TestIngestBackPressure(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+
+	// Once the collection is closed, the blocked producers are released
+	ingest := col.Ingest(IngestOptions{BatchSize: 2, Buffer: 1})
+	col.Close()
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = ingest.Insert(Object{"name": "Roman"})
+	}
+	assert.Error(t, err)
+	ingest.Close()
+}
@@ -0,0 +1,194 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// kvPartSize is the maximum size of a value written into a key-value store
+const kvPartSize = 1 << 20
+
+// KV represents a key-value store into which the snapshots of a collection can be written,
+// such as the IndexedDB of a browser or the local storage of a mobile application, when
+// there is no file system to write them into.
+type KV interface {
+	Get(key string) ([]byte, bool, error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+}
+
+// SnapshotKV writes a snapshot of the collection into a key-value store, under the specified
+// key. The snapshot is split into values of up to 1MB, written under the key followed by a
+// generation and a sequence number, and the key itself records the generation and the number
+// of values once they are all written. The values of the previous snapshot are deleted last,
+// so that a failure at any point leaves either the previous or the new snapshot intact.
+func (c *Collection) SnapshotKV(kv KV, key string) error {
+	prev, err := readManifest(kv, key)
+	if err != nil {
+		return err
+	}
+
+	// Write the parts of a new generation, then point the key to them
+	w := &kvWriter{kv: kv, key: key, generation: prev.generation + 1}
+	if err := c.Snapshot(w); err != nil {
+		return err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	next := kvManifest{generation: w.generation, parts: w.parts}
+	if err := kv.Put(key, next.encode()); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < prev.parts; i++ {
+		if err := kv.Delete(partKey(key, prev.generation, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreKV restores the collection from a snapshot written into a key-value store with
+// SnapshotKV(), under the specified key. As with Restore(), this should be called right
+// after the collection is created, before any transaction.
+func (c *Collection) RestoreKV(kv KV, key string) error {
+	value, ok, err := kv.Get(key)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("column: unable to restore, snapshot '%s' does not exist", key)
+	}
+
+	manifest, err := decodeManifest(value)
+	if err != nil {
+		return err
+	}
+
+	return c.Restore(&kvReader{
+		kv:       kv,
+		key:      key,
+		manifest: manifest,
+	})
+}
+
+// kvManifest represents the value of the key of a snapshot, which records its parts
+type kvManifest struct {
+	generation uint64 // The generation of the snapshot
+	parts      uint64 // The number of values the snapshot is split into
+}
+
+// readManifest reads the manifest of a snapshot, or returns an empty one if it is missing
+func readManifest(kv KV, key string) (kvManifest, error) {
+	value, ok, err := kv.Get(key)
+	if err != nil || !ok {
+		return kvManifest{}, err
+	}
+	return decodeManifest(value)
+}
+
+// decodeManifest decodes the manifest of a snapshot
+func decodeManifest(value []byte) (kvManifest, error) {
+	if len(value) != 16 {
+		return kvManifest{}, fmt.Errorf("column: unable to restore, invalid snapshot manifest")
+	}
+
+	return kvManifest{
+		generation: binary.BigEndian.Uint64(value[0:8]),
+		parts:      binary.BigEndian.Uint64(value[8:16]),
+	}, nil
+}
+
+// encode encodes the manifest
+func (m kvManifest) encode() []byte {
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[0:8], m.generation)
+	binary.BigEndian.PutUint64(out[8:16], m.parts)
+	return out
+}
+
+// partKey returns the key of a part of a snapshot
+func partKey(key string, generation, part uint64) string {
+	return fmt.Sprintf("%s.%d.%d", key, generation, part)
+}
+
+// kvWriter represents a writer which splits a snapshot into the values of a store
+type kvWriter struct {
+	kv         KV
+	key        string
+	generation uint64
+	parts      uint64
+	buffer     bytes.Buffer
+}
+
+// Write buffers the bytes and writes every full part into the store
+func (w *kvWriter) Write(p []byte) (int, error) {
+	n, _ := w.buffer.Write(p)
+	for w.buffer.Len() >= kvPartSize {
+		if err := w.put(w.buffer.Next(kvPartSize)); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// flush writes the remainder of the buffer as the last part
+func (w *kvWriter) flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	return w.put(w.buffer.Next(w.buffer.Len()))
+}
+
+// put writes a part into the store
+func (w *kvWriter) put(part []byte) error {
+	value := make([]byte, len(part))
+	copy(value, part)
+	if err := w.kv.Put(partKey(w.key, w.generation, w.parts), value); err != nil {
+		return err
+	}
+
+	w.parts++
+	return nil
+}
+
+// kvReader represents a reader of the parts of a snapshot, in sequence
+type kvReader struct {
+	kv       KV
+	key      string
+	manifest kvManifest
+	next     uint64 // The next part to read
+	part     []byte // The remainder of the current part
+}
+
+// Read reads from the current part, and reads the next part once it is exhausted
+func (r *kvReader) Read(p []byte) (int, error) {
+	for len(r.part) == 0 {
+		if r.next == r.manifest.parts {
+			return 0, io.EOF
+		}
+
+		key := partKey(r.key, r.manifest.generation, r.next)
+		value, ok, err := r.kv.Get(key)
+		switch {
+		case err != nil:
+			return 0, err
+		case !ok:
+			return 0, fmt.Errorf("column: unable to restore, snapshot part '%s' does not exist", key)
+		}
+
+		r.part = value
+		r.next++
+	}
+
+	n := copy(p, r.part)
+	r.part = r.part[n:]
+	return n, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+//go:build js && wasm
+// +build js,wasm
+
+package column
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// indexedDB represents a key-value store backed by an object store of the IndexedDB of
+// the browser, with the values stored as Uint8Array.
+type indexedDB struct {
+	db    js.Value
+	store string
+}
+
+// IndexedDB opens a key-value store backed by an object store of an IndexedDB database of
+// the browser, creating them if they do not exist, so that the snapshots can be written with
+// SnapshotKV(). Since every request waits for its result, the store must not be used from
+// the callback of a js.Func, but from a goroutine instead.
+func IndexedDB(database, store string) (KV, error) {
+	factory := js.Global().Get("indexedDB")
+	if factory.IsUndefined() {
+		return nil, fmt.Errorf("column: unable to open database, indexedDB is not available")
+	}
+
+	// Open the database, and upgrade it if the object store needs to be created
+	version := 0
+	for {
+		db, err := openIndexedDB(factory, database, store, version)
+		if err != nil {
+			return nil, err
+		}
+
+		if db.Get("objectStoreNames").Call("contains", store).Bool() {
+			return &indexedDB{db: db, store: store}, nil
+		}
+
+		version = db.Get("version").Int() + 1
+		db.Call("close")
+	}
+}
+
+// openIndexedDB opens a database with a specific version, or its current one if zero, and
+// creates the object store during the upgrade
+func openIndexedDB(factory js.Value, database, store string, version int) (js.Value, error) {
+	var req js.Value
+	if version > 0 {
+		req = factory.Call("open", database, version)
+	} else {
+		req = factory.Call("open", database)
+	}
+
+	upgrade := js.FuncOf(func(js.Value, []js.Value) interface{} {
+		db := req.Get("result")
+		if !db.Get("objectStoreNames").Call("contains", store).Bool() {
+			db.Call("createObjectStore", store)
+		}
+		return nil
+	})
+	defer upgrade.Release()
+
+	req.Set("onupgradeneeded", upgrade)
+	return await(req)
+}
+
+// Get reads the value of a key
+func (s *indexedDB) Get(key string) ([]byte, bool, error) {
+	value, err := await(s.objectStore("readonly").Call("get", key))
+	if err != nil || value.IsUndefined() {
+		return nil, false, err
+	}
+
+	out := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(out, value)
+	return out, true, nil
+}
+
+// Put writes the value of a key
+func (s *indexedDB) Put(key string, value []byte) error {
+	array := js.Global().Get("Uint8Array").New(len(value))
+	js.CopyBytesToJS(array, value)
+	_, err := await(s.objectStore("readwrite").Call("put", array, key))
+	return err
+}
+
+// Delete deletes a key
+func (s *indexedDB) Delete(key string) error {
+	_, err := await(s.objectStore("readwrite").Call("delete", key))
+	return err
+}
+
+// objectStore starts a transaction on the object store
+func (s *indexedDB) objectStore(mode string) js.Value {
+	return s.db.Call("transaction", s.store, mode).Call("objectStore", s.store)
+}
+
+// await waits for a request to complete and returns its result
+func await(req js.Value) (js.Value, error) {
+	done := make(chan error, 1)
+	success := js.FuncOf(func(js.Value, []js.Value) interface{} {
+		done <- nil
+		return nil
+	})
+	failure := js.FuncOf(func(js.Value, []js.Value) interface{} {
+		done <- fmt.Errorf("column: indexedDB request failed, %s", req.Get("error").Call("toString").String())
+		return nil
+	})
+	defer success.Release()
+	defer failure.Release()
+
+	req.Set("onsuccess", success)
+	req.Set("onerror", failure)
+	if err := <-done; err != nil {
+		return js.Undefined(), err
+	}
+	return req.Get("result"), nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotKV(t *testing.T) {
+	input := loadPlayers(500)
+	defer input.Close()
+
+	kv := newFakeKV()
+	assert.NoError(t, input.SnapshotKV(kv, "players"))
+	assert.NoError(t, input.SnapshotKV(kv, "players"))
+
+	// Only the parts of the latest snapshot are kept, along with its manifest
+	manifest, err := readManifest(kv, "players")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), manifest.generation)
+	assert.Len(t, kv.data, int(manifest.parts)+1)
+
+	output := newEmpty(500)
+	defer output.Close()
+	assert.NoError(t, output.RestoreKV(kv, "players"))
+	assert.Equal(t, input.Count(), output.Count())
+}
+
+func TestSnapshotKVFailed(t *testing.T) {
+	input := loadPlayers(500)
+	defer input.Close()
+
+	kv := newFakeKV()
+	assert.NoError(t, input.SnapshotKV(kv, "players"))
+	input.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	})
+
+	// The previous snapshot is kept if the new one could not be written
+	kv.fail = true
+	assert.Error(t, input.SnapshotKV(kv, "players"))
+	kv.fail = false
+
+	output := newEmpty(500)
+	defer output.Close()
+	assert.NoError(t, output.RestoreKV(kv, "players"))
+	assert.Equal(t, 500, output.Count())
+	assert.Error(t, output.RestoreKV(kv, "missing"))
+}
+
+// fakeKV represents an in-memory key-value store
+type fakeKV struct {
+	data map[string][]byte
+	fail bool
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{data: make(map[string][]byte)}
+}
+
+func (kv *fakeKV) Get(key string) ([]byte, bool, error) {
+	value, ok := kv.data[key]
+	return value, ok, nil
+}
+
+func (kv *fakeKV) Put(key string, value []byte) error {
+	if kv.fail {
+		return fmt.Errorf("unable to write '%s'", key)
+	}
+
+	kv.data[key] = value
+	return nil
+}
+
+func (kv *fakeKV) Delete(key string) error {
+	delete(kv.data, key)
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOverloaded is returned when a transaction could not be admitted before its deadline,
+// since the collection was already running as many queries or writers as it allows.
+var ErrOverloaded = errors.New("column: too many concurrent transactions")
+
+// limiter represents a semaphore limiting the number of concurrent operations. Since the
+// goroutines blocked on a channel are woken up in the order they arrived, the operations
+// waiting for a slot are admitted in a first-come, first-served order.
+type limiter chan struct{}
+
+// newLimiter creates a new limiter, or returns nil if the operations are not limited
+func newLimiter(limit int) limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return make(limiter, limit)
+}
+
+// acquire waits for a slot until the context is done, and returns whether it got one
+func (l limiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+
+	select {
+	case l <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case l <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release releases a slot acquired previously
+func (l limiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// admit waits until the transaction can scan the collection, if the number of concurrent
+// scans is limited. The point reads and writes never scan and hence are not limited, so that
+// a burst of analytical queries does not starve them.
+func (txn *Txn) admit() {
+	if txn.admitted || txn.owner.scans == nil {
+		return
+	}
+
+	if !txn.owner.scans.acquire(txn.ctx) {
+		txn.abort(ErrOverloaded)
+		return
+	}
+	txn.admitted = true
+}
+
+// leave releases the slot of the transaction, if it was admitted to scan the collection,
+// along with the chunks pinned by the views of the columns.
+func (txn *Txn) leave() {
+	txn.unpin()
+	if txn.admitted {
+		txn.admitted = false
+		txn.owner.scans.release()
+	}
+}
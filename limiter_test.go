@@ -0,0 +1,85 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	col := NewCollection(Options{
+		MaxConcurrentQueries: 1,
+		Timeout:              50 * time.Millisecond,
+	})
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+	idx := col.InsertObject(Object{"name": "roman"})
+
+	// While a scan holds the only slot, other scans are rejected but not the point writes
+	started, done := make(chan struct{}), make(chan struct{})
+	go col.Query(func(txn *Txn) error {
+		txn.WithString("name", func(v string) bool { return true }).Count()
+		close(started)
+		<-done
+		return nil
+	})
+
+	<-started
+	assert.Equal(t, ErrOverloaded, col.Query(func(txn *Txn) error {
+		txn.WithString("name", func(v string) bool { return true }).Count()
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		r.SetString("name", "updated")
+		return nil
+	}))
+
+	// Once released, the slot is given to the next scan
+	close(done)
+	assert.Eventually(t, func() bool {
+		return countWhere(col, func(txn *Txn) *Txn {
+			return txn.WithValue("name", func(v interface{}) bool { return v == "updated" })
+		}) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestMaxWriters(t *testing.T) {
+	col := NewCollection(Options{
+		MaxWriters: 2,
+	})
+	col.CreateColumn("balance", ForFloat64())
+	defer col.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			col.InsertObject(Object{"balance": 1.0})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, col.Count())
+	assert.Empty(t, col.writers)
+}
+
+func TestLimiter(t *testing.T) {
+	assert.Nil(t, newLimiter(0))
+	assert.True(t, limiter(nil).acquire(nil))
+	limiter(nil).release()
+
+	l := newLimiter(1)
+	assert.True(t, l.acquire(nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, l.acquire(ctx))
+	l.release()
+	assert.True(t, l.acquire(ctx))
+}
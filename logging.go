@@ -0,0 +1,117 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"time"
+)
+
+// Logger receives the structured events of the operations which a collection performs on its
+// own, such as the vacuum or the eviction, so that they can be forwarded to a logging library
+// with a small adapter. The method is called synchronously, from concurrent goroutines, and
+// should return quickly, without blocking.
+type Logger interface {
+	Log(event LogEvent)
+}
+
+// LoggerFunc is an adapter which allows a function to be used as a logger.
+type LoggerFunc func(event LogEvent)
+
+// Log calls the function with the event
+func (fn LoggerFunc) Log(event LogEvent) {
+	fn(event)
+}
+
+// LogEvent represents an event reported by a collection to its logger.
+type LogEvent struct {
+	Time    time.Time              // The time of the event, as per the clock of the collection
+	Level   LogLevel               // The severity of the event
+	Name    string                 // The name of the event, one of the Event constants
+	Message string                 // The description of the event
+	Fields  map[string]interface{} // The attributes of the event, keyed by the Field constants
+	Err     error                  // The error of the operation, if it failed
+}
+
+// LogLevel represents the severity of an event
+type LogLevel uint8
+
+// Severities of the events, in increasing order
+const (
+	LevelDebug LogLevel = iota // The frequent events, such as the progress of a snapshot
+	LevelInfo                  // The events which changed the collection, such as an eviction
+	LevelWarn                  // The operations which were refused, such as a duplicate key
+	LevelError                 // The operations which failed
+)
+
+// String returns the name of the severity
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Names of the events and of the fields reported by the collection
+const (
+	EventIndexBuilt = "column.index.built"
+	EventVacuum     = "column.vacuum"
+	EventSnapshot   = "column.snapshot"
+	EventEviction   = "column.eviction"
+	EventViolation  = "column.violation"
+	EventHistory    = "column.history"
+	FieldIndex      = "index"
+	FieldColumn     = "column"
+	FieldKey        = "key"
+	FieldRow        = "row"
+	FieldRows       = "rows"
+	FieldChunk      = "chunk"
+	FieldChunks     = "chunks"
+	FieldBytes      = "bytes"
+	FieldLimit      = "limit"
+	FieldDuration   = "duration"
+)
+
+// logs returns whether the collection has a logger, so that the fields of an event are
+// only collected when it is reported.
+func (c *Collection) logs() bool {
+	return c.opts.Logger != nil
+}
+
+// log reports an event to the logger of the collection, if there is one. The fields are
+// specified as pairs of a name and a value.
+func (c *Collection) log(level LogLevel, name, message string, err error, fields ...interface{}) {
+	if c.opts.Logger == nil {
+		return
+	}
+
+	event := LogEvent{
+		Time:    c.now(),
+		Level:   level,
+		Name:    name,
+		Message: message,
+		Fields:  make(map[string]interface{}, len(fields)/2),
+		Err:     err,
+	}
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		if key, ok := fields[i].(string); ok {
+			event.Fields[key] = fields[i+1]
+		}
+	}
+
+	c.opts.Logger.Log(event)
+}
+
+// violated reports a write refused by a constraint of the collection, such as a duplicate
+// primary key or a value of the wrong type, and returns the error.
+func (txn *Txn) violated(err error, fields ...interface{}) error {
+	txn.owner.log(LevelWarn, EventViolation, err.Error(), err, fields...)
+	return err
+}
@@ -0,0 +1,196 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	logger := new(fakeLogger)
+	col := NewCollection(Options{
+		Logger:   logger,
+		MaxRows:  50000,
+		Eviction: EvictFIFO(),
+	})
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("serial", ForKey()))
+	assert.NoError(t, col.CreateColumn("age", ForInt()))
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		for i := 0; i < 40000; i++ {
+			txn.InsertObject(Object{"serial": strconv.Itoa(i), "age": i % 100})
+		}
+		return nil
+	}))
+
+	// The index built from the existing rows is reported
+	assert.NoError(t, col.CreateIndex("old", "age", func(r Reader) bool { return r.Int() > 50 }))
+	event, ok := logger.last(EventIndexBuilt)
+	assert.True(t, ok)
+	assert.Equal(t, LevelInfo, event.Level)
+	assert.Equal(t, "old", event.Fields[FieldIndex])
+	assert.Equal(t, "age", event.Fields[FieldColumn])
+	assert.Equal(t, 3, event.Fields[FieldChunks])
+
+	// The snapshot reports its progress for every chunk, then its completion
+	assert.NoError(t, col.Snapshot(new(bytes.Buffer)))
+	progress := logger.all(EventSnapshot)
+	assert.Len(t, progress, 4)
+	for i, event := range progress[:3] {
+		assert.Equal(t, LevelDebug, event.Level)
+		assert.Equal(t, i, event.Fields[FieldChunk])
+	}
+	assert.Equal(t, LevelInfo, progress[3].Level)
+	assert.Equal(t, "snapshot completed", progress[3].Message)
+
+	// The rows evicted beyond the maximum are reported
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		for i := 40000; i < 50010; i++ {
+			txn.InsertObject(Object{"serial": strconv.Itoa(i)})
+		}
+		return nil
+	}))
+	event, ok = logger.last(EventEviction)
+	assert.True(t, ok)
+	assert.Equal(t, 10, event.Fields[FieldRows])
+	assert.Equal(t, 50000, event.Fields[FieldLimit])
+}
+
+func TestLoggerViolation(t *testing.T) {
+	logger := new(fakeLogger)
+	col := NewCollection(Options{Logger: logger})
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("serial", ForKey()))
+	assert.NoError(t, col.CreateColumn("age", ForInt()))
+	assert.NoError(t, insertObject(col, Object{"serial": "a", "age": 30}))
+
+	// A duplicate key is reported along with the key
+	assert.ErrorIs(t, insertObject(col, Object{"serial": "a"}), ErrDuplicateKey)
+	event, _ := logger.last(EventViolation)
+	assert.Equal(t, LevelWarn, event.Level)
+	assert.Equal(t, "a", event.Fields[FieldKey])
+	assert.ErrorIs(t, event.Err, ErrDuplicateKey)
+
+	// A value of the wrong type is reported along with the column
+	assert.ErrorIs(t, insertObject(col, Object{"serial": "b", "age": "old"}), ErrTypeMismatch)
+	event, _ = logger.last(EventViolation)
+	assert.Equal(t, "age", event.Fields[FieldColumn])
+	assert.ErrorIs(t, event.Err, ErrTypeMismatch)
+
+	// A change vetoed by a hook is reported along with the row
+	errTooOld := errors.New("too old")
+	col.BeforeUpdate(func(txn *Txn, change Change) error {
+		return errTooOld
+	})
+	assert.ErrorIs(t, col.QueryKey("a", func(r Row) error {
+		r.SetInt("age", 130)
+		return nil
+	}), errTooOld)
+	event, _ = logger.last(EventViolation)
+	assert.Equal(t, uint32(0), event.Fields[FieldRow])
+	assert.Len(t, logger.all(EventViolation), 3)
+}
+
+func TestLoggerHistory(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	logger := new(fakeLogger)
+	col := NewCollection(Options{Logger: logger, Clock: clock, Retention: time.Hour})
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+	assert.NoError(t, insertObject(col, Object{"name": "Roman"}))
+
+	// A commit which fails to be applied on the base of the history is reported and kept
+	col.history.base.Freeze()
+	clock.Advance(2 * time.Hour)
+	assert.NoError(t, insertObject(col, Object{"name": "Merlin"}))
+	event, ok := logger.last(EventHistory)
+	assert.True(t, ok)
+	assert.Equal(t, LevelError, event.Level)
+	assert.ErrorIs(t, event.Err, ErrReadOnly)
+	assert.NoError(t, col.QueryAsOf(start, func(txn *Txn) error {
+		assert.Equal(t, 1, txn.Count())
+		return nil
+	}))
+	assert.Len(t, col.history.changes, 2)
+
+	// It is applied again with the next commit
+	atomic.StoreInt32(&col.history.base.frozen, 0)
+	clock.Advance(2 * time.Hour)
+	assert.NoError(t, insertObject(col, Object{"name": "Alice"}))
+	assert.Len(t, logger.all(EventHistory), 1)
+	assert.Len(t, col.history.changes, 1)
+	assert.Error(t, col.QueryAsOf(start, func(txn *Txn) error {
+		return nil
+	}))
+}
+
+func TestLoggerVacuum(t *testing.T) {
+	logger := new(fakeLogger)
+	col := NewCollection(Options{
+		Logger: logger,
+		Vacuum: 10 * time.Millisecond,
+	})
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+	col.InsertObjectWithTTL(Object{"name": "Roman"}, time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		for _, event := range logger.all(EventVacuum) {
+			if event.Fields[FieldRows] == 1 {
+				return event.Level == LevelDebug
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestLogLevel(t *testing.T) {
+	assert.Equal(t, "debug", LevelDebug.String())
+	assert.Equal(t, "info", LevelInfo.String())
+	assert.Equal(t, "warn", LevelWarn.String())
+	assert.Equal(t, "error", LevelError.String())
+}
+
+// fakeLogger represents a logger which records the events
+type fakeLogger struct {
+	lock   sync.Mutex
+	events []LogEvent
+}
+
+// Log records the event
+func (l *fakeLogger) Log(event LogEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.events = append(l.events, event)
+}
+
+// all returns the events recorded with the name
+func (l *fakeLogger) all(name string) (out []LogEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, event := range l.events {
+		if event.Name == name {
+			out = append(out, event)
+		}
+	}
+	return
+}
+
+// last returns the last event recorded with the name
+func (l *fakeLogger) last(name string) (LogEvent, bool) {
+	events := l.all(name)
+	if len(events) == 0 {
+		return LogEvent{}, false
+	}
+	return events[len(events)-1], true
+}
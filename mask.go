@@ -0,0 +1,114 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"io"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Mask represents a redaction function of a column, which returns the value to show to the
+// principal instead of the stored one. A privileged principal can be shown the value as is.
+type Mask func(principal interface{}, value string) string
+
+// MaskColumn sets the mask of a string or enum column, or removes it if nil. The mask is
+// applied when the values are read by a transaction whose context carries a principal, as
+// well as in the snapshots created with SnapshotMasked(), while the stored values remain
+// intact. Filters still evaluate the stored values.
+func (c *Collection) MaskColumn(columnName string, fn Mask) error {
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return errorOf(ErrColumnNotFound, "column: unable to mask, column '%v' does not exist", columnName)
+	}
+
+	switch column.Column.(type) {
+	case *columnString, *columnEnum:
+	default:
+		return errorOf(ErrTypeMismatch, "column: unable to mask, column '%v' is not of type string", columnName)
+	}
+
+	// Copy the masks, so that the transactions can keep using the previous ones
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	masks := make(map[string]Mask, len(c.masks)+1)
+	for name, mask := range c.masks {
+		masks[name] = mask
+	}
+
+	if fn != nil {
+		masks[columnName] = fn
+	} else {
+		delete(masks, columnName)
+	}
+
+	c.masks = masks
+	return nil
+}
+
+// SnapshotMasked writes a snapshot of the collection into the underlying writer, where the
+// values of the masked columns are replaced with their masked values for the principal.
+// This allows to share a copy of the data, for example with a development environment,
+// without disclosing the raw values.
+func (c *Collection) SnapshotMasked(dst io.Writer, principal interface{}) error {
+	c.lock.RLock()
+	masks := c.masks
+	c.lock.RUnlock()
+
+	view, err := c.clone()
+	if err != nil {
+		return err
+	}
+
+	defer view.Close()
+	if err := view.Query(func(txn *Txn) error {
+		for columnName, fn := range masks {
+			column, ok := txn.columnAt(columnName)
+			if !ok {
+				continue
+			}
+
+			writer := txn.bufferFor(columnName)
+			reader := column.Column.(Textual)
+			txn.Range(func(idx uint32) {
+				if v, ok := reader.LoadString(idx); ok {
+					writer.PutString(commit.Put, idx, fn(principal, v))
+				}
+			})
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return view.Snapshot(dst)
+}
+
+// --------------------------- Masking ----------------------------
+
+// masking represents the mask of a column for the principal of a transaction
+type masking struct {
+	mask      Mask
+	principal interface{}
+}
+
+// maskOf returns the masking of a column for the transaction.
+func (txn *Txn) maskOf(columnName string) masking {
+	if txn.masks == nil {
+		return masking{}
+	}
+
+	return masking{
+		mask:      txn.masks[columnName],
+		principal: txn.principal,
+	}
+}
+
+// apply applies the mask to the value, if there is one
+func (m masking) apply(v string, ok bool) (string, bool) {
+	if m.mask != nil && ok {
+		v = m.mask(m.principal, v)
+	}
+	return v, ok
+}
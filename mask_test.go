@@ -0,0 +1,92 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskColumn(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("email", ForString())
+	col.CreateColumn("country", ForEnum())
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	for i := 0; i < 10; i++ {
+		col.Insert(func(r Row) error {
+			r.SetString("email", "roman@example.com")
+			r.SetEnum("country", "FR")
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	redact := func(principal interface{}, v string) string {
+		if principal == "admin" {
+			return v
+		}
+		return "***"
+	}
+
+	assert.Error(t, col.MaskColumn("missing", redact))
+	assert.Error(t, col.MaskColumn("age", redact))
+	assert.NoError(t, col.MaskColumn("email", redact))
+	assert.NoError(t, col.MaskColumn("country", redact))
+
+	read := func(ctx context.Context) (email, country string, any interface{}) {
+		assert.NoError(t, col.QueryContext(ctx, func(txn *Txn) error {
+			return txn.QueryAt(0, func(r Row) error {
+				email, _ = r.String("email")
+				country, _ = r.Enum("country")
+				any, _ = r.Any("email")
+				return nil
+			})
+		}))
+		return
+	}
+
+	// Unprivileged principals only see the masked values
+	email, country, any := read(WithPrincipal(context.Background(), "dev"))
+	assert.Equal(t, "***", email)
+	assert.Equal(t, "***", country)
+	assert.Equal(t, "***", any)
+
+	email, country, _ = read(WithPrincipal(context.Background(), "admin"))
+	assert.Equal(t, "roman@example.com", email)
+	assert.Equal(t, "FR", country)
+
+	email, _, _ = read(context.Background())
+	assert.Equal(t, "roman@example.com", email)
+
+	// Snapshots for a principal contain the masked values only
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, col.SnapshotMasked(buffer, "dev"))
+
+	other := NewCollection()
+	other.CreateColumn("email", ForString())
+	other.CreateColumn("country", ForEnum())
+	other.CreateColumn("age", ForInt())
+	defer other.Close()
+	assert.NoError(t, other.Restore(buffer))
+	assert.Equal(t, 10, other.Count())
+	assert.NoError(t, other.QueryAt(3, func(r Row) error {
+		email, _ := r.String("email")
+		age, _ := r.Int("age")
+		assert.Equal(t, "***", email)
+		assert.Equal(t, 3, age)
+		return nil
+	}))
+
+	// The stored values remain intact, and the mask can be removed
+	email, _, _ = read(context.Background())
+	assert.Equal(t, "roman@example.com", email)
+	assert.NoError(t, col.MaskColumn("email", nil))
+	email, _, _ = read(WithPrincipal(context.Background(), "dev"))
+	assert.Equal(t, "roman@example.com", email)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"unsafe"
+
+	"github.com/kelindar/bitmap"
+)
+
+// MemoryUsage represents an estimate of the memory used by a collection, in bytes.
+type MemoryUsage struct {
+	Fill    int                    // The fill list of the collection
+	Arena   int                    // The string arena shared by the columns
+	Columns map[string]ColumnUsage // The memory used by each column and index
+}
+
+// Total returns the total memory used by the collection, in bytes.
+func (u *MemoryUsage) Total() int {
+	total := u.Fill + u.Arena
+	for _, c := range u.Columns {
+		total += c.Total()
+	}
+	return total
+}
+
+// ColumnUsage represents an estimate of the memory used by a column, in bytes.
+type ColumnUsage struct {
+	Data       int // The values stored in the column
+	Dictionary int // The dictionary of the distinct values, for enums and keys
+	Index      int // The bitmaps, such as the fill list or the bitmap index
+}
+
+// Total returns the total memory used by the column, in bytes.
+func (u ColumnUsage) Total() int {
+	return u.Data + u.Dictionary + u.Index
+}
+
+// measurable represents a column which is able to estimate its memory usage
+type measurable interface {
+	usage() ColumnUsage
+}
+
+// MemoryUsage estimates the memory used by the collection and each of its columns. The
+// estimate accounts for the allocated capacity rather than the number of rows.
+func (c *Collection) MemoryUsage() MemoryUsage {
+	c.lock.RLock()
+	usage := MemoryUsage{
+		Fill:    sizeOfBitmap(c.fill),
+		Arena:   c.arena.usage(),
+		Columns: make(map[string]ColumnUsage, c.cols.Count()),
+	}
+	c.lock.RUnlock()
+
+	c.cols.Range(func(v *column) {
+		usage.Columns[v.name] = usageOf(v)
+	})
+	return usage
+}
+
+// usageOf estimates the memory used by a column
+func usageOf(v *column) ColumnUsage {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if column, ok := v.Column.(measurable); ok {
+		return column.usage()
+	}
+
+	return ColumnUsage{
+		Index: sizeOfBitmap(*v.Column.Index()),
+	}
+}
+
+// sizeOfBitmap returns the allocated size of a bitmap
+func sizeOfBitmap(v bitmap.Bitmap) int {
+	return cap(v) * 8
+}
+
+// sizeOfStrings returns the allocated size of a slice of strings, including the strings
+func sizeOfStrings(v []string) int {
+	size := cap(v) * int(unsafe.Sizeof(""))
+	for _, s := range v {
+		size += len(s)
+	}
+	return size
+}
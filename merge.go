@@ -0,0 +1,162 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"reflect"
+
+	"github.com/kelindar/column/commit"
+)
+
+// MergeFunc represents a function which combines the current value of a row with a delta
+// merged into it, and returns the new value. The value is nil if the row has no value yet,
+// and both the value and the delta are of the type of the column, for example float64 for
+// a ForFloat64() column. The returned value must be of the same type, or nil to remove it.
+type MergeFunc func(value, delta interface{}) interface{}
+
+// merger holds the merge function of a column, if any
+type merger struct {
+	merge MergeFunc
+}
+
+// mergeFunc returns the merge function of the column
+func (m *merger) mergeFunc() MergeFunc {
+	return m.merge
+}
+
+// mergeOf returns the merge function of a column, if any
+func mergeOf(column Column) MergeFunc {
+	if c, ok := column.(interface{ mergeFunc() MergeFunc }); ok {
+		return c.mergeFunc()
+	}
+	return nil
+}
+
+// mergeOf returns the merge function of a column of the collection, if any
+func (c *Collection) mergeOf(columnName string) MergeFunc {
+	if column, ok := c.cols.Load(columnName); ok {
+		return mergeOf(column.Column)
+	}
+	return nil
+}
+
+// Merge merges a delta into the value at the current transaction cursor, with the merge
+// function of the column. The delta is converted to the type of the column and, since it
+// is only merged once the transaction is committed, the concurrent transactions merging
+// into the same value do not overwrite each other.
+func (s anyWriter) Merge(delta interface{}) {
+	mutation := Merge(s.name, delta)
+	value, err := mutation.valueOf(s.reader)
+	if err != nil {
+		s.txn.abort(err)
+		return
+	}
+
+	s.writer.MergeAny(*s.cursor, value)
+}
+
+// commitMerges resolves the merges of a chunk into the values they produce, by combining
+// their deltas with the current values while the chunk is locked. The updates of the
+// transaction are replaced by the resolved ones until the returned function is called, so
+// that the indexes, the commit log and the history only see the resulting values.
+func (txn *Txn) commitMerges(chunk commit.Chunk) func() {
+	var replaced []int
+	var originals []*commit.Buffer
+	for i, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn || !hasMerges(txn.reader, u, chunk) {
+			continue
+		}
+
+		column, ok := txn.owner.cols.Load(u.Column)
+		if !ok || mergeOf(column.Column) == nil {
+			continue
+		}
+
+		replaced = append(replaced, i)
+		originals = append(originals, u)
+		txn.updates[i] = txn.resolveMerges(column, u, chunk)
+	}
+
+	return func() {
+		for i, at := range replaced {
+			txn.owner.txns.releasePage(txn.updates[at])
+			txn.updates[at] = originals[i]
+		}
+	}
+}
+
+// resolveMerges rewrites the operations of a chunk as the values they produce
+func (txn *Txn) resolveMerges(column *column, u *commit.Buffer, chunk commit.Chunk) *commit.Buffer {
+	merge := mergeOf(column.Column)
+	typ, isNumber := numericTypeOf(column.Column)
+	resolved := txn.owner.txns.acquirePage(u.Column)
+	values := make(map[uint32]interface{}, 8)
+	txn.reader.Range(u, chunk, func(r *commit.Reader) {
+		for r.Next() {
+			idx := r.Index()
+			value := valueOf(column.Column, r)
+			switch r.Type {
+			case commit.Add, commit.Merge:
+				current, ok := values[idx]
+				if !ok {
+					if current, ok = column.Value(idx); !ok {
+						current = nil
+					}
+				}
+
+				if r.Type == commit.Add {
+					value = addValue(current, value)
+				} else {
+					value = merge(current, value)
+				}
+
+				// The merged value must be of the type of the column, or it is left unchanged
+				if value, ok = nativeOf(value, typ, isNumber); !ok {
+					value = current
+				}
+			}
+
+			values[idx] = value
+			if value == nil {
+				resolved.PutOperation(commit.Delete, idx)
+				continue
+			}
+			resolved.PutAny(commit.Put, idx, value)
+		}
+	})
+	return resolved
+}
+
+// hasMerges returns whether a buffer contains merges for a chunk
+func hasMerges(reader *commit.Reader, u *commit.Buffer, chunk commit.Chunk) (found bool) {
+	reader.Range(u, chunk, func(r *commit.Reader) {
+		for !found && r.Next() {
+			found = r.Type == commit.Merge
+		}
+	})
+	return
+}
+
+// nativeOf converts a value to the native type of a numeric column, or checks whether it is
+// a string for the other columns
+func nativeOf(value interface{}, typ reflect.Type, isNumber bool) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, true
+	case string:
+		return v, !isNumber
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case !isNumber:
+		return nil, false
+	case rv.Type() == typ:
+		return value, true
+	case rv.Type().ConvertibleTo(typ) && rv.Kind() != reflect.String && rv.Kind() != reflect.Bool:
+		return rv.Convert(typ).Interface(), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	col := newMergedCollection(nil)
+	defer col.Close()
+	idx := col.InsertObject(Object{"hp": 10.0})
+
+	// Concurrent merges compose with one another
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				col.QueryAt(idx, func(r Row) error {
+					r.Merge("hp", i*50+j)
+					r.Merge("tags", "x")
+					return nil
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		hp, _ := r.Float64("hp")
+		tags, _ := r.String("tags")
+		assert.Equal(t, 399.0, hp)
+		assert.Len(t, tags, 400*2-1)
+		return nil
+	}))
+
+	// The index is computed from the merged value
+	assert.Equal(t, 1, col.Count())
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.With("strong").Count())
+		return nil
+	})
+}
+
+func TestMergeInTransaction(t *testing.T) {
+	col := newMergedCollection(nil)
+	defer col.Close()
+	col.InsertObject(Object{"hp": 10.0, "tags": "a"})
+	col.InsertObject(Object{"hp": 50.0})
+
+	// Multiple merges in the same transaction see each other
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		n, err := txn.Update(Merge("hp", 20), Merge("tags", "b"))
+		assert.Equal(t, 2, n)
+		assert.NoError(t, err)
+
+		_, err = txn.Update(Merge("hp", 30.0), Merge("tags", "c"))
+		return err
+	}))
+
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		hp, _ := r.Float64("hp")
+		tags, _ := r.String("tags")
+		assert.Equal(t, 30.0, hp)
+		assert.Equal(t, "a,b,c", tags)
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(1, func(r Row) error {
+		hp, _ := r.Float64("hp")
+		tags, _ := r.String("tags")
+		assert.Equal(t, 50.0, hp)
+		assert.Equal(t, "b,c", tags)
+		return nil
+	}))
+}
+
+func TestMergeCommitLog(t *testing.T) {
+	writer := make(commit.Channel, 10)
+	col := newMergedCollection(&writer)
+	defer col.Close()
+	col.InsertObject(Object{"hp": 10.0})
+	<-writer
+
+	// The commit log only contains the merged values
+	col.QueryAt(0, func(r Row) error {
+		r.Merge("hp", 5.0)
+		r.Merge("hp", 25.0)
+		return nil
+	})
+
+	var last float64
+	change := <-writer
+	for _, u := range change.Updates {
+		reader := commit.NewReader()
+		reader.Seek(u)
+		for reader.Next() {
+			assert.Equal(t, commit.Put, reader.Type)
+			last = reader.Float64()
+		}
+	}
+	assert.Equal(t, 25.0, last)
+
+	// Replaying the commit gives the same value
+	other := newMergedCollection(nil)
+	defer other.Close()
+	other.InsertObject(Object{"hp": 10.0})
+	assert.NoError(t, other.Replay(change))
+	other.QueryAt(0, func(r Row) error {
+		hp, _ := r.Float64("hp")
+		assert.Equal(t, 25.0, hp)
+		return nil
+	})
+}
+
+func TestMergeTrigger(t *testing.T) {
+	col := newMergedCollection(nil)
+	defer col.Close()
+	col.InsertObject(Object{"hp": 10.0})
+
+	var events []TriggerEvent
+	col.OnUpdate("hp", func(txn *Txn, event TriggerEvent) error {
+		events = append(events, event)
+		return nil
+	})
+
+	col.QueryAt(0, func(r Row) error {
+		r.Merge("hp", 15)
+		return nil
+	})
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, 10.0, events[0].Old["hp"])
+	assert.Equal(t, 15.0, events[0].New["hp"])
+}
+
+func TestMergeErrors(t *testing.T) {
+	col := newMergedCollection(nil)
+	col.CreateColumn("age", ForInt())
+	col.CreateColumn("odd", ForInt(WithMerge(func(value, delta interface{}) interface{} {
+		return "not a number"
+	})))
+	defer col.Close()
+	col.InsertObject(Object{"age": 10, "odd": 5})
+
+	// Columns without a merge function can not be merged into
+	assert.Error(t, col.Query(func(txn *Txn) error {
+		_, err := txn.Update(Merge("age", 1))
+		return err
+	}))
+
+	// A merged value of the wrong type is ignored
+	col.QueryAt(0, func(r Row) error {
+		r.Merge("odd", 1)
+		return nil
+	})
+	col.QueryAt(0, func(r Row) error {
+		odd, _ := r.Int("odd")
+		assert.Equal(t, 5, odd)
+		return nil
+	})
+
+	// The merge function is kept when the column is copied
+	column, _ := col.cols.Load("hp")
+	empty, err := makeEmpty(column.Column)
+	assert.NoError(t, err)
+	assert.NotNil(t, mergeOf(empty))
+}
+
+// newMergedCollection creates a collection with columns merged with custom functions
+func newMergedCollection(writer commit.Logger) *Collection {
+	col := NewCollection(Options{Writer: writer})
+	col.CreateColumn("hp", ForFloat64(WithMerge(func(value, delta interface{}) interface{} {
+		if value == nil || delta.(float64) > value.(float64) {
+			return delta
+		}
+		return value
+	})))
+	col.CreateColumn("tags", ForString(WithMerge(func(value, delta interface{}) interface{} {
+		if value == nil {
+			return delta
+		}
+		return value.(string) + "," + delta.(string)
+	})))
+	col.CreateIndex("strong", "hp", func(r Reader) bool {
+		return r.Float() >= 100
+	})
+	return col
+}
@@ -0,0 +1,255 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package metrics provides an observer for collections, which exports the metrics in the
+// Prometheus text exposition format or through the expvar package.
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics represents a set of metrics of a single collection. It implements the
+// column.Observer interface and should be set in the options of the collection.
+type Metrics struct {
+	name      string     // The name of the collection
+	queries   counter    // The number of transactions
+	failures  counter    // The number of transactions which were rolled back
+	commits   counter    // The number of commits
+	chunks    counter    // The number of chunks committed
+	scanned   counter    // The number of rows scanned
+	snapshots counter    // The number of snapshots
+	errors    counter    // The number of snapshots which have failed
+	latency   *histogram // The duration of the transactions
+	commit    *histogram // The duration of the commits
+	lockWait  *histogram // The time spent waiting for chunk locks
+	snapshot  *histogram // The duration of the snapshots
+}
+
+// New creates a new set of metrics for a collection with the specified name.
+func New(collection string) *Metrics {
+	return &Metrics{
+		name:     collection,
+		latency:  newHistogram(),
+		commit:   newHistogram(),
+		lockWait: newHistogram(),
+		snapshot: newHistogram(),
+	}
+}
+
+// ObserveQuery is called when a transaction has completed.
+func (m *Metrics) ObserveQuery(duration time.Duration, scanned int, err error) {
+	m.queries.Add(1)
+	m.scanned.Add(uint64(scanned))
+	m.latency.Observe(duration)
+	if err != nil {
+		m.failures.Add(1)
+	}
+}
+
+// ObserveCommit is called when a transaction has been committed.
+func (m *Metrics) ObserveCommit(duration time.Duration, chunks int) {
+	m.commits.Add(1)
+	m.chunks.Add(uint64(chunks))
+	m.commit.Observe(duration)
+}
+
+// ObserveLockWait is called when a chunk read lock was acquired.
+func (m *Metrics) ObserveLockWait(duration time.Duration) {
+	m.lockWait.Observe(duration)
+}
+
+// ObserveSnapshot is called when a snapshot has completed.
+func (m *Metrics) ObserveSnapshot(duration time.Duration, err error) {
+	m.snapshots.Add(1)
+	m.snapshot.Observe(duration)
+	if err != nil {
+		m.errors.Add(1)
+	}
+}
+
+// WriteTo writes the metrics into the destination in the Prometheus text format.
+func (m *Metrics) WriteTo(dst io.Writer) (int64, error) {
+	return write(dst, []*Metrics{m})
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Handler(m).ServeHTTP(w, r)
+}
+
+// String returns the metrics encoded as JSON, so that they can be published as an
+// expvar.Var by calling expvar.Publish().
+func (m *Metrics) String() string {
+	out, _ := json.Marshal(map[string]interface{}{
+		"queries":           m.queries.Load(),
+		"failures":          m.failures.Load(),
+		"commits":           m.commits.Load(),
+		"chunks":            m.chunks.Load(),
+		"scanned":           m.scanned.Load(),
+		"snapshots":         m.snapshots.Load(),
+		"snapshot_errors":   m.errors.Load(),
+		"query_seconds":     m.latency.Sum().Seconds(),
+		"commit_seconds":    m.commit.Sum().Seconds(),
+		"lock_wait_seconds": m.lockWait.Sum().Seconds(),
+		"snapshot_seconds":  m.snapshot.Sum().Seconds(),
+	})
+	return string(out)
+}
+
+// Handler returns an HTTP handler which serves the metrics of several collections in
+// the Prometheus text format.
+func Handler(metrics ...*Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		write(w, metrics)
+	})
+}
+
+// --------------------------- Exposition ----------------------------
+
+// family represents a family of metrics, exported under the same name
+type family struct {
+	name    string
+	help    string
+	counter func(*Metrics) *counter
+	histo   func(*Metrics) *histogram
+}
+
+// families is the list of exported metric families
+var families = []family{
+	{name: "column_queries_total", help: "The number of transactions.", counter: func(m *Metrics) *counter { return &m.queries }},
+	{name: "column_query_failures_total", help: "The number of transactions rolled back.", counter: func(m *Metrics) *counter { return &m.failures }},
+	{name: "column_commits_total", help: "The number of committed transactions.", counter: func(m *Metrics) *counter { return &m.commits }},
+	{name: "column_commit_chunks_total", help: "The number of chunks committed.", counter: func(m *Metrics) *counter { return &m.chunks }},
+	{name: "column_rows_scanned_total", help: "The number of rows scanned.", counter: func(m *Metrics) *counter { return &m.scanned }},
+	{name: "column_snapshots_total", help: "The number of snapshots.", counter: func(m *Metrics) *counter { return &m.snapshots }},
+	{name: "column_snapshot_failures_total", help: "The number of failed snapshots.", counter: func(m *Metrics) *counter { return &m.errors }},
+	{name: "column_query_duration_seconds", help: "The duration of the transactions.", histo: func(m *Metrics) *histogram { return m.latency }},
+	{name: "column_commit_duration_seconds", help: "The duration of the commits.", histo: func(m *Metrics) *histogram { return m.commit }},
+	{name: "column_lock_wait_seconds", help: "The time spent waiting for chunk locks.", histo: func(m *Metrics) *histogram { return m.lockWait }},
+	{name: "column_snapshot_duration_seconds", help: "The duration of the snapshots.", histo: func(m *Metrics) *histogram { return m.snapshot }},
+}
+
+// write writes the metrics of several collections in the Prometheus text format
+func write(dst io.Writer, metrics []*Metrics) (int64, error) {
+	out := &countingWriter{Writer: bufio.NewWriter(dst)}
+	sorted := append([]*Metrics(nil), metrics...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].name < sorted[j].name
+	})
+
+	for _, f := range families {
+		typ := "counter"
+		if f.histo != nil {
+			typ = "histogram"
+		}
+
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, typ)
+		for _, m := range sorted {
+			if f.counter != nil {
+				fmt.Fprintf(out, "%s{collection=%q} %d\n", f.name, m.name, f.counter(m).Load())
+				continue
+			}
+
+			h := f.histo(m)
+			cumulative := uint64(0)
+			for i, le := range buckets {
+				cumulative += atomic.LoadUint64(&h.counts[i])
+				fmt.Fprintf(out, "%s_bucket{collection=%q,le=\"%g\"} %d\n", f.name, m.name, le.Seconds(), cumulative)
+			}
+
+			count := h.Count()
+			fmt.Fprintf(out, "%s_bucket{collection=%q,le=\"+Inf\"} %d\n", f.name, m.name, count)
+			fmt.Fprintf(out, "%s_sum{collection=%q} %g\n", f.name, m.name, h.Sum().Seconds())
+			fmt.Fprintf(out, "%s_count{collection=%q} %d\n", f.name, m.name, count)
+		}
+	}
+
+	return out.n, out.Writer.(*bufio.Writer).Flush()
+}
+
+// countingWriter counts the number of bytes written to the underlying writer.
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+// Write writes the buffer to the underlying writer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// --------------------------- Primitives ----------------------------
+
+// counter represents a monotonically increasing counter
+type counter struct {
+	value uint64
+}
+
+// Add increments the counter
+func (c *counter) Add(delta uint64) {
+	atomic.AddUint64(&c.value, delta)
+}
+
+// Load returns the current value of the counter
+func (c *counter) Load() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// buckets are the upper bounds of the histogram buckets
+var buckets = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// histogram represents a histogram of durations, with fixed buckets
+type histogram struct {
+	counts []uint64 // The number of observations per bucket
+	count  uint64   // The total number of observations
+	sum    int64    // The sum of the observations, in nanoseconds
+}
+
+// newHistogram creates a new histogram
+func newHistogram() *histogram {
+	return &histogram{
+		counts: make([]uint64, len(buckets)),
+	}
+}
+
+// Observe records a single observation
+func (h *histogram) Observe(v time.Duration) {
+	for i, le := range buckets {
+		if v <= le {
+			atomic.AddUint64(&h.counts[i], 1)
+			break
+		}
+	}
+
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(v))
+}
+
+// Count returns the number of observations
+func (h *histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Sum returns the sum of the observations
+func (h *histogram) Sum() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.sum))
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+// Assert the observer implementation
+var _ column.Observer = new(Metrics)
+
+func TestMetrics(t *testing.T) {
+	metrics := New("players")
+	players := column.NewCollection(column.Options{
+		Observer: metrics,
+	})
+	players.CreateColumn("name", column.ForString())
+
+	for i := 0; i < 10; i++ {
+		players.InsertObject(column.Object{"name": fmt.Sprintf("player %d", i)})
+	}
+
+	// Scan all of the rows and then fail a transaction
+	assert.NoError(t, players.Query(func(txn *column.Txn) error {
+		return txn.Range(func(idx uint32) {})
+	}))
+	assert.Error(t, players.Query(func(txn *column.Txn) error {
+		return fmt.Errorf("rollback")
+	}))
+
+	assert.NoError(t, players.Snapshot(bytes.NewBuffer(nil)))
+	assert.Equal(t, uint64(12), metrics.queries.Load())
+	assert.Equal(t, uint64(1), metrics.failures.Load())
+	assert.Equal(t, uint64(11), metrics.commits.Load())
+	assert.Equal(t, uint64(10), metrics.chunks.Load())
+	assert.Equal(t, uint64(10), metrics.scanned.Load())
+	assert.Equal(t, uint64(1), metrics.snapshots.Load())
+	assert.NotZero(t, metrics.lockWait.Count())
+
+	// Prometheus text format
+	buffer := bytes.NewBuffer(nil)
+	n, err := metrics.WriteTo(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buffer.Len()), n)
+	assert.Contains(t, buffer.String(), `column_queries_total{collection="players"} 12`)
+	assert.Contains(t, buffer.String(), `column_query_duration_seconds_count{collection="players"} 12`)
+	assert.Contains(t, buffer.String(), `# TYPE column_lock_wait_seconds histogram`)
+
+	// Expvar format
+	var out map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(metrics.String()), &out))
+	assert.Equal(t, float64(12), out["queries"])
+}
+
+func TestHandler(t *testing.T) {
+	a, b := New("a"), New("b")
+	a.ObserveCommit(time.Millisecond, 1)
+	b.ObserveQuery(time.Second, 100, nil)
+
+	w := httptest.NewRecorder()
+	Handler(b, a).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, 1, strings.Count(string(body), "# TYPE column_commits_total counter"))
+	assert.Contains(t, string(body), `column_commits_total{collection="a"} 1`)
+	assert.Contains(t, string(body), `column_rows_scanned_total{collection="b"} 100`)
+	assert.Contains(t, string(body), `column_query_duration_seconds_bucket{collection="b",le="1"} 1`)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+}
@@ -0,0 +1,299 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package migrate provides versioned migrations of the schema of a collection. Each
+// migration is a list of steps, such as adding a column with a backfill, renaming a
+// column, changing its type or creating an index. The changes to the rows are applied
+// one chunk at a time, with a transaction per chunk, and the progress is recorded in a
+// property of the collection, which is written in its snapshots. A migration which was
+// interrupted resumes from the last chunk recorded, once the snapshot is restored.
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kelindar/column"
+)
+
+// property is the name of the property holding the progress of the migrations
+const property = "migrate"
+
+// Migration represents a versioned change of the schema of a collection.
+type Migration struct {
+	Version int    // The version of the schema once migrated, which must be positive
+	Name    string // The description of the migration
+	Steps   []Step // The steps of the migration, applied in order
+}
+
+// Constructor creates a column, such as column.ForInt or column.ForString. A constructor
+// rather than a column is required, since a column may need to be created several times.
+type Constructor = func(opts ...column.ColumnOption) column.Column
+
+// Step represents a step of a migration, created by one of the functions of the package.
+type Step struct {
+	ops []operation
+}
+
+// operation represents either a change of the schema, or a change of every row
+type operation struct {
+	schema func(c *column.Collection) error // The change of the schema, applied at once
+	row    func(r column.Row) error         // The change of a row, applied one chunk at a time
+}
+
+// AddColumn returns a step which creates a column and, unless the backfill function is
+// nil, calls it for every existing row in order to set its initial value. Since the
+// backfill of a chunk may be applied again when a migration resumes, it must produce
+// the same result when called twice on a row.
+func AddColumn(name string, fn Constructor, backfill func(r column.Row) error) Step {
+	step := Step{ops: []operation{create(name, fn)}}
+	if backfill != nil {
+		step.ops = append(step.ops, operation{row: backfill})
+	}
+	return step
+}
+
+// DropColumn returns a step which removes a column or an index.
+func DropColumn(name string) Step {
+	return Step{ops: []operation{drop(name)}}
+}
+
+// RenameColumn returns a step which moves the values of a column into a new column, with
+// the specified type, and then removes the previous column.
+func RenameColumn(from, to string, fn Constructor) Step {
+	return Step{ops: []operation{
+		create(to, fn),
+		copyValues(from, to, nil),
+		drop(from),
+	}}
+}
+
+// ChangeType returns a step which changes the type of a column. The values are converted
+// into a temporary column of the new type, which then replaces the column. The values for
+// which the conversion returns false are removed.
+func ChangeType(name string, fn Constructor, convert func(v interface{}) (interface{}, bool)) Step {
+	temp := name + "~"
+	return Step{ops: []operation{
+		create(temp, fn),
+		copyValues(name, temp, convert),
+		drop(name),
+		create(name, fn),
+		copyValues(temp, name, nil),
+		drop(temp),
+	}}
+}
+
+// CreateIndex returns a step which creates a bitmap index on a column. The index is
+// computed over the existing rows when it is created.
+func CreateIndex(name, columnName string, fn func(r column.Reader) bool) Step {
+	return Step{ops: []operation{{
+		schema: func(c *column.Collection) error {
+			return c.CreateIndex(name, columnName, fn)
+		},
+	}}}
+}
+
+// create returns an operation which creates a column
+func create(name string, fn Constructor) operation {
+	return operation{
+		schema: func(c *column.Collection) error {
+			return c.CreateColumn(name, fn())
+		},
+	}
+}
+
+// drop returns an operation which removes a column
+func drop(name string) operation {
+	return operation{
+		schema: func(c *column.Collection) error {
+			c.DropColumn(name)
+			return nil
+		},
+	}
+}
+
+// copyValues returns an operation which copies the values of a column into another one,
+// converting them if a conversion is specified
+func copyValues(from, to string, convert func(v interface{}) (interface{}, bool)) operation {
+	return operation{
+		row: func(r column.Row) error {
+			v, ok := r.Any(from)
+			if ok && convert != nil {
+				v, ok = convert(v)
+			}
+
+			if ok {
+				r.SetAny(to, v)
+			}
+			return nil
+		},
+	}
+}
+
+// --------------------------- Migrator ----------------------------
+
+// Migrator represents a set of migrations, ordered by their version.
+type Migrator struct {
+	migrations []Migration
+}
+
+// New creates a migrator for the migrations, which must have distinct positive versions.
+func New(migrations ...Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		switch {
+		case m.Version <= 0:
+			return nil, fmt.Errorf("migrate: version of '%s' must be positive", m.Name)
+		case i > 0 && sorted[i-1].Version == m.Version:
+			return nil, fmt.Errorf("migrate: version %d is declared twice", m.Version)
+		}
+	}
+
+	return &Migrator{migrations: sorted}, nil
+}
+
+// Run applies all of the migrations which were not yet applied to the collection, in the
+// order of their versions. If a migration was interrupted, it resumes from the last step
+// and chunk recorded in the collection.
+func (m *Migrator) Run(c *column.Collection) error {
+	at := stateOf(c)
+	for _, migration := range m.migrations {
+		if migration.Version <= at.version {
+			continue
+		}
+
+		ops := flatten(migration)
+		for ; at.op < len(ops); at.op, at.chunk = at.op+1, 0 {
+			if err := at.apply(c, ops[at.op]); err != nil {
+				return fmt.Errorf("migrate: unable to apply version %d (%s), %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		at = state{version: migration.Version}
+		at.save(c)
+	}
+	return nil
+}
+
+// Restore restores a snapshot into a collection created with the schema which precedes
+// all of the migrations. Since the snapshots do not carry the schema, the progress of
+// the migrations recorded in the snapshot is read first, and the changes of the schema
+// made up to that point are applied to the collection before the snapshot is restored.
+// Once restored, Run() resumes the migrations. The options are used to read the snapshot,
+// for example if it is encrypted.
+func (m *Migrator) Restore(c *column.Collection, snapshot io.ReadSeeker, opts ...column.Options) error {
+	scratch := column.NewCollection(opts...)
+	defer scratch.Close()
+	if err := scratch.Restore(snapshot); err != nil {
+		return err
+	}
+
+	// Apply the changes of the schema made up to the recorded progress
+	at := stateOf(scratch)
+	for _, migration := range m.migrations {
+		ops := flatten(migration)
+		if migration.Version > at.version {
+			ops = ops[:min(at.op, len(ops))]
+		}
+
+		for _, op := range ops {
+			if op.schema != nil {
+				if err := op.schema(c); err != nil {
+					return err
+				}
+			}
+		}
+
+		if migration.Version > at.version {
+			break
+		}
+	}
+
+	if _, err := snapshot.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return c.Restore(snapshot)
+}
+
+// Version returns the version of the last migration applied to the collection, or zero.
+func Version(c *column.Collection) int {
+	return stateOf(c).version
+}
+
+// flatten returns the operations of all of the steps of a migration
+func flatten(m Migration) (out []operation) {
+	for _, step := range m.Steps {
+		out = append(out, step.ops...)
+	}
+	return
+}
+
+// --------------------------- State ----------------------------
+
+// state represents the progress of the migrations of a collection
+type state struct {
+	version int // The version of the last migration applied
+	op      int // The next operation of the migration in progress
+	chunk   int // The next chunk of the operation in progress
+}
+
+// stateOf reads the progress of the migrations recorded in the collection
+func stateOf(c *column.Collection) (s state) {
+	value, ok := c.Property(property)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(value, "/")
+	if len(parts) == 3 {
+		s.version, _ = strconv.Atoi(parts[0])
+		s.op, _ = strconv.Atoi(parts[1])
+		s.chunk, _ = strconv.Atoi(parts[2])
+	}
+	return
+}
+
+// save records the progress of the migrations in the collection
+func (s state) save(c *column.Collection) {
+	c.SetProperty(property, fmt.Sprintf("%d/%d/%d", s.version, s.op, s.chunk))
+}
+
+// apply applies an operation to the collection, from the chunk in progress, and records
+// the progress after every chunk
+func (s *state) apply(c *column.Collection, op operation) error {
+	if op.schema != nil {
+		if err := op.schema(c); err != nil {
+			return err
+		}
+
+		(&state{version: s.version, op: s.op + 1}).save(c)
+		return nil
+	}
+
+	for ; s.chunk < c.Chunks(); s.chunk++ {
+		if err := c.QueryChunk(s.chunk, func(txn *column.Txn) (err error) {
+			txn.Rows()(func(_ uint32, r column.Row) bool {
+				err = op.row(r)
+				return err == nil
+			})
+			return err
+		}); err != nil {
+			return err
+		}
+
+		(&state{version: s.version, op: s.op, chunk: s.chunk + 1}).save(c)
+	}
+	return nil
+}
+
+// min returns the smaller of two integers
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package migrate
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	users := newUsers(20000)
+	defer users.Close()
+	assert.Equal(t, 2, users.Chunks())
+
+	m, err := New(migrations()...)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, Version(users))
+	assert.NoError(t, m.Run(users))
+	assert.Equal(t, 3, Version(users))
+	assertMigrated(t, users, 20000)
+
+	// Running again does nothing
+	assert.NoError(t, m.Run(users))
+	assert.Equal(t, 3, Version(users))
+}
+
+func TestMigrateResume(t *testing.T) {
+	users := newUsers(20000)
+	defer users.Close()
+
+	// Fail the backfill on the second chunk, the first one is recorded
+	failing := true
+	m, err := New(Migration{Version: 1, Name: "add score", Steps: []Step{
+		AddColumn("score", column.ForInt, func(r column.Row) error {
+			if age, _ := r.Int("age"); failing && age >= 16384 {
+				return assert.AnError
+			}
+
+			age, _ := r.Int("age")
+			r.SetInt("score", age*2)
+			return nil
+		}),
+	}})
+	assert.NoError(t, err)
+	assert.Error(t, m.Run(users))
+	assert.Equal(t, 0, Version(users))
+	progress, _ := users.Property("migrate")
+	assert.Equal(t, "0/1/1", progress)
+
+	// Snapshot the collection in the middle of the migration
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, users.Snapshot(buffer))
+
+	// Restore into the original schema and resume the migration
+	restored := newUsers(0)
+	defer restored.Close()
+	assert.NoError(t, m.Restore(restored, bytes.NewReader(buffer.Bytes())))
+	assert.Equal(t, 16384, count(restored, "score"))
+
+	failing = false
+	assert.NoError(t, m.Run(restored))
+	assert.Equal(t, 1, Version(restored))
+	restored.Query(func(txn *column.Txn) error {
+		score := txn.Int("score")
+		age := txn.Int("age")
+		return txn.Range(func(idx uint32) {
+			a, _ := age.Get()
+			s, ok := score.Get()
+			assert.True(t, ok)
+			assert.Equal(t, a*2, s)
+		})
+	})
+}
+
+func TestMigrateRestore(t *testing.T) {
+	users := newUsers(1000)
+	defer users.Close()
+
+	m, err := New(migrations()...)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Run(users))
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, users.Snapshot(buffer))
+
+	restored := newUsers(0)
+	defer restored.Close()
+	assert.NoError(t, m.Restore(restored, bytes.NewReader(buffer.Bytes())))
+	assert.Equal(t, 3, Version(restored))
+	assertMigrated(t, restored, 1000)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Migration{Version: 0})
+	assert.Error(t, err)
+
+	_, err = New(Migration{Version: 1}, Migration{Version: 1})
+	assert.Error(t, err)
+
+	m, err := New(Migration{Version: 2}, Migration{Version: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, m.migrations[0].Version)
+}
+
+// migrations returns the migrations of the users collection
+func migrations() []Migration {
+	return []Migration{{
+		Version: 1,
+		Name:    "add score",
+		Steps: []Step{
+			AddColumn("score", column.ForInt, func(r column.Row) error {
+				age, _ := r.Int("age")
+				r.SetInt("score", age*2)
+				return nil
+			}),
+		},
+	}, {
+		Version: 2,
+		Name:    "rename name and make code numeric",
+		Steps: []Step{
+			RenameColumn("name", "login", column.ForString),
+			ChangeType("code", column.ForInt, func(v interface{}) (interface{}, bool) {
+				n, err := strconv.Atoi(v.(string))
+				return n, err == nil
+			}),
+		},
+	}, {
+		Version: 3,
+		Name:    "index adults",
+		Steps: []Step{
+			CreateIndex("adult", "age", func(r column.Reader) bool {
+				return r.Int() >= 18
+			}),
+		},
+	}}
+}
+
+// assertMigrated asserts that the users collection has the schema of the last version
+func assertMigrated(t *testing.T, users *column.Collection, n int) {
+	assert.Equal(t, n, count(users, "score"))
+	assert.Equal(t, n, count(users, "login"))
+	assert.Equal(t, n, count(users, "code"))
+	assert.Equal(t, n-18, count(users, "adult"))
+	assert.Equal(t, 0, count(users, "name"))
+
+	users.QueryAt(42, func(r column.Row) error {
+		login, _ := r.String("login")
+		code, _ := r.Int("code")
+		score, _ := r.Int("score")
+		assert.Equal(t, "user-42", login)
+		assert.Equal(t, 42, code)
+		assert.Equal(t, 84, score)
+		return nil
+	})
+}
+
+// count returns the number of rows which have a value in a column
+func count(c *column.Collection, columnName string) (n int) {
+	c.Query(func(txn *column.Txn) error {
+		n = txn.With(columnName).Count()
+		return nil
+	})
+	return
+}
+
+// newUsers creates a collection of users, in the schema which precedes the migrations
+func newUsers(n int) *column.Collection {
+	users := column.NewCollection()
+	users.CreateColumn("name", column.ForString())
+	users.CreateColumn("code", column.ForString())
+	users.CreateColumn("age", column.ForInt())
+	users.Query(func(txn *column.Txn) error {
+		for i := 0; i < n; i++ {
+			txn.InsertObject(map[string]interface{}{
+				"name": "user-" + strconv.Itoa(i),
+				"code": strconv.Itoa(i),
+				"age":  i,
+			})
+		}
+		return nil
+	})
+	return users
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Mirror represents an eventually-consistent replica of a collection within the process,
+// which is fed by the commits of the collection and applied in the background. The queries
+// of a mirror never take the locks of the collection it mirrors, so that the heavy scans
+// run against it do not stall the transactions writing into the collection.
+type Mirror struct {
+	replica *Collection     // The replica the commits are applied to
+	stop    func()          // The function which stops the replication
+	lock    sync.Mutex      // The lock protecting the pending commits
+	cond    *sync.Cond      // The condition signalled once commits are applied
+	pending []commit.Commit // The commits which are not yet applied
+	shipped uint64          // The number of commits shipped to the mirror
+	applied uint64          // The number of commits applied to the mirror
+	signal  chan struct{}   // The signal that commits are pending
+	done    chan struct{}   // The signal that the mirror is closed
+}
+
+// Mirror creates an in-process replica of the collection, with the same columns and indexes,
+// and keeps it up to date with the commits of the collection. The current rows are copied
+// while the transactions are excluded, and every commit is then queued without waiting for
+// the replica to apply it, so the reads of the mirror may lag behind the writes. Columns
+// created once the mirror exists are not mirrored, and the mirror must be closed once it is
+// no longer needed.
+func (c *Collection) Mirror() (*Mirror, error) {
+	replica := NewCollection(Options{
+		Capacity: c.opts.Capacity,
+		Vacuum:   c.opts.Vacuum,
+	})
+
+	if err := replica.copySchema(c); err != nil {
+		replica.Close()
+		return nil, err
+	}
+
+	m := &Mirror{
+		replica: replica,
+		signal:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	m.cond = sync.NewCond(&m.lock)
+
+	stop, err := c.Replicate(m, ReplicaFilter{})
+	if err != nil {
+		replica.Close()
+		return nil, err
+	}
+
+	m.stop = stop
+	go m.run()
+	return m, nil
+}
+
+// Append queues a commit of the mirrored collection, to be applied in the background.
+func (m *Mirror) Append(change commit.Commit) error {
+	m.lock.Lock()
+	m.pending = append(m.pending, change.Clone())
+	m.shipped++
+	m.lock.Unlock()
+
+	select {
+	case m.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run applies the pending commits to the replica until the mirror is closed
+func (m *Mirror) run() {
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-m.signal:
+		}
+
+		m.lock.Lock()
+		pending := m.pending
+		m.pending = nil
+		m.lock.Unlock()
+
+		for _, change := range pending {
+			m.replica.Replay(change)
+		}
+
+		m.lock.Lock()
+		m.applied += uint64(len(pending))
+		m.cond.Broadcast()
+		m.lock.Unlock()
+	}
+}
+
+// Query performs a read-only query against the mirror, which reflects the commits applied
+// so far.
+func (m *Mirror) Query(fn func(txn *Txn) error) error {
+	return m.replica.Query(fn)
+}
+
+// Count returns the number of rows of the mirror.
+func (m *Mirror) Count() int {
+	return m.replica.Count()
+}
+
+// Lag returns the number of commits of the mirrored collection which are not yet applied.
+func (m *Mirror) Lag() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return int(m.shipped - m.applied)
+}
+
+// Sync waits until all of the commits of the mirrored collection shipped before the call are
+// applied, so that the next queries read them.
+func (m *Mirror) Sync() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for target := m.shipped; m.applied < target; {
+		select {
+		case <-m.done:
+			return
+		default:
+			m.cond.Wait()
+		}
+	}
+}
+
+// Close stops the mirroring and releases the replica.
+func (m *Mirror) Close() error {
+	m.stop()
+	close(m.done)
+
+	m.lock.Lock()
+	m.cond.Broadcast()
+	m.lock.Unlock()
+	return m.replica.Close()
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirror(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	mirror, err := players.Mirror()
+	assert.NoError(t, err)
+	defer mirror.Close()
+
+	// The current rows and indexes are mirrored
+	mirror.Sync()
+	assert.Equal(t, 500, mirror.Count())
+	assert.Equal(t, countWhere(players, isOld), mirrorCount(mirror, isOld))
+
+	// The changes are applied to the mirror eventually
+	players.Query(func(txn *Txn) error {
+		age := txn.Float64("age")
+		return txn.With("human").Range(func(idx uint32) {
+			age.Set(99)
+		})
+	})
+	for i := uint32(0); i < 10; i++ {
+		players.DeleteAt(i)
+	}
+	players.InsertObject(Object{"name": "merlin", "race": "human", "age": 500.0})
+
+	mirror.Sync()
+	assert.Zero(t, mirror.Lag())
+	assert.Equal(t, players.Count(), mirror.Count())
+	assert.Equal(t, countWhere(players, isOld), mirrorCount(mirror, isOld))
+	assert.NoError(t, mirror.Query(func(txn *Txn) error {
+		age := txn.Float64("age")
+		return txn.With("human").Range(func(idx uint32) {
+			v, _ := age.Get()
+			assert.GreaterOrEqual(t, v, 99.0)
+		})
+	}))
+}
+
+func TestMirrorClose(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	mirror, err := players.Mirror()
+	assert.NoError(t, err)
+	assert.NoError(t, mirror.Close())
+
+	// Once closed, the commits are no longer shipped to the mirror
+	lag := mirror.Lag()
+	players.InsertObject(Object{"name": "merlin"})
+	assert.Equal(t, lag, mirror.Lag())
+	mirror.Sync()
+}
+
+// mirrorCount counts the rows of a mirror matching the filter
+func mirrorCount(mirror *Mirror, where func(txn *Txn) *Txn) (count int) {
+	mirror.Query(func(txn *Txn) error {
+		count = where(txn).Count()
+		return nil
+	})
+	return
+}
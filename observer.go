@@ -0,0 +1,212 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives the measurements of the operations performed on a collection, so that
+// they can be exported as metrics. The methods are called synchronously and should return
+// quickly, without blocking.
+type Observer interface {
+	ObserveQuery(duration time.Duration, scanned int, err error) // A transaction has completed
+	ObserveCommit(duration time.Duration, chunks int)            // A transaction has been committed
+	ObserveLockWait(duration time.Duration)                      // A chunk read lock was acquired
+	ObserveSnapshot(duration time.Duration, err error)           // A snapshot has completed
+}
+
+// observe calls the function with the observer of the collection, if there is one.
+func (c *Collection) observe(fn func(Observer)) {
+	if c.opts.Observer != nil {
+		fn(c.opts.Observer)
+	}
+}
+
+// QueryInfo describes a transaction which ran for longer than the slow query threshold.
+type QueryInfo struct {
+	Duration time.Duration // The duration of the transaction
+	Filters  []string      // The filters applied, in their order of execution
+	Scanned  int           // The number of rows scanned
+	Err      error         // The error which aborted the transaction, if any
+}
+
+// observeQuery reports a completed transaction to the observer and returns the information
+// about it if it exceeded the slow query threshold, so it can be reported once the
+// transaction is released.
+func (c *Collection) observeQuery(txn *Txn, duration time.Duration, err error) *QueryInfo {
+	c.observe(func(o Observer) {
+		o.ObserveQuery(duration, txn.scanned, err)
+	})
+
+	if c.opts.OnSlowQuery == nil || duration < c.opts.SlowQuery {
+		return nil
+	}
+
+	filters := make([]string, 0, len(txn.applied))
+	for _, f := range txn.applied {
+		filters = append(filters, f.String())
+	}
+
+	return &QueryInfo{
+		Duration: duration,
+		Filters:  filters,
+		Scanned:  txn.scanned,
+		Err:      err,
+	}
+}
+
+// reportSlow calls the slow query callback, if the transaction was slow.
+func (c *Collection) reportSlow(info *QueryInfo) {
+	if info != nil {
+		c.opts.OnSlowQuery(*info)
+	}
+}
+
+// measured returns whether the transactions need to count the rows they scan.
+func (c *Collection) measured() bool {
+	return c.opts.Observer != nil || c.opts.OnSlowQuery != nil || c.opts.Tracer != nil
+}
+
+// --------------------------- Statistics ----------------------------
+
+// Stats represents the counters of the queries run on a collection since it was created,
+// which help to find the columns which are often scanned and would benefit from an index.
+type Stats struct {
+	Queries uint64                  // The number of transactions completed
+	Failed  uint64                  // The number of transactions which returned an error
+	Filters uint64                  // The number of filters applied
+	Indexed uint64                  // The number of filters applied with a bitmap rather than a scan
+	Scanned uint64                  // The number of rows whose values were scanned by the filters
+	Matched uint64                  // The number of scanned rows which were retained by the filters
+	Pruned  uint64                  // The number of chunks skipped by the range filters, as none of their values could match
+	Columns map[string]uint64       // The number of rows scanned by the filters, by column
+	Access  map[string]ColumnAccess // The number of transactions which read or changed each column and index
+}
+
+// ColumnAccess represents the number of transactions which accessed a column. A transaction
+// which changed the column counts as a write, and one which only filtered or read it as a
+// read, so a column which is neither read nor written is a candidate to be dropped.
+type ColumnAccess struct {
+	Reads  uint64 // The number of transactions which read the column
+	Writes uint64 // The number of transactions which changed the column
+}
+
+// IndexHitRatio returns the fraction of the filters which were applied with a bitmap.
+func (s Stats) IndexHitRatio() float64 {
+	if s.Filters == 0 {
+		return 0
+	}
+	return float64(s.Indexed) / float64(s.Filters)
+}
+
+// MatchRatio returns the fraction of the scanned rows which were retained by the filters.
+// A low ratio on a frequently scanned column suggests that it is missing an index.
+func (s Stats) MatchRatio() float64 {
+	if s.Scanned == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(s.Scanned)
+}
+
+// counters represents the counters of the queries of a collection
+type counters struct {
+	queries uint64
+	failed  uint64
+	filters uint64
+	indexed uint64
+	scanned uint64
+	matched uint64
+	pruned  uint64
+	lock    sync.Mutex
+	columns map[string]uint64
+}
+
+// query counts a completed transaction
+func (c *counters) query(err error) {
+	atomic.AddUint64(&c.queries, 1)
+	if err != nil {
+		atomic.AddUint64(&c.failed, 1)
+	}
+}
+
+// filter counts a filter applied, along with the rows it scanned and retained
+func (c *counters) filter(f *filter, input, output uint64) {
+	atomic.AddUint64(&c.filters, 1)
+	if f.indexed() {
+		atomic.AddUint64(&c.indexed, 1)
+		return
+	}
+
+	atomic.AddUint64(&c.scanned, input)
+	atomic.AddUint64(&c.matched, output)
+	c.lock.Lock()
+	if c.columns == nil {
+		c.columns = make(map[string]uint64, 8)
+	}
+	c.columns[f.column] += input
+	c.lock.Unlock()
+}
+
+// Stats returns the counters of the queries run on the collection since it was created.
+func (c *Collection) Stats() Stats {
+	c.queries.lock.Lock()
+	columns := make(map[string]uint64, len(c.queries.columns))
+	for name, scanned := range c.queries.columns {
+		columns[name] = scanned
+	}
+	c.queries.lock.Unlock()
+
+	return Stats{
+		Queries: atomic.LoadUint64(&c.queries.queries),
+		Failed:  atomic.LoadUint64(&c.queries.failed),
+		Filters: atomic.LoadUint64(&c.queries.filters),
+		Indexed: atomic.LoadUint64(&c.queries.indexed),
+		Scanned: atomic.LoadUint64(&c.queries.scanned),
+		Matched: atomic.LoadUint64(&c.queries.matched),
+		Pruned:  atomic.LoadUint64(&c.queries.pruned),
+		Columns: columns,
+		Access:  c.access(),
+	}
+}
+
+// access returns the number of transactions which read or changed each of the columns
+func (c *Collection) access() map[string]ColumnAccess {
+	out := make(map[string]ColumnAccess, c.cols.Count())
+	c.cols.Range(func(v *column) {
+		out[v.name] = ColumnAccess{
+			Reads:  atomic.LoadUint64(&v.reads),
+			Writes: atomic.LoadUint64(&v.writes),
+		}
+	})
+	return out
+}
+
+// countAccess counts the columns accessed by the transaction since its last commit or
+// rollback, as a write for the columns it changed and as a read for the other ones.
+func (txn *Txn) countAccess() {
+	for _, u := range txn.updates {
+		if column, ok := txn.columnAt(u.Column); ok && !u.IsEmpty() {
+			atomic.AddUint64(&column.writes, 1)
+		}
+	}
+
+	for _, v := range txn.columns {
+		if !txn.changed(v.name) {
+			atomic.AddUint64(&v.col.reads, 1)
+		}
+	}
+}
+
+// changed returns whether the transaction has pending changes for a column
+func (txn *Txn) changed(columnName string) bool {
+	for _, u := range txn.updates {
+		if u.Column == columnName && !u.IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	planCacheSize = 1024 // The maximum number of query shapes whose plan is kept
+	planDrift     = 0.05 // The change of selectivity after which the plans are made again
+)
+
+// planCache represents the plans of the query shapes which were filtered before, so that a
+// query chaining the same filters on the same columns, whatever their values, skips the
+// planning. The plans are stale once the schema of the collection changes, or once the
+// statistics the planner relies on drift from the ones the plans were made with.
+type planCache struct {
+	lock    sync.RWMutex          // The lock protecting the plans
+	schema  uint64                // The version of the schema (atomic)
+	epoch   uint64                // The version of the schema and of the statistics (atomic)
+	entries map[uint64]*queryPlan // The plans, by the fingerprint of their shape
+}
+
+// queryPlan represents the order in which the filters of a query shape are applied
+type queryPlan struct {
+	epoch uint64    // The version of the schema and statistics the plan was made with
+	shape []statKey // The kinds and columns of the filters, in their chained order
+	ranks []float64 // The rank of every filter, in their chained order
+}
+
+// newPlanCache creates a new cache of query plans
+func newPlanCache() *planCache {
+	return &planCache{
+		entries: make(map[uint64]*queryPlan, 16),
+	}
+}
+
+// load loads the ranks of the filters if their shape was planned since the last change of
+// the schema and statistics, and returns whether it was.
+func (c *planCache) load(hash uint64, filters []filter) bool {
+	c.lock.RLock()
+	plan, ok := c.entries[hash]
+	c.lock.RUnlock()
+	if !ok || plan.epoch != atomic.LoadUint64(&c.epoch) || len(plan.shape) != len(filters) {
+		return false
+	}
+
+	for i := range filters {
+		if plan.shape[i] != (statKey{filters[i].kind, filters[i].column}) {
+			return false
+		}
+	}
+
+	for i := range filters {
+		filters[i].rank = plan.ranks[i]
+	}
+	return true
+}
+
+// store stores the ranks of the filters as the plan of their shape, made with the specified
+// version of the schema and statistics.
+func (c *planCache) store(hash, epoch uint64, filters []filter) {
+	plan := &queryPlan{
+		epoch: epoch,
+		shape: make([]statKey, 0, len(filters)),
+		ranks: make([]float64, 0, len(filters)),
+	}
+
+	for i := range filters {
+		plan.shape = append(plan.shape, statKey{filters[i].kind, filters[i].column})
+		plan.ranks = append(plan.ranks, filters[i].rank)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.entries) >= planCacheSize {
+		c.entries = make(map[uint64]*queryPlan, 16)
+	}
+	c.entries[hash] = plan
+}
+
+// version returns the current version of the schema and statistics
+func (c *planCache) version() uint64 {
+	return atomic.LoadUint64(&c.epoch)
+}
+
+// schemaVersion returns the current version of the schema
+func (c *planCache) schemaVersion() uint64 {
+	return atomic.LoadUint64(&c.schema)
+}
+
+// invalidate marks all of the plans as stale, once the statistics changed
+func (c *planCache) invalidate() {
+	atomic.AddUint64(&c.epoch, 1)
+}
+
+// reset marks all of the plans and prepared queries as stale, once the schema changed
+func (c *planCache) reset() {
+	atomic.AddUint64(&c.schema, 1)
+	atomic.AddUint64(&c.epoch, 1)
+}
+
+// planFilters ranks the pending filters, reusing the plan of their shape if it is not stale.
+// The shapes with range filters estimated from a histogram are not cached, since their ranks
+// depend on the bounds of the ranges.
+func (txn *Txn) planFilters(filters []filter) {
+	plans := txn.owner.plans
+	hash := shapeOf(filters)
+	cached := plans.load(hash, filters)
+	if txn.plan != nil {
+		txn.plan.Cached = cached
+	}
+	if cached {
+		return
+	}
+
+	// Take the version before ranking, so that statistics changing meanwhile make it stale
+	epoch := plans.version()
+	bounded := false
+	for i := range filters {
+		filters[i].rank = txn.rankOf(&filters[i])
+		switch filters[i].kind {
+		case filterGreater, filterLess, filterBetween:
+			if txn.owner.histograms.tracked() {
+				_, ok := txn.owner.histograms.cached(filters[i].column)
+				bounded = bounded || ok
+			}
+		}
+	}
+
+	if !bounded {
+		plans.store(hash, epoch, filters)
+	}
+}
+
+// shapeOf computes the fingerprint of the kinds and columns of the filters, with FNV-1a
+func shapeOf(filters []filter) uint64 {
+	const prime = 1099511628211
+	hash := uint64(14695981039346656037)
+	for i := range filters {
+		hash = (hash ^ uint64(filters[i].kind)) * prime
+		for j := 0; j < len(filters[i].column); j++ {
+			hash = (hash ^ uint64(filters[i].column[j])) * prime
+		}
+		hash = (hash ^ 0xff) * prime
+	}
+	return hash
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanCache(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// Once the statistics settle, the plan of the shape is reused whatever the values
+	query := func(class string, age float64) (plan *Plan) {
+		players.Query(func(txn *Txn) error {
+			plan = txn.Explain()
+			txn.Where(Q().Eq("class", class).Gt("age", age).Has("human")).Count()
+			return nil
+		})
+		return
+	}
+
+	for i := 0; i < 20; i++ {
+		query("rogue", 30)
+	}
+
+	assert.True(t, query("rogue", 30).Cached)
+	plan := query("mage", 31)
+	assert.True(t, plan.Cached)
+	assert.Equal(t, []string{"With", "WithStringEqual", "WithFloatGreater"}, operationsOf(plan))
+
+	// A different shape is planned on its own
+	players.Query(func(txn *Txn) error {
+		plan = txn.Explain()
+		txn.Where(Q().Has("human").Gt("age", 30)).Count()
+		return nil
+	})
+	assert.False(t, plan.Cached)
+
+	// Changing the schema makes the plans stale
+	assert.NoError(t, players.CreateColumn("score", ForFloat64()))
+	assert.False(t, query("rogue", 30).Cached)
+
+	// The range filters estimated from a histogram depend on their bounds
+	_, err := players.ColumnHistogram("age", 16)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		assert.False(t, query("rogue", 30).Cached)
+	}
+}
+
+func TestPlanCacheShape(t *testing.T) {
+	a := []filter{{kind: filterEqual, column: "class"}, {kind: filterWith, column: "human"}}
+	b := []filter{{kind: filterEqual, column: "class", predicate: "mage"}, {kind: filterWith, column: "human"}}
+	c := []filter{{kind: filterWith, column: "human"}, {kind: filterEqual, column: "class"}}
+	d := []filter{{kind: filterEqual, column: "classh"}, {kind: filterWith, column: "uman"}}
+	assert.Equal(t, shapeOf(a), shapeOf(b))
+	assert.NotEqual(t, shapeOf(a), shapeOf(c))
+	assert.NotEqual(t, shapeOf(a), shapeOf(d))
+
+	// A plan is only loaded for the same shape and version
+	plans := newPlanCache()
+	a[0].rank, a[1].rank = 2, 1
+	plans.store(shapeOf(a), plans.version(), a)
+	assert.True(t, plans.load(shapeOf(b), b))
+	assert.Equal(t, 2.0, b[0].rank)
+	assert.False(t, plans.load(shapeOf(a), c))
+
+	plans.invalidate()
+	assert.False(t, plans.load(shapeOf(b), b))
+}
+
+func TestPreparedSchemaChange(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	query, err := players.Prepare(Equal("class", Param(0)), Greater("age", 30))
+	assert.NoError(t, err)
+
+	count := func() (n int, err error) {
+		err = players.Query(func(txn *Txn) error {
+			n = query.Run(txn, "rogue").Count()
+			return txn.failed()
+		})
+		return
+	}
+
+	before, err := count()
+	assert.NoError(t, err)
+	assert.Greater(t, before, 0)
+
+	// Once the column is dropped, the query fails instead of reading the previous column
+	players.DropColumn("class")
+	_, err = count()
+	assert.ErrorIs(t, err, ErrColumnNotFound)
+
+	// Once the column is created again, the query is resolved against the new one
+	assert.NoError(t, players.CreateColumn("class", ForString()))
+	after, err := count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, after)
+}
+
+// operationsOf returns the operations of the steps of a plan
+func operationsOf(plan *Plan) (out []string) {
+	for _, step := range plan.Steps {
+		out = append(out, step.Operation)
+	}
+	return
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kelindar/bitmap"
+)
+
+var (
+	// ErrForbidden is returned when a transaction carrying a principal accessed a row which
+	// is not allowed by the row policy of the collection.
+	ErrForbidden = errors.New("column: row is not accessible to the principal")
+)
+
+// RowPolicy represents a security predicate, which returns whether the row is accessible
+// to the principal. It must only read the row and must not retain it.
+type RowPolicy func(principal interface{}, r Row) bool
+
+// principalKey is the key of the principal in a context
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context which carries the principal, such as the
+// user or the tenant on behalf of which the transactions are executed.
+func WithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalOf returns the principal carried by the context, if any.
+func PrincipalOf(ctx context.Context) (interface{}, bool) {
+	principal := ctx.Value(principalKey{})
+	return principal, principal != nil
+}
+
+// SetRowPolicy sets the security policy of the collection, or removes it if nil. The policy
+// is enforced on every transaction started with QueryContext() whose context carries a
+// principal, so that the transaction only selects and accesses the rows allowed by the
+// policy. The transactions without a principal are not restricted.
+func (c *Collection) SetRowPolicy(fn RowPolicy) {
+	c.lock.Lock()
+	c.policy = fn
+	c.lock.Unlock()
+}
+
+// restrict applies the row policy of the collection to the transaction, if its context
+// carries a principal.
+func (txn *Txn) restrict(ctx context.Context) {
+	principal, ok := PrincipalOf(ctx)
+	if !ok {
+		return
+	}
+
+	txn.owner.lock.RLock()
+	txn.policy = txn.owner.policy
+	txn.masks = txn.owner.masks
+	txn.owner.lock.RUnlock()
+	txn.principal = principal
+}
+
+// allowed returns whether the row at the index is accessible to the principal of the
+// transaction. This must be called while the chunk is read-locked.
+func (txn *Txn) allowed(idx uint32) bool {
+	if txn.policy == nil {
+		return true
+	}
+
+	// The policy is evaluated against the stored values, not the masked ones
+	cursor, masks := txn.cursor, txn.masks
+	txn.cursor, txn.masks = idx, nil
+	allowed := txn.policy(txn.principal, Row{txn})
+	txn.cursor, txn.masks = cursor, masks
+	return allowed
+}
+
+// applyPolicy removes the rows which are not accessible to the principal from the
+// selection of the transaction.
+func (txn *Txn) applyPolicy() {
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		index.Filter(func(x uint32) bool {
+			return txn.allowed(offset + x)
+		})
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRowPolicy(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("tenant", ForEnum())
+	col.CreateColumn("balance", ForInt())
+	defer col.Close()
+
+	for i := 0; i < 100; i++ {
+		col.Insert(func(r Row) error {
+			r.SetEnum("tenant", []string{"acme", "globex"}[i%2])
+			r.SetInt("balance", i)
+			return nil
+		})
+	}
+
+	col.SetRowPolicy(func(principal interface{}, r Row) bool {
+		tenant, _ := r.Enum("tenant")
+		return tenant == principal
+	})
+
+	count := func(ctx context.Context) (n int) {
+		assert.NoError(t, col.QueryContext(ctx, func(txn *Txn) error {
+			n = txn.WithInt("balance", func(v int64) bool {
+				return v >= 50
+			}).Count()
+			return nil
+		}))
+		return
+	}
+
+	// Only the rows of the tenant are selected
+	acme := WithPrincipal(context.Background(), "acme")
+	assert.Equal(t, 25, count(acme))
+	assert.Equal(t, 0, count(WithPrincipal(context.Background(), "initech")))
+	assert.Equal(t, 50, count(context.Background()))
+
+	principal, ok := PrincipalOf(acme)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", principal)
+
+	// Updates and deletes only apply to the accessible rows
+	assert.NoError(t, col.QueryContext(acme, func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}))
+	assert.Equal(t, 50, col.Count())
+	assert.Equal(t, 0, count(acme))
+
+	// Accessing a row of another tenant is forbidden
+	assert.Equal(t, ErrForbidden, col.QueryContext(acme, func(txn *Txn) error {
+		return txn.QueryAt(1, func(r Row) error {
+			r.SetInt("balance", 0)
+			return nil
+		})
+	}))
+
+	// Once the policy is removed, the principal is no longer restricted
+	col.SetRowPolicy(nil)
+	assert.Equal(t, 25, count(WithPrincipal(context.Background(), "initech")))
+}
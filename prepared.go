@@ -0,0 +1,348 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+)
+
+// errNotPrepared is returned when a prepared query is run on a transaction of another collection
+var errNotPrepared = errors.New("column: query was prepared for another collection")
+
+// Param represents a positional parameter of a prepared query, which is bound to one of the
+// arguments of Run(). For example, Param(0) is bound to the first argument.
+type Param int
+
+// Condition represents a declarative filter of a prepared query. The values it compares to
+// can either be constants or parameters bound when the query is run.
+type Condition struct {
+	kind   filterKind  // The kind of the filter
+	column string      // The name of the column or index
+	lo, hi interface{} // The bounds or the value, constants or parameters
+}
+
+// Has creates a condition which selects the rows present in the specified index or column.
+func Has(columnName string) Condition {
+	return Condition{kind: filterWith, column: columnName}
+}
+
+// HasNot creates a condition which selects the rows absent from the specified index or column.
+func HasNot(columnName string) Condition {
+	return Condition{kind: filterWithout, column: columnName}
+}
+
+// Equal creates a condition which selects the rows whose value in a string, enum or numeric
+// column is equal to the specified value.
+func Equal(columnName string, value interface{}) Condition {
+	return Condition{kind: filterEqual, column: columnName, lo: value, hi: value}
+}
+
+// Greater creates a condition which selects the rows whose value in a numeric column is
+// greater than the specified value.
+func Greater(columnName string, value interface{}) Condition {
+	return Condition{kind: filterGreater, column: columnName, lo: value}
+}
+
+// Less creates a condition which selects the rows whose value in a numeric column is less
+// than the specified value.
+func Less(columnName string, value interface{}) Condition {
+	return Condition{kind: filterLess, column: columnName, hi: value}
+}
+
+// Between creates a condition which selects the rows whose value in a numeric column is
+// between min and max, inclusive.
+func Between(columnName string, min, max interface{}) Condition {
+	return Condition{kind: filterBetween, column: columnName, lo: min, hi: max}
+}
+
+// PreparedQuery represents a set of conditions which were validated and planned once, and
+// which can be run many times with different parameters.
+type PreparedQuery struct {
+	lock       sync.RWMutex     // The lock protecting the filters, once resolved again
+	owner      *Collection      // The collection the query was prepared for
+	conditions []Condition      // The conditions, to resolve again once the schema changes
+	filters    []preparedFilter // The filters, in the order they are applied
+	schema     uint64           // The version of the schema the filters were resolved with
+	params     int              // The number of parameters
+}
+
+// preparedFilter represents a condition resolved against its column
+type preparedFilter struct {
+	kind    filterKind  // The kind of the filter
+	name    string      // The name of the column
+	column  *column     // The column to filter on
+	textual bool        // Whether the column is a string or an enum
+	lo, hi  interface{} // The bounds, as float64 or string constants, or parameters
+}
+
+// Prepare validates a set of conditions against the columns of the collection and plans the
+// order in which they are applied, so that it is not repeated every time the query runs. The
+// indexes are applied first, then the equality and finally the range conditions. Once the
+// schema of the collection changes, the conditions are resolved again against the columns
+// the next time the query runs, which fails if the columns it filters on were dropped.
+func (c *Collection) Prepare(conditions ...Condition) (*PreparedQuery, error) {
+	query := &PreparedQuery{
+		owner:      c,
+		conditions: conditions,
+		filters:    make([]preparedFilter, 0, len(conditions)),
+		schema:     c.plans.schemaVersion(),
+	}
+
+	for _, stage := range [][]filterKind{
+		{filterWith, filterWithout},
+		{filterEqual},
+		{filterGreater, filterLess, filterBetween},
+	} {
+		for _, cond := range conditions {
+			for _, kind := range stage {
+				if cond.kind != kind {
+					continue
+				}
+
+				f, err := query.prepare(cond)
+				if err != nil {
+					return nil, err
+				}
+				query.filters = append(query.filters, f)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+// prepare resolves a condition against its column and converts its constant values
+func (q *PreparedQuery) prepare(cond Condition) (preparedFilter, error) {
+	column, ok := q.owner.cols.Load(cond.column)
+	if !ok {
+		return preparedFilter{}, errorOf(ErrColumnNotFound, "column: unable to prepare query, column '%s' does not exist", cond.column)
+	}
+
+	f := preparedFilter{
+		kind:    cond.kind,
+		name:    cond.column,
+		column:  column,
+		textual: column.IsTextual() && cond.kind == filterEqual,
+	}
+
+	switch {
+	case cond.kind == filterWith || cond.kind == filterWithout:
+		return f, nil
+	case !f.textual && !column.IsNumeric():
+		return f, errorOf(ErrTypeMismatch, "column: unable to prepare query, column '%s' is not numeric", cond.column)
+	case !f.textual && cond.kind == filterEqual:
+		f.kind = filterBetween // Numeric equality is a range of a single value
+	}
+
+	var err error
+	if f.lo, err = q.constant(f, cond.lo, math.Inf(-1)); err != nil {
+		return f, err
+	}
+	if f.hi, err = q.constant(f, cond.hi, math.Inf(1)); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// constant converts a constant value of a condition, or keeps track of a parameter
+func (q *PreparedQuery) constant(f preparedFilter, value interface{}, unbounded float64) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return unbounded, nil
+	case Param:
+		if v < 0 {
+			return nil, fmt.Errorf("column: unable to prepare query, parameter %d is negative", v)
+		}
+		if int(v) >= q.params {
+			q.params = int(v) + 1
+		}
+		return v, nil
+	}
+
+	bound, err := f.bind(value)
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to prepare query, %v", err)
+	}
+	return bound, nil
+}
+
+// bind converts a value to the type compared by the filter
+func (f *preparedFilter) bind(value interface{}) (interface{}, error) {
+	if f.textual {
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%T is not a string for '%s'", value, f.name)
+	}
+
+	if v, ok := asFloat64(value); ok {
+		return v, nil
+	}
+	return nil, errorOf(ErrTypeMismatch, "%T is not a number for '%s'", value, f.name)
+}
+
+// asFloat64 converts a number to float64
+func asFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// Params returns the number of parameters of the query.
+func (q *PreparedQuery) Params() int {
+	return q.params
+}
+
+// Run applies the conditions of the query to the selection of the transaction, with the
+// parameters bound to the arguments. If the arguments do not match the parameters, the
+// selection is cleared and the transaction fails with an error.
+func (q *PreparedQuery) Run(txn *Txn, args ...interface{}) *Txn {
+	switch {
+	case txn.owner != q.owner:
+		return txn.abort(errNotPrepared)
+	case len(args) != q.params:
+		return txn.abort(fmt.Errorf("column: query expects %d parameters, got %d", q.params, len(args)))
+	}
+
+	filters, err := q.resolve()
+	if err != nil {
+		return txn.abort(err)
+	}
+
+	txn.initialize()
+	txn.cached = nil
+	for i := range filters {
+		if err := filters[i].apply(txn, args); err != nil {
+			return txn.abort(err)
+		}
+	}
+	return txn
+}
+
+// resolve returns the filters of the query, resolved again against the columns if the
+// schema of the collection changed since they were.
+func (q *PreparedQuery) resolve() ([]preparedFilter, error) {
+	schema := q.owner.plans.schemaVersion()
+	q.lock.RLock()
+	filters, current := q.filters, q.schema == schema
+	q.lock.RUnlock()
+	if current {
+		return filters, nil
+	}
+
+	fresh, err := q.owner.Prepare(q.conditions...)
+	if err != nil {
+		return nil, err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.filters, q.schema = fresh.filters, fresh.schema
+	return fresh.filters, nil
+}
+
+// apply applies the filter to the selection of the transaction
+func (f *preparedFilter) apply(txn *Txn, args []interface{}) error {
+	txn.record(f.kind, f.name)
+	switch f.kind {
+	case filterWith:
+		done := txn.trace("With", f.name, true)
+		txn.rangeReadPair(f.column, func(dst, src bitmap.Bitmap) {
+			dst.And(src)
+		})
+		done()
+		return nil
+	case filterWithout:
+		done := txn.trace("Without", f.name, true)
+		txn.rangeReadPair(f.column, func(dst, src bitmap.Bitmap) {
+			dst.AndNot(src)
+		})
+		done()
+		return nil
+	}
+
+	lo, err := f.arg(f.lo, args)
+	if err != nil {
+		return err
+	}
+
+	hi, err := f.arg(f.hi, args)
+	if err != nil {
+		return err
+	}
+
+	// Equality of strings uses the bloom filters, if any
+	if f.textual {
+		txn.withStringEqual(filterEqual, f.name, lo.(string))
+		return nil
+	}
+
+	from, to := lo.(float64), hi.(float64)
+	switch f.kind {
+	case filterGreater:
+		from = math.Nextafter(from, math.Inf(1))
+	case filterLess:
+		to = math.Nextafter(to, math.Inf(-1))
+	}
+
+	done := txn.trace(filterNames[f.kind], f.name, false)
+	txn.withRangeOf(f.column, from, to)
+	done()
+	return nil
+}
+
+// arg returns a bound value, converting the argument if the value is a parameter
+func (f *preparedFilter) arg(value interface{}, args []interface{}) (interface{}, error) {
+	param, ok := value.(Param)
+	if !ok {
+		return value, nil
+	}
+
+	bound, err := f.bind(args[param])
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to bind parameter %d, %v", param, err)
+	}
+	return bound, nil
+}
+
+// abort clears the selection and fails the transaction with an error
+func (txn *Txn) abort(err error) *Txn {
+	txn.index.Clear()
+	txn.filters = txn.filters[:0]
+	txn.setup = true
+	if txn.err == nil {
+		txn.err = err
+	}
+	return txn
+}
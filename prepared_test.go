@@ -0,0 +1,154 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepared(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	query, err := players.Prepare(
+		Between("age", Param(0), Param(1)),
+		Equal("class", Param(2)),
+		Has("human"),
+		HasNot("mage"),
+		Greater("balance", 1000),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, query.Params())
+	assert.Equal(t, []filterKind{filterWith, filterWithout, filterEqual, filterBetween, filterGreater}, kindsOf(query))
+
+	for _, tc := range []struct {
+		lo, hi interface{}
+		class  string
+	}{
+		{lo: 20, hi: 30, class: "rogue"},
+		{lo: 25.5, hi: 50, class: "warrior"},
+		{lo: uint8(0), hi: int64(100), class: "mage"},
+	} {
+		var expect, actual int
+		players.Query(func(txn *Txn) error {
+			expect = txn.With("human").Without("mage").
+				WithFloatBetween("age", toFloat(tc.lo), toFloat(tc.hi)).
+				WithStringEqual("class", tc.class).
+				WithFloatGreater("balance", 1000).
+				Count()
+			return nil
+		})
+
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			actual = query.Run(txn, tc.lo, tc.hi, tc.class).Count()
+			return nil
+		}))
+		assert.Equal(t, expect, actual)
+	}
+}
+
+func TestPreparedNumeric(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+	for i := 0; i < 100; i++ {
+		col.InsertObject(Object{"name": "Roman", "age": i})
+	}
+
+	for _, tc := range []struct {
+		cond   Condition
+		expect int
+	}{
+		{cond: Equal("age", 10), expect: 1},
+		{cond: Greater("age", 89), expect: 10},
+		{cond: Less("age", 10), expect: 10},
+		{cond: Between("age", 10, 19), expect: 10},
+		{cond: Equal("name", "Roman"), expect: 100},
+		{cond: Equal("name", "Alice"), expect: 0},
+	} {
+		query, err := col.Prepare(tc.cond)
+		assert.NoError(t, err)
+		col.Query(func(txn *Txn) error {
+			assert.Equal(t, tc.expect, query.Run(txn).Count())
+			return nil
+		})
+	}
+
+	// Filters chained before the query are applied as well
+	query, err := col.Prepare(Less("age", Param(0)))
+	assert.NoError(t, err)
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 5, query.Run(txn.WithInt("age", func(v int64) bool {
+			return v >= 45
+		}), 50).Count())
+		return nil
+	})
+}
+
+func TestPreparedErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForInt())
+	col.InsertObject(Object{"name": "Roman", "age": 20})
+	defer col.Close()
+
+	for _, conditions := range [][]Condition{
+		{Has("missing")},
+		{Greater("name", 10)},
+		{Equal("name", 10)},
+		{Equal("age", "10")},
+		{Between("age", Param(-1), 10)},
+	} {
+		_, err := col.Prepare(conditions...)
+		assert.Error(t, err)
+	}
+
+	query, err := col.Prepare(Equal("age", Param(0)))
+	assert.NoError(t, err)
+
+	// Wrong number of arguments
+	assert.Error(t, col.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, query.Run(txn).Count())
+		return nil
+	}))
+
+	// Wrong type of argument
+	assert.Error(t, col.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, query.Run(txn, "20").Count())
+		return nil
+	}))
+
+	// Wrong collection
+	other := NewCollection()
+	other.CreateColumn("age", ForInt())
+	other.InsertObject(Object{"age": 20})
+	defer other.Close()
+	assert.Equal(t, errNotPrepared, other.Query(func(txn *Txn) error {
+		query.Run(txn, 20)
+		return nil
+	}))
+
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, query.Run(txn, 20).Count())
+		return nil
+	}))
+}
+
+// kindsOf returns the kinds of the filters of a prepared query, in order
+func kindsOf(query *PreparedQuery) (out []filterKind) {
+	for _, f := range query.filters {
+		out = append(out, f.kind)
+	}
+	return
+}
+
+// toFloat converts a number of the test cases to float64
+func toFloat(v interface{}) float64 {
+	f := preparedFilter{}
+	out, _ := f.bind(v)
+	return out.(float64)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Priority represents the priority of a transaction when it competes for the chunk locks
+type Priority int8
+
+// Various priorities of the transactions
+const (
+	PriorityNormal Priority = iota // The default priority
+	PriorityLow                    // Yields the chunk locks to the high-priority transactions
+	PriorityHigh                   // Takes the chunk locks ahead of the low-priority transactions
+)
+
+// priorityKey is the key of the priority in a context
+type priorityKey struct{}
+
+// WithPriority returns a copy of the context which carries the priority of the transactions
+// started with QueryContext(). A low-priority transaction, such as a bulk import, waits before
+// locking each chunk while a high-priority transaction is waiting for the same chunk, so that
+// latency-sensitive point updates are not blocked behind it. Since the locks are only taken
+// one chunk at a time, a low-priority transaction yields in between the chunks it reads and
+// commits, and may be delayed for as long as the high-priority transactions keep coming.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityOf returns the priority carried by the context, or PriorityNormal if none.
+func PriorityOf(ctx context.Context) Priority {
+	priority, _ := ctx.Value(priorityKey{}).(Priority)
+	return priority
+}
+
+// lanes keeps the number of high-priority transactions waiting for each shard of the chunk
+// locks, so that the low-priority ones can step aside.
+type lanes struct {
+	waiting [128]int32 // The number of high-priority waiters, by shard of the chunk locks
+}
+
+// enter registers a high-priority waiter for the lock of a chunk
+func (l *lanes) enter(chunk commit.Chunk) {
+	atomic.AddInt32(&l.waiting[chunk%128], 1)
+}
+
+// leave unregisters a high-priority waiter, once it acquired the lock of a chunk
+func (l *lanes) leave(chunk commit.Chunk) {
+	atomic.AddInt32(&l.waiting[chunk%128], -1)
+}
+
+// yield waits until no high-priority transaction is waiting for the lock of a chunk, or
+// until the context is cancelled.
+func (l *lanes) yield(ctx context.Context, chunk commit.Chunk) {
+	for atomic.LoadInt32(&l.waiting[chunk%128]) > 0 && ctx.Err() == nil {
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// queue waits for the turn of the transaction to lock a chunk, according to its priority.
+// It must be followed by dequeue() once the lock is acquired.
+func (txn *Txn) queue(chunk commit.Chunk) {
+	switch txn.priority {
+	case PriorityHigh:
+		txn.owner.lanes.enter(chunk)
+	case PriorityLow:
+		txn.owner.lanes.yield(txn.ctx, chunk)
+	}
+}
+
+// dequeue marks the lock of a chunk queued for with queue() as acquired
+func (txn *Txn) dequeue(chunk commit.Chunk) {
+	if txn.priority == PriorityHigh {
+		txn.owner.lanes.leave(chunk)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityOf(t *testing.T) {
+	assert.Equal(t, PriorityNormal, PriorityOf(context.Background()))
+	assert.Equal(t, PriorityLow, PriorityOf(WithPriority(context.Background(), PriorityLow)))
+	assert.Equal(t, PriorityHigh, PriorityOf(WithPriority(context.Background(), PriorityHigh)))
+}
+
+func TestPriorityYield(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// A high-priority transaction is waiting for the first chunk
+	players.lanes.enter(0)
+	done := make(chan int, 1)
+	go func() {
+		ctx := WithPriority(context.Background(), PriorityLow)
+		players.QueryContext(ctx, func(txn *Txn) error {
+			done <- txn.With("human").Count()
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+		assert.Fail(t, "the low-priority transaction did not yield")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// The normal and high-priority transactions are not held back
+	ctx := WithPriority(context.Background(), PriorityHigh)
+	assert.NoError(t, players.QueryContext(ctx, func(txn *Txn) error {
+		return txn.QueryAt(1, func(r Row) error {
+			r.SetFloat64("balance", 500)
+			return nil
+		})
+	}))
+	assert.Equal(t, 500, players.Count())
+
+	players.lanes.leave(0)
+	assert.Equal(t, countWhere(players, func(txn *Txn) *Txn { return txn.With("human") }), <-done)
+	for _, waiting := range players.lanes.waiting {
+		assert.Zero(t, waiting)
+	}
+}
+
+func TestPriorityYieldCancelled(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	players.lanes.enter(0)
+	defer players.lanes.leave(0)
+
+	ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityLow), 10*time.Millisecond)
+	defer cancel()
+	err := players.QueryContext(ctx, func(txn *Txn) error {
+		txn.With("human").Count()
+		return nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sort"
+
+	"github.com/kelindar/column/commit"
+	"github.com/kelindar/iostream"
+)
+
+// SetProperty sets a property of the collection, such as the version of its schema. The
+// properties are written in the snapshots and loaded back when they are restored, which
+// allows to keep track of a state along with the data. An empty value removes the property.
+func (c *Collection) SetProperty(key, value string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.props == nil {
+		c.props = make(map[string]string, 4)
+	}
+
+	if value == "" {
+		delete(c.props, key)
+		return
+	}
+	c.props[key] = value
+}
+
+// Property returns a property of the collection, if it was set.
+func (c *Collection) Property(key string) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	value, ok := c.props[key]
+	return value, ok
+}
+
+// Chunks returns the number of chunks of the collection. A chunk is a range of rows which
+// are locked and committed together, and is the unit in which large changes are best made.
+func (c *Collection) Chunks() int {
+	return c.chunks()
+}
+
+// QueryChunk creates a transaction whose selection starts with the rows of a single chunk
+// rather than all of the rows. This allows to process a large collection one chunk at a
+// time, with a transaction per chunk, without holding the locks of the other chunks.
+func (c *Collection) QueryChunk(chunk int, fn func(txn *Txn) error) error {
+	return c.Query(func(txn *Txn) error {
+		txn.initialize()
+		lo, hi := int(commit.Chunk(chunk).Min()>>6), int((commit.Chunk(chunk).Max()+1)>>6)
+		for i := range txn.index {
+			if i < lo || i >= hi {
+				txn.index[i] = 0
+			}
+		}
+		return fn(txn)
+	})
+}
+
+// writeProperties writes the properties of the collection, in the order of their keys
+func (c *Collection) writeProperties(w *iostream.Writer) error {
+	c.lock.RLock()
+	keys := make([]string, 0, len(c.props))
+	values := make(map[string]string, len(c.props))
+	for k, v := range c.props {
+		keys = append(keys, k)
+		values[k] = v
+	}
+	c.lock.RUnlock()
+
+	sort.Strings(keys)
+	return w.WriteRange(len(keys), func(i int, w *iostream.Writer) error {
+		if err := w.WriteString(keys[i]); err != nil {
+			return err
+		}
+		return w.WriteString(values[keys[i]])
+	})
+}
+
+// readProperties reads the properties written in a snapshot and sets them
+func (c *Collection) readProperties(r *iostream.Reader) error {
+	return r.ReadRange(func(i int, r *iostream.Reader) error {
+		key, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		value, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		c.SetProperty(key, value)
+		return nil
+	})
+}
@@ -0,0 +1,223 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math/bits"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+const pushdownSize = 256 // The maximum number of aggregates whose chunks are kept
+
+// pushdownCache represents the aggregates of the chunks over the intersections of bitmaps
+// which were computed before, along with the commit of every chunk they were computed at.
+// An aggregate repeated over the chunks which were not committed since is answered without
+// intersecting their bitmaps or reading their values again.
+type pushdownCache struct {
+	lock    sync.Mutex                // The lock protecting the entries
+	entries map[uint64]*pushdownEntry // The aggregates, by the fingerprint of their filters and column
+}
+
+// pushdownEntry represents an aggregate of every chunk of the collection
+type pushdownEntry struct {
+	lock    sync.Mutex  // The lock held while the entry is computed
+	schema  uint64      // The version of the schema the chunks were computed with
+	shape   []statKey   // The kinds and columns of the filters
+	commits []uint64    // The commit of every chunk, as of its aggregate
+	chunks  []Aggregate // The aggregate of every chunk
+}
+
+// newPushdownCache creates a new cache of the pushed down aggregates
+func newPushdownCache() *pushdownCache {
+	return &pushdownCache{
+		entries: make(map[uint64]*pushdownEntry, 16),
+	}
+}
+
+// load loads or creates the entry of the filters and the column aggregated, if any
+func (c *pushdownCache) load(filters []filter, columnName string, schema uint64) *pushdownEntry {
+	hash := shapeOf(append(filters[:len(filters):len(filters)], filter{column: columnName}))
+	shape := make([]statKey, 0, len(filters)+1)
+	for i := range filters {
+		shape = append(shape, statKey{filters[i].kind, filters[i].column})
+	}
+	shape = append(shape, statKey{column: columnName})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.entries[hash]; ok && entry.schema == schema && sameShape(entry.shape, shape) {
+		return entry
+	}
+
+	if len(c.entries) >= pushdownSize {
+		c.entries = make(map[uint64]*pushdownEntry, 16)
+	}
+
+	entry := &pushdownEntry{schema: schema, shape: shape}
+	c.entries[hash] = entry
+	return entry
+}
+
+// reset removes all of the aggregates, once the bitmaps were changed without a commit
+func (c *pushdownCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[uint64]*pushdownEntry, 16)
+}
+
+// pushable checks whether the pending filters of the transaction only intersect bitmaps,
+// so that an aggregate over them can be computed chunk by chunk without a selection.
+func (txn *Txn) pushable() bool {
+	if txn.setup || txn.policy != nil || txn.owner.opts.SoftDelete {
+		return false
+	}
+
+	// The rows written by the transaction are only reserved in the fill-list
+	for _, u := range txn.updates {
+		if !u.IsEmpty() {
+			return false
+		}
+	}
+
+	for i := range txn.filters {
+		switch txn.filters[i].kind {
+		case filterWith, filterWithout:
+			if _, ok := txn.columnAt(txn.filters[i].column); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// pushdown computes an aggregate over the intersection of the fill-list and the bitmaps
+// of the pending filters, without materializing the selection. The aggregates of the
+// chunks which were not committed since they were last computed are reused, and the
+// other chunks are either counted from their bitmaps, or aggregated from the values of
+// the column if one is specified.
+func (txn *Txn) pushdown(columnName string, values Numeric) (Aggregate, bool) {
+	if !txn.pushable() {
+		return Aggregate{}, false
+	}
+
+	if txn.admit(); txn.failed() != nil {
+		return Aggregate{}, true
+	}
+
+	if txn.plan != nil {
+		txn.plan.Pushed = true
+	}
+
+	owner := txn.owner
+	columns := make([]*column, 0, len(txn.filters))
+	for i := range txn.filters {
+		column, _ := txn.columnAt(txn.filters[i].column)
+		columns = append(columns, column)
+	}
+
+	entry := owner.pushed.load(txn.filters, columnName, owner.plans.schemaVersion())
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	var out Aggregate
+	include := make([]bitmap.Bitmap, 0, len(columns))
+	exclude := make([]bitmap.Bitmap, 0, 2)
+	chunks := owner.chunks()
+	for len(entry.chunks) < chunks {
+		entry.chunks = append(entry.chunks, Aggregate{})
+		entry.commits = append(entry.commits, 0)
+	}
+
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		if txn.cancelled() || !txn.rlock(chunk) {
+			return Aggregate{}, true
+		}
+
+		// The chunk is reused if it was not committed since its aggregate was computed,
+		// otherwise the bitmaps are loaded again since they may have grown meanwhile
+		var fill bitmap.Bitmap
+		var commitID uint64
+		owner.lock.RLock()
+		if int(chunk) < len(owner.commits) {
+			commitID = owner.commits[chunk]
+		}
+
+		stale := commitID == 0 || commitID != entry.commits[chunk]
+		if stale {
+			fill, include, exclude = owner.fill, include[:0], exclude[:0]
+			for i, column := range columns {
+				if txn.filters[i].kind == filterWith {
+					include = append(include, *column.Index())
+				} else {
+					exclude = append(exclude, *column.Index())
+				}
+			}
+		}
+		owner.lock.RUnlock()
+
+		if stale {
+			entry.chunks[chunk] = intersect(chunk, fill, include, exclude, values)
+			entry.commits[chunk] = commitID
+		}
+
+		txn.runlock(chunk)
+		out.merge(entry.chunks[chunk])
+	}
+	return out, true
+}
+
+// intersect aggregates a chunk of the intersection of the fill-list with the bitmaps
+// included and without the bitmaps excluded. The rows are only counted, unless the values
+// of a column are aggregated.
+func intersect(chunk commit.Chunk, fill bitmap.Bitmap, include, exclude []bitmap.Bitmap, values Numeric) (out Aggregate) {
+	offset := chunk.Min()
+	words := chunk.OfBitmap(fill)
+	for i, word := range words {
+		for _, b := range include {
+			if other := chunk.OfBitmap(b); i < len(other) {
+				word &= other[i]
+			} else {
+				word = 0
+			}
+		}
+
+		for _, b := range exclude {
+			if other := chunk.OfBitmap(b); i < len(other) {
+				word &^= other[i]
+			}
+		}
+
+		if values == nil {
+			out.Count += bits.OnesCount64(word)
+			continue
+		}
+
+		for ; word != 0; word &= word - 1 {
+			idx := offset + uint32(i<<6+bits.TrailingZeros64(word))
+			if v, ok := values.LoadFloat64(idx); ok {
+				out.merge(Aggregate{Count: 1, Sum: v, Min: v, Max: v})
+			}
+		}
+	}
+	return
+}
+
+// sameShape checks whether two shapes are equal
+func sameShape(a, b []statKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushdownCount(t *testing.T) {
+	players := loadPlayers(20000)
+	defer players.Close()
+
+	// The count from the bitmaps matches the count of the selection
+	check := func(filter func(txn *Txn) *Txn) {
+		var pushed, selected int
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			plan := txn.Explain()
+			pushed = filter(txn).Count()
+			assert.True(t, plan.Pushed)
+			assert.Empty(t, plan.Steps)
+			return nil
+		}))
+
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			return filter(txn).Range(func(idx uint32) {
+				selected++
+			})
+		}))
+		assert.Equal(t, selected, pushed)
+	}
+
+	for i := 0; i < 2; i++ {
+		check(func(txn *Txn) *Txn { return txn.With("human", "mage") })
+		check(func(txn *Txn) *Txn { return txn.With("human").Without("mage") })
+		check(func(txn *Txn) *Txn { return txn.Without("old") })
+	}
+
+	// The chunks committed since are counted again
+	before := players.pushed.entries[pushedHash("human", "mage")].commits
+	before = append([]uint64(nil), before...)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.With("human", "mage").Range(func(idx uint32) {
+			if idx < 10 {
+				txn.Enum("class").Set("fighter")
+			}
+		})
+	}))
+
+	check(func(txn *Txn) *Txn { return txn.With("human", "mage") })
+	after := players.pushed.entries[pushedHash("human", "mage")].commits
+	assert.NotEqual(t, before[0], after[0])
+	assert.Equal(t, before[1], after[1])
+
+	// The deleted and inserted rows are counted as well
+	assert.True(t, players.DeleteAt(1))
+	players.InsertObject(Object{"serial": "new", "race": "human", "class": "mage", "age": 50})
+	check(func(txn *Txn) *Txn { return txn.With("human", "mage") })
+	check(func(txn *Txn) *Txn { return txn.Without("old") })
+}
+
+func TestPushdownAggregate(t *testing.T) {
+	players := loadPlayers(20000)
+	defer players.Close()
+
+	aggregate := func() (pushed, selected Aggregate) {
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			plan := txn.Explain()
+			pushed, _ = txn.With("human").Without("old").Aggregate("balance", 1)
+			assert.True(t, plan.Pushed)
+			return nil
+		}))
+
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			balance := txn.Float64("balance")
+			return txn.With("human").Without("old").Range(func(idx uint32) {
+				v, _ := balance.Get()
+				selected.merge(Aggregate{Count: 1, Sum: v, Min: v, Max: v})
+			})
+		}))
+		return
+	}
+
+	pushed, selected := aggregate()
+	assert.Equal(t, selected.Count, pushed.Count)
+	assert.InDelta(t, selected.Sum, pushed.Sum, 1e-3)
+	assert.Equal(t, selected.Min, pushed.Min)
+	assert.Equal(t, selected.Max, pushed.Max)
+
+	// The values changed since are aggregated again
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		r.SetFloat64("balance", 1e9)
+		return nil
+	}))
+
+	pushed, selected = aggregate()
+	assert.Equal(t, selected.Count, pushed.Count)
+	assert.InDelta(t, selected.Sum, pushed.Sum, 1e-3)
+	assert.Equal(t, selected.Max, pushed.Max)
+}
+
+func TestPushdownFallback(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// The filters which scan values, and the selections already used, are not pushed down
+	for _, filter := range []func(txn *Txn) *Txn{
+		func(txn *Txn) *Txn { return txn.With("human").WithFloat("age", func(v float64) bool { return v > 30 }) },
+		func(txn *Txn) *Txn { return txn.With("human", "missing") },
+		func(txn *Txn) *Txn { txn.Count(); return txn.With("human") },
+	} {
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			plan := txn.Explain()
+			filter(txn).Count()
+			assert.False(t, plan.Pushed)
+			return nil
+		}))
+	}
+
+	// The transactions which wrote rows count them from their selection
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		plan := txn.Explain()
+		txn.InsertObject(Object{"serial": "new", "race": "human"})
+		txn.Without("old").Count()
+		assert.False(t, plan.Pushed)
+		return nil
+	}))
+}
+
+// pushedHash returns the fingerprint of the count of the intersection of the indexes
+func pushedHash(columns ...string) uint64 {
+	filters := make([]filter, 0, len(columns)+1)
+	for _, name := range columns {
+		filters = append(filters, filter{kind: filterWith, column: name})
+	}
+	return shapeOf(append(filters, filter{}))
+}
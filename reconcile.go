@@ -0,0 +1,221 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaChange represents a difference between the schema of a collection and a spec. The
+// action is "create", "drop" or "change", and the kind is "column" or "index".
+type SchemaChange struct {
+	Action string // The action which reconciles the collection with the spec
+	Kind   string // The kind of the definition, "column" or "index"
+	Name   string // The name of the column or of the index
+	Detail string // The description of the change, for the changed definitions
+}
+
+// String returns a human-readable description of the change
+func (c SchemaChange) String() string {
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s '%s'", c.Action, c.Kind, c.Name)
+	}
+	return fmt.Sprintf("%s %s '%s' (%s)", c.Action, c.Kind, c.Name, c.Detail)
+}
+
+// SchemaDiff represents the outcome of the reconciliation of a collection with a spec
+type SchemaDiff struct {
+	Applied     []SchemaChange // The additive changes, which were applied
+	Destructive []SchemaChange // The changes which lose values, pending a confirmation
+	spec        Schema         // The spec the collection was reconciled with
+}
+
+// Reconcile compares the schema of the collection with a declarative spec, typically loaded
+// from a file along with the configuration of a deployment. The additive changes, which are
+// the columns and the indexes missing from the collection, are applied online as with
+// ApplySchema(). The destructive changes, which are the columns and the indexes missing from
+// the spec and the ones whose definition differs, are not applied but reported, so that they
+// can be reviewed and then applied with ConfirmSchema(). The spec is validated entirely
+// before any change is applied.
+func (c *Collection) Reconcile(spec Schema) (*SchemaDiff, error) {
+	current := c.ExportSchema()
+	diff := &SchemaDiff{spec: spec}
+	additive := &Schema{}
+
+	columns := make(map[string]ColumnSchema, len(current.Columns))
+	for _, v := range current.Columns {
+		columns[v.Name] = v
+	}
+
+	indexes := make(map[string]IndexSchema, len(current.Indexes))
+	for _, v := range current.Indexes {
+		indexes[v.Name] = v
+	}
+
+	// The definitions of the spec are either missing or changed
+	declared := make(map[string]bool, len(spec.Columns)+len(spec.Indexes))
+	for _, want := range spec.Columns {
+		declared[want.Name] = true
+		have, ok := columns[want.Name]
+		switch {
+		case !ok:
+			if _, isIndex := indexes[want.Name]; isIndex {
+				return nil, fmt.Errorf("column: unable to reconcile schema, column '%s' is an index", want.Name)
+			}
+			additive.Columns = append(additive.Columns, want)
+			diff.Applied = append(diff.Applied, SchemaChange{Action: "create", Kind: "column", Name: want.Name})
+		case !sameColumn(have, want):
+			if _, err := want.column(); err != nil {
+				return nil, err
+			}
+			diff.Destructive = append(diff.Destructive, SchemaChange{
+				Action: "change", Kind: "column", Name: want.Name, Detail: columnDetail(have, want),
+			})
+		}
+	}
+
+	// The columns missing from the spec are dropped, along with the indexes which depend on them
+	for _, have := range current.Columns {
+		if !declared[have.Name] {
+			diff.Destructive = append(diff.Destructive, SchemaChange{Action: "drop", Kind: "column", Name: have.Name})
+		}
+	}
+
+	affected := make(map[string]bool, len(diff.Destructive))
+	for _, change := range diff.Destructive {
+		affected[change.Name] = true
+	}
+
+	for _, want := range spec.Indexes {
+		declared[want.Name] = true
+		have, ok := indexes[want.Name]
+		detail := ""
+		switch {
+		case !ok:
+			additive.Indexes = append(additive.Indexes, want)
+			diff.Applied = append(diff.Applied, SchemaChange{Action: "create", Kind: "index", Name: want.Name})
+			continue
+		case !sameIndex(have, want):
+			detail = fmt.Sprintf("%s on %s", want.Kind, want.Column)
+		case dependsOn(have, affected):
+			detail = "column changed"
+		default:
+			continue
+		}
+
+		if computedKind(want.Kind) {
+			return nil, fmt.Errorf("column: unable to reconcile schema, index '%s' is computed by a function and must be changed manually", want.Name)
+		}
+		diff.Destructive = append(diff.Destructive, SchemaChange{
+			Action: "change", Kind: "index", Name: want.Name, Detail: detail,
+		})
+	}
+
+	for _, have := range current.Indexes {
+		if !declared[have.Name] {
+			diff.Destructive = append(diff.Destructive, SchemaChange{Action: "drop", Kind: "index", Name: have.Name})
+		}
+	}
+
+	// Apply the additive changes, which also validates them before creating anything
+	if err := c.ApplySchema(additive); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// ConfirmSchema applies the destructive changes of a reconciliation once they were reviewed.
+// The columns and the indexes missing from the spec are dropped along with their values, and
+// the columns whose definition changed are created again from the spec, empty.
+func (c *Collection) ConfirmSchema(diff *SchemaDiff) error {
+	columns := make(map[string]ColumnSchema, len(diff.spec.Columns))
+	for _, v := range diff.spec.Columns {
+		columns[v.Name] = v
+	}
+
+	indexes := make(map[string]IndexSchema, len(diff.spec.Indexes))
+	for _, v := range diff.spec.Indexes {
+		indexes[v.Name] = v
+	}
+
+	// Drop the indexes first, since they depend on the columns
+	recreate := &Schema{}
+	for _, change := range diff.Destructive {
+		if change.Kind != "index" {
+			continue
+		}
+
+		if err := c.DropIndex(change.Name); err != nil {
+			return err
+		}
+		if change.Action == "change" {
+			recreate.Indexes = append(recreate.Indexes, indexes[change.Name])
+		}
+	}
+
+	for _, change := range diff.Destructive {
+		if change.Kind != "column" {
+			continue
+		}
+
+		c.DropColumn(change.Name)
+		if change.Action == "change" {
+			recreate.Columns = append(recreate.Columns, columns[change.Name])
+		}
+	}
+
+	return c.ApplySchema(recreate)
+}
+
+// sameColumn checks whether two definitions of a column are equivalent
+func sameColumn(a, b ColumnSchema) bool {
+	return a.Type == b.Type &&
+		a.Encoding == b.Encoding &&
+		a.Collation == b.Collation &&
+		a.Storage == b.Storage &&
+		a.Cardinality == b.Cardinality &&
+		a.CodeWidth == b.CodeWidth &&
+		strings.Join(a.Dictionary, "\x00") == strings.Join(b.Dictionary, "\x00") &&
+		fmt.Sprint(a.Prefix) == fmt.Sprint(b.Prefix)
+}
+
+// sameIndex checks whether two definitions of an index are equivalent
+func sameIndex(a, b IndexSchema) bool {
+	x := append([]string(nil), a.Columns...)
+	y := append([]string(nil), b.Columns...)
+	sort.Strings(x)
+	sort.Strings(y)
+	return a.Kind == b.Kind &&
+		a.Column == b.Column &&
+		a.Scope == b.Scope &&
+		strings.Join(x, ",") == strings.Join(y, ",")
+}
+
+// dependsOn checks whether an index depends on any of the columns
+func dependsOn(index IndexSchema, columns map[string]bool) bool {
+	if columns[index.Column] || columns[index.Scope] {
+		return true
+	}
+	for _, name := range index.Columns {
+		if columns[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// columnDetail describes the change of the definition of a column
+func columnDetail(have, want ColumnSchema) string {
+	if have.Type != want.Type {
+		return fmt.Sprintf("type %s to %s", have.Type, want.Type)
+	}
+	return "options"
+}
+
+// computedKind checks whether the indexes of a kind are computed by a function
+func computedKind(kind string) bool {
+	return kind == "index" || kind == "partial" || kind == "expression"
+}
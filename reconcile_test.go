@@ -0,0 +1,88 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcile(t *testing.T) {
+	players := newSchemaFixture()
+	defer players.Close()
+	players.InsertObject(Object{"serial": "a", "name": "Roman", "age": 35, "online": true})
+
+	// Reconciling with the schema of the collection itself changes nothing
+	diff, err := players.Reconcile(*players.ExportSchema())
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Applied)
+	assert.Empty(t, diff.Destructive)
+
+	// Add a column and an index, drop a column and change the type of another
+	spec := &Schema{Indexes: players.ExportSchema().Indexes}
+	for _, v := range players.ExportSchema().Columns {
+		switch v.Name {
+		case "name":
+			spec.Columns = append(spec.Columns, ColumnSchema{Name: "name", Type: "enum"})
+		case "online":
+		default:
+			spec.Columns = append(spec.Columns, v)
+		}
+	}
+	spec.Columns = append(spec.Columns, ColumnSchema{Name: "title", Type: "string"})
+	spec.Indexes = append(spec.Indexes, IndexSchema{Name: "title_bloom", Kind: "bloom", Column: "title"})
+
+	diff, err = players.Reconcile(*spec)
+	assert.NoError(t, err)
+	assert.Equal(t, []SchemaChange{
+		{Action: "create", Kind: "column", Name: "title"},
+		{Action: "create", Kind: "index", Name: "title_bloom"},
+	}, diff.Applied)
+	assert.Equal(t, []SchemaChange{
+		{Action: "change", Kind: "column", Name: "name", Detail: "type string to enum"},
+		{Action: "drop", Kind: "column", Name: "online"},
+		{Action: "change", Kind: "index", Name: "by_name", Detail: "column changed"},
+		{Action: "change", Kind: "index", Name: "name_bloom", Detail: "column changed"},
+	}, diff.Destructive)
+	assert.Equal(t, "change column 'name' (type string to enum)", diff.Destructive[0].String())
+
+	// The additive changes are applied, while the values are kept until confirmed
+	assert.Contains(t, players.Columns(), "title")
+	assert.Contains(t, players.Columns(), "online")
+	assert.NoError(t, players.QueryKey("a", func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Roman", name)
+		return nil
+	}))
+
+	// Once confirmed, the collection matches the spec
+	assert.NoError(t, players.ConfirmSchema(diff))
+	assert.Equal(t, spec, players.ExportSchema())
+	assert.NotContains(t, players.Columns(), "online")
+
+	diff, err = players.Reconcile(*spec)
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Applied)
+	assert.Empty(t, diff.Destructive)
+}
+
+func TestReconcileInvalid(t *testing.T) {
+	players := newSchemaFixture()
+	defer players.Close()
+
+	for _, spec := range []Schema{
+		{Columns: []ColumnSchema{{Name: "x", Type: "complex"}}},
+		{Columns: []ColumnSchema{{Name: "age", Type: "complex"}}},
+		{Columns: []ColumnSchema{{Name: "old", Type: "int"}}},
+		{Indexes: []IndexSchema{{Name: "x", Kind: "index", Column: "age"}}},
+		{Indexes: []IndexSchema{{Name: "name_bloom", Kind: "index", Column: "name"}}},
+	} {
+		_, err := players.Reconcile(spec)
+		assert.Error(t, err)
+	}
+
+	// Nothing is changed by an invalid spec
+	assert.Equal(t, newSchemaFixture().ExportSchema(), players.ExportSchema())
+}
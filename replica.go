@@ -0,0 +1,249 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// ReplicaFilter represents the subset of a collection which is shipped to a replica
+type ReplicaFilter struct {
+	Columns []string // The columns to replicate, all of them if none are specified
+	Index   string   // The bitmap index of the rows to replicate, all of them if not specified
+}
+
+// replica represents a destination to which the commits are shipped once filtered
+type replica struct {
+	lock    sync.Mutex    // The lock to serialize the commits shipped
+	dst     commit.Logger // The destination of the commits
+	columns []string      // The columns to replicate, by name
+	index   string        // The bitmap index of the rows to replicate (optional)
+	rows    bitmap.Bitmap // The rows which are present on the replica
+}
+
+// Replicate ships the changes of the collection to a destination, similarly to the Writer of
+// the options, but only the changes of the specified columns and of the rows which are part
+// of the specified bitmap index, for example an index of the active rows. The filtering is
+// done before the commits are shipped, so that a replica such as an edge cache only receives
+// and keeps the subset it needs. Once a row becomes part of the index, all of its replicated
+// columns are shipped along with an insert marker, and once it leaves the index or is deleted,
+// a delete marker is shipped. The rows which are already part of the index are shipped first,
+// while the transactions are excluded. The returned function stops the replication.
+func (c *Collection) Replicate(dst commit.Logger, filter ReplicaFilter) (func(), error) {
+	if filter.Index != "" {
+		if index, ok := c.cols.Load(filter.Index); !ok || !index.IsIndex() {
+			return nil, fmt.Errorf("column: unable to replicate, index '%s' does not exist", filter.Index)
+		}
+	}
+
+	// If no columns are specified, replicate all of the columns which are not indexes
+	columns := filter.Columns
+	if len(columns) == 0 {
+		c.cols.Range(func(column *column) {
+			if !column.IsIndex() {
+				columns = append(columns, column.name)
+			}
+		})
+	}
+
+	for _, name := range columns {
+		if column, ok := c.cols.Load(name); !ok || column.IsIndex() {
+			return nil, errorOf(ErrColumnNotFound, "column: unable to replicate, column '%s' does not exist", name)
+		}
+	}
+
+	r := &replica{
+		dst:     dst,
+		columns: append([]string(nil), columns...),
+		index:   filter.Index,
+	}
+	sort.Strings(r.columns)
+
+	// Exclude the transactions while the current rows are shipped, so that none is missed
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+	if err := r.hydrate(c); err != nil {
+		return nil, err
+	}
+
+	c.replicas = append(c.replicas, r)
+	c.observers.add(1)
+	return func() {
+		c.txlock.Lock()
+		defer c.txlock.Unlock()
+		for i, v := range c.replicas {
+			if v == r {
+				c.replicas = append(c.replicas[:i], c.replicas[i+1:]...)
+				c.observers.add(-1)
+				break
+			}
+		}
+	}, nil
+}
+
+// hydrate ships the rows which are currently part of the index, one chunk at a time
+func (r *replica) hydrate(c *Collection) error {
+	for chunk := commit.Chunk(0); int(chunk) < c.chunks(); chunk++ {
+		c.lock.RLock()
+		fill := chunk.OfBitmap(c.fill).Clone(nil)
+		c.lock.RUnlock()
+
+		updates := r.acquire(c)
+		fill.Range(func(x uint32) {
+			if idx := chunk.Min() + x; r.contains(c, idx) {
+				r.rows.Set(idx)
+				r.insert(c, idx, updates)
+			}
+		})
+
+		if err := r.append(c, commit.Next(), chunk, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ship ships the changes of a chunk committed by a transaction. This is called while the
+// chunk is locked, once the updates are applied to the columns.
+func (r *replica) ship(txn *Txn, commitID uint64, chunk commit.Chunk) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	c := txn.owner
+
+	// Find the rows changed in the chunk, relative to the start of the chunk
+	var changed bitmap.Bitmap
+	for _, u := range txn.updates {
+		txn.reader.Range(u, chunk, func(rd *commit.Reader) {
+			for rd.Next() {
+				changed.Set(rd.Index() - chunk.Min())
+			}
+		})
+	}
+
+	// Insert the rows which joined the replica and delete the ones which left it
+	var updated bitmap.Bitmap
+	updates := r.acquire(c)
+	changed.Range(func(x uint32) {
+		idx := chunk.Min() + x
+		switch present, had := r.contains(c, idx), r.rows.Contains(idx); {
+		case present && !had:
+			r.rows.Set(idx)
+			r.insert(c, idx, updates)
+		case present:
+			updated.Set(x)
+		case had:
+			r.rows.Remove(idx)
+			updates[0].PutOperation(commit.Delete, idx)
+		}
+	})
+
+	// Copy the changes of the replicated columns for the rows already on the replica
+	for _, u := range txn.updates {
+		i := sort.SearchStrings(r.columns, u.Column)
+		if i == len(r.columns) || r.columns[i] != u.Column {
+			continue
+		}
+
+		column, ok := c.cols.Load(u.Column)
+		if !ok {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(rd *commit.Reader) {
+			for rd.Next() {
+				if updated.Contains(rd.Index() - chunk.Min()) {
+					copyResolved(updates[i+1], column, rd)
+				}
+			}
+		})
+	}
+
+	return r.append(c, commitID, chunk, updates)
+}
+
+// contains checks whether a row should be present on the replica
+func (r *replica) contains(c *Collection, idx uint32) bool {
+	c.lock.RLock()
+	exists := c.fill.Contains(idx)
+	c.lock.RUnlock()
+	if !exists || r.index == "" {
+		return exists
+	}
+
+	index, ok := c.cols.Load(r.index)
+	return ok && index.Contains(idx)
+}
+
+// insert writes an insert marker and the values of all of the replicated columns of a row
+func (r *replica) insert(c *Collection, idx uint32, updates []*commit.Buffer) {
+	updates[0].PutOperation(commit.Insert, idx)
+	for i, name := range r.columns {
+		column, ok := c.cols.Load(name)
+		if !ok {
+			continue
+		}
+
+		if _, isBool := column.Column.(*columnBool); isBool {
+			if column.Contains(idx) {
+				updates[i+1].PutBool(idx, true)
+			}
+			continue
+		}
+
+		if value, ok := column.Value(idx); ok {
+			updates[i+1].PutAny(commit.Put, idx, value)
+		}
+	}
+}
+
+// acquire acquires the buffers for the markers and for each of the replicated columns
+func (r *replica) acquire(c *Collection) []*commit.Buffer {
+	updates := make([]*commit.Buffer, 0, len(r.columns)+1)
+	updates = append(updates, c.txns.acquirePage(rowColumn))
+	for _, name := range r.columns {
+		updates = append(updates, c.txns.acquirePage(name))
+	}
+	return updates
+}
+
+// append appends the commit of a chunk to the destination, unless nothing has changed, and
+// releases the buffers afterwards.
+func (r *replica) append(c *Collection, commitID uint64, chunk commit.Chunk, updates []*commit.Buffer) (err error) {
+	change := commit.Commit{ID: commitID, Chunk: chunk}
+	for _, u := range updates {
+		if !u.IsEmpty() {
+			change.Updates = append(change.Updates, u)
+		}
+	}
+
+	if len(change.Updates) > 0 {
+		err = r.dst.Append(change)
+	}
+
+	for _, u := range updates {
+		c.txns.releasePage(u)
+	}
+	return
+}
+
+// copyResolved copies the current operation of the reader into the buffer. Since the
+// additions are swapped with the resulting values once committed, they are copied as puts.
+func copyResolved(dst *commit.Buffer, column *column, r *commit.Reader) {
+	_, isBool := column.Column.(*columnBool)
+	switch {
+	case isBool:
+		dst.PutBool(r.Index(), r.Bool())
+	case r.Type == commit.Delete:
+		dst.PutOperation(commit.Delete, r.Index())
+	case r.Type == commit.Add:
+		dst.PutAny(commit.Put, r.Index(), valueOf(column.Column, r))
+	default:
+		dst.PutAny(r.Type, r.Index(), valueOf(column.Column, r))
+	}
+}
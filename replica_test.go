@@ -0,0 +1,127 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicateFiltered(t *testing.T) {
+	primary := NewCollection()
+	primary.CreateColumn("name", ForString())
+	primary.CreateColumn("email", ForString())
+	primary.CreateColumn("active", ForBool())
+	primary.CreateColumn("balance", ForFloat64())
+	primary.CreateIndex("online", "active", func(r Reader) bool {
+		return r.Bool()
+	})
+	defer primary.Close()
+
+	primary.InsertObject(Object{"name": "a", "email": "a@x", "active": true, "balance": 10.0})
+	primary.InsertObject(Object{"name": "b", "email": "b@x", "active": false, "balance": 20.0})
+	primary.InsertObject(Object{"name": "c", "email": "c@x", "active": true, "balance": 30.0})
+
+	// The replica only keeps the active rows, without their email
+	replica := NewCollection()
+	replica.CreateColumn("name", ForString())
+	replica.CreateColumn("active", ForBool())
+	replica.CreateColumn("balance", ForFloat64())
+	defer replica.Close()
+
+	writer := make(commit.Channel, 1024)
+	stop, err := primary.Replicate(&writer, ReplicaFilter{
+		Columns: []string{"name", "active", "balance"},
+		Index:   "online",
+	})
+	assert.NoError(t, err)
+
+	replay := func() {
+		for len(writer) > 0 {
+			change := <-writer
+			for _, u := range change.Updates {
+				assert.NotEqual(t, "email", u.Column)
+			}
+			assert.NoError(t, replica.Replay(change))
+		}
+	}
+
+	replay()
+	assert.Equal(t, 2, replica.Count())
+
+	// Changes of the active rows are shipped, and rows join or leave with the index
+	primary.InsertObject(Object{"name": "d", "email": "d@x", "active": true, "balance": 40.0})
+	primary.InsertObject(Object{"name": "e", "email": "e@x", "active": false, "balance": 50.0})
+	assert.NoError(t, primary.QueryAt(0, func(r Row) error {
+		r.AddFloat64("balance", 5)
+		r.SetString("email", "new@x")
+		return nil
+	}))
+	assert.NoError(t, primary.QueryAt(1, func(r Row) error {
+		r.SetBool("active", true)
+		return nil
+	}))
+	assert.NoError(t, primary.QueryAt(2, func(r Row) error {
+		r.SetBool("active", false)
+		return nil
+	}))
+	primary.DeleteAt(3)
+
+	replay()
+	assert.Equal(t, 2, replica.Count())
+	assert.NoError(t, replica.QueryAt(0, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, 15.0, balance)
+		return nil
+	}))
+	assert.NoError(t, replica.QueryAt(1, func(r Row) error {
+		name, _ := r.String("name")
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, "b", name)
+		assert.Equal(t, 20.0, balance)
+		assert.True(t, r.Bool("active"))
+		return nil
+	}))
+	assert.NoError(t, replica.QueryAt(2, func(r Row) error {
+		_, ok := r.String("name")
+		assert.False(t, ok)
+		return nil
+	}))
+
+	// Once stopped, nothing is shipped anymore
+	stop()
+	primary.InsertObject(Object{"name": "f", "active": true})
+	assert.Empty(t, writer)
+}
+
+func TestReplicateErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateIndex("named", "name", func(r Reader) bool {
+		return r.String() != ""
+	})
+	defer col.Close()
+
+	writer := make(commit.Channel, 10)
+	for _, filter := range []ReplicaFilter{
+		{Index: "missing"},
+		{Index: "name"},
+		{Columns: []string{"missing"}},
+		{Columns: []string{"named"}},
+	} {
+		_, err := col.Replicate(&writer, filter)
+		assert.Error(t, err)
+	}
+
+	// All of the columns are replicated by default
+	col.InsertObject(Object{"name": "roman"})
+	stop, err := col.Replicate(&writer, ReplicaFilter{})
+	assert.NoError(t, err)
+	defer stop()
+
+	change := <-writer
+	assert.Len(t, change.Updates, 2)
+}
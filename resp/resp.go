@@ -0,0 +1,417 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package resp provides a listener which speaks the Redis protocol (RESP) and maps a few
+// of its commands onto a collection with a primary key, so that existing Redis clients
+// and tools can read the rows. GET and SET read and write a single column of the row with
+// the key, HGET and HGETALL read its columns as the fields of a hash, and SCAN iterates
+// over the keys.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kelindar/column"
+)
+
+// errClosed is returned when the server is already closed
+var errClosed = errors.New("resp: server is closed")
+
+// Options represents the options of the server.
+type Options struct {
+	Value    string                                  // The column read by GET and written by SET
+	ReadOnly bool                                    // Whether SET is rejected (optional)
+	Parse    func(value string) (interface{}, error) // The parser of the values written by SET (optional)
+}
+
+// Server represents a listener which serves a collection over the Redis protocol.
+type Server struct {
+	collection *column.Collection
+	opts       Options
+	lock       sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]struct{}
+	closed     bool
+}
+
+// New creates a new server for a collection with a primary key. The values written with SET
+// are stored as strings, unless a parser is specified in the options.
+func New(collection *column.Collection, opts Options) *Server {
+	if opts.Parse == nil {
+		opts.Parse = func(value string) (interface{}, error) {
+			return value, nil
+		}
+	}
+
+	return &Server{
+		collection: collection,
+		opts:       opts,
+		listeners:  make(map[net.Listener]struct{}, 1),
+		conns:      make(map[net.Conn]struct{}, 16),
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves the connections until the server is
+// closed.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts the connections of the listener and serves each of them in a goroutine,
+// until the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	if !s.track(listener, nil) {
+		listener.Close()
+		return errClosed
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return errClosed
+			}
+			return err
+		}
+
+		if !s.track(nil, conn) {
+			conn.Close()
+			return errClosed
+		}
+
+		go s.serve(conn)
+	}
+}
+
+// Close closes the listeners and all of the open connections.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+// track keeps track of a listener or a connection, unless the server is closed
+func (s *Server) track(listener net.Listener, conn net.Conn) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case s.closed:
+		return false
+	case listener != nil:
+		s.listeners[listener] = struct{}{}
+	case conn != nil:
+		s.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+// isClosed returns whether the server is closed
+func (s *Server) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+// serve reads the commands of a connection and writes their replies
+func (s *Server) serve(conn net.Conn) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err != io.EOF {
+				writeError(w, err.Error())
+				w.Flush()
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.execute(w, args)
+		if err := w.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// execute executes a command and writes its reply, and returns whether the connection
+// should be closed
+func (s *Server) execute(w *bufio.Writer, args []string) bool {
+	switch name := strings.ToUpper(args[0]); {
+	case name == "QUIT":
+		writeSimple(w, "OK")
+		return true
+	case name == "PING" && len(args) == 1:
+		writeSimple(w, "PONG")
+	case (name == "PING" || name == "ECHO") && len(args) == 2:
+		writeBulk(w, args[1], true)
+	case name == "COMMAND":
+		writeArray(w, nil)
+	case name == "SELECT" && len(args) == 2:
+		writeSimple(w, "OK")
+	case name == "DBSIZE" && len(args) == 1:
+		writeInt(w, s.collection.Count())
+	case name == "EXISTS" && len(args) > 1:
+		writeInt(w, s.exists(args[1:]))
+	case name == "GET" && len(args) == 2:
+		value, ok := s.get(args[1], s.opts.Value)
+		writeBulk(w, value, ok)
+	case name == "HGET" && len(args) == 3:
+		value, ok := s.get(args[1], args[2])
+		writeBulk(w, value, ok)
+	case name == "HGETALL" && len(args) == 2:
+		writeArray(w, s.hgetall(args[1]))
+	case name == "SET" && len(args) == 3:
+		if err := s.set(args[1], args[2]); err != nil {
+			writeError(w, err.Error())
+			return false
+		}
+		writeSimple(w, "OK")
+	case name == "SCAN" && len(args) >= 2:
+		cursor, keys, err := s.scan(args[1:])
+		if err != nil {
+			writeError(w, err.Error())
+			return false
+		}
+
+		fmt.Fprintf(w, "*2\r\n")
+		writeBulk(w, strconv.FormatUint(uint64(cursor), 10), true)
+		writeArray(w, keys)
+	default:
+		writeError(w, fmt.Sprintf("unknown command or wrong number of arguments for '%s'", args[0]))
+	}
+	return false
+}
+
+// --------------------------- Commands ----------------------------
+
+// exists returns the number of keys which exist
+func (s *Server) exists(keys []string) (n int) {
+	for _, key := range keys {
+		if _, ok := s.collection.IndexOf(key); ok {
+			n++
+		}
+	}
+	return
+}
+
+// get reads the value of a column of the row with the key
+func (s *Server) get(key, columnName string) (value string, found bool) {
+	idx, ok := s.collection.IndexOf(key)
+	if !ok || !s.hasColumn(columnName) {
+		return "", false
+	}
+
+	s.collection.QueryAt(idx, func(r column.Row) error {
+		var v interface{}
+		if v, found = r.Any(columnName); found {
+			value = fmt.Sprint(v)
+		}
+		return nil
+	})
+	return
+}
+
+// hgetall reads all of the columns of the row with the key, as field and value pairs
+func (s *Server) hgetall(key string) (out []string) {
+	idx, ok := s.collection.IndexOf(key)
+	if !ok {
+		return nil
+	}
+
+	columns := s.collection.Columns()
+	s.collection.QueryAt(idx, func(r column.Row) error {
+		for _, name := range columns {
+			if v, ok := r.Any(name); ok {
+				out = append(out, name, fmt.Sprint(v))
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// set writes the value of the row with the key, which is inserted if it does not exist
+func (s *Server) set(key, value string) error {
+	if s.opts.ReadOnly || s.opts.Value == "" {
+		return errors.New("READONLY the collection can not be written")
+	}
+
+	v, err := s.opts.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid value, %v", err)
+	}
+
+	return s.collection.QueryKey(key, func(r column.Row) error {
+		r.SetAny(s.opts.Value, v)
+		return nil
+	})
+}
+
+// scan iterates over the keys, starting from the row at the cursor, and returns the next
+// cursor which is zero once all of the rows have been iterated over
+func (s *Server) scan(args []string) (next uint32, keys []string, err error) {
+	cursor, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return 0, nil, errors.New("invalid cursor")
+	}
+
+	// Parse the options of the scan
+	match, count := "*", 10
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				return 0, nil, errors.New("value is not an integer or out of range")
+			}
+		}
+	}
+
+	err = s.collection.Query(func(txn *column.Txn) error {
+		scanned := 0
+		txn.Rows()(func(idx uint32, r column.Row) bool {
+			if idx < uint32(cursor) {
+				return true
+			}
+
+			if scanned == count {
+				next = idx
+				return false
+			}
+
+			scanned++
+			if key, ok := r.Key(); ok {
+				if matched, _ := path.Match(match, key); matched {
+					keys = append(keys, key)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	return
+}
+
+// hasColumn returns whether the collection has the column
+func (s *Server) hasColumn(columnName string) bool {
+	for _, name := range s.collection.Columns() {
+		if name == columnName {
+			return true
+		}
+	}
+	return false
+}
+
+// --------------------------- Protocol ----------------------------
+
+// readCommand reads a command, either as an array of bulk strings or inline
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > 1024 {
+		return nil, errors.New("ERR Protocol error: invalid multibulk length")
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimPrefix(header, "$"))
+		if !strings.HasPrefix(header, "$") || err != nil || size < 0 || size > 512<<20 {
+			return nil, errors.New("ERR Protocol error: invalid bulk length")
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by CRLF
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeSimple writes a simple string
+func writeSimple(w *bufio.Writer, value string) {
+	fmt.Fprintf(w, "+%s\r\n", value)
+}
+
+// writeError writes an error, prefixed with ERR unless it already has a prefix
+func writeError(w *bufio.Writer, message string) {
+	if prefix := strings.SplitN(message, " ", 2)[0]; prefix != strings.ToUpper(prefix) {
+		message = "ERR " + message
+	}
+	fmt.Fprintf(w, "-%s\r\n", message)
+}
+
+// writeInt writes an integer
+func writeInt(w *bufio.Writer, value int) {
+	fmt.Fprintf(w, ":%d\r\n", value)
+}
+
+// writeBulk writes a bulk string, or a null one if the value was not found
+func writeBulk(w *bufio.Writer, value string, found bool) {
+	if !found {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+}
+
+// writeArray writes an array of bulk strings
+func writeArray(w *bufio.Writer, values []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(values))
+	for _, v := range values {
+		writeBulk(w, v, true)
+	}
+}
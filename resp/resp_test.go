@@ -0,0 +1,192 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	players := newPlayers(25)
+	defer players.Close()
+
+	client, closer := serve(t, New(players, Options{Value: "name"}))
+	defer closer()
+
+	assert.Equal(t, "+PONG", client.do("PING"))
+	assert.Equal(t, "$5 hello", client.do("ECHO", "hello"))
+	assert.Equal(t, ":25", client.do("DBSIZE"))
+	assert.Equal(t, ":2", client.do("EXISTS", "player-1", "player-2", "player-99"))
+
+	// Reads of the rows
+	assert.Equal(t, "$6 Roman1", client.do("GET", "player-1"))
+	assert.Equal(t, "$-1", client.do("GET", "player-99"))
+	assert.Equal(t, "$2 10", client.do("HGET", "player-1", "level"))
+	assert.Equal(t, "$-1", client.do("HGET", "player-1", "missing"))
+	assert.Equal(t, "*6 $5 level $2 10 $4 name $6 Roman1 $6 serial $8 player-1", client.do("HGETALL", "player-1"))
+	assert.Equal(t, "*0", client.do("HGETALL", "player-99"))
+
+	// Writes of the value, which insert a row if missing
+	assert.Equal(t, "+OK", client.do("SET", "player-1", "Alice"))
+	assert.Equal(t, "$5 Alice", client.do("GET", "player-1"))
+	assert.Equal(t, "+OK", client.do("SET", "player-99", "Bob"))
+	assert.Equal(t, "$3 Bob", client.do("GET", "player-99"))
+	assert.Equal(t, ":26", client.do("DBSIZE"))
+
+	// Unknown commands
+	assert.True(t, strings.HasPrefix(client.do("FLUSHALL"), "-ERR unknown command"))
+	assert.True(t, strings.HasPrefix(client.do("GET"), "-ERR"))
+	assert.Equal(t, "+OK", client.do("QUIT"))
+}
+
+func TestServerScan(t *testing.T) {
+	players := newPlayers(25)
+	defer players.Close()
+
+	client, closer := serve(t, New(players, Options{Value: "name"}))
+	defer closer()
+
+	// Iterate until the cursor is back to zero
+	var keys []string
+	cursor := "0"
+	for i := 0; i < 10; i++ {
+		reply := strings.Fields(client.do("SCAN", cursor, "COUNT", "10"))
+		cursor = reply[2]
+		for j := 5; j < len(reply); j += 2 {
+			keys = append(keys, reply[j])
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+
+	assert.Equal(t, "0", cursor)
+	assert.Len(t, keys, 25)
+
+	// Match a pattern
+	reply := client.do("SCAN", "0", "MATCH", "player-1*", "COUNT", "100")
+	assert.Equal(t, "*2 $1 0 *11", reply[:strings.Index(reply, "*11")+3])
+	assert.True(t, strings.HasPrefix(client.do("SCAN", "x"), "-ERR"))
+}
+
+func TestServerReadOnly(t *testing.T) {
+	players := newPlayers(5)
+	defer players.Close()
+
+	client, closer := serve(t, New(players, Options{
+		Value:    "level",
+		ReadOnly: true,
+	}))
+	defer closer()
+
+	assert.Equal(t, "$2 10", client.do("GET", "player-1"))
+	assert.True(t, strings.HasPrefix(client.do("SET", "player-1", "5"), "-READONLY"))
+}
+
+func TestServerParse(t *testing.T) {
+	players := newPlayers(5)
+	defer players.Close()
+
+	client, closer := serve(t, New(players, Options{
+		Value: "level",
+		Parse: func(value string) (interface{}, error) {
+			return strconv.Atoi(value)
+		},
+	}))
+	defer closer()
+
+	assert.Equal(t, "+OK", client.do("SET", "player-1", "42"))
+	assert.Equal(t, "$2 42", client.do("GET", "player-1"))
+	assert.True(t, strings.HasPrefix(client.do("SET", "player-1", "x"), "-ERR invalid value"))
+}
+
+func TestServerClose(t *testing.T) {
+	server := New(newPlayers(0), Options{})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	assert.NoError(t, server.Close())
+	assert.Equal(t, errClosed, <-done)
+	assert.Equal(t, errClosed, server.ListenAndServe("127.0.0.1:0"))
+}
+
+// --------------------------- Client ----------------------------
+
+// client represents a minimal client which flattens the replies on a single line
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// serve starts the server on a local listener and connects a client to it
+func serve(t *testing.T, server *Server) (*client, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go server.Serve(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	return &client{conn: conn, reader: bufio.NewReader(conn)}, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+// do sends a command and reads its reply
+func (c *client) do(args ...string) string {
+	fmt.Fprintf(c.conn, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.conn, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	return strings.Join(c.read(), " ")
+}
+
+// read reads a reply as a list of tokens
+func (c *client) read() []string {
+	line, _ := readLine(c.reader)
+	switch {
+	case strings.HasPrefix(line, "$") && line != "$-1":
+		value, _ := readLine(c.reader)
+		return []string{line, value}
+	case strings.HasPrefix(line, "*"):
+		n, _ := strconv.Atoi(line[1:])
+		out := []string{line}
+		for i := 0; i < n; i++ {
+			out = append(out, c.read()...)
+		}
+		return out
+	default:
+		return []string{line}
+	}
+}
+
+// newPlayers creates a collection of players with a primary key
+func newPlayers(n int) *column.Collection {
+	players := column.NewCollection()
+	players.CreateColumn("serial", column.ForKey())
+	players.CreateColumn("name", column.ForString())
+	players.CreateColumn("level", column.ForInt())
+	for i := 0; i < n; i++ {
+		players.InsertObject(column.Object{
+			"serial": "player-" + strconv.Itoa(i),
+			"name":   "Roman" + strconv.Itoa(i),
+			"level":  i * 10,
+		})
+	}
+	return players
+}
@@ -0,0 +1,56 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy represents the policy to retry the transactions which were aborted by a
+// concurrent one, such as a conditional write which conflicted or a lock which timed out.
+type RetryPolicy struct {
+	Attempts   int           // The maximum number of attempts, including the first one, 3 by default
+	Backoff    time.Duration // The delay before the first retry, doubled for every retry, 1ms by default
+	MaxBackoff time.Duration // The maximum delay between two attempts, 100ms by default
+}
+
+// QueryWithRetry executes a transaction in the same way as Query() and, if it returns
+// ErrConflict or ErrLockTimeout, executes it again after a delay which doubles with every
+// attempt and is randomly jittered, so that the transactions which conflicted do not collide
+// again. The error of the last attempt is returned once the attempts are exhausted, and any
+// other error is returned right away. Since a transaction whose conditional writes conflicted
+// is rolled back as a whole, the function can safely be executed several times.
+func (c *Collection) QueryWithRetry(policy RetryPolicy, fn func(txn *Txn) error) error {
+	if policy.Attempts <= 0 {
+		policy.Attempts = 3
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 100 * time.Millisecond
+	}
+
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := c.Query(fn)
+		if attempt >= policy.Attempts || !retryable(err) {
+			return err
+		}
+
+		// Wait between half and the entirety of the backoff, then double it
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		backoff *= 2
+	}
+}
+
+// retryable returns whether a transaction aborted with the error can be retried
+func retryable(err error) bool {
+	return errors.Is(err, ErrConflict) || errors.Is(err, ErrLockTimeout)
+}
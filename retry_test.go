@@ -0,0 +1,77 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryWithRetry(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// The first attempt conflicts with a concurrent transaction and is retried
+	attempts := 0
+	assert.NoError(t, players.QueryWithRetry(RetryPolicy{}, func(txn *Txn) error {
+		attempts++
+		txn.QueryAt(1, func(r Row) error {
+			balance, _ := r.Float64("balance")
+			r.SetIf("balance", balance, balance+10)
+			return nil
+		})
+
+		if attempts > 1 {
+			return nil
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- players.QueryAt(1, func(r Row) error {
+				r.SetFloat64("balance", 100)
+				return nil
+			})
+		}()
+		return <-done
+	}))
+
+	assert.Equal(t, 2, attempts)
+	assert.NoError(t, players.QueryAt(1, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, 110.0, balance)
+		return nil
+	}))
+}
+
+func TestQueryWithRetryExhausted(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	attempts := 0
+	start := time.Now()
+	err := players.QueryWithRetry(RetryPolicy{
+		Attempts:   4,
+		Backoff:    2 * time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+	}, func(txn *Txn) error {
+		attempts++
+		return ErrLockTimeout
+	})
+
+	assert.Equal(t, ErrLockTimeout, err)
+	assert.Equal(t, 4, attempts)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(5*time.Millisecond))
+
+	// The other errors are not retried
+	attempts = 0
+	err = players.QueryWithRetry(RetryPolicy{}, func(txn *Txn) error {
+		attempts++
+		return fmt.Errorf("failed")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,209 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/kelindar/bitmap"
+)
+
+// The cookies of the portable roaring format, with and without run containers
+const (
+	roaringCookie     = 12346
+	roaringCookieRuns = 12347
+	roaringArrayMax   = 4096 // The maximum cardinality of an array container
+	roaringNoOffsets  = 4    // The number of containers below which run bitmaps have no offsets
+)
+
+// errRoaring is returned when a bitmap is not in the portable roaring format
+var errRoaring = errors.New("invalid roaring bitmap")
+
+// Bitmap returns a copy of the current selection, serialized in the portable roaring
+// bitmap format, which can be read by the roaring libraries of most languages. This allows
+// to intersect the selection with bitmaps computed by other systems.
+func (txn *Txn) Bitmap() []byte {
+	txn.initialize()
+	return encodeRoaring(txn.index)
+}
+
+// WithBitmap filters down the selection to the rows which are present in the specified
+// bitmap, serialized in the portable roaring bitmap format. If the bitmap can not be read,
+// the transaction is aborted with an error.
+func (txn *Txn) WithBitmap(data []byte) *Txn {
+	other, err := decodeRoaring(data)
+	if err != nil {
+		txn.err = fmt.Errorf("column: unable to read bitmap, %w", err)
+		return txn
+	}
+
+	txn.filter(filterBitmap, "", other)
+	return txn
+}
+
+// withBitmap applies a logical AND operation to the current query and the bitmap.
+func (txn *Txn) withBitmap(other bitmap.Bitmap) {
+	defer txn.trace("WithBitmap", "", true)()
+	for i := range txn.index {
+		if i < len(other) {
+			txn.index[i] &= other[i]
+		} else {
+			txn.index[i] = 0
+		}
+	}
+}
+
+// encodeRoaring encodes the bitmap in the portable roaring format, without run containers.
+// Every non-empty block of 65536 values is stored as a sorted array if it has few values,
+// or as a bitmap otherwise.
+func encodeRoaring(src bitmap.Bitmap) []byte {
+	type container struct {
+		key   uint16
+		count int
+		words []uint64
+	}
+
+	containers := make([]container, 0, 4)
+	for at := 0; at < len(src); at += 1024 {
+		words := src[at:]
+		if len(words) > 1024 {
+			words = words[:1024]
+		}
+
+		count := 0
+		for _, w := range words {
+			count += bits.OnesCount64(w)
+		}
+
+		if count > 0 {
+			containers = append(containers, container{key: uint16(at >> 10), count: count, words: words})
+		}
+	}
+
+	// Write the cookie, the descriptive header and the offset header
+	size := 8 + 8*len(containers)
+	out := make([]byte, size, size+len(containers)*2*roaringArrayMax)
+	binary.LittleEndian.PutUint32(out[0:], roaringCookie)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(containers)))
+	for i, c := range containers {
+		binary.LittleEndian.PutUint16(out[8+4*i:], c.key)
+		binary.LittleEndian.PutUint16(out[10+4*i:], uint16(c.count-1))
+	}
+
+	for i, c := range containers {
+		binary.LittleEndian.PutUint32(out[8+4*len(containers)+4*i:], uint32(len(out)))
+		if c.count <= roaringArrayMax {
+			for j, w := range c.words {
+				for ; w != 0; w &= w - 1 {
+					out = append(out, 0, 0)
+					binary.LittleEndian.PutUint16(out[len(out)-2:], uint16(j<<6+bits.TrailingZeros64(w)))
+				}
+			}
+			continue
+		}
+
+		for j := 0; j < 1024; j++ {
+			var w uint64
+			if j < len(c.words) {
+				w = c.words[j]
+			}
+
+			out = append(out, 0, 0, 0, 0, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint64(out[len(out)-8:], w)
+		}
+	}
+	return out
+}
+
+// decodeRoaring decodes a bitmap in the portable roaring format, with or without run
+// containers.
+func decodeRoaring(data []byte) (bitmap.Bitmap, error) {
+	if len(data) < 4 {
+		return nil, errRoaring
+	}
+
+	// Read the cookie, the number of containers and the run flags
+	var count int
+	var runs []byte
+	at := 4
+	switch cookie := binary.LittleEndian.Uint32(data); {
+	case cookie == roaringCookie:
+		if len(data) < 8 {
+			return nil, errRoaring
+		}
+		count = int(binary.LittleEndian.Uint32(data[4:]))
+		at = 8
+	case cookie&0xffff == roaringCookieRuns:
+		count = int(cookie>>16) + 1
+		if len(data) < at+(count+7)/8 {
+			return nil, errRoaring
+		}
+		runs = data[at : at+(count+7)/8]
+		at += len(runs)
+	default:
+		return nil, errRoaring
+	}
+
+	// Read the descriptive header, and skip the offset header if present
+	if count > 1<<16 || len(data) < at+4*count {
+		return nil, errRoaring
+	}
+
+	header := data[at : at+4*count]
+	at += 4 * count
+	if runs == nil || count >= roaringNoOffsets {
+		at += 4 * count
+	}
+
+	var out bitmap.Bitmap
+	for i := 0; i < count; i++ {
+		base := uint32(binary.LittleEndian.Uint16(header[4*i:])) << 16
+		cardinality := int(binary.LittleEndian.Uint16(header[4*i+2:])) + 1
+		switch {
+		case runs != nil && runs[i/8]&(1<<(i%8)) != 0:
+			if len(data) < at+2 {
+				return nil, errRoaring
+			}
+
+			n := int(binary.LittleEndian.Uint16(data[at:]))
+			at += 2
+			if len(data) < at+4*n {
+				return nil, errRoaring
+			}
+
+			for j := 0; j < n; j++ {
+				start := uint32(binary.LittleEndian.Uint16(data[at+4*j:]))
+				length := uint32(binary.LittleEndian.Uint16(data[at+4*j+2:]))
+				for x := start; x <= start+length; x++ {
+					out.Set(base + x)
+				}
+			}
+			at += 4 * n
+		case cardinality <= roaringArrayMax:
+			if len(data) < at+2*cardinality {
+				return nil, errRoaring
+			}
+
+			for j := 0; j < cardinality; j++ {
+				out.Set(base + uint32(binary.LittleEndian.Uint16(data[at+2*j:])))
+			}
+			at += 2 * cardinality
+		default:
+			if len(data) < at+8192 {
+				return nil, errRoaring
+			}
+
+			block := int(base >> 6)
+			out.Grow(base + 65535)
+			for j := 0; j < 1024; j++ {
+				out[block+j] = binary.LittleEndian.Uint64(data[at+8*j:])
+			}
+			at += 8192
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/kelindar/bitmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitmap(t *testing.T) {
+	players := loadPlayers(500)
+	var olds, humans []byte
+	players.Query(func(txn *Txn) error {
+		olds = txn.With("old").Bitmap()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		humans = txn.With("human").Bitmap()
+		return nil
+	})
+
+	// Seeding a query with the bitmap intersects the selections
+	var expect int
+	players.Query(func(txn *Txn) error {
+		expect = txn.With("old", "human").Count()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		assert.NotZero(t, expect)
+		assert.Equal(t, expect, txn.WithBitmap(olds).WithBitmap(humans).Count())
+		return nil
+	})
+
+	// An invalid bitmap aborts the transaction
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.WithBitmap([]byte{1, 2, 3}).Count()
+		return nil
+	}))
+}
+
+func TestRoaringCodec(t *testing.T) {
+	var src bitmap.Bitmap
+	for i := uint32(0); i < 10000; i++ {
+		src.Set(i * 3) // Dense enough for a bitmap container
+	}
+	src.Set(200000) // Sparse array container
+	src.Set(200005)
+
+	out, err := decodeRoaring(encodeRoaring(src))
+	assert.NoError(t, err)
+	assert.Equal(t, src.Count(), out.Count())
+	src.Range(func(x uint32) {
+		assert.True(t, out.Contains(x))
+	})
+}
+
+func TestRoaringRuns(t *testing.T) {
+	// A bitmap with a single run container [10, 14], as written by other roaring libraries
+	data := make([]byte, 4+1+4+2+4)
+	binary.LittleEndian.PutUint32(data[0:], roaringCookieRuns)
+	data[4] = 1
+	binary.LittleEndian.PutUint16(data[5:], 0)
+	binary.LittleEndian.PutUint16(data[7:], 4)
+	binary.LittleEndian.PutUint16(data[9:], 1)
+	binary.LittleEndian.PutUint16(data[11:], 10)
+	binary.LittleEndian.PutUint16(data[13:], 4)
+
+	out, err := decodeRoaring(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, out.Count())
+	assert.True(t, out.Contains(10))
+	assert.True(t, out.Contains(14))
+
+	_, err = decodeRoaring(data[:12])
+	assert.Error(t, err)
+}
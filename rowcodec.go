@@ -0,0 +1,228 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"time"
+)
+
+// rowVersion is the version of the binary format of the rows
+const rowVersion = 1
+
+// The types of the values of an encoded row
+const (
+	rowBool   = 1 // A boolean, as a byte
+	rowInt    = 2 // A signed integer or a duration, as a varint
+	rowUint   = 3 // An unsigned integer, as an uvarint
+	rowFloat  = 4 // A floating-point number, as 8 bytes in big-endian order
+	rowString = 5 // A string, prefixed by its length
+	rowBytes  = 6 // The binary form of an address, a 128-bit integer or a map, prefixed by its length
+)
+
+// EncodeRow encodes the values of a row into a compact binary form, so that the row can be
+// cached in a key-value store or sent over an RPC without converting it to JSON. Every value
+// is tagged with the name of its column and its type, so that the rows can be decoded by a
+// collection whose columns were added, removed or widened since. The columns without a value
+// in the row are not encoded, except the boolean columns which are encoded as false.
+func (c *Collection) EncodeRow(idx uint32) (out []byte, err error) {
+	c.lock.RLock()
+	exists := c.fill.Contains(idx)
+	c.lock.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("column: unable to encode row %d, row does not exist", idx)
+	}
+
+	names := c.Columns()
+	err = c.QueryAt(idx, func(r Row) error {
+		out, err = encodeRow(r, names)
+		return err
+	})
+	return
+}
+
+// DecodeRowInto decodes a row encoded with EncodeRow() and writes its values into a row of
+// a transaction, typically within Insert() or QueryKey(). The numbers are converted to the
+// type of their column, the values of the columns which do not exist are skipped, and the
+// columns of the row which have no encoded value are left unchanged.
+func DecodeRowInto(data []byte, dst Row) error {
+	r := rowReader{data: data}
+	if version := r.byte(); version != rowVersion {
+		return fmt.Errorf("column: unable to decode row, unsupported version %d", version)
+	}
+
+	for count := r.uvarint(); count > 0 && r.err == nil; count-- {
+		name := string(r.bytes())
+		value := r.value()
+		if r.err != nil {
+			break
+		}
+
+		column, ok := dst.txn.columnAt(name)
+		if !ok || column.IsIndex() {
+			continue
+		}
+
+		mutation := Set(name, value)
+		converted, err := mutation.valueOf(column.Column)
+		if err != nil {
+			return err
+		}
+		dst.SetAny(name, converted)
+	}
+	return r.err
+}
+
+// encodeRow encodes the values of the specified columns of the current row
+func encodeRow(r Row, names []string) ([]byte, error) {
+	var scratch [binary.MaxVarintLen64]byte
+	out := make([]byte, 0, 16*len(names))
+	out = append(out, rowVersion)
+
+	values := make([]interface{}, 0, len(names))
+	present := names[:0:0]
+	for _, name := range names {
+		v, ok := r.Any(name)
+		if column, exists := r.txn.columnAt(name); exists && !ok {
+			_, ok = column.Column.(*columnBool)
+		}
+
+		if ok {
+			values = append(values, v)
+			present = append(present, name)
+		}
+	}
+
+	out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(present)))]...)
+	for i, name := range present {
+		out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(name)))]...)
+		out = append(out, name...)
+
+		switch v := values[i].(type) {
+		case bool:
+			out = append(out, rowBool, byte(bit(v)))
+		case int, int8, int16, int32, int64, time.Duration:
+			out = append(out, rowInt)
+			out = append(out, scratch[:binary.PutVarint(scratch[:], reflect.ValueOf(v).Int())]...)
+		case uint, uint8, uint16, uint32, uint64:
+			out = append(out, rowUint)
+			out = append(out, scratch[:binary.PutUvarint(scratch[:], reflect.ValueOf(v).Uint())]...)
+		case float32, float64:
+			binary.BigEndian.PutUint64(scratch[:8], math.Float64bits(reflect.ValueOf(v).Float()))
+			out = append(out, rowFloat)
+			out = append(out, scratch[:8]...)
+		case string:
+			out = append(out, rowString)
+			out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(v)))]...)
+			out = append(out, v...)
+		case net.IP:
+			out = append(out, rowBytes)
+			out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(v)))]...)
+			out = append(out, v...)
+		case interface{ Encode() []byte }:
+			b := v.Encode()
+			out = append(out, rowBytes)
+			out = append(out, scratch[:binary.PutUvarint(scratch[:], uint64(len(b)))]...)
+			out = append(out, b...)
+		default:
+			return nil, fmt.Errorf("column: unable to encode row, unsupported type %T of column '%s'", v, name)
+		}
+	}
+	return out, nil
+}
+
+// rowReader represents a reader of an encoded row, which records the first error
+type rowReader struct {
+	data []byte
+	err  error
+}
+
+// fail records an error for a truncated or invalid row
+func (r *rowReader) fail() {
+	if r.err == nil {
+		r.err = fmt.Errorf("column: unable to decode row, invalid encoding")
+	}
+	r.data = nil
+}
+
+// byte reads a single byte
+func (r *rowReader) byte() byte {
+	if len(r.data) < 1 {
+		r.fail()
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+// uvarint reads an unsigned varint
+func (r *rowReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+// varint reads a signed varint
+func (r *rowReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+// bytes reads a sequence of bytes prefixed by its length
+func (r *rowReader) bytes() []byte {
+	size := r.uvarint()
+	if size > uint64(len(r.data)) {
+		r.fail()
+		return nil
+	}
+
+	b := r.data[:size]
+	r.data = r.data[size:]
+	return b
+}
+
+// value reads a value along with its type
+func (r *rowReader) value() interface{} {
+	switch typ := r.byte(); typ {
+	case rowBool:
+		return r.byte() != 0
+	case rowInt:
+		return r.varint()
+	case rowUint:
+		return r.uvarint()
+	case rowFloat:
+		if len(r.data) < 8 {
+			r.fail()
+			return nil
+		}
+
+		v := math.Float64frombits(binary.BigEndian.Uint64(r.data[:8]))
+		r.data = r.data[8:]
+		return v
+	case rowString:
+		return string(r.bytes())
+	case rowBytes:
+		return append([]byte(nil), r.bytes()...)
+	default:
+		r.fail()
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeRow(t *testing.T) {
+	input := loadPlayers(500)
+	defer input.Close()
+
+	output := newEmpty(500)
+	defer output.Close()
+
+	// Copy every row through its binary form
+	for idx := uint32(0); idx < 500; idx++ {
+		data, err := input.EncodeRow(idx)
+		assert.NoError(t, err)
+
+		_, err = output.Insert(func(r Row) error {
+			return DecodeRowInto(data, r)
+		})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, input.Count(), output.Count())
+	for _, idx := range []uint32{0, 1, 250, 499} {
+		assert.Equal(t, objectAt(input, idx), objectAt(output, idx))
+	}
+
+	_, err := input.EncodeRow(10000)
+	assert.Error(t, err)
+}
+
+func TestEncodeRowTypes(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("id", ForKey())
+	input.CreateColumn("level", ForInt8())
+	input.CreateColumn("ttl", ForDuration())
+	input.CreateColumn("mana", ForUint16())
+	input.CreateColumn("ip", ForIP())
+	input.CreateColumn("balance", ForInt128())
+	input.CreateColumn("props", ForMap())
+	input.CreateColumn("banned", ForBool())
+	input.CreateColumn("dropped", ForString())
+	idx, _ := input.Insert(func(r Row) error {
+		r.SetKey("merlin")
+		r.SetInt8("level", 12)
+		r.SetAny("ttl", 5*time.Second)
+		r.SetUint16("mana", 300)
+		r.SetAny("ip", net.ParseIP("10.0.0.1"))
+		r.SetAny("balance", Int128Of(-42))
+		r.SetAny("props", Map{"guild": "druids", "rank": int64(3)})
+		r.SetString("dropped", "value")
+		return nil
+	})
+
+	data, err := input.EncodeRow(idx)
+	assert.NoError(t, err)
+
+	// Decode into a collection whose columns were widened or dropped since
+	output := NewCollection()
+	output.CreateColumn("id", ForKey())
+	output.CreateColumn("level", ForInt64())
+	output.CreateColumn("ttl", ForDuration())
+	output.CreateColumn("mana", ForFloat64())
+	output.CreateColumn("ip", ForIP())
+	output.CreateColumn("balance", ForInt128())
+	output.CreateColumn("props", ForMap())
+	output.CreateColumn("banned", ForBool())
+	assert.NoError(t, output.Query(func(txn *Txn) error {
+		_, err := txn.Insert(func(r Row) error {
+			r.SetBool("banned", true)
+			return DecodeRowInto(data, r)
+		})
+		return err
+	}))
+
+	assert.NoError(t, output.QueryKey("merlin", func(r Row) error {
+		level, _ := r.Int64("level")
+		ttl, _ := r.Duration("ttl")
+		mana, _ := r.Float64("mana")
+		ip, _ := r.Any("ip")
+		balance, _ := r.Any("balance")
+		props, _ := r.Any("props")
+		assert.Equal(t, int64(12), level)
+		assert.Equal(t, 5*time.Second, ttl)
+		assert.Equal(t, 300.0, mana)
+		assert.Equal(t, "10.0.0.1", ip.(net.IP).String())
+		assert.Equal(t, Int128Of(-42), balance)
+		assert.Equal(t, Map{"guild": "druids", "rank": int64(3)}, props)
+		assert.False(t, r.Bool("banned"))
+		return nil
+	}))
+}
+
+func TestDecodeRowInvalid(t *testing.T) {
+	input := loadPlayers(500)
+	defer input.Close()
+
+	data, err := input.EncodeRow(0)
+	assert.NoError(t, err)
+
+	for _, invalid := range [][]byte{nil, {2}, data[:len(data)-1]} {
+		_, err := input.Insert(func(r Row) error {
+			return DecodeRowInto(invalid, r)
+		})
+		assert.Error(t, err)
+	}
+
+	// The numbers are converted to the type of their column, but not the strings
+	other := NewCollection()
+	other.CreateColumn("name", ForFloat64())
+	_, err = other.Insert(func(r Row) error {
+		return DecodeRowInto(data, r)
+	})
+	assert.Error(t, err)
+}
+
+// objectAt returns the values of a row as an object
+func objectAt(c *Collection, idx uint32) Object {
+	out := make(Object)
+	c.QueryAt(idx, func(r Row) error {
+		for _, name := range c.Columns() {
+			if v, ok := r.Any(name); ok {
+				out[name] = v
+			}
+		}
+		return nil
+	})
+	return out
+}
@@ -0,0 +1,236 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadOptions represents the options of loading the rows of a SQL query into a collection
+type LoadOptions struct {
+	BatchSize  int              // The number of rows loaded per batch, a chunk by default
+	OnProgress func(loaded int) // The callback called with the number of rows loaded after every batch (optional)
+}
+
+// FromRows loads the rows of a SQL query from any database/sql driver into the collection,
+// batch by batch, and returns the number of rows loaded. The columns of the result which do
+// not exist in the collection are created depending on their SQL type, as reported by the
+// driver: integer, float, bool and string columns for the respective types, and int64 columns
+// of unix nanoseconds for the timestamps. The rows are closed once they are all loaded.
+func (c *Collection) FromRows(rows *sql.Rows, options ...LoadOptions) (int, error) {
+	defer rows.Close()
+
+	var opts LoadOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = chunkSize
+	}
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	// Create the columns which are missing, depending on their type
+	columns := make([]*column, 0, len(types))
+	for _, typ := range types {
+		if _, ok := c.cols.Load(typ.Name()); !ok {
+			if err := c.CreateColumn(typ.Name(), columnForSQL(typ)); err != nil {
+				return 0, err
+			}
+		}
+
+		column, _ := c.cols.Load(typ.Name())
+		if column.IsIndex() {
+			return 0, fmt.Errorf("column: unable to load into index '%s'", typ.Name())
+		}
+		columns = append(columns, column)
+	}
+
+	values := make([]interface{}, len(columns))
+	scan := make([]interface{}, len(columns))
+	for i := range values {
+		scan[i] = &values[i]
+	}
+
+	batch := make(map[string][]interface{}, len(columns))
+	loaded, count := 0, 0
+	flush := func() error {
+		n, err := c.BulkLoad(batch)
+		if loaded += n; err == nil && n > 0 && opts.OnProgress != nil {
+			opts.OnProgress(loaded)
+		}
+
+		for name := range batch {
+			batch[name] = batch[name][:0]
+		}
+		count = 0
+		return err
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(scan...); err != nil {
+			return loaded, err
+		}
+
+		for i, column := range columns {
+			value, err := loadableOf(column, values[i])
+			if err != nil {
+				return loaded, err
+			}
+			batch[column.name] = append(batch[column.name], value)
+		}
+
+		if count++; count == opts.BatchSize {
+			if err := flush(); err != nil {
+				return loaded, err
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return loaded, err
+	}
+
+	err = flush()
+	return loaded, err
+}
+
+// columnForSQL creates a column for the type of a SQL result, by the type it is scanned into
+// or, if the driver does not report it, by the name of the type in the database.
+func columnForSQL(typ *sql.ColumnType) Column {
+	scan := typ.ScanType()
+	if scan != nil && scan.Kind() == reflect.Ptr {
+		scan = scan.Elem()
+	}
+
+	switch scan {
+	case reflect.TypeOf(sql.NullInt64{}):
+		return ForInt64()
+	case reflect.TypeOf(sql.NullInt32{}):
+		return ForInt32()
+	case reflect.TypeOf(sql.NullInt16{}):
+		return ForInt16()
+	case reflect.TypeOf(sql.NullByte{}):
+		return ForUint8()
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return ForFloat64()
+	case reflect.TypeOf(sql.NullBool{}):
+		return ForBool()
+	case reflect.TypeOf(sql.NullString{}), reflect.TypeOf(sql.RawBytes{}), reflect.TypeOf([]byte{}):
+		return ForString()
+	case reflect.TypeOf(sql.NullTime{}), reflect.TypeOf(time.Time{}):
+		return ForInt64()
+	}
+
+	if scan != nil {
+		switch scan.Kind() {
+		case reflect.Int, reflect.Int64:
+			return ForInt64()
+		case reflect.Int32:
+			return ForInt32()
+		case reflect.Int16:
+			return ForInt16()
+		case reflect.Int8:
+			return ForInt8()
+		case reflect.Uint, reflect.Uint64:
+			return ForUint64()
+		case reflect.Uint32:
+			return ForUint32()
+		case reflect.Uint16:
+			return ForUint16()
+		case reflect.Uint8:
+			return ForUint8()
+		case reflect.Float32:
+			return ForFloat32()
+		case reflect.Float64:
+			return ForFloat64()
+		case reflect.Bool:
+			return ForBool()
+		case reflect.String:
+			return ForString()
+		}
+	}
+
+	return columnForType(typ.DatabaseTypeName())
+}
+
+// columnForType creates a column for the name of a SQL type, following the type affinity
+// rules of SQLite, so that most of the dialects are mapped to the expected column.
+func columnForType(name string) Column {
+	name = strings.ToUpper(name)
+	switch {
+	case strings.Contains(name, "BOOL"):
+		return ForBool()
+	case strings.Contains(name, "INT"):
+		return ForInt64()
+	case strings.Contains(name, "CHAR"), strings.Contains(name, "CLOB"), strings.Contains(name, "TEXT"):
+		return ForString()
+	case strings.Contains(name, "TIME"), strings.Contains(name, "DATE"):
+		return ForInt64()
+	case name == "", strings.Contains(name, "BLOB"), strings.Contains(name, "BYTE"):
+		return ForString()
+	default:
+		return ForFloat64()
+	}
+}
+
+// loadableOf converts a value returned by a database to a value which can be loaded into
+// the column with BulkLoad(). The bytes are loaded as strings, the timestamps as unix
+// nanoseconds, and the numbers and strings are converted for the textual, numeric and
+// bool columns where needed.
+func loadableOf(column *column, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		value = string(v)
+	case time.Time:
+		value = v.UnixNano()
+	}
+
+	_, isBool := column.Column.(*columnBool)
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		switch {
+		case isBool:
+			return v != 0, nil
+		case column.IsTextual():
+			return strconv.FormatInt(v, 10), nil
+		}
+	case float64:
+		switch {
+		case isBool:
+			return v != 0, nil
+		case column.IsTextual():
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+	case bool:
+		if column.IsTextual() {
+			return strconv.FormatBool(v), nil
+		}
+	case string:
+		switch {
+		case isBool:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		case column.IsNumeric():
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, nil
+			}
+		}
+	}
+	return value, nil
+}
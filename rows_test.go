@@ -0,0 +1,206 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRows(t *testing.T) {
+	db := openFakeDB(t, 25000, nil)
+	rows, err := db.Query("players")
+	assert.NoError(t, err)
+
+	col := NewCollection()
+	col.CreateColumn("class", ForEnum())
+	defer col.Close()
+
+	var progress []int
+	n, err := col.FromRows(rows, LoadOptions{
+		BatchSize: 10000,
+		OnProgress: func(loaded int) {
+			progress = append(progress, loaded)
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 25000, n)
+	assert.Equal(t, 25000, col.Count())
+	assert.Equal(t, []int{10000, 20000, 25000}, progress)
+	assert.Equal(t, 12500, countWhere(col, func(txn *Txn) *Txn {
+		return txn.With("active")
+	}))
+	assert.Equal(t, 8333, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithValue("class", func(v interface{}) bool { return v == "rogue" })
+	}))
+
+	assert.NoError(t, col.QueryAt(7, func(r Row) error {
+		id, _ := r.Int64("id")
+		name, _ := r.String("name")
+		balance, _ := r.Float64("balance")
+		amount, _ := r.Float64("amount")
+		created, _ := r.Int64("created")
+		assert.Equal(t, int64(7), id)
+		assert.Equal(t, "player 7", name)
+		assert.Equal(t, 10.5, balance)
+		assert.Equal(t, 7.25, amount)
+		assert.Equal(t, time.Unix(7, 0).UnixNano(), created)
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(10, func(r Row) error {
+		_, hasName := r.String("name")
+		_, hasBalance := r.Float64("balance")
+		assert.False(t, hasName)
+		assert.False(t, hasBalance)
+		return nil
+	}))
+}
+
+func TestFromRowsErrors(t *testing.T) {
+	failure := errors.New("connection lost")
+	db := openFakeDB(t, 25000, failure)
+
+	// The rows loaded before the failure are kept
+	col := NewCollection()
+	defer col.Close()
+	rows, err := db.Query("players")
+	assert.NoError(t, err)
+	n, err := col.FromRows(rows)
+	assert.Equal(t, failure, err)
+	assert.Equal(t, chunkSize, n)
+
+	// The values can not be loaded into a column of a different type
+	other := NewCollection()
+	other.CreateColumn("name", ForInt64())
+	defer other.Close()
+	rows, err = db.Query("players")
+	assert.NoError(t, err)
+	n, err = other.FromRows(rows)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+
+	// The values can not be loaded into an index
+	indexed := NewCollection()
+	indexed.CreateColumn("age", ForInt64())
+	indexed.CreateIndex("name", "age", func(r Reader) bool { return true })
+	defer indexed.Close()
+	rows, err = db.Query("players")
+	assert.NoError(t, err)
+	_, err = indexed.FromRows(rows)
+	assert.Error(t, err)
+}
+
+func TestColumnForType(t *testing.T) {
+	for name, expect := range map[string]Column{
+		"BIGINT":           ForInt64(),
+		"VARCHAR(255)":     ForString(),
+		"boolean":          ForBool(),
+		"DOUBLE PRECISION": ForFloat64(),
+		"NUMERIC(10, 2)":   ForFloat64(),
+		"TIMESTAMPTZ":      ForInt64(),
+		"BYTEA":            ForString(),
+		"":                 ForString(),
+	} {
+		assert.IsType(t, expect, columnForType(name), name)
+	}
+}
+
+// --------------------------- Fake Driver ---------------------------
+
+// openFakeDB opens a database which returns the specified number of rows, and fails with
+// the error after a chunk of rows if one is specified.
+func openFakeDB(t *testing.T, count int, failure error) *sql.DB {
+	db := sql.OpenDB(&fakeConnector{count: count, failure: failure})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type fakeConnector struct {
+	count   int
+	failure error
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ *fakeConnector }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c.fakeConnector}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ *fakeConnector }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{fakeConnector: s.fakeConnector}, nil
+}
+
+type fakeRows struct {
+	*fakeConnector
+	next int
+}
+
+var fakeColumns = []struct {
+	name string
+	scan reflect.Type
+	kind string
+}{
+	{"id", reflect.TypeOf(int64(0)), "BIGINT"},
+	{"name", reflect.TypeOf(sql.NullString{}), "VARCHAR"},
+	{"class", reflect.TypeOf(""), "TEXT"},
+	{"balance", reflect.TypeOf(sql.NullFloat64{}), "FLOAT8"},
+	{"active", reflect.TypeOf(true), "BOOL"},
+	{"created", reflect.TypeOf(time.Time{}), "TIMESTAMP"},
+	{"amount", reflect.TypeOf(new(interface{})).Elem(), "NUMERIC"},
+}
+
+func (r *fakeRows) Columns() []string {
+	names := make([]string, 0, len(fakeColumns))
+	for _, v := range fakeColumns {
+		names = append(names, v.name)
+	}
+	return names
+}
+
+func (r *fakeRows) ColumnTypeScanType(i int) reflect.Type   { return fakeColumns[i].scan }
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return fakeColumns[i].kind }
+func (r *fakeRows) Close() error                            { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	i := r.next
+	switch {
+	case r.failure != nil && i == chunkSize:
+		return r.failure
+	case i == r.count:
+		return io.EOF
+	}
+
+	r.next++
+	dest[0] = int64(i)
+	dest[1] = "player " + strconv.Itoa(i)
+	dest[2] = []string{"mage", "rogue", "fighter"}[i%3]
+	dest[3] = float64(i) * 1.5
+	dest[4] = i%2 == 0
+	dest[5] = time.Unix(int64(i), 0)
+	dest[6] = []byte(strconv.FormatFloat(float64(i)+0.25, 'f', 2, 64))
+	if i%10 == 0 {
+		dest[1], dest[3] = nil, nil
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema represents the declarative definition of the columns and of the indexes of a
+// collection, which can be encoded in JSON and kept along with the code of an application.
+type Schema struct {
+	Columns []ColumnSchema `json:"columns"`           // The columns, in the order of their names
+	Indexes []IndexSchema  `json:"indexes,omitempty"` // The indexes, in the order of their names
+}
+
+// ColumnSchema represents the definition of a column and of its options
+type ColumnSchema struct {
+	Name        string   `json:"name"`                  // The name of the column
+	Type        string   `json:"type"`                  // The type of the column, as returned by ColumnType()
+	Encoding    string   `json:"encoding,omitempty"`    // The encoding of a numeric column, "rle" or "delta"
+	Collation   string   `json:"collation,omitempty"`   // The collation of a string or an enum column, "fold"
+	Storage     string   `json:"storage,omitempty"`     // The storage of the strings, "arena" or "interned"
+	Cardinality int      `json:"cardinality,omitempty"` // The maximum number of values of an enum dictionary
+	Dictionary  []string `json:"dictionary,omitempty"`  // The values to seed an enum dictionary with
+	CodeWidth   int      `json:"codeWidth,omitempty"`   // The width of the codes of an enum, in bits
+	Prefix      []int    `json:"prefix,omitempty"`      // The lengths of the IPv4 and IPv6 prefixes indexed
+}
+
+// IndexSchema represents the definition of an index. The kind of the index is "index",
+// "partial" or "expression" for the indexes computed by a function, or "bloom", "lookup" or
+// "dictionary" for the indexes which only depend on the values of their column.
+type IndexSchema struct {
+	Name    string   `json:"name"`              // The name of the index
+	Kind    string   `json:"kind"`              // The kind of the index
+	Column  string   `json:"column,omitempty"`  // The column the index is computed from
+	Columns []string `json:"columns,omitempty"` // The columns of an expression index
+	Scope   string   `json:"scope,omitempty"`   // The scope of a partial index
+}
+
+// constructors are the constructors of the columns, by the name of their type
+var constructors = map[string]func(opts ...ColumnOption) Column{
+	"float32":  ForFloat32,
+	"float64":  ForFloat64,
+	"int":      ForInt,
+	"int8":     ForInt8,
+	"int16":    ForInt16,
+	"int32":    ForInt32,
+	"int64":    ForInt64,
+	"uint":     ForUint,
+	"uint8":    ForUint8,
+	"uint16":   ForUint16,
+	"uint32":   ForUint32,
+	"uint64":   ForUint64,
+	"duration": ForDuration,
+	"string":   ForString,
+	"enum":     ForEnum,
+	"ip":       ForIP,
+	"bool":     func(...ColumnOption) Column { return ForBool() },
+	"key":      func(...ColumnOption) Column { return ForKey() },
+	"map":      func(...ColumnOption) Column { return ForMap() },
+	"int128":   func(...ColumnOption) Column { return ForInt128() },
+}
+
+// ExportSchema returns the definition of the columns and of the indexes of the collection.
+// The functions of the columns and of the indexes, such as the merge functions or the rules
+// of the bitmap indexes, can not be encoded and are left out, and the indexes created by the
+// advisor are omitted since the advisor creates them again on its own.
+func (c *Collection) ExportSchema() *Schema {
+	out := &Schema{
+		Columns: make([]ColumnSchema, 0, 8),
+	}
+
+	c.cols.Range(func(v *column) {
+		switch {
+		case c.isInternal(v.name), strings.HasPrefix(v.name, "auto:"):
+			return
+		case v.IsIndex():
+			if index, ok := indexSchemaOf(v); ok {
+				out.Indexes = append(out.Indexes, index)
+			}
+		default:
+			out.Columns = append(out.Columns, columnSchemaOf(v))
+		}
+	})
+
+	sort.Slice(out.Columns, func(i, j int) bool { return out.Columns[i].Name < out.Columns[j].Name })
+	sort.Slice(out.Indexes, func(i, j int) bool { return out.Indexes[i].Name < out.Indexes[j].Name })
+	return out
+}
+
+// ApplySchema creates the columns and the indexes of the schema which are missing from the
+// collection, so that the schema of a collection can be managed declaratively or copied to
+// a replica. The columns and the indexes which already exist must be of the same type and
+// kind, and the indexes computed by a function must be created beforehand, since their rule
+// can not be described by the schema. The schema is validated entirely before any column is
+// created, and nothing is dropped from the collection.
+func (c *Collection) ApplySchema(schema *Schema) error {
+	columns := make(map[string]Column, len(schema.Columns))
+	for _, spec := range schema.Columns {
+		if existing, ok := c.cols.Load(spec.Name); ok {
+			if typ := typeName(existing.Column); typ != spec.Type || existing.IsIndex() {
+				return fmt.Errorf("column: unable to apply schema, column '%s' is of type %s", spec.Name, typ)
+			}
+			continue
+		}
+
+		column, err := spec.column()
+		if err != nil {
+			return err
+		}
+		columns[spec.Name] = column
+	}
+
+	for _, spec := range schema.Indexes {
+		existing, ok := c.cols.Load(spec.Name)
+		switch {
+		case ok:
+			if current, _ := indexSchemaOf(existing); current.Kind != spec.Kind {
+				return fmt.Errorf("column: unable to apply schema, index '%s' is not a %s index", spec.Name, spec.Kind)
+			}
+		case spec.Kind == "index", spec.Kind == "partial", spec.Kind == "expression":
+			return fmt.Errorf("column: unable to apply schema, index '%s' is computed by a function and must be created first", spec.Name)
+		case spec.Kind == "dictionary" && spec.Name != dictionaryName(spec.Column):
+			return fmt.Errorf("column: unable to apply schema, dictionary index '%s' must be named '%s'", spec.Name, dictionaryName(spec.Column))
+		case spec.Kind != "bloom" && spec.Kind != "lookup" && spec.Kind != "dictionary":
+			return fmt.Errorf("column: unable to apply schema, index '%s' is of unknown kind '%s'", spec.Name, spec.Kind)
+		}
+	}
+
+	// Create the missing columns first, then the indexes which depend on them
+	for _, spec := range schema.Columns {
+		if column, ok := columns[spec.Name]; ok {
+			if err := c.CreateColumn(spec.Name, column); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, spec := range schema.Indexes {
+		if _, ok := c.cols.Load(spec.Name); ok {
+			continue
+		}
+
+		var err error
+		switch spec.Kind {
+		case "bloom":
+			err = c.CreateBloomIndex(spec.Name, spec.Column)
+		case "lookup":
+			err = c.CreateLookup(spec.Name, spec.Column)
+		case "dictionary":
+			err = c.CreateDictionaryIndex(spec.Column)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnSchemaOf returns the definition of a column
+func columnSchemaOf(v *column) ColumnSchema {
+	var opts []ColumnOption
+	if column, ok := v.Column.(interface{ options() []ColumnOption }); ok {
+		opts = column.options()
+	}
+	if column, ok := v.Column.(interface{ Encoding() Encoding }); ok {
+		opts = append(opts, WithEncoding(column.Encoding()))
+	}
+
+	config := configure(opts)
+	out := ColumnSchema{
+		Name:        v.name,
+		Type:        typeName(v.Column),
+		Encoding:    encodingNames[config.encoding],
+		Collation:   collationNames[config.collation],
+		Cardinality: config.cardinality,
+		Dictionary:  config.dictionary,
+		CodeWidth:   config.width,
+	}
+
+	switch {
+	case config.intern:
+		out.Storage = "interned"
+	case config.arena:
+		out.Storage = "arena"
+	}
+
+	if config.prefix != [2]int{} {
+		out.Prefix = []int{config.prefix[0], config.prefix[1]}
+	}
+	return out
+}
+
+// column creates an empty column from its definition
+func (s *ColumnSchema) column() (Column, error) {
+	fn, ok := constructors[s.Type]
+	if !ok {
+		return nil, fmt.Errorf("column: unable to apply schema, column '%s' is of unknown type '%s'", s.Name, s.Type)
+	}
+
+	var opts []ColumnOption
+	switch encoding, ok := encodingOf(s.Encoding); {
+	case !ok:
+		return nil, fmt.Errorf("column: unable to apply schema, column '%s' has unknown encoding '%s'", s.Name, s.Encoding)
+	case encoding != Plain:
+		opts = append(opts, WithEncoding(encoding))
+	}
+
+	switch collation, ok := collationOfName(s.Collation); {
+	case !ok:
+		return nil, fmt.Errorf("column: unable to apply schema, column '%s' has unknown collation '%s'", s.Name, s.Collation)
+	case collation != CollateBinary:
+		opts = append(opts, WithCollation(collation))
+	}
+
+	switch s.Storage {
+	case "":
+	case "arena":
+		opts = append(opts, WithArena())
+	case "interned":
+		opts = append(opts, WithInterning())
+	default:
+		return nil, fmt.Errorf("column: unable to apply schema, column '%s' has unknown storage '%s'", s.Name, s.Storage)
+	}
+
+	if s.Cardinality > 0 {
+		opts = append(opts, WithCardinality(s.Cardinality))
+	}
+	if len(s.Dictionary) > 0 {
+		opts = append(opts, WithDictionary(s.Dictionary...))
+	}
+	if s.CodeWidth > 0 {
+		opts = append(opts, WithCodeWidth(s.CodeWidth))
+	}
+	if len(s.Prefix) == 2 {
+		opts = append(opts, WithPrefixIndex(s.Prefix[0], s.Prefix[1]))
+	}
+	return fn(opts...), nil
+}
+
+// indexSchemaOf returns the definition of an index, if it can be described
+func indexSchemaOf(v *column) (IndexSchema, bool) {
+	out := IndexSchema{Name: v.name}
+	switch index := v.Column.(type) {
+	case *columnIndex:
+		out.Kind, out.Column = "index", index.name
+		if index.scope != nil {
+			out.Kind, out.Scope = "partial", index.scope.name
+		}
+	case *columnExpr:
+		out.Kind = "expression"
+		for _, source := range index.sources {
+			out.Columns = append(out.Columns, source.name)
+		}
+	case *columnBloom:
+		out.Kind, out.Column = "bloom", index.name
+	case *columnLookup:
+		out.Kind, out.Column = "lookup", index.name
+	case *columnDictionary:
+		out.Kind, out.Column = "dictionary", index.name
+	default:
+		return out, false
+	}
+	return out, true
+}
+
+// --------------------------- Names ----------------------------
+
+// encodingNames are the names of the encodings in a schema
+var encodingNames = map[Encoding]string{
+	Plain: "",
+	RLE:   "rle",
+	Delta: "delta",
+}
+
+// collationNames are the names of the collations in a schema
+var collationNames = map[Collation]string{
+	CollateBinary: "",
+	CollateFold:   "fold",
+}
+
+// encodingOf returns the encoding for its name in a schema
+func encodingOf(name string) (Encoding, bool) {
+	for encoding, v := range encodingNames {
+		if v == name {
+			return encoding, true
+		}
+	}
+	return Plain, false
+}
+
+// collationOfName returns the collation for its name in a schema
+func collationOfName(name string) (Collation, bool) {
+	for collation, v := range collationNames {
+		if v == name {
+			return collation, true
+		}
+	}
+	return CollateBinary, false
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportSchema(t *testing.T) {
+	players := newSchemaFixture()
+	defer players.Close()
+
+	schema := players.ExportSchema()
+	assert.Equal(t, []ColumnSchema{
+		{Name: "address", Type: "ip", Prefix: []int{24, 48}},
+		{Name: "age", Type: "int64", Encoding: "delta"},
+		{Name: "class", Type: "enum", Cardinality: 16, Dictionary: []string{"mage", "rogue"}, CodeWidth: 8},
+		{Name: "name", Type: "string", Collation: "fold", Storage: "interned"},
+		{Name: "online", Type: "bool"},
+		{Name: "serial", Type: "key"},
+	}, schema.Columns)
+	assert.Equal(t, []IndexSchema{
+		{Name: "by_name", Kind: "lookup", Column: "name"},
+		{Name: "dictionary:class", Kind: "dictionary", Column: "class"},
+		{Name: "name_bloom", Kind: "bloom", Column: "name"},
+		{Name: "old", Kind: "index", Column: "age"},
+	}, schema.Indexes)
+}
+
+func TestExportSchemaInternal(t *testing.T) {
+	plain := NewCollection()
+	plain.CreateColumn("tombstone", ForString())
+	defer plain.Close()
+
+	// The columns are only internal if the features which need them are enabled
+	assert.Equal(t, []ColumnSchema{
+		{Name: "tombstone", Type: "string"},
+	}, plain.ExportSchema().Columns)
+	assert.Equal(t, []string{"tombstone"}, plain.Columns())
+
+	deletable := NewCollection(Options{SoftDelete: true, Versioned: true})
+	deletable.CreateColumn("name", ForString())
+	defer deletable.Close()
+	assert.Equal(t, []ColumnSchema{
+		{Name: "name", Type: "string"},
+	}, deletable.ExportSchema().Columns)
+	assert.Equal(t, []string{"name"}, deletable.Columns())
+}
+
+func TestApplySchema(t *testing.T) {
+	players := newSchemaFixture()
+	defer players.Close()
+
+	// Encode the schema, as it would be kept along with the code
+	encoded, err := json.Marshal(players.ExportSchema())
+	assert.NoError(t, err)
+
+	var schema Schema
+	assert.NoError(t, json.Unmarshal(encoded, &schema))
+
+	// The indexes computed by a function must be created first
+	replica := NewCollection()
+	defer replica.Close()
+	assert.Error(t, replica.ApplySchema(&schema))
+	assert.Empty(t, replica.Columns())
+
+	replica.CreateColumn("age", ForInt64(WithEncoding(Delta)))
+	replica.CreateIndex("old", "age", func(r Reader) bool { return r.Int() >= 30 })
+	assert.NoError(t, replica.ApplySchema(&schema))
+	assert.Equal(t, players.ExportSchema(), replica.ExportSchema())
+
+	// Applying the schema again does not change anything
+	assert.NoError(t, replica.ApplySchema(&schema))
+	assert.Equal(t, players.ExportSchema(), replica.ExportSchema())
+}
+
+func TestApplySchemaInvalid(t *testing.T) {
+	players := newSchemaFixture()
+	defer players.Close()
+
+	for _, schema := range []Schema{
+		{Columns: []ColumnSchema{{Name: "age", Type: "float64"}}},
+		{Columns: []ColumnSchema{{Name: "x", Type: "complex"}}},
+		{Columns: []ColumnSchema{{Name: "x", Type: "int", Encoding: "zip"}}},
+		{Columns: []ColumnSchema{{Name: "x", Type: "string", Collation: "latin"}}},
+		{Columns: []ColumnSchema{{Name: "x", Type: "string", Storage: "disk"}}},
+		{Indexes: []IndexSchema{{Name: "old", Kind: "lookup", Column: "age"}}},
+		{Indexes: []IndexSchema{{Name: "x", Kind: "dictionary", Column: "class"}}},
+		{Indexes: []IndexSchema{{Name: "x", Kind: "btree", Column: "class"}}},
+	} {
+		assert.Error(t, players.ApplySchema(&schema))
+	}
+}
+
+// newSchemaFixture creates a collection with columns and indexes of various kinds
+func newSchemaFixture() *Collection {
+	out := NewCollection()
+	out.CreateColumn("serial", ForKey())
+	out.CreateColumn("name", ForString(WithInterning(), WithCollation(CollateFold)))
+	out.CreateColumn("class", ForEnum(WithCardinality(16), WithDictionary("mage", "rogue"), WithCodeWidth(8)))
+	out.CreateColumn("age", ForInt64(WithEncoding(Delta)))
+	out.CreateColumn("online", ForBool())
+	out.CreateColumn("address", ForIP(WithPrefixIndex(24, 48)))
+	out.CreateIndex("old", "age", func(r Reader) bool { return r.Int() >= 30 })
+	out.CreateLookup("by_name", "name")
+	out.CreateBloomIndex("name_bloom", "name")
+	out.CreateDictionaryIndex("class")
+	return out
+}
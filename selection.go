@@ -0,0 +1,142 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+
+	"github.com/kelindar/bitmap"
+)
+
+// Selection represents the rows selected by a transaction, which can be kept once the
+// transaction is done and combined with the selection of a later transaction, for example
+// to refine a result step by step as a user adds filters to it interactively.
+type Selection struct {
+	owner   *Collection   // The collection the rows were selected from
+	index   bitmap.Bitmap // The rows selected
+	commits []uint64      // The ID of the last commit of every chunk, once the rows were selected
+}
+
+// Selection returns a copy of the rows currently selected by the transaction. The selection
+// keeps the ID of the last commit of every chunk, so that it is able to tell whether the
+// collection was changed since the rows were selected.
+func (txn *Txn) Selection() *Selection {
+	txn.initialize()
+	out := &Selection{
+		owner: txn.owner,
+		index: txn.index.Clone(nil),
+	}
+
+	txn.owner.lock.RLock()
+	out.commits = append(make([]uint64, 0, len(txn.owner.commits)), txn.owner.commits...)
+	txn.owner.lock.RUnlock()
+	return out
+}
+
+// Keys returns the primary keys of the rows currently selected by the transaction, in the
+// order of the rows. The keys are read from the key column chunk by chunk, without moving
+// the cursor or reading the other columns of the rows. If the collection has no primary key,
+// this returns an error.
+func (txn *Txn) Keys() ([]string, error) {
+	pk := txn.owner.pk
+	if pk == nil {
+		return nil, errNoKey
+	}
+
+	out := make([]string, 0, txn.Count())
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		index.Range(func(x uint32) {
+			if key, ok := pk.LoadString(offset + x); ok {
+				out = append(out, key)
+			}
+		})
+	})
+
+	if err := txn.failed(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Count returns the number of rows in the selection
+func (s *Selection) Count() int {
+	return s.index.Count()
+}
+
+// Contains checks whether the row at the specified index is in the selection
+func (s *Selection) Contains(idx uint32) bool {
+	return s.index.Contains(idx)
+}
+
+// Stale checks whether any chunk of the collection was committed since the rows were
+// selected, in which case the rows may no longer match the filters they were selected with.
+func (s *Selection) Stale() bool {
+	s.owner.lock.RLock()
+	defer s.owner.lock.RUnlock()
+	for i, id := range s.owner.commits {
+		if i >= len(s.commits) && id != 0 || i < len(s.commits) && id != s.commits[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// And applies a logical AND operation to the current query and a selection kept from an
+// earlier transaction on the same collection.
+func (txn *Txn) And(sel *Selection) *Txn {
+	if txn.ownerOf(sel) {
+		txn.filter(filterAnd, "", sel.index)
+	}
+	return txn
+}
+
+// AndNot applies a logical AND NOT operation to the current query and a selection kept
+// from an earlier transaction on the same collection.
+func (txn *Txn) AndNot(sel *Selection) *Txn {
+	if txn.ownerOf(sel) {
+		txn.filter(filterAndNot, "", sel.index)
+	}
+	return txn
+}
+
+// Or computes a union between the current query and a selection kept from an earlier
+// transaction on the same collection. The rows of the selection which were deleted since
+// it was made, or which are otherwise hidden from the transaction, are not added to it.
+func (txn *Txn) Or(sel *Selection) *Txn {
+	if !txn.ownerOf(sel) {
+		return txn
+	}
+
+	txn.initialize()
+	txn.cached = nil
+	txn.record(filterOr, "")
+	defer txn.trace("Or", "", true)()
+
+	// Only the rows of the selection which are still visible to the transaction are added
+	selected := txn.index.Clone(nil)
+	txn.setupIndex()
+	txn.index.And(sel.index)
+	txn.index.Or(selected)
+	return txn
+}
+
+// ownerOf checks whether the selection was made on the collection of the transaction, and
+// aborts the transaction otherwise.
+func (txn *Txn) ownerOf(sel *Selection) bool {
+	switch {
+	case sel == nil:
+		txn.err = fmt.Errorf("column: unable to combine selection, selection is not specified")
+	case sel.owner != txn.owner:
+		txn.err = fmt.Errorf("column: unable to combine selection, it belongs to another collection")
+	default:
+		return true
+	}
+	return false
+}
+
+// withoutBitmap applies a logical AND NOT operation to the current query and the bitmap.
+func (txn *Txn) withoutBitmap(other bitmap.Bitmap) {
+	defer txn.trace("AndNot", "", true)()
+	txn.index.AndNot(other)
+}
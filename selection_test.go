@@ -0,0 +1,118 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelection(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// Select the humans and the mages in separate transactions
+	var humans, mages *Selection
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		humans = txn.With("human").Selection()
+		return nil
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		mages = txn.With("mage").Selection()
+		return nil
+	}))
+
+	assert.False(t, humans.Stale())
+	assert.Equal(t, countWhere(players, func(txn *Txn) *Txn { return txn.With("human") }), humans.Count())
+
+	// Combine the selections in a later transaction
+	combine := func(fn func(txn *Txn) *Txn) int {
+		return countWhere(players, fn)
+	}
+
+	assert.Equal(t, combine(func(txn *Txn) *Txn { return txn.With("human", "mage") }),
+		combine(func(txn *Txn) *Txn { return txn.And(humans).And(mages) }))
+	assert.Equal(t, combine(func(txn *Txn) *Txn { return txn.With("human").Union("mage") }),
+		combine(func(txn *Txn) *Txn { return txn.With("human").Or(mages) }))
+	assert.Equal(t, combine(func(txn *Txn) *Txn { return txn.With("human").Without("mage") }),
+		combine(func(txn *Txn) *Txn { return txn.And(humans).AndNot(mages) }))
+	assert.Equal(t, combine(func(txn *Txn) *Txn { return txn.With("old", "human") }),
+		combine(func(txn *Txn) *Txn { return txn.With("old").And(humans) }))
+
+	// Once a row is deleted, the selection is stale and the row is no longer selected
+	var idx uint32
+	humans.index.Range(func(x uint32) {
+		idx = x
+	})
+
+	assert.True(t, players.DeleteAt(idx))
+	assert.True(t, humans.Stale())
+	assert.True(t, humans.Contains(idx))
+	assert.Equal(t, humans.Count()-1, combine(func(txn *Txn) *Txn { return txn.And(humans) }))
+	assert.Equal(t, humans.Count()-1, combine(func(txn *Txn) *Txn { return txn.With("mage").AndNot(mages).Or(humans) }))
+}
+
+func TestSelectionInvalid(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	other := NewCollection()
+	defer other.Close()
+
+	var sel *Selection
+	other.Query(func(txn *Txn) error {
+		sel = txn.Selection()
+		return nil
+	})
+
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.And(sel)
+		return nil
+	}))
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.Or(nil)
+		return nil
+	}))
+}
+
+func TestKeys(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// The keys are the ones of the selected rows, in their order
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		var expect []string
+		serial := txn.Key()
+		txn.With("mage").Range(func(idx uint32) {
+			key, _ := serial.Get()
+			expect = append(expect, key)
+		})
+
+		keys, err := txn.With("mage").Keys()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, keys)
+		assert.Equal(t, expect, keys)
+		return nil
+	}))
+
+	// An empty selection has no keys
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		keys, err := txn.WithValue("serial", func(v interface{}) bool { return false }).Keys()
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+		return nil
+	}))
+
+	// The collection must have a primary key
+	other := NewCollection()
+	defer other.Close()
+	other.CreateColumn("name", ForString())
+	other.InsertObject(Object{"name": "Roman"})
+	assert.NoError(t, other.Query(func(txn *Txn) error {
+		_, err := txn.Keys()
+		assert.ErrorIs(t, err, ErrColumnNotFound)
+		return nil
+	}))
+}
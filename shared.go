@@ -0,0 +1,339 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/kelindar/column/commit"
+)
+
+const (
+	chunksBuffer  = "chunks" // The versions of the schema and of the chunks, for the shared readers
+	schemaBuffer  = "schema" // The schema of the collection, for the shared readers
+	sharedRetries = 64       // The number of attempts of a shared read before it gives up
+)
+
+// remapper represents a storage which maps read-only the buffers written by another process
+type remapper interface {
+	Storage
+	remap() error
+}
+
+// --------------------------- Writer ----------------------------
+
+// publisher represents the versions and the schema which a collection backed by a storage
+// publishes for the processes reading its buffers. Every version is a sequence which is odd
+// while the chunk, or the schema for the first one, is being written.
+type publisher struct {
+	lock     sync.Mutex // The lock of the schema
+	versions *mapped    // The storage of the versions
+	schema   *mapped    // The storage of the schema
+	seqs     []uint64   // The versions of the schema and of the chunks, guarded by the collection lock
+	encoded  []byte     // The length of the schema, followed by its JSON encoding
+}
+
+// mountShared restores the versions published by the collection from the storage
+func (c *Collection) mountShared() error {
+	p := &publisher{
+		versions: &mapped{storage: c.opts.Storage, name: chunksBuffer},
+		schema:   &mapped{storage: c.opts.Storage, name: schemaBuffer},
+		seqs:     make([]uint64, 1, 64),
+	}
+
+	if err := p.versions.open("", unsafe.Pointer(&p.seqs), 8); err != nil {
+		return err
+	}
+	if err := p.schema.open("", unsafe.Pointer(&p.encoded), 1); err != nil {
+		return err
+	}
+
+	// A writer which stopped in the middle of a commit leaves its chunk odd
+	for i := range p.seqs {
+		p.seqs[i] += p.seqs[i] & 1
+	}
+	c.shared = p
+	return nil
+}
+
+// advance increments the version of a chunk, before and after it is written
+func (c *Collection) advance(chunk commit.Chunk) {
+	c.lock.RLock()
+	if i := int(chunk) + 1; i < len(c.shared.seqs) {
+		atomic.AddUint64(&c.shared.seqs[i], 1)
+	}
+	c.lock.RUnlock()
+}
+
+// growShared grows the versions up to the specified chunk. This must be called while the
+// collection lock is held.
+func (c *Collection) growShared(last commit.Chunk) error {
+	return c.shared.versions.grow("", unsafe.Pointer(&c.shared.seqs), 8, int(last)+2)
+}
+
+// publishSchema publishes the schema of the collection, once a column is created or dropped
+func (c *Collection) publishSchema() error {
+	p := c.shared
+	if p == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(c.ExportSchema())
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	// The previous schema remains published if the buffer can not be grown
+	if err := p.schema.grow("", unsafe.Pointer(&p.encoded), 1, 8+len(encoded)); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&p.seqs[0], 1)
+	binary.LittleEndian.PutUint64(p.encoded, uint64(len(encoded)))
+	copy(p.encoded[8:], encoded)
+	atomic.AddUint64(&p.seqs[0], 1)
+	return nil
+}
+
+// --------------------------- Reader ----------------------------
+
+// SharedReader represents a read-only view of a collection which is written by another
+// process, over the memory-mapped files of its storage. The values are read in place from
+// the files the writer maps, without a copy or a network hop, and the versions of the chunks
+// which the writer publishes tell whether a read overlapped with one of its commits.
+type SharedReader struct {
+	lock    sync.RWMutex // The lock excluding the queries while the view is mapped again
+	dir     string       // The directory of the storage of the writer
+	storage remapper     // The storage of the view
+	view    *Collection  // The read-only collection over the buffers
+	schema  uint64       // The version of the schema the view was created with
+}
+
+// OpenShared opens read-only the collection whose columns are backed by memory-mapped
+// files in the specified directory, as written by another process with the MMap() storage,
+// for example so that a sidecar can run analytical queries on it. Only the numeric and the
+// boolean columns without an encoding are mapped, since the other columns are kept in the
+// memory of the writer. The view follows the schema of the writer, and the columns created
+// or dropped by the writer are mapped or unmapped on the next query.
+func OpenShared(dir string) (*SharedReader, error) {
+	r := &SharedReader{dir: dir}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Query runs a read-only transaction against the rows written by the other process so far.
+// A read which overlaps with a commit of the writer is retried, hence the function may be
+// called more than once. If the chunks keep changing, this returns ErrConflict.
+func (r *SharedReader) Query(fn func(txn *Txn) error) error {
+	for attempt := 0; attempt < sharedRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 10 * time.Microsecond)
+		}
+
+		before, err := r.refresh()
+		switch {
+		case err != nil:
+			return err
+		case before == nil:
+			continue
+		}
+
+		r.lock.RLock()
+		err = r.view.Query(fn)
+		after := r.versions()
+		r.lock.RUnlock()
+		if sameVersions(before, after) {
+			return err
+		}
+	}
+
+	return errorOf(ErrConflict, "column: unable to read shared collection, the chunks kept changing")
+}
+
+// Count returns the number of rows written by the other process so far.
+func (r *SharedReader) Count() (count int) {
+	r.Query(func(txn *Txn) error {
+		count = txn.Count()
+		return nil
+	})
+	return
+}
+
+// Close unmaps the files of the collection.
+func (r *SharedReader) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.view.Close()
+}
+
+// refresh maps the buffers grown by the writer and returns the versions of the chunks as
+// of before they were mapped, or nil if a chunk is being written.
+func (r *SharedReader) refresh() ([]uint64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	before := r.versions()
+	switch {
+	case len(before) == 0 || before[0]&1 == 1:
+		return nil, nil
+	case before[0] != r.schema:
+		if err := r.reload(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	for _, v := range before {
+		if v&1 == 1 {
+			return nil, nil
+		}
+	}
+
+	// Point the columns and the rows at the buffers, as mapped again
+	if err := r.storage.remap(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.view.cols.Range(func(v *column) {
+		if column, ok := v.Column.(mountable); ok && err == nil {
+			err = column.mount(&mapped{storage: r.storage, name: v.name})
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if err := r.view.mountRows(); err != nil {
+		return nil, err
+	}
+	return before, nil
+}
+
+// reload creates the view again, with the current schema of the writer. This must be
+// called while the lock is held.
+func (r *SharedReader) reload() error {
+	storage, err := mmapShared(r.dir)
+	if err != nil {
+		return fmt.Errorf("column: unable to open shared collection, %w", err)
+	}
+
+	versions, err := storage.Open(chunksBuffer)
+	if err != nil || len(versions) < 8 {
+		storage.Close()
+		return fmt.Errorf("column: unable to open shared collection, '%s' is not shared", r.dir)
+	}
+
+	// Read the schema, until it is not written meanwhile
+	var schema Schema
+	var version uint64
+	for attempt := 0; ; attempt++ {
+		if attempt == sharedRetries {
+			storage.Close()
+			return errorOf(ErrConflict, "column: unable to open shared collection, the schema kept changing")
+		}
+
+		if err := storage.remap(); err != nil {
+			storage.Close()
+			return err
+		}
+
+		versions, _ = storage.Open(chunksBuffer)
+		if version = seqAt(versions, 0); version&1 == 1 {
+			continue
+		}
+
+		encoded, err := storage.Open(schemaBuffer)
+		if err != nil {
+			storage.Close()
+			return err
+		}
+
+		if len(encoded) < 8 || binary.LittleEndian.Uint64(encoded) > uint64(len(encoded)-8) {
+			continue
+		}
+
+		size := binary.LittleEndian.Uint64(encoded)
+		payload := append([]byte(nil), encoded[8:8+size]...)
+		if seqAt(versions, 0) == version && json.Unmarshal(payload, &schema) == nil {
+			break
+		}
+	}
+
+	// Create the columns which are backed by the storage, and freeze the view
+	view, err := Open(Options{Capacity: 1, Storage: storage})
+	if err != nil {
+		storage.Close()
+		return err
+	}
+
+	for _, spec := range schema.Columns {
+		column, err := spec.column()
+		if _, ok := column.(mountable); err != nil || !ok || spec.Encoding != "" {
+			continue
+		}
+
+		if err := view.CreateColumn(spec.Name, column); err != nil {
+			view.Close()
+			return err
+		}
+	}
+	view.Freeze()
+
+	if r.view != nil {
+		r.view.Close()
+	}
+
+	r.storage, r.view, r.schema = storage, view, version
+	return nil
+}
+
+// versions returns the versions of the schema and of the chunks, as published by the writer
+func (r *SharedReader) versions() []uint64 {
+	buffer, err := r.storage.Open(chunksBuffer)
+	if err != nil {
+		return nil
+	}
+
+	out := make([]uint64, len(buffer)/8)
+	for i := range out {
+		out[i] = seqAt(buffer, i)
+	}
+	return out
+}
+
+// seqAt atomically loads the version at the specified position of the buffer
+func seqAt(buffer []byte, i int) uint64 {
+	if (i+1)*8 > len(buffer) {
+		return 0
+	}
+	return atomic.LoadUint64((*uint64)(unsafe.Pointer(&buffer[i*8])))
+}
+
+// sameVersions checks whether none of the chunks changed between two versions
+func sameVersions(before, after []uint64) bool {
+	if len(before) != len(after) {
+		return false
+	}
+
+	for i := range before {
+		if before[i] != after[i] {
+			return false
+		}
+	}
+	return true
+}
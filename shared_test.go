@@ -0,0 +1,174 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShared(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewCollection(Options{Storage: MMap(dir)})
+	defer writer.Close()
+	assert.NoError(t, writer.CreateColumn("age", ForFloat64()))
+	assert.NoError(t, writer.CreateColumn("active", ForBool()))
+	assert.NoError(t, writer.CreateColumn("name", ForString()))
+	insertShared(t, writer, 20000)
+
+	reader, err := OpenShared(dir)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	// The numeric and boolean columns are read in place, while the strings are not shared
+	sum := func() (total float64, active int) {
+		assert.NoError(t, reader.Query(func(txn *Txn) error {
+			age := txn.Float64("age")
+			total = 0
+			txn.Range(func(idx uint32) {
+				v, _ := age.Get()
+				total += v
+			})
+
+			active = txn.With("active").Count()
+			return nil
+		}))
+		return
+	}
+
+	total, active := sum()
+	assert.Equal(t, 20000, reader.Count())
+	assert.Equal(t, float64(19999*20000/2), total)
+	assert.Equal(t, 10000, active)
+	assert.Error(t, reader.Query(func(txn *Txn) error {
+		txn.String("name")
+		return txn.failed()
+	}))
+
+	// The rows inserted and changed by the writer are visible, including the new chunks
+	insertShared(t, writer, 20000)
+	assert.NoError(t, writer.QueryAt(0, func(r Row) error {
+		r.SetFloat64("age", 1000)
+		return nil
+	}))
+
+	total, active = sum()
+	assert.Equal(t, 40000, reader.Count())
+	assert.Equal(t, float64(2*19999*20000/2+1000), total)
+	assert.Equal(t, 20000, active)
+
+	// The columns created by the writer are mapped on the next query
+	assert.NoError(t, writer.CreateColumn("score", ForInt64()))
+	assert.NoError(t, writer.QueryAt(5, func(r Row) error {
+		r.SetInt64("score", 42)
+		return nil
+	}))
+	assert.NoError(t, reader.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.With("score").Count())
+		return nil
+	}))
+
+	// The view is read-only
+	assert.ErrorIs(t, reader.Query(func(txn *Txn) error {
+		return txn.QueryAt(0, func(r Row) error {
+			r.SetFloat64("age", 1)
+			return nil
+		})
+	}), ErrReadOnly)
+}
+
+func TestSharedConsistency(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewCollection(Options{Storage: MMap(dir)})
+	defer writer.Close()
+	assert.NoError(t, writer.CreateColumn("a", ForInt64()))
+	assert.NoError(t, writer.CreateColumn("b", ForInt64()))
+	assert.NoError(t, writer.Query(func(txn *Txn) error {
+		for i := 0; i < 50000; i++ {
+			txn.InsertObject(Object{"a": int64(100), "b": int64(0)})
+		}
+		return nil
+	}))
+
+	reader, err := OpenShared(dir)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	// The writer moves amounts between the columns, which always add up to 100
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			writer.Query(func(txn *Txn) error {
+				a, b := txn.Int64("a"), txn.Int64("b")
+				return txn.Range(func(idx uint32) {
+					a.Add(-1)
+					b.Add(1)
+				})
+			})
+		}
+	}()
+
+	// The reads which overlap with a commit of the writer midway are retried
+	for i := 0; i < 20; i++ {
+		var total int64
+		assert.NoError(t, reader.Query(func(txn *Txn) error {
+			a, b := txn.Int64("a"), txn.Int64("b")
+			total = 0
+			return txn.Range(func(idx uint32) {
+				x, _ := a.Get()
+				y, _ := b.Get()
+				total += x + y
+			})
+		}))
+		assert.Equal(t, int64(100*50000), total)
+	}
+	wg.Wait()
+}
+
+func TestOpenSharedInvalid(t *testing.T) {
+	_, err := OpenShared(t.TempDir() + "/missing")
+	assert.Error(t, err)
+
+	_, err = OpenShared(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestOpenSharedUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	writer := NewCollection(Options{Storage: MMap(dir)})
+	assert.NoError(t, writer.CreateColumn("age", ForFloat64()))
+	assert.NoError(t, writer.CreateColumn("active", ForBool()))
+	assert.NoError(t, writer.CreateColumn("name", ForString()))
+	insertShared(t, writer, 100)
+	assert.NoError(t, writer.Close())
+
+	// A buffer which can not be mapped is reported rather than panicking
+	assert.NoError(t, os.Remove(filepath.Join(dir, rowsBuffer)))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, rowsBuffer), 0755))
+	assert.NotPanics(t, func() {
+		_, err := OpenShared(dir)
+		assert.Error(t, err)
+	})
+}
+
+// insertShared inserts rows with increasing ages, half of them active
+func insertShared(t *testing.T, col *Collection, n int) {
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		for i := 0; i < n; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetFloat64("age", float64(i))
+				r.SetBool("active", i%2 == 0)
+				r.SetString("name", "Roman")
+				return nil
+			})
+		}
+		return nil
+	}))
+}
@@ -4,36 +4,124 @@
 package column
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
 	"github.com/kelindar/iostream"
-	"github.com/klauspost/compress/s2"
 )
 
 var (
 	errUnexpectedEOF = errors.New("column: unable to restore, unexpected EOF")
+
+	// ErrUnsupportedVersion is returned when a snapshot was written in a version of the
+	// format which can not be read, typically by a newer version of the library. The error
+	// returned is a *VersionError which records the version.
+	ErrUnsupportedVersion = errors.New("column: unsupported snapshot version")
+)
+
+const (
+	snapshotMagic   = "COLS" // The magic of a snapshot with a versioned header
+	snapshotVersion = 0x3    // The version of the snapshot format which is written
+	snapshotLegacy  = 0x1    // The version of the snapshots written without a header
 )
 
+// VersionError represents an error returned when a snapshot was written in a version of
+// the format which can not be read. It matches ErrUnsupportedVersion with errors.Is().
+type VersionError struct {
+	Version uint64 // The version the snapshot was written in
+	Min     uint64 // The oldest version which can be read
+	Max     uint64 // The newest version which can be read
+}
+
+// Error returns the error message
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("column: unable to restore, version %d is not supported (expected %d to %d)",
+		e.Version, e.Min, e.Max)
+}
+
+// Is returns whether the error matches the target
+func (e *VersionError) Is(target error) bool {
+	return target == ErrUnsupportedVersion
+}
+
 // --------------------------- Commit Replay ---------------------------
 
-// Replay replays a commit on a collection, applying the changes.
+// Replay replays a commit on a collection, applying the changes. Since the additions are
+// swapped with the resulting values once committed, they are replayed as puts so that the
+// same commit can be replayed over a snapshot which may already contain it. If a conflict
+// policy is specified in the options, the changes of the values which were changed by a
+// newer commit are resolved according to it.
 func (c *Collection) Replay(change commit.Commit) error {
-	return c.Query(func(txn *Txn) error {
+	var state *replayState
+	if c.versions != nil {
+		state = &replayState{id: change.ID}
+	}
+
+	if err := c.Query(func(txn *Txn) error {
+		txn.replay = state
 		txn.dirty.Set(uint32(change.Chunk))
 		for i := range change.Updates {
 			if !change.Updates[i].IsEmpty() {
-				txn.updates = append(txn.updates, change.Updates[i])
+				txn.updates = append(txn.updates, txn.resolveAdds(change.Chunk, change.Updates[i]))
 			}
 		}
 		return nil
+	}); err != nil || state == nil || len(state.conflicts) == 0 {
+		return err
+	}
+
+	// Report the conflicts once the commit is replayed, outside of the locks
+	policy := c.opts.Conflicts
+	if policy.OnConflict != nil {
+		for _, conflict := range state.conflicts {
+			policy.OnConflict(conflict)
+		}
+	}
+
+	if policy.Resolve == RejectConflicts {
+		return &ConflictError{Conflicts: state.conflicts}
+	}
+	return nil
+}
+
+// resolveAdds copies the operations of a chunk, replacing the additions with puts of the
+// values they hold, or returns the buffer as is if there are no additions.
+func (txn *Txn) resolveAdds(chunk commit.Chunk, u *commit.Buffer) *commit.Buffer {
+	column, ok := txn.owner.cols.Load(u.Column)
+	if !ok {
+		return u
+	}
+
+	adds := false
+	txn.reader.Range(u, chunk, func(r *commit.Reader) {
+		for r.Next() && !adds {
+			adds = r.Type == commit.Add
+		}
 	})
+	if !adds {
+		return u
+	}
+
+	resolved := txn.owner.txns.acquirePage(u.Column)
+	txn.reader.Range(u, chunk, func(r *commit.Reader) {
+		for r.Next() {
+			copyResolved(resolved, column, r)
+		}
+	})
+
+	txn.owner.txns.releasePage(u)
+	return resolved
 }
 
 // --------------------------- Snapshotting ---------------------------
@@ -41,37 +129,157 @@ func (c *Collection) Replay(change commit.Commit) error {
 // Restore restores the collection from the underlying snapshot reader. This operation
 // should be called before any of transactions, right after initialization.
 func (c *Collection) Restore(snapshot io.Reader) error {
-	commits, err := c.readState(s2.NewReader(snapshot))
+	_, err := c.restore(snapshot)
+	return err
+}
+
+// restore restores the collection from a snapshot and returns the last commit IDs of its
+// chunks, including the commits appended to the snapshot.
+func (c *Collection) restore(snapshot io.Reader) ([]uint64, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+
+	if keys := c.opts.Encryption; keys != nil {
+		decrypter, err := newDecrypter(snapshot, keys)
+		if err != nil {
+			return nil, err
+		}
+		snapshot = decrypter
+	}
+
+	state, codec, err := readHeader(snapshot)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	reader, err := c.decompressor(state, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := c.readState(reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if err := reader.Close(); err != nil {
+		return nil, err
+	}
+
+	// Count the restored rows, since the counters are only maintained by the commits
+	if c.counters.tracked() {
+		c.counters.recount(c)
+	}
+	if c.opts.SoftDelete {
+		c.recountTombstones()
 	}
 
 	// Reconcile the pending commit log
-	return commit.Open(snapshot).Range(func(commit commit.Commit) error {
+	if err := commit.Open(snapshot).Range(func(commit commit.Commit) error {
 		lastCommit := commits[commit.Chunk]
 		if commit.ID > lastCommit {
+			commits[commit.Chunk] = commit.ID
 			return c.Replay(commit)
 		}
 		return nil
-	})
+	}); err != nil {
+		return commits, err
+	}
+
+	// Like a clone, the chunks keep the IDs of their last commit in the snapshot, so that
+	// the scans resumed from a checkpoint can tell whether they changed since.
+	c.lock.Lock()
+	for chunk, commitID := range commits {
+		if chunk < len(c.commits) {
+			c.commits[chunk] = commitID
+		}
+	}
+	c.lock.Unlock()
+	return commits, nil
 }
 
 // Snapshot writes a collection snapshot into the underlying writer.
-func (c *Collection) Snapshot(dst io.Writer) error {
+func (c *Collection) Snapshot(dst io.Writer) (err error) {
+	return c.snapshot(dst, nil)
+}
+
+// snapshot writes a collection snapshot into the underlying writer. If specified, the
+// barrier is called once the state is written, before the commits recorded meanwhile
+// are appended to the snapshot.
+func (c *Collection) snapshot(dst io.Writer, barrier func()) (err error) {
+	_, span := c.trace(context.Background(), SpanSnapshot)
+	defer func() { span.End(err) }()
+
+	if c.opts.Observer != nil {
+		start := time.Now()
+		defer func() {
+			c.opts.Observer.ObserveSnapshot(time.Since(start), err)
+		}()
+	}
+
+	var written int64
+	if c.logs() {
+		start := time.Now()
+		defer func() {
+			level, message := LevelInfo, "snapshot completed"
+			if err != nil {
+				level, message = LevelError, "snapshot failed"
+			}
+
+			c.log(level, EventSnapshot, message, err,
+				FieldChunks, c.chunks(),
+				FieldBytes, written,
+				FieldDuration, time.Since(start),
+			)
+		}()
+	}
+
 	recorder, err := c.recorderOpen()
 	if err != nil {
 		return err
 	}
 
-	// Take a snapshot of the current state
+	// If encryption is enabled, encrypt both the state and the pending commits
 	defer os.Remove(recorder.Name())
-	if _, err := c.writeState(s2.NewWriter(dst)); err != nil {
+	var encrypter *encrypter
+	if keys := c.opts.Encryption; keys != nil {
+		if encrypter, err = newEncrypter(dst, keys); err != nil {
+			c.recorderClose()
+			return err
+		}
+		dst = encrypter
+	}
+
+	// Take a snapshot of the current state, after the header
+	codec := c.codec()
+	if err := writeHeader(dst, codec); err != nil {
+		c.recorderClose()
+		return err
+	}
+
+	writer, err := c.compressor(dst, codec)
+	if err != nil {
+		c.recorderClose()
+		return err
+	}
+	if written, err = c.writeState(writer); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		c.recorderClose()
 		return err
 	}
 
 	// Close the recorder
+	if barrier != nil {
+		barrier()
+	}
 	c.recorderClose()
-	return recorder.Copy(dst)
+	if err := recorder.Copy(dst); err != nil || encrypter == nil {
+		return err
+	}
+	return encrypter.Close()
 }
 
 // recorderOpen opens a recorder for commits while the snapshot is in progress
@@ -82,15 +290,16 @@ func (c *Collection) recorderOpen() (log *commit.Log, err error) {
 		if !atomic.CompareAndSwapPointer(dst, nil, ptr) {
 			return nil, fmt.Errorf("column: unable to snapshot, another one might be in progress")
 		}
+		c.observers.add(1)
 	}
 	return
 }
 
 // recorderClose closes the pending commit recorder and deletes the file
 func (c *Collection) recorderClose() {
-	if _, ok := c.isSnapshotting(); ok {
-		dst := (*unsafe.Pointer)(unsafe.Pointer(&c.record))
-		atomic.StorePointer(dst, nil)
+	dst := (*unsafe.Pointer)(unsafe.Pointer(&c.record))
+	if atomic.SwapPointer(dst, nil) != nil {
+		c.observers.add(-1)
 	}
 }
 
@@ -105,39 +314,260 @@ func (c *Collection) isSnapshotting() (*commit.Log, bool) {
 	return (*commit.Log)(ptr), true
 }
 
+// writeHeader writes the header of a snapshot, with the version of its format and the
+// codec its state is compressed with
+func writeHeader(dst io.Writer, codec byte) error {
+	var header [7]byte
+	copy(header[:4], snapshotMagic)
+	binary.BigEndian.PutUint16(header[4:], snapshotVersion)
+	header[6] = codec
+	_, err := dst.Write(header[:])
+	return err
+}
+
+// readHeader reads the header of a snapshot and checks that its version can be read. The
+// snapshots written before the header was introduced start with the compressed state, so
+// the bytes read are returned to the state in that case. The snapshots written before the
+// codec was recorded are all compressed with s2.
+func readHeader(src io.Reader) (io.Reader, byte, error) {
+	var header [7]byte
+	if err := readFull(src, header[:4]); err != nil {
+		return nil, 0, err
+	}
+
+	if string(header[:4]) != snapshotMagic {
+		return io.MultiReader(bytes.NewReader(header[:4]), src), codecS2, nil
+	}
+
+	if err := readFull(src, header[4:6]); err != nil {
+		return nil, 0, err
+	}
+
+	switch version := uint64(binary.BigEndian.Uint16(header[4:])); {
+	case version < snapshotLegacy || version > snapshotVersion:
+		return nil, 0, &VersionError{Version: version, Min: snapshotLegacy, Max: snapshotVersion}
+	case version < 0x3:
+		return src, codecS2, nil
+	default:
+		if err := readFull(src, header[6:]); err != nil {
+			return nil, 0, err
+		}
+		return src, header[6], nil
+	}
+}
+
+// readFull reads exactly enough bytes to fill the destination
+func readFull(src io.Reader, dst []byte) error {
+	switch _, err := io.ReadFull(src, dst); err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return errUnexpectedEOF
+	default:
+		return err
+	}
+}
+
+// --------------------------- Collection Copy ---------------------------
+
+// clone creates a deep copy of the collection, including its schema, indexes and data. The
+// chunks are read-locked for the duration of the copy so that no commit can be applied
+// to the collection while the copy is in progress. The copy has the same options, except
+// for the commit log, storage, checkpoints, backend, audit log and eviction policy, which
+// belong to the collection and would otherwise receive the changes of the copy as well.
+func (c *Collection) clone() (*Collection, error) {
+	out, err := c.cloneSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	// Acquire the read locks on all of the chunks at once, so the copy is consistent
+	c.txlock.RLock()
+	defer c.txlock.RUnlock()
+	chunks := c.chunks()
+	for shard := 0; shard < chunks && shard < lockShards; shard++ {
+		c.slock.RLock(uint(shard))
+		defer c.slock.RUnlock(uint(shard))
+	}
+
+	if err := c.copyChunks(out, chunks, func(*column) bool { return true }); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// cloneSchema creates an empty collection with the options, schema and properties of the
+// collection, for clone().
+func (c *Collection) cloneSchema() (*Collection, error) {
+	opts := c.opts
+	opts.Writer, opts.Audit, opts.Eviction = nil, nil, nil
+	opts.Storage, opts.SpillAfter = nil, 0
+	opts.Checkpoint, opts.Backend = nil, nil
+	out := NewCollection(opts)
+
+	if err := out.copySchema(c); err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	c.lock.RLock()
+	for key, value := range c.props {
+		out.SetProperty(key, value)
+	}
+	c.lock.RUnlock()
+	return out, nil
+}
+
+// copyChunks copies the rows of the chunks and the values of the columns selected into
+// another collection. Each chunk is copied through a transaction, so the indexes are
+// computed as well. This must be called while all of the chunks are read-locked.
+func (c *Collection) copyChunks(out *Collection, chunks int, copied func(*column) bool) error {
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		c.lock.RLock()
+		fill := chunk.OfBitmap(c.fill)
+		commitID := c.commits[chunk]
+		c.lock.RUnlock()
+
+		if err := out.Query(func(txn *Txn) error {
+			txn.dirty.Set(uint32(chunk))
+			inserts := txn.bufferFor(rowColumn)
+			chunk.Range(fill, func(idx uint32) {
+				inserts.PutOperation(commit.Insert, idx)
+			})
+
+			c.cols.Range(func(v *column) {
+				if !copied(v) {
+					return
+				}
+
+				buffer := txn.owner.txns.acquirePage(v.name)
+				if v.Snapshot(chunk, buffer) {
+					txn.updates = append(txn.updates, buffer)
+				}
+			})
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		out.commits[chunk] = commitID
+	}
+	return nil
+}
+
+// copySchema creates all of the columns and indexes of the source collection which are
+// missing in this collection. The data itself is not copied.
+func (c *Collection) copySchema(src *Collection) error {
+	if err := src.cols.RangeUntil(func(v *column) error {
+		if _, exists := c.cols.Load(v.name); exists || v.IsIndex() {
+			return nil
+		}
+
+		empty, err := makeEmpty(v.Column)
+		if err != nil {
+			return err
+		}
+		return c.CreateColumn(v.name, empty)
+	}); err != nil {
+		return err
+	}
+
+	// Create the indexes once all of the columns they depend on exist
+	return src.cols.RangeUntil(func(v *column) error {
+		if expr, ok := v.Column.(*columnExpr); ok {
+			if _, exists := c.cols.Load(v.name); exists {
+				return nil
+			}
+
+			columns := make([]string, 0, len(expr.sources))
+			for _, source := range expr.sources {
+				columns = append(columns, source.name)
+			}
+			return c.CreateExpressionIndex(v.name, columns, expr.rule)
+		}
+
+		if lookup, ok := v.Column.(*columnLookup); ok {
+			if _, exists := c.cols.Load(v.name); exists {
+				return nil
+			}
+			return c.CreateLookup(v.name, lookup.name)
+		}
+
+		index, ok := v.Column.(*columnIndex)
+		switch _, exists := c.cols.Load(v.name); {
+		case exists || !ok:
+			return nil
+		case index.scope != nil:
+			return c.CreatePartialIndex(v.name, index.name, index.scope.name, index.rule)
+		default:
+			return c.CreateIndex(v.name, index.name, index.rule)
+		}
+	})
+}
+
 // --------------------------- Collection Encoding ---------------------------
 
 // writeState writes collection state into the specified writer.
 func (c *Collection) writeState(dst io.Writer) (int64, error) {
 	writer := iostream.NewWriter(dst)
-	buffer := c.txns.acquirePage(rowColumn)
-	defer c.txns.releasePage(buffer)
+
+	// In deterministic mode, exclude the transactions while the state is written so that
+	// there are no pending commits to be appended after it
+	if c.opts.Deterministic {
+		c.txlock.Lock()
+		defer c.txlock.Unlock()
+	} else {
+		c.txlock.RLock()
+		defer c.txlock.RUnlock()
+	}
 
 	// Write the schema version
-	if err := writer.WriteUvarint(0x1); err != nil {
+	if err := writer.WriteUvarint(0x3); err != nil {
 		return writer.Offset(), err
 	}
 
 	// Load the number of columns and the max index
 	chunks := c.chunks()
 	columns := uint64(c.cols.Count()) + 1 // extra 'insert' column
+	indexes := c.indexes()
+	ordered := c.snapshotColumns()
 
 	// Write the number of columns
 	if err := writer.WriteUvarint(columns); err != nil {
 		return writer.Offset(), err
 	}
 
-	// Write each chunk
-	if err := writer.WriteRange(chunks, func(i int, w *iostream.Writer) error {
-		return c.readChunk(commit.Chunk(i), func(lastCommit uint64, chunk commit.Chunk, fill bitmap.Bitmap) error {
+	// Write the definitions of the bitmap indexes, so they can be restored as they are
+	if err := writer.WriteRange(len(indexes), func(i int, w *iostream.Writer) error {
+		if err := w.WriteString(indexes[i].name); err != nil {
+			return err
+		}
+		return w.WriteString(indexes[i].Column.(*columnIndex).name)
+	}); err != nil {
+		return writer.Offset(), err
+	}
+
+	// Write the properties of the collection
+	if err := c.writeProperties(writer); err != nil {
+		return writer.Offset(), err
+	}
+
+	// Write each chunk, encoded in parallel if the snapshots have several workers
+	if err := c.writeChunks(writer, chunks, func(chunk commit.Chunk, writer *iostream.Writer, buffer *commit.Buffer) error {
+		return c.readChunk(chunk, func(lastCommit uint64, chunk commit.Chunk, fill bitmap.Bitmap) error {
 			offset := chunk.Min()
 
 			// Write the last written commit for this chunk
+			if c.opts.Deterministic {
+				lastCommit = 0 // Commit IDs depend on the history of the process
+			}
 			if err := writer.WriteUvarint(lastCommit); err != nil {
 				return err
 			}
 
-			// Write the inserts column
+			// Write the inserts column, unless the chunk no longer matches its checksums
+			if err := c.checksums.checkFill(chunk, fill); err != nil {
+				return err
+			}
 			buffer.Reset(rowColumn)
 			fill.Range(func(idx uint32) {
 				buffer.PutOperation(commit.Insert, offset+idx)
@@ -147,12 +577,27 @@ func (c *Collection) writeState(dst io.Writer) (int64, error) {
 			}
 
 			// Snapshot each column and write the buffer
-			return c.cols.RangeUntil(func(column *column) error {
+			for _, column := range ordered {
 				if !column.Snapshot(chunk, buffer) {
-					return nil // Skip indexes
+					continue // Skip indexes
 				}
-				return writer.WriteSelf(buffer)
-			})
+				if err := c.checksums.check(column.name, chunk, buffer); err != nil {
+					return err
+				}
+				if err := writer.WriteSelf(buffer); err != nil {
+					return err
+				}
+			}
+
+			// Write the bitmap of each index
+			for _, index := range indexes {
+				buffer.Reset(index.name)
+				index.Column.Snapshot(chunk, buffer)
+				if err := writer.WriteSelf(buffer); err != nil {
+					return err
+				}
+			}
+			return nil
 		})
 	}); err != nil {
 		return writer.Offset(), err
@@ -167,10 +612,13 @@ func (c *Collection) readState(src io.Reader) ([]uint64, error) {
 	r := iostream.NewReader(src)
 	commits := make([]uint64, 128)
 
-	// Read the version and make sure it matches
+	// Read the version of the schema and make sure it matches
 	version, err := r.ReadUvarint()
-	if err != nil || version != 0x1 {
-		return nil, fmt.Errorf("column: unable to restore (version %d) %v", version, err)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("column: unable to restore, %w", err)
+	case version < 0x1 || version > 0x3:
+		return nil, &VersionError{Version: version, Min: 0x1, Max: 0x3}
 	}
 
 	// Read the number of columns
@@ -179,42 +627,159 @@ func (c *Collection) readState(src io.Reader) ([]uint64, error) {
 		return nil, err
 	}
 
-	// Read each chunk
-	return commits, r.ReadRange(func(chunk int, r *iostream.Reader) error {
-		return c.Query(func(txn *Txn) error {
-			txn.dirty.Set(uint32(chunk))
+	// Read the definitions of the indexes, and only restore the bitmaps of the ones which
+	// are defined in the same way, the others are recomputed from the values.
+	var restored map[string]bool
+	if version >= 0x2 {
+		if restored, err = c.readIndexes(r); err != nil {
+			return nil, err
+		}
+		defer c.restoring(restored, false)
+	}
 
-			// Read the last written commit ID for the chunk
-			if commits[chunk], err = r.ReadUvarint(); err != nil {
+	// Read the properties of the collection
+	if version >= 0x3 {
+		if err := c.readProperties(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read each chunk, and commit them in parallel if the snapshots have several workers
+	loader := newChunkLoader(c)
+	err = r.ReadRange(func(chunk int, r *iostream.Reader) error {
+		for len(commits) <= chunk {
+			commits = append(commits, 0)
+		}
+
+		// Read the last written commit ID for the chunk
+		if commits[chunk], err = r.ReadUvarint(); err != nil {
+			return err
+		}
+
+		updates := make([]*commit.Buffer, 0, columns)
+		var bitmaps []*commit.Buffer
+		for i := uint64(0); i < columns+uint64(len(restored)); i++ {
+			buffer := c.txns.acquirePage("")
+			_, err := buffer.ReadFrom(r)
+			switch {
+			case err == io.EOF:
+				return errUnexpectedEOF
+			case err != nil:
 				return err
+			case i >= columns && restored[buffer.Column]:
+				bitmaps = append(bitmaps, buffer)
+			case i >= columns:
+				c.txns.releasePage(buffer)
+			default:
+				updates = append(updates, buffer)
 			}
+		}
 
-			for i := uint64(0); i < columns; i++ {
-				buffer := txn.owner.txns.acquirePage("")
-				_, err := buffer.ReadFrom(r)
-				switch {
-				case err == io.EOF && i < columns:
-					return errUnexpectedEOF
-				case err != nil:
-					return err
-				default:
-					txn.updates = append(txn.updates, buffer)
-				}
-			}
+		// Once the values are committed, the bitmaps of the indexes are restored
+		return loader.load(commit.Chunk(chunk), updates, bitmaps)
+	})
+	if loaded := loader.wait(); err == nil {
+		err = loaded
+	}
+	return commits, err
+}
 
-			return nil
-		})
+// loadIndexes loads the bitmaps of the indexes written in a snapshot for the chunk
+func (c *Collection) loadIndexes(chunk commit.Chunk, bitmaps []*commit.Buffer) {
+	if len(bitmaps) == 0 {
+		return
+	}
+
+	reader := commit.NewReader()
+	c.slock.Lock(uint(chunk))
+	defer c.slock.Unlock(uint(chunk))
+	for _, buffer := range bitmaps {
+		if v, ok := c.cols.Load(buffer.Column); ok {
+			reader.Range(buffer, chunk, func(r *commit.Reader) {
+				v.lock.RLock()
+				v.Column.(*columnIndex).load(r)
+				v.lock.RUnlock()
+			})
+		}
+		c.txns.releasePage(buffer)
+	}
+}
+
+// indexes returns the bitmap indexes of the collection, which are written in snapshots
+func (c *Collection) indexes() (out []*column) {
+	c.cols.Range(func(v *column) {
+		if _, ok := v.Column.(*columnIndex); ok {
+			out = append(out, v)
+		}
+	})
+
+	if c.opts.Deterministic {
+		sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	}
+	return
+}
+
+// snapshotColumns returns the columns in the order they are written in a snapshot, which
+// is the order of their creation or, in deterministic mode, the order of their names.
+func (c *Collection) snapshotColumns() (out []*column) {
+	c.cols.Range(func(v *column) {
+		out = append(out, v)
 	})
+
+	if c.opts.Deterministic {
+		sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	}
+	return
+}
+
+// readIndexes reads the definitions of the indexes written in a snapshot and returns, for
+// each of them, whether its bitmap can be restored as is. Since the predicates can not be
+// compared, an index is restored if it has the same name and target column. The indexes
+// which are restored are marked so that their predicates are not evaluated.
+func (c *Collection) readIndexes(r *iostream.Reader) (map[string]bool, error) {
+	out := make(map[string]bool, 4)
+	if err := r.ReadRange(func(i int, r *iostream.Reader) error {
+		name, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		target, err := r.ReadString()
+		if err != nil {
+			return err
+		}
+
+		out[name] = false
+		if v, ok := c.cols.Load(name); ok {
+			index, ok := v.Column.(*columnIndex)
+			out[name] = ok && index.name == target
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	c.restoring(out, true)
+	return out, nil
+}
+
+// restoring marks or unmarks the indexes whose bitmaps are restored from a snapshot
+func (c *Collection) restoring(indexes map[string]bool, enabled bool) {
+	for name, restored := range indexes {
+		if v, ok := c.cols.Load(name); ok && restored {
+			v.Column.(*columnIndex).restoring(enabled)
+		}
+	}
 }
 
 // chunks returns the number of chunks and columns
 func (c *Collection) chunks() int {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	if len(c.fill) == 0 {
+	max, ok := c.fill.Max()
+	if !ok {
 		return 0
 	}
 
-	max, _ := c.fill.Max()
 	return int(commit.ChunkAt(max) + 1)
 }
@@ -0,0 +1,156 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/kelindar/column/commit"
+	"github.com/kelindar/iostream"
+)
+
+// chunkEncoder encodes a chunk of the collection into a writer, using a scratch buffer
+type chunkEncoder = func(chunk commit.Chunk, w *iostream.Writer, buffer *commit.Buffer) error
+
+// writeChunks writes the chunks of the collection in order. If the snapshots have several
+// workers, the chunks are encoded in memory by that many workers ahead of the one being
+// written, so that the columns of a large collection are not encoded by a single core.
+func (c *Collection) writeChunks(dst *iostream.Writer, chunks int, encode chunkEncoder) error {
+	workers := c.opts.SnapshotWorkers
+	if workers <= 1 || chunks <= 1 {
+		buffer := c.txns.acquirePage(rowColumn)
+		defer c.txns.releasePage(buffer)
+		return dst.WriteRange(chunks, func(i int, _ *iostream.Writer) error {
+			if err := encode(commit.Chunk(i), dst, buffer); err != nil {
+				return err
+			}
+
+			c.logChunk(i, chunks, dst.Offset())
+			return nil
+		})
+	}
+
+	// Encode the chunks in the background, queued in the order they are written in
+	type encoded struct {
+		data []byte
+		err  error
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	pending := make(chan chan encoded, workers)
+	go func() {
+		defer close(pending)
+		for i := 0; i < chunks; i++ {
+			out := make(chan encoded, 1)
+			select {
+			case pending <- out:
+			case <-stop:
+				return
+			}
+
+			go func(chunk commit.Chunk) {
+				buffer := c.txns.acquirePage(rowColumn)
+				defer c.txns.releasePage(buffer)
+
+				var data bytes.Buffer
+				err := encode(chunk, iostream.NewWriter(&data), buffer)
+				out <- encoded{data: data.Bytes(), err: err}
+			}(commit.Chunk(i))
+		}
+	}()
+
+	return dst.WriteRange(chunks, func(i int, _ *iostream.Writer) error {
+		result := <-<-pending
+		if result.err != nil {
+			return result.err
+		}
+
+		if _, err := dst.Write(result.data); err != nil {
+			return err
+		}
+
+		c.logChunk(i, chunks, dst.Offset())
+		return nil
+	})
+}
+
+// logChunk reports the progress of a snapshot, once a chunk is written
+func (c *Collection) logChunk(chunk, chunks int, written int64) {
+	if c.logs() {
+		c.log(LevelDebug, EventSnapshot, fmt.Sprintf("snapshot wrote chunk %d of %d", chunk+1, chunks), nil,
+			FieldChunk, chunk,
+			FieldChunks, chunks,
+			FieldBytes, written,
+		)
+	}
+}
+
+// chunkLoader commits the chunks read from a snapshot, with up to a number of workers
+type chunkLoader struct {
+	owner *Collection    // The collection to load the chunks into
+	slots chan struct{}  // The slots of the workers, or nil to load the chunks in place
+	group sync.WaitGroup // The group of the chunks being loaded
+	lock  sync.Mutex     // The lock protecting the error
+	err   error          // The first error of a worker
+}
+
+// newChunkLoader creates a new loader of the chunks of a snapshot
+func newChunkLoader(owner *Collection) *chunkLoader {
+	loader := &chunkLoader{owner: owner}
+	if workers := owner.opts.SnapshotWorkers; workers > 1 {
+		loader.slots = make(chan struct{}, workers)
+	}
+	return loader
+}
+
+// load commits the values of a chunk and restores the bitmaps of its indexes, once one of
+// the workers is available. It returns the error of any chunk loaded so far, so that the
+// snapshot is no longer read after a failure.
+func (l *chunkLoader) load(chunk commit.Chunk, updates, bitmaps []*commit.Buffer) error {
+	if l.slots == nil {
+		return l.owner.loadChunk(chunk, updates, bitmaps)
+	}
+
+	l.slots <- struct{}{}
+	l.group.Add(1)
+	go func() {
+		defer l.group.Done()
+		if err := l.owner.loadChunk(chunk, updates, bitmaps); err != nil {
+			l.lock.Lock()
+			if l.err == nil {
+				l.err = err
+			}
+			l.lock.Unlock()
+		}
+		<-l.slots
+	}()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.err
+}
+
+// wait waits for all of the chunks to be loaded and returns the first error
+func (l *chunkLoader) wait() error {
+	l.group.Wait()
+	return l.err
+}
+
+// loadChunk commits the values of a chunk read from a snapshot, then restores the bitmaps
+// of its indexes
+func (c *Collection) loadChunk(chunk commit.Chunk, updates, bitmaps []*commit.Buffer) error {
+	if err := c.Query(func(txn *Txn) error {
+		txn.dirty.Set(uint32(chunk))
+		txn.updates = append(txn.updates, updates...)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.loadIndexes(chunk, bitmaps)
+	return nil
+}
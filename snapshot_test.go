@@ -6,6 +6,7 @@ package column
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/kelindar/async"
 	"github.com/kelindar/column/commit"
+	"github.com/klauspost/compress/s2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -198,6 +200,53 @@ func TestSnapshot(t *testing.T) {
 	assert.Equal(t, amount, output.Count())
 }
 
+func TestSnapshotDeterministic(t *testing.T) {
+	a := NewCollection(Options{Deterministic: true})
+	a.CreateColumn("name", ForString())
+	a.CreateColumn("class", ForEnum())
+	a.CreateIndex("rogue", "class", func(r Reader) bool { return r.String() == "rogue" })
+	a.CreateIndex("mage", "class", func(r Reader) bool { return r.String() == "mage" })
+	defer a.Close()
+
+	b := NewCollection(Options{Deterministic: true})
+	b.CreateColumn("class", ForEnum())
+	b.CreateColumn("name", ForString())
+	b.CreateIndex("mage", "class", func(r Reader) bool { return r.String() == "mage" })
+	b.CreateIndex("rogue", "class", func(r Reader) bool { return r.String() == "rogue" })
+	defer b.Close()
+
+	classes := []string{"rogue", "mage", "warrior"}
+	for i := 0; i < 70; i++ {
+		a.InsertObject(Object{"name": fmt.Sprintf("player %d", i), "class": classes[i%3]})
+	}
+
+	// Replace a row in the first collection, the first free index is reused
+	a.DeleteAt(5)
+	a.InsertObject(Object{"name": "player 5", "class": "mage"})
+	for i := 0; i < 70; i++ {
+		if i == 5 {
+			b.InsertObject(Object{"name": "player 5", "class": "mage"})
+			continue
+		}
+		b.InsertObject(Object{"name": fmt.Sprintf("player %d", i), "class": classes[i%3]})
+	}
+
+	// Both collections produce identical snapshots
+	snapshot := func(c *Collection) []byte {
+		buffer := bytes.NewBuffer(nil)
+		assert.NoError(t, c.Snapshot(buffer))
+		return buffer.Bytes()
+	}
+
+	assert.Equal(t, snapshot(a), snapshot(b))
+	assert.Equal(t, snapshot(a), snapshot(a))
+
+	dictA, _ := a.Dictionary("class")
+	dictB, _ := b.Dictionary("class")
+	assert.Equal(t, dictA, dictB)
+	assert.Equal(t, []string{"mage", "rogue", "warrior"}, dictA)
+}
+
 func TestSnapshotFailures(t *testing.T) {
 	input := NewCollection()
 	input.CreateColumn("name", ForString())
@@ -225,6 +274,86 @@ func TestRestoreIncomplete(t *testing.T) {
 	assert.Error(t, output.Restore(buffer))
 }
 
+func TestSnapshotVersion(t *testing.T) {
+	input := loadPlayers(500)
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer))
+	assert.Equal(t, []byte("COLS\x00\x03\x00"), buffer.Bytes()[:7])
+
+	// The snapshots written without a header can still be restored
+	legacy := bytes.NewBuffer(nil)
+	writer := s2.NewWriter(legacy)
+	_, err := input.writeState(writer)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	output := newEmpty(500)
+	assert.NoError(t, output.Restore(legacy))
+	assert.Equal(t, 500, output.Count())
+
+	// The snapshots written before the codec was recorded are compressed with s2
+	previous := append([]byte("COLS\x00\x02"), buffer.Bytes()[7:]...)
+	output = newEmpty(500)
+	assert.NoError(t, output.Restore(bytes.NewReader(previous)))
+	assert.Equal(t, 500, output.Count())
+
+	// The snapshots written by a newer version are rejected with a typed error
+	future := append([]byte("COLS\x00\x09"), buffer.Bytes()[6:]...)
+	err = newEmpty(500).Restore(bytes.NewReader(future))
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+
+	var version *VersionError
+	assert.True(t, errors.As(err, &version))
+	assert.Equal(t, uint64(9), version.Version)
+	assert.Equal(t, uint64(3), version.Max)
+}
+
+func TestSnapshotDictionary(t *testing.T) {
+	input := loadPlayers(500)
+	dictionary, err := input.TrainDictionary(16 << 10)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, dictionary)
+
+	// Snapshot a few rows, both with the default codec and with the dictionary
+	input.Query(func(txn *Txn) error {
+		return txn.WithValue("race", func(v interface{}) bool {
+			return v == "human"
+		}).Range(func(idx uint32) {
+			if idx >= 20 {
+				txn.DeleteAt(idx)
+			}
+		})
+	})
+
+	plain := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(plain))
+
+	input.opts.Dictionary = dictionary
+	compressed := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(compressed))
+	assert.Less(t, compressed.Len(), plain.Len())
+	assert.Equal(t, byte(codecZstd), compressed.Bytes()[6])
+
+	// The dictionary is required to restore the snapshot
+	err = newEmpty(500).Restore(bytes.NewReader(compressed.Bytes()))
+	assert.Error(t, err)
+
+	output := NewCollection(Options{Dictionary: dictionary})
+	output.CreateColumnsOf(map[string]interface{}{"name": "", "race": "", "age": float64(0)})
+	assert.NoError(t, output.Restore(bytes.NewReader(compressed.Bytes())))
+	assert.Equal(t, input.Count(), output.Count())
+
+	// The snapshots of the default codec can still be restored with a dictionary
+	output = NewCollection(Options{Dictionary: dictionary})
+	assert.NoError(t, output.Restore(bytes.NewReader(plain.Bytes())))
+	assert.Equal(t, input.Count(), output.Count())
+}
+
+func TestTrainDictionaryEmpty(t *testing.T) {
+	_, err := NewCollection().TrainDictionary(0)
+	assert.Error(t, err)
+}
+
 func TestSnapshotFailedAppendCommit(t *testing.T) {
 	input := NewCollection()
 	input.CreateColumn("name", ForString())
@@ -286,6 +415,69 @@ func TestCollectionCodec(t *testing.T) {
 	assert.Equal(t, input.Count(), output.Count())
 }
 
+func TestSnapshotIndexes(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("age", ForInt())
+	input.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 50
+	})
+
+	for i := 0; i < 100; i++ {
+		input.Insert(func(r Row) error {
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	_, err := input.writeState(buffer)
+	assert.NoError(t, err)
+
+	// Restore with the same definition, the predicate should not be evaluated
+	calls := 0
+	output := NewCollection()
+	output.CreateColumn("age", ForInt())
+	output.CreateColumn("other", ForInt())
+	output.CreateIndex("old", "age", func(r Reader) bool {
+		calls++
+		return r.Int() >= 50
+	})
+
+	_, err = output.readState(bytes.NewReader(buffer.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, calls)
+	output.Query(func(txn *Txn) error {
+		assert.Equal(t, 50, txn.With("old").Count())
+		return nil
+	})
+
+	// The predicate is evaluated again for the new values
+	output.Insert(func(r Row) error {
+		r.SetInt("age", 99)
+		return nil
+	})
+	assert.Equal(t, 1, calls)
+	output.Query(func(txn *Txn) error {
+		assert.Equal(t, 51, txn.With("old").Count())
+		return nil
+	})
+
+	// Restore with a different definition, the index is computed from the values
+	output = NewCollection()
+	output.CreateColumn("age", ForInt())
+	output.CreateColumn("other", ForInt())
+	output.CreateIndex("old", "other", func(r Reader) bool {
+		return true
+	})
+
+	_, err = output.readState(bytes.NewReader(buffer.Bytes()))
+	assert.NoError(t, err)
+	output.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("old").Count())
+		return nil
+	})
+}
+
 func TestWriteToSizeUncompresed(t *testing.T) {
 	input := loadPlayers(1e4) // 10K
 	output := bytes.NewBuffer(nil)
@@ -328,6 +520,65 @@ func TestWriteEmpty(t *testing.T) {
 	}
 }
 
+func TestSnapshotProperties(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("name", ForString())
+	input.SetProperty("version", "3")
+	input.SetProperty("owner", "roman")
+	input.SetProperty("owner", "")
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer))
+
+	output := NewCollection()
+	output.CreateColumn("name", ForString())
+	assert.NoError(t, output.Restore(buffer))
+
+	version, ok := output.Property("version")
+	assert.True(t, ok)
+	assert.Equal(t, "3", version)
+	_, ok = output.Property("owner")
+	assert.False(t, ok)
+}
+
+func TestSnapshotParallel(t *testing.T) {
+	amount := 50000
+	input := loadPlayers(amount)
+	defer input.Close()
+
+	// The chunks encoded in parallel are written in the same order
+	sequential := bytes.NewBuffer(nil)
+	_, err := input.writeState(sequential)
+	assert.NoError(t, err)
+
+	input.opts.SnapshotWorkers = 4
+	parallel := bytes.NewBuffer(nil)
+	_, err = input.writeState(parallel)
+	assert.NoError(t, err)
+	assert.Equal(t, sequential.Bytes(), parallel.Bytes())
+
+	// Restore the chunks in parallel, along with the bitmaps of the indexes
+	output := newEmpty(amount)
+	output.opts.SnapshotWorkers = 4
+	defer output.Close()
+	_, err = output.readState(parallel)
+	assert.NoError(t, err)
+	assert.Equal(t, amount, output.Count())
+	assert.Equal(t, countWhere(input, func(txn *Txn) *Txn { return txn.With("human") }),
+		countWhere(output, func(txn *Txn) *Txn { return txn.With("human") }))
+	assert.Equal(t, objectAt(input, 40000), objectAt(output, 40000))
+
+	// A failure to write or read any of the chunks is returned
+	_, err = input.writeState(&limitWriter{Limit: sequential.Len() / 2})
+	assert.Error(t, err)
+
+	truncated := newEmpty(amount)
+	truncated.opts.SnapshotWorkers = 4
+	defer truncated.Close()
+	_, err = truncated.readState(bytes.NewReader(sequential.Bytes()[:sequential.Len()/2]))
+	assert.Error(t, err)
+}
+
 func TestReadFromFailures(t *testing.T) {
 	input := NewCollection()
 	input.CreateColumn("name", ForString())
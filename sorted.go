@@ -0,0 +1,201 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// SortOptions represents the order in which MergeSorted() iterates over the rows of shards
+type SortOptions struct {
+	Column string         // The numeric or textual column to sort the rows by
+	Desc   bool           // Whether the rows are sorted in descending order
+	Limit  int            // The maximum number of rows, or all of them if zero
+	Filter func(txn *Txn) // The filter applied on the transaction of every shard (optional)
+}
+
+// MergeSorted iterates over the rows of several collections holding the shards of the same
+// data, in the order of a column, as ORDER BY with an optional LIMIT would. Every shard only
+// sorts the keys of its own rows, keeping no more than the limit of them, and the shards are
+// then merged as sorted streams, so that the rows are never materialized and only the first
+// ones are read. The shards are queried in a transaction each, held until the iteration is
+// complete, and the function receives the position of the shard along with the row, until it
+// returns false. The rows without a value in the column are skipped.
+func MergeSorted(shards []*Collection, opts SortOptions, fn func(shard int, r Row) bool) error {
+	for i := range shards {
+		for j := 0; j < i; j++ {
+			if shards[i] == shards[j] {
+				return fmt.Errorf("column: unable to sort, shard %d is the same collection as shard %d", i, j)
+			}
+		}
+	}
+
+	txns := make([]*Txn, len(shards))
+	cursors := make(sortMerge, 0, len(shards))
+
+	// Open the transactions of the shards one within the other, then merge them
+	var open func(i int) error
+	open = func(i int) error {
+		if i == len(shards) {
+			return cursors.each(txns, opts.Limit, fn)
+		}
+
+		return shards[i].Query(func(txn *Txn) error {
+			if opts.Filter != nil {
+				opts.Filter(txn)
+			}
+
+			keys, err := sortKeysOf(txn, opts)
+			if err != nil {
+				return err
+			}
+
+			txns[i] = txn
+			if len(keys) > 0 {
+				cursors = append(cursors, &sortCursor{shard: i, keys: keys, desc: opts.Desc})
+			}
+			return open(i + 1)
+		})
+	}
+	return open(0)
+}
+
+// sortKey represents the value of a row in the column the rows are sorted by
+type sortKey struct {
+	idx    uint32
+	number float64
+	text   string
+}
+
+// sortKeysOf returns the keys of the selected rows of a shard, in order. If there is a limit,
+// only the first keys are kept while the selection is scanned.
+func sortKeysOf(txn *Txn, opts SortOptions) ([]sortKey, error) {
+	column, ok := txn.columnAt(opts.Column)
+	switch {
+	case !ok:
+		return nil, errorOf(ErrColumnNotFound, "column: unable to sort, column '%s' does not exist", opts.Column)
+	case !column.IsNumeric() && !column.IsTextual():
+		return nil, errorOf(ErrTypeMismatch, "column: unable to sort, column '%s' is not numeric or textual", opts.Column)
+	}
+
+	// Keep the last of the first keys on top of the heap, so it is replaced by better ones
+	keys := &sortHeap{less: func(a, b sortKey) bool {
+		return sortLess(b, a, opts.Desc)
+	}}
+
+	numbers, _ := column.Column.(Numeric)
+	texts, _ := column.Column.(Textual)
+	if err := txn.Range(func(idx uint32) {
+		key := sortKey{idx: idx}
+		if numbers != nil {
+			if key.number, ok = numbers.LoadFloat64(idx); !ok {
+				return
+			}
+		} else if key.text, ok = texts.LoadString(idx); !ok {
+			return
+		}
+
+		switch {
+		case opts.Limit <= 0 || keys.Len() < opts.Limit:
+			heap.Push(keys, key)
+		case sortLess(key, keys.keys[0], opts.Desc):
+			keys.keys[0] = key
+			heap.Fix(keys, 0)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys.keys, func(i, j int) bool {
+		return sortLess(keys.keys[i], keys.keys[j], opts.Desc)
+	})
+	return keys.keys, nil
+}
+
+// sortLess returns whether a key is before another one, the rows being in the order of their
+// index for the same value
+func sortLess(a, b sortKey, desc bool) bool {
+	switch {
+	case a.number != b.number:
+		return (a.number < b.number) != desc
+	case a.text != b.text:
+		return (a.text < b.text) != desc
+	default:
+		return a.idx < b.idx
+	}
+}
+
+// sortHeap represents a heap of keys
+type sortHeap struct {
+	keys []sortKey
+	less func(a, b sortKey) bool
+}
+
+func (h *sortHeap) Len() int           { return len(h.keys) }
+func (h *sortHeap) Less(i, j int) bool { return h.less(h.keys[i], h.keys[j]) }
+func (h *sortHeap) Swap(i, j int)      { h.keys[i], h.keys[j] = h.keys[j], h.keys[i] }
+func (h *sortHeap) Push(x interface{}) { h.keys = append(h.keys, x.(sortKey)) }
+func (h *sortHeap) Pop() (x interface{}) {
+	x, h.keys = h.keys[len(h.keys)-1], h.keys[:len(h.keys)-1]
+	return
+}
+
+// --------------------------- Merge ----------------------------
+
+// sortCursor represents the position in the sorted keys of a shard
+type sortCursor struct {
+	shard int       // The position of the shard
+	keys  []sortKey // The remaining keys of the shard
+	desc  bool      // Whether the keys are in descending order
+}
+
+// sortMerge represents a heap of the cursors of the shards, by their next key
+type sortMerge []*sortCursor
+
+func (m sortMerge) Len() int            { return len(m) }
+func (m sortMerge) Swap(i, j int)       { m[i], m[j] = m[j], m[i] }
+func (m *sortMerge) Push(x interface{}) { *m = append(*m, x.(*sortCursor)) }
+func (m *sortMerge) Pop() (x interface{}) {
+	old := *m
+	x, *m = old[len(old)-1], old[:len(old)-1]
+	return
+}
+
+// Less returns whether the next key of a shard is before the one of another shard
+func (m sortMerge) Less(i, j int) bool {
+	a, b := m[i].keys[0], m[j].keys[0]
+	if a.number == b.number && a.text == b.text {
+		return m[i].shard < m[j].shard
+	}
+	return sortLess(a, b, m[i].desc)
+}
+
+// each iterates over the rows of the shards in order, until the limit is reached or the
+// function returns false
+func (m *sortMerge) each(txns []*Txn, limit int, fn func(shard int, r Row) bool) error {
+	heap.Init(m)
+	for count := 0; m.Len() > 0 && (limit <= 0 || count < limit); count++ {
+		cursor := (*m)[0]
+		next := true
+		if err := txns[cursor.shard].QueryAt(cursor.keys[0].idx, func(r Row) error {
+			next = fn(cursor.shard, r)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if !next {
+			return nil
+		}
+
+		if cursor.keys = cursor.keys[1:]; len(cursor.keys) > 0 {
+			heap.Fix(m, 0)
+		} else {
+			heap.Pop(m)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeSorted(t *testing.T) {
+	shards := []*Collection{NewCollection(), NewCollection(), NewCollection()}
+	for i, shard := range shards {
+		shard.CreateColumn("name", ForString())
+		shard.CreateColumn("score", ForFloat64())
+		for j := 0; j < 100; j++ {
+			shard.InsertObject(Object{
+				"name":  fmt.Sprintf("player-%d-%02d", i, j),
+				"score": float64((j*7+i*13)%100) + float64(i)/10,
+			})
+		}
+	}
+
+	// The first rows across all of the shards, in descending order
+	var scores []float64
+	assert.NoError(t, MergeSorted(shards, SortOptions{
+		Column: "score",
+		Desc:   true,
+		Limit:  5,
+	}, func(shard int, r Row) bool {
+		score, _ := r.Float64("score")
+		scores = append(scores, score)
+		return true
+	}))
+	assert.Equal(t, []float64{99.2, 99.1, 99, 98.2, 98.1}, scores)
+
+	// The rows of a filtered selection in ascending order, until the function stops
+	var names []string
+	assert.NoError(t, MergeSorted(shards, SortOptions{
+		Column: "name",
+		Filter: func(txn *Txn) {
+			txn.WithFloat("score", func(v float64) bool { return v < 10 })
+		},
+	}, func(shard int, r Row) bool {
+		name, _ := r.String("name")
+		names = append(names, name)
+		return len(names) < 4
+	}))
+	assert.Equal(t, []string{"player-0-00", "player-0-01", "player-0-15", "player-0-29"}, names)
+}
+
+func TestMergeSortedInvalid(t *testing.T) {
+	shard := NewCollection()
+	shard.CreateColumn("active", ForBool())
+	shard.InsertObject(Object{"active": true})
+
+	fn := func(int, Row) bool { return true }
+	assert.Error(t, MergeSorted([]*Collection{shard}, SortOptions{Column: "missing"}, fn))
+	assert.Error(t, MergeSorted([]*Collection{shard}, SortOptions{Column: "active"}, fn))
+	assert.Error(t, MergeSorted([]*Collection{shard, shard}, SortOptions{Column: "active"}, fn))
+}
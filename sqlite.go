@@ -0,0 +1,985 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// --------------------------- SQLite Export ---------------------------
+
+// ToSQLite writes the rows of the collection into a new SQLite database file, replacing the
+// file if it exists. The rows are written into a table with the specified name, with their
+// offset as the rowid, and the columns which are neither indexes nor internal columns, such
+// as the expiration of the rows, are written with the INTEGER, REAL, TEXT or BOOLEAN type. The missing values are written as NULL. The file is written
+// directly in the SQLite format, so that it can be opened by any SQLite client.
+func (c *Collection) ToSQLite(path, table string) error {
+	if table == "" {
+		return fmt.Errorf("column: unable to export, table name is empty")
+	}
+
+	var columns []*column
+	c.cols.Range(func(v *column) {
+		if !v.IsIndex() && !c.isInternal(v.name) {
+			columns = append(columns, v)
+		}
+	})
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	w := newSQLiteWriter(file)
+	values := make([]interface{}, len(columns))
+	if err := c.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			for i, column := range columns {
+				values[i] = sqliteValueOf(column, idx)
+			}
+			w.insert(int64(idx), values)
+		})
+	}); err != nil {
+		return err
+	}
+
+	if err := w.close(table, sqliteSchemaOf(table, columns)); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// FromSQLite loads the rows of a table of a SQLite database file into the collection and
+// returns the number of rows loaded. The columns of the table which do not exist in the
+// collection are created, as integer, float, string or bool columns depending on their
+// declared type. The rows are loaded in batches with BulkLoad(), so they are appended to
+// the collection rather than inserted at their rowid.
+func (c *Collection) FromSQLite(path, table string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+	r, err := newSQLiteReader(file)
+	if err != nil {
+		return 0, err
+	}
+
+	root, schema, err := r.find(table)
+	if err != nil {
+		return 0, err
+	}
+
+	columns, err := parseSQLiteSchema(schema)
+	if err != nil {
+		return 0, err
+	}
+
+	// Create the columns which are missing, depending on their declared type
+	for _, v := range columns {
+		if _, ok := c.cols.Load(v.name); !ok {
+			if err := c.CreateColumn(v.name, columnForType(v.decl)); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	batch := make(map[string][]interface{}, len(columns))
+	loaded, count := 0, 0
+	flush := func() error {
+		n, err := c.BulkLoad(batch)
+		loaded += n
+		for name := range batch {
+			batch[name] = batch[name][:0]
+		}
+		count = 0
+		return err
+	}
+
+	if err := r.scan(root, func(rowid int64, payload []byte) error {
+		record, err := decodeSQLiteRecord(payload)
+		if err != nil {
+			return err
+		}
+
+		for i, v := range columns {
+			var value interface{}
+			switch {
+			case v.rowid:
+				value = rowid
+			case i < len(record):
+				value = record[i]
+			}
+
+			column, _ := c.cols.Load(v.name)
+			if value, err = loadableOf(column, value); err != nil {
+				return err
+			}
+			batch[v.name] = append(batch[v.name], value)
+		}
+
+		if count++; count == chunkSize {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		return loaded, err
+	}
+
+	err = flush()
+	return loaded, err
+}
+
+// sqliteValueOf returns the value of a row, as a SQLite value
+func sqliteValueOf(column *column, idx uint32) interface{} {
+	value, ok := column.Value(idx)
+	if !ok {
+		if _, isBool := column.Column.(*columnBool); isBool {
+			return int64(0)
+		}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return int64(0)
+	case string:
+		return v
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case uint:
+		return sqliteUint(uint64(v))
+	case uint64:
+		return sqliteUint(v)
+	}
+
+	// The remaining integers fit into an int64
+	if n, ok := toInt64(value); ok {
+		return n
+	}
+	return nil
+}
+
+// sqliteUint returns an unsigned integer as a SQLite value, as a real if it overflows
+func sqliteUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+// toInt64 converts a signed or small unsigned integer to an int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// sqliteSchemaOf returns the statement creating the table for the columns
+func sqliteSchemaOf(table string, columns []*column) string {
+	var sql strings.Builder
+	sql.WriteString("CREATE TABLE ")
+	sql.WriteString(sqliteQuote(table))
+	sql.WriteString(" (")
+	for i, v := range columns {
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+
+		sql.WriteString(sqliteQuote(v.name))
+		_, isBool := v.Column.(*columnBool)
+		_, isFloat32 := v.Column.(*float32Column)
+		_, isFloat64 := v.Column.(*float64Column)
+		switch {
+		case isBool:
+			sql.WriteString(" BOOLEAN")
+		case isFloat32 || isFloat64:
+			sql.WriteString(" REAL")
+		case v.IsNumeric():
+			sql.WriteString(" INTEGER")
+		default:
+			sql.WriteString(" TEXT")
+		}
+	}
+	sql.WriteString(")")
+	return sql.String()
+}
+
+// sqliteQuote quotes an identifier
+func sqliteQuote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// --------------------------- SQLite Schema ---------------------------
+
+// sqliteColumn represents a column declared in a SQLite table
+type sqliteColumn struct {
+	name  string // The name of the column
+	decl  string // The declared type of the column
+	rowid bool   // Whether the column is an alias of the rowid
+}
+
+// parseSQLiteSchema parses the columns of a CREATE TABLE statement
+func parseSQLiteSchema(sql string) ([]sqliteColumn, error) {
+	if strings.Contains(strings.ToUpper(sql), "WITHOUT ROWID") {
+		return nil, fmt.Errorf("column: unable to import, tables without rowid are not supported")
+	}
+
+	defs := splitSQLiteDefinitions(sql)
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("column: unable to import, invalid table definition")
+	}
+
+	var columns []sqliteColumn
+	for _, def := range defs {
+		tokens := tokenizeSQLite(def)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(tokens[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue // Table constraint
+		}
+
+		column := sqliteColumn{name: unquoteSQLite(tokens[0])}
+		var decl []string
+		for i := 1; i < len(tokens); i++ {
+			switch strings.ToUpper(tokens[i]) {
+			case "CONSTRAINT", "PRIMARY", "NOT", "NULL", "UNIQUE", "CHECK", "DEFAULT",
+				"COLLATE", "REFERENCES", "GENERATED", "AS":
+				column.rowid = strings.EqualFold(strings.Join(decl, " "), "INTEGER") &&
+					strings.EqualFold(tokens[i], "PRIMARY")
+				i = len(tokens)
+			default:
+				decl = append(decl, tokens[i])
+			}
+		}
+
+		column.decl = strings.Join(decl, " ")
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// splitSQLiteDefinitions returns the definitions within the outer parentheses of a statement,
+// split by the commas which are neither nested nor quoted.
+func splitSQLiteDefinitions(sql string) (defs []string) {
+	depth, start := 0, 0
+	for i := 0; i < len(sql); i++ {
+		switch ch := sql[i]; ch {
+		case '"', '\'', '`', '[':
+			i = skipSQLiteQuoted(sql, i)
+		case '(':
+			if depth++; depth == 1 {
+				start = i + 1
+			}
+		case ')':
+			if depth--; depth == 0 {
+				return append(defs, strings.TrimSpace(sql[start:i]))
+			}
+		case ',':
+			if depth == 1 {
+				defs = append(defs, strings.TrimSpace(sql[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil
+}
+
+// tokenizeSQLite splits a column definition into its words, quoted identifiers and
+// parenthesized expressions
+func tokenizeSQLite(def string) (tokens []string) {
+	for i := 0; i < len(def); {
+		switch ch := def[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '"' || ch == '\'' || ch == '`' || ch == '[':
+			end := skipSQLiteQuoted(def, i)
+			tokens = append(tokens, def[i:end+1])
+			i = end + 1
+		case ch == '(':
+			depth, end := 0, i
+			for ; end < len(def); end++ {
+				if def[end] == '(' {
+					depth++
+				} else if def[end] == ')' {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			if n := len(tokens); n > 0 {
+				tokens[n-1] += def[i:minInt(end+1, len(def))]
+			}
+			i = end + 1
+		default:
+			end := i
+			for end < len(def) && !strings.ContainsRune(" \t\n\r(\"'`[", rune(def[end])) {
+				end++
+			}
+			tokens = append(tokens, def[i:end])
+			i = end
+		}
+	}
+	return
+}
+
+// skipSQLiteQuoted returns the position of the quote closing the one at the offset
+func skipSQLiteQuoted(s string, offset int) int {
+	closing := s[offset]
+	if closing == '[' {
+		closing = ']'
+	}
+
+	for i := offset + 1; i < len(s); i++ {
+		if s[i] == closing {
+			if closing != ']' && i+1 < len(s) && s[i+1] == closing {
+				i++ // Escaped quote
+				continue
+			}
+			return i
+		}
+	}
+	return len(s) - 1
+}
+
+// unquoteSQLite removes the quotes of an identifier
+func unquoteSQLite(name string) string {
+	if len(name) < 2 {
+		return name
+	}
+
+	switch first, last := name[0], name[len(name)-1]; {
+	case first == '[' && last == ']':
+		return name[1 : len(name)-1]
+	case (first == '"' || first == '\'' || first == '`') && last == first:
+		quote := string(first)
+		return strings.ReplaceAll(name[1:len(name)-1], quote+quote, quote)
+	default:
+		return name
+	}
+}
+
+// minInt returns the minimum of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// --------------------------- SQLite Format ---------------------------
+
+const (
+	sqlitePageSize      = 4096 // The size of the pages written
+	sqliteLeafTable     = 0x0d // The type of a leaf page of a table
+	sqliteInteriorTable = 0x05 // The type of an interior page of a table
+	sqliteMaxDepth      = 64   // The maximum depth of a tree, to detect corrupted files
+)
+
+// sqliteChild represents a page of a tree, along with the largest rowid it contains
+type sqliteChild struct {
+	page  uint32
+	rowid int64
+}
+
+// sqliteWriter writes a SQLite database with a single table. The rows are written into leaf
+// pages as they come, and the interior pages of the tree are written once all of the rows
+// are written, along with the schema on the first page.
+type sqliteWriter struct {
+	dst    io.WriterAt
+	err    error
+	pages  uint32        // The number of pages allocated
+	leaf   []byte        // The leaf page being filled
+	cells  []uint16      // The offsets of the cells of the leaf page
+	top    int           // The start of the content area of the leaf page
+	last   int64         // The last rowid of the leaf page
+	leaves []sqliteChild // The leaf pages written so far
+	record []byte        // The buffer for the records
+}
+
+// newSQLiteWriter creates a new writer, where the first page is reserved for the schema
+func newSQLiteWriter(dst io.WriterAt) *sqliteWriter {
+	w := &sqliteWriter{
+		dst:   dst,
+		pages: 1,
+		leaf:  make([]byte, sqlitePageSize),
+		top:   sqlitePageSize,
+	}
+	return w
+}
+
+// alloc allocates a new page
+func (w *sqliteWriter) alloc() uint32 {
+	w.pages++
+	return w.pages
+}
+
+// write writes a page at its position in the file
+func (w *sqliteWriter) write(page uint32, data []byte) {
+	if w.err == nil {
+		_, w.err = w.dst.WriteAt(data, int64(page-1)*sqlitePageSize)
+	}
+}
+
+// insert writes a row into the leaf page, or into a new one if it is full
+func (w *sqliteWriter) insert(rowid int64, values []interface{}) {
+	w.record = encodeSQLiteRecord(w.record[:0], values)
+	cell := w.cell(rowid, w.record)
+	if w.top-len(cell) < 8+2*(len(w.cells)+1) {
+		w.flush()
+	}
+
+	w.top -= len(cell)
+	copy(w.leaf[w.top:], cell)
+	w.cells = append(w.cells, uint16(w.top))
+	w.last = rowid
+}
+
+// cell encodes a cell of a leaf page and writes the overflow pages of its payload
+func (w *sqliteWriter) cell(rowid int64, payload []byte) []byte {
+	cell := appendSQLiteVarint(nil, uint64(len(payload)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	local := sqliteLocalSize(len(payload), sqlitePageSize)
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+
+	// Write the rest of the payload into a chain of overflow pages
+	page := w.alloc()
+	cell = append(cell, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(cell[len(cell)-4:], page)
+	for rest := payload[local:]; len(rest) > 0; {
+		data := make([]byte, sqlitePageSize)
+		n := copy(data[4:], rest)
+		if rest = rest[n:]; len(rest) > 0 {
+			next := w.alloc()
+			binary.BigEndian.PutUint32(data, next)
+			w.write(page, data)
+			page = next
+			continue
+		}
+		w.write(page, data)
+	}
+	return cell
+}
+
+// flush writes the leaf page and starts a new one
+func (w *sqliteWriter) flush() {
+	page := w.alloc()
+	writeSQLitePage(w.leaf, 0, sqliteLeafTable, w.cells, w.top, 0)
+	w.write(page, w.leaf)
+	w.leaves = append(w.leaves, sqliteChild{page: page, rowid: w.last})
+
+	w.leaf = make([]byte, sqlitePageSize)
+	w.cells = w.cells[:0]
+	w.top = sqlitePageSize
+}
+
+// close writes the last leaf page, the interior pages and the schema of the table
+func (w *sqliteWriter) close(table, schema string) error {
+	if len(w.cells) > 0 || len(w.leaves) == 0 {
+		w.flush()
+	}
+
+	// Build the interior pages, level by level, until there is a single root
+	level := w.leaves
+	for len(level) > 1 {
+		const capacity = (sqlitePageSize - 12) / (2 + 4 + 9)
+		var next []sqliteChild
+		for len(level) > 0 {
+			n := minInt(len(level), capacity+1)
+			if len(level)-n == 1 {
+				n-- // Leave at least two children for the last page
+			}
+
+			page, children := w.alloc(), level[:n]
+			data := make([]byte, sqlitePageSize)
+			cells, top := make([]uint16, 0, n-1), sqlitePageSize
+			for _, child := range children[:n-1] {
+				cell := make([]byte, 4, 13)
+				binary.BigEndian.PutUint32(cell, child.page)
+				cell = appendSQLiteVarint(cell, uint64(child.rowid))
+				top -= len(cell)
+				copy(data[top:], cell)
+				cells = append(cells, uint16(top))
+			}
+
+			right := children[n-1]
+			writeSQLitePage(data, 0, sqliteInteriorTable, cells, top, right.page)
+			w.write(page, data)
+			next = append(next, sqliteChild{page: page, rowid: right.rowid})
+			level = level[n:]
+		}
+		level = next
+	}
+
+	// Write the first page, with the header of the file and the schema
+	w.record = encodeSQLiteRecord(w.record[:0], []interface{}{
+		"table", table, table, int64(level[0].page), schema,
+	})
+
+	first := make([]byte, sqlitePageSize)
+	cell := w.cell(1, w.record)
+	top := sqlitePageSize - len(cell)
+	copy(first[top:], cell)
+	writeSQLitePage(first, 100, sqliteLeafTable, []uint16{uint16(top)}, top, 0)
+	writeSQLiteHeader(first, w.pages)
+	w.write(1, first)
+	return w.err
+}
+
+// writeSQLitePage writes the header of a b-tree page along with its cell pointers
+func writeSQLitePage(data []byte, offset int, kind byte, cells []uint16, top int, right uint32) {
+	header := data[offset:]
+	header[0] = kind
+	binary.BigEndian.PutUint16(header[1:], 0)
+	binary.BigEndian.PutUint16(header[3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(header[5:], uint16(top))
+	header[7] = 0
+
+	size := 8
+	if kind == sqliteInteriorTable {
+		binary.BigEndian.PutUint32(header[8:], right)
+		size = 12
+	}
+
+	for i, cell := range cells {
+		binary.BigEndian.PutUint16(header[size+2*i:], cell)
+	}
+}
+
+// writeSQLiteHeader writes the header of the database file
+func writeSQLiteHeader(data []byte, pages uint32) {
+	copy(data, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(data[16:], sqlitePageSize)
+	data[18], data[19] = 1, 1                 // Legacy journal mode
+	data[20] = 0                              // No reserved space
+	data[21], data[22], data[23] = 64, 32, 32 // Payload fractions
+	binary.BigEndian.PutUint32(data[24:], 1)  // File change counter
+	binary.BigEndian.PutUint32(data[28:], pages)
+	binary.BigEndian.PutUint32(data[40:], 1) // Schema cookie
+	binary.BigEndian.PutUint32(data[44:], 4) // Schema format
+	binary.BigEndian.PutUint32(data[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(data[92:], 1) // Version valid for
+	binary.BigEndian.PutUint32(data[96:], 3040001)
+}
+
+// sqliteLocalSize returns the number of bytes of a payload stored in a leaf page of a table,
+// the rest being stored in overflow pages.
+func sqliteLocalSize(payload, usable int) int {
+	maxLocal := usable - 35
+	if payload <= maxLocal {
+		return payload
+	}
+
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (payload-minLocal)%(usable-4)
+	if local > maxLocal {
+		return minLocal
+	}
+	return local
+}
+
+// encodeSQLiteRecord encodes the values in the record format
+func encodeSQLiteRecord(dst []byte, values []interface{}) []byte {
+	var types []byte
+	size := 0
+	for _, value := range values {
+		serial, n := sqliteSerialOf(value)
+		types = appendSQLiteVarint(types, serial)
+		size += n
+	}
+
+	// The size of the header includes its own varint
+	header := len(types) + 1
+	for len(appendSQLiteVarint(nil, uint64(header)))+len(types) != header {
+		header++
+	}
+
+	dst = appendSQLiteVarint(dst, uint64(header))
+	dst = append(dst, types...)
+	for _, value := range values {
+		switch v := value.(type) {
+		case int64:
+			serial, n := sqliteSerialOf(v)
+			if serial <= 6 {
+				var buf [8]byte
+				binary.BigEndian.PutUint64(buf[:], uint64(v))
+				dst = append(dst, buf[8-n:]...)
+			}
+		case float64:
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+			dst = append(dst, buf[:]...)
+		case string:
+			dst = append(dst, v...)
+		}
+	}
+	return dst
+}
+
+// sqliteSerialOf returns the serial type of a value and the size of its content
+func sqliteSerialOf(value interface{}) (uint64, int) {
+	switch v := value.(type) {
+	case int64:
+		switch {
+		case v == 0:
+			return 8, 0
+		case v == 1:
+			return 9, 0
+		case v >= math.MinInt8 && v <= math.MaxInt8:
+			return 1, 1
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			return 2, 2
+		case v >= -1<<23 && v < 1<<23:
+			return 3, 3
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			return 4, 4
+		case v >= -1<<47 && v < 1<<47:
+			return 5, 6
+		default:
+			return 6, 8
+		}
+	case float64:
+		return 7, 8
+	case string:
+		return uint64(13 + 2*len(v)), len(v)
+	default:
+		return 0, 0
+	}
+}
+
+// decodeSQLiteRecord decodes the values of a record
+func decodeSQLiteRecord(payload []byte) ([]interface{}, error) {
+	header, n := readSQLiteVarint(payload)
+	if n == 0 || header > uint64(len(payload)) || header < uint64(n) {
+		return nil, errCorruptSQLite
+	}
+
+	var values []interface{}
+	types, body := payload[n:header], payload[header:]
+	for len(types) > 0 {
+		serial, n := readSQLiteVarint(types)
+		if n == 0 {
+			return nil, errCorruptSQLite
+		}
+
+		types = types[n:]
+		size := sqliteSizeOf(serial)
+		if size > len(body) {
+			return nil, errCorruptSQLite
+		}
+
+		data := body[:size]
+		body = body[size:]
+		switch {
+		case serial == 0:
+			values = append(values, nil)
+		case serial <= 6:
+			v := int64(0)
+			for _, b := range data {
+				v = v<<8 | int64(b)
+			}
+			shift := 64 - 8*uint(size)
+			values = append(values, v<<shift>>shift)
+		case serial == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(data)))
+		case serial == 8:
+			values = append(values, int64(0))
+		case serial == 9:
+			values = append(values, int64(1))
+		case serial >= 12 && serial%2 == 0:
+			values = append(values, append([]byte(nil), data...))
+		case serial >= 13:
+			values = append(values, string(data))
+		default:
+			return nil, errCorruptSQLite
+		}
+	}
+	return values, nil
+}
+
+// sqliteSizeOf returns the size of the content of a serial type
+func sqliteSizeOf(serial uint64) int {
+	switch {
+	case serial <= 4:
+		return int(serial)
+	case serial == 5:
+		return 6
+	case serial == 6 || serial == 7:
+		return 8
+	case serial < 12:
+		return 0
+	default:
+		return int((serial - 12) / 2)
+	}
+}
+
+// appendSQLiteVarint appends a variable-length integer, in big-endian order
+func appendSQLiteVarint(dst []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var buf [9]byte
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(dst, buf[:]...)
+	}
+
+	var buf [8]byte
+	i := len(buf) - 1
+	for buf[i] = byte(v & 0x7f); v >= 0x80; buf[i] |= 0x80 {
+		v >>= 7
+		i--
+		buf[i] = byte(v & 0x7f)
+	}
+	for j := i; j < len(buf)-1; j++ {
+		buf[j] |= 0x80
+	}
+	return append(dst, buf[i:]...)
+}
+
+// readSQLiteVarint reads a variable-length integer and returns the number of bytes read,
+// or zero if the buffer is too short
+func readSQLiteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+
+	if len(b) < 9 {
+		return 0, 0
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// --------------------------- SQLite Reader ---------------------------
+
+// errCorruptSQLite is returned when a SQLite file can not be read
+var errCorruptSQLite = fmt.Errorf("column: unable to import, the database file is corrupted")
+
+// sqliteReader reads the tables of a SQLite database
+type sqliteReader struct {
+	src    io.ReaderAt
+	size   int // The size of the pages
+	usable int // The usable size of the pages, without the reserved space
+}
+
+// newSQLiteReader reads the header of a database
+func newSQLiteReader(src io.ReaderAt) (*sqliteReader, error) {
+	header := make([]byte, 100)
+	if _, err := src.ReadAt(header, 0); err != nil || string(header[:16]) != "SQLite format 3\x00" {
+		return nil, fmt.Errorf("column: unable to import, not a SQLite database")
+	}
+
+	if binary.BigEndian.Uint32(header[56:]) > 1 {
+		return nil, fmt.Errorf("column: unable to import, only UTF-8 databases are supported")
+	}
+
+	size := int(binary.BigEndian.Uint16(header[16:]))
+	if size == 1 {
+		size = 65536
+	}
+
+	if size < 512 || size&(size-1) != 0 || int(header[20]) >= size-480 {
+		return nil, errCorruptSQLite
+	}
+
+	return &sqliteReader{
+		src:    src,
+		size:   size,
+		usable: size - int(header[20]),
+	}, nil
+}
+
+// find finds the root page and the definition of a table
+func (r *sqliteReader) find(table string) (root uint32, schema string, err error) {
+	err = r.scan(1, func(_ int64, payload []byte) error {
+		record, err := decodeSQLiteRecord(payload)
+		if err != nil || len(record) < 5 {
+			return errCorruptSQLite
+		}
+
+		kind, _ := record[0].(string)
+		name, _ := record[1].(string)
+		page, _ := record[3].(int64)
+		if kind == "table" && strings.EqualFold(name, table) && root == 0 {
+			root = uint32(page)
+			schema, _ = record[4].(string)
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil:
+		return 0, "", err
+	case root == 0:
+		return 0, "", fmt.Errorf("column: unable to import, table '%s' does not exist", table)
+	default:
+		return root, schema, nil
+	}
+}
+
+// page reads a page of the database
+func (r *sqliteReader) page(n uint32) ([]byte, error) {
+	if n == 0 {
+		return nil, errCorruptSQLite
+	}
+
+	data := make([]byte, r.size)
+	if _, err := r.src.ReadAt(data, int64(n-1)*int64(r.size)); err != nil {
+		return nil, errCorruptSQLite
+	}
+	return data[:r.usable], nil
+}
+
+// scan calls the function for every row of the table whose tree starts at the root page,
+// in the order of their rowid.
+func (r *sqliteReader) scan(root uint32, fn func(rowid int64, payload []byte) error) error {
+	return r.scanPage(root, 0, fn)
+}
+
+// scanPage scans the rows of a page of a table, and of its children
+func (r *sqliteReader) scanPage(n uint32, depth int, fn func(rowid int64, payload []byte) error) error {
+	if depth > sqliteMaxDepth {
+		return errCorruptSQLite
+	}
+
+	data, err := r.page(n)
+	if err != nil {
+		return err
+	}
+
+	header := data
+	if n == 1 {
+		header = data[100:]
+	}
+
+	kind := header[0]
+	cells := int(binary.BigEndian.Uint16(header[3:]))
+	size := 8
+	if kind == sqliteInteriorTable {
+		size = 12
+	}
+
+	if size+2*cells > len(header) {
+		return errCorruptSQLite
+	}
+
+	for i := 0; i < cells; i++ {
+		offset := int(binary.BigEndian.Uint16(header[size+2*i:]))
+		if offset >= len(data) {
+			return errCorruptSQLite
+		}
+
+		cell := data[offset:]
+		switch kind {
+		case sqliteInteriorTable:
+			if len(cell) < 4 {
+				return errCorruptSQLite
+			}
+			if err := r.scanPage(binary.BigEndian.Uint32(cell), depth+1, fn); err != nil {
+				return err
+			}
+
+		case sqliteLeafTable:
+			rowid, payload, err := r.readCell(cell)
+			if err != nil {
+				return err
+			}
+			if err := fn(rowid, payload); err != nil {
+				return err
+			}
+
+		default:
+			return errCorruptSQLite
+		}
+	}
+
+	if kind == sqliteInteriorTable {
+		return r.scanPage(binary.BigEndian.Uint32(header[8:]), depth+1, fn)
+	}
+	return nil
+}
+
+// readCell reads the rowid and the payload of a cell of a leaf page, along with the part
+// of the payload stored in overflow pages.
+func (r *sqliteReader) readCell(cell []byte) (int64, []byte, error) {
+	size, n1 := readSQLiteVarint(cell)
+	rowid, n2 := readSQLiteVarint(cell[minInt(n1, len(cell)):])
+	if n1 == 0 || n2 == 0 || size > math.MaxInt32 {
+		return 0, nil, errCorruptSQLite
+	}
+
+	cell = cell[n1+n2:]
+	local := sqliteLocalSize(int(size), r.usable)
+	if local > len(cell) {
+		return 0, nil, errCorruptSQLite
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	if local == int(size) {
+		return int64(rowid), payload, nil
+	}
+
+	// Follow the chain of overflow pages
+	if len(cell) < local+4 {
+		return 0, nil, errCorruptSQLite
+	}
+
+	for next := binary.BigEndian.Uint32(cell[local:]); len(payload) < int(size); {
+		data, err := r.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		n := minInt(int(size)-len(payload), len(data)-4)
+		payload = append(payload, data[4:4+n]...)
+		next = binary.BigEndian.Uint32(data)
+	}
+	return int64(rowid), payload, nil
+}
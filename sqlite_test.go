@@ -0,0 +1,250 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("serial", ForKey())
+	input.CreateColumn("name", ForString())
+	input.CreateColumn("class", ForEnum())
+	input.CreateColumn("age", ForInt16())
+	input.CreateColumn("score", ForInt64())
+	input.CreateColumn("huge", ForUint64())
+	input.CreateColumn("balance", ForFloat32())
+	input.CreateColumn("active", ForBool())
+	input.CreateIndex("adult", "age", func(r Reader) bool {
+		return r.Int() >= 18
+	})
+	defer input.Close()
+
+	const count = 20000
+	for i := 0; i < count; i++ {
+		obj := Object{
+			"serial":  "player-" + string(rune('a'+i%26)) + strings.Repeat("x", i%7) + strconv.Itoa(i),
+			"class":   []string{"mage", "rogue", "fighter"}[i%3],
+			"age":     int16(i % 100),
+			"score":   int64(i) * int64(math.MaxInt32) * int64(1-2*(i%2)),
+			"huge":    uint64(i) * 1e12,
+			"balance": float32(i) / 4,
+			"active":  i%2 == 0,
+		}
+		switch {
+		case i%1000 == 0:
+			obj["name"] = strings.Repeat("long name ", 1000+i/100)
+		case i%10 != 0:
+			obj["name"] = "player " + strconv.Itoa(i)
+		}
+		input.InsertObject(obj)
+	}
+	input.Query(func(txn *Txn) error {
+		txn.WithValue("age", func(v interface{}) bool {
+			return v.(int16) == 50
+		}).DeleteAll()
+		return nil
+	})
+
+	path := filepath.Join(t.TempDir(), "players.db")
+	assert.NoError(t, input.ToSQLite(path, `my "players"`))
+
+	// Load into an empty collection, where the columns are created from the schema
+	output := NewCollection()
+	defer output.Close()
+	n, err := output.FromSQLite(path, `MY "PLAYERS"`)
+	assert.NoError(t, err)
+	assert.Equal(t, input.Count(), n)
+	assert.Equal(t, input.Count(), output.Count())
+
+	// Rows are loaded in the order of their rowid, so compare them in order
+	var offsets []uint32
+	input.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			offsets = append(offsets, idx)
+		})
+	})
+
+	for i, idx := range offsets {
+		assert.NoError(t, input.QueryAt(idx, func(r1 Row) error {
+			return output.QueryAt(uint32(i), func(r2 Row) error {
+				key1, _ := r1.Key()
+				key2, _ := r2.String("serial")
+				class1, _ := r1.Enum("class")
+				class2, _ := r2.String("class")
+				name1, ok1 := r1.String("name")
+				name2, ok2 := r2.String("name")
+				assert.Equal(t, key1, key2)
+				assert.Equal(t, class1, class2)
+				assert.Equal(t, ok1, ok2)
+				assert.Equal(t, name1, name2)
+
+				age, _ := r1.Int16("age")
+				score, _ := r1.Int64("score")
+				huge, _ := r1.Uint64("huge")
+				balance, _ := r1.Float32("balance")
+				age2, _ := r2.Int64("age")
+				score2, _ := r2.Int64("score")
+				huge2, _ := r2.Int64("huge")
+				balance2, _ := r2.Float64("balance")
+				assert.Equal(t, int64(age), age2)
+				assert.Equal(t, score, score2)
+				assert.Equal(t, float64(balance), balance2)
+				assert.Equal(t, int64(huge), huge2)
+				assert.Equal(t, r1.Bool("active"), r2.Bool("active"))
+				return nil
+			})
+		}))
+
+		if t.Failed() {
+			return
+		}
+	}
+
+	// Load again into a collection with the same schema
+	again := NewCollection()
+	again.CreateColumn("age", ForInt16())
+	again.CreateColumn("huge", ForUint64())
+	again.CreateColumn("active", ForBool())
+	again.CreateIndex("adult", "age", func(r Reader) bool {
+		return r.Int() >= 18
+	})
+	defer again.Close()
+
+	n, err = again.FromSQLite(path, `my "players"`)
+	assert.NoError(t, err)
+	assert.Equal(t, input.Count(), n)
+	assert.Equal(t, countWhere(input, func(txn *Txn) *Txn {
+		return txn.With("adult", "active")
+	}), countWhere(again, func(txn *Txn) *Txn {
+		return txn.With("adult", "active")
+	}))
+}
+
+func TestToSQLiteInternal(t *testing.T) {
+	input := NewCollection(Options{SoftDelete: true, Versioned: true})
+	input.CreateColumn("name", ForString())
+	defer input.Close()
+
+	input.InsertObjectWithTTL(Object{"name": "Roman"}, time.Hour)
+	input.InsertObject(Object{"name": "Alice"})
+
+	path := filepath.Join(t.TempDir(), "players.db")
+	assert.NoError(t, input.ToSQLite(path, "players"))
+
+	// Only the columns of the user are exported, without the expiration or the versions
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	r, err := newSQLiteReader(file)
+	assert.NoError(t, err)
+	_, schema, err := r.find("players")
+	assert.NoError(t, err)
+	columns, err := parseSQLiteSchema(schema)
+	assert.NoError(t, err)
+	assert.Len(t, columns, 1)
+	assert.Equal(t, "name", columns[0].name)
+
+	output := NewCollection()
+	defer output.Close()
+	n, err := output.FromSQLite(path, "players")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"name"}, output.Columns())
+}
+
+func TestFromSQLite(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("class", ForEnum())
+	defer col.Close()
+
+	// The fixture was written by SQLite, with an integer primary key, a column added with
+	// ALTER TABLE, a value spilling into overflow pages and an interior page
+	n, err := col.FromSQLite("fixtures/players.db", "players")
+	assert.NoError(t, err)
+	assert.Equal(t, 2000, n)
+	assert.Equal(t, 2000, col.Count())
+	assert.Equal(t, 667, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithValue("class", func(v interface{}) bool { return v == "rogue" })
+	}))
+	assert.Equal(t, 1000, countWhere(col, func(txn *Txn) *Txn {
+		return txn.With("active")
+	}))
+
+	assert.NoError(t, col.QueryAt(4, func(r Row) error {
+		id, _ := r.Int64("id")
+		name, _ := r.String("name")
+		balance, _ := r.Float64("balance")
+		bio, _ := r.String("bio")
+		assert.Equal(t, int64(10), id)
+		assert.Equal(t, "player5", name)
+		assert.Equal(t, 7.5, balance)
+		assert.Equal(t, strings.Repeat("x", 10000), bio)
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(9, func(r Row) error {
+		_, hasBalance := r.Float64("balance")
+		_, hasBio := r.String("bio")
+		assert.False(t, hasBalance)
+		assert.False(t, hasBio)
+		return nil
+	}))
+}
+
+func TestSQLiteErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("class", ForInt64())
+	defer col.Close()
+
+	dir := t.TempDir()
+	assert.Error(t, col.ToSQLite(filepath.Join(dir, "players.db"), ""))
+	assert.Error(t, col.ToSQLite(filepath.Join(dir, "missing", "players.db"), "players"))
+
+	_, err := col.FromSQLite(filepath.Join(dir, "missing.db"), "players")
+	assert.Error(t, err)
+	_, err = col.FromSQLite("fixtures/players.json", "players")
+	assert.Error(t, err)
+	_, err = col.FromSQLite("fixtures/players.db", "missing")
+	assert.Error(t, err)
+	_, err = col.FromSQLite("fixtures/players.db", "ordered")
+	assert.Error(t, err)
+
+	// The text of the class can not be loaded into the numeric column
+	_, err = col.FromSQLite("fixtures/players.db", "players")
+	assert.Error(t, err)
+
+	// A truncated file is reported as corrupted
+	b, err := os.ReadFile("fixtures/players.db")
+	assert.NoError(t, err)
+	truncated := filepath.Join(dir, "truncated.db")
+	assert.NoError(t, os.WriteFile(truncated, b[:8192], 0644))
+	_, err = NewCollection().FromSQLite(truncated, "players")
+	assert.Error(t, err)
+}
+
+func TestSQLiteVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 240, 2287, 16383, 16384, 1<<56 - 1, 1 << 56, math.MaxUint64} {
+		b := appendSQLiteVarint(nil, v)
+		out, n := readSQLiteVarint(b)
+		assert.Equal(t, v, out)
+		assert.Equal(t, len(b), n)
+	}
+
+	for _, v := range []interface{}{nil, int64(0), int64(1), int64(-1), int64(1 << 20), int64(-1 << 40), int64(math.MinInt64), 1.5, "", "hello"} {
+		record, err := decodeSQLiteRecord(encodeSQLiteRecord(nil, []interface{}{v, "x"}))
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{v, "x"}, record)
+	}
+}
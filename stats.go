@@ -0,0 +1,141 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// columnStats keeps the aggregates of every chunk of the numeric columns whose statistics
+// were requested. Every commit marks the chunks it changed as stale for the columns it
+// updated, and only these chunks are aggregated again when the statistics are read.
+type columnStats struct {
+	lock      sync.Mutex             // The lock protecting the columns and their stale chunks
+	count     int32                  // The number of columns tracked (atomic)
+	columns   map[string]*statsEntry // The aggregates of the columns, by name
+	observers *rowObservers          // The observers of the rows of the collection
+}
+
+// statsEntry represents the aggregates of a column
+type statsEntry struct {
+	lock sync.Mutex // The lock held while the stale chunks are aggregated
+	cacheAggregate
+}
+
+// newColumnStats creates a new set of column statistics
+func newColumnStats(observers *rowObservers) *columnStats {
+	return &columnStats{
+		columns:   make(map[string]*statsEntry, 4),
+		observers: observers,
+	}
+}
+
+// ColumnStats returns the count, sum, minimum and maximum of a numeric column over all of
+// the rows of the collection. Once requested for a column, the statistics are maintained
+// for every chunk of rows: the commits only mark the chunks they changed as stale, and only
+// these are aggregated again on the next call. This makes the aggregates of a collection
+// which changes little almost free, compared to a transaction scanning all of the rows.
+func (c *Collection) ColumnStats(columnName string) (out Aggregate, err error) {
+	column, ok := c.cols.Load(columnName)
+	if !ok || !column.IsNumeric() {
+		return Aggregate{}, errorOf(ErrTypeMismatch, "column: unable to compute statistics of '%s', column is not numeric", columnName)
+	}
+
+	err = c.Query(func(txn *Txn) (err error) {
+		out, err = c.colstats.aggregate(txn, columnName, column.Column.(Numeric))
+		return
+	})
+	return
+}
+
+// tracked checks whether the statistics of any column are maintained
+func (s *columnStats) tracked() bool {
+	return atomic.LoadInt32(&s.count) > 0
+}
+
+// aggregate aggregates the stale chunks of a column and merges the aggregates of all of
+// its chunks.
+func (s *columnStats) aggregate(txn *Txn, columnName string, column Numeric) (Aggregate, error) {
+	s.lock.Lock()
+	entry, ok := s.columns[columnName]
+	if !ok {
+		entry = new(statsEntry)
+		s.columns[columnName] = entry
+		s.observers.track(&s.count, len(s.columns))
+		for i := 0; i < txn.owner.chunks(); i++ {
+			entry.stale.Set(uint32(i))
+		}
+	}
+	s.lock.Unlock()
+
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+
+	s.lock.Lock()
+	stale := append(bitmap.Bitmap(nil), entry.stale...)
+	entry.stale.Clear()
+	s.lock.Unlock()
+
+	// Aggregate the stale chunks, reading the values of the column which are present
+	var failed error
+	fill := column.Index()
+	stale.Range(func(x uint32) {
+		chunk := commit.Chunk(x)
+		if failed != nil || !txn.rlock(chunk) {
+			failed = txn.failed()
+			return
+		}
+
+		for len(entry.chunks) <= int(chunk) {
+			entry.chunks = append(entry.chunks, Aggregate{})
+		}
+
+		var out Aggregate
+		chunk.Range(*fill, func(idx uint32) {
+			if v, ok := column.LoadFloat64(idx); ok {
+				out.merge(Aggregate{Count: 1, Sum: v, Min: v, Max: v})
+			}
+		})
+
+		entry.chunks[chunk] = out
+		txn.runlock(chunk)
+	})
+
+	if failed != nil {
+		s.lock.Lock()
+		entry.stale.Or(stale)
+		s.lock.Unlock()
+		return Aggregate{}, failed
+	}
+
+	var out Aggregate
+	for _, chunk := range entry.chunks {
+		out.merge(chunk)
+	}
+	return out, nil
+}
+
+// invalidate marks a chunk as stale for the columns which were changed, or for all of the
+// columns if rows were inserted or deleted.
+func (s *columnStats) invalidate(chunk commit.Chunk, changed map[string]bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name, entry := range s.columns {
+		if changed[name] || changed[rowColumn] {
+			entry.stale.Set(uint32(chunk))
+		}
+	}
+}
+
+// remove stops maintaining the statistics of a column, once it is dropped or replaced
+func (s *columnStats) remove(columnName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.columns, columnName)
+	s.observers.track(&s.count, len(s.columns))
+}
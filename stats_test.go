@@ -0,0 +1,108 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnStats(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("balance", ForInt64())
+	defer col.Close()
+
+	for i := 0; i < 50000; i++ {
+		col.InsertObject(Object{"balance": int64(i % 1000)})
+	}
+
+	// The statistics are computed from all of the chunks the first time
+	stats, err := col.ColumnStats("balance")
+	assert.NoError(t, err)
+	assertStats(t, col, "balance", stats)
+	assert.Equal(t, 50000, stats.Count)
+	assert.Equal(t, 0.0, stats.Min)
+	assert.Equal(t, 999.0, stats.Max)
+
+	// Only the chunk changed is stale, and the largest value can be removed
+	assert.NoError(t, col.QueryAt(20000, func(r Row) error {
+		r.SetInt64("balance", 5000)
+		return nil
+	}))
+	assert.Equal(t, 1, col.colstats.columns["balance"].stale.Count())
+	stats, err = col.ColumnStats("balance")
+	assert.NoError(t, err)
+	assertStats(t, col, "balance", stats)
+	assert.Equal(t, 5000.0, stats.Max)
+
+	assert.True(t, col.DeleteAt(20000))
+	stats, err = col.ColumnStats("balance")
+	assert.NoError(t, err)
+	assertStats(t, col, "balance", stats)
+	assert.Equal(t, 999.0, stats.Max)
+
+	// Changes of other columns leave the statistics as they are
+	assert.NoError(t, col.QueryAt(100, func(r Row) error {
+		r.SetString("name", "roman")
+		return nil
+	}))
+	assert.Equal(t, 0, col.colstats.columns["balance"].stale.Count())
+
+	// Additions, deletions and rows in new chunks are accounted for
+	col.Query(func(txn *Txn) error {
+		balance := txn.Int64("balance")
+		return txn.WithInt("balance", func(v int64) bool { return v < 10 }).Range(func(idx uint32) {
+			balance.Add(-100)
+		})
+	})
+	col.Query(func(txn *Txn) error {
+		txn.WithInt("balance", func(v int64) bool { return v > 990 }).DeleteAll()
+		return nil
+	})
+	col.Insert(func(r Row) error {
+		r.SetInt64("balance", 1e6)
+		return nil
+	})
+
+	stats, err = col.ColumnStats("balance")
+	assert.NoError(t, err)
+	assertStats(t, col, "balance", stats)
+	assert.Equal(t, -100.0, stats.Min)
+	assert.Equal(t, 1e6, stats.Max)
+}
+
+func TestColumnStatsErrors(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForInt())
+	defer col.Close()
+
+	_, err := col.ColumnStats("name")
+	assert.Error(t, err)
+	_, err = col.ColumnStats("missing")
+	assert.Error(t, err)
+
+	// The statistics of an empty column are empty
+	stats, err := col.ColumnStats("age")
+	assert.NoError(t, err)
+	assert.Equal(t, Aggregate{}, stats)
+	assert.True(t, col.colstats.tracked())
+
+	// Once dropped, the statistics are no longer maintained
+	col.DropColumn("age")
+	assert.False(t, col.colstats.tracked())
+	_, err = col.ColumnStats("age")
+	assert.Error(t, err)
+}
+
+// assertStats compares the statistics with the aggregate computed by a full scan
+func assertStats(t *testing.T, col *Collection, columnName string, stats Aggregate) {
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		expect, err := txn.Aggregate(columnName, 2)
+		assert.Equal(t, expect, stats)
+		return err
+	}))
+}
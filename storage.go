@@ -0,0 +1,269 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Storage represents the backing storage of the column buffers. Each buffer is addressed
+// by its name and its contents are preserved across resizes, so that a collection using
+// a persistent storage can be reopened with its rows without restoring a snapshot.
+type Storage interface {
+	Open(name string) ([]byte, error)             // Opens an existing buffer, or returns nil if there is none
+	Resize(name string, size int) ([]byte, error) // Grows the buffer to at least the specified size
+	Close() error                                 // Releases all of the buffers
+}
+
+// mountable represents a column whose buffers can be backed by a storage.
+type mountable interface {
+	mount(m *mapped) error
+	reserve(idx uint32) error
+}
+
+// spillable represents a mounted column which is able to release the memory of a chunk.
+type spillable interface {
+	release(chunk commit.Chunk)
+}
+
+// releaser represents a storage which is able to release the memory of a range of a buffer,
+// while keeping its contents.
+type releaser interface {
+	Release(name string, offset, size int) error
+}
+
+// mapped represents the buffers of a column which are backed by a storage
+type mapped struct {
+	storage Storage // The storage of the buffers
+	name    string  // The name of the column
+}
+
+// open maps the slice pointed to onto the buffer of the storage. If the buffer was already
+// persisted, the slice is restored from it, otherwise its current contents are copied into
+// a new buffer. The length of the slice is set to its capacity.
+func (m *mapped) open(suffix string, slice unsafe.Pointer, elem uintptr) error {
+	buffer, err := m.storage.Open(m.name + suffix)
+	if err != nil {
+		return fmt.Errorf("column: unable to open '%s', %w", m.name, err)
+	}
+
+	if buffer == nil {
+		header := (*reflect.SliceHeader)(slice)
+		if header.Cap == 0 {
+			return nil
+		}
+
+		current := sliceOf(header.Data, header.Len*int(elem))
+		if buffer, err = m.storage.Resize(m.name+suffix, header.Cap*int(elem)); err != nil {
+			return fmt.Errorf("column: unable to open '%s', %w", m.name, err)
+		}
+
+		copy(buffer, current)
+	}
+
+	viewOf(slice, buffer, elem)
+	return nil
+}
+
+// grow grows the mapped slice pointed to until it has the specified length, resizing the
+// buffer of the storage if the capacity of the slice is not sufficient.
+func (m *mapped) grow(suffix string, slice unsafe.Pointer, elem uintptr, length int) error {
+	header := (*reflect.SliceHeader)(slice)
+	switch {
+	case length <= header.Len:
+		return nil
+	case length <= header.Cap:
+		header.Len = length
+		return nil
+	}
+
+	buffer, err := m.storage.Resize(m.name+suffix, resize(header.Cap, uint32(length))*int(elem))
+	if err != nil {
+		return fmt.Errorf("column: unable to grow '%s', %w", m.name, err)
+	}
+
+	viewOf(slice, buffer, elem)
+	header.Len = length
+	return nil
+}
+
+// release releases the memory of a range of the buffer, if the storage supports it
+func (m *mapped) release(suffix string, offset, size int) {
+	if storage, ok := m.storage.(releaser); ok {
+		storage.Release(m.name+suffix, offset, size)
+	}
+}
+
+// viewOf points the slice pointed to at the buffer, as elements of the specified size
+func viewOf(slice unsafe.Pointer, buffer []byte, elem uintptr) {
+	header := (*reflect.SliceHeader)(slice)
+	if len(buffer) < int(elem) {
+		header.Data, header.Len, header.Cap = 0, 0, 0
+		return
+	}
+
+	header.Data = uintptr(unsafe.Pointer(&buffer[0]))
+	header.Len = len(buffer) / int(elem)
+	header.Cap = header.Len
+}
+
+// sliceOf returns a byte slice over the memory at the address, without copying it
+func sliceOf(data uintptr, size int) (out []byte) {
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&out))
+	header.Data = data
+	header.Len = size
+	header.Cap = size
+	return
+}
+
+// --------------------------- Collection ----------------------------
+
+const rowsBuffer = "rows"
+
+// mountRows restores the fill-list of the collection from the storage, along with the
+// number of rows and chunks. The columns restore their own values once created.
+func (c *Collection) mountRows() error {
+	c.rows = &mapped{storage: c.opts.Storage, name: rowsBuffer}
+	if err := c.rows.open("", unsafe.Pointer(&c.stored), 8); err != nil {
+		return err
+	}
+
+	c.fill = append(c.fill[:0], c.stored...)
+	c.count = uint64(c.fill.Count())
+	if max, ok := c.fill.Max(); ok {
+		c.commits = make([]uint64, commit.ChunkAt(max)+1)
+		c.touched = make([]int64, len(c.commits))
+	}
+	return nil
+}
+
+// reserveStorage grows the buffers of the storage until they are able to store the rows of
+// the chunk, before a transaction is committed, so that the commit itself never fails to grow
+// them. This returns the error of the storage, if a buffer can not be grown.
+func (c *Collection) reserveStorage(last commit.Chunk) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.rows.grow("", unsafe.Pointer(&c.stored), 8, int(last+1)<<bitmapShift); err != nil {
+		return err
+	}
+
+	if c.shared != nil {
+		if err := c.growShared(last); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	c.cols.Range(func(v *column) {
+		if column, ok := v.Column.(mountable); ok && err == nil {
+			err = column.reserve(last.Max())
+		}
+	})
+	return err
+}
+
+// reserve grows the buffers of the storage for the chunks written by the transaction, if
+// the collection is backed by a storage.
+func (txn *Txn) reserve() error {
+	if txn.owner.rows == nil {
+		return nil
+	}
+
+	last, ok := commit.Chunk(0), false
+	for _, u := range txn.updates {
+		u.RangeChunks(func(chunk commit.Chunk) {
+			if !ok || chunk > last {
+				last, ok = chunk, true
+			}
+		})
+	}
+
+	if !ok {
+		return nil
+	}
+	return txn.owner.reserveStorage(last)
+}
+
+// persistRows copies the fill-list of the chunk into the storage. This must be called
+// while the collection lock is held, once the buffer is reserved.
+func (c *Collection) persistRows(chunk commit.Chunk) {
+	if c.rows == nil {
+		return
+	}
+
+	fill := chunk.OfBitmap(c.fill)
+	offset := int(chunk) << bitmapShift
+	if c.rows.grow("", unsafe.Pointer(&c.stored), 8, offset+len(fill)) == nil {
+		copy(c.stored[offset:], fill)
+	}
+}
+
+// capacity returns the index up to which the columns need to be grown
+func (c *Collection) capacity() uint32 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if size := len(c.commits) << chunkShift; size > c.opts.Capacity {
+		return uint32(size - 1)
+	}
+	return uint32(c.opts.Capacity)
+}
+
+// --------------------------- Spilling ----------------------------
+
+const spilled = -1 // The access time of a chunk whose memory was released
+
+// touch marks the chunk as recently accessed, if the cold chunks are spilled
+func (c *Collection) touch(chunk commit.Chunk) {
+	if c.opts.SpillAfter <= 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	c.lock.RLock()
+	if int(chunk) < len(c.touched) {
+		atomic.StoreInt64(&c.touched[chunk], now)
+	}
+	c.lock.RUnlock()
+}
+
+// spill releases the memory of the mounted columns for the chunks which were not accessed
+// since the configured duration. The chunks are paged in again by the storage once they
+// are accessed, and returns the number of chunks which were released.
+func (c *Collection) spill(now time.Time) (count int) {
+	cutoff := now.Add(-c.opts.SpillAfter).UnixNano()
+	for chunk := commit.Chunk(0); ; chunk++ {
+		c.lock.RLock()
+		if int(chunk) >= len(c.touched) {
+			c.lock.RUnlock()
+			return
+		}
+
+		at := atomic.LoadInt64(&c.touched[chunk])
+		c.lock.RUnlock()
+		if at == spilled || at > cutoff {
+			continue
+		}
+
+		c.slock.Lock(uint(chunk))
+		c.cols.Range(func(v *column) {
+			if column, ok := v.Column.(spillable); ok {
+				v.lock.RLock()
+				column.release(chunk)
+				v.lock.RUnlock()
+			}
+		})
+
+		c.lock.RLock()
+		atomic.CompareAndSwapInt64(&c.touched[chunk], at, spilled)
+		c.lock.RUnlock()
+		c.slock.Unlock(uint(chunk))
+		count++
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"os"
+	"syscall"
+)
+
+// Release releases the memory of a range of the buffer. The modified pages are written
+// back to the file by the operating system, and paged in again once they are accessed.
+func (s *mmapStorage) Release(name string, offset, size int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil
+	}
+
+	// Only the pages which are entirely within the range can be released
+	page := os.Getpagesize()
+	lo := (offset + page - 1) / page * page
+	hi := offset + size
+	if hi > len(f.data) {
+		hi = len(f.data)
+	}
+
+	if hi = hi / page * page; lo >= hi {
+		return nil
+	}
+
+	return syscall.Madvise(f.data[lo:hi], syscall.MADV_DONTNEED)
+}
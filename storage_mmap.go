@@ -0,0 +1,217 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package column
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// mmapStorage represents a storage which maps the buffers onto files of a directory
+type mmapStorage struct {
+	lock     sync.Mutex
+	dir      string
+	files    map[string]*mmapFile
+	readOnly bool // Whether the files are written by another process
+}
+
+// mmapFile represents a file mapped in memory. The previous mappings are kept until the
+// storage is closed, since readers may still be using them while the file is resized.
+type mmapFile struct {
+	file *os.File
+	data []byte
+	old  [][]byte
+}
+
+// MMap creates a storage which backs the column buffers with memory-mapped files in the
+// specified directory. This allows collections larger than the memory to be paged by the
+// operating system, and a collection to be reopened without restoring a snapshot.
+func MMap(dir string) Storage {
+	return &mmapStorage{
+		dir:   dir,
+		files: make(map[string]*mmapFile, 8),
+	}
+}
+
+// Open maps an existing buffer, or returns nil if there is none
+func (s *mmapStorage) Open(name string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if f, ok := s.files[name]; ok {
+		return f.data, nil
+	}
+
+	flag, prot := os.O_RDWR, syscall.PROT_READ|syscall.PROT_WRITE
+	if s.readOnly {
+		flag, prot = os.O_RDONLY, syscall.PROT_READ
+	}
+
+	file, err := os.OpenFile(s.pathOf(name), flag, 0)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		file.Close()
+		return nil, err
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), prot, syscall.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	s.files[name] = &mmapFile{file: file, data: data}
+	return data, nil
+}
+
+// Resize grows the buffer to at least the specified size, creating it if necessary
+func (s *mmapStorage) Resize(name string, size int) ([]byte, error) {
+	if s.readOnly {
+		return make([]byte, size), nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		if err := os.MkdirAll(s.dir, 0755); err != nil {
+			return nil, err
+		}
+
+		file, err := os.OpenFile(s.pathOf(name), os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		f = &mmapFile{file: file}
+		s.files[name] = f
+	}
+
+	if size <= len(f.data) {
+		return f.data, nil
+	}
+
+	if err := f.file.Truncate(int64(size)); err != nil {
+		return nil, err
+	}
+
+	data, err := syscall.Mmap(int(f.file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.data != nil {
+		f.old = append(f.old, f.data)
+	}
+
+	f.data = data
+	return data, nil
+}
+
+// Close unmaps all of the buffers and closes their files
+func (s *mmapStorage) Close() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name, f := range s.files {
+		for _, data := range append(f.old, f.data) {
+			if data == nil {
+				continue
+			}
+			if e := syscall.Munmap(data); e != nil && err == nil {
+				err = e
+			}
+		}
+
+		if e := f.file.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(s.files, name)
+	}
+	return
+}
+
+// mmapReader represents a storage which maps the files written by another process read-only.
+// The buffers which are missing are kept in memory, empty, until they are opened again.
+type mmapReader struct {
+	*mmapStorage
+}
+
+// mmapShared creates a storage which maps the files of the directory read-only
+func mmapShared(dir string) (remapper, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	return mmapReader{&mmapStorage{
+		dir:      dir,
+		files:    make(map[string]*mmapFile, 8),
+		readOnly: true,
+	}}, nil
+}
+
+// remap maps the files again which were grown by the writer since they were mapped. The
+// fill-list of the rows is mapped last, so that it never covers rows beyond the values.
+func (s mmapReader) remap() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[j] == rowsBuffer || names[i] != rowsBuffer && names[i] < names[j]
+	})
+
+	for _, name := range names {
+		f := s.files[name]
+		info, err := f.file.Stat()
+		if err != nil {
+			return err
+		}
+
+		if int(info.Size()) <= len(f.data) {
+			continue
+		}
+
+		data, err := syscall.Mmap(int(f.file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+		if err != nil {
+			return err
+		}
+
+		f.old = append(f.old, f.data)
+		f.data = data
+	}
+	return nil
+}
+
+// syncDir syncs a directory, so that the files renamed into it are durable
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	return f.Sync()
+}
+
+// pathOf returns the path of the file for a buffer
+func (s *mmapStorage) pathOf(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name))
+}
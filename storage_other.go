@@ -0,0 +1,46 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package column
+
+import (
+	"fmt"
+)
+
+// unsupportedStorage represents a storage on a platform without memory-mapped files
+type unsupportedStorage struct{}
+
+// MMap creates a storage which backs the column buffers with memory-mapped files in the
+// specified directory. Memory-mapped files are not supported on this platform, so any
+// attempt to use the storage returns an error.
+func MMap(dir string) Storage {
+	return unsupportedStorage{}
+}
+
+// Open returns an error, as the storage is not supported
+func (unsupportedStorage) Open(name string) ([]byte, error) {
+	return nil, fmt.Errorf("column: memory-mapped storage is not supported on this platform")
+}
+
+// Resize returns an error, as the storage is not supported
+func (unsupportedStorage) Resize(name string, size int) ([]byte, error) {
+	return nil, fmt.Errorf("column: memory-mapped storage is not supported on this platform")
+}
+
+// Close does nothing, as the storage is not supported
+func (unsupportedStorage) Close() error {
+	return nil
+}
+
+// mmapShared returns an error, as memory-mapped files are not supported on this platform
+func mmapShared(dir string) (remapper, error) {
+	return nil, fmt.Errorf("column: memory-mapped storage is not supported on this platform")
+}
+
+// syncDir does nothing, as the directories can not be synced on this platform
+func syncDir(dir string) error {
+	return nil
+}
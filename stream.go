@@ -0,0 +1,93 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/column/commit"
+)
+
+// streamBatch is the number of rows pushed to a row writer while their chunk is read-locked
+const streamBatch = 1024
+
+// RowWriter represents a consumer of the rows streamed by a transaction, such as an encoder
+// of the response of an HTTP or gRPC handler.
+type RowWriter interface {
+	WriteRow(idx uint32, r Row) error
+}
+
+// RowFlusher is implemented by the row writers which buffer the rows. Since it is called
+// between the batches of rows while no lock is held, Flush() can block until the consumer
+// has caught up, which pauses the stream without delaying the concurrent writers.
+type RowFlusher interface {
+	Flush() error
+}
+
+// Stream pushes the selected rows to a writer in batches of up to 1024 rows, during which
+// their chunk is read-locked as with Range(). Between two batches, the lock is released and
+// the writer is flushed if it implements RowFlusher, so that a slow consumer applies back
+// pressure on the stream and millions of rows can be streamed without being buffered. The
+// rows deleted by a concurrent commit before their batch is pushed are skipped. The stream
+// stops at the first error of the writer, or once the context of the transaction is done.
+func (txn *Txn) Stream(w RowWriter) error {
+	txn.initialize()
+	flusher, _ := w.(RowFlusher)
+	row := Row{txn}
+
+	idxs := make([]uint32, 0, 64)
+	limit := commit.Chunk(len(txn.index) >> bitmapShift)
+	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
+		idxs = idxs[:0]
+		offset := chunk.Min()
+		chunk.OfBitmap(txn.index).Range(func(x uint32) {
+			idxs = append(idxs, offset+x)
+		})
+
+		for len(idxs) > 0 {
+			batch := idxs
+			if len(batch) > streamBatch {
+				batch = batch[:streamBatch]
+			}
+
+			if txn.cancelled() || !txn.rlock(chunk) {
+				return txn.failed()
+			}
+
+			if txn.owner.measured() {
+				txn.scanned += len(batch)
+			}
+
+			err := txn.pushRows(w, row, batch)
+			txn.runlock(chunk)
+			if err == nil && flusher != nil {
+				err = flusher.Flush()
+			}
+			if err != nil {
+				return err
+			}
+
+			idxs = idxs[len(batch):]
+		}
+	}
+	return txn.failed()
+}
+
+// pushRows pushes a batch of rows to the writer, skipping the rows which were deleted
+func (txn *Txn) pushRows(w RowWriter, row Row, idxs []uint32) error {
+	txn.owner.lock.RLock()
+	present := idxs[:0]
+	for _, idx := range idxs {
+		if txn.owner.fill.Contains(idx) {
+			present = append(present, idx)
+		}
+	}
+	txn.owner.lock.RUnlock()
+
+	for _, idx := range present {
+		txn.cursor = idx
+		if err := w.WriteRow(idx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
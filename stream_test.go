@@ -0,0 +1,113 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	// Every flush inserts a row concurrently, which would block if the chunk was locked
+	w := &testRowWriter{onFlush: func() {
+		done := make(chan struct{})
+		go func() {
+			players.InsertObject(Object{"name": "Merlin"})
+			close(done)
+		}()
+		<-done
+	}}
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.With("human").Stream(w)
+	}))
+
+	var expect []uint32
+	players.Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {
+			if idx < 500 {
+				expect = append(expect, idx)
+			}
+		})
+	})
+	assert.Equal(t, expect, w.rows)
+	assert.Equal(t, 1, w.flushes)
+}
+
+func TestStreamBatches(t *testing.T) {
+	players := newEmpty(3000)
+	defer players.Close()
+	players.CreateColumn("name", ForString())
+	for i := 0; i < 3000; i++ {
+		players.InsertObject(Object{"name": "Roman"})
+	}
+
+	// The rows deleted while the stream is paused are skipped
+	w := &testRowWriter{}
+	w.onFlush = func() {
+		if w.flushes == 1 {
+			players.DeleteAt(2000)
+		}
+	}
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.Stream(w)
+	}))
+	assert.Len(t, w.rows, 2999)
+	assert.NotContains(t, w.rows, uint32(2000))
+	assert.Equal(t, 3, w.flushes)
+}
+
+func TestStreamFailed(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	errStop := errors.New("stop")
+	w := &testRowWriter{limit: 10, err: errStop}
+	assert.Equal(t, errStop, players.Query(func(txn *Txn) error {
+		return txn.Stream(w)
+	}))
+	assert.Len(t, w.rows, 10)
+
+	// A cancelled context stops the stream
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, players.QueryContext(ctx, func(txn *Txn) error {
+		return txn.Stream(&testRowWriter{})
+	}))
+}
+
+// testRowWriter represents a writer of the streamed rows
+type testRowWriter struct {
+	rows    []uint32
+	flushes int
+	limit   int
+	err     error
+	onFlush func()
+}
+
+func (w *testRowWriter) WriteRow(idx uint32, r Row) error {
+	if w.limit > 0 && len(w.rows) == w.limit {
+		return w.err
+	}
+
+	if _, ok := r.Any("name"); ok {
+		w.rows = append(w.rows, idx)
+	}
+	return nil
+}
+
+func (w *testRowWriter) Flush() error {
+	w.flushes++
+	if w.onFlush != nil {
+		w.onFlush()
+	}
+	return nil
+}
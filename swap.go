@@ -0,0 +1,163 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+
+	"github.com/kelindar/column/commit"
+)
+
+// swap represents a conditional write, which is only applied if the value of the row is
+// still the expected one once the transaction is committed.
+type swap struct {
+	column   string      // The name of the target column
+	index    uint32      // The index of the target row
+	expected interface{} // The expected value, converted to the column type
+	row      bool        // Whether all of the writes of the row are discarded on conflict
+}
+
+// SetIf sets the value at the current transaction cursor only if the current value is the
+// expected one, and returns whether it is. A nil value is expected if the row should have
+// no value yet. The comparison is repeated once the transaction is committed and, if a
+// concurrent transaction has changed the value in the meantime, the write is discarded and
+// the transaction returns ErrConflict, so that a value is never overwritten unless it is
+// the expected one. The values are compared with the committed ones, without the pending
+// changes of the transaction itself.
+func (s anyWriter) SetIf(expected, value interface{}) bool {
+	swapped, err := s.txn.setIf(s.name, *s.cursor, expected, value)
+	if err != nil {
+		s.txn.abort(err)
+	}
+	return swapped
+}
+
+// SetIf sets the value at a particular column only if the current value is the expected
+// one, and returns whether it is. If the column does not exist, the transaction is aborted.
+func (r Row) SetIf(columnName string, expected, value interface{}) bool {
+	return r.txn.Any(columnName).SetIf(expected, value)
+}
+
+// UpdateAtIf sets the value of a column of the row at the specified index, only if the
+// current value is the expected one, and returns whether it is. Similarly to SetIf(), the
+// transaction returns ErrConflict if the value was changed by a concurrent transaction
+// before this one is committed.
+func (txn *Txn) UpdateAtIf(index uint32, columnName string, expected, value interface{}) (bool, error) {
+	chunk := commit.ChunkAt(index)
+	if !txn.rlock(chunk) {
+		return false, txn.err
+	}
+
+	defer txn.runlock(chunk)
+	if !txn.allowed(index) {
+		return false, ErrForbidden
+	}
+
+	return txn.setIf(columnName, index, expected, value)
+}
+
+// setIf compares the value of a row with the expected one and, if it matches, queues the
+// write along with the condition to check again at commit time.
+func (txn *Txn) setIf(columnName string, index uint32, expected, value interface{}) (bool, error) {
+	column, ok := txn.columnAt(columnName)
+	if !ok {
+		return false, errorOf(ErrColumnNotFound, "column: column '%s' does not exist", columnName)
+	}
+
+	expected, err := expectedOf(column, expected)
+	if err != nil {
+		return false, err
+	}
+
+	if currentOf(column, index) != expected {
+		return false, nil
+	}
+
+	txn.swaps = append(txn.swaps, swap{
+		column:   columnName,
+		index:    index,
+		expected: expected,
+	})
+	txn.bufferFor(columnName).PutAny(commit.Put, index, value)
+	return true, nil
+}
+
+// checkSwaps checks whether the values of all of the conditional writes of the transaction
+// are still the expected ones. This must be called while their chunks are locked.
+func (txn *Txn) checkSwaps() bool {
+	for _, s := range txn.swaps {
+		column, ok := txn.owner.cols.Load(s.column)
+		if ok && currentOf(column, s.index) != s.expected {
+			return false
+		}
+	}
+	return true
+}
+
+// discardWrites copies the operations of a chunk, except the ones of the specified rows
+func (txn *Txn) discardWrites(column *column, u *commit.Buffer, chunk commit.Chunk, rows map[uint32]bool) *commit.Buffer {
+	_, isBool := column.Column.(*columnBool)
+	kept := txn.owner.txns.acquirePage(u.Column)
+	txn.reader.Range(u, chunk, func(r *commit.Reader) {
+		for r.Next() {
+			idx := r.Index()
+			switch {
+			case rows[idx]:
+				continue
+			case isBool:
+				kept.PutBool(idx, r.Bool())
+			case r.Type == commit.Delete:
+				kept.PutOperation(commit.Delete, idx)
+			case r.Type == commit.Add:
+				kept.AddAny(idx, valueOf(column.Column, r))
+			case r.Type == commit.Merge:
+				kept.MergeAny(idx, valueOf(column.Column, r))
+			default:
+				kept.PutAny(r.Type, idx, valueOf(column.Column, r))
+			}
+		}
+	})
+	return kept
+}
+
+// expectedOf converts an expected value to the type of the column
+func expectedOf(column *column, expected interface{}) (interface{}, error) {
+	_, isBool := column.Column.(*columnBool)
+	_, isNumber := numericTypeOf(column.Column)
+	switch v := expected.(type) {
+	case nil:
+		if isBool {
+			return false, nil
+		}
+		return nil, nil
+	case bool:
+		if isBool {
+			return v, nil
+		}
+	case string:
+		if !isBool && !isNumber {
+			return v, nil
+		}
+	default:
+		if isNumber {
+			mutation := Set(column.name, expected)
+			return mutation.valueOf(column.Column)
+		}
+	}
+
+	return nil, fmt.Errorf("column: unable to compare '%s' with %T", column.name, expected)
+}
+
+// currentOf returns the committed value of a row, or nil if it has no value
+func currentOf(column *column, index uint32) interface{} {
+	value, ok := column.Value(index)
+	if _, isBool := column.Column.(*columnBool); isBool {
+		return value
+	}
+
+	if !ok {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetIf(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("status", ForString())
+	defer col.Close()
+	idx := col.InsertObject(Object{"status": "pending"})
+
+	// Only one of the concurrent transactions wins the row
+	var wg sync.WaitGroup
+	var winners int32
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var swapped bool
+			err := col.QueryAt(idx, func(r Row) error {
+				swapped = r.SetIf("status", "pending", fmt.Sprintf("taken:%d", i))
+				return nil
+			})
+
+			assert.True(t, err == nil || err == ErrConflict)
+			if swapped && err == nil {
+				atomic.AddInt32(&winners, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), winners)
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		status, _ := r.String("status")
+		assert.Contains(t, status, "taken:")
+		assert.False(t, r.SetIf("status", "pending", "again"))
+		return nil
+	}))
+}
+
+func TestSetIfConflict(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("status", ForString())
+	col.CreateColumn("owner", ForString())
+	defer col.Close()
+	col.InsertObject(Object{"status": "pending"})
+
+	// A concurrent transaction commits before this one, so the whole transaction is rolled
+	// back, including its other writes and inserts
+	assert.Equal(t, ErrConflict, col.Query(func(txn *Txn) error {
+		swapped, err := txn.UpdateAtIf(0, "status", "pending", "a")
+		assert.True(t, swapped)
+		assert.NoError(t, err)
+
+		assert.NoError(t, col.QueryAt(0, func(r Row) error {
+			r.SetString("status", "b")
+			return nil
+		}))
+
+		txn.InsertObject(Object{"status": "new"})
+		return txn.QueryAt(0, func(r Row) error {
+			r.SetString("owner", "roman")
+			return nil
+		})
+	}))
+
+	assert.Equal(t, 1, col.Count())
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		status, _ := r.String("status")
+		_, hasOwner := r.String("owner")
+		assert.Equal(t, "b", status)
+		assert.False(t, hasOwner)
+		return nil
+	}))
+
+	// Without a conflict, all of the writes are committed
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		swapped, err := txn.UpdateAtIf(0, "status", "b", "c")
+		assert.True(t, swapped)
+		assert.NoError(t, err)
+		return txn.QueryAt(0, func(r Row) error {
+			r.SetString("owner", "roman")
+			return nil
+		})
+	}))
+
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		status, _ := r.String("status")
+		owner, _ := r.String("owner")
+		assert.Equal(t, "c", status)
+		assert.Equal(t, "roman", owner)
+		return nil
+	}))
+}
+
+func TestUpdateAtIf(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("balance", ForFloat64())
+	col.CreateColumn("active", ForBool())
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+	col.InsertObject(Object{"balance": 10.0})
+
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		for _, tc := range []struct {
+			column   string
+			expected interface{}
+			value    interface{}
+			swapped  bool
+		}{
+			{"balance", 10, 20.0, true},
+			{"balance", 20, 30.0, false},
+			{"active", false, true, true},
+			{"active", nil, true, true},
+			{"name", nil, "roman", true},
+			{"name", "roman", "other", false},
+		} {
+			swapped, err := txn.UpdateAtIf(0, tc.column, tc.expected, tc.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.swapped, swapped, tc.column)
+		}
+		return nil
+	}))
+
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		name, _ := r.String("name")
+		assert.Equal(t, 20.0, balance)
+		assert.Equal(t, "roman", name)
+		assert.True(t, r.Bool("active"))
+		return nil
+	}))
+
+	// Values which can not be compared return an error
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		_, err := txn.UpdateAtIf(0, "balance", "ten", 20.0)
+		assert.Error(t, err)
+		_, err = txn.UpdateAtIf(0, "name", 10, "x")
+		assert.Error(t, err)
+		_, err = txn.UpdateAtIf(0, "missing", 10, 20)
+		assert.Error(t, err)
+		return nil
+	}))
+}
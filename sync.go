@@ -0,0 +1,193 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/kelindar/bitmap"
+)
+
+// RowSource represents an external dataset, such as a file or the result of a query, whose
+// rows are read one after the other as objects.
+type RowSource interface {
+	Range(fn func(Object) error) error
+}
+
+// Objects represents a set of objects held in memory, which can be used as a row source.
+type Objects []Object
+
+// Range calls the function for every object, until it returns an error.
+func (o Objects) Range(fn func(Object) error) error {
+	for _, object := range o {
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncResult represents the changes made to a collection to match an external dataset.
+type SyncResult struct {
+	Inserted int // The number of rows inserted
+	Updated  int // The number of rows with at least one value updated
+	Deleted  int // The number of rows deleted
+}
+
+// Sync reconciles the collection with an external dataset, so that it contains the same rows
+// once it returns. The rows of the source are matched with the rows of the collection by the
+// value of the key column: the rows missing from the collection are inserted, the rows whose
+// values differ are updated, and the rows of the collection which are not in the source are
+// deleted. Only the columns present in the objects of the source are compared and written,
+// and the changes are applied in batches of a chunk of rows per transaction, so syncing the
+// same dataset twice does not change anything the second time.
+func (c *Collection) Sync(source RowSource, key string) (SyncResult, error) {
+	var out SyncResult
+	column, ok := c.cols.Load(key)
+	switch {
+	case !ok:
+		return out, errorOf(ErrColumnNotFound, "column: unable to sync, column '%s' does not exist", key)
+	case column.IsIndex():
+		return out, fmt.Errorf("column: unable to sync, column '%s' is an index", key)
+	}
+
+	// Find the rows of the collection by the value of their key
+	var existing, seen bitmap.Bitmap
+	rows := make(map[interface{}]uint32, c.Count())
+	if err := c.Query(func(txn *Txn) error {
+		reader := txn.Any(key)
+		return txn.Range(func(idx uint32) {
+			existing.Set(idx)
+			if v, ok := reader.Get(); ok && reflect.TypeOf(v).Comparable() {
+				rows[v] = idx
+			}
+		})
+	}); err != nil {
+		return out, err
+	}
+
+	batch := make([]Object, 0, chunkSize)
+	flush := func() error {
+		err := c.Query(func(txn *Txn) error {
+			return txn.sync(batch, column, rows, &seen, &out)
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	if err := source.Range(func(object Object) error {
+		if batch = append(batch, object); len(batch) == chunkSize {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		return out, err
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return out, err
+		}
+	}
+
+	// Delete the rows which were not found in the source, except the ones inserted since
+	existing.AndNot(seen)
+	deletes := make([]uint32, 0, existing.Count())
+	existing.Range(func(idx uint32) {
+		deletes = append(deletes, idx)
+	})
+
+	for len(deletes) > 0 {
+		n := chunkSize
+		if n > len(deletes) {
+			n = len(deletes)
+		}
+
+		if err := c.Query(func(txn *Txn) error {
+			for _, idx := range deletes[:n] {
+				if txn.DeleteAt(idx) {
+					out.Deleted++
+				}
+			}
+			return nil
+		}); err != nil {
+			return out, err
+		}
+		deletes = deletes[n:]
+	}
+	return out, nil
+}
+
+// sync inserts or updates a batch of objects of an external dataset, depending on whether
+// the value of their key is already present in the collection.
+func (txn *Txn) sync(batch []Object, key *column, rows map[interface{}]uint32, seen *bitmap.Bitmap, out *SyncResult) error {
+	for _, object := range batch {
+		object, err := txn.syncObject(object)
+		if err != nil {
+			return err
+		}
+
+		value, ok := object[key.name]
+		if !ok || value == nil || !reflect.TypeOf(value).Comparable() {
+			return fmt.Errorf("column: unable to sync, object has no valid value for '%s'", key.name)
+		}
+
+		idx, ok := rows[value]
+		if !ok {
+			idx, err := txn.InsertObject(object)
+			if err != nil {
+				return err
+			}
+
+			rows[value] = idx
+			seen.Set(idx)
+			out.Inserted++
+			continue
+		}
+
+		seen.Set(idx)
+		changed := false
+		if err := txn.QueryAt(idx, func(r Row) error {
+			for name, v := range object {
+				if current, _ := r.Any(name); !reflect.DeepEqual(current, v) {
+					r.SetAny(name, v)
+					changed = true
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if changed {
+			out.Updated++
+		}
+	}
+	return nil
+}
+
+// syncObject flattens an object of an external dataset if required, and converts its values
+// to the native types of their columns, leaving out the values of unknown columns.
+func (txn *Txn) syncObject(object Object) (Object, error) {
+	if f := txn.owner.opts.Flatten; f != nil {
+		object = f.flatten(object, txn.isMap)
+	}
+
+	out := make(Object, len(object))
+	for name, v := range object {
+		column, ok := txn.columnAt(name)
+		if !ok || column.IsIndex() || v == nil {
+			continue
+		}
+
+		m := Set(name, v)
+		value, err := m.valueOf(column.Column)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = value
+	}
+	return out, nil
+}
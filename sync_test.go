@@ -0,0 +1,72 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSync(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("serial", ForString())
+	players.CreateColumn("class", ForEnum())
+	players.CreateColumn("balance", ForFloat64())
+	defer players.Close()
+
+	source := make(Objects, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		source = append(source, Object{
+			"serial":  fmt.Sprintf("p%d", i),
+			"class":   "mage",
+			"balance": i,
+		})
+	}
+
+	result, err := players.Sync(source, "serial")
+	assert.NoError(t, err)
+	assert.Equal(t, SyncResult{Inserted: 5000}, result)
+	assert.Equal(t, 5000, players.Count())
+
+	// Syncing the same dataset again does not change anything
+	result, err = players.Sync(source, "serial")
+	assert.NoError(t, err)
+	assert.Equal(t, SyncResult{}, result)
+
+	// Change a few rows, remove some of them and add new ones
+	source[10]["class"] = "rogue"
+	source[20]["balance"] = 1.5
+	source = append(source[:4000], Object{"serial": "new", "balance": 1})
+
+	result, err = players.Sync(source, "serial")
+	assert.NoError(t, err)
+	assert.Equal(t, SyncResult{Inserted: 1, Updated: 2, Deleted: 1000}, result)
+	assert.Equal(t, 4001, players.Count())
+	assert.Equal(t, 4000, countWhere(players, func(txn *Txn) *Txn {
+		return txn.WithValue("class", func(v interface{}) bool { return v != nil })
+	}))
+
+	players.Query(func(txn *Txn) error {
+		return txn.WithString("serial", func(v string) bool { return v == "p20" }).Range(func(idx uint32) {
+			balance, _ := txn.Float64("balance").Get()
+			assert.Equal(t, 1.5, balance)
+		})
+	})
+}
+
+func TestSyncInvalid(t *testing.T) {
+	players := loadPlayers(100)
+	defer players.Close()
+
+	_, err := players.Sync(Objects{}, "missing")
+	assert.Error(t, err)
+	_, err = players.Sync(Objects{}, "human")
+	assert.Error(t, err)
+	_, err = players.Sync(Objects{{"age": 10}}, "name")
+	assert.Error(t, err)
+	_, err = players.Sync(Objects{{"name": "roman", "age": "old"}}, "name")
+	assert.Error(t, err)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// ErrQuota is returned when a transaction would exceed the quota of a tenant, in which
+// case it is rolled back.
+var ErrQuota = errors.New("column: quota of the tenant exceeded")
+
+// Quota represents the limits enforced on the rows of a tenant. A zero limit is not enforced.
+type Quota struct {
+	MaxRows   int // The maximum number of rows of the tenant (optional)
+	MaxMemory int // The maximum size of the values of the tenant, in bytes (optional)
+}
+
+// TenantUsage represents the rows of a tenant and an estimate of the size of their values.
+type TenantUsage struct {
+	Rows   int // The number of rows of the tenant
+	Memory int // The size of the values of the tenant, in bytes
+}
+
+// exceeds returns whether the usage exceeds the quota
+func (u TenantUsage) exceeds(q Quota) bool {
+	return (q.MaxRows > 0 && u.Rows > q.MaxRows) || (q.MaxMemory > 0 && u.Memory > q.MaxMemory)
+}
+
+// tenants represents the namespaces of a collection, keyed by the value of a column
+type tenants struct {
+	lock   sync.Mutex             // The lock protecting the usage
+	column string                 // The name of the column holding the tenant of a row
+	quota  Quota                  // The default quota of every tenant
+	quotas map[string]Quota       // The quotas overriding the default one, by tenant
+	usage  map[string]TenantUsage // The usage of the tenants, including pending commits
+}
+
+// SetTenants partitions the collection into tenants, identified by the value of the
+// specified string or enum column, and enforces the default quota on every tenant.
+// The transactions which would grow a tenant beyond its quota are rolled back with
+// ErrQuota, so that a tenant can not starve the others of rows or memory. The rows of
+// the collection are scanned once in order to compute the current usage.
+func (c *Collection) SetTenants(columnName string, quota Quota) error {
+	column, ok := c.cols.Load(columnName)
+	if !ok || !column.IsTextual() {
+		return fmt.Errorf("column: unable to partition by '%s', column is not textual", columnName)
+	}
+
+	t := &tenants{
+		column: columnName,
+		quota:  quota,
+		quotas: make(map[string]Quota, 4),
+		usage:  make(map[string]TenantUsage, 4),
+	}
+
+	// Exclude the transactions while the usage is computed
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+	c.lock.RLock()
+	fill := append(bitmap.Bitmap(nil), c.fill...)
+	c.lock.RUnlock()
+
+	fill.Range(func(idx uint32) {
+		if tenant, ok := column.Value(idx); ok {
+			usage := t.usage[tenant.(string)]
+			usage.Rows++
+			usage.Memory += c.sizeOfRow(idx)
+			t.usage[tenant.(string)] = usage
+		}
+	})
+
+	c.lock.Lock()
+	c.tenants = t
+	c.lock.Unlock()
+	return nil
+}
+
+// SetQuota sets the quota of a specific tenant, overriding the default one. The quota is
+// only enforced once the collection is partitioned with SetTenants().
+func (c *Collection) SetQuota(tenant string, quota Quota) error {
+	t := c.tenantsOf()
+	if t == nil {
+		return fmt.Errorf("column: unable to set the quota of '%s', collection has no tenants", tenant)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.quotas[tenant] = quota
+	return nil
+}
+
+// TenantUsage returns the number of rows of a tenant and an estimate of their size.
+func (c *Collection) TenantUsage(tenant string) TenantUsage {
+	t := c.tenantsOf()
+	if t == nil {
+		return TenantUsage{}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.usage[tenant]
+}
+
+// tenantsOf returns the tenants of the collection, if it is partitioned
+func (c *Collection) tenantsOf() *tenants {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.tenants
+}
+
+// sizeOfRow estimates the size of the values of a row, in bytes
+func (c *Collection) sizeOfRow(idx uint32) (size int) {
+	c.cols.Range(func(v *column) {
+		if value, ok := v.Value(idx); ok && !v.IsIndex() {
+			size += sizeOfValue(value)
+		}
+	})
+	return
+}
+
+// sizeOfValue estimates the size of a value, in bytes
+func sizeOfValue(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// checkQuotas computes the changes of usage of the tenants proposed by the transaction and
+// reserves them, unless a tenant would exceed its quota. The reservation is released if
+// the transaction is rolled back.
+func (txn *Txn) checkQuotas() error {
+	t := txn.owner.tenantsOf()
+	if t == nil || !txn.modified() {
+		return nil
+	}
+
+	column, _ := txn.owner.cols.Load(t.column)
+	deltas := make(map[string]TenantUsage, 4)
+	move := func(tenant string, rows, memory int) {
+		usage := deltas[tenant]
+		usage.Rows += rows
+		usage.Memory += memory
+		deltas[tenant] = usage
+	}
+
+	changes, kinds := txn.changes()
+	for idx, change := range changes {
+		if kinds[idx] == hookInsert {
+			if tenant, ok := change.Values[t.column].(string); ok {
+				move(tenant, 1, sizeOfChange(change))
+			}
+			continue
+		}
+
+		// Read the current tenant of the row, along with the size of its values
+		chunk := commit.ChunkAt(idx)
+		if !txn.rlock(chunk) {
+			return txn.failed()
+		}
+
+		current, existed := column.Value(idx)
+		size, replaced := txn.owner.sizeOfRow(idx), 0
+		for name := range change.Values {
+			if v, ok := txn.owner.cols.Load(name); ok {
+				if value, ok := v.Value(idx); ok {
+					replaced += sizeOfValue(value)
+				}
+			}
+		}
+		txn.runlock(chunk)
+
+		next, moved := change.Values[t.column].(string)
+		switch {
+		case !existed && !moved:
+			continue // The row does not belong to any tenant
+		case kinds[idx] == hookDelete:
+			move(current.(string), -1, -size)
+		case moved && (!existed || next != current.(string)):
+			if existed {
+				move(current.(string), -1, -size)
+			}
+			move(next, 1, size-replaced+sizeOfChange(change))
+		default:
+			move(current.(string), 0, sizeOfChange(change)-replaced)
+		}
+	}
+
+	// Reserve the usage, unless a tenant which grows exceeds its quota
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for tenant, delta := range deltas {
+		quota, ok := t.quotas[tenant]
+		if !ok {
+			quota = t.quota
+		}
+
+		usage := t.usage[tenant]
+		grown := TenantUsage{Rows: usage.Rows + delta.Rows, Memory: usage.Memory + delta.Memory}
+		if (delta.Rows > 0 || delta.Memory > 0) && grown.exceeds(quota) {
+			return ErrQuota
+		}
+	}
+
+	for tenant, delta := range deltas {
+		usage := t.usage[tenant]
+		usage.Rows += delta.Rows
+		usage.Memory += delta.Memory
+		t.usage[tenant] = usage
+	}
+
+	txn.reserved = deltas
+	return nil
+}
+
+// releaseQuotas releases the usage reserved by the transaction, if it is rolled back
+func (txn *Txn) releaseQuotas() {
+	t := txn.owner.tenantsOf()
+	if t == nil || txn.reserved == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for tenant, delta := range txn.reserved {
+		usage := t.usage[tenant]
+		usage.Rows -= delta.Rows
+		usage.Memory -= delta.Memory
+		t.usage[tenant] = usage
+	}
+}
+
+// sizeOfChange estimates the size of the values written by a change, in bytes
+func sizeOfChange(change *Change) (size int) {
+	for _, v := range change.Values {
+		if v != nil {
+			size += sizeOfValue(v)
+		}
+	}
+	return
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantQuota(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("tenant", ForEnum())
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	insert := func(tenant string, n int) error {
+		return col.Query(func(txn *Txn) error {
+			for i := 0; i < n; i++ {
+				if _, err := txn.InsertObject(Object{"tenant": tenant, "name": "abcd"}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	assert.NoError(t, insert("acme", 5))
+	assert.Error(t, col.SetTenants("missing", Quota{}))
+	assert.Error(t, col.SetQuota("acme", Quota{}))
+	assert.NoError(t, col.SetTenants("tenant", Quota{MaxRows: 10}))
+	assert.Equal(t, TenantUsage{Rows: 5, Memory: 40}, col.TenantUsage("acme"))
+
+	// The transaction exceeding the quota is rolled back entirely
+	assert.Equal(t, ErrQuota, insert("acme", 6))
+	assert.Equal(t, 5, col.Count())
+	assert.NoError(t, insert("acme", 5))
+	assert.NoError(t, insert("globex", 10))
+	assert.Equal(t, 20, col.Count())
+
+	// Deleting rows releases the quota
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		return txn.WithValue("tenant", func(v interface{}) bool {
+			return v == "acme"
+		}).Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}))
+	assert.Equal(t, TenantUsage{}, col.TenantUsage("acme"))
+	assert.NoError(t, insert("acme", 10))
+
+	// Quotas can be overridden for a tenant, including the size of the values
+	assert.NoError(t, col.SetQuota("initech", Quota{MaxMemory: 20}))
+	assert.NoError(t, insert("initech", 1))
+	assert.Equal(t, ErrQuota, col.QueryAt(20, func(r Row) error {
+		r.SetString("name", "a very long name")
+		return nil
+	}))
+	assert.Equal(t, ErrQuota, insert("initech", 2))
+	assert.Equal(t, TenantUsage{Rows: 1, Memory: 11}, col.TenantUsage("initech"))
+}
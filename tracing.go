@@ -0,0 +1,73 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+)
+
+// Tracer starts the trace spans for the operations performed on a collection. This allows
+// a distributed tracing library, such as OpenTelemetry, to be plugged in with a small
+// adapter and without the collection depending on it.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span represents a single traced operation, started by a tracer.
+type Span interface {
+	SetAttribute(key string, value interface{}) // SetAttribute attaches an attribute to the span
+	End(err error)                              // End completes the span, with an optional error
+}
+
+// Names of the spans and of the attributes reported by the collection
+const (
+	SpanQuery       = "column.query"
+	SpanCommit      = "column.commit"
+	SpanSnapshot    = "column.snapshot"
+	AttrRowsMatched = "column.rows.matched"
+	AttrRowsScanned = "column.rows.scanned"
+	AttrFilters     = "column.filters"
+	AttrIndexes     = "column.indexes"
+	AttrChunks      = "column.chunks"
+)
+
+// trace starts a new span with the tracer of the collection, if there is one.
+func (c *Collection) trace(ctx context.Context, name string) (context.Context, Span) {
+	if c.opts.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return c.opts.Tracer.Start(ctx, name)
+}
+
+// traceQuery attaches the attributes of a completed transaction to its span.
+func (txn *Txn) traceQuery(span Span) {
+	if txn.owner.opts.Tracer == nil {
+		return
+	}
+
+	matched := 0
+	if txn.setup {
+		matched = txn.index.Count()
+	}
+
+	filters := make([]string, 0, len(txn.applied))
+	indexes := make([]string, 0, len(txn.applied))
+	for _, f := range txn.applied {
+		filters = append(filters, f.String())
+		if f.indexed() {
+			indexes = append(indexes, f.column)
+		}
+	}
+
+	span.SetAttribute(AttrRowsMatched, matched)
+	span.SetAttribute(AttrRowsScanned, txn.scanned)
+	span.SetAttribute(AttrFilters, filters)
+	span.SetAttribute(AttrIndexes, indexes)
+}
+
+// noopSpan represents a span which does nothing, used when tracing is disabled.
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) End(error)                        {}
@@ -0,0 +1,166 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/kelindar/column/commit"
+)
+
+// TriggerEvent represents a row changed by a transaction, along with its values before
+// and after the change.
+type TriggerEvent struct {
+	Index uint32 // The index of the row
+	Old   Object // The values of the row before the change, or nil if it is inserted
+	New   Object // The values of the row after the change, or nil if it is deleted
+}
+
+// Trigger represents a function which is called within a transaction with a row changed by
+// it, before the transaction is committed. Unlike a hook, a trigger can make changes to the
+// transaction, for example in order to maintain a counter or a derived column, which are
+// then committed along with the row. If the trigger returns an error, the entire transaction
+// is rolled back. The changes made by the triggers do not call the triggers again.
+type Trigger func(txn *Txn, event TriggerEvent) error
+
+// trigger represents a registered trigger, along with the column it observes
+type trigger struct {
+	column string  // The column whose changes call the trigger, for the updates
+	fn     Trigger // The trigger function
+}
+
+// OnInsert registers a trigger which is called for every row inserted by a transaction.
+func (c *Collection) OnInsert(fn Trigger) {
+	c.addTrigger(hookInsert, "", fn)
+}
+
+// OnUpdate registers a trigger which is called for every existing row whose value in the
+// specified column is changed by a transaction.
+func (c *Collection) OnUpdate(columnName string, fn Trigger) {
+	c.addTrigger(hookUpdate, columnName, fn)
+}
+
+// OnDelete registers a trigger which is called for every row deleted by a transaction.
+func (c *Collection) OnDelete(fn Trigger) {
+	c.addTrigger(hookDelete, "", fn)
+}
+
+// addTrigger registers a trigger for a kind of change. The triggers are copied, so that
+// they can be read without holding the lock.
+func (c *Collection) addTrigger(kind hookType, columnName string, fn Trigger) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	triggers := make([]trigger, 0, len(c.trigs[kind])+1)
+	c.trigs[kind] = append(append(triggers, c.trigs[kind]...), trigger{
+		column: columnName,
+		fn:     fn,
+	})
+}
+
+// runTriggers calls the registered triggers with all of the changes made by the transaction,
+// in the order of the rows, and returns the first error.
+func (txn *Txn) runTriggers() error {
+	txn.owner.lock.RLock()
+	triggers := txn.owner.trigs
+	txn.owner.lock.RUnlock()
+	if len(triggers[hookInsert]) == 0 && len(triggers[hookUpdate]) == 0 && len(triggers[hookDelete]) == 0 {
+		return nil
+	}
+
+	changes, kinds := txn.changes()
+	order := make([]uint32, 0, len(changes))
+	for idx := range changes {
+		if len(triggers[kinds[idx]]) > 0 {
+			order = append(order, idx)
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	for _, idx := range order {
+		kind, change := kinds[idx], changes[idx]
+		event := TriggerEvent{Index: idx}
+		if kind != hookInsert {
+			chunk := commit.ChunkAt(idx)
+			if !txn.rlock(chunk) {
+				return txn.failed()
+			}
+
+			event.Old = txn.objectAt(idx)
+			txn.runlock(chunk)
+		}
+
+		if kind != hookDelete {
+			event.New = valuesAfter(txn.owner, event.Old, change)
+		}
+
+		for _, t := range triggers[kind] {
+			if kind == hookUpdate && !event.changed(t.column) {
+				continue
+			}
+
+			if err := t.fn(txn, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// changed returns whether the value of a column differs before and after the change
+func (e *TriggerEvent) changed(columnName string) bool {
+	before, existed := e.Old[columnName]
+	after, exists := e.New[columnName]
+	return existed != exists || before != after
+}
+
+// valuesAfter returns the values of a row once a change is applied to its current values
+func valuesAfter(owner *Collection, current Object, change *Change) Object {
+	out := make(Object, len(current)+len(change.Values))
+	for k, v := range current {
+		out[k] = v
+	}
+
+	for k, v := range change.Values {
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		out[k] = v
+	}
+
+	for k, delta := range change.Deltas {
+		out[k] = addValue(out[k], delta)
+	}
+
+	for k, delta := range change.Merges {
+		if merge := owner.mergeOf(k); merge != nil {
+			if out[k] = merge(out[k], delta); out[k] == nil {
+				delete(out, k)
+			}
+		}
+	}
+	return out
+}
+
+// addValue adds a delta to a numeric value of the same type
+func addValue(value, delta interface{}) interface{} {
+	if value == nil || reflect.TypeOf(value) != reflect.TypeOf(delta) {
+		return delta
+	}
+
+	v, d := reflect.ValueOf(value), reflect.ValueOf(delta)
+	out := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out.SetInt(v.Int() + d.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		out.SetUint(v.Uint() + d.Uint())
+	case reflect.Float32, reflect.Float64:
+		out.SetFloat(v.Float() + d.Float())
+	default:
+		return delta
+	}
+	return out.Interface()
+}
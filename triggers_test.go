@@ -0,0 +1,153 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggers(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("owner", ForString())
+	col.CreateColumn("balance", ForInt())
+	col.CreateColumn("tier", ForString())
+	col.CreateColumn("count", ForInt())
+	defer col.Close()
+
+	// The first row keeps the number of accounts
+	counter, err := col.Insert(func(r Row) error {
+		r.SetInt("count", 0)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	errOverdraft := errors.New("overdraft")
+	var updates []TriggerEvent
+	col.OnInsert(func(txn *Txn, event TriggerEvent) error {
+		assert.Nil(t, event.Old)
+		return txn.QueryAt(counter, func(r Row) error {
+			r.AddInt("count", 1)
+			return nil
+		})
+	})
+	col.OnDelete(func(txn *Txn, event TriggerEvent) error {
+		assert.Nil(t, event.New)
+		return txn.QueryAt(counter, func(r Row) error {
+			r.AddInt("count", -1)
+			return nil
+		})
+	})
+	col.OnUpdate("balance", func(txn *Txn, event TriggerEvent) error {
+		updates = append(updates, event)
+		balance := event.New["balance"].(int)
+		if balance < 0 {
+			return errOverdraft
+		}
+
+		return txn.QueryAt(event.Index, func(r Row) error {
+			if balance >= 100 {
+				r.SetString("tier", "gold")
+			} else {
+				r.SetString("tier", "basic")
+			}
+			return nil
+		})
+	})
+
+	// Inserts maintain the counter, but the changes of the counter do not trigger again
+	for i := 0; i < 3; i++ {
+		col.Insert(func(r Row) error {
+			r.SetString("owner", "roman")
+			r.SetInt("balance", 10)
+			return nil
+		})
+	}
+
+	assert.Equal(t, 3, countOf(col, counter))
+	assert.Len(t, updates, 0)
+
+	// Updates of the balance maintain the derived tier, with the old and new values
+	assert.NoError(t, col.QueryAt(1, func(r Row) error {
+		r.AddInt("balance", 150)
+		return nil
+	}))
+	assert.Len(t, updates, 1)
+	assert.Equal(t, 10, updates[0].Old["balance"])
+	assert.Equal(t, 160, updates[0].New["balance"])
+	col.QueryAt(1, func(r Row) error {
+		tier, _ := r.String("tier")
+		assert.Equal(t, "gold", tier)
+		return nil
+	})
+
+	// Updates of other columns, or with the same value, do not call the trigger
+	col.QueryAt(2, func(r Row) error {
+		r.SetString("owner", "alice")
+		r.SetInt("balance", 10)
+		return nil
+	})
+	assert.Len(t, updates, 1)
+
+	// An error rolls back the entire transaction
+	assert.Equal(t, errOverdraft, col.QueryAt(2, func(r Row) error {
+		r.SetInt("balance", -5)
+		return nil
+	}))
+	col.QueryAt(2, func(r Row) error {
+		balance, _ := r.Int("balance")
+		assert.Equal(t, 10, balance)
+		return nil
+	})
+
+	// Deletes maintain the counter
+	assert.True(t, col.DeleteAt(3))
+	assert.Equal(t, 2, countOf(col, counter))
+}
+
+func TestTriggersAtomic(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("balance", ForInt())
+	col.CreateColumn("double", ForInt())
+	defer col.Close()
+
+	col.OnInsert(func(txn *Txn, event TriggerEvent) error {
+		return txn.QueryAt(event.Index, func(r Row) error {
+			r.SetInt("double", event.New["balance"].(int)*2)
+			return nil
+		})
+	})
+
+	assert.NoError(t, Atomic(func(tx *MultiTxn) error {
+		return tx.Query(col, func(txn *Txn) error {
+			_, err := txn.InsertObject(Object{"balance": 21})
+			return err
+		})
+	}))
+
+	col.QueryAt(0, func(r Row) error {
+		double, _ := r.Int("double")
+		assert.Equal(t, 42, double)
+		return nil
+	})
+}
+
+func TestAddValue(t *testing.T) {
+	assert.Equal(t, 3, addValue(1, 2))
+	assert.Equal(t, uint8(3), addValue(uint8(1), uint8(2)))
+	assert.Equal(t, 1.5, addValue(1.0, 0.5))
+	assert.Equal(t, 2, addValue(nil, 2))
+	assert.Equal(t, 2, addValue("a", 2))
+}
+
+// countOf reads the counter of the rows
+func countOf(col *Collection, counter uint32) (n int) {
+	col.QueryAt(counter, func(r Row) error {
+		n, _ = r.Int("count")
+		return nil
+	})
+	return
+}
@@ -4,17 +4,46 @@
 package column
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
+	"github.com/zeebo/xxh3"
 )
 
 var (
-	errNoKey = errors.New("column: collection does not have a key column")
+	errNoKey       = errorOf(ErrColumnNotFound, "column: collection does not have a key column")
+	errNoSavepoint = errors.New("column: savepoint does not belong to the transaction")
+	errRowExists   = errors.New("column: unable to insert, row already exists")
+)
+
+var (
+	// ErrTimeout is returned when a transaction runs for longer than the timeout configured
+	// for the collection and was aborted.
+	ErrTimeout = errors.New("column: transaction timed out")
+
+	// ErrLockTimeout is returned when a transaction waited for a chunk lock for longer than
+	// the lock timeout configured for the collection and was aborted.
+	ErrLockTimeout = errors.New("column: transaction timed out waiting for a lock")
+
+	// ErrReadOnly is returned when a read-only transaction attempted to modify the collection
+	// and was rolled back.
+	ErrReadOnly = errors.New("column: read-only transaction attempted a write")
+
+	// ErrDeleted is returned when a row which was soft-deleted, and not yet purged, is
+	// accessed at its index. The row can be recovered with Undelete().
+	ErrDeleted = errors.New("column: row is deleted")
+
+	// ErrConflict is returned when a conditional write of a transaction could not be applied,
+	// since the value was changed by a concurrent transaction before it was committed. The
+	// whole transaction is rolled back.
+	ErrConflict = errors.New("column: conditional write conflicted with a concurrent transaction")
 )
 
 // --------------------------- Pool of Transactions ----------------------------
@@ -32,8 +61,11 @@ func newTxnPool() *txnPool {
 				return &Txn{
 					index:   make(bitmap.Bitmap, 0, 4),
 					dirty:   make(bitmap.Bitmap, 0, 4),
+					deletes: make(bitmap.Bitmap, 0, 4),
 					updates: make([]*commit.Buffer, 0, 256),
 					columns: make([]columnCache, 0, 16),
+					filters: make([]filter, 0, 8),
+					applied: make([]filter, 0, 8),
 					reader:  commit.NewReader(),
 				}
 			},
@@ -49,6 +81,27 @@ func newTxnPool() *txnPool {
 // acquire acquires a new transaction from the pool
 func (p *txnPool) acquire(owner *Collection) *Txn {
 	txn := p.txns.Get().(*Txn)
+	txn.ctx = context.Background()
+	txn.err = nil
+	txn.tombstones = false
+	txn.unlocked = false
+	txn.priority = PriorityNormal
+	txn.meta = nil
+	txn.scanned = 0
+	txn.plan = nil
+	txn.hints = hints{noIndex: txn.hints.noIndex[:0]}
+	txn.policy = nil
+	txn.principal = nil
+	txn.masks = nil
+	txn.filters = txn.filters[:0]
+	txn.applied = txn.applied[:0]
+	txn.cached = nil
+	txn.budget = nil
+	txn.replay = nil
+	txn.loaded = false
+	txn.expiring = 0
+	txn.derived = nil
+	txn.bulk = false
 	txn.owner = owner
 	txn.logger = owner.logger
 	txn.setup = false
@@ -57,6 +110,7 @@ func (p *txnPool) acquire(owner *Collection) *Txn {
 
 // release the transaction to the pool or the GC
 func (p *txnPool) release(txn *Txn) {
+	txn.leave()
 	p.txns.Put(txn)
 }
 
@@ -77,31 +131,86 @@ func (p *txnPool) releasePage(buffer *commit.Buffer) {
 
 // Txn represents a transaction which supports filtering and projection.
 type Txn struct {
-	cursor  uint32           // The current cursor
-	setup   bool             // Whether the transaction was set up or not
-	owner   *Collection      // The target collection
-	index   bitmap.Bitmap    // The filtering index
-	dirty   bitmap.Bitmap    // The dirty chunks
-	updates []*commit.Buffer // The update buffers
-	columns []columnCache    // The column mapping
-	logger  commit.Logger    // The optional commit logger
-	reader  *commit.Reader   // The commit reader to re-use
+	ctx        context.Context        // The context of the transaction
+	err        error                  // The error which aborted the transaction
+	cursor     uint32                 // The current cursor
+	setup      bool                   // Whether the transaction was set up or not
+	tombstones bool                   // Whether the soft-deleted rows are selected
+	unlocked   bool                   // Whether the chunks are read without locking them
+	pinned     bool                   // Whether all of the chunks are read-locked by the views of the columns
+	priority   Priority               // The priority of the transaction for the chunk locks
+	meta       Metadata               // The metadata attached to the transaction
+	scanned    int                    // The number of rows scanned, if observed
+	plan       *Plan                  // The execution plan, if being explained
+	hints      hints                  // The hints overriding the query planner
+	policy     RowPolicy              // The row policy to enforce, if a principal is set
+	principal  interface{}            // The principal on behalf of which the transaction runs
+	masks      map[string]Mask        // The masks of the columns, if a principal is set
+	filters    []filter               // The pending filters, applied lazily
+	applied    []filter               // The filters applied, if slow queries are reported or traced
+	owner      *Collection            // The target collection
+	index      bitmap.Bitmap          // The filtering index
+	dirty      bitmap.Bitmap          // The dirty chunks
+	deletes    bitmap.Bitmap          // The pending bulk deletes
+	updates    []*commit.Buffer       // The update buffers
+	swaps      []swap                 // The conditional writes, checked at commit
+	held       bitmap.Bitmap          // The shards of the chunks locked ahead of the commit, to check the conditional writes
+	bulk       bool                   // Whether the indexes are built after the commit
+	admitted   bool                   // Whether the transaction holds a slot to scan
+	columns    []columnCache          // The column mapping
+	logger     commit.Logger          // The optional commit logger
+	reader     *commit.Reader         // The commit reader to re-use
+	reserved   map[string]TenantUsage // The usage of the tenants reserved by the transaction
+	cached     *cacheEntry            // The cached selection, unless filtered further
+	budget     *memoryBudget          // The memory the query may allocate, if limited
+	replay     *replayState           // The commit being replayed, if its conflicts are resolved
+	loaded     bool                   // Whether the rows are loaded from the backend, and not written back
+	expiring   int64                  // The time at which the expired values of the columns are removed, if any
+	derived    []derivedChange        // The changes of the source rows to copy into the derived columns, once committed
+	keys       map[string]uint32      // The rows of the primary keys written by the transaction
+	claimed    []string               // The primary keys written by the transaction, in order
 }
 
 // Reset resets the transaction state so it can be used again.
 func (txn *Txn) reset() {
+	txn.countAccess()
 	for i := range txn.updates {
 		txn.owner.txns.releasePage(txn.updates[i])
 	}
 
 	txn.dirty.Clear()
+	txn.deletes.Clear()
 	txn.reader.Rewind()
 	txn.columns = txn.columns[:0]
 	txn.updates = txn.updates[:0]
+	txn.swaps = txn.swaps[:0]
+	txn.reserved = nil
+	txn.releaseKeys(0)
 }
 
-// bufferFor loads or creates a buffer for a given column.
+// modified returns whether the transaction has any pending updates or deletes.
+func (txn *Txn) modified() bool {
+	for _, u := range txn.updates {
+		if !u.IsEmpty() {
+			return true
+		}
+	}
+	return txn.deletes.Count() > 0
+}
+
+// Context returns the context of the transaction. For transactions started with Query(),
+// this is always a background context.
+func (txn *Txn) Context() context.Context {
+	return txn.ctx
+}
+
+// bufferFor loads or creates a buffer for a given column. Since the internal columns are
+// maintained by the collection, a write into one of them aborts the transaction.
 func (txn *Txn) bufferFor(columnName string) *commit.Buffer {
+	if columnName == versionColumn {
+		txn.abort(errorOf(ErrReservedColumn, "column: unable to write %s, it is maintained by the collection", columnName))
+	}
+
 	for _, c := range txn.updates {
 		if c.Column == columnName {
 			return c
@@ -145,37 +254,50 @@ func (txn *Txn) columnAt(columnName string) (*column, bool) {
 
 // With applies a logical AND operation to the current query and the specified index.
 func (txn *Txn) With(columns ...string) *Txn {
-	txn.initialize()
 	for _, columnName := range columns {
-		if idx, ok := txn.columnAt(columnName); ok {
-			txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
-				dst.And(src)
-			})
-		} else {
-			txn.index.Clear()
-		}
+		txn.filter(filterWith, columnName, nil)
 	}
 	return txn
 }
 
+// with applies a logical AND operation to the current query and the specified index.
+func (txn *Txn) with(columnName string) {
+	defer txn.trace("With", columnName, true)()
+	if idx, ok := txn.columnAt(columnName); ok {
+		txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
+			dst.And(src)
+		})
+	} else {
+		txn.index.Clear()
+	}
+}
+
 // Without applies a logical AND NOT operation to the current query and the specified index.
 func (txn *Txn) Without(columns ...string) *Txn {
-	txn.initialize()
 	for _, columnName := range columns {
-		if idx, ok := txn.columnAt(columnName); ok {
-			txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
-				dst.AndNot(src)
-			})
-		}
+		txn.filter(filterWithout, columnName, nil)
 	}
 	return txn
 }
 
+// without applies a logical AND NOT operation to the current query and the specified index.
+func (txn *Txn) without(columnName string) {
+	defer txn.trace("Without", columnName, true)()
+	if idx, ok := txn.columnAt(columnName); ok {
+		txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
+			dst.AndNot(src)
+		})
+	}
+}
+
 // Union computes a union between the current query and the specified index.
 func (txn *Txn) Union(columns ...string) *Txn {
-	first := !txn.setup
+	first := !txn.setup && len(txn.filters) == 0
 	txn.initialize()
+	txn.cached = nil
 	for _, columnName := range columns {
+		txn.record(filterUnion, columnName)
+		done := txn.trace("Union", columnName, true)
 		if idx, ok := txn.columnAt(columnName); ok {
 			txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
 				if first {
@@ -186,6 +308,7 @@ func (txn *Txn) Union(columns ...string) *Txn {
 			})
 		}
 		first = false
+		done()
 	}
 	return txn
 }
@@ -193,11 +316,17 @@ func (txn *Txn) Union(columns ...string) *Txn {
 // WithValue applies a filter predicate over values for a specific properties. It filters
 // down the items in the query.
 func (txn *Txn) WithValue(column string, predicate func(v interface{}) bool) *Txn {
-	txn.initialize()
+	txn.filter(filterValue, column, predicate)
+	return txn
+}
+
+// withValue filters down the current selection using the predicate over values.
+func (txn *Txn) withValue(column string, predicate func(v interface{}) bool) {
+	defer txn.trace("WithValue", column, false)()
 	c, ok := txn.columnAt(column)
 	if !ok {
 		txn.index.Clear()
-		return txn
+		return
 	}
 
 	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
@@ -208,114 +337,372 @@ func (txn *Txn) WithValue(column string, predicate func(v interface{}) bool) *Tx
 			return
 		})
 	})
-	return txn
 }
 
 // WithFloat filters down the values based on the specified predicate. The column for
 // this filter must be numerical and convertible to float64.
 func (txn *Txn) WithFloat(column string, predicate func(v float64) bool) *Txn {
-	txn.initialize()
+	txn.filter(filterFloat, column, predicate)
+	return txn
+}
+
+// withFloat filters down the current selection based on the specified predicate.
+func (txn *Txn) withFloat(column string, predicate func(v float64) bool) {
+	defer txn.trace("WithFloat", column, false)()
 	c, ok := txn.columnAt(column)
 	if !ok || !c.IsNumeric() {
 		txn.index.Clear()
-		return txn
+		return
 	}
 
 	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
 		c.Column.(Numeric).FilterFloat64(offset, index, predicate)
 	})
+}
+
+// WithFloatGreater filters down the values to the ones greater than the specified value. The
+// column for this filter must be numerical. Unlike WithFloat(), the values are compared in
+// bulk rather than by calling a predicate for every value, which is considerably faster.
+func (txn *Txn) WithFloatGreater(column string, value float64) *Txn {
+	txn.filterRange(filterGreater, column, math.Nextafter(value, math.Inf(1)), math.Inf(1))
+	return txn
+}
+
+// WithFloatLess filters down the values to the ones less than the specified value. The
+// column for this filter must be numerical. Unlike WithFloat(), the values are compared in
+// bulk rather than by calling a predicate for every value, which is considerably faster.
+func (txn *Txn) WithFloatLess(column string, value float64) *Txn {
+	txn.filterRange(filterLess, column, math.Inf(-1), math.Nextafter(value, math.Inf(-1)))
+	return txn
+}
+
+// WithFloatBetween filters down the values to the ones between min and max, inclusive. The
+// column for this filter must be numerical. Unlike WithFloat(), the values are compared in
+// bulk rather than by calling a predicate for every value, which is considerably faster.
+func (txn *Txn) WithFloatBetween(column string, min, max float64) *Txn {
+	txn.filterRange(filterBetween, column, min, max)
 	return txn
 }
 
+// withRange filters down the current selection to the values within the inclusive range.
+func (txn *Txn) withRange(kind filterKind, column string, lo, hi float64) {
+	defer txn.trace(filterNames[kind], column, false)()
+	c, ok := txn.columnAt(column)
+	if !ok || !c.IsNumeric() {
+		txn.index.Clear()
+		return
+	}
+
+	txn.withRangeOf(c, lo, hi)
+}
+
+// withRangeOf filters down the current selection to the values of a numeric column within
+// the inclusive range.
+func (txn *Txn) withRangeOf(c *column, lo, hi float64) {
+
+	// Use the index created by the advisor for the same range, if any
+	if name, ok := txn.owner.advisor.indexFor(c.name, lo, hi); ok && txn.hints.allows(c.name) && txn.hints.allows(name) {
+		if index, ok := txn.columnAt(name); ok {
+			txn.rangeReadPair(index, func(dst, src bitmap.Bitmap) {
+				dst.And(src)
+			})
+			return
+		}
+	}
+
+	zones := c.zones
+	if !txn.hints.allows(c.name) {
+		zones = nil
+	}
+
+	kernel, isKernel := c.Column.(rangeFilter)
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		switch {
+
+		// Skip the chunk if its zone shows that none of its values can be in the range
+		case zones != nil && zones.excludes(commit.ChunkAt(offset), lo, hi):
+			if index.Count() > 0 {
+				atomic.AddUint64(&txn.owner.queries.pruned, 1)
+				index.Clear()
+			}
+
+		// Use the bulk comparison if the column supports it, or fall back to a predicate
+		case isKernel:
+			kernel.filterRange(offset, index, lo, hi)
+		default:
+			c.Column.(Numeric).FilterFloat64(offset, index, func(v float64) bool {
+				return v >= lo && v <= hi
+			})
+		}
+	})
+}
+
 // WithInt filters down the values based on the specified predicate. The column for
 // this filter must be numerical and convertible to int64.
 func (txn *Txn) WithInt(column string, predicate func(v int64) bool) *Txn {
-	txn.initialize()
+	txn.filter(filterInt, column, predicate)
+	return txn
+}
+
+// withInt filters down the current selection based on the specified predicate.
+func (txn *Txn) withInt(column string, predicate func(v int64) bool) {
+	defer txn.trace("WithInt", column, false)()
 	c, ok := txn.columnAt(column)
 	if !ok || !c.IsNumeric() {
 		txn.index.Clear()
-		return txn
+		return
 	}
 
 	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
 		c.Column.(Numeric).FilterInt64(offset, index, predicate)
 	})
-	return txn
 }
 
 // WithUint filters down the values based on the specified predicate. The column for
 // this filter must be numerical and convertible to uint64.
 func (txn *Txn) WithUint(column string, predicate func(v uint64) bool) *Txn {
-	txn.initialize()
+	txn.filter(filterUint, column, predicate)
+	return txn
+}
+
+// withUint filters down the current selection based on the specified predicate.
+func (txn *Txn) withUint(column string, predicate func(v uint64) bool) {
+	defer txn.trace("WithUint", column, false)()
 	c, ok := txn.columnAt(column)
 	if !ok || !c.IsNumeric() {
 		txn.index.Clear()
-		return txn
+		return
 	}
 
 	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
 		c.Column.(Numeric).FilterUint64(offset, index, predicate)
 	})
-	return txn
 }
 
 // WithString filters down the values based on the specified predicate. The column for
 // this filter must be a string.
 func (txn *Txn) WithString(column string, predicate func(v string) bool) *Txn {
-	txn.initialize()
+	txn.filter(filterString, column, predicate)
+	return txn
+}
+
+// withString filters down the current selection based on the specified predicate.
+func (txn *Txn) withString(column string, predicate func(v string) bool) {
+	defer txn.trace("WithString", column, false)()
 	c, ok := txn.columnAt(column)
 	if !ok || !c.IsTextual() {
 		txn.index.Clear()
-		return txn
+		return
 	}
 
 	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
 		c.Column.(Textual).FilterString(offset, index, predicate)
 	})
+}
+
+// WithStringEqual filters down the values which are equal to the specified string. The
+// column for this filter must be a string. The values are compared with the collation of
+// the column, byte by byte unless specified otherwise. If a bloom filter index was created
+// on the column, the chunks which do not contain the value are skipped without being scanned.
+func (txn *Txn) WithStringEqual(column, value string) *Txn {
+	txn.filter(filterEqual, column, value)
 	return txn
 }
 
-// Count returns the number of objects matching the query
+// WithStringFold filters down the values which are equal to the specified string under
+// Unicode case folding, regardless of the collation of the column. The column for this
+// filter must be a string.
+func (txn *Txn) WithStringFold(column, value string) *Txn {
+	txn.filter(filterFold, column, value)
+	return txn
+}
+
+// withStringEqual filters down the current selection to the values equal to the string,
+// under the collation of the column or with case folding.
+func (txn *Txn) withStringEqual(kind filterKind, column, value string) {
+	columns, ok := txn.owner.cols.LoadWithIndex(column)
+	if !ok || !columns[0].IsTextual() {
+		defer txn.trace(filterNames[kind], column, false)()
+		txn.index.Clear()
+		return
+	}
+
+	// A lookup finds the rows with the exact value without scanning the column
+	columns = txn.hints.indexesOf(columns)
+	collation := collationOf(columns[0].Column)
+	if lookup := lookupOf(columns); lookup != nil && kind == filterEqual && collation == CollateBinary {
+		defer txn.trace(filterNames[kind], column, true)()
+		var rows bitmap.Bitmap
+		lookup.rowsOf(value, &rows)
+		txn.index.And(rows)
+		return
+	}
+
+	// A dictionary index keeps the rows of every value of an enum as a bitmap
+	if dictionary := dictionaryOf(columns); dictionary != nil && kind == filterEqual && collation == CollateBinary {
+		defer txn.trace(filterNames[kind], column, true)()
+		dictionary.filter(value, &txn.index)
+		return
+	}
+
+	// The bloom filters can only be used with the collation they were computed with
+	txn.owner.lock.RLock()
+	filters := bloomOf(columns)
+	txn.owner.lock.RUnlock()
+	if kind == filterFold && collation != CollateFold {
+		collation, filters = CollateFold, nil
+	}
+
+	defer txn.trace(filterNames[kind], column, filters != nil)()
+	hash := xxh3.Hash(collation.key([]byte(value)))
+	textual := columns[0].Column.(Textual)
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		if filters != nil && !bloomContains(filters, commit.ChunkAt(offset), hash) {
+			for i := range index {
+				index[i] = 0
+			}
+			return
+		}
+
+		textual.FilterString(offset, index, func(v string) bool {
+			return collation.Equal(v, value)
+		})
+	})
+}
+
+// Count returns the number of objects matching the query. If the filters only intersect
+// bitmap indexes, the rows are counted from the bitmaps without selecting them, and the
+// counts of the chunks which were not committed since the last count are reused.
 func (txn *Txn) Count() int {
+	if len(txn.filters) > 0 {
+		if agg, ok := txn.pushdown("", nil); ok {
+			return agg.Count
+		}
+	}
+
 	txn.initialize()
 	return int(txn.index.Count())
 }
 
 // QueryKey jumps at a particular key in the collection, sets the cursor to the
-// provided position and executes given callback fn.
+// provided position and executes given callback fn. If the row with the key was
+// soft-deleted and not yet purged, it is recovered first, as with Undelete().
 func (txn *Txn) QueryKey(key string, fn func(Row) error) error {
 	if txn.owner.pk == nil {
 		return errNoKey
 	}
 
 	if idx, ok := txn.owner.pk.OffsetOf(key); ok {
+		if !txn.owner.opts.SoftDelete {
+			return txn.QueryAt(idx, fn)
+		}
+
+		tombstones := txn.tombstones
+		txn.tombstones = true
+		defer func() { txn.tombstones = tombstones }()
+		return txn.QueryAt(idx, func(r Row) error {
+			txn.Undelete(idx)
+			return fn(r)
+		})
+	}
+
+	// If the key was written by the transaction, jump at its row
+	if idx, ok := txn.keys[key]; ok {
 		return txn.QueryAt(idx, fn)
 	}
 
 	// If not found, insert at a new index
 	idx, err := txn.insert(fn, 0)
+	if err := txn.claimKey(key, idx); err != nil {
+		return err
+	}
+
 	txn.bufferFor(txn.owner.pk.name).PutString(commit.Put, idx, key)
 	return err
 }
 
+// SeekAll narrows down the selection to the rows whose value is the specified one, using a
+// lookup index created with CreateLookup(). Unlike a filter, it does not scan the column.
+func (txn *Txn) SeekAll(lookupName string, value interface{}) *Txn {
+	column, ok := txn.columnAt(lookupName)
+	if !ok {
+		return txn.abort(fmt.Errorf("column: lookup '%s' does not exist", lookupName))
+	}
+
+	lookup, ok := column.Column.(*columnLookup)
+	if !ok {
+		return txn.abort(fmt.Errorf("column: index '%s' is not a lookup", lookupName))
+	}
+
+	if _, isNumber := numericTypeOf(lookup.target); isNumber {
+		mutation := Set(lookupName, value)
+		converted, err := mutation.valueOf(lookup.target)
+		if err != nil {
+			return txn.abort(err)
+		}
+		value = converted
+	}
+
+	var rows bitmap.Bitmap
+	lookup.rowsOf(value, &rows)
+	txn.initialize()
+	txn.index.And(rows)
+	return txn
+}
+
 // DeleteAt attempts to delete an item at the specified index for this transaction. If the item
-// exists, it marks at as deleted and returns true, otherwise it returns false.
+// exists, it marks at as deleted and returns true, otherwise it returns false. If soft delete
+// is enabled on the collection, the item is hidden instead and can be recovered with Undelete().
 func (txn *Txn) DeleteAt(index uint32) bool {
 	txn.initialize()
 	if !txn.index.Contains(index) {
 		return false
 	}
 
+	if txn.owner.opts.SoftDelete {
+		txn.bufferFor(tombstoneColumn).PutInt64(index, txn.owner.now().UnixNano())
+		return true
+	}
+
 	txn.deleteAt(index)
 	return true
 }
 
+// Undelete recovers an item at the specified index which was previously soft-deleted and
+// not yet purged. It returns whether the item was recovered or not.
+func (txn *Txn) Undelete(index uint32) bool {
+	tombstones, ok := txn.columnAt(tombstoneColumn)
+	if !ok || !tombstones.Contains(index) {
+		return false
+	}
+
+	txn.bufferFor(tombstoneColumn).PutOperation(commit.Delete, index)
+	return true
+}
+
+// objectAt reads the values of all columns of a row into an object, except for the
+// internal ones. This must be called while the chunk of the row is locked.
+func (txn *Txn) objectAt(idx uint32) Object {
+	out := make(Object, 8)
+	txn.owner.cols.Range(func(c *column) {
+		if c.IsIndex() || txn.owner.isInternal(c.name) {
+			return
+		}
+
+		if v, ok := c.Value(idx); ok {
+			out[c.name] = v
+		}
+	})
+	return out
+}
+
 // deleteAt marks an index as deleted
 func (txn *Txn) deleteAt(idx uint32) {
 	txn.bufferFor(rowColumn).PutOperation(commit.Delete, idx)
 }
 
-// InsertObject adds an object to a collection and returns the allocated index.
+// InsertObject adds an object to a collection and returns the allocated index. If the
+// Flatten option is set, the nested maps and structs of the object are flattened first.
 func (txn *Txn) InsertObject(object Object) (uint32, error) {
 	return txn.insertObject(object, 0)
 }
@@ -323,7 +710,7 @@ func (txn *Txn) InsertObject(object Object) (uint32, error) {
 // InsertObjectWithTTL adds an object to a collection, sets the expiration time
 // based on the specified time-to-live and returns the allocated index.
 func (txn *Txn) InsertObjectWithTTL(object Object, ttl time.Duration) (uint32, error) {
-	return txn.insertObject(object, time.Now().Add(ttl).UnixNano())
+	return txn.insertObject(object, txn.owner.now().Add(ttl).UnixNano())
 }
 
 // Insert executes a mutable cursor transactionally at a new offset.
@@ -334,21 +721,206 @@ func (txn *Txn) Insert(fn func(Row) error) (uint32, error) {
 // InsertWithTTL executes a mutable cursor transactionally at a new offset and sets the expiration time
 // based on the specified time-to-live and returns the allocated index.
 func (txn *Txn) InsertWithTTL(ttl time.Duration, fn func(Row) error) (uint32, error) {
-	return txn.insert(fn, time.Now().Add(ttl).UnixNano())
+	return txn.insert(fn, txn.owner.now().Add(ttl).UnixNano())
 }
 
-// insertObject inserts all of the keys of a map, if previously registered as columns.
+// InsertAt executes a mutable cursor transactionally at the specified offset, which must
+// not be taken by another row, instead of a new offset.
+func (txn *Txn) InsertAt(index uint32, fn func(Row) error) error {
+	if !txn.owner.reserve(index) {
+		return errRowExists
+	}
+
+	txn.bufferFor(rowColumn).PutOperation(commit.Insert, index)
+	return txn.QueryAt(index, fn)
+}
+
+// insertObject inserts all of the keys of a map, if previously registered as columns. The
+// numbers are converted to the type of their column, and a value which can not be stored in
+// its column, or a primary key which is already taken, fails the insertion.
 func (txn *Txn) insertObject(object Object, expireAt int64) (uint32, error) {
+	if f := txn.owner.opts.Flatten; f != nil {
+		object = f.flatten(object, txn.isMap)
+	}
+
+	if err := txn.checkKey(object); err != nil {
+		return 0, err
+	}
+
 	return txn.insert(func(Row) error {
 		for k, v := range object {
-			if _, ok := txn.columnAt(k); ok {
-				txn.bufferFor(k).PutAny(commit.Put, txn.cursor, v)
+			column, ok := txn.columnAt(k)
+			if !ok {
+				continue
 			}
+
+			value, err := valueFor(k, column.Column, v)
+			if err != nil {
+				return txn.violated(err, FieldColumn, k)
+			}
+
+			if key, ok := value.(string); ok && column.Column == Column(txn.owner.pk) {
+				if err := txn.claimKey(key, txn.cursor); err != nil {
+					return err
+				}
+			}
+			txn.bufferFor(k).PutAny(commit.Put, txn.cursor, value)
 		}
 		return nil
 	}, expireAt)
 }
 
+// checkKey checks that the primary key of an object being inserted is not already taken,
+// before a row is allocated for it
+func (txn *Txn) checkKey(object Object) error {
+	pk := txn.owner.pk
+	if pk == nil {
+		return nil
+	}
+
+	if key, ok := object[pk.name].(string); ok && txn.keyTaken(key, noRow) {
+		return txn.duplicateKey(key)
+	}
+	return nil
+}
+
+// noRow is the index of a row which does not exist yet, and hence owns no key
+const noRow = ^uint32(0)
+
+// keyTaken returns whether the primary key is taken by a row other than the specified one,
+// either in the collection or by the pending writes of the transaction
+func (txn *Txn) keyTaken(key string, idx uint32) bool {
+	if at, ok := txn.owner.pk.OffsetOf(key); ok && at != idx {
+		return true
+	}
+
+	at, ok := txn.keys[key]
+	return ok && at != idx
+}
+
+// claimKey checks that the primary key is not taken by another row and reserves it for the
+// specified row until the transaction ends, so that the transaction can not write it twice
+func (txn *Txn) claimKey(key string, idx uint32) error {
+	if txn.keyTaken(key, idx) {
+		return txn.duplicateKey(key)
+	}
+
+	if _, ok := txn.keys[key]; !ok {
+		if txn.keys == nil {
+			txn.keys = make(map[string]uint32, 8)
+		}
+		txn.keys[key] = idx
+		txn.claimed = append(txn.claimed, key)
+	}
+	return nil
+}
+
+// releaseKeys releases the primary keys claimed by the transaction after the specified number
+func (txn *Txn) releaseKeys(from int) {
+	for _, key := range txn.claimed[from:] {
+		delete(txn.keys, key)
+	}
+	txn.claimed = txn.claimed[:from]
+}
+
+// duplicateKey returns the error for a primary key which is already taken
+func (txn *Txn) duplicateKey(key string) error {
+	return txn.violated(errorOf(ErrDuplicateKey, "column: unable to insert, key '%s' already exists", key),
+		FieldColumn, txn.owner.pk.name,
+		FieldKey, key,
+	)
+}
+
+// InsertObjects adds a batch of objects to a collection and returns their allocated indexes,
+// in the order of the objects. Rather than looking up the column and the buffer of every key
+// of every object, the batch is transposed into the values of each column once and written
+// column by column, which is considerably faster for large batches. If the Flatten option is
+// set, the nested maps and structs of the objects are flattened first.
+func (txn *Txn) InsertObjects(objects []Object) ([]uint32, error) {
+	out := make([]uint32, 0, len(objects))
+
+	// The row policy needs to be evaluated on every row, as it is inserted
+	if txn.policy != nil {
+		for _, object := range objects {
+			idx, err := txn.insertObject(object, 0)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, idx)
+		}
+		return out, nil
+	}
+
+	if f := txn.owner.opts.Flatten; f != nil {
+		flat := make([]Object, 0, len(objects))
+		for _, object := range objects {
+			flat = append(flat, f.flatten(object, txn.isMap))
+		}
+		objects = flat
+	}
+
+	for _, object := range objects {
+		if err := txn.checkKey(object); err != nil {
+			return out, err
+		}
+	}
+
+	// Transpose the objects into the values of each column
+	out = txn.owner.nextN(out, len(objects))
+	values := make(map[string][]interface{}, 8)
+	inserts := txn.bufferFor(rowColumn)
+	for i, object := range objects {
+		inserts.PutOperation(commit.Insert, out[i])
+		for k, v := range object {
+			if _, ok := values[k]; !ok {
+				values[k] = make([]interface{}, len(objects))
+			}
+			values[k][i] = v
+		}
+	}
+
+	// Write the values column by column, skipping the keys which are not columns
+	for k, column := range values {
+		target, ok := txn.columnAt(k)
+		if !ok {
+			continue
+		}
+
+		buffer := txn.bufferFor(k)
+		for i, v := range column {
+			if v == nil && !hasKey(objects[i], k) {
+				continue
+			}
+
+			value, err := valueFor(k, target.Column, v)
+			if err != nil {
+				return out, txn.violated(err, FieldColumn, k)
+			}
+
+			if key, ok := value.(string); ok && target.Column == Column(txn.owner.pk) {
+				if err := txn.claimKey(key, out[i]); err != nil {
+					return out, err
+				}
+			}
+			buffer.PutAny(commit.Put, out[i], value)
+		}
+	}
+
+	// The inserted rows are recently used for the eviction policy
+	if policy := txn.owner.opts.Eviction; policy != nil {
+		for _, idx := range out {
+			policy.Touch(idx)
+		}
+	}
+	return out, txn.failed()
+}
+
+// hasKey checks whether the object has a key, even if its value is nil
+func hasKey(object Object, key string) bool {
+	_, ok := object[key]
+	return ok
+}
+
 // insert creates an insertion cursor for a given column and expiration time.
 func (txn *Txn) insert(fn func(Row) error, expireAt int64) (uint32, error) {
 
@@ -368,17 +940,27 @@ func (txn *Txn) insert(fn func(Row) error, expireAt int64) (uint32, error) {
 	})
 }
 
-// DeleteAll marks all of the items currently selected by this transaction for deletion. The
-// actual delete will take place once the transaction is committed.
-func (txn *Txn) DeleteAll() {
+// DeleteAll marks all of the items currently selected by this transaction for deletion and
+// returns the number of rows marked. The actual delete will take place once the transaction
+// is committed, at which point the whole selection is removed in a single pass.
+func (txn *Txn) DeleteAll() int {
 	txn.initialize()
-	txn.index.Range(func(x uint32) {
-		txn.deleteAt(x)
-	})
+	if txn.owner.opts.SoftDelete {
+		now := txn.owner.now().UnixNano()
+		tombstones := txn.bufferFor(tombstoneColumn)
+		txn.index.Range(func(idx uint32) {
+			tombstones.PutInt64(idx, now)
+		})
+		return txn.index.Count()
+	}
+
+	txn.deletes.Or(txn.index)
+	return txn.index.Count()
 }
 
 // Range selects and iterates over result set. In each iteration step, the internal
-// transaction cursor is updated and can be used by various column accessors.
+// transaction cursor is updated and can be used by various column accessors. If the
+// context of the transaction is cancelled, the iteration stops and an error is returned.
 func (txn *Txn) Range(fn func(idx uint32)) error {
 	txn.initialize()
 	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
@@ -387,6 +969,100 @@ func (txn *Txn) Range(fn func(idx uint32)) error {
 			fn(offset + x)
 		})
 	})
+	return txn.failed()
+}
+
+// Rows returns an iterator over the selected rows and their indexes, which can be used in
+// a range-over-func loop. As with Range(), the chunk being iterated is read-locked while
+// the body of the loop runs, and the iteration can be stopped early with a break.
+func (txn *Txn) Rows() func(yield func(uint32, Row) bool) {
+	return func(yield func(uint32, Row) bool) {
+		row := Row{txn}
+		txn.each(func(idx uint32) bool {
+			return yield(idx, row)
+		})
+	}
+}
+
+// each iterates over the selected rows with the cursor set, until the function returns false.
+func (txn *Txn) each(fn func(idx uint32) bool) {
+	txn.initialize()
+	txn.rangeReadUntil(func(offset uint32, index bitmap.Bitmap) bool {
+		next := true
+		index.Range(func(x uint32) {
+			if next {
+				txn.cursor = offset + x
+				next = fn(offset + x)
+			}
+		})
+		return next
+	})
+}
+
+// Savepoint represents a point within a transaction to which it can be partially
+// rolled back, discarding all of the changes made after it.
+type Savepoint struct {
+	txn     *Txn          // The transaction which created the savepoint
+	marks   []commit.Mark // The marks of the update buffers
+	deletes bitmap.Bitmap // The pending bulk deletes
+	swaps   int           // The number of conditional writes
+	keys    int           // The number of primary keys claimed
+	derived int           // The number of changes to copy into the derived columns
+}
+
+// Savepoint creates a savepoint at the current state of the transaction, which can
+// later be used with RollbackTo() to discard the changes made after it.
+func (txn *Txn) Savepoint() Savepoint {
+	marks := make([]commit.Mark, 0, len(txn.updates))
+	for _, u := range txn.updates {
+		marks = append(marks, u.Mark())
+	}
+
+	return Savepoint{
+		txn:     txn,
+		marks:   marks,
+		deletes: txn.deletes.Clone(nil),
+		swaps:   len(txn.swaps),
+		keys:    len(txn.claimed),
+		derived: len(txn.derived),
+	}
+}
+
+// RollbackTo discards all of the pending updates and deletes which were made after the
+// savepoint was created, while keeping the ones made before it. The rows inserted after the
+// savepoint are released, along with their keys, and the source changes recorded since for
+// the derived columns are discarded. The quotas of the tenants are only reserved
+// once the transaction commits, hence there is nothing to release for them.
+func (txn *Txn) RollbackTo(savepoint Savepoint) error {
+	if savepoint.txn != txn || len(savepoint.marks) > len(txn.updates) {
+		return errNoSavepoint
+	}
+
+	reserved := txn.inserted()
+	for i, u := range txn.updates {
+		switch {
+		case i < len(savepoint.marks):
+			u.Truncate(savepoint.marks[i])
+		default:
+			u.Reset(u.Column)
+		}
+	}
+
+	txn.deletes.Clear()
+	txn.deletes.Or(savepoint.deletes)
+	if savepoint.swaps <= len(txn.swaps) {
+		txn.swaps = txn.swaps[:savepoint.swaps]
+	}
+	if savepoint.keys <= len(txn.claimed) {
+		txn.releaseKeys(savepoint.keys)
+	}
+	if savepoint.derived <= len(txn.derived) {
+		txn.derived = txn.derived[:savepoint.derived]
+	}
+
+	// Release the rows which were only inserted after the savepoint
+	reserved.AndNot(txn.inserted())
+	txn.releaseRows(reserved)
 	return nil
 }
 
@@ -394,15 +1070,70 @@ func (txn *Txn) Range(fn func(idx uint32)) error {
 // the pending updates/deletes. This operation can be called several times for
 // a transaction in order to perform partial rollbacks.
 func (txn *Txn) rollback() {
+	txn.releaseInserts()
+	txn.releaseQuotas()
 	txn.reset()
 }
 
+// releaseInserts releases the indexes reserved for the rows inserted by the transaction,
+// since they were added to the fill list ahead of the commit.
+func (txn *Txn) releaseInserts() {
+	txn.releaseRows(txn.inserted())
+}
+
+// inserted returns the indexes of the rows inserted by the pending insert markers
+func (txn *Txn) inserted() (rows bitmap.Bitmap) {
+	markers, ok := txn.findMarkers()
+	if !ok {
+		return
+	}
+
+	txn.reader.Seek(markers)
+	for txn.reader.Next() {
+		if txn.reader.Type == commit.Insert {
+			rows.Set(txn.reader.Index())
+		}
+	}
+	return
+}
+
+// releaseRows removes the reserved rows from the fill list
+func (txn *Txn) releaseRows(rows bitmap.Bitmap) {
+	if rows.Count() == 0 {
+		return
+	}
+
+	var released bitmap.Bitmap
+	txn.owner.lock.Lock()
+	rows.Range(func(idx uint32) {
+		if txn.owner.fill.Contains(idx) {
+			txn.owner.fill.Remove(idx)
+			atomic.AddUint64(&txn.owner.count, ^uint64(0))
+			released.Set(uint32(commit.ChunkAt(idx)))
+		}
+	})
+	txn.owner.lock.Unlock()
+
+	// The cached selections may contain the rows which were reserved
+	if cache := txn.owner.cache; cache != nil {
+		released.Range(func(chunk uint32) {
+			cache.invalidate(commit.Chunk(chunk), map[string]bool{rowColumn: true})
+		})
+	}
+}
+
 // Commit commits the transaction by applying all pending updates and deletes to
 // the collection. This operation is can be called several times for a transaction
 // in order to perform partial commits. If there's no pending updates/deletes, this
 // operation will result in a no-op.
 func (txn *Txn) commit() {
 	defer txn.reset()
+	_, span := txn.owner.trace(txn.ctx, SpanCommit)
+	txn.commitDeletes()
+	if txn.owner.opts.Versioned {
+		txn.commitVersions()
+	}
+	start, chunks, compact := time.Now(), 0, false
 
 	// Mark the dirty chunks from the updates
 	for _, u := range txn.updates {
@@ -419,16 +1150,102 @@ func (txn *Txn) commit() {
 
 	// Commit chunk by chunk to reduce lock contentions
 	txn.rangeWrite(func(commitID uint64, chunk commit.Chunk, fill bitmap.Bitmap) {
+
+		// Reconcile a replayed commit with the newer ones, before any of its changes is applied
+		if txn.replay != nil {
+			restore, ok := txn.commitReplay(chunk)
+			defer restore()
+			if !ok {
+				return
+			}
+		}
+
+		// If the rows are soft-deleted, count the tombstones of the chunk again once committed
+		if txn.owner.opts.SoftDelete {
+			defer txn.commitTombstones(chunk)()
+		}
+
+		// Convert the bulk deletes of the chunk into delete markers, only if the changes of
+		// the rows are observed one by one
+		deleted := hasValues(chunk, txn.deletes)
+		deletes := txn.deleteMarkers(chunk, deleted)
+		if deletes != nil {
+			defer txn.releaseMarkers(deletes)
+		}
+
+		// If the changes are written behind to a backend, track the keys of the rows changed
+		// before the deleted rows lose their keys
+		if b := txn.owner.backend; b != nil && !txn.loaded {
+			b.track(txn, chunk)
+		}
+
 		if changedRows {
 			txn.commitMarkers(chunk, fill, markers)
 		}
+		if deleted {
+			txn.commitBulkDeletes(chunk, deletes)
+		}
+
+		// Resolve the merges against the values of the chunk, while it is locked
+		defer txn.commitMerges(chunk)()
+		if txn.expiring != 0 {
+			defer txn.commitExpiry(chunk)()
+		}
 
 		// Attemp to update, if nothing was changed we're done
 		updated := txn.commitUpdates(chunk)
-		if !changedRows && !updated {
+		if !changedRows && !updated && !deleted {
 			return
 		}
 
+		chunks++
+
+		// If the audit is enabled, record every change of the chunk
+		if audit := txn.owner.opts.Audit; audit != nil {
+			txn.commitAudit(audit, commitID, chunk)
+		}
+
+		// If the query cache is enabled, invalidate the selections of the chunk
+		if cache := txn.owner.cache; cache != nil {
+			txn.commitCache(cache, chunk)
+		}
+
+		// If the conflicts of the replayed commits are resolved, record the versions of the values
+		if versions := txn.owner.versions; versions != nil {
+			if txn.replay != nil {
+				commitID = txn.replay.id
+			}
+			versions.record(txn, commitID, chunk)
+		}
+
+		// If some columns have a time-to-live, set the deadlines of the values written
+		if txn.owner.ttls.tracked() {
+			txn.owner.ttls.commit(txn, chunk)
+		}
+
+		// If some counters are maintained, count the rows changed again
+		if txn.owner.counters.tracked() {
+			txn.owner.counters.commit(txn, chunk)
+		}
+
+		// If the checksums are enabled, hash the columns changed again
+		if sums := txn.owner.checksums; sums != nil {
+			sums.commit(txn, chunk)
+		}
+
+		// If the statistics of some columns are maintained, mark the chunk as stale
+		if txn.owner.colstats.tracked() {
+			txn.owner.colstats.invalidate(chunk, txn.changedColumns(chunk))
+		}
+		if txn.owner.histograms.tracked() {
+			txn.owner.histograms.invalidate(chunk, txn.changedColumns(chunk))
+		}
+
+		// If the eviction is enabled, keep track of the rows changed
+		if policy := txn.owner.opts.Eviction; policy != nil {
+			txn.commitEviction(policy, chunk)
+		}
+
 		// If there is a pending snapshot, append commit into a temp log
 		if dst, ok := txn.owner.isSnapshotting(); ok {
 			dst.Append(commit.Commit{
@@ -444,8 +1261,51 @@ func (txn *Txn) commit() {
 				Chunk:   chunk,
 				Updates: txn.updates,
 			})
+			txn.owner.checkpoint.commit()
+		}
+
+		// Deliver the commit to the writers which were added, in the order of their priority
+		for _, w := range txn.owner.outputs {
+			w.append(commit.Commit{
+				ID:      commitID,
+				Chunk:   chunk,
+				Updates: txn.updates,
+			})
+		}
+
+		// If there are filtered replicas, ship them their subset of the changes
+		for _, r := range txn.owner.replicas {
+			r.ship(txn, commitID, chunk)
+		}
+
+		// If there are sliding windows, count the rows which entered or left them
+		for _, w := range txn.owner.windows {
+			w.update(txn, chunk)
+		}
+
+		// If the history is retained, append the commit to it
+		if txn.owner.history != nil {
+			compact = txn.owner.history.Append(txn.owner, commit.Commit{
+				ID:      commitID,
+				Chunk:   chunk,
+				Updates: txn.updates,
+			}) || compact
 		}
 	})
+
+	// Apply the commits which went out of the history, once the chunks are unlocked
+	if compact {
+		if err := txn.owner.history.Compact(txn.owner); err != nil {
+			txn.owner.log(LevelError, EventHistory, "history failed to compact", err)
+		}
+	}
+
+	txn.owner.observe(func(o Observer) {
+		o.ObserveCommit(time.Since(start), chunks)
+	})
+
+	span.SetAttribute(AttrChunks, chunks)
+	span.End(nil)
 }
 
 // commitUpdates applies the pending updates to the collection.
@@ -461,6 +1321,11 @@ func (txn *Txn) commitUpdates(chunk commit.Chunk) (updated bool) {
 			continue
 		}
 
+		// When bulk loading, the indexes are built once all of the rows are loaded
+		if txn.bulk {
+			columns = columns[:1]
+		}
+
 		// Do a linear search to find the offset for the current chunk
 		updated = true
 		txn.reader.Range(u, chunk, func(r *commit.Reader) {
@@ -470,25 +1335,178 @@ func (txn *Txn) commitUpdates(chunk commit.Chunk) (updated bool) {
 			for _, v := range columns {
 				v.Apply(r)
 			}
+
+			// Widen the bounds of the chunk with the values applied
+			if zones := columns[0].zones; zones != nil {
+				r.Rewind()
+				zones.widen(chunk, columns[0].Column.(Numeric), r)
+			}
 		})
 	}
 	return updated
 }
 
+// commitDeletes marks the chunks of the pending bulk deletes as dirty. The deletes are
+// applied chunk by chunk as a bitmap, rather than as a delete marker for every row.
+func (txn *Txn) commitDeletes() {
+	if len(txn.deletes) == 0 {
+		return
+	}
+
+	last := commit.ChunkAt(uint32(len(txn.deletes)*64 - 1))
+	for chunk := commit.Chunk(0); chunk <= last; chunk++ {
+		if hasValues(chunk, txn.deletes) {
+			txn.dirty.Set(uint32(chunk))
+		}
+	}
+}
+
+// observesRows returns whether some feature of the collection reads the changes of the
+// rows one by one as they are committed, and hence needs the bulk deletes as markers. The
+// features register themselves with the observers of the rows once they are enabled, only
+// the replays and the writes to the backend depend on the transaction.
+func (txn *Txn) observesRows() bool {
+	return txn.owner.observers.any() || txn.replay != nil ||
+		(txn.owner.backend != nil && !txn.loaded)
+}
+
+// rowObservers counts the features which read the changes of the rows one by one as they
+// are committed, such as the commit log, the history or the counters of the columns.
+type rowObservers struct {
+	count int32 // The number of features registered (atomic)
+}
+
+// add registers a number of features which read the rows, or unregisters them if negative
+func (o *rowObservers) add(n int) {
+	atomic.AddInt32(&o.count, int32(n))
+}
+
+// any returns whether some feature reads the rows
+func (o *rowObservers) any() bool {
+	return atomic.LoadInt32(&o.count) > 0
+}
+
+// track sets the number of entries of a set which reads the rows, such as the columns with a
+// time-to-live, and registers the difference. This must be called while the set is locked.
+func (o *rowObservers) track(count *int32, n int) {
+	o.add(n - int(atomic.SwapInt32(count, int32(n))))
+}
+
+// deleteMarkers writes the bulk deletes of the chunk as delete markers into a separate
+// buffer of the transaction, if the changes of the rows are observed.
+func (txn *Txn) deleteMarkers(chunk commit.Chunk, deleted bool) *commit.Buffer {
+	if !deleted || !txn.observesRows() {
+		return nil
+	}
+
+	markers := txn.owner.txns.acquirePage(rowColumn)
+	chunk.Range(txn.deletes, func(idx uint32) {
+		markers.PutOperation(commit.Delete, idx)
+	})
+
+	txn.updates = append(txn.updates, markers)
+	return markers
+}
+
+// releaseMarkers removes the delete markers of a chunk from the transaction
+func (txn *Txn) releaseMarkers(markers *commit.Buffer) {
+	for i, u := range txn.updates {
+		if u == markers {
+			txn.updates = append(txn.updates[:i], txn.updates[i+1:]...)
+			break
+		}
+	}
+	txn.owner.txns.releasePage(markers)
+}
+
+// commitBulkDeletes applies the bulk deletes of the chunk to the fill list and to the
+// columns as a bitmap. The columns which keep a lookup of their values still need to
+// remove every row from it, so the delete markers are applied to them instead.
+func (txn *Txn) commitBulkDeletes(chunk commit.Chunk, markers *commit.Buffer) {
+	rows := chunk.OfBitmap(txn.deletes)
+	txn.owner.lock.Lock()
+	andNot(chunk.OfBitmap(txn.owner.fill), rows)
+	txn.owner.persistRows(chunk)
+	atomic.StoreUint64(&txn.owner.count, uint64(txn.owner.fill.Count()))
+	txn.owner.lock.Unlock()
+
+	var lookups []*column
+	txn.owner.cols.Range(func(v *column) {
+		switch v.Column.(type) {
+		case *columnKey, *columnLookup, *columnDictionary, *columnIP, *columnEnum:
+			lookups = append(lookups, v)
+		default:
+			v.lock.RLock()
+			if index := v.Index(); index != nil {
+				andNot(chunk.OfBitmap(*index), rows)
+			}
+			v.lock.RUnlock()
+		}
+	})
+
+	if len(lookups) == 0 {
+		return
+	}
+
+	if markers == nil {
+		markers = txn.owner.txns.acquirePage(rowColumn)
+		defer txn.owner.txns.releasePage(markers)
+		chunk.Range(txn.deletes, func(idx uint32) {
+			markers.PutOperation(commit.Delete, idx)
+		})
+	}
+
+	txn.reader.Range(markers, chunk, func(r *commit.Reader) {
+		for _, v := range lookups {
+			v.Apply(r)
+		}
+	})
+}
+
+// commitTombstones counts the soft-deleted rows of the chunk before it is committed, and
+// returns a function which adds the difference to the count of the collection once it is.
+// This must be called while the chunk is locked.
+func (txn *Txn) commitTombstones(chunk commit.Chunk) func() {
+	column, ok := txn.owner.cols.Load(tombstoneColumn)
+	if !ok {
+		return func() {}
+	}
+
+	before := tombstonesIn(column, chunk)
+	return func() {
+		atomic.AddInt64(&txn.owner.tombstones, int64(tombstonesIn(column, chunk)-before))
+	}
+}
+
+// tombstonesIn returns the number of soft-deleted rows of the chunk
+func tombstonesIn(column *column, chunk commit.Chunk) int {
+	column.lock.RLock()
+	defer column.lock.RUnlock()
+	return chunk.OfBitmap(*column.Index()).Count()
+}
+
+// andNot clears the bits of the rows from the words of a chunk
+func andNot(dst, rows bitmap.Bitmap) {
+	for i := 0; i < len(dst) && i < len(rows); i++ {
+		dst[i] &^= rows[i]
+	}
+}
+
 // commitMarkers commits inserts and deletes to the collection.
 func (txn *Txn) commitMarkers(chunk commit.Chunk, fill bitmap.Bitmap, buffer *commit.Buffer) {
+	txn.owner.lock.Lock()
 	txn.reader.Range(buffer, chunk, func(r *commit.Reader) {
 		for r.Next() {
-			txn.owner.lock.Lock()
 			switch r.Type {
 			case commit.Insert:
 				txn.owner.fill.Set(r.Index())
 			case commit.Delete:
 				txn.owner.fill.Remove(r.Index())
 			}
-			txn.owner.lock.Unlock()
 		}
 	})
+	txn.owner.persistRows(chunk)
+	txn.owner.lock.Unlock()
 
 	// We also need to apply the delete operations on the column so it
 	// can remove unnecessary data.
@@ -526,6 +1544,16 @@ func (txn *Txn) commitCapacity(last commit.Chunk) {
 		txn.owner.commits = append(txn.owner.commits, 0)
 	}
 
+	// Keep track of the access time of the chunks, if the cold ones are spilled
+	for now := time.Now().UnixNano(); txn.owner.opts.SpillAfter > 0 && len(txn.owner.touched) < int(last+1); {
+		txn.owner.touched = append(txn.owner.touched, now)
+	}
+
+	// Grow the versions of the chunks published for the shared readers, as reserved by prepare()
+	if txn.owner.shared != nil {
+		txn.owner.growShared(last)
+	}
+
 	// Grow the fill list and all of the owner's columns
 	max := last.Max()
 	txn.owner.fill.Grow(max)
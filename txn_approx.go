@@ -0,0 +1,102 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// CountEstimate represents the estimated number of rows matching a query, along with the
+// bounds of its 95% confidence interval.
+type CountEstimate struct {
+	Count int  // The estimated number of rows
+	Lower int  // The lower bound of the confidence interval
+	Upper int  // The upper bound of the confidence interval
+	Exact bool // Whether every row was scanned, and the count is exact
+}
+
+// CountApprox estimates the number of objects matching the query within a time budget. The
+// pending filters are applied to the chunks of the selection in a random order until the
+// budget is exhausted, and the count of the rows matched is extrapolated to the rows which
+// were not scanned. At least one row is always scanned, and the estimate is exact if all
+// of them were. The filters remain pending, so the selection can still be counted exactly.
+func (txn *Txn) CountApprox(budget time.Duration) CountEstimate {
+	filters := txn.filters
+	txn.filters = nil
+	txn.initialize()
+	if len(filters) == 0 {
+		count := txn.index.Count()
+		return CountEstimate{Count: count, Lower: count, Upper: count, Exact: true}
+	}
+
+	// Restore the selection and the filters, once done with the chunks
+	full := txn.index
+	defer func() {
+		txn.index = full
+		txn.filters = filters
+	}()
+
+	total := full.Count()
+	limit := len(full) >> bitmapShift
+	chunks := rand.Perm(limit + 1)
+	deadline := time.Now().Add(budget)
+	pending := make([]filter, len(filters))
+	sample := make(bitmap.Bitmap, len(full))
+
+	scanned, matched := 0, 0
+	for _, chunk := range chunks {
+		if scanned > 0 && time.Now().After(deadline) || txn.cancelled() {
+			break
+		}
+
+		// Select only the rows of the chunk, then apply the filters on them
+		lo := commit.Chunk(chunk).Min() >> 6
+		hi := lo + chunkSize>>6
+		if hi > uint32(len(full)) {
+			hi = uint32(len(full))
+		}
+
+		copy(sample[lo:hi], full[lo:hi])
+		copy(pending, filters)
+		txn.index = sample
+		txn.filters = pending
+		txn.applyFilters()
+
+		scanned += full[lo:hi].Count()
+		matched += txn.index.Count()
+		for j := lo; j < hi; j++ {
+			sample[j] = 0
+		}
+	}
+
+	return estimateOf(matched, scanned, total)
+}
+
+// estimateOf extrapolates the number of rows matched among the rows scanned to all of the
+// rows, with the normal approximation of the confidence interval of the fraction matched.
+func estimateOf(matched, scanned, total int) CountEstimate {
+	switch {
+	case scanned >= total:
+		return CountEstimate{Count: matched, Lower: matched, Upper: matched, Exact: true}
+	case scanned == 0:
+		return CountEstimate{Upper: total}
+	}
+
+	p := float64(matched) / float64(scanned)
+	margin := 1.96 * math.Sqrt(p*(1-p)/float64(scanned)) *
+		math.Sqrt(float64(total-scanned)/float64(total-1))
+
+	// The rows scanned are known, only the others can be matched or not
+	unknown := total - scanned
+	return CountEstimate{
+		Count: int(math.Round(p * float64(total))),
+		Lower: int(math.Max(math.Floor((p-margin)*float64(total)), float64(matched))),
+		Upper: int(math.Min(math.Ceil((p+margin)*float64(total)), float64(matched+unknown))),
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+)
+
+// ColumnSlice represents the values of a column for a batch of rows. The values are
+// loaded into a contiguous slice on first access, where the value at position i belongs
+// to the i-th row of the batch. Rows without a value are represented by a zero value.
+// The slices are reused between batches and must not be retained by the callback.
+type ColumnSlice struct {
+	*columnBatch
+}
+
+// columnBatch represents the reusable state of a column slice.
+type columnBatch struct {
+	name    string    // The name of the column
+	column  Column    // The column to load the values from
+	idxs    []uint32  // The rows of the current batch
+	floats  []float64 // The values loaded as float64
+	ints    []int64   // The values loaded as int64
+	uints   []uint64  // The values loaded as uint64
+	strings []string  // The values loaded as string
+	loaded  uint8     // The bit set of the slices loaded for the current batch
+}
+
+const (
+	loadedFloats = 1 << iota
+	loadedInts
+	loadedUints
+	loadedStrings
+)
+
+// Name returns the name of the column.
+func (s ColumnSlice) Name() string {
+	return s.name
+}
+
+// Float64s returns the values of the batch as float64. The column must be numeric.
+func (s ColumnSlice) Float64s() []float64 {
+	if s.loaded&loadedFloats == 0 {
+		s.loaded |= loadedFloats
+		s.floats = s.floats[:0]
+		column, _ := s.column.(Numeric)
+		for _, idx := range s.idxs {
+			var v float64
+			if column != nil {
+				v, _ = column.LoadFloat64(idx)
+			}
+			s.floats = append(s.floats, v)
+		}
+	}
+	return s.floats
+}
+
+// Int64s returns the values of the batch as int64. The column must be numeric.
+func (s ColumnSlice) Int64s() []int64 {
+	if s.loaded&loadedInts == 0 {
+		s.loaded |= loadedInts
+		s.ints = s.ints[:0]
+		column, _ := s.column.(Numeric)
+		for _, idx := range s.idxs {
+			var v int64
+			if column != nil {
+				v, _ = column.LoadInt64(idx)
+			}
+			s.ints = append(s.ints, v)
+		}
+	}
+	return s.ints
+}
+
+// Uint64s returns the values of the batch as uint64. The column must be numeric.
+func (s ColumnSlice) Uint64s() []uint64 {
+	if s.loaded&loadedUints == 0 {
+		s.loaded |= loadedUints
+		s.uints = s.uints[:0]
+		column, _ := s.column.(Numeric)
+		for _, idx := range s.idxs {
+			var v uint64
+			if column != nil {
+				v, _ = column.LoadUint64(idx)
+			}
+			s.uints = append(s.uints, v)
+		}
+	}
+	return s.uints
+}
+
+// Strings returns the values of the batch as string. The column must be textual.
+func (s ColumnSlice) Strings() []string {
+	if s.loaded&loadedStrings == 0 {
+		s.loaded |= loadedStrings
+		s.strings = s.strings[:0]
+		column, _ := s.column.(Textual)
+		for _, idx := range s.idxs {
+			var v string
+			if column != nil {
+				v, _ = column.LoadString(idx)
+			}
+			s.strings = append(s.strings, v)
+		}
+	}
+	return s.strings
+}
+
+// RangeBatch selects and iterates over the result set in batches, one for every chunk
+// of the collection. For each batch, the function receives the indices of the rows
+// along with the values of the requested columns, which amortizes the cost of the
+// iteration for analytical workloads. If the context of the transaction is cancelled,
+// the iteration stops and an error is returned.
+func (txn *Txn) RangeBatch(fn func(idxs []uint32, cols ...ColumnSlice), columns ...string) error {
+	cols := make([]ColumnSlice, 0, len(columns))
+	for _, columnName := range columns {
+		column, ok := txn.columnAt(columnName)
+		if !ok {
+			return errorOf(ErrColumnNotFound, "column: column '%s' does not exist", columnName)
+		}
+
+		cols = append(cols, ColumnSlice{&columnBatch{
+			name:   columnName,
+			column: column.Column,
+		}})
+	}
+
+	// The indices and the values are held for the largest batch, each value in up to 16 bytes
+	idxs := make([]uint32, 0, 64)
+	held := 0
+	txn.initialize()
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		idxs = idxs[:0]
+		index.Range(func(x uint32) {
+			idxs = append(idxs, offset+x)
+		})
+
+		if len(idxs) == 0 {
+			return
+		}
+
+		if size := len(idxs); size > held {
+			if !txn.allocate((size - held) * (4 + 16*len(cols))) {
+				return
+			}
+			held = size
+		}
+
+		for _, c := range cols {
+			c.idxs = idxs
+			c.loaded = 0
+		}
+		fn(idxs, cols...)
+	})
+	return txn.failed()
+}
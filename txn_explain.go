@@ -0,0 +1,115 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Plan represents the execution plan of a query, as a sequence of filter steps.
+type Plan struct {
+	Steps  []PlanStep // The filter steps, in their order of execution
+	Cached bool       // Whether the order of the filters was planned before for their shape
+	Pushed bool       // Whether an aggregate was computed from the bitmaps, without the filter steps
+}
+
+// PlanStep represents a single filter step of a query plan.
+type PlanStep struct {
+	Operation string        // The filter operation, such as "With" or "WithFloat"
+	Column    string        // The column or the index used by the step
+	Indexed   bool          // Whether the step used a bitmap rather than scanning values
+	Input     int           // The number of rows selected before the step
+	Estimated int           // The estimated number of rows selected after the step
+	Actual    int           // The actual number of rows selected after the step
+	Duration  time.Duration // The time spent executing the step
+}
+
+// String returns a human-readable representation of the plan.
+func (p *Plan) String() string {
+	var sb strings.Builder
+	for i, s := range p.Steps {
+		access := "scan"
+		if s.Indexed {
+			access = "bitmap"
+		}
+
+		fmt.Fprintf(&sb, "%d. %s(%s) %s: %d rows -> %d rows (estimated %d) in %v\n",
+			i+1, s.Operation, s.Column, access, s.Input, s.Actual, s.Estimated, s.Duration)
+	}
+	return sb.String()
+}
+
+// Explain starts recording the execution plan of the transaction. Every filter applied
+// afterwards adds a step to the returned plan, describing whether an index was used, the
+// estimated and actual number of rows selected and the time spent. Recording a plan adds
+// some overhead and should only be used for diagnostics.
+func (txn *Txn) Explain() *Plan {
+	if txn.plan == nil {
+		txn.plan = &Plan{
+			Steps: make([]PlanStep, 0, 4),
+		}
+	}
+	return txn.plan
+}
+
+// trace records a step of the plan, if the plan is being recorded. This must be called
+// once the transaction is initialized, and the returned function once the step is done.
+func (txn *Txn) trace(operation, columnName string, indexed bool) func() {
+	if txn.plan == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	input := int(txn.index.Count())
+	estimate := txn.estimate(columnName, input)
+	return func() {
+		txn.plan.Steps = append(txn.plan.Steps, PlanStep{
+			Operation: operation,
+			Column:    columnName,
+			Indexed:   indexed,
+			Input:     input,
+			Estimated: estimate,
+			Actual:    int(txn.index.Count()),
+			Duration:  time.Since(start),
+		})
+	}
+}
+
+// estimate estimates the number of rows selected once a filter on the column is applied,
+// assuming that the values of the column are independent of the current selection.
+func (txn *Txn) estimate(columnName string, input int) int {
+	total := int(atomic.LoadUint64(&txn.owner.count))
+	column, ok := txn.columnAt(columnName)
+	if !ok || total == 0 {
+		return 0
+	}
+
+	selectivity := float64(txn.cardinality(column)) / float64(total)
+	if selectivity > 1 {
+		selectivity = 1
+	}
+	return int(float64(input) * selectivity)
+}
+
+// cardinality counts the number of rows which have a value in the column, or which
+// are contained in the index, chunk by chunk.
+func (txn *Txn) cardinality(column *column) (count int) {
+	txn.owner.lock.RLock()
+	other := *column.Index()
+	txn.owner.lock.RUnlock()
+
+	lock := txn.owner.slock
+	limit := commit.Chunk(len(other) >> bitmapShift)
+	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
+		lock.RLock(uint(chunk))
+		count += chunk.OfBitmap(other).Count()
+		lock.RUnlock(uint(chunk))
+	}
+	return
+}
@@ -0,0 +1,292 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+)
+
+// filterKind represents a kind of filter which can be applied to a selection
+type filterKind uint8
+
+// Various kinds of filters, all of them commute with one another
+const (
+	filterWith filterKind = iota
+	filterWithout
+	filterValue
+	filterFloat
+	filterInt
+	filterUint
+	filterString
+	filterGreater
+	filterLess
+	filterBetween
+	filterEqual
+	filterBitmap
+	filterFold
+	filterUnion // Unions are applied eagerly and only recorded
+	filterMapKey
+	filterCode
+	filterCIDR
+	filterInt128
+	filterAnd
+	filterAndNot
+	filterOr // Unions with a selection are applied eagerly and only recorded
+	filterExpr
+)
+
+// filterNames are the names of the filters, by their kind
+var filterNames = [...]string{"With", "Without", "WithValue", "WithFloat", "WithInt", "WithUint", "WithString",
+	"WithFloatGreater", "WithFloatLess", "WithFloatBetween", "WithStringEqual", "WithBitmap",
+	"WithStringFold", "Union", "WithMapKey", "WithEnumCode", "WithinCIDR",
+	"WithInt128Between", "And", "AndNot", "Or", "WithExpr"}
+
+const (
+	costBitmap = 1  // The relative cost of intersecting 64 rows with a bitmap
+	costScan   = 64 // The relative cost of evaluating a predicate on 64 rows
+)
+
+// filter represents a pending filter of the transaction. Filters are not applied when
+// chained, but only once the selection is needed, so that they can be reordered based
+// on their estimated cost.
+type filter struct {
+	kind      filterKind  // The kind of the filter
+	column    string      // The column or the index to filter on
+	predicate interface{} // The predicate function, for scans
+	lo, hi    float64     // The inclusive bounds, for range scans
+	rank      float64     // The rank of the filter, lower ranks are applied first
+}
+
+// String returns the filter as it was called, for example "WithFloat(age)".
+func (f filter) String() string {
+	return filterNames[f.kind] + "(" + f.column + ")"
+}
+
+// indexed returns whether the filter uses a bitmap rather than scanning values.
+func (f *filter) indexed() bool {
+	switch f.kind {
+	case filterWith, filterWithout, filterBitmap, filterUnion, filterAnd, filterAndNot, filterOr:
+		return true
+	default:
+		return false
+	}
+}
+
+// filter queues a filter to be applied to the selection
+func (txn *Txn) filter(kind filterKind, columnName string, predicate interface{}) {
+	txn.cached = nil
+	txn.filters = append(txn.filters, filter{
+		kind:      kind,
+		column:    columnName,
+		predicate: predicate,
+	})
+}
+
+// filterRange queues a range filter to be applied to the selection
+func (txn *Txn) filterRange(kind filterKind, columnName string, lo, hi float64) {
+	txn.cached = nil
+	txn.filters = append(txn.filters, filter{
+		kind:   kind,
+		column: columnName,
+		lo:     lo,
+		hi:     hi,
+	})
+}
+
+// applyFilters applies the pending filters to the selection. When multiple filters are
+// chained, they are applied in the order of increasing cost per row eliminated, so that
+// the cheapest and the most selective filters run first and the scans only need to look
+// at what remains of the selection.
+func (txn *Txn) applyFilters() {
+	filters := txn.filters
+	defer func() { txn.filters = filters[:0] }()
+	if len(filters) == 1 {
+		input := txn.index.Count()
+		txn.applyFilter(&filters[0])
+		txn.owner.queries.filter(&filters[0], uint64(input), uint64(txn.index.Count()))
+		txn.owner.advisor.observe(txn.owner, &filters[0], uint64(input))
+		return
+	}
+
+	// Insertion sort keeps the chained order for equal ranks and does not allocate
+	if !txn.hints.inOrder {
+		txn.planFilters(filters)
+
+		for i := 1; i < len(filters); i++ {
+			for j := i; j > 0 && filters[j].rank < filters[j-1].rank; j-- {
+				filters[j], filters[j-1] = filters[j-1], filters[j]
+			}
+		}
+	}
+
+	for i := range filters {
+		input := txn.index.Count()
+		txn.applyFilter(&filters[i])
+		output := txn.index.Count()
+		txn.owner.queries.filter(&filters[i], uint64(input), uint64(output))
+		txn.owner.advisor.observe(txn.owner, &filters[i], uint64(input))
+		if input > 0 && txn.owner.stats.observe(statKey{filters[i].kind, filters[i].column},
+			float64(output)/float64(input)) {
+			txn.owner.plans.invalidate()
+		}
+	}
+}
+
+// applyFilter applies a single filter to the selection
+func (txn *Txn) applyFilter(f *filter) {
+	txn.record(f.kind, f.column)
+	switch f.kind {
+	case filterWith:
+		txn.with(f.column)
+	case filterWithout:
+		txn.without(f.column)
+	case filterValue:
+		txn.withValue(f.column, f.predicate.(func(v interface{}) bool))
+	case filterFloat:
+		txn.withFloat(f.column, f.predicate.(func(v float64) bool))
+	case filterInt:
+		txn.withInt(f.column, f.predicate.(func(v int64) bool))
+	case filterUint:
+		txn.withUint(f.column, f.predicate.(func(v uint64) bool))
+	case filterString:
+		txn.withString(f.column, f.predicate.(func(v string) bool))
+	case filterGreater, filterLess, filterBetween:
+		txn.withRange(f.kind, f.column, f.lo, f.hi)
+	case filterEqual, filterFold:
+		txn.withStringEqual(f.kind, f.column, f.predicate.(string))
+	case filterBitmap, filterAnd:
+		txn.withBitmap(f.predicate.(bitmap.Bitmap))
+	case filterAndNot:
+		txn.withoutBitmap(f.predicate.(bitmap.Bitmap))
+	case filterMapKey:
+		txn.withMapKey(f.column, f.predicate.(mapPredicate))
+	case filterCode:
+		txn.withEnumCode(f.column, uint32(f.lo))
+	case filterCIDR:
+		txn.withinCIDR(f.column, f.predicate.(ipNetwork))
+	case filterInt128:
+		txn.withInt128(f.column, f.predicate.(int128Range))
+	case filterExpr:
+		txn.withExpr(f.predicate.(*Expression))
+	}
+}
+
+// record records the filter applied, if slow queries are reported or traced
+func (txn *Txn) record(kind filterKind, columnName string) {
+	if txn.owner.measured() {
+		txn.applied = append(txn.applied, filter{
+			kind:   kind,
+			column: columnName,
+		})
+	}
+}
+
+// rankOf computes the rank of a filter, which is its cost divided by the fraction of the
+// selection it is expected to eliminate.
+func (txn *Txn) rankOf(f *filter) float64 {
+	selectivity := txn.selectivityOf(f)
+	if selectivity >= 1 {
+		return math.Inf(1)
+	}
+
+	cost := float64(costScan)
+	if f.indexed() {
+		cost = costBitmap
+	}
+	return cost / (1 - selectivity)
+}
+
+// selectivityOf returns the expected fraction of the selection retained by the filter. If
+// the filter was not observed before, the fraction of rows which have a value in the
+// column is used instead, since no predicate can retain more than that. The ranges of the
+// columns with a histogram are estimated from it instead, given their bounds.
+func (txn *Txn) selectivityOf(f *filter) float64 {
+	// Estimate the fraction of a range from the histogram of its column, if one was built
+	switch f.kind {
+	case filterGreater, filterLess, filterBetween:
+		total := atomic.LoadUint64(&txn.owner.count)
+		if h, ok := txn.owner.histograms.cached(f.column); ok && total > 0 {
+			return math.Min(h.Estimate(f.lo, f.hi)/float64(total), 1)
+		}
+	}
+
+	key := statKey{f.kind, f.column}
+	if selectivity, ok := txn.owner.stats.load(key); ok {
+		return selectivity
+	}
+
+	column, ok := txn.columnAt(f.column)
+	if !ok {
+		if f.kind == filterWithout {
+			return 1
+		}
+		return 0
+	}
+
+	selectivity := 1.0
+	if total := atomic.LoadUint64(&txn.owner.count); total > 0 {
+		selectivity = math.Min(float64(txn.cardinality(column))/float64(total), 1)
+	}
+
+	if f.kind == filterWithout {
+		selectivity = 1 - selectivity
+	}
+
+	if txn.owner.stats.observe(key, selectivity) {
+		txn.owner.plans.invalidate()
+	}
+	return selectivity
+}
+
+// --------------------------- Statistics ----------------------------
+
+// statKey represents the key of a filter in the statistics
+type statKey struct {
+	kind   filterKind
+	column string
+}
+
+// statistics represents the selectivity statistics of the filters applied to a collection,
+// as a moving average of the fraction of the selection each filter retains.
+type statistics struct {
+	lock  sync.RWMutex
+	rates map[statKey]float64
+	basis map[statKey]float64 // The rates the query plans were last made with
+}
+
+// load loads the selectivity of a filter, if it was observed before
+func (s *statistics) load(key statKey) (float64, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	rate, ok := s.rates[key]
+	return rate, ok
+}
+
+// observe records the selectivity of a filter which was just applied, and returns whether
+// it drifted from the selectivity the query plans were made with.
+func (s *statistics) observe(key statKey, rate float64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rates == nil {
+		s.rates = make(map[statKey]float64, 8)
+		s.basis = make(map[statKey]float64, 8)
+	}
+
+	if prev, ok := s.rates[key]; ok {
+		rate = prev + (rate-prev)/4
+	}
+	s.rates[key] = rate
+
+	// Only a significant change makes the plans stale, not every update of the average
+	if basis, ok := s.basis[key]; ok && math.Abs(rate-basis) <= planDrift {
+		return false
+	}
+
+	s.basis[key] = rate
+	return true
+}
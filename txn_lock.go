@@ -4,6 +4,10 @@
 package column
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
 )
@@ -13,49 +17,188 @@ const (
 	bitmapSize  = 1 << bitmapShift
 	chunkShift  = 14 // 16K
 	chunkSize   = 1 << chunkShift
+	lockShards  = 128 // The number of shards of the chunk locks
 )
 
 // initialize ensures that the transaction is pre-initialized with the snapshot
-// of the owner's fill list.
+// of the owner's fill list and that all of the pending filters are applied.
 func (txn *Txn) initialize() {
-	if txn.setup {
-		return
+	if !txn.setup {
+		txn.admit()
+	}
+	if !txn.setup {
+		txn.setupIndex()
+	}
+
+	if len(txn.filters) > 0 {
+		txn.applyFilters()
 	}
+}
 
+// setupIndex initializes the selection with the owner's fill list.
+func (txn *Txn) setupIndex() {
 	txn.owner.lock.RLock()
 	txn.index.Grow(uint32(txn.owner.opts.Capacity))
 	txn.owner.fill.Clone(&txn.index)
 	txn.owner.lock.RUnlock()
 	txn.setup = true
+
+	// If soft delete is enabled, hide all of the rows which were deleted
+	if txn.owner.opts.SoftDelete && !txn.tombstones {
+		if tombstones, ok := txn.columnAt(tombstoneColumn); ok {
+			txn.rangeReadPair(tombstones, func(dst, src bitmap.Bitmap) {
+				dst.AndNot(src)
+			})
+		}
+	}
+
+	// If a row policy is enforced, hide all of the rows which are not accessible
+	if txn.policy != nil {
+		txn.applyPolicy()
+	}
+}
+
+// cancelled checks whether the transaction was cancelled or aborted. If it was, the
+// selection is cleared so that a partially filtered result is never observed.
+func (txn *Txn) cancelled() bool {
+	if txn.err == nil && txn.ctx.Err() == nil {
+		return false
+	}
+
+	txn.index.Clear()
+	return true
+}
+
+// failed returns the error which has aborted the transaction, if any.
+func (txn *Txn) failed() error {
+	if txn.err != nil {
+		return txn.err
+	}
+	return txn.ctx.Err()
+}
+
+// rlock acquires a read lock for a chunk, waiting at most for the lock timeout configured
+// for the collection. If the lock could not be acquired in time, the transaction is aborted.
+func (txn *Txn) rlock(chunk commit.Chunk) bool {
+	if txn.unlocked {
+		return true
+	}
+
+	if observer := txn.owner.opts.Observer; observer != nil {
+		start := time.Now()
+		defer func() {
+			observer.ObserveLockWait(time.Since(start))
+		}()
+	}
+
+	lock := txn.owner.slock
+	timeout := txn.owner.opts.LockTimeout
+	txn.queue(chunk)
+	defer txn.dequeue(chunk)
+	txn.owner.fetch(uint(chunk))
+	switch {
+	case timeout <= 0:
+		lock.RLock(uint(chunk))
+	case !acquire(time.Now().Add(timeout), func() bool { return lock.TryRLock(uint(chunk)) }):
+		txn.err = ErrLockTimeout
+		return false
+	}
+
+	txn.owner.touch(chunk)
+	return true
+}
+
+// runlock releases the read lock for a chunk, acquired with rlock().
+func (txn *Txn) runlock(chunk commit.Chunk) {
+	if !txn.unlocked {
+		txn.owner.slock.RUnlock(uint(chunk))
+	}
+}
+
+// withTimeout returns a context which is cancelled once the transaction timeout configured
+// for the collection elapses.
+func (c *Collection) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.opts.Timeout)
 }
 
 // --------------------------- Locked Seek ---------------------------
 
 // QueryAt jumps at a particular offset in the collection, sets the cursor to the
-// provided position and executes given callback fn.
+// provided position and executes given callback fn. If the row was soft-deleted and
+// not yet purged, ErrDeleted is returned instead.
 func (txn *Txn) QueryAt(index uint32, f func(Row) error) (err error) {
-	lock := txn.owner.slock
 	txn.cursor = index
 
 	chunk := commit.ChunkAt(index)
-	lock.RLock(uint(chunk))
+	if !txn.rlock(chunk) {
+		return txn.err
+	}
+
+	if txn.deleted(index) {
+		txn.runlock(chunk)
+		return ErrDeleted
+	}
+
+	if !txn.allowed(index) {
+		txn.runlock(chunk)
+		return ErrForbidden
+	}
+
 	err = f(Row{txn})
-	lock.RUnlock(uint(chunk))
+	txn.runlock(chunk)
+
+	// Accessing a row makes it recently used for the eviction policy
+	if policy := txn.owner.opts.Eviction; policy != nil {
+		policy.Touch(index)
+	}
 	return err
 }
 
+// deleted returns whether the row was soft-deleted and is hidden from the transaction. This
+// must be called while the chunk of the row is locked.
+func (txn *Txn) deleted(idx uint32) bool {
+	if !txn.owner.opts.SoftDelete || txn.tombstones {
+		return false
+	}
+
+	tombstones, ok := txn.columnAt(tombstoneColumn)
+	return ok && tombstones.Contains(idx)
+}
+
 // --------------------------- Locked Range ---------------------------
 
 // rangeRead iterates over index, chunk by chunk and ensures that each
 // chunk is protected by an appropriate read lock.
 func (txn *Txn) rangeRead(f func(offset uint32, index bitmap.Bitmap)) {
+	txn.rangeReadUntil(func(offset uint32, index bitmap.Bitmap) bool {
+		f(offset, index)
+		return true
+	})
+}
+
+// rangeReadUntil iterates over index, chunk by chunk and ensures that each chunk is
+// protected by an appropriate read lock, until the function returns false.
+func (txn *Txn) rangeReadUntil(f func(offset uint32, index bitmap.Bitmap) bool) {
 	limit := commit.Chunk(len(txn.index) >> bitmapShift)
-	lock := txn.owner.slock
 
 	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
-		lock.RLock(uint(chunk))
-		f(chunk.Min(), chunk.OfBitmap(txn.index))
-		lock.RUnlock(uint(chunk))
+		if txn.cancelled() || !txn.rlock(chunk) {
+			return
+		}
+
+		index := chunk.OfBitmap(txn.index)
+		if txn.owner.measured() {
+			txn.scanned += index.Count()
+		}
+
+		next := f(chunk.Min(), index)
+		txn.runlock(chunk)
+		if !next {
+			return
+		}
 	}
 }
 
@@ -63,7 +206,6 @@ func (txn *Txn) rangeRead(f func(offset uint32, index bitmap.Bitmap)) {
 // ensures that each chunk is protected by an appropriate read lock.
 func (txn *Txn) rangeReadPair(column *column, f func(a, b bitmap.Bitmap)) {
 	limit := commit.Chunk(len(txn.index) >> bitmapShift)
-	lock := txn.owner.slock
 
 	// To avoid a potential data race between the reading of the index bitmap
 	// and growing it (concurrent inserts), we need to acquire a read-lock.
@@ -73,30 +215,143 @@ func (txn *Txn) rangeReadPair(column *column, f func(a, b bitmap.Bitmap)) {
 
 	// Iterate through all of the chunks and acquire appropriate shard locks.
 	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
-		lock.RLock(uint(chunk))
+		if txn.cancelled() || !txn.rlock(chunk) {
+			return
+		}
+
 		f(chunk.OfBitmap(txn.index), chunk.OfBitmap(other))
-		lock.RUnlock(uint(chunk))
+		txn.runlock(chunk)
 	}
 }
 
+// prepare locks the chunks written by the transaction ahead of its commit if it needs to be
+// checked before any of its changes is applied, which is the case if it has conditional
+// writes, or if a lock timeout is configured since a commit can not be aborted midway. The
+// chunks stay locked until the transaction is committed. If a conditional write conflicts
+// or the chunks could not be locked in time, the chunks are unlocked and the error is
+// returned, in which case the whole transaction needs to be rolled back. The buffers of the
+// storage are grown beforehand as well, since growing them may fail.
+func (txn *Txn) prepare() error {
+	if err := txn.reserve(); err != nil {
+		return err
+	}
+
+	if len(txn.swaps) == 0 && txn.owner.opts.LockTimeout <= 0 {
+		return nil
+	}
+
+	var shards bitmap.Bitmap
+	for _, u := range txn.updates {
+		u.RangeChunks(func(chunk commit.Chunk) {
+			shards.Set(uint32(chunk) % lockShards)
+		})
+	}
+	for _, s := range txn.swaps {
+		shards.Set(uint32(commit.ChunkAt(s.index)) % lockShards)
+	}
+	txn.deletes.Range(func(idx uint32) {
+		shards.Set(uint32(commit.ChunkAt(idx)) % lockShards)
+	})
+
+	if err := txn.lockShards(shards); err != nil {
+		return err
+	}
+
+	if !txn.checkSwaps() {
+		txn.unlockHeld()
+		return ErrConflict
+	}
+	return nil
+}
+
+// lockShards exclusively locks all of the specified shards at once. The shards are only
+// waited for while none is held, and the others are tried, so that a reader which holds the
+// lock of another chunk is never waited for while holding one. If the lock timeout of the
+// collection elapses meanwhile, the shards are released and ErrLockTimeout is returned.
+func (txn *Txn) lockShards(shards bitmap.Bitmap) error {
+	lock := txn.owner.slock
+	timeout := txn.owner.opts.LockTimeout
+	deadline := time.Now().Add(timeout)
+	for {
+		contended, ok := uint32(0), true
+		for shard := uint32(0); shard < lockShards && ok; shard++ {
+			switch {
+			case !shards.Contains(shard) || txn.held.Contains(shard):
+			case lock.TryLock(uint(shard)):
+				txn.held.Set(shard)
+			default:
+				contended, ok = shard, false
+			}
+		}
+
+		if ok {
+			return nil
+		}
+
+		// Release the shards held and wait for the contended one alone
+		txn.unlockHeld()
+		lock.unshare(uint(contended))
+		switch {
+		case timeout <= 0:
+			lock.Lock(uint(contended))
+		case !acquire(deadline, func() bool { return lock.TryLock(uint(contended)) }):
+			return ErrLockTimeout
+		}
+		txn.held.Set(contended)
+	}
+}
+
+// holds returns whether the chunk was locked ahead of the commit by prepare()
+func (txn *Txn) holds(chunk commit.Chunk) bool {
+	return txn.held.Contains(uint32(chunk) % lockShards)
+}
+
+// unlockHeld unlocks the chunks locked ahead of the commit by prepare()
+func (txn *Txn) unlockHeld() {
+	txn.held.Range(func(shard uint32) {
+		txn.owner.slock.Unlock(uint(shard))
+	})
+	txn.held.Clear()
+}
+
 // rangeWrite ranges over the dirty chunks and acquires exclusive latches along
-// the way. This is used to commit a transaction.
+// the way, unless they were locked ahead by prepare(). This is used to commit a transaction.
 func (txn *Txn) rangeWrite(fn func(commitID uint64, chunk commit.Chunk, fill bitmap.Bitmap)) {
 	lock := txn.owner.slock
+	defer txn.unlockHeld()
 	txn.dirty.Range(func(x uint32) {
 		chunk := commit.Chunk(x)
+		held := txn.holds(chunk)
+		if !held {
+			txn.queue(chunk)
+			lock.Lock(uint(chunk))
+			txn.dequeue(chunk)
+		}
+
+		// The commit ID is issued while holding the lock, so that a chunk read after the
+		// issue of a greater ID always observes the commits with a lower one.
 		commitID := commit.Next()
-		lock.Lock(uint(chunk))
 
 		// Compute the fill and set the last commit ID
+		txn.owner.touch(chunk)
 		txn.owner.lock.RLock()
 		fill := chunk.OfBitmap(txn.owner.fill)
 		txn.owner.commits[chunk] = commitID // OK, since we have a shard lock
 		txn.owner.lock.RUnlock()
 
-		// Call the delegate
+		// Call the delegate, while the chunk is marked as being written for the shared readers
+		shared := txn.owner.shared != nil
+		if shared {
+			txn.owner.advance(chunk)
+		}
+
 		fn(commitID, chunk, fill)
-		lock.Unlock(uint(chunk))
+		if shared {
+			txn.owner.advance(chunk)
+		}
+		if !held {
+			lock.Unlock(uint(chunk))
+		}
 	})
 }
 
@@ -116,3 +371,116 @@ func (c *Collection) readChunk(chunk commit.Chunk, fn func(uint64, commit.Chunk,
 	lock.RUnlock(uint(chunk))
 	return
 }
+
+// lockAll exclusively locks all of the chunks of the collection, in the order of the shards
+func (c *Collection) lockAll() {
+	for shard := uint(0); shard < lockShards; shard++ {
+		c.slock.Lock(shard)
+	}
+}
+
+// unlockAll unlocks all of the chunks locked with lockAll()
+func (c *Collection) unlockAll() {
+	for shard := uint(0); shard < lockShards; shard++ {
+		c.slock.Unlock(shard)
+	}
+}
+
+// --------------------------- Chunk Locks ---------------------------
+
+// chunkLocks represents the sharded read-write locks of the chunks of a collection, where
+// every chunk is locked by the shard of its number.
+type chunkLocks struct {
+	owner *Collection // The collection whose chunks are locked
+	mu    [lockShards]struct {
+		sync.RWMutex
+		_ [40]byte // Padding to prevent false sharing
+	}
+}
+
+// Lock locks the shard for writing. The chunks of the shard which are shared with the
+// origin or the forks of the collection are copied first, see Fork().
+func (l *chunkLocks) Lock(shard uint) {
+	l.owner.fetch(shard)
+	for {
+		l.owner.detach(shard)
+		l.mu[shard%lockShards].Lock()
+		if !l.owner.sharing(shard) {
+			return
+		}
+
+		// A fork was created meanwhile, which shares the chunks again
+		l.mu[shard%lockShards].Unlock()
+	}
+}
+
+// Unlock unlocks the shard for writing
+func (l *chunkLocks) Unlock(shard uint) {
+	l.mu[shard%lockShards].Unlock()
+}
+
+// RLock locks the shard for reading, once the chunks shared with the origin of the
+// collection are copied.
+func (l *chunkLocks) RLock(shard uint) {
+	l.owner.fetch(shard)
+	l.mu[shard%lockShards].RLock()
+}
+
+// RUnlock undoes a single RLock call
+func (l *chunkLocks) RUnlock(shard uint) {
+	l.mu[shard%lockShards].RUnlock()
+}
+
+// TryLock tries to lock the shard for writing, without waiting. It fails while the chunks
+// of the shard are shared, see unshare().
+func (l *chunkLocks) TryLock(shard uint) bool {
+	if l.owner.fetching(shard) || l.owner.sharing(shard) {
+		return false
+	}
+
+	if !l.mu[shard%lockShards].TryLock() {
+		return false
+	}
+
+	if l.owner.sharing(shard) {
+		l.mu[shard%lockShards].Unlock()
+		return false
+	}
+	return true
+}
+
+// TryRLock tries to lock the shard for reading, without waiting. It fails while the chunks
+// of the shard are shared with the origin of the collection, see unshare().
+func (l *chunkLocks) TryRLock(shard uint) bool {
+	return !l.owner.fetching(shard) && l.mu[shard%lockShards].TryRLock()
+}
+
+// unshare copies the chunks of the shard which are shared with the origin or the forks of
+// the collection, so that the shard can then be tried without waiting for the copies.
+func (l *chunkLocks) unshare(shard uint) {
+	l.owner.fetch(shard)
+	l.owner.detach(shard)
+}
+
+// acquire tries to acquire a lock until it succeeds or the deadline passes, waiting for an
+// exponentially increasing delay between the attempts, of up to a millisecond.
+func acquire(deadline time.Time, try func() bool) bool {
+	for delay := time.Microsecond; ; {
+		if try() {
+			return true
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+
+		if delay > remaining {
+			delay = remaining
+		}
+		time.Sleep(delay)
+		if delay < time.Millisecond {
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrMemoryLimit is returned when a query would allocate more memory than the limit set
+// with WithMemoryLimit(). The error returned is a *MemoryError which records the limit.
+var ErrMemoryLimit = errors.New("column: query exceeded its memory limit")
+
+// MemoryError represents an error returned when a query was aborted because it would have
+// exceeded its memory limit. It matches ErrMemoryLimit with errors.Is().
+type MemoryError struct {
+	Limit     int // The memory limit of the query, in bytes
+	Requested int // The memory the query would have held, in bytes
+}
+
+// Error returns the error message
+func (e *MemoryError) Error() string {
+	return fmt.Sprintf("column: query requires %d bytes of memory, exceeding its limit of %d bytes",
+		e.Requested, e.Limit)
+}
+
+// Is returns whether the error matches the target
+func (e *MemoryError) Is(target error) bool {
+	return target == ErrMemoryLimit
+}
+
+// memoryBudget represents the memory a query is allowed to hold, shared by its workers
+type memoryBudget struct {
+	limit int64 // The maximum number of bytes
+	used  int64 // The number of bytes allocated so far
+}
+
+// WithMemoryLimit bounds the memory the transaction allocates for its intermediate results,
+// such as the groups and the sorted buckets of Downsample() and the batches of RangeBatch().
+// Once the limit would be exceeded, the query is aborted and fails with a *MemoryError,
+// instead of exhausting the memory of the process.
+func (txn *Txn) WithMemoryLimit(bytes int) *Txn {
+	txn.budget = &memoryBudget{limit: int64(bytes)}
+	return txn
+}
+
+// allocate accounts for the memory the transaction is about to hold. If this exceeds the
+// memory limit, the transaction is aborted and false is returned.
+func (txn *Txn) allocate(bytes int) bool {
+	if txn.budget == nil {
+		return true
+	}
+
+	used := atomic.AddInt64(&txn.budget.used, int64(bytes))
+	if used <= txn.budget.limit {
+		return true
+	}
+
+	if txn.err == nil {
+		txn.err = &MemoryError{
+			Limit:     int(txn.budget.limit),
+			Requested: int(used),
+		}
+	}
+	return false
+}
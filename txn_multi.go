@@ -0,0 +1,138 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// errNotJoined is returned when a sealed transaction queries another collection
+var errNotJoined = errors.New("column: collection is not part of the transaction")
+
+// joinTimeout is the maximum duration to wait for a collection joined out of order, if the
+// collection has no lock timeout configured
+const joinTimeout = time.Second
+
+// lastCollection is the sequence number of the last collection created, which orders the
+// locks taken by the transactions spanning several collections
+var lastCollection uint64
+
+// nextCollection returns the sequence number of a new collection
+func nextCollection() uint64 {
+	return atomic.AddUint64(&lastCollection, 1)
+}
+
+// MultiTxn represents a transaction which spans several collections. The changes made
+// to all of the collections are either committed or rolled back together.
+type MultiTxn struct {
+	queries []*query // The queries, one per collection
+	sealed  bool     // Whether other collections can no longer join the transaction
+}
+
+// Atomic creates a transaction spanning multiple collections. If the function returns
+// an error, all of the pending changes on every collection are rolled back, otherwise
+// they are all committed once the function returns. Every collection is checked before
+// any of them is committed, so if the triggers, hooks, quotas or conditional writes of
+// one collection refuse the changes, none of the collections is changed. Like a query,
+// the changes of each collection are subject to its timeout, its limit of writers and its
+// eviction policy.
+func Atomic(fn func(tx *MultiTxn) error) error {
+	tx := &MultiTxn{
+		queries: make([]*query, 0, 4),
+	}
+
+	// Execute the query and keep the error for later, then check every collection in the
+	// order of their locks, the triggers and hooks can veto the changes
+	err := fn(tx)
+	sort.Slice(tx.queries, func(i, j int) bool {
+		return tx.queries[i].owner.seq < tx.queries[j].owner.seq
+	})
+
+	for _, q := range tx.queries {
+		err = q.prepare(err)
+	}
+
+	// Commit every collection if none of the transactions have failed, or roll them back
+	var derived []derivedChange
+	for _, q := range tx.queries {
+		derived = append(derived, q.finish(err)...)
+	}
+	if err != nil {
+		return err
+	}
+
+	propagate(derived)
+	for _, q := range tx.queries {
+		q.done()
+	}
+	return nil
+}
+
+// Query executes a function on a specified collection as part of the transaction. The
+// selection starts with all of the rows in the collection for every call, while the
+// pending changes are retained until the entire transaction is committed.
+func (tx *MultiTxn) Query(collection *Collection, fn func(txn *Txn) error) error {
+	txn, err := tx.txnFor(collection)
+	if err != nil {
+		return err
+	}
+
+	txn.setup = false
+	return fn(txn)
+}
+
+// txnFor loads or acquires a transaction for the specified collection. If the transaction
+// is sealed and the collection is not part of it, errNotJoined is returned.
+func (tx *MultiTxn) txnFor(collection *Collection) (*Txn, error) {
+	last := uint64(0)
+	for _, q := range tx.queries {
+		if q.owner == collection {
+			return q.txn, nil
+		}
+		if q.owner.seq > last {
+			last = q.owner.seq
+		}
+	}
+
+	if tx.sealed {
+		return nil, errNotJoined
+	}
+
+	// The collections joined in the order of their locks are waited for, as they can not
+	// be part of a cycle. Otherwise, a shrink queued on the collection could be waiting for
+	// another transaction which waits for one of the collections already joined, hence the
+	// lock is only tried until the lock timeout of the collection.
+	q, err := collection.begin(context.Background(), func() error {
+		return collection.joinLock(collection.seq > last)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx.queries = append(tx.queries, q)
+	return q.txn, nil
+}
+
+// joinLock acquires the transaction lock of the collection for a transaction spanning several
+// collections. If the lock can not be waited for, it is tried until the lock timeout.
+func (c *Collection) joinLock(wait bool) error {
+	if wait {
+		c.txlock.RLock()
+		return nil
+	}
+
+	timeout := c.opts.LockTimeout
+	if timeout <= 0 {
+		timeout = joinTimeout
+	}
+
+	if !acquire(time.Now().Add(timeout), c.txlock.TryRLock) {
+		return ErrLockTimeout
+	}
+	return nil
+}
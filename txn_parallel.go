@@ -0,0 +1,178 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// RangeParallel selects and iterates over the result set using a number of workers, each
+// processing a different chunk of the selection. Since the transaction cursor cannot be
+// shared, the function receives the index along with a row bound to the worker, which
+// should only be used for reading as any updates made through it are discarded. The
+// function must be safe for concurrent use. If the number of workers is not positive,
+// one worker per CPU is used.
+func (txn *Txn) RangeParallel(workers int, fn func(idx uint32, r Row)) error {
+	txn.initialize()
+	txn.rangeParallel(workers, func(worker *Txn, offset uint32, index bitmap.Bitmap) {
+		index.Range(func(x uint32) {
+			worker.cursor = offset + x
+			fn(offset+x, Row{worker})
+		})
+	})
+	return txn.failed()
+}
+
+// CountParallel returns the number of objects matching the query, counting the chunks of
+// the selection using a number of workers.
+func (txn *Txn) CountParallel(workers int) int {
+	var count int64
+	txn.initialize()
+	txn.rangeParallel(workers, func(_ *Txn, _ uint32, index bitmap.Bitmap) {
+		atomic.AddInt64(&count, int64(index.Count()))
+	})
+	return int(count)
+}
+
+// Aggregate represents the aggregated values of a numeric column over a selection.
+type Aggregate struct {
+	Count int     // The number of rows which have a value
+	Sum   float64 // The sum of the values
+	Min   float64 // The smallest value
+	Max   float64 // The largest value
+}
+
+// Avg returns the average of the values, or zero if there are none.
+func (a Aggregate) Avg() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// merge merges another aggregate into this one
+func (a *Aggregate) merge(other Aggregate) {
+	if other.Count == 0 {
+		return
+	}
+
+	if a.Count == 0 {
+		*a = other
+		return
+	}
+
+	a.Count += other.Count
+	a.Sum += other.Sum
+	a.Min = math.Min(a.Min, other.Min)
+	a.Max = math.Max(a.Max, other.Max)
+}
+
+// Aggregate computes the count, sum, minimum and maximum of a numeric column over the
+// result set. The chunks of the selection are aggregated by a number of workers and their
+// results merged together. If the number of workers is not positive, one worker per CPU
+// is used.
+func (txn *Txn) Aggregate(columnName string, workers int) (Aggregate, error) {
+	c, ok := txn.columnAt(columnName)
+	if !ok || !c.IsNumeric() {
+		return Aggregate{}, errorOf(ErrTypeMismatch, "column: unable to aggregate '%s', column is not numeric", columnName)
+	}
+
+	// If the selection is cached, so are the aggregates of its chunks
+	column := c.Column.(Numeric)
+	if txn.cached != nil {
+		return txn.owner.cache.aggregate(txn, txn.cached, columnName, column)
+	}
+
+	// If the filters only intersect bitmaps, reuse the aggregates of the unchanged chunks
+	if agg, ok := txn.pushdown(columnName, column); ok {
+		return agg, txn.failed()
+	}
+
+	var lock sync.Mutex
+	var out Aggregate
+	txn.initialize()
+	txn.rangeParallel(workers, func(_ *Txn, offset uint32, index bitmap.Bitmap) {
+		var agg Aggregate
+		index.Range(func(x uint32) {
+			v, ok := column.LoadFloat64(offset + x)
+			if !ok {
+				return
+			}
+
+			agg.merge(Aggregate{Count: 1, Sum: v, Min: v, Max: v})
+		})
+
+		lock.Lock()
+		out.merge(agg)
+		lock.Unlock()
+	})
+	return out, txn.failed()
+}
+
+// rangeParallel iterates over the index, distributing its chunks across a number of
+// workers. Each worker is a separate transaction with its own cursor, and holds the read
+// lock of the chunk it is processing.
+func (txn *Txn) rangeParallel(workers int, fn func(worker *Txn, offset uint32, index bitmap.Bitmap)) {
+	chunks := (len(txn.index) >> bitmapShift) + 1
+	if workers <= 0 {
+		workers = txn.hints.workers
+	}
+
+	switch {
+	case workers <= 0:
+		workers = runtime.GOMAXPROCS(0)
+	case workers > chunks:
+		workers = chunks
+	}
+
+	var wg sync.WaitGroup
+	var next uint32
+	pool, measured := txn.owner.txns, txn.owner.measured()
+	group := make([]*Txn, workers)
+	for i := range group {
+		worker := pool.acquire(txn.owner)
+		worker.ctx = txn.ctx
+		worker.setup = true
+		worker.unlocked = txn.unlocked
+		worker.budget = txn.budget
+		group[i] = worker
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				chunk := commit.Chunk(atomic.AddUint32(&next, 1) - 1)
+				if int(chunk) >= chunks || worker.cancelled() || !worker.rlock(chunk) {
+					return
+				}
+
+				index := chunk.OfBitmap(txn.index)
+				if measured {
+					worker.scanned += index.Count()
+				}
+
+				fn(worker, chunk.Min(), index)
+				worker.runlock(chunk)
+			}
+		}()
+	}
+
+	// Merge the state of the workers back and release them
+	wg.Wait()
+	for _, worker := range group {
+		if txn.err == nil {
+			txn.err = worker.err
+		}
+
+		txn.scanned += worker.scanned
+		worker.rollback()
+		pool.release(worker)
+	}
+}
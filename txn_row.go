@@ -3,6 +3,12 @@
 
 package column
 
+import (
+	"net"
+	"sort"
+	"time"
+)
+
 // Row represents a cursor at a particular row offest in the transaction.
 type Row struct {
 	txn *Txn
@@ -25,6 +31,21 @@ func (r Row) AddInt(columnName string, value int) {
 	r.txn.Int(columnName).Add(value)
 }
 
+// Int8 loads a int8 value at a particular column
+func (r Row) Int8(columnName string) (v int8, ok bool) {
+	return int8ReaderFor(r.txn, columnName).Get()
+}
+
+// SetInt8 stores a int8 value at a particular column
+func (r Row) SetInt8(columnName string, value int8) {
+	r.txn.Int8(columnName).Set(value)
+}
+
+// AddInt8 adds delta to a int8 value at a particular column
+func (r Row) AddInt8(columnName string, value int8) {
+	r.txn.Int8(columnName).Add(value)
+}
+
 // Int16 loads a int16 value at a particular column
 func (r Row) Int16(columnName string) (v int16, ok bool) {
 	return int16ReaderFor(r.txn, columnName).Get()
@@ -85,6 +106,21 @@ func (r Row) AddUint(columnName string, value uint) {
 	r.txn.Uint(columnName).Add(value)
 }
 
+// Uint8 loads a uint8 value at a particular column
+func (r Row) Uint8(columnName string) (v uint8, ok bool) {
+	return uint8ReaderFor(r.txn, columnName).Get()
+}
+
+// SetUint8 stores a uint8 value at a particular column
+func (r Row) SetUint8(columnName string, value uint8) {
+	r.txn.Uint8(columnName).Set(value)
+}
+
+// AddUint8 adds delta to a uint8 value at a particular column
+func (r Row) AddUint8(columnName string, value uint8) {
+	r.txn.Uint8(columnName).Add(value)
+}
+
 // Uint16 loads a uint16 value at a particular column
 func (r Row) Uint16(columnName string) (v uint16, ok bool) {
 	return uint16ReaderFor(r.txn, columnName).Get()
@@ -207,6 +243,51 @@ func (r Row) SetBool(columnName string, value bool) {
 	r.txn.Bool(columnName).Set(value)
 }
 
+// Map loads a map value at a particular column
+func (r Row) Map(columnName string) (Map, bool) {
+	return mapReaderFor(r.txn, columnName).Get()
+}
+
+// SetMap stores a map value at a particular column
+func (r Row) SetMap(columnName string, value Map) {
+	r.txn.Map(columnName).Set(value)
+}
+
+// Duration loads a duration value at a particular column
+func (r Row) Duration(columnName string) (time.Duration, bool) {
+	return durationReaderFor(r.txn, columnName).Get()
+}
+
+// SetDuration stores a duration value at a particular column
+func (r Row) SetDuration(columnName string, value time.Duration) {
+	r.txn.Duration(columnName).Set(value)
+}
+
+// AddDuration adds delta to a duration value at a particular column
+func (r Row) AddDuration(columnName string, value time.Duration) {
+	r.txn.Duration(columnName).Add(value)
+}
+
+// IP loads an IP address at a particular column
+func (r Row) IP(columnName string) (net.IP, bool) {
+	return ipReaderFor(r.txn, columnName).Get()
+}
+
+// SetIP stores an IP address at a particular column
+func (r Row) SetIP(columnName string, value net.IP) {
+	r.txn.IP(columnName).Set(value)
+}
+
+// Int128 loads a 128-bit integer at a particular column
+func (r Row) Int128(columnName string) (Int128, bool) {
+	return int128ReaderFor(r.txn, columnName).Get()
+}
+
+// SetInt128 stores a 128-bit integer at a particular column
+func (r Row) SetInt128(columnName string, value Int128) {
+	r.txn.Int128(columnName).Set(value)
+}
+
 // Any loads a bool value at a particular column
 func (r Row) Any(columnName string) (interface{}, bool) {
 	return anyReaderFor(r.txn, columnName).Get()
@@ -216,3 +297,34 @@ func (r Row) Any(columnName string) (interface{}, bool) {
 func (r Row) SetAny(columnName string, value interface{}) {
 	r.txn.Any(columnName).Set(value)
 }
+
+// Add atomically adds a delta of any numeric type to the value at a particular column. The
+// delta is converted to the type of the column, for example Add("balance", 10) adds 10.0
+// to a float64 column. If the column is not numeric, the transaction is aborted.
+func (r Row) Add(columnName string, delta interface{}) {
+	r.txn.Any(columnName).Add(delta)
+}
+
+// Merge merges a delta into the value at a particular column, with the merge function the
+// column was created with. If the column has no merge function, the transaction is aborted.
+func (r Row) Merge(columnName string, delta interface{}) {
+	r.txn.Any(columnName).Merge(delta)
+}
+
+// Indexes returns the names of the bitmap indexes which currently contain the row, in
+// alphabetical order, including the partial and the expression indexes. This helps to
+// understand why a row is excluded from a query filtering on the indexes.
+func (r Row) Indexes() []string {
+	names := make([]string, 0, 4)
+	r.txn.owner.cols.Range(func(v *column) {
+		switch v.Column.(type) {
+		case *columnIndex, *columnExpr:
+			if v.Contains(r.txn.cursor) {
+				names = append(names, v.name)
+			}
+		}
+	})
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,78 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// SampleWeighted randomly picks up to n distinct rows of the result set, with a probability
+// proportional to the value of a numeric column, and returns their indexes in the order in
+// which they were drawn. The rows without a value, or with a value which is not positive,
+// are never picked. The selection is sampled in a single pass, without being exported.
+func (txn *Txn) SampleWeighted(n int, columnName string) ([]uint32, error) {
+	c, ok := txn.columnAt(columnName)
+	switch {
+	case !ok:
+		return nil, errorOf(ErrColumnNotFound, "column: unable to sample by '%s', column does not exist", columnName)
+	case !c.IsNumeric():
+		return nil, errorOf(ErrTypeMismatch, "column: unable to sample by '%s', column is not numeric", columnName)
+	case n <= 0:
+		return nil, nil
+	}
+
+	// Each row is given a random key of log(u) / weight and the rows with the largest keys
+	// are kept, which is equivalent to drawing them one by one without replacement.
+	column := c.Column.(Numeric)
+	picked := make(sampleHeap, 0, n)
+	if err := txn.Range(func(idx uint32) {
+		weight, ok := column.LoadFloat64(idx)
+		if !ok || !(weight > 0) || math.IsInf(weight, 1) {
+			return
+		}
+
+		key := math.Log(1-rand.Float64()) / weight
+		switch {
+		case len(picked) < n:
+			heap.Push(&picked, sampleEntry{key: key, index: idx})
+		case key > picked[0].key:
+			picked[0] = sampleEntry{key: key, index: idx}
+			heap.Fix(&picked, 0)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(picked, func(i, j int) bool {
+		return picked[i].key > picked[j].key
+	})
+
+	out := make([]uint32, 0, len(picked))
+	for _, v := range picked {
+		out = append(out, v.index)
+	}
+	return out, nil
+}
+
+// sampleEntry represents a row sampled along with its random key
+type sampleEntry struct {
+	key   float64
+	index uint32
+}
+
+// sampleHeap represents a min-heap of the sampled rows, by their key
+type sampleHeap []sampleEntry
+
+func (h sampleHeap) Len() int            { return len(h) }
+func (h sampleHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h sampleHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sampleHeap) Push(x interface{}) { *h = append(*h, x.(sampleEntry)) }
+func (h *sampleHeap) Pop() (x interface{}) {
+	old := *h
+	x, *h = old[len(old)-1], old[:len(old)-1]
+	return
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleWeighted(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+	assert.NoError(t, col.CreateColumn("weight", ForFloat64()))
+	for i, w := range []float64{1, 9, 0, -5} {
+		col.Insert(func(r Row) error {
+			r.SetString("name", string(rune('a'+i)))
+			r.SetFloat64("weight", w)
+			return nil
+		})
+	}
+	col.InsertObject(Object{"name": "e"})
+
+	// Only the rows with a positive weight can be picked, with their odds
+	hits := make(map[uint32]int)
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		for i := 0; i < 2000; i++ {
+			picked, err := txn.SampleWeighted(1, "weight")
+			assert.NoError(t, err)
+			assert.Len(t, picked, 1)
+			hits[picked[0]]++
+		}
+
+		picked, err := txn.SampleWeighted(10, "weight")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []uint32{0, 1}, picked)
+
+		picked, err = txn.WithFloat("weight", func(v float64) bool {
+			return v < 5
+		}).SampleWeighted(10, "weight")
+		assert.NoError(t, err)
+		assert.Equal(t, []uint32{0}, picked)
+		return nil
+	}))
+
+	assert.Len(t, hits, 2)
+	assert.InDelta(t, 1800, hits[1], 150)
+
+	// The column must exist and be numeric
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		_, err := txn.SampleWeighted(1, "missing")
+		assert.ErrorIs(t, err, ErrColumnNotFound)
+		_, err = txn.SampleWeighted(1, "name")
+		assert.ErrorIs(t, err, ErrTypeMismatch)
+		return nil
+	}))
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// ScanCheckpoint represents the progress of a scan which can be resumed, even by another
+// process once the collection is restored. The zero value starts a scan from the first row.
+type ScanCheckpoint struct {
+	Offset uint32 `json:"offset"` // The offset of the next row to scan
+	Commit uint64 `json:"commit"` // The commit watermark of the rows before the offset
+}
+
+// RangeFrom iterates over the result set in the order of the rows, starting from a
+// checkpoint, until the function returns false, and returns the checkpoint from which the
+// scan continues after the last row passed to the function. The rows before the offset of
+// the checkpoint are not scanned again, unless their chunk was committed after its
+// watermark, since the rows inserted into the free slots of the chunk would otherwise be
+// missed. In that case the rows of the chunk are scanned again first, and if the scan
+// stops before reaching the offset, the same checkpoint is returned.
+func (txn *Txn) RangeFrom(from ScanCheckpoint, fn func(idx uint32) bool) (ScanCheckpoint, error) {
+	watermark := commit.Last()
+	next, more := from, true
+
+	txn.initialize()
+	txn.rangeReadUntil(func(offset uint32, index bitmap.Bitmap) bool {
+		stale := from.Offset > offset && txn.owner.commitOf(commit.ChunkAt(offset)) > from.Commit
+		if !stale && from.Offset >= offset+chunkSize {
+			return true
+		}
+
+		index.Range(func(x uint32) {
+			idx := offset + x
+			switch {
+			case !more:
+			case idx < from.Offset && !stale:
+			default:
+				txn.cursor = idx
+				if more = fn(idx); idx >= from.Offset {
+					next = ScanCheckpoint{Offset: idx + 1, Commit: watermark}
+				}
+			}
+		})
+		return more
+	})
+
+	// Once every row is scanned, the watermark holds for all of them
+	if err := txn.failed(); err != nil {
+		return from, err
+	}
+	if more {
+		next.Commit = watermark
+	}
+	return next, nil
+}
+
+// commitOf returns the ID of the last commit of a chunk
+func (c *Collection) commitOf(chunk commit.Chunk) (commitID uint64) {
+	c.lock.RLock()
+	if int(chunk) < len(c.commits) {
+		commitID = c.commits[chunk]
+	}
+	c.lock.RUnlock()
+	return
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeFrom(t *testing.T) {
+	col := NewCollection()
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("value", ForInt()))
+	assert.NoError(t, col.Query(func(txn *Txn) error {
+		for i := 0; i < 20000; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetInt("value", i)
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	// Scan the rows in batches, resuming from the checkpoint each time
+	seen := make(map[uint32]int)
+	scan := func(col *Collection, from ScanCheckpoint, limit int) (next ScanCheckpoint) {
+		assert.NoError(t, col.Query(func(txn *Txn) (err error) {
+			count := 0
+			next, err = txn.RangeFrom(from, func(idx uint32) bool {
+				seen[idx]++
+				count++
+				return count < limit
+			})
+			return
+		}))
+		return
+	}
+
+	checkpoint := scan(col, ScanCheckpoint{}, 100)
+	assert.Equal(t, uint32(100), checkpoint.Offset)
+	checkpoint = scan(col, checkpoint, 100)
+	assert.Equal(t, uint32(200), checkpoint.Offset)
+	assert.Len(t, seen, 200)
+
+	// Resume in another collection, restored from a snapshot
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, col.Snapshot(buffer))
+	restored := NewCollection()
+	defer restored.Close()
+	assert.NoError(t, restored.Restore(buffer))
+
+	checkpoint = scan(restored, checkpoint, 50000)
+	assert.Equal(t, uint32(20000), checkpoint.Offset)
+	assert.Len(t, seen, 20000)
+	for _, n := range seen {
+		assert.Equal(t, 1, n)
+	}
+
+	// Once caught up, only the new rows and the chunks committed since are scanned
+	seen = make(map[uint32]int)
+	assert.Equal(t, checkpoint, scan(restored, checkpoint, 50000))
+	assert.Empty(t, seen)
+
+	restored.DeleteAt(17000)
+	idx := restored.InsertObject(Object{"value": 99})
+	checkpoint = scan(restored, checkpoint, 50000)
+	assert.Equal(t, 1, seen[idx])
+	assert.Len(t, seen, 20000-chunkSize)
+	assert.Zero(t, seen[0])
+}
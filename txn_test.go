@@ -4,10 +4,15 @@
 package column
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/kelindar/column/commit"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,6 +52,59 @@ func TestMany(t *testing.T) {
 	assert.Equal(t, 20000, count)
 }
 
+func TestIterators(t *testing.T) {
+	players := loadPlayers(20000)
+	players.Query(func(txn *Txn) error {
+		txn.WithValue("race", func(v interface{}) bool {
+			return v == "human"
+		})
+
+		// Iterate over the rows, until we break
+		count := 0
+		txn.Rows()(func(idx uint32, r Row) bool {
+			race, _ := r.Enum("race")
+			assert.Equal(t, "human", race)
+			count++
+			return count < 100
+		})
+		assert.Equal(t, 100, count)
+
+		// Iterate over the values, and check them against the range
+		sum := 0.0
+		txn.Float64s("age")(func(v float64) bool {
+			sum += v
+			return true
+		})
+
+		expect := 0.0
+		age := txn.Float64("age")
+		txn.Range(func(idx uint32) {
+			v, _ := age.Get()
+			expect += v
+		})
+		assert.Equal(t, expect, sum)
+		return nil
+	})
+
+	// Iterate over the string values, the next rows are not visited once stopped
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+	for i := 0; i < 20000; i++ {
+		col.InsertObject(Object{"name": fmt.Sprintf("player %d", i)})
+	}
+
+	col.Query(func(txn *Txn) error {
+		var names []string
+		txn.Strings("name")(func(v string) bool {
+			names = append(names, v)
+			return v != "player 2"
+		})
+		assert.Equal(t, []string{"player 0", "player 1", "player 2"}, names)
+		return nil
+	})
+}
+
 func TestCount(t *testing.T) {
 	players := loadPlayers(500)
 
@@ -142,14 +200,12 @@ func TestIndexInvalid(t *testing.T) {
 		return nil
 	})
 
-	assert.Panics(t, func() {
-		players.Query(func(txn *Txn) error {
-			invalid := txn.Float64("invalid-column")
-			return txn.Range(func(index uint32) {
-				invalid.Add(1)
-			})
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		invalid := txn.Float64("invalid-column")
+		return txn.Range(func(index uint32) {
+			invalid.Add(1)
 		})
-	})
+	}), ErrColumnNotFound)
 
 	assert.NoError(t, players.Query(func(txn *Txn) error {
 		txn.WithString("class", func(v string) bool {
@@ -227,7 +283,7 @@ func TestDeleteAll(t *testing.T) {
 
 	// Delete all old people from the collection
 	players.Query(func(txn *Txn) error {
-		txn.With("old").DeleteAll()
+		assert.Equal(t, 255, txn.With("old").DeleteAll())
 		return nil
 	})
 
@@ -238,6 +294,135 @@ func TestDeleteAll(t *testing.T) {
 	}))
 }
 
+func TestRowObservers(t *testing.T) {
+	players := loadPlayers(500)
+	players.observers.add(-1) // The writer of the fixture
+	assert.False(t, players.observers.any())
+
+	// The features register themselves once enabled, and unregister once disabled
+	assert.NoError(t, players.SetColumnTTL("hp", time.Hour))
+	assert.NoError(t, players.CreateCounter("mages", "class", func(v interface{}) bool {
+		return v == "mage"
+	}))
+	assert.True(t, players.observers.any())
+	players.DropColumn("hp")
+	players.DropCounter("mages")
+	assert.False(t, players.observers.any())
+
+	remove, err := players.AddWriter(new(noopWriter), WriterOptions{})
+	assert.NoError(t, err)
+	window, err := players.Window("age", time.Minute, "")
+	assert.NoError(t, err)
+	assert.True(t, players.observers.any())
+	remove()
+	window.Close()
+	assert.False(t, players.observers.any())
+
+	// A snapshot only observes the rows while it is in progress
+	assert.NoError(t, players.Snapshot(new(bytes.Buffer)))
+	assert.False(t, players.observers.any())
+	assert.True(t, NewCollection(Options{Retention: time.Hour}).observers.any())
+}
+
+func TestDeleteAllBitmap(t *testing.T) {
+	newPeople := func(writer commit.Logger) *Collection {
+		people := NewCollection(Options{Writer: writer})
+		people.CreateColumn("id", ForKey())
+		people.CreateColumn("class", ForEnum())
+		people.CreateColumn("age", ForInt())
+		people.CreateColumn("active", ForBool())
+		people.CreateIndex("old", "age", func(r Reader) bool {
+			return r.Int() >= 50
+		})
+
+		people.Query(func(txn *Txn) error {
+			for i := 0; i < 20000; i++ {
+				txn.Insert(func(r Row) error {
+					r.SetKey(fmt.Sprintf("p%d", i))
+					r.SetEnum("class", "mage")
+					r.SetInt("age", i%100)
+					r.SetBool("active", true)
+					return nil
+				})
+			}
+			return nil
+		})
+		return people
+	}
+
+	// Without a writer, the deletes are applied to the fill list and the columns at once
+	people := newPeople(nil)
+	assert.NoError(t, people.Query(func(txn *Txn) error {
+		assert.Equal(t, 10000, txn.With("old").DeleteAll())
+		return nil
+	}))
+
+	assert.Equal(t, 10000, people.Count())
+	assert.NoError(t, people.Query(func(txn *Txn) error {
+		assert.Equal(t, 10000, txn.With("active").Count())
+		assert.Equal(t, 10000, txn.WithValue("class", func(v interface{}) bool { return v == "mage" }).Count())
+		assert.Equal(t, 0, txn.With("old").Count())
+		return nil
+	}))
+
+	_, ok := people.IndexOf("p50")
+	assert.False(t, ok)
+	_, ok = people.IndexOf("p49")
+	assert.True(t, ok)
+
+	// The rows inserted into the deleted slots do not see any of the stale values
+	idx, err := people.Insert(func(r Row) error {
+		r.SetKey("new")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, people.QueryAt(idx, func(r Row) error {
+		_, ok := r.Int("age")
+		assert.False(t, ok)
+		assert.False(t, r.Bool("active"))
+		return nil
+	}))
+
+	// With a writer, the deletes are still written as delete markers
+	writer := make(commit.Channel, 1024)
+	people = newPeople(&writer)
+	for len(writer) > 0 {
+		<-writer
+	}
+
+	assert.NoError(t, people.Query(func(txn *Txn) error {
+		assert.Equal(t, 10000, txn.With("old").DeleteAll())
+		return nil
+	}))
+
+	deleted := 0
+	reader := commit.NewReader()
+	for len(writer) > 0 {
+		change := <-writer
+		for _, u := range change.Updates {
+			if u.Column == rowColumn {
+				reader.Range(u, change.Chunk, func(r *commit.Reader) {
+					for r.Next() {
+						assert.Equal(t, commit.Delete, r.Type)
+						deleted++
+					}
+				})
+			}
+		}
+	}
+	assert.Equal(t, 10000, deleted)
+}
+
+func TestDeleteAllWithRollback(t *testing.T) {
+	players := loadPlayers(500)
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.DeleteAll())
+		return fmt.Errorf("rollback")
+	}))
+
+	assert.Equal(t, 500, players.Count())
+}
+
 func TestDeleteFromIndex(t *testing.T) {
 	players := loadPlayers(500)
 	assert.Equal(t, 500, players.Count())
@@ -317,6 +502,73 @@ func TestIndexWithAtomicAdd(t *testing.T) {
 	})
 }
 
+func TestConcurrentAdd(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("balance", ForFloat64())
+	col.CreateColumn("count", ForInt32())
+	defer col.Close()
+	idx := col.InsertObject(Object{"balance": 100.0})
+
+	// Concurrent increments on the same row compose with one another
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				col.QueryAt(idx, func(r Row) error {
+					r.Add("balance", 2)
+					r.Add("balance", -1.5)
+					r.Add("count", uint8(1))
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		count, _ := r.Int32("count")
+		assert.Equal(t, 500.0, balance)
+		assert.Equal(t, int32(800), count)
+		return nil
+	}))
+
+	// Non-numeric values can not be added
+	col.CreateColumn("name", ForString())
+	assert.ErrorIs(t, col.QueryAt(idx, func(r Row) error {
+		r.Add("name", 1)
+		return nil
+	}), ErrTypeMismatch)
+	assert.ErrorIs(t, col.QueryAt(idx, func(r Row) error {
+		r.Add("count", "1")
+		return nil
+	}), ErrTypeMismatch)
+}
+
+func TestAddAfterDelete(t *testing.T) {
+	for _, opts := range [][]ColumnOption{nil, {WithEncoding(Delta)}} {
+		col := NewCollection()
+		col.CreateColumn("balance", ForInt64(opts...))
+		idx := col.InsertObject(Object{"balance": 100})
+		assert.True(t, col.DeleteAt(idx))
+
+		// The row inserted at the same index starts from zero
+		again, _ := col.Insert(func(r Row) error {
+			r.AddInt64("balance", 5)
+			return nil
+		})
+		assert.Equal(t, idx, again)
+		col.QueryAt(again, func(r Row) error {
+			balance, _ := r.Int64("balance")
+			assert.Equal(t, int64(5), balance)
+			return nil
+		})
+		col.Close()
+	}
+}
+
 func TestUpdateWithRollback(t *testing.T) {
 	players := loadPlayers(500)
 	players.CreateIndex("rich", "balance", func(r Reader) bool {
@@ -439,12 +691,10 @@ func TestUpdateAtInvalid(t *testing.T) {
 	c := NewCollection()
 	c.CreateColumn("col1", ForString())
 
-	assert.Panics(t, func() {
-		c.QueryAt(0, func(r Row) error {
-			r.SetString("col2", "hi")
-			return nil
-		})
-	})
+	assert.ErrorIs(t, c.QueryAt(0, func(r Row) error {
+		r.SetString("col2", "hi")
+		return nil
+	}), ErrColumnNotFound)
 }
 func TestUpdateAtNoChanges(t *testing.T) {
 	c := NewCollection()
@@ -483,12 +733,11 @@ func TestUpsertKeyNoColumn(t *testing.T) {
 	c := NewCollection()
 	c.CreateColumn("key", ForKey())
 
-	assert.Panics(t, func() {
-		c.QueryKey("1", func(r Row) error {
-			r.Enum("xxx")
-			return nil
-		})
-	})
+	assert.ErrorIs(t, c.QueryKey("1", func(r Row) error {
+		r.Enum("xxx")
+		return nil
+	}), ErrColumnNotFound)
+	assert.Zero(t, c.Count())
 }
 
 func TestDuplicateKey(t *testing.T) {
@@ -593,6 +842,36 @@ func TestRow(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRowIndexes(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("age", ForInt())
+	c.CreateColumn("active", ForBool())
+	c.CreateIndex("young", "age", func(r Reader) bool { return r.Int() < 30 })
+	c.CreateIndex("old", "age", func(r Reader) bool { return r.Int() >= 60 })
+	c.CreatePartialIndex("young_active", "age", "active", func(r Reader) bool { return r.Int() < 30 })
+	c.CreateExpressionIndex("adult_active", []string{"age", "active"}, func(r Row) bool {
+		age, _ := r.Int("age")
+		return age >= 18 && r.Bool("active")
+	})
+	c.CreateLookup("by_age", "age")
+
+	idx := c.InsertObject(Object{"age": 25, "active": true})
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		assert.Equal(t, []string{"adult_active", "young", "young_active"}, r.Indexes())
+		return nil
+	}))
+
+	c.QueryAt(idx, func(r Row) error {
+		r.SetInt("age", 70)
+		r.SetBool("active", false)
+		return nil
+	})
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		assert.Equal(t, []string{"old"}, r.Indexes())
+		return nil
+	}))
+}
+
 func TestUnion(t *testing.T) {
 	c := NewCollection()
 	c.CreateColumn("d_a", ForString())
@@ -631,3 +910,682 @@ func TestUnion(t *testing.T) {
 		return nil
 	})
 }
+
+func TestUpdate(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		n, err := txn.With("mage").Update(Set("age", 99), Add("balance", 10), Set("class", "archmage"))
+		assert.Equal(t, 151, n)
+		return err
+	}))
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("mage").Count())
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 151, txn.WithFloat("age", func(v float64) bool {
+			return v == 99
+		}).WithString("class", func(v string) bool {
+			return v == "archmage"
+		}).Count())
+		return nil
+	})
+}
+
+func TestUpdateInvalid(t *testing.T) {
+	players := loadPlayers(500)
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		_, err := txn.Update(Set("invalid", 1))
+		return err
+	}))
+
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		_, err := txn.Update(Add("class", 1))
+		return err
+	}))
+
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		_, err := txn.Update(Set("age", "old"))
+		return err
+	}))
+}
+
+func TestUpdateTypeMismatch(t *testing.T) {
+	players := loadPlayers(500)
+	players.CreateColumn("nickname", ForString())
+
+	for _, m := range []Mutation{
+		Set("nickname", 5),          // string
+		Set("nickname", struct{}{}), // string, not supported by the buffers
+		Set("name", 5),              // enum
+		Set("active", "yes"),        // bool
+		Set("serial", 5),            // key
+	} {
+		assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+			_, err := txn.With("mage").Update(m)
+			return err
+		}), ErrTypeMismatch)
+	}
+
+	// The values are left as they were
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.WithValue("name", func(v interface{}) bool {
+			return v != ""
+		}).Count())
+		return nil
+	}))
+}
+
+func TestSavepoint(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		txn.With("mage").Range(func(idx uint32) {
+			balance.Set(10)
+		})
+
+		// Roll back the second batch only
+		sp := txn.Savepoint()
+		txn.With("human").Range(func(idx uint32) {
+			balance.Set(20)
+		})
+		txn.Enum("class").Set("unknown")
+		txn.DeleteAll()
+		return txn.RollbackTo(sp)
+	}))
+
+	assert.Equal(t, 500, players.Count())
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 151, txn.WithFloat("balance", func(v float64) bool {
+			return v == 10
+		}).Count())
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithFloat("balance", func(v float64) bool {
+			return v == 20
+		}).Count())
+		return nil
+	})
+}
+
+func TestSavepointInsert(t *testing.T) {
+	players := NewCollection()
+	defer players.Close()
+	players.CreateColumn("serial", ForKey())
+	players.CreateColumn("name", ForString())
+
+	// The rows inserted after the savepoint are released, along with their keys
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.InsertObject(Object{"serial": "a", "name": "Roman"})
+		sp := txn.Savepoint()
+		txn.InsertObject(Object{"serial": "b", "name": "Merlin"})
+		txn.QueryKey("c", func(r Row) error {
+			r.SetString("name", "Gandalf")
+			return nil
+		})
+		return txn.RollbackTo(sp)
+	}))
+
+	assert.Equal(t, 1, players.Count())
+	assert.Equal(t, []string{"Roman"}, namesOf(players))
+	_, ok := players.pk.OffsetOf("b")
+	assert.False(t, ok)
+	_, ok = players.pk.OffsetOf("c")
+	assert.False(t, ok)
+
+	// The released indexes are reused by the next inserts
+	players.InsertObject(Object{"serial": "d", "name": "Frodo"})
+	assert.Equal(t, 2, players.Count())
+	idx, ok := players.IndexOf("d")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), idx)
+}
+
+func TestSavepointInvalid(t *testing.T) {
+	players := loadPlayers(500)
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		return txn.RollbackTo(Savepoint{})
+	}))
+}
+
+func TestAtomic(t *testing.T) {
+	src := loadPlayers(500)
+	dst := loadPlayers(500)
+
+	// Move the balance from one collection to another and fail midway
+	assert.Error(t, Atomic(func(tx *MultiTxn) error {
+		tx.Query(src, func(txn *Txn) error {
+			txn.QueryAt(0, func(r Row) error {
+				r.SetFloat64("balance", 0)
+				return nil
+			})
+			return nil
+		})
+
+		return tx.Query(dst, func(txn *Txn) error {
+			return fmt.Errorf("unable to transfer")
+		})
+	}))
+
+	// Move the balance from one collection to another
+	assert.NoError(t, Atomic(func(tx *MultiTxn) error {
+		tx.Query(src, func(txn *Txn) error {
+			return txn.QueryAt(1, func(r Row) error {
+				r.SetFloat64("balance", 0)
+				return nil
+			})
+		})
+
+		return tx.Query(dst, func(txn *Txn) error {
+			return txn.QueryAt(1, func(r Row) error {
+				r.AddFloat64("balance", 1000)
+				return nil
+			})
+		})
+	}))
+
+	src.QueryAt(0, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.NotEqual(t, 0.0, balance)
+		return nil
+	})
+
+	src.QueryAt(1, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.Equal(t, 0.0, balance)
+		return nil
+	})
+
+	dst.QueryAt(1, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.Greater(t, balance, 1000.0)
+		return nil
+	})
+}
+
+func TestAtomicConflict(t *testing.T) {
+	src := loadPlayers(500)
+	dst := loadPlayers(500)
+
+	// The conditional write on the second collection conflicts, so the first one is
+	// not committed either
+	assert.Equal(t, ErrConflict, Atomic(func(tx *MultiTxn) error {
+		tx.Query(src, func(txn *Txn) error {
+			return txn.QueryAt(0, func(r Row) error {
+				r.SetFloat64("balance", 0)
+				return nil
+			})
+		})
+
+		return tx.Query(dst, func(txn *Txn) error {
+			var balance float64
+			txn.QueryAt(0, func(r Row) error {
+				balance, _ = r.Float64("balance")
+				return nil
+			})
+
+			swapped, err := txn.UpdateAtIf(0, "balance", balance, 0.0)
+			assert.True(t, swapped)
+			assert.NoError(t, dst.QueryAt(0, func(r Row) error {
+				r.SetFloat64("balance", balance+1)
+				return nil
+			}))
+			return err
+		})
+	}))
+
+	assert.NoError(t, src.QueryAt(0, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		assert.NotEqual(t, 0.0, balance)
+		return nil
+	}))
+}
+
+func TestAtomicLimits(t *testing.T) {
+	bounded := NewCollection(Options{
+		MaxRows:  10,
+		Eviction: EvictFIFO(),
+	})
+	bounded.CreateColumn("name", ForString())
+
+	// The rows beyond the limit of the collection are evicted
+	assert.NoError(t, Atomic(func(tx *MultiTxn) error {
+		return tx.Query(bounded, func(txn *Txn) error {
+			for i := 0; i < 50; i++ {
+				txn.InsertObject(Object{"name": fmt.Sprintf("item %d", i)})
+			}
+			return nil
+		})
+	}))
+	assert.Equal(t, 10, bounded.Count())
+
+	// The transaction times out once the timeout of the collection is reached
+	players := loadPlayers(500)
+	players.opts.Timeout = time.Millisecond
+	assert.Equal(t, ErrTimeout, Atomic(func(tx *MultiTxn) error {
+		return tx.Query(players, func(txn *Txn) error {
+			time.Sleep(5 * time.Millisecond)
+			return txn.Range(func(idx uint32) {
+				txn.DeleteAt(idx)
+			})
+		})
+	}))
+	assert.Equal(t, 500, players.Count())
+}
+
+func TestAtomicLockOrder(t *testing.T) {
+	a := NewCollection(Options{LockTimeout: time.Millisecond})
+	b := NewCollection(Options{LockTimeout: time.Millisecond})
+	for _, c := range []*Collection{a, b} {
+		c.CreateColumn("balance", ForFloat64())
+		c.InsertObject(Object{"balance": 0.0})
+	}
+
+	// The collections are joined in opposite orders while they are being shrunk
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(4)
+		for _, c := range []*Collection{a, b} {
+			go func(c *Collection) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					c.Shrink()
+				}
+			}(c)
+		}
+
+		for _, pair := range [][2]*Collection{{a, b}, {b, a}} {
+			go func(first, second *Collection) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					err := Atomic(func(tx *MultiTxn) error {
+						return tx.Query(first, func(txn *Txn) error {
+							time.Sleep(100 * time.Microsecond)
+							return tx.Query(second, func(txn *Txn) error {
+								return txn.QueryAt(0, func(r Row) error {
+									r.AddFloat64("balance", 1)
+									return nil
+								})
+							})
+						})
+					})
+					assert.True(t, err == nil || err == ErrLockTimeout)
+				}
+			}(pair[0], pair[1])
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("deadlock")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	players := loadPlayers(500)
+	players.Query(func(txn *Txn) error {
+		plan := txn.Explain()
+		count := txn.With("human", "mage").WithFloat("age", func(v float64) bool {
+			return v >= 30
+		}).Count()
+
+		assert.Len(t, plan.Steps, 3)
+		assert.Equal(t, "With", plan.Steps[0].Operation)
+		assert.Equal(t, "human", plan.Steps[0].Column)
+		assert.True(t, plan.Steps[0].Indexed)
+		assert.Equal(t, 500, plan.Steps[0].Input)
+		assert.Equal(t, plan.Steps[0].Actual, plan.Steps[0].Estimated)
+		assert.Equal(t, plan.Steps[0].Actual, plan.Steps[1].Input)
+		assert.Equal(t, "WithFloat", plan.Steps[2].Operation)
+		assert.False(t, plan.Steps[2].Indexed)
+		assert.Equal(t, count, plan.Steps[2].Actual)
+		assert.Contains(t, plan.String(), "1. With(human) bitmap: 500 rows")
+		return nil
+	})
+
+	// Plans are not recorded unless requested
+	players.Query(func(txn *Txn) error {
+		txn.With("human")
+		assert.Nil(t, txn.plan)
+		return nil
+	})
+}
+
+func TestFilterReorder(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("id", ForFloat64())
+	c.CreateColumn("active", ForBool())
+	for i := 0; i < 1000; i++ {
+		c.InsertObject(Object{"id": float64(i), "active": i > 0})
+	}
+
+	// Until the scan is observed, the index is expected to be more selective
+	query := func() (count int, plan *Plan) {
+		c.Query(func(txn *Txn) error {
+			plan = txn.Explain()
+			count = txn.With("active").WithFloat("id", func(v float64) bool {
+				return v < 10
+			}).Count()
+			return nil
+		})
+		return
+	}
+
+	count, plan := query()
+	assert.Equal(t, 9, count)
+	assert.Equal(t, "With", plan.Steps[0].Operation)
+
+	// Once the scan was observed to be selective, it is applied first
+	count, plan = query()
+	assert.Equal(t, 9, count)
+	assert.Equal(t, "WithFloat", plan.Steps[0].Operation)
+	assert.Equal(t, 10, plan.Steps[0].Actual)
+}
+
+func TestFilterBeforeUnion(t *testing.T) {
+	players := loadPlayers(500)
+	humans := countWith(players, "human")
+	elves := countWith(players, "elf")
+
+	// Pending filters must be applied before the union
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, humans+elves, txn.With("human").Union("elf").Count())
+		return nil
+	})
+}
+
+// countWith counts the rows in the specified index
+func countWith(c *Collection, index string) (count int) {
+	c.Query(func(txn *Txn) error {
+		count = txn.With(index).Count()
+		return nil
+	})
+	return
+}
+
+func TestRangeParallel(t *testing.T) {
+	players := loadPlayers(60000)
+	players.Query(func(txn *Txn) error {
+		expect := 0.0
+		balance := txn.Float64("balance")
+		txn.With("human").Range(func(idx uint32) {
+			v, _ := balance.Get()
+			expect += v
+		})
+
+		var lock sync.Mutex
+		actual := 0.0
+		assert.NoError(t, txn.RangeParallel(4, func(idx uint32, r Row) {
+			v, _ := r.Float64("balance")
+			lock.Lock()
+			actual += v
+			lock.Unlock()
+		}))
+
+		assert.InDelta(t, expect, actual, 0.01)
+		assert.Equal(t, txn.Count(), txn.CountParallel(4))
+		assert.Equal(t, txn.Count(), txn.CountParallel(0))
+		return nil
+	})
+}
+
+func TestAggregate(t *testing.T) {
+	players := loadPlayers(60000)
+	players.Query(func(txn *Txn) error {
+		expect := Aggregate{Min: math.MaxFloat64}
+		age := txn.Float64("age")
+		txn.With("old").Range(func(idx uint32) {
+			v, _ := age.Get()
+			expect.Count++
+			expect.Sum += v
+			expect.Min = math.Min(expect.Min, v)
+			expect.Max = math.Max(expect.Max, v)
+		})
+
+		actual, err := txn.Aggregate("age", 8)
+		assert.NoError(t, err)
+		assert.Equal(t, expect.Count, actual.Count)
+		assert.InDelta(t, expect.Sum, actual.Sum, 0.01)
+		assert.Equal(t, expect.Min, actual.Min)
+		assert.Equal(t, expect.Max, actual.Max)
+		assert.InDelta(t, expect.Sum/float64(expect.Count), actual.Avg(), 0.01)
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		_, err := txn.Aggregate("race", 2)
+		assert.Error(t, err)
+
+		empty, err := txn.With("invalid").Aggregate("age", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, empty.Avg())
+		return nil
+	})
+}
+
+func TestWithFloatRange(t *testing.T) {
+	players := loadPlayers(500)
+	players.CreateColumn("level", ForInt32(WithEncoding(Delta)))
+	players.Query(func(txn *Txn) error {
+		level := txn.Int32("level")
+		return txn.Range(func(idx uint32) {
+			level.Set(int32(idx % 100))
+		})
+	})
+
+	count := func(fn func(txn *Txn) *Txn) (n int) {
+		players.Query(func(txn *Txn) error {
+			n = fn(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	tests := []struct {
+		expect func(txn *Txn) *Txn
+		actual func(txn *Txn) *Txn
+	}{
+		{
+			expect: func(txn *Txn) *Txn { return txn.WithFloat("age", func(v float64) bool { return v > 30 }) },
+			actual: func(txn *Txn) *Txn { return txn.WithFloatGreater("age", 30) },
+		},
+		{
+			expect: func(txn *Txn) *Txn { return txn.WithFloat("age", func(v float64) bool { return v < 30 }) },
+			actual: func(txn *Txn) *Txn { return txn.WithFloatLess("age", 30) },
+		},
+		{
+			expect: func(txn *Txn) *Txn {
+				return txn.With("human").WithFloat("balance", func(v float64) bool { return v >= 1000 && v <= 2000 })
+			},
+			actual: func(txn *Txn) *Txn { return txn.With("human").WithFloatBetween("balance", 1000, 2000) },
+		},
+		{
+			expect: func(txn *Txn) *Txn { return txn.WithInt("level", func(v int64) bool { return v >= 10 && v <= 20 }) },
+			actual: func(txn *Txn) *Txn { return txn.WithFloatBetween("level", 10, 20) },
+		},
+		{
+			expect: func(txn *Txn) *Txn { return txn.WithFloat("invalid", func(v float64) bool { return true }) },
+			actual: func(txn *Txn) *Txn { return txn.WithFloatGreater("invalid", 0) },
+		},
+	}
+
+	for _, tc := range tests {
+		expect := count(tc.expect)
+		assert.Equal(t, expect, count(tc.actual))
+	}
+
+	assert.Equal(t, 55, count(func(txn *Txn) *Txn { return txn.WithFloatBetween("level", 10, 20) }))
+}
+
+func TestFloat32(t *testing.T) {
+	players := loadPlayers(500)
+	players.CreateColumn("score", ForFloat32())
+	players.Query(func(txn *Txn) error {
+		score := txn.Float32("score")
+		return txn.Range(func(idx uint32) {
+			score.Set(float32(idx%100) / 4)
+		})
+	})
+
+	players.Query(func(txn *Txn) error {
+		expect := txn.WithFloat("score", func(v float64) bool { return v >= 5 && v <= 10 }).Count()
+		assert.Equal(t, 105, expect)
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 105, txn.WithFloatBetween("score", 5, 10).Count())
+
+		agg, err := txn.WithFloatGreater("score", 9.75).Aggregate("score", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, agg.Count)
+		assert.Equal(t, 10.0, agg.Min)
+		assert.Equal(t, 50.0, agg.Sum)
+		return nil
+	})
+}
+
+func TestRangeBatch(t *testing.T) {
+	players := loadPlayers(60000)
+	players.Query(func(txn *Txn) error {
+		expectSum, expectCount := 0.0, 0
+		age := txn.Float64("age")
+		txn.With("human").Range(func(idx uint32) {
+			v, _ := age.Get()
+			expectSum += v
+			expectCount++
+		})
+
+		batches, sum, count := 0, 0.0, 0
+		assert.NoError(t, txn.RangeBatch(func(idxs []uint32, cols ...ColumnSlice) {
+			assert.Equal(t, "age", cols[0].Name())
+			assert.Len(t, cols[0].Float64s(), len(idxs))
+			assert.Len(t, cols[1].Strings(), len(idxs))
+			assert.Equal(t, "human", cols[1].Strings()[0])
+			for _, v := range cols[0].Float64s() {
+				sum += v
+			}
+
+			batches++
+			count += len(idxs)
+		}, "age", "race"))
+
+		assert.Equal(t, 4, batches)
+		assert.Equal(t, expectCount, count)
+		assert.InDelta(t, expectSum, sum, 0.01)
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Error(t, txn.RangeBatch(func(idxs []uint32, cols ...ColumnSlice) {}, "invalid"))
+		return nil
+	})
+}
+
+func TestMemoryLimit(t *testing.T) {
+	players := loadPlayers(60000)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.WithMemoryLimit(1<<30).With("human").RangeBatch(func(idxs []uint32, cols ...ColumnSlice) {
+			assert.NotEmpty(t, cols[0].Float64s())
+		}, "age")
+	}))
+
+	// The batches are not processed once they exceed the limit
+	err := players.Query(func(txn *Txn) error {
+		return txn.WithMemoryLimit(1<<10).With("human").RangeBatch(func(idxs []uint32, cols ...ColumnSlice) {
+			assert.Fail(t, "batch processed over the memory limit")
+		}, "age")
+	})
+	assert.True(t, errors.Is(err, ErrMemoryLimit))
+
+	var memory *MemoryError
+	assert.True(t, errors.As(err, &memory))
+	assert.Equal(t, 1<<10, memory.Limit)
+	assert.Greater(t, memory.Requested, 1<<10)
+
+	// The groups of a downsampled query are bounded as well
+	err = players.Query(func(txn *Txn) error {
+		_, err := txn.WithMemoryLimit(256).Downsample("age", time.Nanosecond).Aggregate(Sum("balance"))
+		return err
+	})
+	assert.True(t, errors.Is(err, ErrMemoryLimit))
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		buckets, err := txn.WithMemoryLimit(1<<20).Downsample("age", time.Hour).Aggregate(Sum("balance"))
+		assert.NoError(t, err)
+		assert.Len(t, buckets, 1)
+		return nil
+	}))
+}
+
+func TestInsertObjects(t *testing.T) {
+	data := loadFixture("players.json")
+	expect := loadPlayers(500)
+	defer expect.Close()
+
+	players := newEmpty(500)
+	defer players.Close()
+
+	var rows []uint32
+	assert.NoError(t, players.Query(func(txn *Txn) (err error) {
+		rows, err = txn.InsertObjects(data)
+		return
+	}))
+
+	// Every row is inserted at its own index, in the order of the objects
+	assert.Len(t, rows, len(data))
+	assert.Equal(t, expect.Count(), players.Count())
+	for i, idx := range rows {
+		assert.Equal(t, uint32(i), idx)
+	}
+
+	// The values and the indexes are the same as if they were inserted one by one
+	for _, index := range []string{"human", "elf", "mage", "old"} {
+		assert.Equal(t, countWhere(expect, func(txn *Txn) *Txn { return txn.With(index) }),
+			countWhere(players, func(txn *Txn) *Txn { return txn.With(index) }))
+	}
+
+	assert.NoError(t, players.QueryAt(rows[42], func(r Row) error {
+		name, _ := r.Enum("name")
+		age, _ := r.Float64("age")
+		assert.Equal(t, data[42]["name"], name)
+		assert.Equal(t, data[42]["age"], age)
+		return nil
+	}))
+}
+
+func TestCountApprox(t *testing.T) {
+	players := loadPlayers(50000)
+	defer players.Close()
+
+	exact := countWhere(players, isOld)
+	players.Query(func(txn *Txn) error {
+		// A single chunk is scanned, and the rest is extrapolated
+		estimate := isOld(txn).CountApprox(0)
+		assert.False(t, estimate.Exact)
+		assert.InDelta(t, exact, estimate.Count, 0.1*float64(exact))
+		assert.LessOrEqual(t, estimate.Lower, exact)
+		assert.GreaterOrEqual(t, estimate.Upper, exact)
+
+		// With enough time, every chunk is scanned and the filters are still pending
+		assert.Equal(t, CountEstimate{Count: exact, Lower: exact, Upper: exact, Exact: true}, txn.CountApprox(time.Minute))
+		assert.Equal(t, exact, txn.Count())
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, CountEstimate{Count: 50000, Lower: 50000, Upper: 50000, Exact: true}, txn.CountApprox(0))
+		return nil
+	})
+}
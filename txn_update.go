@@ -0,0 +1,159 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Mutation represents a declarative change of a single column, which can be applied
+// on every row of the current selection of a transaction.
+type Mutation struct {
+	op     commit.OpType // The operation to perform
+	column string        // The name of the target column
+	value  interface{}   // The value to store or the delta to add
+}
+
+// Set creates a mutation which stores a value in the specified column.
+func Set(columnName string, value interface{}) Mutation {
+	return Mutation{
+		op:     commit.Put,
+		column: columnName,
+		value:  value,
+	}
+}
+
+// Add creates a mutation which atomically adds a delta to the value of the specified
+// numeric column.
+func Add(columnName string, delta interface{}) Mutation {
+	return Mutation{
+		op:     commit.Add,
+		column: columnName,
+		value:  delta,
+	}
+}
+
+// Merge creates a mutation which merges a delta into the value of the specified column,
+// with the merge function the column was created with.
+func Merge(columnName string, delta interface{}) Mutation {
+	return Mutation{
+		op:     commit.Merge,
+		column: columnName,
+		value:  delta,
+	}
+}
+
+// mutation represents a mutation resolved against a specific column of the transaction.
+type mutation struct {
+	op     commit.OpType  // The operation to perform
+	value  interface{}    // The value converted to the native column type
+	buffer *commit.Buffer // The buffer to write the mutation into
+}
+
+// Update applies a set of mutations on every row of the current selection in a single
+// pass over the selection and returns the number of rows updated. The actual updates
+// will take place once the transaction is committed.
+func (txn *Txn) Update(mutations ...Mutation) (int, error) {
+	resolved := make([]mutation, 0, len(mutations))
+	for _, m := range mutations {
+		c, ok := txn.columnAt(m.column)
+		if !ok {
+			return 0, errorOf(ErrColumnNotFound, "column: column '%s' does not exist", m.column)
+		}
+
+		value, err := m.valueOf(c.Column)
+		if err != nil {
+			return 0, err
+		}
+
+		resolved = append(resolved, mutation{
+			op:     m.op,
+			value:  value,
+			buffer: txn.bufferFor(m.column),
+		})
+	}
+
+	count := 0
+	txn.initialize()
+	txn.rangeRead(func(offset uint32, index bitmap.Bitmap) {
+		index.Range(func(x uint32) {
+			count++
+			for _, m := range resolved {
+				switch m.op {
+				case commit.Add:
+					m.buffer.AddAny(offset+x, m.value)
+				case commit.Merge:
+					m.buffer.MergeAny(offset+x, m.value)
+				default:
+					m.buffer.PutAny(m.op, offset+x, m.value)
+				}
+			}
+		})
+	})
+	return count, nil
+}
+
+// valueOf converts the value of the mutation to the native type of the column.
+func (m *Mutation) valueOf(c Column) (interface{}, error) {
+	typ, isNumber := numericTypeOf(c)
+	switch {
+	case m.op == commit.Add && !isNumber:
+		return nil, errorOf(ErrTypeMismatch, "column: unable to add to '%s', column is not numeric", m.column)
+	case m.op == commit.Merge && mergeOf(c) == nil:
+		return nil, fmt.Errorf("column: unable to merge into '%s', column has no merge function", m.column)
+	case !isNumber:
+		return valueFor(m.column, c, m.value)
+	case reflect.TypeOf(m.value) == typ:
+		return m.value, nil
+	}
+
+	value := reflect.ValueOf(m.value)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return value.Convert(typ).Interface(), nil
+	default:
+		return nil, errorOf(ErrTypeMismatch, "column: unable to update '%s', %T is not a number", m.column, m.value)
+	}
+}
+
+// numericTypeOf returns the native type of a numeric column.
+func numericTypeOf(c Column) (reflect.Type, bool) {
+	switch c.(type) {
+	case *float32Column:
+		return reflect.TypeOf(float32(0)), true
+	case *float64Column:
+		return reflect.TypeOf(float64(0)), true
+	case *intColumn:
+		return reflect.TypeOf(int(0)), true
+	case *int8Column:
+		return reflect.TypeOf(int8(0)), true
+	case *int16Column:
+		return reflect.TypeOf(int16(0)), true
+	case *int32Column:
+		return reflect.TypeOf(int32(0)), true
+	case *int64Column:
+		return reflect.TypeOf(int64(0)), true
+	case *columnDuration:
+		return reflect.TypeOf(time.Duration(0)), true
+	case *uintColumn:
+		return reflect.TypeOf(uint(0)), true
+	case *uint8Column:
+		return reflect.TypeOf(uint8(0)), true
+	case *uint16Column:
+		return reflect.TypeOf(uint16(0)), true
+	case *uint32Column:
+		return reflect.TypeOf(uint32(0)), true
+	case *uint64Column:
+		return reflect.TypeOf(uint64(0)), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+)
+
+// pin read-locks every chunk of the collection until the transaction ends, so that the
+// views over the buffers of the columns are not modified meanwhile, and returns whether
+// the column can be viewed. Since the chunks are then locked, the transaction reads them
+// without locking them again. The views must be created before ranging over the rows,
+// as the chunk being ranged over is already read-locked.
+func (txn *Txn) pin(columnName string, encoding Encoding) bool {
+	if encoding != Plain {
+		txn.abort(fmt.Errorf("column: unable to view '%s', column is encoded", columnName))
+		return false
+	}
+
+	if txn.unlocked {
+		return true
+	}
+
+	for shard := uint(0); shard < 128; shard++ {
+		txn.owner.slock.RLock(shard)
+	}
+
+	txn.unlocked = true
+	txn.pinned = true
+	return true
+}
+
+// unpin releases the chunks pinned by the views, once the transaction ends and before its
+// changes are committed.
+func (txn *Txn) unpin() {
+	if !txn.pinned {
+		return
+	}
+
+	for shard := uint(0); shard < 128; shard++ {
+		txn.owner.slock.RUnlock(shard)
+	}
+
+	txn.unlocked = false
+	txn.pinned = false
+}
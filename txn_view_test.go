@@ -0,0 +1,69 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnSlice(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	var written int32
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		ages, valid := txn.Float64Slice("age")
+		assert.GreaterOrEqual(t, len(ages), 500)
+		assert.Equal(t, 500, valid.Count())
+
+		// The values are the ones of the column, without a copy
+		age := txn.Float64("age")
+		assert.NoError(t, txn.Range(func(idx uint32) {
+			v, _ := age.Get()
+			assert.Equal(t, v, ages[idx])
+		}))
+
+		// The concurrent writers wait for the transaction to end
+		go func() {
+			players.QueryAt(0, func(r Row) error {
+				r.SetFloat64("age", 1000)
+				return nil
+			})
+			atomic.StoreInt32(&written, 1)
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		assert.Zero(t, atomic.LoadInt32(&written))
+		assert.NotEqual(t, 1000.0, ages[0])
+
+		// The transaction can still write, once the views are released
+		age.Set(10)
+		return nil
+	}))
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&written) == 1
+	}, time.Second, time.Millisecond)
+
+	// The column must exist, be of the type and not be encoded
+	assert.NoError(t, players.CreateColumn("score", ForInt64(WithEncoding(Delta))))
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		txn.Float64Slice("missing")
+		return nil
+	}), ErrColumnNotFound)
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		txn.Int32Slice("age")
+		return nil
+	}), ErrTypeMismatch)
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		values, valid := txn.Int64Slice("score")
+		assert.Nil(t, values)
+		assert.Nil(t, valid)
+		return nil
+	}))
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// versionColumn is the internal column holding the version of every row, if versioned. Its
+// name is reserved so that it never collides with a column of the user, who can not write it.
+const versionColumn = "internal:version"
+
+// Version returns the version of the row, which starts at 1 once the row is inserted and is
+// incremented by every commit which changes it. This returns 0 if the row does not exist or
+// if the Versioned option of the collection is not set. The version only changes once the
+// transaction is committed, and not with the pending changes of the transaction itself,
+// which makes it suitable as an HTTP ETag for read-modify-write across requests.
+func (r Row) Version() uint64 {
+	versions, ok := r.txn.columnAt(versionColumn)
+	if !ok {
+		return 0
+	}
+
+	if v, ok := versions.Value(r.txn.cursor); ok {
+		return v.(uint64)
+	}
+	return 0
+}
+
+// IfVersion checks whether the row is still at the specified version and returns whether it
+// is. The check is repeated once the transaction is committed and, if a concurrent
+// transaction has changed the row in the meantime, the whole transaction is rolled back and
+// returns ErrConflict. This allows a caller to
+// update a row only if it is unchanged since it was read, for example given the version of
+// an If-Match header. If the collection is not versioned, the transaction is aborted.
+func (r Row) IfVersion(version uint64) bool {
+	if _, ok := r.txn.columnAt(versionColumn); !ok {
+		r.txn.abort(errorOf(ErrColumnNotFound, "column: unable to check the version, collection is not versioned"))
+		return false
+	}
+
+	if r.Version() != version {
+		return false
+	}
+
+	// A row without a version is expected to have no value
+	var expected interface{}
+	if version > 0 {
+		expected = version
+	}
+
+	r.txn.swaps = append(r.txn.swaps, swap{
+		column:   versionColumn,
+		index:    r.txn.cursor,
+		expected: expected,
+	})
+	return true
+}
+
+// commitVersions increments the version of every row inserted or changed by the transaction,
+// as part of the same commit, so that the versions are replicated and persisted along with
+// the changes. The versions are kept as is if the transaction writes them itself, as the
+// replayed commits do.
+func (txn *Txn) commitVersions() {
+	var changed, deleted bitmap.Bitmap
+	for _, u := range txn.updates {
+		switch {
+		case u.IsEmpty(), u.Column == expireColumn, u.Column == tombstoneColumn:
+			continue
+		case u.Column == versionColumn:
+			return
+		}
+
+		u.RangeChunks(func(chunk commit.Chunk) {
+			txn.reader.Range(u, chunk, func(r *commit.Reader) {
+				for r.Next() {
+					switch {
+					case u.Column == rowColumn && r.Type == commit.Delete:
+						deleted.Set(r.Index())
+					default:
+						changed.Set(r.Index())
+					}
+				}
+			})
+		})
+	}
+
+	// The deleted rows lose their version along with their values
+	changed.AndNot(deleted)
+	changed.AndNot(txn.deletes)
+	if changed.Count() == 0 {
+		return
+	}
+
+	versions := txn.owner.txns.acquirePage(versionColumn)
+	txn.updates = append(txn.updates, versions)
+	changed.Range(func(idx uint32) {
+		versions.AddUint64(idx, 1)
+	})
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersion(t *testing.T) {
+	col := NewCollection(Options{Versioned: true})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("balance", ForInt())
+	defer col.Close()
+
+	idx := col.InsertObject(Object{"name": "Roman", "balance": 10})
+	other := col.InsertObject(Object{"name": "Alice", "balance": 10})
+	assert.Equal(t, uint64(1), versionOf(col, idx))
+
+	// Every commit which changes the row increments its version once
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		r.SetString("name", "Roman A.")
+		r.AddInt("balance", 5)
+		assert.Equal(t, uint64(1), r.Version())
+		return nil
+	}))
+	assert.Equal(t, uint64(2), versionOf(col, idx))
+	assert.Equal(t, uint64(1), versionOf(col, other))
+
+	// A transaction which only reads the row keeps its version
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		r.String("name")
+		return nil
+	}))
+	assert.Equal(t, uint64(2), versionOf(col, idx))
+
+	// The version is internal and not part of the objects or the columns
+	assert.Equal(t, []string{"balance", "name"}, col.Columns())
+
+	// Deleted rows have no version
+	assert.True(t, col.DeleteAt(other))
+	assert.Equal(t, uint64(0), versionOf(col, other))
+}
+
+func TestIfVersion(t *testing.T) {
+	col := NewCollection(Options{Versioned: true})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("balance", ForInt())
+	defer col.Close()
+	idx := col.InsertObject(Object{"name": "Roman", "balance": 10})
+
+	// A stale version does not match
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		assert.False(t, r.IfVersion(5))
+		return nil
+	}))
+
+	// Only one of the concurrent writers of the same version wins the row
+	var wg sync.WaitGroup
+	var winners int32
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var matched bool
+			err := col.QueryAt(idx, func(r Row) error {
+				if matched = r.IfVersion(1); matched {
+					r.AddInt("balance", 1)
+				}
+				return nil
+			})
+
+			assert.True(t, err == nil || err == ErrConflict)
+			if matched && err == nil {
+				atomic.AddInt32(&winners, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), winners)
+	assert.Equal(t, uint64(2), versionOf(col, idx))
+}
+
+func TestIfVersionConflict(t *testing.T) {
+	col := NewCollection(Options{Versioned: true})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("owner", ForString())
+	defer col.Close()
+	idx := col.InsertObject(Object{"name": "Roman"})
+
+	// A concurrent transaction commits first, so the whole transaction is rolled back
+	assert.Equal(t, ErrConflict, col.Query(func(txn *Txn) error {
+		assert.NoError(t, txn.QueryAt(idx, func(r Row) error {
+			assert.True(t, r.IfVersion(1))
+			r.SetString("name", "a")
+			r.SetString("owner", "a")
+			return nil
+		}))
+
+		return col.QueryAt(idx, func(r Row) error {
+			r.SetString("name", "b")
+			return nil
+		})
+	}))
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		name, _ := r.String("name")
+		_, hasOwner := r.String("owner")
+		assert.Equal(t, "b", name)
+		assert.False(t, hasOwner)
+		assert.Equal(t, uint64(2), r.Version())
+		return nil
+	}))
+
+	// Collections which are not versioned can not check the versions
+	plain := NewCollection()
+	defer plain.Close()
+	plain.CreateColumn("name", ForString())
+	plain.InsertObject(Object{"name": "Roman"})
+	assert.ErrorIs(t, plain.QueryAt(0, func(r Row) error {
+		assert.Equal(t, uint64(0), r.Version())
+		assert.False(t, r.IfVersion(1))
+		return nil
+	}), ErrColumnNotFound)
+}
+
+// versionOf returns the version of a row
+func versionOf(col *Collection, idx uint32) (version uint64) {
+	col.QueryAt(idx, func(r Row) error {
+		version = r.Version()
+		return nil
+	})
+	return
+}
+
+func TestVersionReserved(t *testing.T) {
+	col := NewCollection(Options{Versioned: true})
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("version", ForString())
+	defer col.Close()
+
+	// A column of the user can be named after the version
+	idx := col.InsertObject(Object{"name": "Roman", "version": "v1"})
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		r.SetString("version", "v2")
+		return nil
+	}))
+	assert.Equal(t, uint64(2), versionOf(col, idx))
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		v, _ := r.String("version")
+		assert.Equal(t, "v2", v)
+		return nil
+	}))
+
+	// The internal column of the versions can not be written
+	err := col.QueryAt(idx, func(r Row) error {
+		r.SetString("name", "Roman A.")
+		r.SetUint64(versionColumn, 100)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrReservedColumn)
+	assert.Equal(t, uint64(2), versionOf(col, idx))
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		v, _ := r.String("name")
+		assert.Equal(t, "Roman", v)
+		return nil
+	}))
+}
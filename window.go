@@ -0,0 +1,239 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Window filters down the current selection to the rows whose time, stored in a numeric
+// column as nanoseconds since the Unix epoch, is within the specified duration before now.
+// Since the rows are typically inserted in the order of their time, the zone maps of the
+// column allow the filter to only scan the most recent chunks of the collection.
+func (txn *Txn) Window(timeColumn string, width time.Duration) *Txn {
+	horizon := float64(txn.owner.now().Add(-width).UnixNano())
+	txn.filterRange(filterGreater, timeColumn, math.Nextafter(horizon, math.Inf(1)), math.Inf(1))
+	return txn
+}
+
+// Window represents a sliding window over the time column of a collection, which keeps the
+// number of rows within the window up to date for every value of a grouping column. The
+// counts are maintained as the transactions are committed and as the rows age out of the
+// window, so that reading them does not require scanning the collection.
+type Window struct {
+	lock   sync.Mutex
+	owner  *Collection
+	clock  string               // The name of the time column
+	group  string               // The name of the grouping column, if any
+	width  time.Duration        // The width of the window
+	rows   map[uint32]windowRow // The rows currently in the window
+	counts map[string]int       // The number of rows in the window, by group
+	expiry windowHeap           // The rows in the order of their time, to expire them
+}
+
+// windowRow represents a row in the window
+type windowRow struct {
+	time  int64  // The time of the row
+	group string // The group of the row
+}
+
+// Window creates a sliding window of the specified width over a numeric column holding the
+// time of the rows as nanoseconds since the Unix epoch, for example the time of an action.
+// The rows are counted for every value of the textual grouping column, for example the
+// player performing the action, or all together if the grouping column is empty. The rows
+// already in the window are counted first, while the transactions are excluded, and the
+// window must be closed once no longer needed.
+func (c *Collection) Window(timeColumn string, width time.Duration, groupColumn string) (*Window, error) {
+	if column, ok := c.cols.Load(timeColumn); !ok || !column.IsNumeric() {
+		return nil, errorOf(ErrTypeMismatch, "column: unable to create window, column '%s' is not numeric", timeColumn)
+	}
+
+	if groupColumn != "" {
+		if column, ok := c.cols.Load(groupColumn); !ok || !column.IsTextual() {
+			return nil, fmt.Errorf("column: unable to create window, column '%s' is not textual", groupColumn)
+		}
+	}
+
+	w := &Window{
+		owner:  c,
+		clock:  timeColumn,
+		group:  groupColumn,
+		width:  width,
+		rows:   make(map[uint32]windowRow, 64),
+		counts: make(map[string]int, 16),
+	}
+
+	// Exclude the transactions while the current rows are counted, so that none is missed
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+	c.lock.RLock()
+	fill := c.fill.Clone(nil)
+	c.lock.RUnlock()
+
+	horizon := w.horizon()
+	fill.Range(func(idx uint32) {
+		w.refresh(idx, horizon)
+	})
+
+	c.windows = append(c.windows, w)
+	c.observers.add(1)
+	return w, nil
+}
+
+// Count returns the number of rows of a group which are currently in the window. If the
+// window is not grouped, the group must be empty.
+func (w *Window) Count(group string) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.expire(w.horizon())
+	return w.counts[group]
+}
+
+// Counts returns the number of rows currently in the window, for every group which has
+// at least one row in it.
+func (w *Window) Counts() map[string]int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.expire(w.horizon())
+
+	out := make(map[string]int, len(w.counts))
+	for group, count := range w.counts {
+		out[group] = count
+	}
+	return out
+}
+
+// Close stops maintaining the window.
+func (w *Window) Close() {
+	c := w.owner
+	c.txlock.Lock()
+	defer c.txlock.Unlock()
+	for i, v := range c.windows {
+		if v == w {
+			c.windows = append(c.windows[:i], c.windows[i+1:]...)
+			c.observers.add(-1)
+			break
+		}
+	}
+}
+
+// horizon returns the time before which the rows are out of the window
+func (w *Window) horizon() int64 {
+	return w.owner.now().Add(-w.width).UnixNano()
+}
+
+// update counts the rows of a chunk whose time or group was changed by a transaction. This
+// is called while the chunk is locked, once the updates are applied to the columns.
+func (w *Window) update(txn *Txn, chunk commit.Chunk) {
+	var changed bitmap.Bitmap
+	for _, u := range txn.updates {
+		if u.Column != rowColumn && u.Column != w.clock && (w.group == "" || u.Column != w.group) {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				changed.Set(r.Index() - chunk.Min())
+			}
+		})
+	}
+
+	if changed.Count() == 0 {
+		return
+	}
+
+	horizon := w.horizon()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	changed.Range(func(x uint32) {
+		w.refresh(chunk.Min()+x, horizon)
+	})
+}
+
+// refresh removes a row from the window and adds it back if it is still present and its
+// time is within the window
+func (w *Window) refresh(idx uint32, horizon int64) {
+	if row, ok := w.rows[idx]; ok {
+		w.remove(idx, row)
+	}
+
+	c := w.owner
+	c.lock.RLock()
+	exists := c.fill.Contains(idx)
+	c.lock.RUnlock()
+	if !exists {
+		return
+	}
+
+	clock, ok := c.cols.Load(w.clock)
+	if !ok {
+		return
+	}
+
+	at, ok := clock.Column.(Numeric).LoadInt64(idx)
+	if !ok || at <= horizon {
+		return
+	}
+
+	var group string
+	if w.group != "" {
+		if column, ok := c.cols.Load(w.group); ok {
+			group, _ = column.Column.(Textual).LoadString(idx)
+		}
+	}
+
+	w.rows[idx] = windowRow{time: at, group: group}
+	w.counts[group]++
+	heap.Push(&w.expiry, windowItem{time: at, idx: idx})
+}
+
+// remove removes a row from the window
+func (w *Window) remove(idx uint32, row windowRow) {
+	delete(w.rows, idx)
+	if w.counts[row.group]--; w.counts[row.group] <= 0 {
+		delete(w.counts, row.group)
+	}
+}
+
+// expire removes the rows whose time is no longer within the window
+func (w *Window) expire(horizon int64) {
+	for len(w.expiry) > 0 && w.expiry[0].time <= horizon {
+		item := heap.Pop(&w.expiry).(windowItem)
+
+		// The row may have been changed or removed since it was pushed
+		if row, ok := w.rows[item.idx]; ok && row.time == item.time {
+			w.remove(item.idx, row)
+		}
+	}
+}
+
+// --------------------------- Expiration Heap ----------------------------
+
+// windowItem represents a row in the expiration heap
+type windowItem struct {
+	time int64
+	idx  uint32
+}
+
+// windowHeap is a min-heap of the rows, by their time
+type windowHeap []windowItem
+
+func (h windowHeap) Len() int            { return len(h) }
+func (h windowHeap) Less(i, j int) bool  { return h[i].time < h[j].time }
+func (h windowHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *windowHeap) Push(x interface{}) { *h = append(*h, x.(windowItem)) }
+func (h *windowHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
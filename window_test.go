@@ -0,0 +1,94 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindow(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("ts", ForInt64())
+	col.CreateColumn("player", ForString())
+	defer col.Close()
+
+	// Old actions are not counted, the recent ones are counted by player
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		col.InsertObject(Object{
+			"ts":     now.Add(-time.Hour).UnixNano(),
+			"player": "roman",
+		})
+	}
+
+	col.InsertObject(Object{"ts": now.Add(-1 * time.Minute).UnixNano(), "player": "roman"})
+	col.InsertObject(Object{"ts": now.Add(-2 * time.Minute).UnixNano(), "player": "roman"})
+	window, err := col.Window("ts", 5*time.Minute, "player")
+	assert.NoError(t, err)
+	defer window.Close()
+	assert.Equal(t, 2, window.Count("roman"))
+
+	// The inserts, updates and deletes are counted as they are committed
+	idx := col.InsertObject(Object{"ts": now.UnixNano(), "player": "merlin"})
+	col.InsertObject(Object{"player": "merlin"})
+	assert.Equal(t, map[string]int{"roman": 2, "merlin": 1}, window.Counts())
+
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		r.SetString("player", "roman")
+		return nil
+	}))
+	assert.Equal(t, map[string]int{"roman": 3}, window.Counts())
+
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		r.SetInt64("ts", now.UnixNano())
+		return nil
+	}))
+	assert.True(t, col.DeleteAt(100))
+	assert.Equal(t, 3, window.Count("roman"))
+
+	// The rows are filtered the same way by the transactions
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.Window("ts", 5*time.Minute).Count())
+		return nil
+	})
+
+	// Once closed, the window is no longer maintained
+	window.Close()
+	col.InsertObject(Object{"ts": now.UnixNano(), "player": "roman"})
+	assert.Equal(t, 3, window.Count("roman"))
+	assert.Empty(t, col.windows)
+}
+
+func TestWindowExpiry(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("ts", ForInt64())
+	col.CreateColumn("name", ForInt())
+	defer col.Close()
+
+	window, err := col.Window("ts", 50*time.Millisecond, "")
+	assert.NoError(t, err)
+	defer window.Close()
+
+	col.InsertObject(Object{"ts": time.Now().UnixNano()})
+	col.InsertObject(Object{"ts": time.Now().UnixNano()})
+	assert.Equal(t, 2, window.Count(""))
+
+	// The rows age out of the window without being changed
+	assert.Eventually(t, func() bool {
+		return window.Count("") == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, window.rows)
+
+	// The columns must be of the right type
+	other, err := col.Window("name", time.Second, "")
+	assert.NoError(t, err)
+	other.Close()
+	_, err = col.Window("missing", time.Second, "")
+	assert.Error(t, err)
+	_, err = col.Window("ts", time.Second, "name")
+	assert.Error(t, err)
+}
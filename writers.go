@@ -0,0 +1,157 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/kelindar/column/commit"
+)
+
+// DropPolicy represents what an asynchronous writer does with a commit once its buffer is full
+type DropPolicy uint8
+
+// Various drop policies
+const (
+	DropNewest DropPolicy = iota // The commit is dropped, and the buffered ones are kept
+	DropOldest                   // The oldest buffered commit is dropped to make room for it
+	DropNone                     // The commit blocks until there is room in the buffer
+)
+
+// WriterOptions represents the options of a commit writer added to a collection
+type WriterOptions struct {
+	Priority int                 // The priority of the writer, the ones of higher priority receive the commits first
+	Async    bool                // Whether the commits are buffered and appended in the background
+	Buffer   int                 // The number of commits buffered by an asynchronous writer, 1024 by default
+	Drop     DropPolicy          // What an asynchronous writer does once its buffer is full
+	OnDrop   func(commit.Commit) // The callback for the commits dropped by an asynchronous writer (optional)
+}
+
+// commitWriter represents a writer to which the commits are delivered, in addition to the
+// Writer of the options
+type commitWriter struct {
+	dst   commit.Logger      // The destination of the commits
+	opts  WriterOptions      // The options of the writer
+	queue chan commit.Commit // The buffered commits, if asynchronous
+	done  chan struct{}      // The channel closed once the buffered commits are delivered
+}
+
+// AddWriter adds a writer to which the commits are delivered after the Writer of the options,
+// so that several writers such as a replication stream and a metrics hook can be combined.
+// The writers receive every commit in the order of their priority, the highest first, and
+// in the order they were added for the same priority. A synchronous writer blocks the commit
+// until the commit is appended, while an asynchronous one buffers the commits and appends them
+// in the background, dropping them according to its drop policy once the buffer is full. The
+// returned function removes the writer, once its buffered commits are appended.
+func (c *Collection) AddWriter(dst commit.Logger, opts WriterOptions) (func(), error) {
+	if dst == nil {
+		return nil, fmt.Errorf("column: unable to add writer, destination is not specified")
+	}
+
+	w := &commitWriter{dst: dst, opts: opts}
+	if opts.Async {
+		if opts.Buffer <= 0 {
+			w.opts.Buffer = 1024
+		}
+
+		w.queue = make(chan commit.Commit, w.opts.Buffer)
+		w.done = make(chan struct{})
+		go w.run(c.ctx)
+	}
+
+	// Exclude the transactions while the writers are reordered
+	c.txlock.Lock()
+	outputs := append(append(make([]*commitWriter, 0, len(c.outputs)+1), c.outputs...), w)
+	sort.SliceStable(outputs, func(i, j int) bool {
+		return outputs[i].opts.Priority > outputs[j].opts.Priority
+	})
+	c.outputs = outputs
+	c.observers.add(1)
+	c.txlock.Unlock()
+
+	return func() {
+		c.txlock.Lock()
+		for i, v := range c.outputs {
+			if v == w {
+				c.outputs = append(c.outputs[:i:i], c.outputs[i+1:]...)
+				c.observers.add(-1)
+				break
+			}
+		}
+		c.txlock.Unlock()
+
+		if w.queue != nil {
+			close(w.queue)
+			<-w.done
+		}
+	}, nil
+}
+
+// append delivers a commit to the writer. Since the buffers of a transaction are reused once
+// it is committed, the asynchronous writers buffer a copy of the commit.
+func (w *commitWriter) append(change commit.Commit) {
+	if w.queue == nil {
+		w.dst.Append(change)
+		return
+	}
+
+	updates := make([]*commit.Buffer, 0, len(change.Updates))
+	for _, u := range change.Updates {
+		if !u.IsEmpty() {
+			updates = append(updates, u.Clone())
+		}
+	}
+	change.Updates = updates
+
+	switch w.opts.Drop {
+	case DropNone:
+		w.queue <- change
+	case DropOldest:
+		for {
+			select {
+			case w.queue <- change:
+				return
+			default:
+			}
+
+			select {
+			case oldest := <-w.queue:
+				w.drop(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case w.queue <- change:
+		default:
+			w.drop(change)
+		}
+	}
+}
+
+// drop reports a commit which was dropped
+func (w *commitWriter) drop(change commit.Commit) {
+	if w.opts.OnDrop != nil {
+		w.opts.OnDrop(change)
+	}
+}
+
+// run appends the buffered commits in the background, until the writer is removed or the
+// collection is closed
+func (w *commitWriter) run(ctx context.Context) {
+	defer close(w.done)
+	for {
+		select {
+		case change, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			w.dst.Append(change)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
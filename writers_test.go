@@ -0,0 +1,152 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderedWriter records the name of the writer for every commit appended to it
+type orderedWriter struct {
+	name  string
+	lock  *sync.Mutex
+	order *[]string
+}
+
+// Append appends the commit
+func (w *orderedWriter) Append(commit commit.Commit) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	*w.order = append(*w.order, w.name)
+	return nil
+}
+
+func TestWriterPriority(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	var lock sync.Mutex
+	var order []string
+	for _, w := range []struct {
+		name     string
+		priority int
+	}{{"metrics", 0}, {"replication", 10}, {"audit", 0}} {
+		_, err := col.AddWriter(&orderedWriter{name: w.name, lock: &lock, order: &order}, WriterOptions{
+			Priority: w.priority,
+		})
+		assert.NoError(t, err)
+	}
+
+	_, err := col.Insert(func(r Row) error {
+		r.SetString("name", "merlin")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"replication", "metrics", "audit"}, order)
+
+	_, err = col.AddWriter(nil, WriterOptions{})
+	assert.Error(t, err)
+}
+
+func TestWriterAsync(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	// The asynchronous writer receives a copy of the commits
+	writer := make(commit.Channel, 10)
+	remove, err := col.AddWriter(&writer, WriterOptions{Async: true})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		col.Insert(func(r Row) error {
+			r.SetString("name", "merlin")
+			return nil
+		})
+	}
+
+	remove()
+	assert.Len(t, writer, 3)
+	change, found := <-writer, false
+	for _, u := range change.Updates {
+		if u.Column == "name" {
+			found = true
+			r := commit.NewReader()
+			r.Seek(u)
+			assert.True(t, r.Next())
+			assert.Equal(t, "merlin", r.String())
+		}
+	}
+	assert.True(t, found)
+
+	// Once removed, the writer no longer receives the commits
+	col.Insert(func(r Row) error {
+		r.SetString("name", "merlin")
+		return nil
+	})
+	assert.Len(t, writer, 2)
+}
+
+func TestWriterDrop(t *testing.T) {
+	for _, policy := range []DropPolicy{DropNewest, DropOldest} {
+		col := NewCollection()
+		col.CreateColumn("name", ForString())
+
+		// The writer blocks until released, so that its buffer fills up
+		writer := &blockingWriter{started: make(chan uint64, 1), release: make(chan struct{})}
+		var dropped []uint64
+		remove, err := col.AddWriter(writer, WriterOptions{
+			Async:  true,
+			Buffer: 2,
+			Drop:   policy,
+			OnDrop: func(change commit.Commit) {
+				dropped = append(dropped, change.ID)
+			},
+		})
+		assert.NoError(t, err)
+
+		insert := func() {
+			col.Insert(func(r Row) error {
+				r.SetString("name", "merlin")
+				return nil
+			})
+		}
+
+		// Of the 10 commits, one is held by the writer and two are buffered
+		insert()
+		first := <-writer.started
+		for i := 0; i < 9; i++ {
+			insert()
+		}
+
+		close(writer.release)
+		remove()
+		assert.Len(t, dropped, 7)
+		if policy == DropOldest {
+			assert.Greater(t, dropped[0], first)
+		}
+		col.Close()
+	}
+}
+
+// blockingWriter blocks every commit until it is released
+type blockingWriter struct {
+	started chan uint64
+	release chan struct{}
+}
+
+// Append appends the commit
+func (w *blockingWriter) Append(commit commit.Commit) error {
+	select {
+	case w.started <- commit.ID:
+	default:
+	}
+	<-w.release
+	return nil
+}
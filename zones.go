@@ -0,0 +1,93 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"sync"
+
+	"github.com/kelindar/column/commit"
+)
+
+// zoneMap keeps the bounds of the values of a numeric column for every chunk, so that the
+// range filters skip the chunks whose values are all out of the range. The bounds are only
+// widened by the commits and never narrowed when values are overwritten or removed, so they
+// may be wider than the values of the chunk, but never narrower.
+type zoneMap struct {
+	lock  sync.RWMutex // The lock protecting the zones as they grow
+	zones []zone       // The bounds of the values, by chunk
+}
+
+// zone represents the bounds of the values of a chunk
+type zone struct {
+	min, max float64 // The inclusive bounds of the values
+	set      bool    // Whether any value was written into the chunk
+}
+
+// newZoneMap creates a new zone map
+func newZoneMap() *zoneMap {
+	return &zoneMap{
+		zones: make([]zone, 0, 4),
+	}
+}
+
+// widen widens the bounds of the chunk with the values written by the reader, once they
+// are applied to the column. This is called while the chunk is locked.
+func (z *zoneMap) widen(chunk commit.Chunk, column Numeric, r *commit.Reader) {
+	bounds, changed := z.load(chunk), false
+	for r.Next() {
+		if r.Type == commit.Delete {
+			continue
+		}
+
+		v, ok := column.LoadFloat64(r.Index())
+		switch {
+		case !ok:
+			continue
+		case math.IsNaN(v):
+			bounds = zone{min: math.Inf(-1), max: math.Inf(1), set: true}
+		case !bounds.set:
+			bounds = zone{min: v, max: v, set: true}
+		case v < bounds.min:
+			bounds.min = v
+		case v > bounds.max:
+			bounds.max = v
+		default:
+			continue
+		}
+		changed = true
+	}
+
+	if changed {
+		z.store(chunk, bounds)
+	}
+}
+
+// excludes checks whether none of the values of the chunk can be within the inclusive range,
+// in which case a range filter can skip the chunk entirely. This is called while the chunk
+// is read-locked.
+func (z *zoneMap) excludes(chunk commit.Chunk, lo, hi float64) bool {
+	bounds := z.load(chunk)
+	return !bounds.set || bounds.max < lo || bounds.min > hi
+}
+
+// load loads the bounds of a chunk
+func (z *zoneMap) load(chunk commit.Chunk) zone {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if int(chunk) < len(z.zones) {
+		return z.zones[chunk]
+	}
+	return zone{}
+}
+
+// store stores the bounds of a chunk
+func (z *zoneMap) store(chunk commit.Chunk, bounds zone) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	for len(z.zones) <= int(chunk) {
+		z.zones = append(z.zones, zone{})
+	}
+	z.zones[chunk] = bounds
+}
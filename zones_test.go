@@ -0,0 +1,91 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kelindar/column/commit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneMaps(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("time", ForInt64())
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	// Insert the rows in the order of their time
+	const count = 100000
+	for i := 0; i < count; i++ {
+		col.InsertObject(Object{"time": int64(1000 + i)})
+	}
+
+	chunks := col.chunks()
+	assert.Equal(t, 7, chunks)
+
+	// Only the last chunk is scanned for the most recent rows
+	assert.Equal(t, 100, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatGreater("time", 1000+count-101)
+	}))
+	assert.Equal(t, uint64(chunks-1), col.Stats().Pruned)
+
+	// Once a value of the first chunk is out of its bounds, the chunk is scanned again
+	assert.NoError(t, col.QueryAt(5, func(r Row) error {
+		r.SetInt64("time", 1e9)
+		return nil
+	}))
+	assert.Equal(t, 101, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatGreater("time", 1000+count-101)
+	}))
+	assert.Equal(t, uint64(2*chunks-3), col.Stats().Pruned)
+
+	// The bounds are not narrowed, but the values are filtered anyway
+	assert.True(t, col.DeleteAt(5))
+	assert.Equal(t, 100, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatGreater("time", 1000+count-101)
+	}))
+
+	// Additions widen the bounds with the resulting values
+	col.QueryAt(20000, func(r Row) error {
+		r.AddInt64("time", 1e6)
+		return nil
+	})
+	assert.Equal(t, 1, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatBetween("time", 1e6, 1e7)
+	}))
+	assert.Equal(t, 0, countWhere(col, func(txn *Txn) *Txn {
+		return txn.WithFloatLess("time", 1000)
+	}))
+}
+
+func TestZoneMap(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("score", ForFloat64())
+	col.CreateColumn("name", ForString())
+	defer col.Close()
+
+	score, _ := col.cols.Load("score")
+	name, _ := col.cols.Load("name")
+	assert.NotNil(t, score.zones)
+	assert.Nil(t, name.zones)
+
+	// A chunk without values can not match any range
+	zones := score.zones
+	assert.True(t, zones.excludes(0, math.Inf(-1), math.Inf(1)))
+
+	col.InsertObject(Object{"score": 10.0})
+	col.InsertObject(Object{"score": 20.0})
+	assert.True(t, zones.excludes(0, 21, 30))
+	assert.True(t, zones.excludes(0, 0, 9))
+	assert.False(t, zones.excludes(0, 20, 20))
+	assert.False(t, zones.excludes(0, 15, 16))
+	assert.True(t, zones.excludes(1, 15, 16))
+
+	// Not a number could be anything
+	col.InsertObject(Object{"score": math.NaN()})
+	assert.False(t, zones.excludes(0, 100, 200))
+	assert.Equal(t, zone{min: math.Inf(-1), max: math.Inf(1), set: true}, zones.load(commit.Chunk(0)))
+}